@@ -0,0 +1,478 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package action runs actions bound to Stream Deck keys.
+//
+// A Dispatcher holds the bindings of a deck's keys to actions and starts
+// the bound action when a key is pressed. Bindings may limit how often
+// their action is started and may ignore presses while it is running,
+// preventing accidental double-launches of external commands.
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/animation"
+	"github.com/kortschak/ardilla/secret"
+)
+
+// Action is performed in response to a key press.
+type Action interface {
+	// Do performs the action for the key press p.
+	// The context is cancelled when the Dispatcher
+	// that started the action is closed.
+	Do(ctx context.Context, p Press) error
+}
+
+// Func is an Action implemented by a function.
+type Func func(ctx context.Context, p Press) error
+
+// Do calls f.
+func (f Func) Do(ctx context.Context, p Press) error {
+	return f(ctx, p)
+}
+
+// Stateful is implemented by actions whose key image depends on state
+// held by the action. The Dispatcher shows the action's image when it is
+// bound and each time the action has been performed.
+type Stateful interface {
+	Action
+
+	// Image returns the image for the action's
+	// current state. If Image returns nil, the
+	// binding's image is used.
+	Image() image.Image
+}
+
+// Press is a key press that starts an action.
+type Press struct {
+	// KeyEvent is the event that started the
+	// action; the press, or the release for
+	// bindings started on release.
+	ardilla.KeyEvent
+
+	// Page is the Dispatcher's page when the
+	// action was started.
+	Page int
+
+	// PressDuration is how long the key was
+	// held for bindings started on release,
+	// and zero otherwise.
+	PressDuration time.Duration
+
+	// redraw renders the binding's image.
+	redraw func() error
+}
+
+// Redraw renders the image of the action that handled the press on its
+// key, as is done after the action has been performed. It allows Stateful
+// actions to show changes in their state that happen after Do returns,
+// for example on a timeout. Redraw does nothing if the key has since been
+// bound to another action, the Dispatcher has been closed, or the press
+// was not started by a Dispatcher.
+func (p Press) Redraw() error {
+	if p.redraw == nil {
+		return nil
+	}
+	return p.redraw()
+}
+
+// Command is an Action that runs an external program. The program is
+// killed if the action's context is cancelled.
+//
+// If Expand is true, each argument is a text/template template that is
+// executed with the Press that started the action, so that one program
+// can serve many keys, for example
+//
+//	Args: []string{"--key={{.Key}}", "--page={{.Page}}", "--held={{.PressDuration}}"}
+//
+// Each argument expands to exactly one argument of the program, and the
+// program is not run by a shell, so expanded values cannot inject further
+// arguments or commands. When the program is itself a shell given a script
+// argument, values interpolated into the script must be quoted with the
+// shellquote template function:
+//
+//	Path: "sh",
+//	Args: []string{"-c", "notify-send {{.Page | printf \"page %d\" | shellquote}}"}
+type Command struct {
+	// Path is the program to run. If Path contains
+	// no path separators, it is resolved using the
+	// directories in the PATH environment variable.
+	Path string
+
+	// Args are the arguments passed to the program,
+	// not including the program name.
+	Args []string
+
+	// Dir is the working directory of the program.
+	// If Dir is empty the program runs in the current
+	// directory.
+	Dir string
+
+	// Env is the environment of the program. If Env
+	// is nil the program uses the current process's
+	// environment.
+	Env []string
+
+	// Stdout and Stderr receive the program's output.
+	// If they are nil the output is discarded.
+	Stdout, Stderr io.Writer
+
+	// Expand is whether Args are expanded as
+	// templates.
+	Expand bool
+
+	// Secrets maps environment variable names to
+	// secret references that are looked up with
+	// secret.Lookup each time the command is run
+	// and added to its environment, so credentials
+	// need not be held in the profile.
+	Secrets map[string]string
+}
+
+// Do runs the command and waits for it to complete.
+func (c *Command) Do(ctx context.Context, p Press) error {
+	args := c.Args
+	if c.Expand {
+		var err error
+		args, err = expand(c.Args, p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.Path, err)
+		}
+	}
+	env, err := secret.AppendEnv(ctx, c.Env, c.Secrets)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Path, err)
+	}
+	cmd := exec.CommandContext(ctx, c.Path, args...)
+	cmd.Dir = c.Dir
+	cmd.Env = env
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Path, err)
+	}
+	return nil
+}
+
+// Binding is the binding of an action to a key.
+type Binding struct {
+	// Action is started when the key is pressed.
+	Action Action
+
+	// Image is the image shown on the key. It is
+	// set when the binding is made and restored
+	// after the busy indicator is shown. If Image
+	// is nil the key's image is not changed by
+	// Bind, and black is restored after the busy
+	// indicator is shown.
+	Image image.Image
+
+	// MinInterval is the minimum interval between
+	// starts of the action. Presses less than
+	// MinInterval after the press that last started
+	// the action are ignored. Intervals are measured
+	// using the times of the key events.
+	MinInterval time.Duration
+
+	// Exclusive is whether presses are ignored
+	// while the action is running. A busy indicator
+	// is shown on the key if an exclusive action is
+	// still running after a short delay.
+	Exclusive bool
+
+	// OnRelease is whether the action is started
+	// when the key is released rather than when it
+	// is pressed, so that the action can see how
+	// long the key was held.
+	OnRelease bool
+}
+
+// Dispatcher starts the actions bound to the keys of a deck.
+type Dispatcher struct {
+	d *ardilla.Deck
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// clock times the busy indicator.
+	clock animation.Clock
+
+	mu         sync.Mutex
+	bindings   map[int]*binding
+	page       int
+	suppressed bool
+	onError    func(ev ardilla.KeyEvent, err error)
+}
+
+// binding is the state of a key binding.
+type binding struct {
+	Binding
+
+	// last is the time of the press
+	// that last started the action.
+	last time.Time
+
+	// running is the number of running
+	// instances of the action.
+	running int
+
+	// pressed is the time the key was pressed
+	// for bindings started on release, or zero
+	// if the key is not held.
+	pressed time.Time
+}
+
+// New returns a new Dispatcher for the deck.
+func New(d *ardilla.Deck) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{d: d, ctx: ctx, cancel: cancel, clock: animation.SystemClock, bindings: make(map[int]*binding)}
+}
+
+// SetErrorHandler sets a function to be called with errors returned by
+// actions and with errors rendering the busy indicator. If fn is nil,
+// errors are discarded. The function may be called concurrently.
+func (p *Dispatcher) SetErrorHandler(fn func(ev ardilla.KeyEvent, err error)) {
+	p.mu.Lock()
+	p.onError = fn
+	p.mu.Unlock()
+}
+
+// SetPage sets the page number passed to actions in Press.Page. The
+// Dispatcher does not interpret pages; programs that show pages of
+// bindings, for example with ui.Pages, should set the page when it is
+// changed.
+func (p *Dispatcher) SetPage(page int) {
+	p.mu.Lock()
+	p.page = page
+	p.mu.Unlock()
+}
+
+// Suppress sets whether key events are ignored, so that no actions are
+// started, for example while the host's session is locked. Running
+// actions are not stopped. Keys held when suppression starts do not start
+// actions bound to their release.
+func (p *Dispatcher) Suppress(suppress bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.suppressed = suppress
+	if suppress {
+		for _, b := range p.bindings {
+			b.pressed = time.Time{}
+		}
+	}
+}
+
+// Suppressed returns whether key events are being ignored.
+func (p *Dispatcher) Suppressed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.suppressed
+}
+
+// Bind binds the action described by b to the key at row and col,
+// replacing any existing binding. The image of a Stateful action, or
+// b.Image if the action is not Stateful or has no image for its state, is
+// set on the key if it is not nil. Running instances of a replaced action
+// are not stopped.
+func (p *Dispatcher) Bind(row, col int, b Binding) error {
+	key, err := p.key(row, col)
+	if err != nil {
+		return err
+	}
+	if b.Action == nil {
+		return errors.New("missing action")
+	}
+	if b.MinInterval < 0 {
+		return fmt.Errorf("negative interval: %v", b.MinInterval)
+	}
+	bb := &binding{Binding: b}
+	if img := bb.image(); img != nil {
+		err = p.d.SetImage(row, col, img)
+		if err != nil {
+			return err
+		}
+	}
+	p.mu.Lock()
+	p.bindings[key] = bb
+	p.mu.Unlock()
+	return nil
+}
+
+// image returns the image to show for the binding.
+func (b *binding) image() image.Image {
+	if s, ok := b.Action.(Stateful); ok {
+		if img := s.Image(); img != nil {
+			return img
+		}
+	}
+	return b.Image
+}
+
+// Unbind removes the binding of the key at row and col.
+func (p *Dispatcher) Unbind(row, col int) {
+	key, err := p.key(row, col)
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	delete(p.bindings, key)
+	p.mu.Unlock()
+}
+
+// key returns the key number for row and col.
+func (p *Dispatcher) key(row, col int) (int, error) {
+	rows, cols := p.d.Layout()
+	if row < 0 || rows <= row {
+		return 0, fmt.Errorf("row out of bounds: %d", row)
+	}
+	if col < 0 || cols <= col {
+		return 0, fmt.Errorf("column out of bounds: %d", col)
+	}
+	return p.d.Key(row, col), nil
+}
+
+// Handle starts the action bound to the key of ev if ev is a press, or a
+// release for bindings started on release, and the action is not
+// suppressed by Suppress, by the binding's rate limit or because the
+// binding is exclusive and its action is running. Handle returns whether an action
+// was started. Actions run in their own goroutines.
+func (p *Dispatcher) Handle(ev ardilla.KeyEvent) bool {
+	if ev.Stuck || ev.Lagged != 0 || ev.Err != nil {
+		return false
+	}
+	if p.ctx.Err() != nil {
+		return false
+	}
+	p.mu.Lock()
+	b, ok := p.bindings[ev.Key]
+	if !ok || p.suppressed {
+		p.mu.Unlock()
+		return false
+	}
+	press := Press{KeyEvent: ev, Page: p.page}
+	press.redraw = func() error { return p.redraw(ev, b) }
+	switch {
+	case b.OnRelease && ev.Pressed:
+		b.pressed = ev.Time
+		p.mu.Unlock()
+		return false
+	case b.OnRelease:
+		if b.pressed.IsZero() {
+			// The press was not seen.
+			p.mu.Unlock()
+			return false
+		}
+		press.PressDuration = ev.Time.Sub(b.pressed)
+		b.pressed = time.Time{}
+	case !ev.Pressed:
+		p.mu.Unlock()
+		return false
+	}
+	if (b.Exclusive && b.running != 0) || (!b.last.IsZero() && ev.Time.Sub(b.last) < b.MinInterval) {
+		p.mu.Unlock()
+		return false
+	}
+	b.last = ev.Time
+	b.running++
+	p.wg.Add(1)
+	p.mu.Unlock()
+
+	go func() {
+		defer p.wg.Done()
+		var busy *ardilla.Animation
+		if b.Exclusive {
+			var err error
+			busy, err = p.busy(ev, b.image())
+			if err != nil {
+				p.error(ev, err)
+			}
+		}
+		err := b.Action.Do(p.ctx, press)
+		if err != nil {
+			p.error(ev, err)
+		}
+		var shown bool
+		if busy != nil {
+			busy.Stop()
+			shown = busy.Wait() == errShown
+		}
+		_, stateful := b.Action.(Stateful)
+		if img := b.image(); shown || (stateful && img != nil) {
+			err = p.restore(ev, img)
+			if err != nil {
+				p.error(ev, err)
+			}
+		}
+		p.mu.Lock()
+		b.running--
+		p.mu.Unlock()
+	}()
+	return true
+}
+
+// Redraw renders the image of the action bound to the key at row and col,
+// as is done after the action has been performed, so that changes to the
+// state of a Stateful action made elsewhere are shown. Redraw does nothing
+// if no action is bound to the key.
+func (p *Dispatcher) Redraw(row, col int) error {
+	key, err := p.key(row, col)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	b, ok := p.bindings[key]
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return p.redraw(ardilla.KeyEvent{Key: key, Row: row, Col: col}, b)
+}
+
+// redraw renders the image of b on the key of ev if b is still bound to
+// the key.
+func (p *Dispatcher) redraw(ev ardilla.KeyEvent, b *binding) error {
+	if p.ctx.Err() != nil {
+		return nil
+	}
+	p.mu.Lock()
+	current := p.bindings[ev.Key] == b
+	p.mu.Unlock()
+	if !current {
+		return nil
+	}
+	return p.restore(ev, b.image())
+}
+
+// error reports err for ev to the error handler.
+func (p *Dispatcher) error(ev ardilla.KeyEvent, err error) {
+	p.mu.Lock()
+	fn := p.onError
+	p.mu.Unlock()
+	if fn != nil {
+		fn(ev, err)
+	}
+}
+
+// Wait waits for all running actions to complete.
+func (p *Dispatcher) Wait() {
+	p.wg.Wait()
+}
+
+// Close cancels the contexts of running actions and waits for them to
+// complete. Presses handled after Close do not start actions.
+func (p *Dispatcher) Close() {
+	p.cancel()
+	p.wg.Wait()
+}
@@ -0,0 +1,24 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package action
+
+import "context"
+
+// Run starts the actions bound to keys pressed on the dispatcher's deck
+// until ctx is cancelled or reading events fails, returning the error that
+// ended the event stream. Running actions are not waited for.
+//
+// Run requires Go 1.23.
+func (p *Dispatcher) Run(ctx context.Context) error {
+	for ev := range p.d.Events(ctx) {
+		if ev.Err != nil {
+			return ev.Err
+		}
+		p.Handle(ev)
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,657 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package action
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/animation"
+	"github.com/kortschak/ardilla/internal/decktest"
+	"github.com/kortschak/ardilla/layout"
+	"github.com/kortschak/ardilla/secret"
+	"github.com/kortschak/ardilla/state"
+)
+
+var start = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func press(row, col int, at time.Duration) ardilla.KeyEvent {
+	return ardilla.KeyEvent{Time: start.Add(at), Key: row*5 + col, Row: row, Col: col, Pressed: true}
+}
+
+func TestRateLimit(t *testing.T) {
+	d, _ := decktest.New(t)
+	p := New(d)
+	defer p.Close()
+
+	var n atomic.Int64
+	err := p.Bind(0, 0, Binding{
+		Action: Func(func(ctx context.Context, _ Press) error {
+			n.Add(1)
+			return nil
+		}),
+		MinInterval: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error binding action: %v", err)
+	}
+	for _, test := range []struct {
+		ev   ardilla.KeyEvent
+		want bool
+	}{
+		{ev: press(0, 0, 0), want: true},
+		{ev: press(0, 0, 50*time.Millisecond), want: false},
+		{ev: press(0, 0, 120*time.Millisecond), want: true},
+		{ev: press(0, 0, 200*time.Millisecond), want: false},
+		{ev: ardilla.KeyEvent{Time: start.Add(time.Second), Key: 0}, want: false},
+		{ev: press(0, 1, time.Second), want: false},
+	} {
+		if got := p.Handle(test.ev); got != test.want {
+			t.Errorf("unexpected result for press at %v: got:%t want:%t", test.ev.Time.Sub(start), got, test.want)
+		}
+	}
+	p.Wait()
+	if got := n.Load(); got != 2 {
+		t.Errorf("unexpected number of action runs: got:%d want:2", got)
+	}
+}
+
+func TestExclusive(t *testing.T) {
+	d, dev := decktest.New(t)
+	p := New(d)
+	defer p.Close()
+	clock := animation.NewManualClock(start)
+	p.clock = clock
+
+	release := make(chan struct{})
+	var n atomic.Int64
+	err := p.Bind(1, 2, Binding{
+		Action: Func(func(ctx context.Context, _ Press) error {
+			n.Add(1)
+			<-release
+			return nil
+		}),
+		Image:     layout.Fill(image.Rect(0, 0, 72, 72), color.RGBA{R: 0xff, A: 0xff}),
+		Exclusive: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error binding action: %v", err)
+	}
+	if got := dev.Colour(1, 2); got != "red" {
+		t.Errorf("unexpected colour after binding: got:%s want:red", got)
+	}
+
+	if !p.Handle(press(1, 2, 0)) {
+		t.Fatal("first press did not start action")
+	}
+	if p.Handle(press(1, 2, time.Second)) {
+		t.Error("press while running started action")
+	}
+
+	// Show the busy indicator and wait for its first
+	// frame to be written.
+	clock.BlockUntil(1)
+	clock.Advance(busyDelay)
+	clock.BlockUntil(1)
+	if got := dev.Colour(1, 2); got != "black" {
+		t.Errorf("unexpected colour under busy indicator: got:%s want:black", got)
+	}
+	if !dev.Written(1, 2, 2) {
+		t.Error("busy indicator not written")
+	}
+
+	close(release)
+	p.Wait()
+	if got := dev.Colour(1, 2); got != "red" {
+		t.Errorf("unexpected colour after action: got:%s want:red", got)
+	}
+	if !p.Handle(press(1, 2, 2*time.Second)) {
+		t.Error("press after completion did not start action")
+	}
+	p.Wait()
+	if got := n.Load(); got != 2 {
+		t.Errorf("unexpected number of action runs: got:%d want:2", got)
+	}
+}
+
+func TestToggle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := state.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening store: %v", err)
+	}
+	red := layout.Fill(image.Rect(0, 0, 72, 72), color.RGBA{R: 0xff, A: 0xff})
+	blue := layout.Fill(image.Rect(0, 0, 72, 72), color.RGBA{B: 0xff, A: 0xff})
+	fail := errors.New("failed")
+	var failOn atomic.Bool
+	failOn.Store(true)
+	var off atomic.Int64
+	newToggle := func() *Toggle {
+		return &Toggle{
+			Name:  "mic",
+			Store: store,
+			On: Func(func(ctx context.Context, _ Press) error {
+				if failOn.Load() {
+					return fail
+				}
+				return nil
+			}),
+			Off: Func(func(ctx context.Context, _ Press) error {
+				off.Add(1)
+				return nil
+			}),
+			OnImage:  red,
+			OffImage: blue,
+		}
+	}
+
+	d, dev := decktest.New(t)
+	p := New(d)
+	defer p.Close()
+	tog := newToggle()
+	err = p.Bind(0, 0, Binding{Action: tog})
+	if err != nil {
+		t.Fatalf("unexpected error binding toggle: %v", err)
+	}
+	if got := dev.Colour(0, 0); got != "blue" {
+		t.Errorf("unexpected colour after binding: got:%s want:blue", got)
+	}
+
+	p.Handle(press(0, 0, 0))
+	p.Wait()
+	if tog.State() {
+		t.Error("toggle switched on by failed action")
+	}
+	failOn.Store(false)
+	p.Handle(press(0, 0, time.Second))
+	p.Wait()
+	if !tog.State() {
+		t.Error("toggle not switched on")
+	}
+	if got := dev.Colour(0, 0); got != "red" {
+		t.Errorf("unexpected colour after switching on: got:%s want:red", got)
+	}
+
+	// Restart with the persisted state.
+	store, err = state.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	d, dev = decktest.New(t)
+	p = New(d)
+	defer p.Close()
+	tog = newToggle()
+	err = p.Bind(0, 0, Binding{Action: tog})
+	if err != nil {
+		t.Fatalf("unexpected error binding toggle: %v", err)
+	}
+	if got := dev.Colour(0, 0); got != "red" {
+		t.Errorf("unexpected colour after restart: got:%s want:red", got)
+	}
+	p.Handle(press(0, 0, 0))
+	p.Wait()
+	if tog.State() || off.Load() != 1 {
+		t.Errorf("toggle not switched off: on=%t off actions=%d", tog.State(), off.Load())
+	}
+	if got := dev.Colour(0, 0); got != "blue" {
+		t.Errorf("unexpected colour after switching off: got:%s want:blue", got)
+	}
+}
+
+func TestBindLayout(t *testing.T) {
+	l, err := layout.Unmarshal([]byte(`{"keys": [
+	{"row": 0, "col": 0, "color": "white", "button": {"kind": "cycle", "name": "scene", "states": [
+		{"color": "red"},
+		{"color": "green", "command": ["false"]},
+		{"color": "blue"}
+	]}},
+	{"row": 0, "col": 1, "button": {"kind": "toggle", "name": "mic", "states": [
+		{"color": "black"},
+		{"color": "yellow", "command": ["true"]}
+	]}},
+	{"row": 0, "col": 2, "color": "cyan"}
+]}`))
+	if err != nil {
+		t.Fatalf("unexpected error parsing layout: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := state.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening store: %v", err)
+	}
+	err = store.Set("scene", 2)
+	if err != nil {
+		t.Fatalf("unexpected error setting state: %v", err)
+	}
+
+	d, dev := decktest.New(t)
+	p := New(d)
+	defer p.Close()
+	var failed atomic.Int64
+	p.SetErrorHandler(func(ev ardilla.KeyEvent, err error) {
+		failed.Add(1)
+	})
+	err = BindLayout(p, l, store)
+	if err != nil {
+		t.Fatalf("unexpected error binding layout: %v", err)
+	}
+	for _, step := range []struct {
+		name        string
+		key         int
+		wantColours [2]string
+		wantFailed  int64
+	}{
+		{name: "bound", key: -1, wantColours: [2]string{"blue", "black"}},
+		{name: "cycle wraps", key: 0, wantColours: [2]string{"red", "black"}},
+		{name: "command fails", key: 0, wantColours: [2]string{"red", "black"}, wantFailed: 1},
+		{name: "toggle on", key: 1, wantColours: [2]string{"red", "yellow"}, wantFailed: 1},
+	} {
+		if step.key >= 0 {
+			p.Handle(press(0, step.key, 0))
+			p.Wait()
+		}
+		got := [2]string{dev.Colour(0, 0), dev.Colour(0, 1)}
+		if got != step.wantColours {
+			t.Errorf("unexpected colours after %s: got:%v want:%v", step.name, got, step.wantColours)
+		}
+		if n := failed.Load(); n != step.wantFailed {
+			t.Errorf("unexpected number of failures after %s: got:%d want:%d", step.name, n, step.wantFailed)
+		}
+	}
+	var scene int
+	_, err = store.Get("scene", &scene)
+	if err != nil || scene != 0 {
+		t.Errorf("unexpected persisted cycle state: got:%d err:%v want:0", scene, err)
+	}
+	var mic bool
+	_, err = store.Get("mic", &mic)
+	if err != nil || !mic {
+		t.Errorf("unexpected persisted toggle state: got:%t err:%v want:true", mic, err)
+	}
+	if p.Handle(press(0, 2, 0)) {
+		t.Error("key without button bound")
+	}
+}
+
+func TestRadio(t *testing.T) {
+	fill := func(c color.RGBA) image.Image { return layout.Fill(image.Rect(0, 0, 72, 72), c) }
+	red, blue := fill(color.RGBA{R: 0xff, A: 0xff}), fill(color.RGBA{B: 0xff, A: 0xff})
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := state.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening store: %v", err)
+	}
+	fail := errors.New("failed")
+	var selections []int
+	newRadio := func() *Radio {
+		r := &Radio{Name: "scene", Store: store, OnSelect: func(i int) { selections = append(selections, i) }}
+		for col := 0; col < 3; col++ {
+			o := RadioOption{Row: 1, Col: col, Active: red, Inactive: blue}
+			if col == 2 {
+				o.Action = Func(func(context.Context, Press) error { return fail })
+			}
+			r.Options = append(r.Options, o)
+		}
+		return r
+	}
+	colours := func(dev *decktest.Device) [3]string {
+		return [3]string{dev.Colour(1, 0), dev.Colour(1, 1), dev.Colour(1, 2)}
+	}
+
+	d, dev := decktest.New(t)
+	p := New(d)
+	defer p.Close()
+	r := newRadio()
+	err = p.BindRadio(r)
+	if err != nil {
+		t.Fatalf("unexpected error binding radio group: %v", err)
+	}
+	if got, want := colours(dev), [3]string{"blue", "blue", "blue"}; got != want {
+		t.Errorf("unexpected colours after binding: got:%v want:%v", got, want)
+	}
+	for _, step := range []struct {
+		col          int
+		wantSelected int
+		wantColours  [3]string
+	}{
+		{col: 1, wantSelected: 1, wantColours: [3]string{"blue", "red", "blue"}},
+		{col: 0, wantSelected: 0, wantColours: [3]string{"red", "blue", "blue"}},
+		{col: 2, wantSelected: 0, wantColours: [3]string{"red", "blue", "blue"}},
+	} {
+		p.Handle(press(1, step.col, 0))
+		p.Wait()
+		if got := r.Selected(); got != step.wantSelected {
+			t.Errorf("unexpected selection after pressing %d: got:%d want:%d", step.col, got, step.wantSelected)
+		}
+		if got := colours(dev); got != step.wantColours {
+			t.Errorf("unexpected colours after pressing %d: got:%v want:%v", step.col, got, step.wantColours)
+		}
+	}
+	if len(selections) != 2 || selections[0] != 1 || selections[1] != 0 {
+		t.Errorf("unexpected selections: got:%v want:[1 0]", selections)
+	}
+
+	// Restart with the persisted selection.
+	d, dev = decktest.New(t)
+	p = New(d)
+	defer p.Close()
+	r = newRadio()
+	err = p.BindRadio(r)
+	if err != nil {
+		t.Fatalf("unexpected error binding radio group: %v", err)
+	}
+	if got, want := colours(dev), [3]string{"red", "blue", "blue"}; got != want {
+		t.Errorf("unexpected colours after restart: got:%v want:%v", got, want)
+	}
+	err = r.Select(-1)
+	if err != nil {
+		t.Fatalf("unexpected error clearing selection: %v", err)
+	}
+	if got, want := colours(dev), [3]string{"blue", "blue", "blue"}; got != want {
+		t.Errorf("unexpected colours after clearing selection: got:%v want:%v", got, want)
+	}
+	err = r.Select(3)
+	if err == nil {
+		t.Error("expected error for out of range selection")
+	}
+	err = New(d).BindRadio(r)
+	if err == nil {
+		t.Error("expected error binding to a second dispatcher")
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	red := layout.Fill(image.Rect(0, 0, 72, 72), color.RGBA{R: 0xff, A: 0xff})
+	blue := layout.Fill(image.Rect(0, 0, 72, 72), color.RGBA{B: 0xff, A: 0xff})
+	var n atomic.Int64
+	c := RequireConfirm(Func(func(ctx context.Context, _ Press) error {
+		n.Add(1)
+		return nil
+	}), red, 250*time.Millisecond)
+
+	d, dev := decktest.New(t)
+	p := New(d)
+	defer p.Close()
+	err := p.Bind(0, 0, Binding{Action: c, Image: blue})
+	if err != nil {
+		t.Fatalf("unexpected error binding action: %v", err)
+	}
+
+	p.Handle(press(0, 0, 0))
+	p.Wait()
+	if !c.Pending() || n.Load() != 0 {
+		t.Errorf("unexpected state after first press: pending=%t actions=%d", c.Pending(), n.Load())
+	}
+	if got := dev.Colour(0, 0); got != "red" {
+		t.Errorf("unexpected colour while pending: got:%s want:red", got)
+	}
+	p.Handle(press(0, 0, 10*time.Millisecond))
+	p.Wait()
+	if c.Pending() || n.Load() != 1 {
+		t.Errorf("unexpected state after confirmation: pending=%t actions=%d", c.Pending(), n.Load())
+	}
+	if got := dev.Colour(0, 0); got != "blue" {
+		t.Errorf("unexpected colour after confirmation: got:%s want:blue", got)
+	}
+
+	p.Handle(press(0, 0, time.Second))
+	p.Wait()
+	if got := dev.Colour(0, 0); got != "red" {
+		t.Errorf("unexpected colour while pending: got:%s want:red", got)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for dev.Colour(0, 0) != "blue" {
+		if time.Now().After(deadline) {
+			t.Fatal("prompt not removed after timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c.Pending() || n.Load() != 1 {
+		t.Errorf("unexpected state after timeout: pending=%t actions=%d", c.Pending(), n.Load())
+	}
+}
+
+func TestErrors(t *testing.T) {
+	d, _ := decktest.New(t)
+	p := New(d)
+	defer p.Close()
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	p.SetErrorHandler(func(ev ardilla.KeyEvent, err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	})
+	want := errors.New("failed")
+	err := p.Bind(0, 0, Binding{Action: Func(func(ctx context.Context, _ Press) error {
+		return want
+	})})
+	if err != nil {
+		t.Fatalf("unexpected error binding action: %v", err)
+	}
+	p.Handle(press(0, 0, 0))
+	p.Wait()
+	if len(errs) != 1 || errs[0] != want {
+		t.Errorf("unexpected errors: got:%v want:[%v]", errs, want)
+	}
+
+	for _, test := range []struct {
+		row, col int
+		b        Binding
+		want     error
+	}{
+		{row: 3, col: 0, b: Binding{Action: Func(nil)}, want: errors.New("row out of bounds: 3")},
+		{row: 0, col: 5, b: Binding{Action: Func(nil)}, want: errors.New("column out of bounds: 5")},
+		{row: 0, col: 0, want: errors.New("missing action")},
+		{row: 0, col: 0, b: Binding{Action: Func(nil), MinInterval: -1}, want: errors.New("negative interval: -1ns")},
+	} {
+		err := p.Bind(test.row, test.col, test.b)
+		if err == nil || err.Error() != test.want.Error() {
+			t.Errorf("unexpected error for invalid binding: got:%v want:%v", err, test.want)
+		}
+	}
+
+	p.Close()
+	if p.Handle(press(0, 0, time.Hour)) {
+		t.Error("press after close started action")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell available")
+	}
+	var out bytes.Buffer
+	c := &Command{Path: sh, Args: []string{"-c", "echo $0; exit 3", "key"}, Stdout: &out}
+	err = c.Do(context.Background(), Press{KeyEvent: press(0, 0, 0)})
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("unexpected error: got:%v want exit status 3", err)
+	}
+	if got := out.String(); got != "key\n" {
+		t.Errorf("unexpected output: got:%q want:%q", got, "key\n")
+	}
+
+	t.Setenv("ARDILLA_TEST_TOKEN", "s3cret")
+	out.Reset()
+	c = &Command{
+		Path:    sh,
+		Args:    []string{"-c", "echo $TOKEN"},
+		Stdout:  &out,
+		Secrets: map[string]string{"TOKEN": "env:ARDILLA_TEST_TOKEN"},
+	}
+	err = c.Do(context.Background(), Press{KeyEvent: press(0, 0, 0)})
+	if err != nil {
+		t.Errorf("unexpected error running command with secret: %v", err)
+	}
+	if got := out.String(); got != "s3cret\n" {
+		t.Errorf("unexpected output: got:%q want:%q", got, "s3cret\n")
+	}
+	c.Secrets["TOKEN"] = "env:ARDILLA_TEST_MISSING"
+	err = c.Do(context.Background(), Press{KeyEvent: press(0, 0, 0)})
+	if !errors.Is(err, secret.ErrNotFound) {
+		t.Errorf("unexpected error for missing secret: got:%v want:%v", err, secret.ErrNotFound)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	p := Press{KeyEvent: press(1, 2, 0), Page: 3, PressDuration: 1500 * time.Millisecond}
+	for _, test := range []struct {
+		args []string
+		want []string
+		err  bool
+	}{
+		{
+			args: []string{"--key={{.Key}}", "--pos={{.Row}},{{.Col}}", "--page={{.Page}}", "--held={{.PressDuration}}", "{literal}"},
+			want: []string{"--key=7", "--pos=1,2", "--page=3", "--held=1.5s", "{literal}"},
+		},
+		{
+			args: []string{"-c", "echo {{printf \"it's $(key) %d\" .Key | shellquote}}"},
+			want: []string{"-c", `echo 'it'\''s $(key) 7'`},
+		},
+		{
+			args: []string{"{{.Key"},
+			err:  true,
+		},
+		{
+			args: []string{"{{.Missing}}"},
+			err:  true,
+		},
+	} {
+		got, err := expand(test.args, p)
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error for %q: %v", test.args, err)
+			continue
+		}
+		if !equal(got, test.want) {
+			t.Errorf("unexpected expansion of %q: got:%q want:%q", test.args, got, test.want)
+		}
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell available")
+	}
+	var out bytes.Buffer
+	c := &Command{
+		Path:   sh,
+		Args:   []string{"-c", `printf '%s\n' "$0" {{"a'b; echo injected" | shellquote}}`, "{{.Key}} $(echo injected)"},
+		Stdout: &out,
+		Expand: true,
+	}
+	err = c.Do(context.Background(), p)
+	if err != nil {
+		t.Fatalf("unexpected error running command: %v", err)
+	}
+	want := "7 $(echo injected)\na'b; echo injected\n"
+	if got := out.String(); got != want {
+		t.Errorf("unexpected output: got:%q want:%q", got, want)
+	}
+}
+
+func TestOnRelease(t *testing.T) {
+	d, _ := decktest.New(t)
+	p := New(d)
+	defer p.Close()
+	p.SetPage(2)
+
+	got := make(chan Press, 1)
+	err := p.Bind(0, 0, Binding{
+		Action: Func(func(ctx context.Context, p Press) error {
+			got <- p
+			return nil
+		}),
+		OnRelease: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error binding action: %v", err)
+	}
+	release := func(at time.Duration) ardilla.KeyEvent {
+		ev := press(0, 0, at)
+		ev.Pressed = false
+		return ev
+	}
+	if p.Handle(release(0)) {
+		t.Error("release without press started action")
+	}
+	if p.Handle(press(0, 0, time.Second)) {
+		t.Error("press started release action")
+	}
+	if !p.Handle(release(1800 * time.Millisecond)) {
+		t.Fatal("release did not start action")
+	}
+	if p.Handle(release(2 * time.Second)) {
+		t.Error("repeated release started action")
+	}
+	pr := <-got
+	if pr.PressDuration != 800*time.Millisecond || pr.Page != 2 || pr.Pressed {
+		t.Errorf("unexpected press: got:%+v", pr)
+	}
+}
+
+func TestSuppress(t *testing.T) {
+	d, _ := decktest.New(t)
+	p := New(d)
+	defer p.Close()
+
+	do := Func(func(ctx context.Context, p Press) error { return nil })
+	err := p.Bind(0, 0, Binding{Action: do})
+	if err != nil {
+		t.Fatalf("unexpected error binding action: %v", err)
+	}
+	err = p.Bind(0, 1, Binding{Action: do, OnRelease: true})
+	if err != nil {
+		t.Fatalf("unexpected error binding action: %v", err)
+	}
+	release := func(row, col int, at time.Duration) ardilla.KeyEvent {
+		ev := press(row, col, at)
+		ev.Pressed = false
+		return ev
+	}
+
+	if p.Handle(press(0, 1, 0)) {
+		t.Error("press started release action")
+	}
+	p.Suppress(true)
+	if !p.Suppressed() {
+		t.Error("dispatcher not suppressed")
+	}
+	if p.Handle(press(0, 0, time.Second)) {
+		t.Error("suppressed press started action")
+	}
+	p.Suppress(false)
+	if p.Handle(release(0, 1, 2*time.Second)) {
+		t.Error("release of key held over suppression started action")
+	}
+	if !p.Handle(press(0, 0, 3*time.Second)) {
+		t.Error("press did not start action after suppression ended")
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
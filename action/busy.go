@@ -0,0 +1,145 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package action
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"time"
+
+	"golang.org/x/image/draw"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/animation"
+)
+
+const (
+	// busyDelay is how long an exclusive action
+	// runs before the busy indicator is shown.
+	busyDelay = 250 * time.Millisecond
+
+	// busyFrame is the interval between frames
+	// of the busy indicator.
+	busyFrame = 100 * time.Millisecond
+
+	// busyDots is the number of dots in the
+	// busy indicator.
+	busyDots = 8
+)
+
+// errShown is returned by the busy indicator animation if it rendered
+// any frames.
+var errShown = errors.New("busy indicator shown")
+
+// busy starts the busy indicator on the key of ev over img after
+// busyDelay. It returns nil if the deck does not display images.
+func (p *Dispatcher) busy(ev ardilla.KeyEvent, img image.Image) (*ardilla.Animation, error) {
+	bounds, err := p.d.Bounds()
+	if err != nil {
+		return nil, nil
+	}
+	return p.d.Animate(p.ctx, ev.Row, ev.Col, func(ctx context.Context, set func(image.Image) error) error {
+		if !animation.Sleep(ctx, p.clock, busyDelay) {
+			return ctx.Err()
+		}
+		base := dimmed(bounds, img)
+		for i := 0; ; i++ {
+			err := set(spinner(base, i))
+			if err != nil {
+				return errShown
+			}
+			if !animation.Sleep(ctx, p.clock, busyFrame) {
+				return errShown
+			}
+		}
+	})
+}
+
+// restore sets img, or black if img is nil, on the key of ev after the
+// busy indicator has been shown or the state of a Stateful action has
+// been changed.
+func (p *Dispatcher) restore(ev ardilla.KeyEvent, img image.Image) error {
+	if img == nil {
+		bounds, err := p.d.Bounds()
+		if err != nil {
+			return err
+		}
+		img = dimmed(bounds, nil)
+	}
+	return p.d.SetImage(ev.Row, ev.Col, img)
+}
+
+// dimmed returns img scaled to fit bounds, preserving its aspect ratio,
+// and darkened so that the busy indicator is visible over it.
+func dimmed(bounds image.Rectangle, img image.Image) *image.RGBA {
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	if img != nil {
+		draw.BiLinear.Scale(dst, fitRect(bounds, img.Bounds()), img, img.Bounds(), draw.Over, nil)
+		draw.Draw(dst, bounds, image.NewUniform(color.NRGBA{A: 0xa0}), image.Point{}, draw.Over)
+	}
+	return dst
+}
+
+// fitRect returns the largest rectangle with the aspect ratio of src
+// centred in dst.
+func fitRect(dst, src image.Rectangle) image.Rectangle {
+	dx, dy := src.Dx(), src.Dy()
+	switch {
+	case dx == 0 || dy == 0:
+		return dst
+	case dx*dst.Dy() < dy*dst.Dx():
+		dx, dy = dx*dst.Dy()/dy, dst.Dy()
+	default:
+		dx, dy = dst.Dx(), dy*dst.Dx()/dx
+	}
+	offset := image.Point{X: (dst.Dx() - dx) / 2, Y: (dst.Dy() - dy) / 2}
+	return image.Rectangle{Max: image.Point{X: dx, Y: dy}}.Add(dst.Min.Add(offset))
+}
+
+// spinner returns frame i of the busy indicator drawn over base. The
+// indicator is a ring of dots with a bright dot travelling around it.
+func spinner(base *image.RGBA, i int) image.Image {
+	b := base.Bounds()
+	img := image.NewRGBA(b)
+	copy(img.Pix, base.Pix)
+	size := b.Dx()
+	if b.Dy() < size {
+		size = b.Dy()
+	}
+	cx := float64(b.Min.X) + float64(b.Dx())/2
+	cy := float64(b.Min.Y) + float64(b.Dy())/2
+	ring := float64(size) * 0.3
+	r := float64(size) * 0.06
+	if r < 1 {
+		r = 1
+	}
+	for j := 0; j < busyDots; j++ {
+		// Dots trailing the lead dot fade out.
+		age := (i - j) % busyDots
+		if age < 0 {
+			age += busyDots
+		}
+		level := uint8(0xff - age*0xc0/(busyDots-1))
+		theta := 2 * math.Pi * float64(j) / busyDots
+		disc(img, cx+ring*math.Sin(theta), cy-ring*math.Cos(theta), r, color.RGBA{R: level, G: level, B: level, A: 0xff})
+	}
+	return img
+}
+
+// disc draws a filled disc of radius r centred at (cx, cy) on img.
+func disc(img *image.RGBA, cx, cy, r float64, c color.RGBA) {
+	for y := int(cy - r); y <= int(cy+r); y++ {
+		for x := int(cx - r); x <= int(cx+r); x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			if dx*dx+dy*dy <= r*r {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
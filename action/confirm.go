@@ -0,0 +1,126 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package action
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"sync"
+	"time"
+
+	"github.com/kortschak/ardilla/label"
+)
+
+// DefaultConfirmTimeout is the time a Confirm waits for its second press
+// if it has no Timeout.
+const DefaultConfirmTimeout = 3 * time.Second
+
+// Confirm is a Stateful action that requires two presses to perform its
+// action, for destructive actions such as restarting a stream or shutting
+// down a server. The first press shows a prompt on the key; a second press
+// before the timeout performs the action. If the timeout expires, the key
+// returns to its normal image and the next press prompts again.
+type Confirm struct {
+	// Action is the action performed when the press
+	// is confirmed.
+	Action Action
+
+	// Prompt is shown on the key while waiting for
+	// confirmation. If Prompt is nil, "Confirm?" is
+	// shown on red.
+	Prompt image.Image
+
+	// Timeout is how long to wait for confirmation.
+	// If Timeout is zero, DefaultConfirmTimeout is
+	// used.
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	armed bool
+	timer *time.Timer
+}
+
+// RequireConfirm returns a Confirm that performs a after a confirming
+// second press within timeout, showing prompt while waiting.
+func RequireConfirm(a Action, prompt image.Image, timeout time.Duration) *Confirm {
+	return &Confirm{Action: a, Prompt: prompt, Timeout: timeout}
+}
+
+// Do prompts for confirmation, or performs the action if the press
+// confirms an earlier press.
+func (c *Confirm) Do(ctx context.Context, p Press) error {
+	c.mu.Lock()
+	if !c.armed {
+		c.armed = true
+		timeout := c.Timeout
+		if timeout <= 0 {
+			timeout = DefaultConfirmTimeout
+		}
+		var t *time.Timer
+		t = time.AfterFunc(timeout, func() {
+			c.mu.Lock()
+			expired := c.armed && c.timer == t
+			if expired {
+				c.armed = false
+				c.timer = nil
+			}
+			c.mu.Unlock()
+			if expired {
+				p.Redraw()
+			}
+		})
+		c.timer = t
+		c.mu.Unlock()
+		return nil
+	}
+	c.armed = false
+	c.timer.Stop()
+	c.timer = nil
+	c.mu.Unlock()
+	return c.Action.Do(ctx, p)
+}
+
+// Pending returns whether the action is waiting for confirmation.
+func (c *Confirm) Pending() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.armed
+}
+
+// Image returns the prompt while waiting for confirmation. Otherwise it
+// returns the image of the action if it is Stateful, or nil so that the
+// binding's image is shown.
+func (c *Confirm) Image() image.Image {
+	if c.Pending() {
+		if c.Prompt != nil {
+			return c.Prompt
+		}
+		return defaultPrompt()
+	}
+	if s, ok := c.Action.(Stateful); ok {
+		return s.Image()
+	}
+	return nil
+}
+
+var (
+	promptOnce sync.Once
+	prompt     image.Image
+)
+
+// defaultPrompt returns the prompt shown when a Confirm has no Prompt. It
+// is scaled to the key size when it is rendered.
+func defaultPrompt() image.Image {
+	promptOnce.Do(func() {
+		img, err := label.Render(image.Rect(0, 0, 96, 96), "Confirm?", 22, color.White, color.RGBA{R: 0xc0, A: 0xff})
+		if err != nil {
+			// The embedded font is known to parse.
+			panic(err)
+		}
+		prompt = img
+	})
+	return prompt
+}
@@ -0,0 +1,118 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/kortschak/ardilla/state"
+)
+
+// Cycle is a Stateful action that moves through a sequence of states, one
+// state each time it is performed, returning to the first state after the
+// last. If Store is not nil, the cycle's state is persisted under Name as
+// for Toggle. A state that cannot be read from the store, or that is out
+// of range, is treated as the first state.
+type Cycle struct {
+	// Name is the cycle's key in Store.
+	Name string
+
+	// Store holds the cycle's state.
+	Store *state.Store
+
+	// States are the cycle's states. The first state
+	// is the initial state.
+	States []CycleState
+
+	// run serialises performing the action.
+	run sync.Mutex
+
+	mu     sync.Mutex
+	loaded bool
+	state  int
+}
+
+// CycleState is a state of a Cycle.
+type CycleState struct {
+	// Action is performed when the cycle enters
+	// the state. If the action fails, the cycle's
+	// state is not changed. Action may be nil.
+	Action Action
+
+	// Image is shown on the key in the state.
+	Image image.Image
+}
+
+// Do moves the cycle to its next state, performing the new state's action.
+func (c *Cycle) Do(ctx context.Context, p Press) error {
+	if len(c.States) == 0 {
+		return errors.New("cycle has no states")
+	}
+	c.run.Lock()
+	defer c.run.Unlock()
+	next := (c.State() + 1) % len(c.States)
+	if a := c.States[next].Action; a != nil {
+		err := a.Do(ctx, p)
+		if err != nil {
+			return err
+		}
+	}
+	return c.Set(next)
+}
+
+// State returns the index of the cycle's current state.
+func (c *Cycle) State() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	return c.state
+}
+
+// Set sets the cycle's state without performing its action, persisting the
+// state if the cycle has a Store. As for Toggle, the image is not updated
+// until the Dispatcher next shows it.
+func (c *Cycle) Set(i int) error {
+	if i < 0 || len(c.States) <= i {
+		return fmt.Errorf("state out of range: %d", i)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loaded = true
+	c.state = i
+	if c.Store == nil {
+		return nil
+	}
+	return c.Store.Set(c.Name, i)
+}
+
+// Image returns the image for the cycle's state.
+func (c *Cycle) Image() image.Image {
+	i := c.State()
+	if i < len(c.States) {
+		return c.States[i].Image
+	}
+	return nil
+}
+
+// load reads the cycle's state from its store on first use. The caller
+// must hold c.mu.
+func (c *Cycle) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	if c.Store == nil {
+		return
+	}
+	var i int
+	_, err := c.Store.Get(c.Name, &i)
+	if err == nil && 0 <= i && i < len(c.States) {
+		c.state = i
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package action
+
+import (
+	"fmt"
+
+	"github.com/kortschak/ardilla/layout"
+	"github.com/kortschak/ardilla/state"
+)
+
+// BindLayout binds the buttons described by the keys of l to the
+// dispatcher's deck, persisting their states in store if it is not nil.
+// Toggle buttons are bound as a Toggle and cycle buttons as a Cycle, each
+// state's command being run as a Command when the button enters the
+// state. Keys of l that are not buttons are not bound.
+func BindLayout(p *Dispatcher, l *layout.Layout, store *state.Store) error {
+	bounds, err := p.d.Bounds()
+	if err != nil {
+		return err
+	}
+	for _, k := range l.Keys {
+		if k.Button == nil {
+			continue
+		}
+		imgs, err := l.ButtonImages(k, bounds)
+		if err != nil {
+			return err
+		}
+		actions := make([]Action, len(k.Button.States))
+		for i, s := range k.Button.States {
+			if len(s.Command) != 0 {
+				actions[i] = &Command{Path: s.Command[0], Args: s.Command[1:]}
+			}
+		}
+		var a Action
+		switch k.Button.Kind {
+		case layout.Toggle:
+			a = &Toggle{
+				Name:     k.Button.Name,
+				Store:    store,
+				Off:      actions[0],
+				On:       actions[1],
+				OffImage: imgs[0],
+				OnImage:  imgs[1],
+			}
+		case layout.Cycle:
+			states := make([]CycleState, len(imgs))
+			for i := range states {
+				states[i] = CycleState{Action: actions[i], Image: imgs[i]}
+			}
+			a = &Cycle{Name: k.Button.Name, Store: store, States: states}
+		default:
+			return fmt.Errorf("key %d,%d: invalid button kind: %q", k.Row, k.Col, k.Button.Kind)
+		}
+		err = p.Bind(k.Row, k.Col, Binding{Action: a, Exclusive: true})
+		if err != nil {
+			return fmt.Errorf("key %d,%d: %w", k.Row, k.Col, err)
+		}
+	}
+	return nil
+}
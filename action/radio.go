@@ -0,0 +1,183 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/kortschak/ardilla/state"
+)
+
+// Radio is a group of keys of which at most one is selected, for example
+// to pick a scene or a profile. Pressing an option's key performs the
+// option's action and, if it succeeds, selects the option, showing its
+// active image and the inactive images of the other options. If Store is
+// not nil, the selection is persisted under Name as for Toggle.
+type Radio struct {
+	// Name is the group's key in Store.
+	Name string
+
+	// Store holds the group's selection.
+	Store *state.Store
+
+	// Options are the keys of the group.
+	Options []RadioOption
+
+	// OnSelect is called with the index of the
+	// option each time an option is selected by
+	// a press or by Select. It may be nil.
+	OnSelect func(i int)
+
+	// p is the dispatcher the group is bound to.
+	p *Dispatcher
+
+	// run serialises selection.
+	run sync.Mutex
+
+	mu       sync.Mutex
+	loaded   bool
+	selected int
+}
+
+// RadioOption is an option of a Radio group.
+type RadioOption struct {
+	// Row and Col are the option's key.
+	Row, Col int
+
+	// Action is performed when the option's key is
+	// pressed. Action may be nil.
+	Action Action
+
+	// Active and Inactive are shown on the key when
+	// the option is and is not selected.
+	Active, Inactive image.Image
+}
+
+// BindRadio binds the options of r to their keys. The group may only be
+// bound to one Dispatcher.
+func (p *Dispatcher) BindRadio(r *Radio) error {
+	if len(r.Options) == 0 {
+		return errors.New("radio group has no options")
+	}
+	if r.p != nil && r.p != p {
+		return errors.New("radio group bound to another dispatcher")
+	}
+	seen := make(map[[2]int]bool)
+	for _, o := range r.Options {
+		k := [2]int{o.Row, o.Col}
+		if seen[k] {
+			return fmt.Errorf("duplicate radio option key: %d,%d", o.Row, o.Col)
+		}
+		seen[k] = true
+	}
+	r.p = p
+	for i, o := range r.Options {
+		err := p.Bind(o.Row, o.Col, Binding{Action: &radioOption{r: r, i: i}, Exclusive: true})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Selected returns the index of the selected option, or -1 if no option
+// is selected.
+func (r *Radio) Selected() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.load()
+	return r.selected
+}
+
+// Select selects the option with index i without performing its action,
+// persisting the selection if the group has a Store, and shows the
+// selection if the group is bound. If i is -1, no option is selected.
+func (r *Radio) Select(i int) error {
+	r.run.Lock()
+	defer r.run.Unlock()
+	return r.set(i)
+}
+
+// set selects option i and redraws the group. The caller must hold r.run.
+func (r *Radio) set(i int) error {
+	if i < -1 || len(r.Options) <= i {
+		return fmt.Errorf("option out of range: %d", i)
+	}
+	r.mu.Lock()
+	r.load()
+	prev := r.selected
+	r.selected = i
+	r.mu.Unlock()
+
+	var err error
+	if r.Store != nil {
+		err = r.Store.Set(r.Name, i)
+	}
+	if i >= 0 && r.OnSelect != nil {
+		r.OnSelect(i)
+	}
+	if r.p != nil {
+		for _, j := range []int{prev, i} {
+			if j < 0 {
+				continue
+			}
+			o := r.Options[j]
+			rerr := r.p.Redraw(o.Row, o.Col)
+			if err == nil {
+				err = rerr
+			}
+		}
+	}
+	return err
+}
+
+// load reads the group's selection from its store on first use. The
+// caller must hold r.mu.
+func (r *Radio) load() {
+	if r.loaded {
+		return
+	}
+	r.loaded = true
+	r.selected = -1
+	if r.Store == nil {
+		return
+	}
+	var i int
+	ok, err := r.Store.Get(r.Name, &i)
+	if ok && err == nil && -1 <= i && i < len(r.Options) {
+		r.selected = i
+	}
+}
+
+// radioOption is the Stateful action bound to an option's key.
+type radioOption struct {
+	r *Radio
+	i int
+}
+
+// Do performs the option's action and selects it.
+func (o *radioOption) Do(ctx context.Context, p Press) error {
+	o.r.run.Lock()
+	defer o.r.run.Unlock()
+	if a := o.r.Options[o.i].Action; a != nil {
+		err := a.Do(ctx, p)
+		if err != nil {
+			return err
+		}
+	}
+	return o.r.set(o.i)
+}
+
+// Image returns the option's active or inactive image.
+func (o *radioOption) Image() image.Image {
+	if o.r.Selected() == o.i {
+		return o.r.Options[o.i].Active
+	}
+	return o.r.Options[o.i].Inactive
+}
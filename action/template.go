@@ -0,0 +1,46 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package action
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// funcs are the functions available to Command argument templates.
+var funcs = template.FuncMap{
+	"shellquote": shellQuote,
+}
+
+// expand returns args with each argument executed as a template with p.
+func expand(args []string, p Press) ([]string, error) {
+	expanded := make([]string, len(args))
+	var buf strings.Builder
+	for i, arg := range args {
+		if !strings.Contains(arg, "{{") {
+			expanded[i] = arg
+			continue
+		}
+		tmpl, err := template.New("arg").Funcs(funcs).Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		buf.Reset()
+		err = tmpl.Execute(&buf, p)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		expanded[i] = buf.String()
+	}
+	return expanded, nil
+}
+
+// shellQuote returns the text of v quoted for use as a single word in a
+// POSIX shell command line.
+func shellQuote(v interface{}) string {
+	s := fmt.Sprint(v)
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,109 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package action
+
+import (
+	"context"
+	"image"
+	"sync"
+
+	"github.com/kortschak/ardilla/state"
+)
+
+// Toggle is a Stateful action that switches between off and on each time
+// it is performed. If Store is not nil, the toggle's state is persisted
+// under Name so that it is restored, and its image shown, when the
+// program is restarted. A state that cannot be read from the store is
+// treated as off.
+type Toggle struct {
+	// Name is the toggle's key in Store.
+	Name string
+
+	// Store holds the toggle's state.
+	Store *state.Store
+
+	// On and Off are performed when the toggle is
+	// switched on and off. If the action fails, the
+	// toggle's state is not changed. Either may be
+	// nil.
+	On, Off Action
+
+	// OnImage and OffImage are shown on the key
+	// when the toggle is on and off.
+	OnImage, OffImage image.Image
+
+	// run serialises performing the action.
+	run sync.Mutex
+
+	mu     sync.Mutex
+	loaded bool
+	on     bool
+}
+
+// Do switches the toggle to its other state, performing the On or Off
+// action.
+func (t *Toggle) Do(ctx context.Context, p Press) error {
+	t.run.Lock()
+	defer t.run.Unlock()
+	on := !t.State()
+	a := t.Off
+	if on {
+		a = t.On
+	}
+	if a != nil {
+		err := a.Do(ctx, p)
+		if err != nil {
+			return err
+		}
+	}
+	return t.Set(on)
+}
+
+// State returns whether the toggle is on.
+func (t *Toggle) State() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.load()
+	return t.on
+}
+
+// Set sets the toggle's state without performing its actions, persisting
+// the state if the toggle has a Store. The toggle's image is not updated
+// until the Dispatcher next shows it.
+func (t *Toggle) Set(on bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.loaded = true
+	t.on = on
+	if t.Store == nil {
+		return nil
+	}
+	return t.Store.Set(t.Name, on)
+}
+
+// Image returns the image for the toggle's state.
+func (t *Toggle) Image() image.Image {
+	if t.State() {
+		return t.OnImage
+	}
+	return t.OffImage
+}
+
+// load reads the toggle's state from its store on first use. The caller
+// must hold t.mu.
+func (t *Toggle) load() {
+	if t.loaded {
+		return
+	}
+	t.loaded = true
+	if t.Store == nil {
+		return
+	}
+	var on bool
+	_, err := t.Store.Get(t.Name, &on)
+	if err == nil {
+		t.on = on
+	}
+}
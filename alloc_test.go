@@ -0,0 +1,170 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// steadyStateTests are the operations that must not allocate once the
+// Deck has been used. Each returns a function performing the operation
+// n times.
+var steadyStateTests = []struct {
+	name string
+	op   func(tb testing.TB, d *Deck) func(n int)
+}{
+	{
+		name: "SetImage_raw",
+		op: func(tb testing.TB, d *Deck) func(n int) {
+			var raw [2]*RawImage
+			for i, c := range []color.Color{color.White, color.Black} {
+				var err error
+				raw[i], err = d.RawImage(uniformRGBA(image.Rect(0, 0, 72, 72), c))
+				if err != nil {
+					tb.Fatalf("unexpected error for RawImage: %v", err)
+				}
+			}
+			return func(n int) {
+				for i := 0; i < n; i++ {
+					// Alternate images so that every
+					// call writes to the device.
+					err := d.SetImage(0, 0, raw[i%2])
+					if err != nil {
+						tb.Fatalf("unexpected error for SetImage: %v", err)
+					}
+				}
+			}
+		},
+	},
+	{
+		name: "AppendKeyStates",
+		op: func(tb testing.TB, d *Deck) func(n int) {
+			states := make([]bool, 0, d.Len())
+			return func(n int) {
+				for i := 0; i < n; i++ {
+					var err error
+					states, err = d.AppendKeyStates(states[:0])
+					if err != nil {
+						tb.Fatalf("unexpected error for AppendKeyStates: %v", err)
+					}
+				}
+			}
+		},
+	},
+	{
+		name: "events",
+		op: func(tb testing.TB, d *Deck) func(n int) {
+			return func(n int) {
+				if n == 0 {
+					return
+				}
+				var events int
+				d.keyEvents(context.Background(), func(ev KeyEvent) bool {
+					if ev.Err != nil {
+						tb.Fatalf("unexpected error in event stream: %v", ev.Err)
+					}
+					events++
+					return events < n
+				})
+			}
+		},
+	},
+}
+
+func TestSteadyStateAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are not reliable with the race detector")
+	}
+	for _, test := range steadyStateTests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := newTestDeck(StreamDeckMK2)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			d.dev = newNullDev(d)
+			op := test.op(t, d)
+
+			// Measure the difference between a short and a
+			// long run so that set-up costs, such as starting
+			// the event reader, are not counted.
+			const short, long = 10, 10010
+			op(short)
+			base := mallocs(func() { op(short) })
+			got := mallocs(func() { op(long) })
+			if perOp := float64(got-base) / (long - short); perOp >= 0.01 {
+				t.Errorf("unexpected allocations in steady state: %.3f per operation", perOp)
+			}
+		})
+	}
+}
+
+func BenchmarkSteadyState(b *testing.B) {
+	for _, test := range steadyStateTests {
+		b.Run(test.name, func(b *testing.B) {
+			d, err := newTestDeck(StreamDeckMK2)
+			if err != nil {
+				b.Fatalf("unexpected error: %v", err)
+			}
+			d.dev = newNullDev(d)
+			op := test.op(b, d)
+			op(1)
+			b.ReportAllocs()
+			b.ResetTimer()
+			op(b.N)
+		})
+	}
+}
+
+// mallocs returns the number of heap allocations made while running fn.
+func mallocs(fn func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+	return after.Mallocs - before.Mallocs
+}
+
+// nullDev is a HIDDevice that does not allocate. Writes are discarded and
+// reads return key state reports that alternately press and release the
+// first key. Reads may be made concurrently by event readers that have not
+// yet terminated.
+type nullDev struct {
+	mu      sync.Mutex
+	report  []byte
+	offset  int
+	pressed bool
+}
+
+func newNullDev(d *Deck) *nullDev {
+	return &nullDev{
+		report: make([]byte, d.desc.keyStatesOffset+d.Len()),
+		offset: d.desc.keyStatesOffset,
+	}
+}
+
+func (d *nullDev) Read(b []byte) (int, error) {
+	// Yield as a blocking device read would so
+	// that event readers do not starve consumers.
+	runtime.Gosched()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pressed = !d.pressed
+	d.report[d.offset] = 0
+	if d.pressed {
+		d.report[d.offset] = 1
+	}
+	return copy(b, d.report), nil
+}
+
+func (d *nullDev) Write(b []byte) (int, error)             { return len(b), nil }
+func (d *nullDev) GetFeatureReport(b []byte) (int, error)  { return len(b), nil }
+func (d *nullDev) SendFeatureReport(b []byte) (int, error) { return len(b), nil }
+func (d *nullDev) Close() error                            { return nil }
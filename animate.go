@@ -0,0 +1,154 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+// Animation is a handle to an animation running on a key.
+type Animation struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Stop stops the animation and waits for it to return. Stop must not be
+// called from within the animation function.
+func (a *Animation) Stop() {
+	a.cancel()
+	<-a.done
+}
+
+// Done returns a channel that is closed when the animation has returned.
+func (a *Animation) Done() <-chan struct{} {
+	return a.done
+}
+
+// Wait waits for the animation to return and returns its error. An
+// animation that was stopped returns context.Canceled.
+func (a *Animation) Wait() error {
+	<-a.done
+	return a.err
+}
+
+// Animate runs fn in a new goroutine to animate the key at row and col.
+// Frames are rendered on the key by calling set, which returns a non-nil
+// error once the animation has been stopped. If throttling is enabled
+// with SetThrottle, set waits until the frame is within the Deck's frame
+// budget. Any animation already running on the key is stopped before fn
+// is started, and the animation is stopped when an image is set on the key
+// with SetImage, when a new animation is started on the key, when ctx is
+// cancelled or when the Deck is closed.
+//
+// Stopping an animation waits for fn to return, so fn must not call
+// methods that stop its own animation. Calling SetImage, SetImageRegion,
+// SetLayer, ClearLayers or Animate for the animated key, or Close, Hold
+// or Release, from within fn will deadlock. Frames must be rendered with
+// set.
+func (d *Deck) Animate(ctx context.Context, row, col int, fn func(ctx context.Context, set func(image.Image) error) error) (*Animation, error) {
+	if _, err := d.Bounds(); err != nil {
+		return nil, err
+	}
+	key, err := d.keyIndex(row, col)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	a := &Animation{cancel: cancel, done: make(chan struct{})}
+	d.animMu.Lock()
+	for {
+		prev, ok := d.animations[key]
+		if !ok {
+			break
+		}
+		d.animMu.Unlock()
+		prev.Stop()
+		d.animMu.Lock()
+	}
+	if d.animations == nil {
+		d.animations = make(map[int]*Animation)
+	}
+	d.animations[key] = a
+	d.animMu.Unlock()
+
+	go func() {
+		defer func() {
+			cancel()
+			d.animMu.Lock()
+			if d.animations[key] == a {
+				delete(d.animations, key)
+			}
+			d.animMu.Unlock()
+			close(a.done)
+		}()
+		var last time.Time
+		a.err = fn(ctx, func(img image.Image) error {
+			var ok bool
+			last, ok = d.throttleFrame(ctx, last)
+			if !ok {
+				return ctx.Err()
+			}
+			return d.setImage(key, img)
+		})
+		if a.err == nil {
+			// Animation functions such as GIF.Play may
+			// return nil when their context is cancelled.
+			a.err = ctx.Err()
+		}
+	}()
+	return a, nil
+}
+
+// AnimateGIF plays g on the key at row and col. The animation is stopped
+// under the same conditions as for Animate. Frames that are identical to
+// the previous frame are not rendered or sent to the device.
+func (d *Deck) AnimateGIF(ctx context.Context, row, col int, g *animation.GIF) (*Animation, error) {
+	return d.Animate(ctx, row, col, func(ctx context.Context, set func(image.Image) error) error {
+		dst := image.NewRGBA(g.Bounds())
+		var last []byte
+		return g.Play(ctx, dst, func(img image.Image) error {
+			if img == image.Image(dst) {
+				if last != nil && bytes.Equal(dst.Pix, last) {
+					return nil
+				}
+				last = append(last[:0], dst.Pix...)
+			} else {
+				last = nil
+			}
+			return set(img)
+		})
+	})
+}
+
+// stopAnimation stops any animation running on the key with the given
+// key number.
+func (d *Deck) stopAnimation(key int) {
+	d.animMu.Lock()
+	a, ok := d.animations[key]
+	d.animMu.Unlock()
+	if ok {
+		a.Stop()
+	}
+}
+
+// stopAnimations stops all running animations.
+func (d *Deck) stopAnimations() {
+	d.animMu.Lock()
+	running := make([]*Animation, 0, len(d.animations))
+	for _, a := range d.animations {
+		running = append(running, a)
+	}
+	d.animMu.Unlock()
+	for _, a := range running {
+		a.Stop()
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+// blockingAnimation returns an animation function that renders a single
+// frame, signals on started and then waits until it is stopped.
+func blockingAnimation(started chan<- struct{}) func(context.Context, func(image.Image) error) error {
+	return func(ctx context.Context, set func(image.Image) error) error {
+		err := set(image.NewRGBA(image.Rect(0, 0, 10, 10)))
+		close(started)
+		if err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return set(image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	}
+}
+
+func TestDeckAnimate(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.setDev(&virtDev{Writer: io.Discard, Closer: io.NopCloser(nil)})
+	ctx := context.Background()
+
+	t.Run("set_image", func(t *testing.T) {
+		started := make(chan struct{})
+		a, err := d.Animate(ctx, 1, 2, blockingAnimation(started))
+		if err != nil {
+			t.Fatalf("unexpected error for Animate: %v", err)
+		}
+		<-started
+		img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+		err = d.SetImage(1, 2, img)
+		if err != nil {
+			t.Fatalf("unexpected error for SetImage: %v", err)
+		}
+		select {
+		case <-a.Done():
+		default:
+			t.Error("animation not stopped by SetImage")
+		}
+		if err := a.Wait(); err != context.Canceled {
+			t.Errorf("unexpected error for stopped animation: got:%v want:%v", err, context.Canceled)
+		}
+		if got := d.images[d.Key(1, 2)]; got != image.Image(img) {
+			t.Error("key image overwritten by stopped animation")
+		}
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		started := make(chan struct{})
+		first, err := d.Animate(ctx, 0, 0, blockingAnimation(started))
+		if err != nil {
+			t.Fatalf("unexpected error for Animate: %v", err)
+		}
+		<-started
+		other := make(chan struct{})
+		unrelated, err := d.Animate(ctx, 0, 1, blockingAnimation(other))
+		if err != nil {
+			t.Fatalf("unexpected error for Animate: %v", err)
+		}
+		<-other
+		started = make(chan struct{})
+		second, err := d.Animate(ctx, 0, 0, blockingAnimation(started))
+		if err != nil {
+			t.Fatalf("unexpected error for Animate: %v", err)
+		}
+		<-started
+		if err := first.Wait(); err != context.Canceled {
+			t.Errorf("unexpected error for replaced animation: got:%v want:%v", err, context.Canceled)
+		}
+		select {
+		case <-second.Done():
+			t.Error("new animation unexpectedly stopped")
+		case <-unrelated.Done():
+			t.Error("animation on other key unexpectedly stopped")
+		default:
+		}
+		second.Stop()
+		if err := second.Wait(); err != context.Canceled {
+			t.Errorf("unexpected error for stopped animation: got:%v want:%v", err, context.Canceled)
+		}
+		select {
+		case <-unrelated.Done():
+			t.Error("animation on other key unexpectedly stopped")
+		default:
+		}
+		unrelated.Stop()
+		if len(d.animations) != 0 {
+			t.Errorf("unexpected number of registered animations: got:%d want:0", len(d.animations))
+		}
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+		a, err := d.Animate(ctx, 2, 4, func(ctx context.Context, set func(image.Image) error) error {
+			return set(img)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error for Animate: %v", err)
+		}
+		if err := a.Wait(); err != nil {
+			t.Errorf("unexpected error for completed animation: %v", err)
+		}
+		if got := d.images[d.Key(2, 4)]; got != image.Image(img) {
+			t.Error("unexpected key image after animation")
+		}
+		a.Stop()
+	})
+
+	t.Run("bounds", func(t *testing.T) {
+		_, err := d.Animate(ctx, 3, 0, blockingAnimation(make(chan struct{})))
+		if want := errors.New("row out of bounds: 3"); !sameError(err, want) {
+			t.Errorf("unexpected error for out of bounds Animate: got:%v want:%v", err, want)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		started := make(chan struct{})
+		a, err := d.Animate(ctx, 1, 1, blockingAnimation(started))
+		if err != nil {
+			t.Fatalf("unexpected error for Animate: %v", err)
+		}
+		<-started
+		d.Close()
+		select {
+		case <-a.Done():
+		default:
+			t.Error("animation not stopped by Close")
+		}
+	})
+}
+
+func TestDeckAnimateGIFUnchangedFrames(t *testing.T) {
+	pal := color.Palette{color.Black, color.White}
+	frame := func(c uint8) *image.Paletted {
+		img := image.NewPaletted(image.Rect(0, 0, 72, 72), pal)
+		for i := range img.Pix {
+			img.Pix[i] = c
+		}
+		return img
+	}
+	g, err := animation.NewGIF(&gif.GIF{
+		Image:     []*image.Paletted{frame(1), frame(1), frame(0), frame(0), frame(1)},
+		Delay:     make([]int, 5),
+		LoopCount: -1,
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	writes := func(dev *virtDev) int {
+		var n int
+		for _, a := range dev.actions {
+			if strings.HasPrefix(a, "Write(") {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Find the cost of writing each distinct frame once.
+	ref, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	refDev := &virtDev{Writer: io.Discard}
+	ref.setDev(refDev)
+	for _, img := range []image.Image{frame(1), frame(0), frame(1)} {
+		err = ref.SetImage(0, 0, img)
+		if err != nil {
+			t.Fatalf("unexpected error for SetImage: %v", err)
+		}
+	}
+
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dev := &virtDev{Writer: io.Discard}
+	d.setDev(dev)
+	a, err := d.AnimateGIF(context.Background(), 0, 0, g)
+	if err != nil {
+		t.Fatalf("unexpected error for AnimateGIF: %v", err)
+	}
+	err = a.Wait()
+	if err != nil {
+		t.Fatalf("unexpected error for animation: %v", err)
+	}
+	if got, want := writes(dev), writes(refDev); got != want {
+		t.Errorf("unexpected number of writes for animation: got:%d want:%d", got, want)
+	}
+}
+
+func TestDeckAnimateGIFStop(t *testing.T) {
+	pal := color.Palette{color.Black, color.White}
+	frame := func(c uint8) *image.Paletted {
+		img := image.NewPaletted(image.Rect(0, 0, 72, 72), pal)
+		for i := range img.Pix {
+			img.Pix[i] = c
+		}
+		return img
+	}
+	g, err := animation.NewGIF(&gif.GIF{
+		Image: []*image.Paletted{frame(0), frame(1)},
+		Delay: []int{100, 100},
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock := animation.NewManualClock(time.Time{})
+	g.Clock = clock
+
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.setDev(&virtDev{Writer: io.Discard})
+	a, err := d.AnimateGIF(context.Background(), 0, 0, g)
+	if err != nil {
+		t.Fatalf("unexpected error for AnimateGIF: %v", err)
+	}
+	// Wait for the animation to sleep on its first frame.
+	clock.BlockUntil(1)
+	a.Stop()
+	if err := a.Wait(); err != context.Canceled {
+		t.Errorf("unexpected error for stopped GIF animation: got:%v want:%v", err, context.Canceled)
+	}
+}
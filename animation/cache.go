@@ -0,0 +1,153 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package animation
+
+import (
+	"container/list"
+	"image"
+	"sync"
+)
+
+// CacheStats holds statistics for a rendered frame cache.
+type CacheStats struct {
+	// Entries is the number of cached frames and
+	// Bytes is their estimated total size.
+	Entries int
+	Bytes   int64
+
+	// Budget is the maximum size of the cache in
+	// bytes, or zero if the cache is not bounded.
+	Budget int64
+
+	// Hits and Misses are the number of cache
+	// lookups that did and did not find a frame,
+	// and Evictions is the number of frames that
+	// have been evicted to remain within budget.
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// cache is a rendered frame cache with least recently used eviction
+// when a memory budget is set.
+type cache struct {
+	mu    sync.Mutex
+	cache map[*image.Paletted]*list.Element
+	lru   list.List // lru holds *entry with the most recently used at the front.
+	miss  func(image.Image) (image.Image, error)
+
+	budget int64
+	stat   CacheStats
+}
+
+// entry is a cached frame.
+type entry struct {
+	key  *image.Paletted
+	img  image.Image
+	size int64
+}
+
+func newCache(miss func(image.Image) (image.Image, error)) *cache {
+	return &cache{
+		cache: make(map[*image.Paletted]*list.Element),
+		miss:  miss,
+	}
+}
+
+// get returns the cached image for the provided key image.
+func (c *cache) get(key *image.Paletted) (image.Image, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cache[key]
+	if !ok {
+		c.stat.Misses++
+		return nil, false
+	}
+	c.stat.Hits++
+	c.lru.MoveToFront(e)
+	return e.Value.(*entry).img, true
+}
+
+// put calculates and returns a cache image for the provided
+// image and caches the result for key.
+func (c *cache) put(key *image.Paletted, img image.Image) (image.Image, error) {
+	if c == nil {
+		return img, nil
+	}
+	r, err := c.miss(img)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.cache[key]; ok {
+		c.remove(e)
+	}
+	size := sizeOf(r)
+	c.cache[key] = c.lru.PushFront(&entry{key: key, img: r, size: size})
+	c.stat.Bytes += size
+	c.evict()
+	return r, nil
+}
+
+// setBudget sets the cache budget, evicting entries if needed.
+func (c *cache) setBudget(bytes int64) {
+	if c == nil {
+		return
+	}
+	if bytes < 0 {
+		bytes = 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = bytes
+	c.evict()
+}
+
+// stats returns the current cache statistics.
+func (c *cache) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stat
+	s.Entries = len(c.cache)
+	s.Budget = c.budget
+	return s
+}
+
+// evict removes least recently used entries until the cache is within
+// budget. It must be called with c.mu held.
+func (c *cache) evict() {
+	if c.budget <= 0 {
+		return
+	}
+	for c.stat.Bytes > c.budget && c.lru.Len() != 0 {
+		c.remove(c.lru.Back())
+		c.stat.Evictions++
+	}
+}
+
+// remove removes e from the cache. It must be called with c.mu held.
+func (c *cache) remove(e *list.Element) {
+	ent := c.lru.Remove(e).(*entry)
+	delete(c.cache, ent.key)
+	c.stat.Bytes -= ent.size
+}
+
+// sizeOf returns the estimated memory used by img. Images with a Len
+// method, such as *ardilla.RawImage, report the length of their data,
+// otherwise four bytes per pixel are assumed.
+func sizeOf(img image.Image) int64 {
+	if l, ok := img.(interface{ Len() int }); ok {
+		return int64(l.Len())
+	}
+	b := img.Bounds()
+	return 4 * int64(b.Dx()) * int64(b.Dy())
+}
@@ -0,0 +1,73 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package animation
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewManualClock(start)
+	t1 := c.NewTimer(time.Second)
+	t2 := c.NewTimer(2 * time.Second)
+	t3 := c.NewTimer(3 * time.Second)
+
+	c.Advance(time.Second)
+	select {
+	case got := <-t1.C():
+		if want := start.Add(time.Second); !got.Equal(want) {
+			t.Errorf("unexpected timer time: got:%v want:%v", got, want)
+		}
+	default:
+		t.Error("expected timer to fire")
+	}
+	select {
+	case <-t2.C():
+		t.Error("unexpected timer firing")
+	default:
+	}
+	if !t3.Stop() {
+		t.Error("expected pending timer to stop")
+	}
+	if t1.Stop() {
+		t.Error("unexpected stop of fired timer")
+	}
+	c.Advance(5 * time.Second)
+	select {
+	case <-t2.C():
+	default:
+		t.Error("expected timer to fire")
+	}
+	select {
+	case <-t3.C():
+		t.Error("unexpected firing of stopped timer")
+	default:
+	}
+	if got, want := c.Now(), start.Add(6*time.Second); !got.Equal(want) {
+		t.Errorf("unexpected time: got:%v want:%v", got, want)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	c := NewManualClock(time.Time{})
+	done := make(chan bool)
+	go func() { done <- Sleep(context.Background(), c, time.Second) }()
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	if !<-done {
+		t.Error("unexpected cancelled sleep")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- Sleep(ctx, c, time.Second) }()
+	c.BlockUntil(1)
+	cancel()
+	if <-done {
+		t.Error("expected cancelled sleep")
+	}
+}
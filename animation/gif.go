@@ -0,0 +1,180 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package animation
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// GIF is an animated GIF.
+type GIF struct {
+	*gif.GIF
+
+	// Clock is the clock used to time frames.
+	// If Clock is nil, SystemClock is used.
+	Clock Clock
+
+	cache *cache
+}
+
+// DecodeGIF returns a *GIF or an *image.Paletted decoded from the provided
+// io.Reader. If the GIF data encodes a single frame, the image returned is
+// an *image.Paletted, otherwise a *GIF is returned. When the result is a
+// *GIF, GIF delay, disposal and global background index values are checked
+// for validity. If miss is not nil, it is used to fill a cache of rendered
+// frames, for example with pre-computed device images.
+func DecodeGIF(r io.Reader, miss func(image.Image) (image.Image, error)) (image.Image, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) == 1 {
+		return g.Image[0], nil
+	}
+	return NewGIF(g, miss)
+}
+
+// NewGIF returns a GIF for g after checking GIF delay, disposal and global
+// background index values for validity. If miss is not nil, it is used to
+// fill a cache of rendered frames.
+func NewGIF(g *gif.GIF, miss func(image.Image) (image.Image, error)) (*GIF, error) {
+	if len(g.Image) == 0 {
+		return nil, fmt.Errorf("no images")
+	}
+	if len(g.Image) != len(g.Delay) && g.Delay != nil {
+		return nil, fmt.Errorf("mismatched image count and delay count: %d != %d", len(g.Image), len(g.Delay))
+	}
+	if len(g.Image) != len(g.Disposal) && g.Disposal != nil {
+		return nil, fmt.Errorf("mismatched image count and disposal count: %d != %d", len(g.Image), len(g.Disposal))
+	}
+	pal, ok := g.Config.ColorModel.(color.Palette)
+	if idx := int(g.BackgroundIndex); ok && idx >= len(pal) {
+		return nil, fmt.Errorf("global background colour index not in palette: %d", idx)
+	}
+	var c *cache
+	if miss != nil {
+		c = newCache(miss)
+	}
+	return &GIF{GIF: g, cache: c}, nil
+}
+
+// SetCacheBudget sets the maximum number of bytes of rendered frames held
+// in the receiver's frame cache. When the budget is exceeded, the least
+// recently used frames are evicted. A budget of zero or less removes the
+// limit, which is the default. SetCacheBudget has no effect if the GIF was
+// created without a frame cache and must not be called during Play.
+func (img *GIF) SetCacheBudget(bytes int64) {
+	img.cache.setBudget(bytes)
+}
+
+// CacheStats returns statistics for the receiver's frame cache.
+func (img *GIF) CacheStats() CacheStats {
+	return img.cache.stats()
+}
+
+func (img *GIF) ColorModel() color.Model {
+	if img.Config.ColorModel != nil {
+		return img.Config.ColorModel
+	}
+	return img.GIF.Image[0].ColorModel()
+}
+
+func (img *GIF) Bounds() image.Rectangle {
+	return img.GIF.Image[0].Bounds()
+}
+
+func (img *GIF) At(x, y int) color.Color {
+	return img.GIF.Image[0].At(x, y)
+}
+
+// Play renders the receiver's frames into dst and calls fn on each
+// rendered frame, waiting for each frame's delay. Play returns when
+// the animation is complete, ctx is cancelled or fn returns an error.
+func (img *GIF) Play(ctx context.Context, dst draw.Image, fn func(image.Image) error) error {
+	const (
+		restoreBackground = 2
+		restorePrevious   = 3
+	)
+	clock := clockOrSystem(img.Clock)
+	var background image.Image
+	pal, ok := img.Config.ColorModel.(color.Palette)
+	if idx := int(img.BackgroundIndex); ok {
+		background = &image.Uniform{pal[idx]}
+	}
+
+	loopCount := img.LoopCount
+	if loopCount <= 0 {
+		loopCount = -loopCount - 1
+	}
+	for i := 0; i <= loopCount || loopCount == -1; i++ {
+		for f, frame := range img.Image {
+			var restore *image.Paletted
+			if img.Disposal != nil && img.Disposal[f] == restorePrevious {
+				restore = image.NewPaletted(frame.Bounds(), frame.Palette)
+				draw.Copy(restore, restore.Bounds().Min, dst, frame.Bounds(), draw.Over, nil)
+			}
+			// Frames are always composited into dst, even when
+			// the rendered frame is cached, so that frames that
+			// have been evicted from the cache are rendered
+			// correctly.
+			draw.Copy(dst, frame.Bounds().Min, frame, frame.Bounds(), draw.Over, nil)
+			if ctx.Err() != nil {
+				return nil
+			}
+			r, ok := img.cache.get(frame)
+			if !ok {
+				var err error
+				r, err = img.cache.put(frame, dst)
+				if err != nil {
+					return err
+				}
+			}
+			err := fn(r)
+			if err != nil {
+				return err
+			}
+			if !Sleep(ctx, clock, img.delay(f)) {
+				return nil
+			}
+			if img.Disposal != nil {
+				switch img.Disposal[f] {
+				case restoreBackground:
+					if background == nil {
+						if idx := int(img.BackgroundIndex); idx < len(frame.Palette) {
+							background = &image.Uniform{frame.Palette[idx]}
+						} else {
+							// No available background, so make this
+							// clear in the rendered image.
+							background = &image.Uniform{color.RGBA{R: 0xff, A: 0xff}}
+						}
+					}
+					draw.Copy(dst, frame.Bounds().Min, background, frame.Bounds(), draw.Over, nil)
+				case restorePrevious:
+					draw.Copy(dst, frame.Bounds().Min, restore, restore.Bounds(), draw.Over, nil)
+				}
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+	}
+	return fn(dst)
+}
+
+// delay returns the delay after frame f.
+func (img *GIF) delay(f int) time.Duration {
+	if img.Delay == nil {
+		return 0
+	}
+	return 10 * time.Duration(img.Delay[f]) * time.Millisecond
+}
@@ -0,0 +1,200 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package animation
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// testGIF returns a GIF with a single pixel frame for each colour index
+// in the palette, and the given delays in centiseconds.
+func testGIF(delays []int, loop int) *gif.GIF {
+	pal := color.Palette{
+		color.RGBA{R: 0xff, A: 0xff},
+		color.RGBA{G: 0xff, A: 0xff},
+		color.RGBA{B: 0xff, A: 0xff},
+	}
+	g := &gif.GIF{Delay: delays, LoopCount: loop}
+	for i := range delays {
+		frame := image.NewPaletted(image.Rect(0, 0, 1, 1), pal)
+		frame.SetColorIndex(0, 0, uint8(i%len(pal)))
+		g.Image = append(g.Image, frame)
+	}
+	return g
+}
+
+type frameTime struct {
+	colour color.RGBA
+	at     time.Duration
+}
+
+func TestGIFPlay(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	g, err := NewGIF(testGIF([]int{10, 20, 30}, -1), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g.Clock = clock
+
+	var got []frameTime
+	done := make(chan error)
+	go func() {
+		done <- g.Play(context.Background(), image.NewRGBA(g.Bounds()), func(img image.Image) error {
+			got = append(got, frameTime{
+				colour: color.RGBAModel.Convert(img.At(0, 0)).(color.RGBA),
+				at:     clock.Now().Sub(start),
+			})
+			return nil
+		})
+	}()
+	for _, d := range []time.Duration{100, 200, 300} {
+		clock.BlockUntil(1)
+		clock.Advance(d * time.Millisecond)
+	}
+	err = <-done
+	if err != nil {
+		t.Errorf("unexpected error from Play: %v", err)
+	}
+
+	want := []frameTime{
+		{colour: color.RGBA{R: 0xff, A: 0xff}, at: 0},
+		{colour: color.RGBA{G: 0xff, A: 0xff}, at: 100 * time.Millisecond},
+		{colour: color.RGBA{B: 0xff, A: 0xff}, at: 300 * time.Millisecond},
+		// The final frame is rendered again at the end of the animation.
+		{colour: color.RGBA{B: 0xff, A: 0xff}, at: 600 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected frames:\ngot: %v\nwant:%v", got, want)
+	}
+}
+
+func TestGIFPlayCancel(t *testing.T) {
+	clock := NewManualClock(time.Time{})
+	g, err := NewGIF(testGIF([]int{10, 10}, 0), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g.Clock = clock
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var frames int
+	done := make(chan error)
+	go func() {
+		done <- g.Play(ctx, image.NewRGBA(g.Bounds()), func(image.Image) error {
+			frames++
+			return nil
+		})
+	}()
+	for i := 0; i < 5; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(100 * time.Millisecond)
+	}
+	clock.BlockUntil(1)
+	cancel()
+	err = <-done
+	if err != nil {
+		t.Errorf("unexpected error from Play: %v", err)
+	}
+	if frames != 6 {
+		t.Errorf("unexpected number of frames for looping animation: got:%d want:6", frames)
+	}
+}
+
+func TestGIFCache(t *testing.T) {
+	clock := NewManualClock(time.Time{})
+	var misses int
+	g, err := NewGIF(testGIF([]int{0, 0}, 2), func(img image.Image) (image.Image, error) {
+		misses++
+		return img, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g.Clock = clock
+	var frames int
+	err = g.Play(context.Background(), image.NewRGBA(g.Bounds()), func(image.Image) error {
+		frames++
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error from Play: %v", err)
+	}
+	if frames != 7 {
+		t.Errorf("unexpected number of frames: got:%d want:7", frames)
+	}
+	if misses != 2 {
+		t.Errorf("unexpected number of cache misses: got:%d want:2", misses)
+	}
+}
+
+func TestGIFCacheBudget(t *testing.T) {
+	for _, test := range []struct {
+		budget    int64
+		wantStats CacheStats
+	}{
+		{
+			budget:    0,
+			wantStats: CacheStats{Entries: 3, Bytes: 12, Hits: 6, Misses: 3},
+		},
+		{
+			// Cycling through three frames with room
+			// for two evicts every frame before reuse.
+			budget:    8,
+			wantStats: CacheStats{Entries: 2, Bytes: 8, Budget: 8, Misses: 9, Evictions: 7},
+		},
+	} {
+		clock := NewManualClock(time.Time{})
+		var misses int
+		g, err := NewGIF(testGIF([]int{0, 0, 0}, 2), func(img image.Image) (image.Image, error) {
+			misses++
+			dst := image.NewRGBA(img.Bounds())
+			draw.Copy(dst, dst.Bounds().Min, img, img.Bounds(), draw.Src, nil)
+			return dst, nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		g.Clock = clock
+		g.SetCacheBudget(test.budget)
+		var got []color.RGBA
+		err = g.Play(context.Background(), image.NewRGBA(g.Bounds()), func(img image.Image) error {
+			got = append(got, color.RGBAModel.Convert(img.At(0, 0)).(color.RGBA))
+			return nil
+		})
+		if err != nil {
+			t.Errorf("unexpected error from Play: %v", err)
+		}
+		pal := g.Image[0].Palette
+		for i, c := range got {
+			// The final frame is repeated when play stops.
+			want := pal[i%len(pal)]
+			if i == len(got)-1 {
+				want = pal[len(pal)-1]
+			}
+			if c != want {
+				t.Errorf("unexpected colour for frame %d with budget %d: got:%v want:%v", i, test.budget, c, want)
+			}
+		}
+		if len(got) != 10 {
+			t.Errorf("unexpected number of frames with budget %d: got:%d want:10", test.budget, len(got))
+		}
+		stats := g.CacheStats()
+		if stats != test.wantStats {
+			t.Errorf("unexpected cache stats with budget %d:\ngot: %+v\nwant:%+v", test.budget, stats, test.wantStats)
+		}
+		if uint64(misses) != stats.Misses {
+			t.Errorf("unexpected number of renders with budget %d: got:%d want:%d", test.budget, misses, stats.Misses)
+		}
+	}
+}
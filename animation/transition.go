@@ -0,0 +1,121 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package animation
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// Transition renders the frame of a transition between the images from
+// and to at progress p into dst. The progress is in [0, 1], with 0 being
+// the start and 1 the end of the transition. The images from, to and dst
+// have the same bounds.
+type Transition func(dst draw.Image, from, to image.Image, p float64)
+
+// FrameInterval is the target interval between transition frames.
+const FrameInterval = time.Second / 30
+
+// PlayTransition renders the transition t from the image from to the image
+// to over the duration dur, calling fn on each rendered frame. Frames are
+// rendered at most every FrameInterval and frames are skipped if fn is slow.
+// The last frame passed to fn is always to. The image from is scaled to the
+// bounds of to, and if it is nil, a black image is used. If clock is nil,
+// SystemClock is used.
+func PlayTransition(ctx context.Context, clock Clock, t Transition, from, to image.Image, dur time.Duration, fn func(image.Image) error) error {
+	clock = clockOrSystem(clock)
+	b := to.Bounds()
+	if from == nil {
+		from = image.NewUniform(color.Black)
+	} else if from.Bounds() != b {
+		scaled := image.NewRGBA(b)
+		draw.BiLinear.Scale(scaled, b, from, from.Bounds(), draw.Src, nil)
+		from = scaled
+	}
+	start := clock.Now()
+	dst := image.NewRGBA(b)
+	for {
+		elapsed := clock.Now().Sub(start)
+		if elapsed >= dur {
+			break
+		}
+		t(dst, from, to, float64(elapsed)/float64(dur))
+		err := fn(dst)
+		if err != nil {
+			return err
+		}
+		if !Sleep(ctx, clock, FrameInterval) {
+			return ctx.Err()
+		}
+	}
+	return fn(to)
+}
+
+// Crossfade is a transition that fades between images.
+func Crossfade(dst draw.Image, from, to image.Image, p float64) {
+	b := dst.Bounds()
+	draw.Draw(dst, b, from, b.Min, draw.Src)
+	alpha := image.NewUniform(color.Alpha{A: uint8(p*0xff + 0.5)})
+	draw.DrawMask(dst, b, to, to.Bounds().Min, alpha, image.Point{}, draw.Over)
+}
+
+// SlideLeft is a transition where the new image pushes the old image out
+// to the left.
+func SlideLeft(dst draw.Image, from, to image.Image, p float64) {
+	slide(dst, from, to, p, image.Point{X: -1})
+}
+
+// SlideRight is a transition where the new image pushes the old image out
+// to the right.
+func SlideRight(dst draw.Image, from, to image.Image, p float64) {
+	slide(dst, from, to, p, image.Point{X: 1})
+}
+
+// SlideUp is a transition where the new image pushes the old image out
+// upwards.
+func SlideUp(dst draw.Image, from, to image.Image, p float64) {
+	slide(dst, from, to, p, image.Point{Y: -1})
+}
+
+// SlideDown is a transition where the new image pushes the old image out
+// downwards.
+func SlideDown(dst draw.Image, from, to image.Image, p float64) {
+	slide(dst, from, to, p, image.Point{Y: 1})
+}
+
+// slide renders a slide transition in the direction dir.
+func slide(dst draw.Image, from, to image.Image, p float64, dir image.Point) {
+	b := dst.Bounds()
+	off := image.Point{
+		X: dir.X * int(p*float64(b.Dx())+0.5),
+		Y: dir.Y * int(p*float64(b.Dy())+0.5),
+	}
+	size := image.Point{X: dir.X * b.Dx(), Y: dir.Y * b.Dy()}
+	draw.Draw(dst, b.Add(off), from, from.Bounds().Min, draw.Src)
+	draw.Draw(dst, b.Add(off.Sub(size)), to, to.Bounds().Min, draw.Src)
+}
+
+// Flip is a transition where the old image turns about the vertical axis
+// to reveal the new image on its reverse side.
+func Flip(dst draw.Image, from, to image.Image, p float64) {
+	b := dst.Bounds()
+	draw.Draw(dst, b, image.Black, image.Point{}, draw.Src)
+	src := from
+	scale := 1 - 2*p
+	if p > 0.5 {
+		src = to
+		scale = 2*p - 1
+	}
+	w := int(scale*float64(b.Dx()) + 0.5)
+	if w == 0 {
+		return
+	}
+	r := image.Rect(0, 0, w, b.Dy()).Add(b.Min).Add(image.Point{X: (b.Dx() - w) / 2})
+	draw.ApproxBiLinear.Scale(dst, r, src, src.Bounds(), draw.Src, nil)
+}
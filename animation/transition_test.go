@@ -0,0 +1,112 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package animation
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+)
+
+var transitions = []struct {
+	name string
+	t    Transition
+}{
+	{name: "crossfade", t: Crossfade},
+	{name: "slide_left", t: SlideLeft},
+	{name: "slide_right", t: SlideRight},
+	{name: "slide_up", t: SlideUp},
+	{name: "slide_down", t: SlideDown},
+	{name: "flip", t: Flip},
+}
+
+func uniform(b image.Rectangle, c color.Color) *image.RGBA {
+	img := image.NewRGBA(b)
+	draw.Draw(img, b, image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestTransitionEnds(t *testing.T) {
+	b := image.Rect(0, 0, 72, 72)
+	from := uniform(b, color.RGBA{R: 0xff, A: 0xff})
+	to := uniform(b, color.RGBA{B: 0xff, A: 0xff})
+	for _, test := range transitions {
+		t.Run(test.name, func(t *testing.T) {
+			for _, end := range []struct {
+				p    float64
+				want *image.RGBA
+			}{
+				{p: 0, want: from},
+				{p: 1, want: to},
+			} {
+				dst := image.NewRGBA(b)
+				test.t(dst, from, to, end.p)
+				for y := b.Min.Y; y < b.Max.Y; y++ {
+					for x := b.Min.X; x < b.Max.X; x++ {
+						if got, want := dst.RGBAAt(x, y), end.want.RGBAAt(x, y); got != want {
+							t.Fatalf("unexpected pixel at %d,%d for p=%v: got:%v want:%v", x, y, end.p, got, want)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSlideMidpoint(t *testing.T) {
+	b := image.Rect(0, 0, 10, 10)
+	red := color.RGBA{R: 0xff, A: 0xff}
+	blue := color.RGBA{B: 0xff, A: 0xff}
+	dst := image.NewRGBA(b)
+	SlideLeft(dst, uniform(b, red), uniform(b, blue), 0.5)
+	if got := dst.RGBAAt(4, 5); got != red {
+		t.Errorf("unexpected left half pixel: got:%v want:%v", got, red)
+	}
+	if got := dst.RGBAAt(5, 5); got != blue {
+		t.Errorf("unexpected right half pixel: got:%v want:%v", got, blue)
+	}
+}
+
+func TestPlayTransition(t *testing.T) {
+	clock := NewManualClock(time.Time{})
+	b := image.Rect(0, 0, 8, 8)
+	from := uniform(image.Rect(0, 0, 16, 16), color.RGBA{R: 0xff, A: 0xff})
+	to := uniform(b, color.RGBA{B: 0xff, A: 0xff})
+
+	var (
+		frames int
+		last   image.Image
+	)
+	done := make(chan error)
+	go func() {
+		done <- PlayTransition(context.Background(), clock, Crossfade, from, to, 100*time.Millisecond, func(img image.Image) error {
+			frames++
+			if img.Bounds() != b {
+				t.Errorf("unexpected frame bounds: got:%v want:%v", img.Bounds(), b)
+			}
+			last = img
+			return nil
+		})
+	}()
+	for i := 0; i < 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(FrameInterval)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(100 * time.Millisecond)
+	err := <-done
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if frames != 5 {
+		t.Errorf("unexpected number of frames: got:%d want:5", frames)
+	}
+	if last != to {
+		t.Error("final frame is not the target image")
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+// Arbiter decides which of several competing subsystems, for example
+// alerts, widgets and user pages, has its content shown on each key of a
+// Deck. Each subsystem claims keys under an owner name with a priority.
+// The content with the highest priority is shown and, when it is cleared
+// or expires, the content of the next highest claim is restored. Among
+// claims of equal priority the most recently set is shown.
+//
+// Keys managed by an Arbiter should not be set directly on the Deck.
+type Arbiter struct {
+	d     *Deck
+	errFn func(error)
+
+	mu     sync.Mutex
+	seq    uint64
+	claims map[int][]*claim // claims is keyed by key number.
+	shown  map[int]*claim   // shown is the claim last shown on each key.
+}
+
+// claim is a subsystem's content for a key.
+type claim struct {
+	owner    string
+	priority int
+	seq      uint64
+	img      image.Image
+	stop     chan struct{} // stop is closed when the claim is removed.
+}
+
+// NewArbiter returns an Arbiter for the keys of d. If errFn is not nil, it
+// is called with errors rendering content restored after a claim expires.
+func NewArbiter(d *Deck, errFn func(error)) *Arbiter {
+	return &Arbiter{
+		d:      d,
+		errFn:  errFn,
+		claims: make(map[int][]*claim),
+		shown:  make(map[int]*claim),
+	}
+}
+
+// Set sets the content claimed by owner for the button at row and column
+// with the given priority, replacing any earlier claim by owner on the
+// key. Higher priorities take precedence. If ttl is positive, the claim
+// expires after ttl. The image is rendered if the claim takes precedence
+// over the key's other claims. A nil image clears owner's claim.
+func (a *Arbiter) Set(row, col int, owner string, priority int, img image.Image, ttl time.Duration) error {
+	if img == nil {
+		return a.Clear(row, col, owner)
+	}
+	key, err := a.d.keyIndex(row, col)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.remove(key, owner)
+	a.seq++
+	c := &claim{owner: owner, priority: priority, seq: a.seq, img: img, stop: make(chan struct{})}
+	a.claims[key] = append(a.claims[key], c)
+	if ttl > 0 {
+		clock := a.d.clock
+		if clock == nil {
+			clock = animation.SystemClock
+		}
+		t := clock.NewTimer(ttl)
+		go func() {
+			select {
+			case <-t.C():
+				a.expire(key, c)
+			case <-c.stop:
+				t.Stop()
+			}
+		}()
+	}
+	return a.show(key)
+}
+
+// Clear clears the claim by owner on the button at row and column,
+// restoring the content of the key's next highest claim. If no claims
+// remain, the key is set to black.
+func (a *Arbiter) Clear(row, col int, owner string) error {
+	key, err := a.d.keyIndex(row, col)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.remove(key, owner) {
+		return nil
+	}
+	return a.show(key)
+}
+
+// Release clears all claims by owner.
+func (a *Arbiter) Release(owner string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var errs []error
+	for key := range a.claims {
+		if a.remove(key, owner) {
+			err := a.show(key)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("key %d: %w", key, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Owner returns the owner of the content shown on the button at row and
+// column, and whether any subsystem has claimed the key.
+func (a *Arbiter) Owner(row, col int) (owner string, ok bool) {
+	key, err := a.d.keyIndex(row, col)
+	if err != nil {
+		return "", false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c := a.top(key)
+	if c == nil {
+		return "", false
+	}
+	return c.owner, true
+}
+
+// expire removes c from the claims of key if it is still present.
+func (a *Arbiter) expire(key int, c *claim) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	claims := a.claims[key]
+	for i, o := range claims {
+		if o != c {
+			continue
+		}
+		a.claims[key] = append(claims[:i], claims[i+1:]...)
+		if len(a.claims[key]) == 0 {
+			delete(a.claims, key)
+		}
+		err := a.show(key)
+		if err != nil && a.errFn != nil {
+			a.errFn(fmt.Errorf("key %d: %w", key, err))
+		}
+		return
+	}
+}
+
+// remove removes the claim by owner on key, returning whether a claim
+// was removed. The caller must hold a.mu.
+func (a *Arbiter) remove(key int, owner string) bool {
+	claims := a.claims[key]
+	for i, c := range claims {
+		if c.owner != owner {
+			continue
+		}
+		close(c.stop)
+		a.claims[key] = append(claims[:i], claims[i+1:]...)
+		if len(a.claims[key]) == 0 {
+			delete(a.claims, key)
+		}
+		return true
+	}
+	return false
+}
+
+// top returns the claim on key that takes precedence, or nil if the key
+// has no claims. The caller must hold a.mu.
+func (a *Arbiter) top(key int) *claim {
+	var top *claim
+	for _, c := range a.claims[key] {
+		if top == nil || c.priority > top.priority || (c.priority == top.priority && c.seq > top.seq) {
+			top = c
+		}
+	}
+	return top
+}
+
+// show renders the content of the claim on key that takes precedence if
+// it is not already shown. The caller must hold a.mu.
+func (a *Arbiter) show(key int) error {
+	c := a.top(key)
+	if c != nil && c == a.shown[key] {
+		return nil
+	}
+	var img image.Image
+	if c == nil {
+		delete(a.shown, key)
+		bounds, err := a.d.Bounds()
+		if err != nil {
+			return err
+		}
+		img = (&layers{}).composite(bounds)
+	} else {
+		a.shown[key] = c
+		img = c.img
+	}
+	row, col := key/a.d.desc.cols, key%a.d.desc.cols
+	err := a.d.SetImage(row, col, img)
+	if err != nil {
+		// Allow the claim to be shown on the
+		// next change to the key.
+		delete(a.shown, key)
+	}
+	return err
+}
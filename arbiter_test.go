@@ -0,0 +1,135 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+func TestArbiter(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.setDev(&virtDev{Writer: io.Discard})
+	clock := animation.NewManualClock(time.Time{})
+	WithClock(clock)(d)
+	a := NewArbiter(d, func(err error) { t.Errorf("unexpected error: %v", err) })
+
+	page := uniformRGBA(image.Rect(0, 0, 72, 72), color.RGBA{B: 0xff, A: 0xff})
+	widget := uniformRGBA(image.Rect(0, 0, 72, 72), color.RGBA{G: 0xff, A: 0xff})
+	alert := uniformRGBA(image.Rect(0, 0, 72, 72), color.RGBA{R: 0xff, A: 0xff})
+	key := d.Key(1, 2)
+
+	check := func(wantOwner string, want image.Image) {
+		t.Helper()
+		owner, ok := a.Owner(1, 2)
+		if owner != wantOwner || ok != (wantOwner != "") {
+			t.Errorf("unexpected owner: got:%q want:%q", owner, wantOwner)
+		}
+		if want == nil {
+			got := d.images[key]
+			if got == nil || color.RGBAModel.Convert(got.At(0, 0)) != (color.RGBA{A: 0xff}) {
+				t.Error("key not cleared to black")
+			}
+			return
+		}
+		if got := d.images[key]; got != want {
+			t.Errorf("unexpected image for %s", wantOwner)
+		}
+	}
+	// waitFor waits for an expiry to restore the claim by owner.
+	waitFor := func(owner string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			got, _ := a.Owner(1, 2)
+			if got == owner {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %q to be restored", owner)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	err = a.Set(1, 2, "page", 0, page, 0)
+	if err != nil {
+		t.Fatalf("unexpected error setting page: %v", err)
+	}
+	check("page", page)
+
+	// A higher priority claim is shown, and lower priority
+	// updates do not replace it.
+	err = a.Set(1, 2, "alert", 10, alert, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error setting alert: %v", err)
+	}
+	check("alert", alert)
+	err = a.Set(1, 2, "widget", 5, widget, 0)
+	if err != nil {
+		t.Fatalf("unexpected error setting widget: %v", err)
+	}
+	check("alert", alert)
+
+	// When the alert expires, the highest remaining claim
+	// is restored.
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	waitFor("widget")
+	check("widget", widget)
+
+	err = a.Clear(1, 2, "widget")
+	if err != nil {
+		t.Fatalf("unexpected error clearing widget: %v", err)
+	}
+	check("page", page)
+
+	// Replacing a claim with a timeout cancels its expiry.
+	err = a.Set(1, 2, "alert", 10, alert, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error setting alert: %v", err)
+	}
+	err = a.Set(1, 2, "alert", 10, alert, 0)
+	if err != nil {
+		t.Fatalf("unexpected error setting alert: %v", err)
+	}
+	clock.BlockUntil(0)
+	check("alert", alert)
+
+	// Claims of equal priority are shown in order of setting.
+	err = a.Set(1, 2, "widget", 10, widget, 0)
+	if err != nil {
+		t.Fatalf("unexpected error setting widget: %v", err)
+	}
+	check("widget", widget)
+
+	err = a.Release("widget")
+	if err != nil {
+		t.Fatalf("unexpected error releasing widget: %v", err)
+	}
+	check("alert", alert)
+	err = a.Set(1, 2, "alert", 10, nil, 0)
+	if err != nil {
+		t.Fatalf("unexpected error clearing alert: %v", err)
+	}
+	check("page", page)
+	err = a.Clear(1, 2, "page")
+	if err != nil {
+		t.Fatalf("unexpected error clearing page: %v", err)
+	}
+	check("", nil)
+
+	if err := a.Set(3, 0, "page", 0, page, 0); err == nil {
+		t.Error("expected error for key out of bounds")
+	}
+}
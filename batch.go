@@ -0,0 +1,141 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+)
+
+// Batch is a set of key image and brightness changes that are applied to
+// a Deck together, for example when switching between pages of a layout.
+type Batch struct {
+	d *Deck
+
+	images     map[int]image.Image
+	brightness int // brightness is -1 if not set.
+	dim        int // dim is -1 if not dimming.
+}
+
+// Begin returns a new empty Batch for the receiver.
+func (d *Deck) Begin() *Batch {
+	return &Batch{d: d, brightness: -1, dim: -1}
+}
+
+// SetImage adds setting the image on the button at the given row and
+// column to the batch. A later image for the same key replaces an
+// earlier image.
+func (b *Batch) SetImage(row, col int, img image.Image) error {
+	key, err := b.d.keyIndex(row, col)
+	if err != nil {
+		return err
+	}
+	if b.images == nil {
+		b.images = make(map[int]image.Image)
+	}
+	b.images[key] = img
+	return nil
+}
+
+// Precompute renders all the images in the batch to RawImages using
+// Deck.RawImages. A precomputed batch may be applied repeatedly, for
+// example each time a page is shown, without re-encoding its images as
+// long as the Deck's rendering settings are not changed.
+func (b *Batch) Precompute() error {
+	raw, err := b.d.RawImages(b.images)
+	if err != nil {
+		return err
+	}
+	for k, img := range raw {
+		b.images[k] = img
+	}
+	return nil
+}
+
+// SetBrightness adds setting the brightness of the device to the batch.
+func (b *Batch) SetBrightness(percent int) error {
+	if percent < 0 || 100 < percent {
+		return fmt.Errorf("brightness out of range: %d", percent)
+	}
+	b.brightness = percent
+	return nil
+}
+
+// Dim sets the brightness that the device is dimmed to while the batch's
+// images are written, hiding the progressive update of the keys. After
+// the images are written, the brightness is set to the batch's brightness
+// or, if the batch does not set a brightness, to the last brightness set
+// on the Deck.
+func (b *Batch) Dim(percent int) error {
+	if percent < 0 || 100 < percent {
+		return fmt.Errorf("brightness out of range: %d", percent)
+	}
+	b.dim = percent
+	return nil
+}
+
+// Apply applies the batch to the Deck. All images are rendered before any
+// are written so that the keys change over as short a time as possible.
+// Images are written in key order. If the batch lowers the brightness, the
+// new brightness is set before the images are written, otherwise it is set
+// after. Any animations running on the batch's keys are stopped.
+func (b *Batch) Apply() error {
+	d := b.d
+	keys := make([]int, 0, len(b.images))
+	for k := range b.images {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	raw := make([]image.Image, len(keys))
+	for i, k := range keys {
+		img, err := d.RawImage(b.images[k])
+		if err != nil {
+			return err
+		}
+		raw[i] = img
+	}
+
+	current, known := d.Brightness()
+	target := b.brightness
+	if target < 0 && known {
+		target = current
+	}
+	if b.dim >= 0 && target < 0 {
+		return errors.New("cannot dim without a known brightness")
+	}
+
+	dimmed := b.dim >= 0 && len(keys) != 0
+	before, after := -1, -1
+	switch {
+	case dimmed:
+		before, after = b.dim, target
+	case b.brightness >= 0 && (!known || b.brightness < current):
+		before = b.brightness
+	case b.brightness >= 0:
+		after = b.brightness
+	}
+	if before >= 0 {
+		err := d.SetBrightness(before)
+		if err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for i, k := range keys {
+		err := d.SetImage(k/d.desc.cols, k%d.desc.cols, raw[i])
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+	if after >= 0 {
+		// Set the brightness even if image writes failed
+		// so the deck is not left dimmed.
+		errs = append(errs, d.SetBrightness(after))
+	}
+	return errors.Join(errs...)
+}
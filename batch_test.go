@@ -0,0 +1,142 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 80, 80))
+	for _, test := range []struct {
+		name       string
+		brightness int // brightness is the initial brightness, -1 for unknown.
+		batch      func(b *Batch) error
+		want       []string
+		wantErr    error
+	}{
+		{
+			name:       "raise",
+			brightness: 20,
+			batch: func(b *Batch) error {
+				return errors.Join(b.SetImage(1, 0, img), b.SetImage(0, 1, img), b.SetBrightness(60))
+			},
+			want: []string{"image 1", "image 3", "brightness 60"},
+		},
+		{
+			name:       "lower",
+			brightness: 60,
+			batch: func(b *Batch) error {
+				return errors.Join(b.SetImage(0, 2, img), b.SetBrightness(20))
+			},
+			want: []string{"brightness 20", "image 2"},
+		},
+		{
+			name:       "precompute",
+			brightness: 20,
+			batch: func(b *Batch) error {
+				err := errors.Join(b.SetImage(1, 0, img), b.SetImage(0, 1, img))
+				if err != nil {
+					return err
+				}
+				err = b.Precompute()
+				if err != nil {
+					return err
+				}
+				for k, img := range b.images {
+					if _, ok := img.(*RawImage); !ok {
+						return fmt.Errorf("image for key %d not precomputed", k)
+					}
+				}
+				return nil
+			},
+			want: []string{"image 1", "image 3"},
+		},
+		{
+			name:       "dim_restore",
+			brightness: 60,
+			batch: func(b *Batch) error {
+				return errors.Join(b.SetImage(0, 0, img), b.Dim(0))
+			},
+			want: []string{"brightness 0", "image 0", "brightness 60"},
+		},
+		{
+			name:       "dim_new",
+			brightness: 60,
+			batch: func(b *Batch) error {
+				return errors.Join(b.SetImage(0, 0, img), b.Dim(10), b.SetBrightness(80))
+			},
+			want: []string{"brightness 10", "image 0", "brightness 80"},
+		},
+		{
+			name:       "dim_no_images",
+			brightness: 60,
+			batch: func(b *Batch) error {
+				return b.Dim(10)
+			},
+		},
+		{
+			name:       "dim_unknown",
+			brightness: -1,
+			batch: func(b *Batch) error {
+				return errors.Join(b.SetImage(0, 0, img), b.Dim(10))
+			},
+			wantErr: errors.New("cannot dim without a known brightness"),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := newTestDeck(StreamDeckMini)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dev := &virtDev{Writer: io.Discard}
+			d.setDev(dev)
+			d.brightness = test.brightness
+
+			b := d.Begin()
+			err = test.batch(b)
+			if err != nil {
+				t.Fatalf("unexpected error building batch: %v", err)
+			}
+			err = b.Apply()
+			if !sameError(err, test.wantErr) {
+				t.Errorf("unexpected error for Apply: got:%v want:%v", err, test.wantErr)
+			}
+			got := batchOps(dev.actions)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected operations:\ngot: %q\nwant:%q", got, test.want)
+			}
+		})
+	}
+}
+
+// batchOps summarises device actions as brightness changes and the
+// first report of each key image.
+func batchOps(actions []string) []string {
+	const (
+		brightness = "SendFeatureReport([]byte{0x5, 0x55, 0xaa, 0xd1, 0x1, "
+		firstPage  = "Write([]byte{0x2, 0x1, 0x0, 0x0, "
+	)
+	var ops []string
+	for _, a := range actions {
+		var v int
+		switch {
+		case strings.HasPrefix(a, brightness):
+			fmt.Sscanf(a[len(brightness):], "0x%x", &v)
+			ops = append(ops, fmt.Sprint("brightness ", v))
+		case strings.HasPrefix(a, firstPage):
+			// Skip the done flag to the key+1 byte.
+			fmt.Sscanf(a[len(firstPage):], "0x%x, 0x%x", new(int), &v)
+			ops = append(ops, fmt.Sprint("image ", v-1))
+		}
+	}
+	return ops
+}
@@ -0,0 +1,99 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// CanvasBounds returns the bounds of an image spanning all the keys of the
+// deck, where adjacent keys are separated by gap pixels. The gap pixels
+// correspond to the bezel between keys and are not rendered. If the device
+// is not visual an error is returned.
+func (d *Deck) CanvasBounds(gap int) (image.Rectangle, error) {
+	if !d.desc.visual {
+		return image.Rectangle{}, fmt.Errorf("images not supported by %s", d.desc)
+	}
+	if gap < 0 {
+		return image.Rectangle{}, fmt.Errorf("negative gap: %d", gap)
+	}
+	return d.desc.canvasBounds(gap), nil
+}
+
+// SetCanvas renders the provided image across all the keys of the deck.
+// The image is scaled to fit the bounds returned by CanvasBounds for the
+// given gap, preserving its aspect ratio, and the region under each key is
+// rendered on that key. Parts of the image falling in the gaps between keys
+// are not shown, so lines crossing the deck remain straight.
+func (d *Deck) SetCanvas(img image.Image, gap int) error {
+	b, err := d.CanvasBounds(gap)
+	if err != nil {
+		return err
+	}
+	s := d.renderSettings()
+	bg := s.background
+	if img.Bounds() != b || bg != nil {
+		dst := image.NewRGBA(b)
+		op := draw.Src
+		if bg != nil {
+			draw.Draw(dst, b, &image.Uniform{bg}, image.Point{}, draw.Src)
+			op = draw.Over
+		}
+		draw.BiLinear.Scale(dst, keepAspectRatio(dst, img), img, img.Bounds(), op, nil)
+		img = dst
+	}
+	// Key padding is not applied to canvas regions.
+	s.padding = 0
+	for row := 0; row < d.desc.rows; row++ {
+		for col := 0; col < d.desc.cols; col++ {
+			raw, err := d.rawImage(d.desc.keyView(img, row, col, gap), s)
+			if err != nil {
+				return err
+			}
+			err = d.SetImage(row, col, raw)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// canvasBounds returns the bounds of the canvas for the device.
+func (d *device) canvasBounds(gap int) image.Rectangle {
+	return image.Rectangle{Max: image.Point{
+		X: d.cols*d.keySize.X + (d.cols-1)*gap,
+		Y: d.rows*d.keySize.Y + (d.rows-1)*gap,
+	}}
+}
+
+// keyView returns a view of the region of the canvas img under the key at
+// row and col. The view has the bounds of a key image.
+func (d *device) keyView(img image.Image, row, col, gap int) image.Image {
+	off := img.Bounds().Min.Add(image.Point{
+		X: col * (d.keySize.X + gap),
+		Y: row * (d.keySize.Y + gap),
+	})
+	return view{Image: img, offset: off, bounds: d.bounds()}
+}
+
+// view is a translated view of a region of an image.
+type view struct {
+	image.Image
+	offset image.Point
+	bounds image.Rectangle
+}
+
+func (v view) Bounds() image.Rectangle {
+	return v.bounds
+}
+
+func (v view) At(x, y int) color.Color {
+	return v.Image.At(x+v.offset.X, y+v.offset.Y)
+}
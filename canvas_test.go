@@ -0,0 +1,97 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+var canvasBoundsTests = []struct {
+	pid     PID
+	gap     int
+	want    image.Rectangle
+	wantErr error
+}{
+	{pid: StreamDeckMini, gap: 0, want: image.Rect(0, 0, 240, 160)},
+	{pid: StreamDeckMini, gap: 10, want: image.Rect(0, 0, 260, 170)},
+	{pid: StreamDeckOriginal, gap: 10, want: image.Rect(0, 0, 400, 236)},
+	{pid: StreamDeckXL, gap: 10, want: image.Rect(0, 0, 838, 414)},
+	{pid: StreamDeckXL, gap: -1, wantErr: errors.New("negative gap: -1")},
+	{pid: StreamDeckPedal, gap: 0, wantErr: errors.New("images not supported by StreamDeckPedal")},
+}
+
+func TestDeckCanvasBounds(t *testing.T) {
+	for _, test := range canvasBoundsTests {
+		t.Run(fmt.Sprintf("%s-%d", test.pid, test.gap), func(t *testing.T) {
+			d, err := newTestDeck(test.pid)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := d.CanvasBounds(test.gap)
+			if !sameError(err, test.wantErr) {
+				t.Errorf("unexpected error for CanvasBounds: got:%v want:%v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("unexpected result for CanvasBounds: got:%v want:%v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDeviceKeyView(t *testing.T) {
+	const gap = 7
+	gapColor := color.RGBA{R: 0xff, A: 0xff}
+	keyColor := func(row, col int) color.RGBA {
+		return color.RGBA{G: byte(row), B: byte(col), A: 0xff}
+	}
+	for _, pid := range []PID{StreamDeckMini, StreamDeckOriginal, StreamDeckXL} {
+		t.Run(pid.String(), func(t *testing.T) {
+			desc := devices[pid]
+			// Use a canvas with a non-zero origin to check that
+			// views are taken relative to the canvas bounds.
+			b := desc.canvasBounds(gap).Add(image.Point{X: 3, Y: 5})
+			canvas := image.NewRGBA(b)
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					canvas.SetRGBA(x, y, gapColor)
+				}
+			}
+			for row := 0; row < desc.rows; row++ {
+				for col := 0; col < desc.cols; col++ {
+					origin := b.Min.Add(image.Point{X: col * (desc.keySize.X + gap), Y: row * (desc.keySize.Y + gap)})
+					key := image.Rectangle{Min: origin, Max: origin.Add(desc.keySize)}
+					for y := key.Min.Y; y < key.Max.Y; y++ {
+						for x := key.Min.X; x < key.Max.X; x++ {
+							canvas.SetRGBA(x, y, keyColor(row, col))
+						}
+					}
+				}
+			}
+
+			for row := 0; row < desc.rows; row++ {
+				for col := 0; col < desc.cols; col++ {
+					v := desc.keyView(canvas, row, col, gap)
+					if v.Bounds() != desc.bounds() {
+						t.Errorf("unexpected bounds for key %d,%d: got:%v want:%v", row, col, v.Bounds(), desc.bounds())
+					}
+					want := keyColor(row, col)
+					vb := v.Bounds()
+					for y := vb.Min.Y; y < vb.Max.Y; y++ {
+						for x := vb.Min.X; x < vb.Max.X; x++ {
+							got := color.RGBAModel.Convert(v.At(x, y))
+							if got != want {
+								t.Fatalf("unexpected pixel for key %d,%d at %d,%d: got:%v want:%v", row, col, x, y, got, want)
+							}
+						}
+					}
+				}
+			}
+		})
+	}
+}
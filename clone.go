@@ -0,0 +1,129 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// Protocol is a Stream Deck report protocol that may be emulated by
+// compatible devices.
+type Protocol int
+
+const (
+	// ProtocolV1 is the protocol of the original Stream
+	// Deck, with BMP key images.
+	ProtocolV1 Protocol = iota + 1
+
+	// ProtocolV2 is the protocol of the Stream Deck MK.2
+	// and XL, with JPEG key images.
+	ProtocolV2
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case ProtocolV1:
+		return "v1"
+	case ProtocolV2:
+		return "v2"
+	default:
+		return fmt.Sprintf("Protocol(%d)", int(p))
+	}
+}
+
+// Clone describes a third-party device that emulates a Stream Deck
+// protocol.
+type Clone struct {
+	// VID and PID are the USB vendor and
+	// product IDs of the device.
+	VID uint16
+	PID PID
+
+	// Protocol is the protocol the device
+	// emulates.
+	Protocol Protocol
+
+	// Rows and Cols are the key layout of
+	// the device.
+	Rows, Cols int
+
+	// KeySize is the size of a key image
+	// in pixels.
+	KeySize image.Point
+
+	// Rotate180 is whether key images are
+	// rotated by 180° before they are sent
+	// to the device, as they are for the
+	// Stream Deck Original and MK.2.
+	Rotate180 bool
+}
+
+// device returns a device description for the clone, based on the device
+// table entry of the model that first used its protocol.
+func (c Clone) device() (device, error) {
+	if c.VID == 0 {
+		return device{}, errors.New("missing clone vendor ID")
+	}
+	if c.PID == AnyPID {
+		return device{}, errors.New("missing clone product ID")
+	}
+	if c.Rows <= 0 || c.Cols <= 0 {
+		return device{}, fmt.Errorf("invalid clone layout: %dx%d", c.Rows, c.Cols)
+	}
+	if c.KeySize.X <= 0 || c.KeySize.Y <= 0 {
+		return device{}, fmt.Errorf("invalid clone key size: %v", c.KeySize)
+	}
+	var desc device
+	switch c.Protocol {
+	case ProtocolV1:
+		desc = devices[StreamDeckOriginal]
+	case ProtocolV2:
+		desc = devices[StreamDeckMK2]
+	default:
+		return device{}, fmt.Errorf("invalid clone protocol: %v", c.Protocol)
+	}
+	desc.PID = c.PID
+	desc.canonical = 0
+	desc.vid = c.VID
+	if desc.vid == vidElGato {
+		desc.vid = 0
+	}
+	desc.rows, desc.cols = c.Rows, c.Cols
+	desc.keySize = c.KeySize
+	desc.geometry = nil
+	desc.quirks = nil
+	desc.transform = identity
+	if c.Rotate180 {
+		desc.transform = rotate180
+	}
+	return desc, nil
+}
+
+// identity returns img unaltered.
+func identity(img image.Image) image.Image {
+	return img
+}
+
+// NewCloneDeck returns a Deck for a third-party device described by c
+// that emulates a Stream Deck protocol. If serial is empty the first
+// device matching c's vendor and product IDs is used. Options are applied
+// before the device is initialised.
+//
+// Clones are used at the caller's risk. ardilla does not verify that the
+// device implements the protocol, and writing Stream Deck reports to a
+// device that does not may leave it in an unusable state.
+func NewCloneDeck(c Clone, serial string, opts ...Option) (*Deck, error) {
+	desc, err := c.device()
+	if err != nil {
+		return nil, err
+	}
+	dev, err := desc.open(serial)
+	if err != nil {
+		return nil, err
+	}
+	return newDeck(desc, serial, dev, opts)
+}
@@ -0,0 +1,104 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+var cloneTests = []struct {
+	name    string
+	clone   Clone
+	wantErr error
+}{
+	{
+		name:  "v1",
+		clone: Clone{VID: 0x1234, PID: 0x5678, Protocol: ProtocolV1, Rows: 2, Cols: 4, KeySize: image.Point{64, 64}},
+	},
+	{
+		name:  "v2",
+		clone: Clone{VID: 0x1234, PID: 0x5678, Protocol: ProtocolV2, Rows: 3, Cols: 5, KeySize: image.Point{72, 72}, Rotate180: true},
+	},
+	{
+		name:    "no_vid",
+		clone:   Clone{PID: 0x5678, Protocol: ProtocolV2, Rows: 3, Cols: 5, KeySize: image.Point{72, 72}},
+		wantErr: errors.New("missing clone vendor ID"),
+	},
+	{
+		name:    "no_pid",
+		clone:   Clone{VID: 0x1234, Protocol: ProtocolV2, Rows: 3, Cols: 5, KeySize: image.Point{72, 72}},
+		wantErr: errors.New("missing clone product ID"),
+	},
+	{
+		name:    "layout",
+		clone:   Clone{VID: 0x1234, PID: 0x5678, Protocol: ProtocolV2, Rows: 0, Cols: 5, KeySize: image.Point{72, 72}},
+		wantErr: errors.New("invalid clone layout: 0x5"),
+	},
+	{
+		name:    "key_size",
+		clone:   Clone{VID: 0x1234, PID: 0x5678, Protocol: ProtocolV2, Rows: 3, Cols: 5},
+		wantErr: errors.New("invalid clone key size: (0,0)"),
+	},
+	{
+		name:    "protocol",
+		clone:   Clone{VID: 0x1234, PID: 0x5678, Rows: 3, Cols: 5, KeySize: image.Point{72, 72}},
+		wantErr: errors.New("invalid clone protocol: Protocol(0)"),
+	},
+}
+
+func TestClone(t *testing.T) {
+	for _, test := range cloneTests {
+		t.Run(test.name, func(t *testing.T) {
+			desc, err := test.clone.device()
+			if !sameError(err, test.wantErr) {
+				t.Fatalf("unexpected error: got:%v want:%v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if desc.vendor() != test.clone.VID || desc.PID != test.clone.PID {
+				t.Errorf("unexpected IDs: got:%04x:%04x want:%04x:%04x", desc.vendor(), uint16(desc.PID), test.clone.VID, uint16(test.clone.PID))
+			}
+			if desc.rows != test.clone.Rows || desc.cols != test.clone.Cols {
+				t.Errorf("unexpected layout: got:%dx%d want:%dx%d", desc.rows, desc.cols, test.clone.Rows, test.clone.Cols)
+			}
+			wantFormat := map[Protocol]string{ProtocolV1: "bmp", ProtocolV2: "jpeg"}[test.clone.Protocol]
+			if desc.format != wantFormat {
+				t.Errorf("unexpected format: got:%q want:%q", desc.format, wantFormat)
+			}
+
+			var buf bytes.Buffer
+			d := &Deck{desc: &desc, buf: make([]byte, desc.bufLen()), brightness: -1}
+			d.setDev(&virtDev{Writer: &buf})
+			b, err := d.Bounds()
+			if err != nil {
+				t.Fatalf("unexpected error for Bounds: %v", err)
+			}
+			if b.Size() != test.clone.KeySize {
+				t.Errorf("unexpected key bounds: got:%v want:%v", b.Size(), test.clone.KeySize)
+			}
+			err = d.SetImage(0, 0, uniformRGBA(b, color.RGBA{R: 0xff, A: 0xff}))
+			if err != nil {
+				t.Fatalf("unexpected error for SetImage: %v", err)
+			}
+			proto := devices[map[Protocol]PID{ProtocolV1: StreamDeckOriginal, ProtocolV2: StreamDeckMK2}[test.clone.Protocol]]
+			if !bytes.HasPrefix(buf.Bytes(), proto.imageHeader[:2]) {
+				t.Errorf("unexpected image report prefix: got:%#v want:%#v", buf.Bytes()[:2], proto.imageHeader[:2])
+			}
+
+			raw, err := Render(StreamDeckMK2, uniformRGBA(b, color.RGBA{G: 0xff, A: 0xff}))
+			if err != nil {
+				t.Fatalf("unexpected error for Render: %v", err)
+			}
+			if d.reusable(raw) {
+				t.Error("raw image for El Gato device reusable by clone")
+			}
+		})
+	}
+}
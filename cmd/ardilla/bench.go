@@ -0,0 +1,204 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"time"
+
+	"github.com/kortschak/ardilla"
+)
+
+var benchCommand = &command{
+	name:  "bench",
+	short: "Measure refresh rate, key latency and encode time as JSON lines.",
+	run:   bench,
+}
+
+func bench(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	n := fs.Int("n", 20, "number of iterations for each measurement")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 0 {
+		return usageError(fs, "unexpected arguments: %q", fs.Args())
+	}
+	if *n < 1 {
+		return usageError(fs, "invalid number of iterations: %d", *n)
+	}
+
+	found, err := dev.connected()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to find devices: %v\n", err)
+		return 1
+	}
+	if len(found) == 0 {
+		fmt.Fprintln(os.Stderr, "no Stream Deck connected")
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	status := 0
+	for _, c := range found {
+		r, err := benchDevice(c, *n, dev.options()...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to benchmark %s: %v\n", c, err)
+			status = 1
+			continue
+		}
+		enc.Encode(r)
+	}
+	return status
+}
+
+// benchResult is the result of benchmarking a device. Durations are
+// in nanoseconds.
+type benchResult struct {
+	Device     string `json:"device"`
+	Serial     string `json:"serial"`
+	Iterations int    `json:"iterations"`
+
+	// Encode is the time taken to scale and encode a key image.
+	Encode durations `json:"encode_ns"`
+	// KeyLatency is the time taken to send a pre-encoded image to a
+	// single key.
+	KeyLatency durations `json:"key_latency_ns"`
+	// Refresh is the time taken to send pre-encoded images to all keys
+	// of the device.
+	Refresh durations `json:"refresh_ns"`
+	// RefreshRate is the number of full panel refreshes per second
+	// based on the mean refresh time.
+	RefreshRate float64 `json:"refresh_hz"`
+	// Throughput is the rate of encoded image data written to the
+	// device measured by the deck over the most recent writes, and
+	// FrameBudget is the estimated full panel refresh rate it allows.
+	Throughput  float64 `json:"throughput_bytes_per_sec"`
+	FrameBudget float64 `json:"frame_budget_hz"`
+}
+
+// durations is a summary of a set of timings.
+type durations struct {
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	Max  time.Duration `json:"max"`
+}
+
+// summarize returns a summary of the timings in t.
+func summarize(t []time.Duration) durations {
+	if len(t) == 0 {
+		return durations{}
+	}
+	s := durations{Min: t[0], Max: t[0]}
+	var sum time.Duration
+	for _, d := range t {
+		if d < s.Min {
+			s.Min = d
+		}
+		if d > s.Max {
+			s.Max = d
+		}
+		sum += d
+	}
+	s.Mean = sum / time.Duration(len(t))
+	return s
+}
+
+// benchDevice benchmarks the device c opened with opts with n iterations
+// of each measurement. The key images are left showing the benchmark
+// pattern.
+func benchDevice(c connected, n int, opts ...ardilla.Option) (*benchResult, error) {
+	d, err := ardilla.NewDeck(c.pid, c.serial, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	b, err := d.Bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode images at twice the key size so that scaling is included
+	// in the encode time as it would be for typical user images.
+	src := make([]image.Image, n)
+	for i := range src {
+		src[i] = benchPattern(image.Rect(0, 0, 2*b.Dx(), 2*b.Dy()), i)
+	}
+	raw := make([]*ardilla.RawImage, n)
+	encode := make([]time.Duration, n)
+	for i, img := range src {
+		start := time.Now()
+		raw[i], err = d.RawImage(img)
+		encode[i] = time.Since(start)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	latency := make([]time.Duration, n)
+	for i, img := range raw {
+		start := time.Now()
+		err = d.SetImage(0, 0, img)
+		latency[i] = time.Since(start)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, cols := d.Layout()
+	refresh := make([]time.Duration, n)
+	for i := range refresh {
+		start := time.Now()
+		for r := 0; r < rows; r++ {
+			for c := 0; c < cols; c++ {
+				err = d.SetImage(r, c, raw[(i+r*cols+c)%n])
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		refresh[i] = time.Since(start)
+	}
+
+	serial := c.serial
+	if serial == "" {
+		serial, _ = d.Serial()
+	}
+	res := &benchResult{
+		Device:     c.pid.String(),
+		Serial:     serial,
+		Iterations: n,
+		Encode:     summarize(encode),
+		KeyLatency: summarize(latency),
+		Refresh:    summarize(refresh),
+	}
+	if res.Refresh.Mean > 0 {
+		res.RefreshRate = float64(time.Second) / float64(res.Refresh.Mean)
+	}
+	res.Throughput = d.Throughput().BytesPerSecond()
+	res.FrameBudget, _ = d.FrameBudget(d.Len())
+	return res, nil
+}
+
+// benchPattern returns a colour gradient image with the given bounds,
+// shifted according to phase so that successive images differ.
+func benchPattern(bounds image.Rectangle, phase int) image.Image {
+	img := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			img.SetRGBA(x, y, color.RGBA{
+				R: uint8(x*255/bounds.Dx() + phase*16),
+				G: uint8(y*255/bounds.Dy() + phase*8),
+				B: uint8((x + y + phase) * 4),
+				A: 0xff,
+			})
+		}
+	}
+	return img
+}
@@ -0,0 +1,46 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+var brightnessCommand = &command{
+	name:  "brightness",
+	args:  "<percent>",
+	short: "Set the screen brightness of a device.",
+	run:   brightness,
+}
+
+func brightness(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		return usageError(fs, "missing brightness")
+	}
+	percent, err := strconv.Atoi(fs.Arg(0))
+	if err != nil || percent < 0 || 100 < percent {
+		return usageError(fs, "invalid brightness: %s", fs.Arg(0))
+	}
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+
+	err = d.SetBrightness(percent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set brightness: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"os/signal"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+var canvasCommand = &command{
+	name:  "canvas",
+	args:  "<image>",
+	short: "Render an image (bmp, gif, jpeg, png or tiff) across all keys.",
+	run:   canvas,
+}
+
+func canvas(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	gap := fs.Int("gap", 0, "pixels hidden by the bezel between adjacent keys (negative to use device geometry)")
+	animate := fs.Bool("animate", false, "animate GIF input")
+	watchFile := fs.Bool("watch", false, "render the image again when the file changes")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		return usageError(fs, "missing image")
+	}
+	path := fs.Arg(0)
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+	if *gap < 0 {
+		*gap, err = d.CanvasGap()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to get device geometry: %v\n", err)
+			return 1
+		}
+	}
+
+	render := func(ctx context.Context) error {
+		img, err := decodeImage(path, nil)
+		if err != nil {
+			return err
+		}
+		switch img := img.(type) {
+		case *animation.GIF:
+			if !*animate {
+				return d.SetCanvas(img.Image[0], *gap)
+			}
+			dst := image.NewRGBA(img.Bounds())
+			return img.Play(ctx, dst, func(img image.Image) error {
+				return d.SetCanvas(img, *gap)
+			})
+		default:
+			return d.SetCanvas(img, *gap)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if *watchFile {
+		watch(ctx, func() []string { return []string{path} }, render)
+		return 0
+	}
+	err = render(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set canvas: %v\n", err)
+		return 1
+	}
+	return 0
+}
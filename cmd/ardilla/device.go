@@ -0,0 +1,132 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kortschak/ardilla"
+)
+
+// pids is the set of devices that can be selected with the -device flag.
+var pids = func() []ardilla.PID {
+	var pids []ardilla.PID
+	for _, d := range ardilla.Devices() {
+		pids = append(pids, d.PID)
+	}
+	return pids
+}()
+
+// deviceFlags are the device selection flags shared by all commands.
+type deviceFlags struct {
+	device       string
+	serial       string
+	pacing       time.Duration
+	highContrast bool
+	dither       ardilla.Dither
+	sharpen      float64
+	throttle     bool
+}
+
+// register registers the device selection flags with fs.
+func (f *deviceFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.device, "device", "", fmt.Sprintf("device name from %s", pids))
+	fs.StringVar(&f.serial, "serial", "", "device serial number")
+	fs.DurationVar(&f.pacing, "pacing", 0, "minimum delay between image writes")
+	fs.BoolVar(&f.highContrast, "high-contrast", false, "render images in high contrast")
+	fs.Float64Var(&f.sharpen, "sharpen", 0, "amount of sharpening applied to scaled images")
+	fs.BoolVar(&f.throttle, "throttle", false, "throttle animations to the measured frame budget")
+	fs.Func("dither", "dither BMP key images: none, floyd-steinberg or ordered (default none)", func(s string) error {
+		for _, m := range []ardilla.Dither{ardilla.NoDither, ardilla.FloydSteinberg, ardilla.OrderedDither} {
+			if s == m.String() {
+				f.dither = m
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown dither method: %q", s)
+	})
+}
+
+// options returns the deck options selected by the flags followed by
+// opts.
+func (f *deviceFlags) options(opts ...ardilla.Option) []ardilla.Option {
+	return append([]ardilla.Option{
+		ardilla.WithPacing(f.pacing),
+		ardilla.WithHighContrast(f.highContrast),
+		ardilla.WithDither(f.dither),
+		ardilla.WithSharpen(f.sharpen),
+		ardilla.WithThrottle(f.throttle),
+	}, opts...)
+}
+
+// open opens the device selected by the flags. If no device type is
+// specified, the connected devices are searched for a deck matching the
+// serial number, or for the only connected deck if no serial number is
+// given. The options in opts are applied after the options selected by
+// the flags.
+func (f *deviceFlags) open(opts ...ardilla.Option) (*ardilla.Deck, error) {
+	if f.device != "" {
+		for _, pid := range pids {
+			if f.device == pid.String() {
+				return ardilla.NewDeck(pid, f.serial, f.options(opts...)...)
+			}
+		}
+		return nil, fmt.Errorf("%q is not a known device", f.device)
+	}
+
+	found, err := f.connected()
+	if err != nil {
+		return nil, err
+	}
+	switch len(found) {
+	case 0:
+		if f.serial != "" {
+			return nil, fmt.Errorf("no Stream Deck with serial %q connected", f.serial)
+		}
+		return nil, errors.New("no Stream Deck connected")
+	case 1:
+		return ardilla.NewDeck(found[0].pid, found[0].serial, f.options(opts...)...)
+	default:
+		names := make([]string, len(found))
+		for i, c := range found {
+			names[i] = c.String()
+		}
+		return nil, fmt.Errorf("multiple Stream Decks connected, select one with -device and -serial: %s", strings.Join(names, ", "))
+	}
+}
+
+// connected returns the connected devices matching the flags.
+func (f *deviceFlags) connected() ([]connected, error) {
+	var found []connected
+	for _, pid := range pids {
+		if f.device != "" && f.device != pid.String() {
+			continue
+		}
+		serials, err := ardilla.Serials(pid)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range serials {
+			if f.serial == "" || f.serial == s {
+				found = append(found, connected{pid: pid, serial: s})
+			}
+		}
+	}
+	return found, nil
+}
+
+// connected is a connected device.
+type connected struct {
+	pid    ardilla.PID
+	serial string
+}
+
+func (c connected) String() string {
+	return fmt.Sprintf("%s (%s)", c.pid, c.serial)
+}
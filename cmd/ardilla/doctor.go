@@ -0,0 +1,95 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/kortschak/ardilla"
+)
+
+var doctorCommand = &command{
+	name:  "doctor",
+	short: "Diagnose device access problems.",
+	run:   doctor,
+}
+
+func doctor(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 0 {
+		return usageError(fs, "unexpected arguments: %q", fs.Args())
+	}
+
+	w := os.Stdout
+	fmt.Fprintf(w, "platform: %s/%s %s\n", runtime.GOOS, runtime.GOARCH, runtime.Version())
+
+	found, problems := listInterfaces(w, dev)
+	if len(found) == 0 {
+		fmt.Fprintln(w, "\tno supported devices found")
+		problems++
+	}
+
+	problems += checkAccess(w)
+
+	for _, c := range found {
+		problems += checkDevice(w, c)
+	}
+
+	if problems != 0 {
+		fmt.Fprintf(w, "\n%d problem(s) found\n", problems)
+		return 1
+	}
+	fmt.Fprintln(w, "\nno problems found")
+	return 0
+}
+
+// checkDevice opens the device, reads its details and reports the number
+// of problems found. Opening a visual device sends a key stream reset,
+// which is a non-destructive write.
+func checkDevice(w io.Writer, c connected) (problems int) {
+	fmt.Fprintf(w, "\n%s:\n", c)
+	d, err := ardilla.NewDeck(c.pid, c.serial)
+	if err != nil {
+		fmt.Fprintf(w, "\topen and write test: failed: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+	if _, err := d.Bounds(); err == nil {
+		fmt.Fprintln(w, "\topen and write test: ok")
+	} else {
+		fmt.Fprintln(w, "\topen: ok (no write test for non-visual device)")
+	}
+	firmware, err := d.Firmware()
+	if err != nil {
+		fmt.Fprintf(w, "\tfirmware: failed: %v\n", err)
+		problems++
+	} else {
+		fmt.Fprintf(w, "\tfirmware: %s\n", firmware)
+	}
+	serial, err := d.Serial()
+	if err != nil {
+		fmt.Fprintf(w, "\tserial: failed: %v\n", err)
+		problems++
+	} else {
+		fmt.Fprintf(w, "\tserial: %s\n", serial)
+	}
+	return problems
+}
+
+// isKnown returns whether pid is a device supported by ardilla.
+func isKnown(pid ardilla.PID) bool {
+	for _, p := range pids {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build cgo && !hidraw && !nohid
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sstallion/go-hid"
+
+	"github.com/kortschak/ardilla"
+)
+
+// vidElGato is the El Gato USB vendor ID.
+const vidElGato = 0x0fd9
+
+// listInterfaces writes the El Gato HID interfaces found by hidapi to w and
+// returns the supported devices that match the device flags, and the number
+// of problems found.
+func listInterfaces(w io.Writer, dev *deviceFlags) (found []connected, problems int) {
+	fmt.Fprintf(w, "hidapi: %s\n", hid.GetVersionStr())
+	fmt.Fprintln(w, "\nEl Gato HID interfaces:")
+	err := hid.Enumerate(vidElGato, hid.ProductIDAny, func(info *hid.DeviceInfo) error {
+		pid := ardilla.PID(info.ProductID)
+		name := pid.String()
+		known := isKnown(pid)
+		if !known {
+			name += " (unsupported)"
+		}
+		fmt.Fprintf(w, "\t%s serial:%q interface:%d usage:%#04x/%#04x path:%s\n",
+			name, info.SerialNbr, info.InterfaceNbr, info.UsagePage, info.Usage, info.Path)
+		if known && (dev.device == "" || dev.device == pid.String()) && (dev.serial == "" || dev.serial == info.SerialNbr) {
+			found = append(found, connected{pid: pid, serial: info.SerialNbr})
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(w, "\tfailed to enumerate devices: %v\n", err)
+		problems++
+	}
+	return found, problems
+}
@@ -0,0 +1,28 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !cgo || hidraw || nohid
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// listInterfaces writes the supported devices that match the device flags
+// to w and returns them, and the number of problems found.
+func listInterfaces(w io.Writer, dev *deviceFlags) (found []connected, problems int) {
+	fmt.Fprintln(w, "hidapi: not used")
+	fmt.Fprintln(w, "\nEl Gato devices:")
+	found, err := dev.connected()
+	if err != nil {
+		fmt.Fprintf(w, "\tfailed to enumerate devices: %v\n", err)
+		return nil, 1
+	}
+	for _, c := range found {
+		fmt.Fprintf(w, "\t%s\n", c)
+	}
+	return found, 0
+}
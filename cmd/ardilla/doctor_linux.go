@@ -0,0 +1,78 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// udevRule is a udev rule granting the logged in user access to El Gato
+// hidraw devices.
+const udevRule = `SUBSYSTEM=="hidraw", ATTRS{idVendor}=="0fd9", TAG+="uaccess"`
+
+// checkAccess checks that the El Gato hidraw device nodes can be opened
+// for reading and writing, and reports the number of problems found.
+func checkAccess(w io.Writer) (problems int) {
+	fmt.Fprintln(w, "\nhidraw access:")
+	nodes, err := filepath.Glob("/sys/class/hidraw/hidraw*")
+	if err != nil {
+		fmt.Fprintf(w, "\tfailed to list hidraw devices: %v\n", err)
+		return 1
+	}
+	var seen bool
+	for _, n := range nodes {
+		if !isElGato(filepath.Join(n, "device", "uevent")) {
+			continue
+		}
+		seen = true
+		path := filepath.Join("/dev", filepath.Base(n))
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			fmt.Fprintf(w, "\t%s: %v\n", path, err)
+			problems++
+			if errors.Is(err, fs.ErrPermission) {
+				fmt.Fprintf(w, "\t\tadd the following rule to /etc/udev/rules.d/70-streamdeck.rules:\n\t\t\t%s\n", udevRule)
+				fmt.Fprintln(w, "\t\tthen run 'sudo udevadm control --reload-rules && sudo udevadm trigger'")
+				fmt.Fprintln(w, "\t\tand reconnect the device")
+			}
+			continue
+		}
+		f.Close()
+		fmt.Fprintf(w, "\t%s: ok\n", path)
+	}
+	if !seen {
+		fmt.Fprintln(w, "\tno El Gato hidraw devices found")
+	}
+	return problems
+}
+
+// isElGato returns whether the uevent file at path describes an El Gato
+// HID device.
+func isElGato(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// HID_ID=bus:vendor:product, for example
+		// HID_ID=0003:00000FD9:00000060.
+		id, ok := strings.CutPrefix(sc.Text(), "HID_ID=")
+		if !ok {
+			continue
+		}
+		parts := strings.Split(id, ":")
+		return len(parts) == 3 && strings.EqualFold(parts[1], "00000fd9")
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+import "io"
+
+// checkAccess is a no-op; device node permission checks are only
+// available on linux.
+func checkAccess(w io.Writer) (problems int) { return 0 }
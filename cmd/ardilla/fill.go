@@ -0,0 +1,119 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/layout"
+)
+
+var fillCommand = &command{
+	name:  "fill",
+	short: "Fill keys with a solid colour.",
+	run:   fill,
+}
+
+func fill(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	colour := fs.String("color", "", "fill colour in #rgb or #rrggbb notation or by name")
+	var targets keys
+	fs.Var(&targets, "key", "row,col of target key (may be repeated, default all keys)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 0 {
+		return usageError(fs, "unexpected arguments: %q", fs.Args())
+	}
+	if *colour == "" {
+		return usageError(fs, "missing colour")
+	}
+	c, err := layout.ParseColor(*colour)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+
+	err = fillKeys(d, targets, c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to fill keys: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+var clearCommand = &command{
+	name:  "clear",
+	short: "Clear keys to black.",
+	run:   clearKeys,
+}
+
+func clearKeys(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	all := fs.Bool("all", false, "clear all keys")
+	var targets keys
+	fs.Var(&targets, "key", "row,col of target key (may be repeated)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 0 {
+		return usageError(fs, "unexpected arguments: %q", fs.Args())
+	}
+	if *all == (len(targets) != 0) {
+		return usageError(fs, "must specify either -all or at least one -key")
+	}
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+
+	err = fillKeys(d, targets, color.Black)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to clear keys: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// fillKeys fills the target keys of d with c. If targets is empty, all
+// keys are filled.
+func fillKeys(d *ardilla.Deck, targets keys, c color.Color) error {
+	b, err := d.Bounds()
+	if err != nil {
+		return err
+	}
+	// Pre-compute the raw image since it is used for every key.
+	img, err := d.RawImage(layout.Fill(b, c))
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		rows, cols := d.Layout()
+		for r := 0; r < rows; r++ {
+			for c := 0; c < cols; c++ {
+				targets = append(targets, key{row: r, col: c})
+			}
+		}
+	}
+	for _, k := range targets {
+		err = d.SetImage(k.row, k.col, img)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", k, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+var identifyCommand = &command{
+	name:  "identify",
+	short: "Flash a device's serial number across its keys.",
+	run:   identify,
+}
+
+func identify(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 0 {
+		return usageError(fs, "unexpected arguments: %q", fs.Args())
+	}
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	err = d.Identify(ctx)
+	if err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "failed to identify device: %v\n", err)
+		return 1
+	}
+	return 0
+}
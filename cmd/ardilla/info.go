@@ -0,0 +1,69 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kortschak/ardilla"
+)
+
+var infoCommand = &command{
+	name:  "info",
+	short: "Print device details.",
+	run:   info,
+}
+
+func info(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 0 {
+		return usageError(fs, "unexpected arguments: %q", fs.Args())
+	}
+
+	// Leave the key stream alone so that inspecting
+	// a deck does not disturb its current display.
+	d, err := dev.open(ardilla.WithResetKeyStream(false))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		if dev.device != "" && dev.serial != "" {
+			for _, pid := range pids {
+				if dev.device != pid.String() {
+					continue
+				}
+				serials, err := ardilla.Serials(pid)
+				if err == nil {
+					fmt.Printf("available: %s\n", strings.Join(serials, ", "))
+				}
+			}
+		}
+		return 1
+	}
+	defer d.Close()
+
+	firmware, err := d.Firmware()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get firmware version: %v\n", err)
+		return 1
+	}
+
+	serial, err := d.Serial()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get serial number: %v\n", err)
+		return 1
+	}
+
+	rows, cols := d.Layout()
+	fmt.Printf("device:%s fw:%s serial:%s layout:%dx%d", d.PID(), firmware, serial, rows, cols)
+	if b, err := d.Bounds(); err == nil {
+		fmt.Printf(" key:%dx%d", b.Dx(), b.Dy())
+	}
+	fmt.Println()
+	return 0
+}
@@ -0,0 +1,58 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// key is a key position.
+type key struct {
+	row, col int
+}
+
+func (k key) String() string {
+	return fmt.Sprintf("%d,%d", k.row, k.col)
+}
+
+// keys is a flag.Value holding a list of key positions given as row,col
+// pairs. The flag may be repeated.
+type keys []key
+
+func (k *keys) String() string {
+	s := make([]string, len(*k))
+	for i, p := range *k {
+		s[i] = p.String()
+	}
+	return strings.Join(s, " ")
+}
+
+func (k *keys) Set(s string) error {
+	p, err := parseKey(s)
+	if err != nil {
+		return err
+	}
+	*k = append(*k, p)
+	return nil
+}
+
+// parseKey parses a row,col key position.
+func parseKey(s string) (key, error) {
+	r, c, ok := strings.Cut(s, ",")
+	if !ok {
+		return key{}, fmt.Errorf("invalid key position %q: want row,col", s)
+	}
+	row, err := strconv.Atoi(strings.TrimSpace(r))
+	if err != nil {
+		return key{}, fmt.Errorf("invalid key row: %q", r)
+	}
+	col, err := strconv.Atoi(strings.TrimSpace(c))
+	if err != nil {
+		return key{}, fmt.Errorf("invalid key column: %q", c)
+	}
+	return key{row: row, col: col}, nil
+}
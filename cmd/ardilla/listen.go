@@ -0,0 +1,101 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kortschak/ardilla"
+)
+
+var listenCommand = &command{
+	name:  "listen",
+	short: "Print key press and release events as JSON lines.",
+	run:   listen,
+}
+
+// keyEvent is a key press or release event printed by the listen
+// command.
+type keyEvent struct {
+	Time    time.Time `json:"time"`
+	Key     int       `json:"key"`
+	Row     int       `json:"row"`
+	Col     int       `json:"col"`
+	Pressed bool      `json:"pressed"`
+}
+
+func listen(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 0 {
+		return usageError(fs, "unexpected arguments: %q", fs.Args())
+	}
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	type poll struct {
+		changes []ardilla.KeyChange
+		time    time.Time
+		err     error
+	}
+	c := make(chan poll)
+	go func() {
+		var states []bool
+		for {
+			var p poll
+			states, p.changes, p.err = d.Poll(states)
+			p.time = time.Now()
+			select {
+			case c <- p:
+			case <-ctx.Done():
+				return
+			}
+			if p.err != nil {
+				return
+			}
+		}
+	}()
+
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		select {
+		case <-ctx.Done():
+			return 0
+		case p := <-c:
+			if p.err != nil {
+				fmt.Fprintf(os.Stderr, "failed to get states: %v\n", p.err)
+				return 1
+			}
+			for _, k := range p.changes {
+				err = enc.Encode(keyEvent{
+					Time:    p.time,
+					Key:     k.Key,
+					Row:     k.Row,
+					Col:     k.Col,
+					Pressed: k.Pressed,
+				})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "failed to write event: %v\n", err)
+					return 1
+				}
+			}
+		}
+	}
+}
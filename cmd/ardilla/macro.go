@@ -0,0 +1,191 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/macro"
+)
+
+var macroCommand = &command{
+	name:  "macro",
+	args:  "record [<macro.json>] | replay <macro.json>",
+	short: "Record key event macros on a device or replay them as JSON lines.",
+	run:   macroCmd,
+}
+
+func macroCmd(cmd *command, args []string) int {
+	if len(args) == 0 {
+		fs, _ := cmd.flagSet()
+		return usageError(fs, "missing macro operation")
+	}
+	switch args[0] {
+	case "record":
+		return macroRecord(cmd, args[1:])
+	case "replay":
+		return macroReplay(cmd, args[1:])
+	default:
+		fs, _ := cmd.flagSet()
+		if code, ok := parse(fs, args); !ok {
+			return code
+		}
+		return usageError(fs, "unknown macro operation: %q", args[0])
+	}
+}
+
+// macroRecord records key events from the selected device until the
+// stop key is pressed or the program is interrupted, and writes the
+// macro to the file given as the argument or to standard output.
+func macroRecord(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	var stop keys
+	fs.Var(&stop, "stop", "row,col of the key that ends the recording (default interrupt only)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 1 {
+		return usageError(fs, "unexpected arguments: %q", fs.Args()[1:])
+	}
+	if len(stop) > 1 {
+		return usageError(fs, "more than one stop key")
+	}
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+	if len(stop) != 0 {
+		rows, cols := d.Layout()
+		if k := stop[0]; k.row < 0 || rows <= k.row || k.col < 0 || cols <= k.col {
+			return usageError(fs, "stop key out of bounds: %s", k)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	type poll struct {
+		changes []ardilla.KeyChange
+		time    time.Time
+		err     error
+	}
+	c := make(chan poll)
+	go func() {
+		var states []bool
+		for {
+			var p poll
+			states, p.changes, p.err = d.Poll(states)
+			p.time = time.Now()
+			select {
+			case c <- p:
+			case <-ctx.Done():
+				return
+			}
+			if p.err != nil {
+				return
+			}
+		}
+	}()
+
+	fmt.Fprintln(os.Stderr, "recording")
+	rec := macro.NewRecorder(time.Time{})
+record:
+	for {
+		select {
+		case <-ctx.Done():
+			break record
+		case p := <-c:
+			if p.err != nil {
+				fmt.Fprintf(os.Stderr, "failed to get states: %v\n", p.err)
+				return 1
+			}
+			for _, ch := range p.changes {
+				if len(stop) != 0 && ch.Row == stop[0].row && ch.Col == stop[0].col {
+					if ch.Pressed {
+						break record
+					}
+					continue
+				}
+				rec.Record(ardilla.KeyEvent{
+					Time:    p.time,
+					Key:     ch.Key,
+					Row:     ch.Row,
+					Col:     ch.Col,
+					Pressed: ch.Pressed,
+				})
+			}
+		}
+	}
+	cancel()
+
+	data, err := json.MarshalIndent(rec.Macro(), "", "\t")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to marshal macro: %v\n", err)
+		return 1
+	}
+	data = append(data, '\n')
+	if fs.NArg() == 0 {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(fs.Arg(0), data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write macro: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// macroReplay replays a macro file, printing its events in the format
+// of the listen command with the recorded timing.
+func macroReplay(cmd *command, args []string) int {
+	fs, _ := cmd.flagSet()
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		return usageError(fs, "missing macro file")
+	}
+	m, err := macro.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load macro: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	enc := json.NewEncoder(os.Stdout)
+	var writeErr error
+	err = m.Replay(ctx, func(ev ardilla.KeyEvent) bool {
+		writeErr = enc.Encode(keyEvent{
+			Time:    ev.Time,
+			Key:     ev.Key,
+			Row:     ev.Row,
+			Col:     ev.Col,
+			Pressed: ev.Pressed,
+		})
+		return writeErr == nil
+	})
+	switch err {
+	case nil, context.Canceled:
+		return 0
+	case macro.ErrStopped:
+		fmt.Fprintf(os.Stderr, "failed to write event: %v\n", writeErr)
+		return 1
+	default:
+		fmt.Fprintf(os.Stderr, "failed to replay macro: %v\n", err)
+		return 1
+	}
+}
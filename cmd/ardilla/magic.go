@@ -0,0 +1,38 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"io"
+)
+
+// hasMagic returns whether r starts with the provided magic bytes.
+func hasMagic(magic string, r readPeaker) bool {
+	b, err := r.Peek(len(magic))
+	if err != nil || len(b) != len(magic) {
+		return false
+	}
+	for i, c := range b {
+		if magic[i] != c && magic[i] != '?' {
+			return false
+		}
+	}
+	return true
+}
+
+// readPeaker is an io.Reader that can also peek n bytes ahead.
+type readPeaker interface {
+	io.Reader
+	Peek(n int) ([]byte, error)
+}
+
+// asReader converts an io.Reader to a readPeaker.
+func asReaderPeaker(r io.Reader) readPeaker {
+	if r, ok := r.(readPeaker); ok {
+		return r
+	}
+	return bufio.NewReader(r)
+}
@@ -0,0 +1,153 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The ardilla command provides command line control of El Gato Stream Deck
+// devices.
+//
+// Usage:
+//
+//	ardilla <command> [flags] [arguments]
+//
+// Run "ardilla help" for the list of commands and "ardilla help <command>"
+// for the flags and arguments of a command.
+//
+// All commands accept -device and -serial flags to select the device to use.
+// If neither is given and only one Stream Deck is connected, that device is
+// used.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func main() {
+	os.Exit(Main())
+}
+
+// command is an ardilla sub-command.
+type command struct {
+	name  string
+	args  string // args is the positional arguments usage.
+	short string // short is the one line command description.
+
+	// run runs the command with the provided command line
+	// arguments and returns the program exit code.
+	run func(cmd *command, args []string) int
+}
+
+// commands is the set of ardilla commands in the order they are listed
+// in the program usage.
+var commands = []*command{
+	benchCommand,
+	brightnessCommand,
+	canvasCommand,
+	clearCommand,
+	doctorCommand,
+	fillCommand,
+	identifyCommand,
+	infoCommand,
+	listenCommand,
+	macroCommand,
+	profileCommand,
+	resetCommand,
+	scheduleCommand,
+	setImageCommand,
+	textCommand,
+}
+
+func Main() int {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		return 2
+	}
+	name, args := os.Args[1], os.Args[2:]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		if len(args) == 0 {
+			usage(os.Stdout)
+			return 0
+		}
+		cmd := lookup(args[0])
+		if cmd == nil {
+			fmt.Fprintf(os.Stderr, "unknown command: %q\n", args[0])
+			return 2
+		}
+		return cmd.run(cmd, []string{"-help"})
+	}
+	cmd := lookup(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %q\n", name)
+		usage(os.Stderr)
+		return 2
+	}
+	return cmd.run(cmd, args)
+}
+
+// flagSet returns a flag set for the command with the device selection
+// flags registered.
+func (c *command) flagSet() (*flag.FlagSet, *deviceFlags) {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "%s\n\nUsage:\n\n\tardilla %s [flags] %s\n\nFlags:\n\n", c.short, c.name, c.args)
+		fs.PrintDefaults()
+	}
+	var dev deviceFlags
+	dev.register(fs)
+	return fs, &dev
+}
+
+// parse parses args with fs, returning the exit code to use and false if
+// the command should not continue.
+func parse(fs *flag.FlagSet, args []string) (code int, ok bool) {
+	err := fs.Parse(args)
+	switch err {
+	case nil:
+		return 0, true
+	case flag.ErrHelp:
+		return 0, false
+	default:
+		return 2, false
+	}
+}
+
+// lookup returns the command with the given name or nil if it does not
+// exist.
+func lookup(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// usage writes the program usage to w.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "ardilla controls El Gato Stream Deck devices.\n\nUsage:\n\n\tardilla <command> [flags] [arguments]\n\nCommands:")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "\t%s\t%s\n", c.name, c.short)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nUse \"ardilla help <command>\" for more information about a command.")
+}
+
+// usageError reports a usage error for the command with the flag set and
+// returns the usage exit code.
+func usageError(fs *flag.FlagSet, format string, args ...any) int {
+	msg := fmt.Sprintf(format, args...)
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	fmt.Fprint(fs.Output(), msg)
+	fs.Usage()
+	return 2
+}
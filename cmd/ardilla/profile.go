@@ -0,0 +1,378 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/kortschak/ardilla"
+
+	"github.com/kortschak/ardilla/layout"
+)
+
+var profileCommand = &command{
+	name:  "profile",
+	args:  "load <layout> | export [<layout.json>] | bundle <layout.json> <layout.bundle> | transcode <layout> <out>",
+	short: "Load a layout onto a device, export a layout template, or bundle or transcode a layout.",
+	run:   profile,
+}
+
+func profile(cmd *command, args []string) int {
+	if len(args) == 0 {
+		fs, _ := cmd.flagSet()
+		return usageError(fs, "missing profile operation")
+	}
+	switch args[0] {
+	case "load":
+		return profileLoad(cmd, args[1:])
+	case "export":
+		return profileExport(cmd, args[1:])
+	case "bundle":
+		return profileBundle(cmd, args[1:])
+	case "transcode":
+		return profileTranscode(cmd, args[1:])
+	default:
+		fs, _ := cmd.flagSet()
+		if code, ok := parse(fs, args); !ok {
+			return code
+		}
+		return usageError(fs, "unknown profile operation: %q", args[0])
+	}
+}
+
+// profileLoad renders a layout file onto the selected device.
+func profileLoad(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	watchFile := fs.Bool("watch", false, "render the layout again when it or its images change")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		return usageError(fs, "missing layout file")
+	}
+	path := fs.Arg(0)
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+
+	render := func(_ context.Context) error {
+		l, err := layout.Load(path)
+		if err != nil {
+			return err
+		}
+		return l.Apply(d)
+	}
+
+	if *watchFile {
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+		watch(ctx, func() []string {
+			paths := []string{path}
+			l, err := layout.Load(path)
+			if err == nil {
+				paths = append(paths, l.Files()...)
+			}
+			return paths
+		}, render)
+		return 0
+	}
+	err = render(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to render layout: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// profileExport writes a layout template for the selected device to the
+// file given as the argument or to standard output. Stream Decks do not
+// report their current key images, so the template lists every key of
+// the device filled with black for editing.
+func profileExport(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	brightness := fs.Int("bright", 100, "brightness to record in the template (0-100)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 1 {
+		return usageError(fs, "unexpected arguments: %q", fs.Args()[1:])
+	}
+	if *brightness < 0 || 100 < *brightness {
+		return usageError(fs, "invalid brightness: %d", *brightness)
+	}
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	rows, cols := d.Layout()
+	_, err = d.Bounds()
+	visual := err == nil
+	d.Close()
+
+	l := layout.Layout{Brightness: brightness}
+	if visual {
+		for r := 0; r < rows; r++ {
+			for c := 0; c < cols; c++ {
+				l.Keys = append(l.Keys, layout.Key{Row: r, Col: c, Color: "black"})
+			}
+		}
+	} else {
+		l.Brightness = nil
+	}
+	b, err := layout.Marshal(&l)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode layout: %v\n", err)
+		return 1
+	}
+	if fs.NArg() == 0 {
+		_, err = os.Stdout.Write(b)
+	} else {
+		err = os.WriteFile(fs.Arg(0), b, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write layout: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// profileBundle writes a layout file and its images to a single layout
+// bundle with key images pre-rendered for the selected device models
+// using the rendering settings given by the device flags.
+func profileBundle(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	compress := fs.String("compress", "gzip", "bundle compression: none or gzip")
+	models := fs.String("models", "", "comma-separated device names to pre-render for (default -device or all devices with displays)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 2 {
+		return usageError(fs, "need layout file and bundle file")
+	}
+
+	var names []string
+	switch {
+	case *models != "":
+		names = strings.Split(*models, ",")
+	case dev.device != "":
+		names = []string{dev.device}
+	}
+	var targets []layout.Target
+	for _, info := range ardilla.Devices() {
+		if names == nil && !info.Visual {
+			continue
+		}
+		if names != nil && !contains(names, info.PID.String()) {
+			continue
+		}
+		targets = append(targets, layout.Target{
+			PID:          info.PID,
+			HighContrast: dev.highContrast,
+			Dither:       dev.dither,
+			Sharpen:      dev.sharpen,
+		})
+	}
+	for _, n := range names {
+		if !containsPID(targets, n) {
+			return usageError(fs, "%q is not a known device", n)
+		}
+	}
+
+	l, err := layout.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load layout: %v\n", err)
+		return 1
+	}
+	f, err := os.Create(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create bundle: %v\n", err)
+		return 1
+	}
+	err = layout.WriteBundle(f, l, *compress, targets...)
+	if err != nil {
+		f.Close()
+		os.Remove(fs.Arg(1))
+		fmt.Fprintf(os.Stderr, "failed to write bundle: %v\n", err)
+		return 1
+	}
+	err = f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write bundle: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// profileTranscode retargets a layout authored for one device model to
+// another. The result is written as a layout file or, with -bundle, as a
+// layout bundle with key images pre-rendered for the destination model
+// using the rendering settings given by the device flags.
+func profileTranscode(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	var from, to ardilla.PID
+	pidFlag := func(dst *ardilla.PID) func(string) error {
+		return func(s string) error {
+			for _, pid := range pids {
+				if s == pid.String() {
+					*dst = pid
+					return nil
+				}
+			}
+			return fmt.Errorf("%q is not a known device", s)
+		}
+	}
+	fs.Func("from", fmt.Sprintf("device name the layout was authored for from %s", pids), pidFlag(&from))
+	fs.Func("to", fmt.Sprintf("device name to retarget the layout to from %s", pids), pidFlag(&to))
+	placement := layout.Anchor
+	fs.Func("placement", "key placement: anchor, center or scale (default anchor)", func(s string) error {
+		for _, p := range []layout.Placement{layout.Anchor, layout.Center, layout.Scale} {
+			if s == p.String() {
+				placement = p
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown placement: %q", s)
+	})
+	bundle := fs.Bool("bundle", false, "write a layout bundle pre-rendered for the destination")
+	compress := fs.String("compress", "gzip", "bundle compression: none or gzip")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 2 {
+		return usageError(fs, "need layout file and output file")
+	}
+	if from == 0 || to == 0 {
+		return usageError(fs, "need -from and -to devices")
+	}
+	in, out := fs.Arg(0), fs.Arg(1)
+
+	l, err := layout.Load(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load layout: %v\n", err)
+		return 1
+	}
+	t, err := layout.Transcode(l, from, to, placement)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to transcode layout: %v\n", err)
+		return 1
+	}
+
+	if *bundle {
+		f, err := os.Create(out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create bundle: %v\n", err)
+			return 1
+		}
+		err = layout.WriteBundle(f, t, *compress, layout.Target{
+			PID:          to,
+			HighContrast: dev.highContrast,
+			Dither:       dev.dither,
+			Sharpen:      dev.sharpen,
+		})
+		if err != nil {
+			f.Close()
+			os.Remove(out)
+			fmt.Fprintf(os.Stderr, "failed to write bundle: %v\n", err)
+			return 1
+		}
+		err = f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write bundle: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if l.Bundled() {
+		fmt.Fprintln(os.Stderr, "bundled layouts can only be transcoded to a bundle")
+		return 1
+	}
+	// Make relative image paths relative to the
+	// output file so that the new layout finds them.
+	rebase := func(path *string) error {
+		if *path == "" || filepath.IsAbs(*path) {
+			return nil
+		}
+		rel, err := relPath(filepath.Join(filepath.Dir(in), *path), filepath.Dir(out))
+		if err != nil {
+			return err
+		}
+		*path = rel
+		return nil
+	}
+	for i := range t.Keys {
+		k := &t.Keys[i]
+		err = rebase(&k.Image)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to resolve image path: %v\n", err)
+			return 1
+		}
+		if k.Button == nil {
+			continue
+		}
+		for j := range k.Button.States {
+			err = rebase(&k.Button.States[j].Image)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to resolve image path: %v\n", err)
+				return 1
+			}
+		}
+	}
+	b, err := layout.Marshal(t)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode layout: %v\n", err)
+		return 1
+	}
+	err = os.WriteFile(out, b, 0o644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write layout: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// relPath returns path relative to dir.
+func relPath(path, dir string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(dir, path)
+}
+
+// contains returns whether s is in list.
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// containsPID returns whether targets includes the named device.
+func containsPID(targets []layout.Target, name string) bool {
+	for _, t := range targets {
+		if t.PID.String() == name {
+			return true
+		}
+	}
+	return false
+}
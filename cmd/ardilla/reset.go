@@ -0,0 +1,40 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var resetCommand = &command{
+	name:  "reset",
+	short: "Reset a device, clearing all key images.",
+	run:   reset,
+}
+
+func reset(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 0 {
+		return usageError(fs, "unexpected arguments: %q", fs.Args())
+	}
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+
+	err = d.Reset()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to reset device: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/kortschak/ardilla/schedule"
+)
+
+var scheduleCommand = &command{
+	name:  "schedule",
+	args:  "<schedule.json>",
+	short: "Show layouts and key content according to a schedule.",
+	run:   runSchedule,
+}
+
+func runSchedule(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		return usageError(fs, "missing schedule file")
+	}
+	s, err := schedule.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load schedule: %v\n", err)
+		return 1
+	}
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	schedule.Run(ctx, d, s, func(err error) {
+		fmt.Fprintf(os.Stderr, "failed to render schedule: %v\n", err)
+	})
+	return 0
+}
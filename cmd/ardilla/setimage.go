@@ -0,0 +1,131 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"os/signal"
+
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+
+	"github.com/kortschak/ardilla/animation"
+	"github.com/kortschak/ardilla/layout"
+)
+
+var setImageCommand = &command{
+	name:  "set-image",
+	args:  "<image>",
+	short: "Render an image (bmp, gif, jpeg, png or tiff) on a key.",
+	run:   setImage,
+}
+
+func setImage(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	cached := fs.Bool("cache", false, "use cached pre-computed images")
+	budget := fs.Int64("budget", 0, "maximum bytes of cached images, zero for no limit")
+	row := fs.Int("row", 0, "row of target button")
+	col := fs.Int("col", 0, "column of target button")
+	background := fs.String("background", "", "letterbox colour in #rgb or #rrggbb notation or by name")
+	padding := fs.Int("padding", 0, "pixels between the image and the key edges")
+	watchFile := fs.Bool("watch", false, "render the image again when the file changes")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		return usageError(fs, "missing image")
+	}
+	if *budget < 0 {
+		return usageError(fs, "invalid budget: %d", *budget)
+	}
+	if *padding < 0 {
+		return usageError(fs, "invalid padding: %d", *padding)
+	}
+	var bg color.Color
+	if *background != "" {
+		c, err := layout.ParseColor(*background)
+		if err != nil {
+			return usageError(fs, "%v", err)
+		}
+		bg = c
+	}
+	path := fs.Arg(0)
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+	d.SetBackground(bg)
+	d.SetPadding(*padding)
+
+	var miss func(image.Image) (image.Image, error)
+	if *cached {
+		miss = func(img image.Image) (image.Image, error) {
+			return d.RawImage(img)
+		}
+	}
+	render := func(ctx context.Context) error {
+		img, err := decodeImage(path, miss)
+		if err != nil {
+			return err
+		}
+		switch img := img.(type) {
+		case *animation.GIF:
+			img.SetCacheBudget(*budget)
+			dst := image.NewRGBA(img.Bounds())
+			return img.Play(ctx, dst, func(img image.Image) error {
+				return d.SetImage(*row, *col, img)
+			})
+		default:
+			return d.SetImage(*row, *col, img)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if *watchFile {
+		watch(ctx, func() []string { return []string{path} }, render)
+		return 0
+	}
+	err = render(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set image: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// decodeImage returns the image held in the file at path. GIF files holding
+// more than one frame are returned as an *animation.GIF, with miss used to
+// fill the frame cache if it is not nil.
+func decodeImage(path string, miss func(image.Image) (image.Image, error)) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var img image.Image
+	// Work around the effective immutability of image.Decode type registration.
+	r := asReaderPeaker(f)
+	if hasMagic("GIF8?a", r) {
+		img, err = animation.DecodeGIF(r, miss)
+	} else {
+		img, _, err = image.Decode(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return img, nil
+}
@@ -0,0 +1,81 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kortschak/ardilla/label"
+	"github.com/kortschak/ardilla/layout"
+)
+
+var textCommand = &command{
+	name:  "text",
+	args:  "<text>",
+	short: "Render a text label on keys.",
+	run:   text,
+}
+
+func text(cmd *command, args []string) int {
+	fs, dev := cmd.flagSet()
+	size := fs.Float64("size", 18, "font size in pixels")
+	fgColor := fs.String("fg", "white", "text colour")
+	bgColor := fs.String("bg", "black", "background colour")
+	var targets keys
+	fs.Var(&targets, "key", "row,col of target key (may be repeated, default 0,0)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "missing text")
+	}
+	if *size <= 0 {
+		return usageError(fs, "invalid font size: %v", *size)
+	}
+	fg, err := layout.ParseColor(*fgColor)
+	if err != nil {
+		return usageError(fs, "invalid foreground: %v", err)
+	}
+	bg, err := layout.ParseColor(*bgColor)
+	if err != nil {
+		return usageError(fs, "invalid background: %v", err)
+	}
+	if len(targets) == 0 {
+		targets = keys{{row: 0, col: 0}}
+	}
+
+	d, err := dev.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+
+	b, err := d.Bounds()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to render text: %v\n", err)
+		return 1
+	}
+	img, err := label.Render(b, strings.Join(fs.Args(), " "), *size, fg, bg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to render text: %v\n", err)
+		return 1
+	}
+	raw, err := d.RawImage(img)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to render text: %v\n", err)
+		return 1
+	}
+	for _, k := range targets {
+		err = d.SetImage(k.row, k.col, raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to set image for key %s: %v\n", k, err)
+			return 1
+		}
+	}
+	return 0
+}
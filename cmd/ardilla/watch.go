@@ -0,0 +1,85 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+)
+
+// pollInterval is the interval between checks for changes to watched files.
+const pollInterval = 250 * time.Millisecond
+
+// watch calls render and calls it again each time any of the files
+// returned by paths changes, until ctx is cancelled. The context passed
+// to render is cancelled when a change is detected so that long-running
+// renders such as animations can be restarted. Errors from render are
+// reported, but do not stop watching since a file may be seen while it
+// is being rewritten. The paths function is called on each check so that
+// the set of watched files may change.
+func watch(ctx context.Context, paths func() []string, render func(ctx context.Context) error) {
+	for {
+		last := stat(paths())
+		rctx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- render(rctx) }()
+
+		ticker := time.NewTicker(pollInterval)
+	poll:
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				cancel()
+				if done != nil {
+					<-done
+				}
+				return
+			case err := <-done:
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "failed to render: %v\n", err)
+				}
+				done = nil
+			case <-ticker.C:
+				if !reflect.DeepEqual(stat(paths()), last) {
+					break poll
+				}
+			}
+		}
+		ticker.Stop()
+		cancel()
+		if done != nil {
+			err := <-done
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to render: %v\n", err)
+			}
+		}
+	}
+}
+
+// fileState is the modification state of a watched file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	err     string
+}
+
+// stat returns the modification state of the files at paths.
+func stat(paths []string) []fileState {
+	s := make([]fileState, len(paths))
+	for i, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			s[i].err = err.Error()
+			continue
+		}
+		s[i].modTime = fi.ModTime()
+		s[i].size = fi.Size()
+	}
+	return s
+}
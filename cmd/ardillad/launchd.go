@@ -0,0 +1,79 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// launchdLabel is the label of the launchd job written by writePlist.
+const launchdLabel = "com.github.kortschak.ardillad"
+
+// pathFlags are the flags that hold paths. They are made absolute in the
+// launchd job since launchd runs jobs in the root directory.
+var pathFlags = map[string]bool{
+	"addr":    true,
+	"layout":  true,
+	"plugins": true,
+}
+
+// writePlist writes a launchd property list to w that runs the daemon
+// with the flags set on the command line, except the flag named by omit.
+// The job is started at login and restarted if it exits.
+func writePlist(w io.Writer, omit string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := []string{exe}
+	flag.Visit(func(f *flag.Flag) {
+		if err != nil || f.Name == omit {
+			return
+		}
+		val := f.Value.String()
+		if pathFlags[f.Name] && val != "" {
+			val, err = filepath.Abs(val)
+		}
+		args = append(args, "-"+f.Name+"="+val)
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf strings.Builder
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>`)
+	xml.EscapeText(&buf, []byte(launchdLabel))
+	buf.WriteString(`</string>
+	<key>ProgramArguments</key>
+	<array>
+`)
+	for _, a := range args {
+		buf.WriteString("\t\t<string>")
+		xml.EscapeText(&buf, []byte(a))
+		buf.WriteString("</string>\n")
+	}
+	buf.WriteString(`	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>ProcessType</key>
+	<string>Interactive</string>
+</dict>
+</plist>
+`)
+	_, err = io.WriteString(w, buf.String())
+	return err
+}
@@ -0,0 +1,192 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kortschak/ardilla/lease"
+)
+
+// leaseInit is the first message sent to a lease holder.
+type leaseInit struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Device string `json:"device"`
+	Rows   int    `json:"rows"`
+	Cols   int    `json:"cols"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// leaseKey is a key event sent to a lease holder.
+type leaseKey struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Row     int       `json:"row"`
+	Col     int       `json:"col"`
+	Pressed bool      `json:"pressed"`
+}
+
+// leaseLagged reports key events dropped because the lease holder was
+// not keeping up. Key states known to the holder may be stale.
+type leaseLagged struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Dropped uint64    `json:"dropped"`
+}
+
+// lease grants a lease on the region of keys in the request body and
+// streams the lease's key events to the client as JSON lines until the
+// client disconnects, when the lease is released.
+func (s *server) lease(w http.ResponseWriter, req *http.Request) {
+	if !allow(w, req, http.MethodPost) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	var r lease.Region
+	err := json.NewDecoder(req.Body).Decode(&r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	l, err := s.leases.Lease(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	defer l.Release()
+	bounds, err := l.Bounds()
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	id, err := newLeaseID()
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	s.leaseMu.Lock()
+	if s.leased == nil {
+		s.leased = make(map[string]*lease.Lease)
+	}
+	s.leased[id] = l
+	s.leaseMu.Unlock()
+	defer func() {
+		s.leaseMu.Lock()
+		delete(s.leased, id)
+		s.leaseMu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	err = enc.Encode(leaseInit{
+		Type:   "init",
+		ID:     id,
+		Device: l.PID().String(),
+		Rows:   r.Rows,
+		Cols:   r.Cols,
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+	})
+	if err != nil {
+		return
+	}
+	flusher.Flush()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case ev := <-l.Events():
+			switch {
+			case ev.Err != nil || ev.Stuck:
+				continue
+			case ev.Lagged != 0:
+				err = enc.Encode(leaseLagged{
+					Type:    "lagged",
+					Time:    ev.Time,
+					Dropped: ev.Lagged,
+				})
+			default:
+				err = enc.Encode(leaseKey{
+					Type:    "key",
+					Time:    ev.Time,
+					Row:     ev.Row,
+					Col:     ev.Col,
+					Pressed: ev.Pressed,
+				})
+			}
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// leaseImage renders the image file in the request body on a leased key.
+// The lease, row and column are given by the id, row and col query
+// parameters, with the key position relative to the leased region.
+func (s *server) leaseImage(w http.ResponseWriter, req *http.Request) {
+	if !allow(w, req, http.MethodPost) {
+		return
+	}
+	q := req.URL.Query()
+	s.leaseMu.Lock()
+	l, ok := s.leased[q.Get("id")]
+	s.leaseMu.Unlock()
+	if !ok {
+		http.Error(w, "unknown lease", http.StatusNotFound)
+		return
+	}
+	row, err := strconv.Atoi(q.Get("row"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid row: %v", err), http.StatusBadRequest)
+		return
+	}
+	col, err := strconv.Atoi(q.Get("col"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid column: %v", err), http.StatusBadRequest)
+		return
+	}
+	img, _, err := image.Decode(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = l.SetImage(row, col, img)
+	if errors.Is(err, lease.ErrReleased) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fail(w, err)
+	}
+}
+
+// newLeaseID returns a random lease identifier. Identifiers are not
+// guessable so that only the lease holder can draw on its keys.
+func newLeaseID() (string, error) {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
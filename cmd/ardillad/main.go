@@ -0,0 +1,535 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The ardillad command is a daemon that owns a Stream Deck device, renders
+// a layout file onto it and exposes an HTTP control API on a unix socket.
+//
+// When started by systemd with socket activation, the control API is served
+// on the passed sockets and readiness is reported with sd_notify.
+//
+// The control API provides the following endpoints:
+//
+//	GET  /info        device details as JSON
+//	POST /brightness  set brightness from {"percent": n}
+//	POST /reset       reset the device
+//	POST /layout      render a JSON layout provided in the request body
+//	POST /reload      re-read and render the layout file
+//	POST /lease       lease a region of keys from {"row": r, "col": c, "rows": n, "cols": m}
+//	POST /lease/image render the image in the request body on a leased key
+//
+// A lease gives a client sole use of a rectangular region of keys so that
+// several programs can share one deck. The /lease response is a stream of
+// JSON lines, starting with an init message holding the lease's id and
+// followed by the key events of the leased keys, for example
+//
+//	{"type":"init","id":"9f86d081884c7d65","device":"StreamDeckXL","rows":2,"cols":4,"width":96,"height":96}
+//	{"type":"key","time":"2023-01-01T00:00:00Z","row":0,"col":1,"pressed":true}
+//
+// Key positions are relative to the top left of the leased region. If the
+// client does not keep up with its events, events are dropped and a lagged
+// message giving the number dropped is sent, after which the key states
+// tracked by the client may be stale, for example
+//
+//	{"type":"lagged","time":"2023-01-01T00:00:01Z","dropped":3}
+//
+// The lease is held until the client closes the stream. Images are drawn on
+// leased keys by posting an image file to /lease/image with the lease's
+// id and the key's position in the id, row and col query parameters.
+// Leased keys should not be given content by the layout.
+//
+// Sending SIGHUP to the daemon also reloads the layout file.
+//
+// On multi-seat systems, or when several users share a deck, the -seat
+// flag names the systemd-logind seat the deck is attached to. The daemon
+// then releases the device while the active session on the seat belongs
+// to another user, and reclaims it and restores the layout when a session
+// of the daemon's user becomes active again. While the device is released
+// the control API reports the service as unavailable.
+//
+// On macOS, the daemon checks the device when the system wakes from sleep,
+// reopening it if needed and restoring the layout. The -launchd-plist flag
+// prints a launchd job definition that runs the daemon at login with the
+// other flags given, for example
+//
+//	ardillad -addr ~/.ardillad.sock -layout ~/deck.json -launchd-plist > ~/Library/LaunchAgents/com.github.kortschak.ardillad.plist
+//	launchctl load ~/Library/LaunchAgents/com.github.kortschak.ardillad.plist
+//
+// Plugins hosted by the daemon are configured with a JSON file given by
+// the -plugins flag holding a list of the programs to run and the keys
+// they own, for example
+//
+//	[
+//		{"path": "/usr/local/bin/clock", "args": ["-24h"], "keys": [{"row": 0, "col": 4}]},
+//		{"path": "/usr/local/bin/ha-lights", "keys": [{"row": 1, "col": 4}],
+//			"secrets": {"HA_TOKEN": "keyring:home-assistant/deck"}}
+//	]
+//
+// Plugin secrets are references, described in the secret package, that
+// are resolved when the plugin is started and passed to it in the named
+// environment variables.
+//
+// Plugins communicate with the daemon using the protocol described in the
+// plugin package. A plugin that exits is restarted after a delay. Plugin
+// keys should not be given content by the layout.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/layout"
+	"github.com/kortschak/ardilla/lease"
+	"github.com/kortschak/ardilla/plugin"
+)
+
+func main() {
+	os.Exit(Main())
+}
+
+func Main() int {
+	var pids []ardilla.PID
+	for _, d := range ardilla.Devices() {
+		pids = append(pids, d.PID)
+	}
+
+	dev := flag.String("device", "", fmt.Sprintf("device name from %s", pids))
+	ser := flag.String("serial", "", "device serial number")
+	path := flag.String("layout", "", "path to layout file")
+	addr := flag.String("addr", "", "unix socket path for the control API if not socket activated")
+	pluginsPath := flag.String("plugins", "", "path to plugin configuration file")
+	seat := flag.String("seat", "", "logind seat to follow, releasing the device when the seat's session is inactive")
+	plist := flag.Bool("launchd-plist", false, "print a launchd job definition for the daemon with the given flags and exit")
+	flag.Parse()
+
+	if *plist {
+		if *addr == "" {
+			fmt.Fprintln(os.Stderr, "missing control socket address")
+			flag.Usage()
+			return 2
+		}
+		err := writePlist(os.Stdout, "launchd-plist")
+		if err != nil {
+			log.Printf("failed to write launchd job definition: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	pid := ardilla.PID(0xffff)
+	for _, id := range pids {
+		if *dev == "" {
+			pid = 0
+			break
+		}
+		if *dev == id.String() {
+			pid = id
+			break
+		}
+	}
+	if pid == 0xffff {
+		fmt.Fprintf(os.Stderr, "%q is not a known device\n", *dev)
+		flag.Usage()
+		return 2
+	}
+
+	var plugins []pluginConfig
+	if *pluginsPath != "" {
+		var err error
+		plugins, err = loadPlugins(*pluginsPath)
+		if err != nil {
+			log.Printf("failed to read plugin configuration: %v", err)
+			return 1
+		}
+	}
+
+	lis, err := listeners()
+	if err != nil {
+		log.Printf("failed to get activation sockets: %v", err)
+		return 1
+	}
+	if len(lis) == 0 {
+		if *addr == "" {
+			fmt.Fprintln(os.Stderr, "missing control socket address")
+			flag.Usage()
+			return 2
+		}
+		l, err := listenUnix(*addr)
+		if err != nil {
+			log.Printf("failed to listen on control socket: %v", err)
+			return 1
+		}
+		lis = append(lis, l)
+	}
+
+	d, err := ardilla.NewDeck(pid, *ser)
+	if err != nil {
+		log.Printf("failed to open device: %v", err)
+		return 1
+	}
+	defer d.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &server{ctx: ctx, deck: d, path: *path}
+	s.leases = lease.New(serverDeck{s})
+	if s.path != "" {
+		err = s.reload()
+		if err != nil {
+			log.Printf("failed to render layout: %v", err)
+			return 1
+		}
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := watchPower(ctx, s.revalidate)
+		if err != nil {
+			log.Printf("failed to watch system power: %v", err)
+		}
+	}()
+	if *seat != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.watchSeat(ctx, *seat)
+		}()
+	}
+	// The event loop is waited for separately since it may be
+	// blocked reading key states until the device is closed.
+	var polling sync.WaitGroup
+	polling.Add(1)
+	go func() {
+		defer polling.Done()
+		s.events(ctx)
+	}()
+	if len(plugins) != 0 {
+		for _, cfg := range plugins {
+			wg.Add(1)
+			go func(cfg pluginConfig) {
+				defer wg.Done()
+				s.supervise(ctx, cfg)
+			}(cfg)
+		}
+	}
+
+	srv := &http.Server{Handler: s.handler()}
+	for _, l := range lis {
+		go func(l net.Listener) {
+			err := srv.Serve(l)
+			if err != http.ErrServerClosed {
+				log.Printf("control API: %v", err)
+			}
+		}(l)
+	}
+	err = notify("READY=1")
+	if err != nil {
+		log.Printf("failed to notify readiness: %v", err)
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	for sig := range c {
+		if isReload(sig) {
+			notify("RELOADING=1")
+			err = s.reload()
+			if err != nil {
+				log.Printf("failed to reload layout: %v", err)
+			}
+			notify("READY=1")
+			continue
+		}
+		break
+	}
+	signal.Stop(c)
+	notify("STOPPING=1")
+
+	cancel()
+	wg.Wait()
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = srv.Shutdown(ctx)
+	d.Close()
+	polling.Wait()
+	if err != nil {
+		log.Printf("failed to shut down control API: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// listenUnix listens on the unix socket at path. A socket file left by an
+// instance that exited without removing it, for example after a crash, is
+// removed first so that a supervisor restarting the daemon, such as launchd
+// with KeepAlive, can recover. A socket that accepts connections belongs to
+// a running instance and is left in place.
+func listenUnix(path string) (net.Listener, error) {
+	fi, err := os.Lstat(path)
+	if err == nil && fi.Mode()&os.ModeSocket != 0 {
+		c, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			c.Close()
+			return nil, fmt.Errorf("%s is in use by another instance", path)
+		}
+		err = os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// server is the daemon's control API.
+type server struct {
+	ctx context.Context
+
+	mu           sync.Mutex
+	deck         *ardilla.Deck
+	reconnecting bool
+	released     bool
+	path         string
+	layout       *layout.Layout
+
+	pluginMu sync.Mutex
+	plugins  map[*plugin.Plugin]bool
+
+	leases  *lease.Leaser
+	leaseMu sync.Mutex
+	leased  map[string]*lease.Lease // leased is keyed by lease id.
+}
+
+// do calls fn with the server's deck while holding the deck lock. If fn
+// returns ardilla.ErrNotConnected, do starts reconnecting to the device
+// and restores the current layout once the device is back. If the device
+// has been released to another session, do returns ardilla.ErrReleased.
+func (s *server) do(fn func(d *ardilla.Deck) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.released {
+		return ardilla.ErrReleased
+	}
+	if s.reconnecting {
+		return ardilla.ErrNotConnected
+	}
+	err := fn(s.deck)
+	if errors.Is(err, ardilla.ErrNotConnected) {
+		s.reconnecting = true
+		go s.reconnect()
+	}
+	return err
+}
+
+// reconnect waits for the deck to be reconnected and then renders the
+// current layout, plugin keys and leased keys.
+func (s *server) reconnect() {
+	log.Print("device disconnected: waiting for reconnection")
+	// The deck is not used by other goroutines while
+	// s.reconnecting is true, so no lock is needed.
+	err := s.deck.Reconnect(s.ctx, time.Second)
+	s.mu.Lock()
+	s.reconnecting = false
+	if err != nil {
+		s.mu.Unlock()
+		log.Printf("failed to reconnect: %v", err)
+		return
+	}
+	log.Print("device reconnected")
+	if s.layout != nil {
+		err = s.layout.Apply(s.deck)
+		if err != nil {
+			log.Printf("failed to render layout: %v", err)
+		}
+	}
+	s.mu.Unlock()
+	s.redraw()
+}
+
+// reload reads the layout file and renders it onto the deck.
+func (s *server) reload() error {
+	if s.path == "" {
+		return errors.New("no layout file")
+	}
+	l, err := layout.Load(s.path)
+	if err != nil {
+		return err
+	}
+	return s.apply(l)
+}
+
+// apply renders l onto the deck and retains it as the current layout.
+// Plugin and leased keys are redrawn after the layout is rendered.
+func (s *server) apply(l *layout.Layout) error {
+	err := s.do(func(d *ardilla.Deck) error {
+		err := l.Apply(d)
+		if err != nil {
+			return err
+		}
+		s.layout = l
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.redraw()
+	return nil
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/info", s.info)
+	mux.HandleFunc("/brightness", s.brightness)
+	mux.HandleFunc("/reset", s.reset)
+	mux.HandleFunc("/layout", s.setLayout)
+	mux.HandleFunc("/reload", s.reloadLayout)
+	mux.HandleFunc("/lease", s.lease)
+	mux.HandleFunc("/lease/image", s.leaseImage)
+	return mux
+}
+
+// info is the device details returned by the /info endpoint.
+type info struct {
+	Device   string `json:"device"`
+	Serial   string `json:"serial"`
+	Firmware string `json:"firmware"`
+	Rows     int    `json:"rows"`
+	Cols     int    `json:"cols"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+}
+
+func (s *server) info(w http.ResponseWriter, req *http.Request) {
+	if !allow(w, req, http.MethodGet) {
+		return
+	}
+	var resp info
+	err := s.do(func(d *ardilla.Deck) error {
+		var err error
+		resp.Device = d.PID().String()
+		resp.Rows, resp.Cols = d.Layout()
+		if b, err := d.Bounds(); err == nil {
+			resp.Width, resp.Height = b.Dx(), b.Dy()
+		}
+		resp.Serial, err = d.Serial()
+		if err != nil {
+			return err
+		}
+		resp.Firmware, err = d.Firmware()
+		return err
+	})
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *server) brightness(w http.ResponseWriter, req *http.Request) {
+	if !allow(w, req, http.MethodPost) {
+		return
+	}
+	var body struct {
+		Percent *int `json:"percent"`
+	}
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err == nil && body.Percent == nil {
+		err = errors.New("missing percent")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = s.do(func(d *ardilla.Deck) error {
+		return d.SetBrightness(*body.Percent)
+	})
+	if err != nil {
+		fail(w, err)
+	}
+}
+
+func (s *server) reset(w http.ResponseWriter, req *http.Request) {
+	if !allow(w, req, http.MethodPost) {
+		return
+	}
+	err := s.do(func(d *ardilla.Deck) error {
+		return d.Reset()
+	})
+	if err != nil {
+		fail(w, err)
+	}
+}
+
+func (s *server) setLayout(w http.ResponseWriter, req *http.Request) {
+	if !allow(w, req, http.MethodPost) {
+		return
+	}
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	l, err := layout.Unmarshal(b)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = s.apply(l)
+	if err != nil {
+		fail(w, err)
+	}
+}
+
+func (s *server) reloadLayout(w http.ResponseWriter, req *http.Request) {
+	if !allow(w, req, http.MethodPost) {
+		return
+	}
+	err := s.reload()
+	if err != nil {
+		fail(w, err)
+	}
+}
+
+// allow returns whether the request uses the given method, responding with
+// an error if it does not.
+func allow(w http.ResponseWriter, req *http.Request, method string) bool {
+	if req.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+// fail responds with err, using a status code appropriate for the error.
+func fail(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	var pathErr *os.PathError
+	switch {
+	case errors.Is(err, ardilla.ErrNotConnected), errors.Is(err, ardilla.ErrReleased):
+		code = http.StatusServiceUnavailable
+	case errors.As(err, &pathErr), errors.Is(err, image.ErrFormat):
+		code = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), code)
+}
@@ -0,0 +1,219 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/layout"
+	"github.com/kortschak/ardilla/plugin"
+	"github.com/kortschak/ardilla/secret"
+)
+
+// pluginConfig is an entry in the plugins file.
+type pluginConfig struct {
+	Path string            `json:"path"`
+	Args []string          `json:"args,omitempty"`
+	Keys []layout.Position `json:"keys"`
+
+	// Secrets maps environment variable names to
+	// secret references that are added to the
+	// plugin's environment.
+	Secrets map[string]string `json:"secrets,omitempty"`
+}
+
+// loadPlugins reads the plugin configurations in the JSON file at path.
+func loadPlugins(path string) ([]pluginConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfgs []pluginConfig
+	err = json.Unmarshal(b, &cfgs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	owner := make(map[layout.Position]string)
+	for _, c := range cfgs {
+		if c.Path == "" {
+			return nil, fmt.Errorf("%s: plugin missing path", path)
+		}
+		for _, k := range c.Keys {
+			if prev, ok := owner[k]; ok {
+				return nil, fmt.Errorf("%s: key %d,%d used by %s and %s", path, k.Row, k.Col, prev, c.Path)
+			}
+			owner[k] = c.Path
+		}
+	}
+	return cfgs, nil
+}
+
+const (
+	// minRestart and maxRestart bound the delay before
+	// a plugin that has exited is restarted.
+	minRestart = time.Second
+	maxRestart = time.Minute
+)
+
+// supervise runs the plugin described by cfg until ctx is cancelled,
+// restarting it with increasing delay if it exits.
+func (s *server) supervise(ctx context.Context, cfg pluginConfig) {
+	delay := minRestart
+	for {
+		started := time.Now()
+		cmd := exec.Command(cfg.Path, cfg.Args...)
+		cmd.Stderr = os.Stderr
+		env, err := secret.AppendEnv(ctx, nil, cfg.Secrets)
+		var p *plugin.Plugin
+		if err == nil {
+			cmd.Env = env
+			p, err = plugin.Start(cmd, serverDeck{s}, cfg.Keys)
+		}
+		if err != nil {
+			log.Printf("failed to start plugin %s: %v", cfg.Path, err)
+		} else {
+			s.addPlugin(p)
+			select {
+			case <-ctx.Done():
+				s.removePlugin(p)
+				err = p.Close()
+				if err != nil {
+					log.Printf("plugin %s: %v", cfg.Path, err)
+				}
+				return
+			case <-p.Done():
+				s.removePlugin(p)
+				log.Printf("plugin %s exited: %v", cfg.Path, p.Wait())
+			}
+		}
+		if time.Since(started) > maxRestart {
+			delay = minRestart
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxRestart {
+			delay = maxRestart
+		}
+	}
+}
+
+func (s *server) addPlugin(p *plugin.Plugin) {
+	s.pluginMu.Lock()
+	defer s.pluginMu.Unlock()
+	if s.plugins == nil {
+		s.plugins = make(map[*plugin.Plugin]bool)
+	}
+	s.plugins[p] = true
+}
+
+func (s *server) removePlugin(p *plugin.Plugin) {
+	s.pluginMu.Lock()
+	defer s.pluginMu.Unlock()
+	delete(s.plugins, p)
+}
+
+// redraw restores the images drawn by plugins and lease holders. It must
+// not be called while holding the deck lock.
+func (s *server) redraw() {
+	s.pluginMu.Lock()
+	for p := range s.plugins {
+		err := p.Redraw()
+		if err != nil {
+			log.Printf("failed to redraw plugin keys: %v", err)
+		}
+	}
+	s.pluginMu.Unlock()
+	err := s.leases.Redraw()
+	if err != nil {
+		log.Printf("failed to redraw leased keys: %v", err)
+	}
+}
+
+// events forwards key events from the deck to the plugins and lease
+// holders until ctx is cancelled. The deck is only polled while it is
+// neither released nor reconnecting, and a poll in progress when the
+// deck is released returns ardilla.ErrReleased, handing the device back
+// to the seat watcher.
+func (s *server) events(ctx context.Context) {
+	var states []bool
+	for ctx.Err() == nil {
+		s.mu.Lock()
+		reconnecting, released := s.reconnecting, s.released
+		s.mu.Unlock()
+		var (
+			changes []ardilla.KeyChange
+			err     error
+		)
+		switch {
+		case released:
+			err = ardilla.ErrReleased
+		case reconnecting:
+			err = ardilla.ErrNotConnected
+		default:
+			states, changes, err = s.deck.Poll(states)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				// The device was closed on shutdown.
+				return
+			}
+			switch {
+			case errors.Is(err, ardilla.ErrReleased):
+				// Wait for the device to be reclaimed.
+			case errors.Is(err, ardilla.ErrNotConnected):
+				// Start reconnecting if this is the
+				// first failure seen.
+				s.do(func(*ardilla.Deck) error { return err })
+			default:
+				log.Printf("failed to read key states: %v", err)
+			}
+			states = nil
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		now := time.Now()
+		s.pluginMu.Lock()
+		for _, c := range changes {
+			ev := ardilla.KeyEvent{Time: now, Key: c.Key, Row: c.Row, Col: c.Col, Pressed: c.Pressed}
+			for p := range s.plugins {
+				p.Handle(ev)
+			}
+			s.leases.Handle(ev)
+		}
+		s.pluginMu.Unlock()
+	}
+}
+
+// serverDeck is the server's deck as seen by plugins. Images are set
+// through the server so that plugins take part in reconnection.
+type serverDeck struct {
+	s *server
+}
+
+func (d serverDeck) PID() ardilla.PID                 { return d.s.deck.PID() }
+func (d serverDeck) Layout() (rows, cols int)         { return d.s.deck.Layout() }
+func (d serverDeck) Bounds() (image.Rectangle, error) { return d.s.deck.Bounds() }
+
+func (d serverDeck) SetImage(row, col int, img image.Image) error {
+	return d.s.do(func(deck *ardilla.Deck) error {
+		return deck.SetImage(row, col, img)
+	})
+}
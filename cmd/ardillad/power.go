@@ -0,0 +1,48 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"log"
+
+	"github.com/kortschak/ardilla"
+)
+
+// revalidate checks the device after the system wakes from sleep and
+// restores the layout and plugin keys, since the device may have been
+// power cycled while the system slept. If the device handle has been
+// invalidated, the device is reopened, falling back to reconnection if
+// it cannot be reopened immediately.
+func (s *server) revalidate() {
+	err := s.do(func(d *ardilla.Deck) error {
+		_, err := d.ReadSerial()
+		if err != nil && !errors.Is(err, ardilla.ErrNotConnected) {
+			d.Release()
+			err = d.Reclaim()
+			if err != nil {
+				log.Printf("failed to reopen device after wake: %v", err)
+				// Reconnection reopens the released
+				// device once it is available.
+				return ardilla.ErrNotConnected
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if s.layout != nil {
+			return s.layout.Apply(d)
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+		s.redraw()
+	case errors.Is(err, ardilla.ErrReleased):
+		// The device is in use by another session.
+	default:
+		log.Printf("failed to revalidate device after wake: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build cgo
+
+package main
+
+/*
+#cgo LDFLAGS: -framework IOKit -framework CoreFoundation
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <IOKit/IOMessage.h>
+#include <IOKit/pwr_mgt/IOPMLib.h>
+
+extern void powerEvent(unsigned int);
+
+static const unsigned int msgHasPoweredOn = kIOMessageSystemHasPoweredOn;
+
+// rootPort, port and notifier are only used by the thread
+// running the power notification run loop.
+static io_connect_t rootPort;
+static IONotificationPortRef port;
+static io_object_t notifier;
+
+static void powerCallback(void *refCon, io_service_t service, natural_t messageType, void *messageArgument) {
+	switch (messageType) {
+	case kIOMessageCanSystemSleep:
+	case kIOMessageSystemWillSleep:
+		// Sleep must be acknowledged or the
+		// system waits for a timeout.
+		IOAllowPowerChange(rootPort, (long)messageArgument);
+		break;
+	}
+	powerEvent(messageType);
+}
+
+// registerPower registers for system power notifications delivered
+// to the current thread's run loop, and returns that run loop, or
+// NULL if registration fails.
+static CFRunLoopRef registerPower(void) {
+	rootPort = IORegisterForSystemPower(NULL, &port, powerCallback, &notifier);
+	if (rootPort == MACH_PORT_NULL) {
+		return NULL;
+	}
+	CFRunLoopRef loop = CFRunLoopGetCurrent();
+	CFRunLoopAddSource(loop, IONotificationPortGetRunLoopSource(port), kCFRunLoopCommonModes);
+	return loop;
+}
+
+// runPowerLoop runs the current thread's run loop until it is stopped
+// and then deregisters from system power notifications.
+static void runPowerLoop(void) {
+	CFRunLoopRun();
+	IODeregisterForSystemPower(&notifier);
+	IOServiceClose(rootPort);
+	IONotificationPortDestroy(port);
+}
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// powerQueue holds IOKit system power messages until they are handled
+// by watchPower. Messages are queued rather than sent on a channel so
+// that the run loop thread never blocks and no message is dropped.
+var powerQueue = struct {
+	mu    sync.Mutex
+	msgs  []uint32
+	ready chan struct{}
+}{ready: make(chan struct{}, 1)}
+
+// queuePowerMessage adds msg to powerQueue and signals watchPower.
+func queuePowerMessage(msg uint32) {
+	powerQueue.mu.Lock()
+	powerQueue.msgs = append(powerQueue.msgs, msg)
+	powerQueue.mu.Unlock()
+	select {
+	case powerQueue.ready <- struct{}{}:
+	default:
+		// watchPower has already been signalled
+		// and will take this message with the
+		// others in the queue.
+	}
+}
+
+// watchPower calls wake each time the system has woken from sleep, until
+// ctx is cancelled, using IOKit system power notifications.
+func watchPower(ctx context.Context, wake func()) error {
+	loop := make(chan C.CFRunLoopRef)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The notification port is serviced by
+		// this thread's run loop.
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		l := C.registerPower()
+		loop <- l
+		if l == 0 {
+			return
+		}
+		C.runPowerLoop()
+	}()
+	// Wait for registration so that the run loop is
+	// known before it may need to be stopped. A run
+	// loop stopped before it runs returns immediately
+	// when it is run.
+	l := <-loop
+	if l == 0 {
+		return errors.New("failed to register for system power notifications")
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			C.CFRunLoopStop(l)
+			<-done
+			return nil
+		case <-powerQueue.ready:
+			powerQueue.mu.Lock()
+			msgs := powerQueue.msgs
+			powerQueue.msgs = nil
+			powerQueue.mu.Unlock()
+			for _, msg := range msgs {
+				if msg == uint32(C.msgHasPoweredOn) {
+					wake()
+				}
+			}
+		}
+	}
+}
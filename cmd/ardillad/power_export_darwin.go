@@ -0,0 +1,14 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build cgo
+
+package main
+
+import "C"
+
+//export powerEvent
+func powerEvent(msg C.uint) {
+	queuePowerMessage(uint32(msg))
+}
@@ -0,0 +1,16 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !darwin || !cgo
+
+package main
+
+import "context"
+
+// watchPower waits for ctx to be cancelled; system wake notifications are
+// only available on darwin with cgo.
+func watchPower(ctx context.Context, wake func()) error {
+	<-ctx.Done()
+	return nil
+}
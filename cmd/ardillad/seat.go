@@ -0,0 +1,96 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// seatPoll is the interval between checks of the seat's active session.
+const seatPoll = time.Second
+
+// watchSeat releases the deck when the active session on seat does not
+// belong to the daemon's user, so that a daemon in the active session can
+// use it, and reclaims the deck when a session of the user becomes active
+// again. watchSeat returns when ctx is cancelled.
+func (s *server) watchSeat(ctx context.Context, seat string) {
+	ticker := time.NewTicker(seatPoll)
+	defer ticker.Stop()
+	var lastErr string
+	for {
+		owned, err := seatOwned(seat)
+		switch {
+		case err != nil:
+			// Only log changes in error to avoid
+			// flooding the log.
+			if err.Error() != lastErr {
+				log.Printf("failed to get seat state: %v", err)
+				lastErr = err.Error()
+			}
+		case owned:
+			lastErr = ""
+			err = s.reclaim()
+		default:
+			lastErr = ""
+			err = s.release()
+		}
+		if err != nil {
+			log.Print(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// release closes the deck's device if it is not already released.
+func (s *server) release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.released || s.reconnecting {
+		// Reconnection owns the deck; try again
+		// on the next poll.
+		return nil
+	}
+	// Mark the deck released before releasing it so
+	// that the events loop does not start another
+	// poll. A poll in progress is ended by Release
+	// with ErrReleased before the device is closed,
+	// and the loop then waits for the deck to be
+	// reclaimed.
+	s.released = true
+	err := s.deck.Release()
+	log.Print("session inactive: device released")
+	return err
+}
+
+// reclaim reopens the deck's device if it has been released and renders
+// the current layout and plugin keys.
+func (s *server) reclaim() error {
+	s.mu.Lock()
+	if !s.released {
+		s.mu.Unlock()
+		return nil
+	}
+	err := s.deck.Reclaim()
+	if err != nil {
+		// The device may not yet have been
+		// released by the other session's owner.
+		s.mu.Unlock()
+		return nil
+	}
+	s.released = false
+	log.Print("session active: device reclaimed")
+	if s.layout != nil {
+		err = s.layout.Apply(s.deck)
+	}
+	s.mu.Unlock()
+	s.redraw()
+	return err
+}
@@ -0,0 +1,49 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// seatsDir is the directory holding systemd-logind seat state files.
+const seatsDir = "/run/systemd/seats"
+
+// seatOwned returns whether the active session on the named seat belongs
+// to the user running the daemon, using the seat state published by
+// systemd-logind. See sd_seat_get_active(3).
+func seatOwned(seat string) (bool, error) {
+	if seat == "" || strings.ContainsRune(seat, filepath.Separator) {
+		return false, errors.New("invalid seat name")
+	}
+	f, err := os.Open(filepath.Join(seatsDir, seat))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, errors.New("no logind seat " + seat)
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), "=")
+		if !ok || k != "ACTIVE_UID" {
+			continue
+		}
+		uid, err := strconv.Atoi(v)
+		if err != nil {
+			return false, err
+		}
+		return uid == os.Getuid(), nil
+	}
+	// No session is active on the seat.
+	return false, sc.Err()
+}
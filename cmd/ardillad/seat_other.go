@@ -0,0 +1,14 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+import "errors"
+
+// seatOwned returns an error; seat awareness is only available on linux.
+func seatOwned(seat string) (bool, error) {
+	return false, errors.New("seat awareness requires systemd-logind")
+}
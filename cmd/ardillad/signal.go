@@ -0,0 +1,20 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !js
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// signals are the signals handled by the daemon.
+var signals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// isReload returns whether sig requests a reload of the layout file.
+func isReload(sig os.Signal) bool {
+	return sig == syscall.SIGHUP
+}
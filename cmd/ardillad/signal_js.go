@@ -0,0 +1,19 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// signals are the signals handled by the daemon. There is no SIGHUP on
+// js, so the layout file can only be reloaded through the control API.
+var signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// isReload returns false; there is no reload signal on js.
+func isReload(sig os.Signal) bool {
+	return false
+}
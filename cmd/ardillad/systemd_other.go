@@ -0,0 +1,17 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+import "net"
+
+// listeners returns no listeners; socket activation is only available
+// on linux.
+func listeners() ([]net.Listener, error) { return nil, nil }
+
+// notify is a no-op; service manager notification is only available
+// on linux.
+func notify(state string) error { return nil }
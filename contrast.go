@@ -0,0 +1,100 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"image"
+	"image/draw"
+)
+
+// SetHighContrast sets whether key images are rendered in high contrast
+// mode. In high contrast mode, images are converted to black and white
+// after edge enhancement to make their shapes easier to distinguish.
+// Changing the mode redraws the keys in the new mode, and a RawImage made
+// in the old mode is rendered again when it is next set.
+func (d *Deck) SetHighContrast(on bool) error {
+	if !d.desc.visual {
+		return nil
+	}
+	d.mu.Lock()
+	if d.highContrast == on {
+		d.mu.Unlock()
+		return nil
+	}
+	d.highContrast = on
+	d.mu.Unlock()
+	return d.rerender()
+}
+
+// HighContrast returns whether key images are rendered in high contrast
+// mode.
+func (d *Deck) HighContrast() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.highContrast
+}
+
+// highContrast returns a black and white rendering of img. Edges are
+// enhanced with a Laplacian sharpening kernel and the result is
+// thresholded at the middle of the image's luminance range.
+func highContrast(img image.Image) *image.Gray {
+	b := img.Bounds()
+	lum := image.NewGray(b)
+	draw.Draw(lum, b, img, b.Min, draw.Src)
+
+	sharp := make([]int, b.Dx()*b.Dy())
+	lo, hi := 255, 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			v := 5*int(lum.GrayAt(x, y).Y) -
+				grayAt(lum, x-1, y) - grayAt(lum, x+1, y) -
+				grayAt(lum, x, y-1) - grayAt(lum, x, y+1)
+			if v < 0 {
+				v = 0
+			} else if v > 255 {
+				v = 255
+			}
+			sharp[(y-b.Min.Y)*b.Dx()+(x-b.Min.X)] = v
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+	}
+
+	// Images with little luminance variation are
+	// thresholded at mid-grey so that uniform light
+	// images remain light and dark images remain dark.
+	threshold := 128
+	if hi-lo >= 16 {
+		threshold = (lo + hi + 1) / 2
+	}
+	dst := image.NewGray(b)
+	for i, v := range sharp {
+		if v >= threshold {
+			dst.Pix[(i/b.Dx())*dst.Stride+i%b.Dx()] = 0xff
+		}
+	}
+	return dst
+}
+
+// grayAt returns the luminance of img at x, y, clamping the coordinates
+// to the image bounds.
+func grayAt(img *image.Gray, x, y int) int {
+	b := img.Bounds()
+	if x < b.Min.X {
+		x = b.Min.X
+	} else if x >= b.Max.X {
+		x = b.Max.X - 1
+	}
+	if y < b.Min.Y {
+		y = b.Min.Y
+	} else if y >= b.Max.Y {
+		y = b.Max.Y - 1
+	}
+	return int(img.GrayAt(x, y).Y)
+}
@@ -0,0 +1,114 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+var highContrastTests = []struct {
+	name string
+	img  image.Image
+	want map[image.Point]uint8
+}{
+	{
+		name: "white",
+		img:  uniformRGBA(image.Rect(0, 0, 8, 8), color.White),
+		want: map[image.Point]uint8{{0, 0}: 0xff, {4, 4}: 0xff},
+	},
+	{
+		name: "black",
+		img:  uniformRGBA(image.Rect(0, 0, 8, 8), color.Black),
+		want: map[image.Point]uint8{{0, 0}: 0, {4, 4}: 0},
+	},
+	{
+		name: "dim_split",
+		img: func() image.Image {
+			img := uniformRGBA(image.Rect(0, 0, 8, 8), color.Gray{Y: 0x40})
+			for y := 0; y < 8; y++ {
+				for x := 4; x < 8; x++ {
+					img.Set(x, y, color.Gray{Y: 0x60})
+				}
+			}
+			return img
+		}(),
+		want: map[image.Point]uint8{{0, 0}: 0, {3, 4}: 0, {4, 4}: 0xff, {7, 7}: 0xff},
+	},
+}
+
+func TestHighContrast(t *testing.T) {
+	for _, test := range highContrastTests {
+		got := highContrast(test.img)
+		if got.Bounds() != test.img.Bounds() {
+			t.Errorf("unexpected bounds for %s: got:%v want:%v", test.name, got.Bounds(), test.img.Bounds())
+		}
+		for p, want := range test.want {
+			if v := got.GrayAt(p.X, p.Y).Y; v != want {
+				t.Errorf("unexpected value for %s at %v: got:%#x want:%#x", test.name, p, v, want)
+			}
+		}
+		for _, v := range got.Pix {
+			if v != 0 && v != 0xff {
+				t.Errorf("unexpected grey value for %s: %#x", test.name, v)
+				break
+			}
+		}
+	}
+}
+
+func TestDeckSetHighContrast(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dev := &virtDev{Writer: io.Discard}
+	d.setDev(dev)
+
+	// Use a grey that is rendered differently in high contrast
+	// mode so that the key image must be written again.
+	img := uniformRGBA(image.Rect(0, 0, 72, 72), color.Gray{0x40})
+	raw, err := d.RawImage(img)
+	if err != nil {
+		t.Fatalf("unexpected error for RawImage: %v", err)
+	}
+	err = d.SetImage(0, 0, raw)
+	if err != nil {
+		t.Fatalf("unexpected error for SetImage: %v", err)
+	}
+	n := len(dev.actions)
+
+	err = d.SetHighContrast(true)
+	if err != nil {
+		t.Fatalf("unexpected error for SetHighContrast: %v", err)
+	}
+	if !d.HighContrast() {
+		t.Error("expected high contrast mode")
+	}
+	if len(dev.actions) == n {
+		t.Error("current key image not rendered again")
+	}
+	if d.reusable(raw) {
+		t.Error("RawImage computed without high contrast reused in high contrast mode")
+	}
+	hc, err := d.RawImage(raw)
+	if err != nil {
+		t.Fatalf("unexpected error for RawImage: %v", err)
+	}
+	if hc == raw || !hc.highContrast {
+		t.Error("RawImage not recomputed in high contrast mode")
+	}
+
+	dev.actions = nil
+	err = d.SetHighContrast(true)
+	if err != nil {
+		t.Fatalf("unexpected error for SetHighContrast: %v", err)
+	}
+	if len(dev.actions) != 0 {
+		t.Errorf("unexpected writes for unchanged mode: %d", len(dev.actions))
+	}
+}
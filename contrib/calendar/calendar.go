@@ -0,0 +1,319 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package calendar provides a Stream Deck widget showing the next event
+// in an iCalendar (ICS) feed, such as the secret address of a hosted
+// calendar.
+//
+// Only the start, end and summary of VEVENT components are used.
+// Recurrence rules are not expanded, so a recurring event is only shown
+// for its first occurrence.
+//
+// The feed is fetched asynchronously by Run, so that rendering a key never
+// waits on the network; Text returns the next event in the most recently
+// fetched feed.
+package calendar
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a calendar event.
+type Event struct {
+	Summary string
+
+	// Start and End are the bounds of the event.
+	// End is after Start.
+	Start, End time.Time
+
+	// AllDay is whether the event is a date
+	// rather than a time.
+	AllDay bool
+}
+
+// Text returns the start and summary of the event relative to now.
+func (e Event) Text(now time.Time) string {
+	var when string
+	start := e.Start.In(now.Location())
+	switch {
+	case !now.Before(e.Start):
+		when = "Now"
+	case e.AllDay && sameDay(start, now):
+		when = "Today"
+	case e.AllDay:
+		when = start.Format("Mon 2")
+	case sameDay(start, now):
+		when = start.Format("15:04")
+	default:
+		when = start.Format("Mon 15:04")
+	}
+	return when + "\n" + e.Summary
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// Parse returns the events in the iCalendar data read from r, sorted by
+// start time. Times without a zone are interpreted in loc.
+func Parse(r io.Reader, loc *time.Location) ([]Event, error) {
+	var (
+		events []Event
+		ev     *Event
+		hasEnd bool
+		lines  []string
+	)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		l := strings.TrimRight(sc.Text(), "\r")
+		if len(lines) != 0 && (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) {
+			// Unfold continuation lines.
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+	err := sc.Err()
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range lines {
+		name, value, ok := strings.Cut(l, ":")
+		if !ok {
+			continue
+		}
+		name, params, _ := strings.Cut(name, ";")
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			ev = &Event{}
+			hasEnd = false
+		case ev == nil:
+		case name == "END" && value == "VEVENT":
+			if ev.Start.IsZero() {
+				return nil, fmt.Errorf("line %d: event without start", i+1)
+			}
+			if !hasEnd {
+				if ev.AllDay {
+					ev.End = ev.Start.AddDate(0, 0, 1)
+				} else {
+					ev.End = ev.Start
+				}
+			}
+			events = append(events, *ev)
+			ev = nil
+		case name == "SUMMARY":
+			ev.Summary = unescape(value)
+		case name == "DTSTART", name == "DTEND":
+			t, allDay, err := parseTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if name == "DTSTART" {
+				ev.Start, ev.AllDay = t, allDay
+			} else {
+				ev.End, hasEnd = t, true
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+	return events, nil
+}
+
+// parseTime returns the time in an iCalendar DATE or DATE-TIME value with
+// the given property parameters.
+func parseTime(value, params string, loc *time.Location) (t time.Time, allDay bool, err error) {
+	for _, p := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(p, "=")
+		switch k {
+		case "VALUE":
+			allDay = v == "DATE"
+		case "TZID":
+			tz, err := time.LoadLocation(strings.Trim(v, `"`))
+			if err == nil {
+				loc = tz
+			}
+		}
+	}
+	switch {
+	case allDay || len(value) == len("20060102"):
+		t, err = time.ParseInLocation("20060102", value, loc)
+		allDay = true
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse("20060102T150405Z", value)
+	default:
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid time: %q", value)
+	}
+	return t, allDay, nil
+}
+
+// unescape returns the iCalendar TEXT value s with escapes replaced.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) {
+			i++
+			c = s[i]
+			if c == 'n' || c == 'N' {
+				c = '\n'
+			}
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// Next returns the first event in events, which must be sorted by start
+// time, that has not ended at now. Events in progress are included.
+func Next(events []Event, now time.Time) (Event, bool) {
+	for _, e := range events {
+		if e.End.After(now) {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+// Widget is a key widget showing the next event in a calendar feed.
+type Widget struct {
+	// URL is the address of the iCalendar feed.
+	URL string
+
+	// Refresh is the interval between fetches of
+	// the feed. If Refresh is zero, 5 minutes is
+	// used. The widget's text is re-evaluated at
+	// least once a minute between fetches.
+	Refresh time.Duration
+
+	// Client is the client used for requests. If
+	// Client is nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// now returns the current time. If now is nil,
+	// time.Now is used.
+	now func() time.Time
+
+	mu      sync.Mutex
+	events  []Event
+	fetched bool
+	err     error
+}
+
+// ErrNoData is returned by Widget.Text before the feed has been fetched.
+var ErrNoData = errors.New("no calendar data")
+
+// Run fetches the feed immediately and then every Refresh interval until
+// ctx is cancelled, calling update with the widget's text each time it
+// changes. Failed fetches are retained and reported by Text, and update is
+// called with the text "ERR", but do not stop Run. Run returns ctx.Err.
+func (w *Widget) Run(ctx context.Context, update func(text string)) error {
+	refresh := w.Refresh
+	if refresh <= 0 {
+		refresh = 5 * time.Minute
+	}
+	tick := refresh
+	if tick > time.Minute {
+		tick = time.Minute
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	var (
+		last    string
+		fetched time.Time
+	)
+	for {
+		if fetched.IsZero() || time.Since(fetched) >= refresh {
+			events, err := w.fetch(ctx)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.mu.Lock()
+			if err == nil {
+				w.events = events
+				w.fetched = true
+			}
+			w.err = err
+			w.mu.Unlock()
+			fetched = time.Now()
+		}
+
+		text, err := w.Text()
+		if err != nil {
+			text = "ERR"
+		}
+		if text != last && update != nil {
+			update(text)
+		}
+		last = text
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetch returns the events in the widget's feed.
+func (w *Widget) fetch(ctx context.Context) ([]Event, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	cli := w.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendar request failed: %s", resp.Status)
+	}
+	return Parse(resp.Body, time.Local)
+}
+
+// Text returns the text for the next event in the most recently fetched
+// feed, or the error from the last fetch if it failed. It does not block
+// on the network.
+func (w *Widget) Text() (string, error) {
+	now := time.Now()
+	if w.now != nil {
+		now = w.now()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return "", w.err
+	}
+	if !w.fetched {
+		return "", ErrNoData
+	}
+	e, ok := Next(w.events, now)
+	if !ok {
+		return "No events", nil
+	}
+	return e.Text(now), nil
+}
@@ -0,0 +1,135 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package calendar
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const feed = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART:20230601T090000Z\r\n" +
+	"DTEND:20230601T093000Z\r\n" +
+	"SUMMARY:Standup\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20230602\r\n" +
+	"SUMMARY:Holiday\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;TZID=UTC:20230601T140000\r\n" +
+	"DTEND:20230601T150000Z\r\n" +
+	"SUMMARY:Design review\\, part 2 with a very long \r\n" +
+	" title\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+var events = []Event{
+	{
+		Summary: "Standup",
+		Start:   time.Date(2023, 6, 1, 9, 0, 0, 0, time.UTC),
+		End:     time.Date(2023, 6, 1, 9, 30, 0, 0, time.UTC),
+	},
+	{
+		Summary: "Design review, part 2 with a very long title",
+		Start:   time.Date(2023, 6, 1, 14, 0, 0, 0, time.UTC),
+		End:     time.Date(2023, 6, 1, 15, 0, 0, 0, time.UTC),
+	},
+	{
+		Summary: "Holiday",
+		Start:   time.Date(2023, 6, 2, 0, 0, 0, 0, time.UTC),
+		End:     time.Date(2023, 6, 3, 0, 0, 0, 0, time.UTC),
+		AllDay:  true,
+	},
+}
+
+func TestParse(t *testing.T) {
+	got, err := Parse(strings.NewReader(feed), time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != len(events) {
+		t.Fatalf("unexpected number of events: got:%d want:%d", len(got), len(events))
+	}
+	for i := range got {
+		if !got[i].Start.Equal(events[i].Start) || !got[i].End.Equal(events[i].End) ||
+			got[i].Summary != events[i].Summary || got[i].AllDay != events[i].AllDay {
+			t.Errorf("unexpected event %d:\ngot: %+v\nwant:%+v", i, got[i], events[i])
+		}
+	}
+
+	_, err = Parse(strings.NewReader("BEGIN:VEVENT\nDTSTART:tomorrow\nEND:VEVENT\n"), time.UTC)
+	if err == nil {
+		t.Error("expected error for invalid time")
+	}
+	_, err = Parse(strings.NewReader("BEGIN:VEVENT\nSUMMARY:never\nEND:VEVENT\n"), time.UTC)
+	if err == nil {
+		t.Error("expected error for event without start")
+	}
+}
+
+func TestNextText(t *testing.T) {
+	for _, test := range []struct {
+		now  time.Time
+		want string
+	}{
+		{now: time.Date(2023, 6, 1, 8, 0, 0, 0, time.UTC), want: "09:00\nStandup"},
+		{now: time.Date(2023, 6, 1, 9, 10, 0, 0, time.UTC), want: "Now\nStandup"},
+		{now: time.Date(2023, 6, 1, 9, 30, 0, 0, time.UTC), want: "14:00\nDesign review, part 2 with a very long title"},
+		{now: time.Date(2023, 5, 31, 9, 30, 0, 0, time.UTC), want: "Thu 09:00\nStandup"},
+		{now: time.Date(2023, 6, 1, 16, 0, 0, 0, time.UTC), want: "Fri 2\nHoliday"},
+		{now: time.Date(2023, 6, 2, 16, 0, 0, 0, time.UTC), want: "Now\nHoliday"},
+		{now: time.Date(2023, 6, 3, 0, 0, 0, 0, time.UTC), want: ""},
+	} {
+		e, ok := Next(events, test.now)
+		if ok != (test.want != "") {
+			t.Errorf("unexpected next event status at %v: got:%t", test.now, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got := e.Text(test.now); got != test.want {
+			t.Errorf("unexpected text at %v: got:%q want:%q", test.now, got, test.want)
+		}
+	}
+}
+
+func TestWidget(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Write([]byte(feed))
+	}))
+	defer srv.Close()
+
+	w := Widget{
+		URL: srv.URL,
+		now: func() time.Time { return time.Date(2023, 6, 1, 8, 0, 0, 0, time.UTC) },
+	}
+	_, err := w.Text()
+	if !errors.Is(err, ErrNoData) {
+		t.Errorf("unexpected error before update: got:%v want:%v", err, ErrNoData)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var updates []string
+	w.Run(ctx, func(text string) {
+		updates = append(updates, text)
+		cancel()
+	})
+	want := []string{"09:00\nStandup"}
+	if !reflect.DeepEqual(updates, want) {
+		t.Errorf("unexpected updates: got:%q want:%q", updates, want)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The ardilla-focus command switches Stream Deck layouts to follow the
+// focused application.
+//
+// Usage:
+//
+//	ardilla-focus [-serial <serial>] <profiles.json>
+//
+// The profiles file lists the layout to use for each application:
+//
+//	{
+//		"profiles": [
+//			{"app": "firefox", "layout": "browser.json"},
+//			{"app": "code", "layout": "editor.json"}
+//		],
+//		"default": "home.json"
+//	}
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/contrib/focus"
+)
+
+func main() {
+	os.Exit(Main())
+}
+
+func Main() int {
+	serial := flag.String("serial", "", "device serial number")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-serial <serial>] <profiles.json>\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return 2
+	}
+	p, err := focus.LoadProfiles(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load profiles: %v\n", err)
+		return 1
+	}
+
+	d, err := ardilla.NewDeck(ardilla.AnyPID, *serial)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	err = focus.Run(ctx, d, p, func(err error) {
+		fmt.Fprintf(os.Stderr, "failed to switch layout: %v\n", err)
+	})
+	if err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "failed to watch focus: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,158 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package focus switches Stream Deck layouts to follow the focused
+// application on Linux desktops.
+//
+// The focused window is found using the EWMH _NET_ACTIVE_WINDOW property
+// on X11, which requires the xprop program, or with the
+// wlr-foreign-toplevel-management protocol on Wayland compositors that
+// support it, such as sway and other wlroots-based compositors.
+package focus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/layout"
+)
+
+// Window is a focused application window.
+type Window struct {
+	// App identifies the application. On X11 it is the
+	// class of the window's WM_CLASS property and on
+	// Wayland it is the toplevel's app_id.
+	App string
+
+	// Title is the window title.
+	Title string
+}
+
+// ErrNoDisplay is returned by Watch when neither a Wayland nor an X11
+// display is available.
+var ErrNoDisplay = errors.New("no display")
+
+// Watch calls fn with the focused window each time the focus changes
+// until ctx is cancelled or watching fails. Wayland is used if the
+// WAYLAND_DISPLAY environment variable is set, otherwise X11 is used
+// if DISPLAY is set.
+func Watch(ctx context.Context, fn func(Window)) error {
+	switch {
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		return WatchWayland(ctx, fn)
+	case os.Getenv("DISPLAY") != "":
+		return WatchX11(ctx, fn)
+	default:
+		return ErrNoDisplay
+	}
+}
+
+// Profiles is a set of layouts selected by the focused application.
+type Profiles struct {
+	// Profiles is the list of application profiles.
+	// The first profile matching the focused window
+	// is used.
+	Profiles []Profile `json:"profiles"`
+
+	// Default is the layout used when no profile
+	// matches the focused window. If Default is
+	// empty, the deck is left unchanged.
+	Default string `json:"default,omitempty"`
+
+	// path is the file the profiles were loaded from.
+	path string
+}
+
+// Profile assigns a layout to an application.
+type Profile struct {
+	// App is the application to match, compared
+	// case-insensitively with Window.App.
+	App string `json:"app"`
+
+	// Layout is the path to the layout file.
+	// Relative paths are resolved relative to
+	// the directory holding the profiles file.
+	Layout string `json:"layout"`
+}
+
+// LoadProfiles reads the profiles held in the JSON file at path.
+func LoadProfiles(path string) (*Profiles, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Profiles
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, r := range p.Profiles {
+		if r.App == "" {
+			return nil, fmt.Errorf("%s: profile %d: missing app", path, i)
+		}
+		if r.Layout == "" {
+			return nil, fmt.Errorf("%s: profile %d: missing layout", path, i)
+		}
+	}
+	p.path = path
+	return &p, nil
+}
+
+// Layout returns the path to the layout for the window w. It returns
+// false if no profile matches and there is no default layout.
+func (p *Profiles) Layout(w Window) (path string, ok bool) {
+	for _, r := range p.Profiles {
+		if strings.EqualFold(r.App, w.App) {
+			return p.resolve(r.Layout), true
+		}
+	}
+	if p.Default == "" {
+		return "", false
+	}
+	return p.resolve(p.Default), true
+}
+
+// resolve returns the path to the named layout file, resolving relative
+// paths against the profiles file's directory.
+func (p *Profiles) resolve(name string) string {
+	if filepath.IsAbs(name) || p.path == "" {
+		return name
+	}
+	return filepath.Join(filepath.Dir(p.path), name)
+}
+
+// Run applies the layout selected by p to d each time the focused window
+// changes, until ctx is cancelled or watching the focus fails. The layout
+// is only applied when the selected layout file changes. Errors loading
+// or applying a layout are passed to errFn if it is not nil and do not
+// stop Run.
+func Run(ctx context.Context, d *ardilla.Deck, p *Profiles, errFn func(error)) error {
+	var current string
+	return Watch(ctx, func(w Window) {
+		path, ok := p.Layout(w)
+		if !ok || path == current {
+			return
+		}
+		l, err := layout.Load(path)
+		if err == nil {
+			err = l.Apply(d)
+		}
+		if err != nil {
+			if errFn != nil {
+				errFn(fmt.Errorf("%s: %w", w.App, err))
+			}
+			return
+		}
+		current = path
+	})
+}
@@ -0,0 +1,207 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package focus
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var activeWindowTests = []struct {
+	line   string
+	wantID string
+	wantOK bool
+}{
+	{line: "_NET_ACTIVE_WINDOW(WINDOW): window id # 0x3a00007", wantID: "0x3a00007", wantOK: true},
+	{line: "_NET_ACTIVE_WINDOW(WINDOW): window id # 0x3a00007, 0x0", wantID: "0x3a00007", wantOK: true},
+	{line: "_NET_ACTIVE_WINDOW(WINDOW): window id # 0x0", wantOK: false},
+	{line: "_NET_ACTIVE_WINDOW:  not found.", wantOK: false},
+}
+
+func TestParseActiveWindow(t *testing.T) {
+	for _, test := range activeWindowTests {
+		id, ok := parseActiveWindow(test.line)
+		if id != test.wantID || ok != test.wantOK {
+			t.Errorf("unexpected result for %q: got:%q %t want:%q %t", test.line, id, ok, test.wantID, test.wantOK)
+		}
+	}
+}
+
+var windowPropsTests = []struct {
+	out  string
+	want Window
+}{
+	{
+		out: `WM_CLASS(STRING) = "Navigator", "firefox"
+_NET_WM_NAME(UTF8_STRING) = "Stream Deck — \"Mozilla\" Firefox"
+WM_NAME(STRING) = "Stream Deck - Mozilla Firefox"
+`,
+		want: Window{App: "firefox", Title: `Stream Deck — "Mozilla" Firefox`},
+	},
+	{
+		out: `WM_CLASS(STRING) = "xterm", "XTerm"
+_NET_WM_NAME:  not found.
+WM_NAME(STRING) = "user@host: ~"
+`,
+		want: Window{App: "XTerm", Title: "user@host: ~"},
+	},
+	{
+		out:  "WM_CLASS:  not found.\n",
+		want: Window{},
+	},
+}
+
+func TestParseWindowProps(t *testing.T) {
+	for _, test := range windowPropsTests {
+		got := parseWindowProps(test.out)
+		if got != test.want {
+			t.Errorf("unexpected window for %q:\ngot: %+v\nwant:%+v", test.out, got, test.want)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "profiles.json")
+	err := os.WriteFile(path, []byte(`{
+	"profiles": [
+		{"app": "firefox", "layout": "browser.json"},
+		{"app": "code", "layout": "/abs/editor.json"}
+	],
+	"default": "home.json"
+}`), 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error writing profiles: %v", err)
+	}
+	p, err := LoadProfiles(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading profiles: %v", err)
+	}
+	for _, test := range []struct {
+		app  string
+		want string
+	}{
+		{app: "Firefox", want: filepath.Join(dir, "browser.json")},
+		{app: "code", want: "/abs/editor.json"},
+		{app: "xterm", want: filepath.Join(dir, "home.json")},
+	} {
+		got, ok := p.Layout(Window{App: test.app})
+		if !ok || got != test.want {
+			t.Errorf("unexpected layout for %q: got:%q %t want:%q true", test.app, got, ok, test.want)
+		}
+	}
+	p.Default = ""
+	if got, ok := p.Layout(Window{App: "xterm"}); ok {
+		t.Errorf("unexpected layout without default: %q", got)
+	}
+
+	err = os.WriteFile(path, []byte(`{"profiles": [{"app": "firefox"}]}`), 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error writing profiles: %v", err)
+	}
+	_, err = LoadProfiles(path)
+	if err == nil {
+		t.Error("expected error for profile without layout")
+	}
+}
+
+func TestWatchToplevels(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	var got []Window
+	done := make(chan error, 1)
+	go func() {
+		done <- watchToplevels(client, func(w Window) {
+			got = append(got, w)
+		})
+	}()
+
+	c := &wlConn{w: server, r: bufio.NewReader(server)}
+	expectRequest(t, c, displayID, displayGetRegistry)
+	expectRequest(t, c, displayID, displaySync)
+	c.request(registryID, registryGlobal, uint32(1), "wl_compositor", uint32(4))
+	c.request(registryID, registryGlobal, uint32(9), toplevelManager, uint32(3))
+	args := expectRequest(t, c, registryID, registryBind)
+	a := wlArgs{b: args}
+	if name, iface, version, id := a.uint(), a.string(), a.uint(), a.uint(); name != 9 || iface != toplevelManager || version != 3 || id != managerID {
+		t.Fatalf("unexpected bind: name=%d interface=%q version=%d id=%d", name, iface, version, id)
+	}
+	c.request(syncID, callbackDone, uint32(0))
+
+	const term, browser = 0xff000000, 0xff000001
+	c.request(managerID, managerToplevel, uint32(term))
+	c.request(term, handleAppID, "foot")
+	c.request(term, handleTitle, "shell")
+	c.request(term, handleDone)
+	c.request(managerID, managerToplevel, uint32(browser))
+	c.request(browser, handleAppID, "firefox")
+	c.request(browser, handleTitle, "home")
+	c.request(browser, handleState, []uint32{stateActivated})
+	c.request(browser, handleDone)
+	c.request(browser, handleTitle, "news")
+	c.request(browser, handleDone)
+	c.request(browser, handleState, []uint32{})
+	c.request(browser, handleDone)
+	c.request(term, handleState, []uint32{stateActivated})
+	c.request(term, handleDone)
+	c.request(term, handleClosed)
+	expectRequest(t, c, term, handleDestroy)
+	if c.err != nil {
+		t.Fatalf("unexpected error writing events: %v", c.err)
+	}
+	server.Close()
+
+	err := <-done
+	if err != io.EOF {
+		t.Errorf("unexpected error: got:%v want:%v", err, io.EOF)
+	}
+	want := []Window{
+		{App: "firefox", Title: "home"},
+		{App: "firefox", Title: "news"},
+		{App: "foot", Title: "shell"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected windows:\ngot: %+v\nwant:%+v", got, want)
+	}
+}
+
+func TestWatchToplevelsUnsupported(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- watchToplevels(client, func(Window) {})
+	}()
+	c := &wlConn{w: server, r: bufio.NewReader(server)}
+	expectRequest(t, c, displayID, displayGetRegistry)
+	expectRequest(t, c, displayID, displaySync)
+	c.request(registryID, registryGlobal, uint32(1), "wl_compositor", uint32(4))
+	c.request(syncID, callbackDone, uint32(0))
+	err := <-done
+	server.Close()
+	if err == nil {
+		t.Error("expected error for compositor without toplevel manager")
+	}
+}
+
+// expectRequest reads a request from the client and checks its object
+// and opcode, returning its arguments.
+func expectRequest(t *testing.T, c *wlConn, obj uint32, op uint16) []byte {
+	t.Helper()
+	gotObj, gotOp, args, err := c.event()
+	if err != nil {
+		t.Fatalf("unexpected error reading request: %v", err)
+	}
+	if gotObj != obj || gotOp != op {
+		t.Fatalf("unexpected request: got:%d.%d want:%d.%d", gotObj, gotOp, obj, op)
+	}
+	return args
+}
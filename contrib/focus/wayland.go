@@ -0,0 +1,336 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package focus
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"unsafe"
+)
+
+// WatchWayland calls fn with the focused Wayland toplevel each time the
+// activated toplevel changes, until ctx is cancelled or watching fails.
+// The compositor must support the wlr-foreign-toplevel-management
+// protocol.
+func WatchWayland(ctx context.Context, fn func(Window)) error {
+	name := os.Getenv("WAYLAND_DISPLAY")
+	if name == "" {
+		name = "wayland-0"
+	}
+	if !filepath.IsAbs(name) {
+		dir := os.Getenv("XDG_RUNTIME_DIR")
+		if dir == "" {
+			return errors.New("XDG_RUNTIME_DIR not set")
+		}
+		name = filepath.Join(dir, name)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", name)
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+	err = watchToplevels(conn, fn)
+	conn.Close()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Wayland object IDs and opcodes used by watchToplevels.
+const (
+	displayID  = 1
+	registryID = 2
+	syncID     = 3
+	managerID  = 4
+
+	// wl_display requests and events.
+	displaySync        = 0
+	displayGetRegistry = 1
+	displayError       = 0
+
+	// wl_registry requests and events.
+	registryBind   = 0
+	registryGlobal = 0
+
+	// wl_callback events.
+	callbackDone = 0
+
+	// zwlr_foreign_toplevel_manager_v1 events.
+	managerToplevel = 0
+	managerFinished = 1
+
+	// zwlr_foreign_toplevel_handle_v1 requests and events.
+	handleDestroy = 7
+	handleTitle   = 0
+	handleAppID   = 1
+	handleState   = 4
+	handleDone    = 5
+	handleClosed  = 6
+
+	// stateActivated is the activated toplevel state.
+	stateActivated = 2
+
+	toplevelManager = "zwlr_foreign_toplevel_manager_v1"
+	managerVersion  = 3
+)
+
+// toplevel is the state of a foreign toplevel handle.
+type toplevel struct {
+	pending   Window
+	window    Window
+	activated bool
+}
+
+// watchToplevels runs the Wayland client protocol on conn, calling fn
+// when the activated toplevel or its application changes.
+func watchToplevels(conn io.ReadWriter, fn func(Window)) error {
+	c := &wlConn{w: conn, r: bufio.NewReader(conn)}
+	c.request(displayID, displayGetRegistry, uint32(registryID))
+	c.request(displayID, displaySync, uint32(syncID))
+	if c.err != nil {
+		return c.err
+	}
+
+	var (
+		bound    bool
+		handles  = make(map[uint32]*toplevel)
+		focused  uint32
+		reported Window
+	)
+	for {
+		obj, op, args, err := c.event()
+		if err != nil {
+			return err
+		}
+		switch obj {
+		case displayID:
+			if op == displayError {
+				a := wlArgs{b: args}
+				id, code, msg := a.uint(), a.uint(), a.string()
+				return fmt.Errorf("wayland error on object %d: code %d: %s", id, code, msg)
+			}
+		case registryID:
+			if op != registryGlobal || bound {
+				continue
+			}
+			a := wlArgs{b: args}
+			name, iface, version := a.uint(), a.string(), a.uint()
+			if a.err != nil {
+				return a.err
+			}
+			if iface != toplevelManager {
+				continue
+			}
+			if version > managerVersion {
+				version = managerVersion
+			}
+			c.request(registryID, registryBind, name, iface, version, uint32(managerID))
+			if c.err != nil {
+				return c.err
+			}
+			bound = true
+		case syncID:
+			if op == callbackDone && !bound {
+				return fmt.Errorf("compositor does not support %s", toplevelManager)
+			}
+		case managerID:
+			switch op {
+			case managerToplevel:
+				a := wlArgs{b: args}
+				id := a.uint()
+				if a.err != nil {
+					return a.err
+				}
+				handles[id] = &toplevel{}
+			case managerFinished:
+				return errors.New("toplevel manager finished")
+			}
+		default:
+			t, ok := handles[obj]
+			if !ok {
+				continue
+			}
+			a := wlArgs{b: args}
+			switch op {
+			case handleTitle:
+				t.pending.Title = a.string()
+			case handleAppID:
+				t.pending.App = a.string()
+			case handleState:
+				t.activated = false
+				for _, s := range a.array() {
+					if s == stateActivated {
+						t.activated = true
+					}
+				}
+			case handleDone:
+				t.window = t.pending
+				if t.activated {
+					focused = obj
+				} else if focused == obj {
+					focused = 0
+				}
+				if focused == obj && t.window != reported {
+					reported = t.window
+					fn(t.window)
+				}
+			case handleClosed:
+				delete(handles, obj)
+				if focused == obj {
+					focused = 0
+				}
+				c.request(obj, handleDestroy)
+				if c.err != nil {
+					return c.err
+				}
+			}
+			if a.err != nil {
+				return a.err
+			}
+		}
+	}
+}
+
+// native is the host byte order used by the Wayland wire protocol.
+var native interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+} = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		native = binary.BigEndian
+	}
+}
+
+// wlConn is a Wayland wire protocol connection.
+type wlConn struct {
+	w   io.Writer
+	r   *bufio.Reader
+	err error
+}
+
+// request sends a request with the given arguments, which must be uint32,
+// string or []uint32 array values. Any error is held in c.err.
+func (c *wlConn) request(obj uint32, op uint16, args ...interface{}) {
+	if c.err != nil {
+		return
+	}
+	msg := make([]byte, 8, 64)
+	for _, a := range args {
+		switch a := a.(type) {
+		case uint32:
+			msg = native.AppendUint32(msg, a)
+		case string:
+			n := len(a) + 1
+			msg = native.AppendUint32(msg, uint32(n))
+			msg = append(msg, a...)
+			msg = append(msg, make([]byte, pad(n)-len(a))...)
+		case []uint32:
+			msg = native.AppendUint32(msg, uint32(4*len(a)))
+			for _, v := range a {
+				msg = native.AppendUint32(msg, v)
+			}
+		default:
+			panic(fmt.Sprintf("invalid wayland argument type: %T", a))
+		}
+	}
+	native.PutUint32(msg, obj)
+	native.PutUint32(msg[4:], uint32(len(msg))<<16|uint32(op))
+	_, c.err = c.w.Write(msg)
+}
+
+// event reads an event from the connection.
+func (c *wlConn) event() (obj uint32, op uint16, args []byte, err error) {
+	var hdr [8]byte
+	_, err = io.ReadFull(c.r, hdr[:])
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	obj = native.Uint32(hdr[:])
+	sizeOp := native.Uint32(hdr[4:])
+	size := int(sizeOp >> 16)
+	if size < len(hdr) {
+		return 0, 0, nil, fmt.Errorf("invalid wayland message size: %d", size)
+	}
+	args = make([]byte, size-len(hdr))
+	_, err = io.ReadFull(c.r, args)
+	return obj, uint16(sizeOp), args, err
+}
+
+// wlArgs decodes Wayland event arguments.
+type wlArgs struct {
+	b   []byte
+	err error
+}
+
+func (a *wlArgs) uint() uint32 {
+	if a.err != nil {
+		return 0
+	}
+	if len(a.b) < 4 {
+		a.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := native.Uint32(a.b)
+	a.b = a.b[4:]
+	return v
+}
+
+// bytes returns the next length-prefixed, padded argument.
+func (a *wlArgs) bytes() []byte {
+	n := int(a.uint())
+	if a.err != nil {
+		return nil
+	}
+	if len(a.b) < pad(n) {
+		a.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := a.b[:n]
+	a.b = a.b[pad(n):]
+	return b
+}
+
+func (a *wlArgs) string() string {
+	b := a.bytes()
+	if len(b) == 0 {
+		return ""
+	}
+	// Strings include their NUL terminator.
+	return string(b[:len(b)-1])
+}
+
+func (a *wlArgs) array() []uint32 {
+	b := a.bytes()
+	v := make([]uint32, len(b)/4)
+	for i := range v {
+		v[i] = native.Uint32(b[4*i:])
+	}
+	return v
+}
+
+// pad returns n rounded up to a multiple of four.
+func pad(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,141 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package focus
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// WatchX11 calls fn with the focused X11 window each time the EWMH active
+// window changes, until ctx is cancelled or watching fails. WatchX11 uses
+// the xprop program, which must be installed.
+func WatchX11(ctx context.Context, fn func(Window)) error {
+	cmd := exec.CommandContext(ctx, "xprop", "-root", "-spy", "_NET_ACTIVE_WINDOW")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	var last string
+	sc := bufio.NewScanner(out)
+	for sc.Scan() {
+		id, ok := parseActiveWindow(sc.Text())
+		if !ok || id == last {
+			continue
+		}
+		last = id
+		w, err := x11Window(ctx, id)
+		if err != nil {
+			// The window may have been destroyed
+			// since it was focused.
+			continue
+		}
+		fn(w)
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err == nil {
+		err = errors.New("xprop exited")
+	}
+	return err
+}
+
+// x11Window returns the application and title of the window with the
+// given id.
+func x11Window(ctx context.Context, id string) (Window, error) {
+	out, err := exec.CommandContext(ctx, "xprop", "-id", id, "WM_CLASS", "_NET_WM_NAME", "WM_NAME").Output()
+	if err != nil {
+		return Window{}, err
+	}
+	return parseWindowProps(string(out)), nil
+}
+
+// parseActiveWindow returns the window id in an xprop _NET_ACTIVE_WINDOW
+// line, for example
+//
+//	_NET_ACTIVE_WINDOW(WINDOW): window id # 0x3a00007
+//
+// It returns false if there is no active window.
+func parseActiveWindow(line string) (id string, ok bool) {
+	_, id, ok = strings.Cut(line, "#")
+	if !ok {
+		return "", false
+	}
+	id = strings.TrimSpace(id)
+	if i := strings.IndexByte(id, ','); i >= 0 {
+		id = id[:i]
+	}
+	n, err := strconv.ParseUint(id, 0, 32)
+	if err != nil || n == 0 {
+		return "", false
+	}
+	return id, true
+}
+
+// parseWindowProps returns the window described by the output of xprop
+// for the WM_CLASS, _NET_WM_NAME and WM_NAME properties. The application
+// is the class, the second string of WM_CLASS.
+func parseWindowProps(out string) Window {
+	var w Window
+	var name string
+	for _, line := range strings.Split(out, "\n") {
+		prop, val, ok := strings.Cut(line, " = ")
+		if !ok {
+			continue
+		}
+		prop, _, _ = strings.Cut(prop, "(")
+		switch prop {
+		case "WM_CLASS":
+			s := xpropStrings(val)
+			if len(s) != 0 {
+				w.App = s[len(s)-1]
+			}
+		case "_NET_WM_NAME":
+			if s := xpropStrings(val); len(s) != 0 {
+				w.Title = s[0]
+			}
+		case "WM_NAME":
+			if s := xpropStrings(val); len(s) != 0 {
+				name = s[0]
+			}
+		}
+	}
+	if w.Title == "" {
+		w.Title = name
+	}
+	return w
+}
+
+// xpropStrings returns the quoted strings in an xprop property value.
+func xpropStrings(val string) []string {
+	var s []string
+	for {
+		i := strings.IndexByte(val, '"')
+		if i < 0 {
+			return s
+		}
+		val = val[i:]
+		q, err := strconv.QuotedPrefix(val)
+		if err != nil {
+			return s
+		}
+		u, err := strconv.Unquote(q)
+		if err != nil {
+			return s
+		}
+		s = append(s, u)
+		val = val[len(q):]
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+// The ardilla-homeassistant command shows Home Assistant entities on
+// Stream Deck keys with their live state, toggling lights, switches and
+// other toggleable entities when their key is pressed.
+//
+// Usage:
+//
+//	ardilla-homeassistant [-serial <serial>] [-url <url>] -token <ref> <row>,<col>=<entity>...
+//
+// Each argument binds a key to an entity ID, for example
+// 0,0=light.kitchen. The long-lived access token is given as a secret
+// reference, for example env:HASS_TOKEN or keyring:home-assistant/ardilla,
+// as described in the secret package.
+//
+// ardilla-homeassistant requires Go 1.23.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/action"
+	"github.com/kortschak/ardilla/contrib/homeassistant"
+	"github.com/kortschak/ardilla/secret"
+)
+
+func main() {
+	os.Exit(Main())
+}
+
+func Main() int {
+	serial := flag.String("serial", "", "device serial number")
+	url := flag.String("url", homeassistant.DefaultURL, "Home Assistant WebSocket API URL")
+	tokenRef := flag.String("token", "", "secret reference for the long-lived access token")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-serial <serial>] [-url <url>] -token <ref> <row>,<col>=<entity>...\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *tokenRef == "" {
+		flag.Usage()
+		return 2
+	}
+	type binding struct {
+		row, col int
+		entity   string
+	}
+	var bindings []binding
+	for _, arg := range flag.Args() {
+		pos, entity, ok := strings.Cut(arg, "=")
+		var r, c int
+		_, err := fmt.Sscanf(pos, "%d,%d", &r, &c)
+		if !ok || err != nil || !strings.Contains(entity, ".") {
+			fmt.Fprintf(os.Stderr, "invalid entity binding %q\n", arg)
+			return 2
+		}
+		bindings = append(bindings, binding{row: r, col: c, entity: entity})
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	token, err := secret.Lookup(ctx, *tokenRef)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get token: %v\n", err)
+		return 1
+	}
+
+	d, err := ardilla.NewDeck(ardilla.AnyPID, *serial)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+
+	disp := action.New(d)
+	defer disp.Close()
+	disp.SetErrorHandler(func(ev ardilla.KeyEvent, err error) {
+		fmt.Fprintf(os.Stderr, "failed to toggle entity on key %d,%d: %v\n", ev.Row, ev.Col, err)
+	})
+	tiles := make([]*homeassistant.Tile, 0, len(bindings))
+	for _, b := range bindings {
+		t, err := homeassistant.NewTile(d, b.row, b.col, b.entity)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create tile: %v\n", err)
+			return 1
+		}
+		err = disp.Bind(b.row, b.col, action.Binding{Action: t, Exclusive: true})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to bind %s: %v\n", b.entity, err)
+			return 1
+		}
+		tiles = append(tiles, t)
+	}
+
+	go func() {
+		err := disp.Run(ctx)
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "failed to read key events: %v\n", err)
+			cancel()
+		}
+	}()
+	err = homeassistant.Run(ctx, *url, token, tiles, func(err error) {
+		fmt.Fprintf(os.Stderr, "failed to draw tile: %v\n", err)
+	})
+	if err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "home assistant connection failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
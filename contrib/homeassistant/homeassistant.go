@@ -0,0 +1,448 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package homeassistant provides Stream Deck keys showing the live state
+// of Home Assistant entities, such as lights, switches and sensors, and
+// toggling them when pressed.
+//
+// Entities are followed and controlled over the Home Assistant WebSocket
+// API, authenticated with a long-lived access token. A Tile is an
+// action.Stateful action, so tiles are bound to keys with an
+// action.Dispatcher, and are redrawn by Run when their entity changes.
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+
+	"github.com/kortschak/ardilla/action"
+	"github.com/kortschak/ardilla/internal/websocket"
+	"github.com/kortschak/ardilla/label"
+)
+
+// DefaultURL is the WebSocket API address of a Home Assistant instance
+// found by mDNS.
+const DefaultURL = "ws://homeassistant.local:8123/api/websocket"
+
+// State is the state of an entity.
+type State struct {
+	EntityID   string         `json:"entity_id"`
+	State      string         `json:"state"`
+	Attributes map[string]any `json:"attributes"`
+}
+
+// Domain returns the domain of the entity, for example "light".
+func (s State) Domain() string {
+	domain, _, _ := strings.Cut(s.EntityID, ".")
+	return domain
+}
+
+// Name returns the friendly name of the entity, or its ID if it has no
+// friendly name.
+func (s State) Name() string {
+	if name, ok := s.Attributes["friendly_name"].(string); ok && name != "" {
+		return name
+	}
+	return s.EntityID
+}
+
+// Text returns the entity's state with its unit of measurement.
+func (s State) Text() string {
+	if unit, ok := s.Attributes["unit_of_measurement"].(string); ok && unit != "" {
+		return s.State + " " + unit
+	}
+	return s.State
+}
+
+// toggleable is the set of domains toggled by a press.
+var toggleable = map[string]bool{
+	"automation":    true,
+	"fan":           true,
+	"input_boolean": true,
+	"light":         true,
+	"switch":        true,
+}
+
+// Toggleable returns whether the entity is toggled when its tile is
+// pressed.
+func (s State) Toggleable() bool {
+	return toggleable[s.Domain()]
+}
+
+// message is a Home Assistant WebSocket API message.
+type message struct {
+	ID      uint64          `json:"id"`
+	Type    string          `json:"type"`
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Event *struct {
+		Type string `json:"event_type"`
+		Data struct {
+			NewState *State `json:"new_state"`
+		} `json:"data"`
+	} `json:"event"`
+}
+
+// Client is a connection to the Home Assistant WebSocket API.
+type Client struct {
+	conn    *websocket.Conn
+	changed func(State)
+
+	mu      sync.Mutex
+	id      uint64
+	pending map[uint64]chan message
+	err     error
+	done    chan struct{}
+}
+
+// Dial connects to the Home Assistant WebSocket API at url and
+// authenticates with the access token. If changed is not nil, the client
+// subscribes to state changes and passes each changed state to changed
+// from the client's receive goroutine; changed must not block on requests
+// to the client.
+func Dial(ctx context.Context, url, token string, changed func(State)) (*Client, error) {
+	conn, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = authenticate(conn, token)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := &Client{
+		conn:    conn,
+		changed: changed,
+		pending: make(map[uint64]chan message),
+		done:    make(chan struct{}),
+	}
+	go c.receive()
+	if changed != nil {
+		err = c.command(ctx, map[string]any{"type": "subscribe_events", "event_type": "state_changed"}, nil)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// authenticate completes the WebSocket API authentication phase on conn.
+func authenticate(conn *websocket.Conn, token string) error {
+	var msg message
+	err := conn.ReadJSON(&msg)
+	if err != nil {
+		return err
+	}
+	if msg.Type != "auth_required" {
+		return fmt.Errorf("homeassistant: unexpected message during authentication: %q", msg.Type)
+	}
+	err = conn.WriteJSON(map[string]string{"type": "auth", "access_token": token})
+	if err != nil {
+		return err
+	}
+	msg = message{}
+	err = conn.ReadJSON(&msg)
+	if err != nil {
+		return err
+	}
+	switch msg.Type {
+	case "auth_ok":
+		return nil
+	case "auth_invalid":
+		return errors.New("homeassistant: authentication failed")
+	default:
+		return fmt.Errorf("homeassistant: unexpected message during authentication: %q", msg.Type)
+	}
+}
+
+// receive dispatches results and events until the connection fails.
+func (c *Client) receive() {
+	var err error
+	for {
+		var msg message
+		err = c.conn.ReadJSON(&msg)
+		if err != nil {
+			break
+		}
+		switch msg.Type {
+		case "event":
+			if c.changed != nil && msg.Event != nil && msg.Event.Type == "state_changed" && msg.Event.Data.NewState != nil {
+				c.changed(*msg.Event.Data.NewState)
+			}
+		case "result":
+			c.mu.Lock()
+			ch, ok := c.pending[msg.ID]
+			delete(c.pending, msg.ID)
+			c.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		}
+	}
+	c.mu.Lock()
+	c.err = err
+	c.pending = nil
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// Done returns a channel that is closed when the connection is lost or
+// closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error that ended the connection, or nil if it is still
+// open.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// command sends the command cmd, adding its message ID, and decodes the
+// result into result if it is not nil.
+func (c *Client) command(ctx context.Context, cmd map[string]any, result any) error {
+	c.mu.Lock()
+	if c.pending == nil {
+		err := c.err
+		c.mu.Unlock()
+		return fmt.Errorf("homeassistant: connection closed: %w", err)
+	}
+	c.id++
+	id := c.id
+	ch := make(chan message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	cmd["id"] = id
+	err := c.conn.WriteJSON(cmd)
+	if err != nil {
+		c.forget(id)
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		c.forget(id)
+		return ctx.Err()
+	case <-c.done:
+		return fmt.Errorf("homeassistant: connection closed: %w", c.Err())
+	case msg := <-ch:
+		if !msg.Success {
+			if msg.Error != nil {
+				return fmt.Errorf("homeassistant: %s failed: %s (%s)", cmd["type"], msg.Error.Message, msg.Error.Code)
+			}
+			return fmt.Errorf("homeassistant: %s failed", cmd["type"])
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	}
+}
+
+// forget removes the pending command with the given ID.
+func (c *Client) forget(id uint64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// States returns the states of all entities.
+func (c *Client) States(ctx context.Context) ([]State, error) {
+	var states []State
+	err := c.command(ctx, map[string]any{"type": "get_states"}, &states)
+	return states, err
+}
+
+// Toggle toggles the entity with the given ID.
+func (c *Client) Toggle(ctx context.Context, entity string) error {
+	return c.command(ctx, map[string]any{
+		"type":    "call_service",
+		"domain":  "homeassistant",
+		"service": "toggle",
+		"target":  map[string]string{"entity_id": entity},
+	}, nil)
+}
+
+// Deck is the set of deck methods used by a Tile. It is satisfied by
+// *ardilla.Deck.
+type Deck interface {
+	Bounds() (image.Rectangle, error)
+	SetImage(row, col int, img image.Image) error
+}
+
+// Tile is a key showing the state of an entity. It is an action.Stateful
+// action that toggles the entity when pressed, if the entity's domain is
+// toggleable. The tile's fields must not be altered after it is passed to
+// Run.
+type Tile struct {
+	// Entity is the ID of the entity, for
+	// example "light.kitchen".
+	Entity string
+
+	// Size is the font size of the tile's
+	// text in pixels. If Size is zero, 14
+	// is used.
+	Size float64
+
+	deck     Deck
+	row, col int
+	key      image.Rectangle
+
+	mu     sync.Mutex
+	client *Client
+	state  *State
+}
+
+var _ action.Stateful = (*Tile)(nil)
+
+// NewTile returns a Tile for the entity drawn on the key of d at row and
+// col. The tile must also be bound to the key with an action.Dispatcher to
+// handle presses.
+func NewTile(d Deck, row, col int, entity string) (*Tile, error) {
+	key, err := d.Bounds()
+	if err != nil {
+		return nil, err
+	}
+	return &Tile{Entity: entity, deck: d, row: row, col: col, key: key}, nil
+}
+
+// Tile colours.
+var (
+	tileOn          = color.RGBA{R: 0xc0, G: 0x80, A: 0xff}
+	tileUnavailable = color.RGBA{R: 0x40, G: 0x40, B: 0x40, A: 0xff}
+)
+
+// Do toggles the tile's entity if it is toggleable. It implements the
+// action.Action interface.
+func (t *Tile) Do(ctx context.Context, _ action.Press) error {
+	t.mu.Lock()
+	c, state := t.client, t.state
+	t.mu.Unlock()
+	if c == nil {
+		return errors.New("homeassistant: not connected")
+	}
+	if state == nil || !state.Toggleable() {
+		return nil
+	}
+	return c.Toggle(ctx, t.Entity)
+}
+
+// Image returns the image of the entity's current state. It implements
+// the action.Stateful interface.
+func (t *Tile) Image() image.Image {
+	img, err := t.render()
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// render returns the image of the entity's current state.
+func (t *Tile) render() (image.Image, error) {
+	t.mu.Lock()
+	state := t.state
+	t.mu.Unlock()
+
+	text := t.Entity
+	var bg color.Color = tileUnavailable
+	if state != nil {
+		text = state.Name() + "\n" + state.Text()
+		switch state.State {
+		case "on", "open", "home", "playing":
+			bg = tileOn
+		case "unavailable", "unknown":
+			bg = tileUnavailable
+		default:
+			bg = color.Black
+		}
+	}
+	size := t.Size
+	if size == 0 {
+		size = 14
+	}
+	return label.Render(t.key, text, size, color.White, bg)
+}
+
+// update sets the tile's state and redraws its key.
+func (t *Tile) update(s State) error {
+	t.mu.Lock()
+	t.state = &s
+	t.mu.Unlock()
+	img, err := t.render()
+	if err != nil {
+		return err
+	}
+	return t.deck.SetImage(t.row, t.col, img)
+}
+
+// Run connects to the Home Assistant WebSocket API at url, authenticating
+// with the access token, and redraws the tiles as the states of their
+// entities change, until ctx is cancelled or the connection is lost.
+// Rendering errors are passed to errFn if it is not nil.
+func Run(ctx context.Context, url, token string, tiles []*Tile, errFn func(error)) error {
+	report := func(err error) {
+		if err != nil && errFn != nil {
+			errFn(err)
+		}
+	}
+	byEntity := make(map[string][]*Tile)
+	for _, t := range tiles {
+		byEntity[t.Entity] = append(byEntity[t.Entity], t)
+	}
+	c, err := Dial(ctx, url, token, func(s State) {
+		for _, t := range byEntity[s.EntityID] {
+			report(t.update(s))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	states, err := c.States(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range tiles {
+		t.mu.Lock()
+		t.client = c
+		t.mu.Unlock()
+	}
+	for _, s := range states {
+		for _, t := range byEntity[s.EntityID] {
+			report(t.update(s))
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-c.Done():
+		err = c.Err()
+	}
+	for _, t := range tiles {
+		t.mu.Lock()
+		t.client = nil
+		t.mu.Unlock()
+	}
+	return err
+}
@@ -0,0 +1,303 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package homeassistant
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla/action"
+	"github.com/kortschak/ardilla/internal/websocket"
+)
+
+var stateTextTests = []struct {
+	state      State
+	name, text string
+	toggleable bool
+}{
+	{
+		state: State{EntityID: "light.kitchen", State: "on", Attributes: map[string]any{"friendly_name": "Kitchen"}},
+		name:  "Kitchen", text: "on", toggleable: true,
+	},
+	{
+		state: State{EntityID: "sensor.outside", State: "12.5", Attributes: map[string]any{"unit_of_measurement": "°C"}},
+		name:  "sensor.outside", text: "12.5 °C", toggleable: false,
+	},
+	{
+		state: State{EntityID: "switch.fan", State: "off"},
+		name:  "switch.fan", text: "off", toggleable: true,
+	},
+}
+
+func TestStateText(t *testing.T) {
+	for _, test := range stateTextTests {
+		if got := test.state.Name(); got != test.name {
+			t.Errorf("unexpected name for %s: got:%q want:%q", test.state.EntityID, got, test.name)
+		}
+		if got := test.state.Text(); got != test.text {
+			t.Errorf("unexpected text for %s: got:%q want:%q", test.state.EntityID, got, test.text)
+		}
+		if got := test.state.Toggleable(); got != test.toggleable {
+			t.Errorf("unexpected toggleable for %s: got:%t want:%t", test.state.EntityID, got, test.toggleable)
+		}
+	}
+}
+
+// fakeHA is a Home Assistant WebSocket API server holding a set of
+// entity states.
+type fakeHA struct {
+	t     *testing.T
+	token string
+
+	mu     sync.Mutex
+	states map[string]State
+}
+
+func (s *fakeHA) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c, err := websocket.Upgrade(w, req)
+	if err != nil {
+		s.t.Errorf("failed to upgrade: %v", err)
+		return
+	}
+	defer c.Close()
+
+	s.send(c, map[string]any{"type": "auth_required"})
+	var auth struct {
+		Token string `json:"access_token"`
+	}
+	err = c.ReadJSON(&auth)
+	if err != nil {
+		return
+	}
+	if auth.Token != s.token {
+		s.send(c, map[string]any{"type": "auth_invalid", "message": "invalid token"})
+		return
+	}
+	s.send(c, map[string]any{"type": "auth_ok"})
+
+	subscription := uint64(0)
+	for {
+		var cmd struct {
+			ID      uint64 `json:"id"`
+			Type    string `json:"type"`
+			Domain  string `json:"domain"`
+			Service string `json:"service"`
+			Target  struct {
+				Entity string `json:"entity_id"`
+			} `json:"target"`
+		}
+		err = c.ReadJSON(&cmd)
+		if err != nil {
+			return
+		}
+		result := map[string]any{"id": cmd.ID, "type": "result", "success": true, "result": nil}
+		var changed *State
+		s.mu.Lock()
+		switch cmd.Type {
+		case "subscribe_events":
+			subscription = cmd.ID
+		case "get_states":
+			var states []State
+			for _, st := range s.states {
+				states = append(states, st)
+			}
+			result["result"] = states
+		case "call_service":
+			st, ok := s.states[cmd.Target.Entity]
+			if cmd.Domain != "homeassistant" || cmd.Service != "toggle" || !ok {
+				result["success"] = false
+				result["error"] = map[string]string{"code": "not_found", "message": "service not found"}
+				break
+			}
+			if st.State == "on" {
+				st.State = "off"
+			} else {
+				st.State = "on"
+			}
+			s.states[st.EntityID] = st
+			changed = &st
+		default:
+			result["success"] = false
+			result["error"] = map[string]string{"code": "unknown_command", "message": "unknown command"}
+		}
+		s.mu.Unlock()
+		s.send(c, result)
+		if changed != nil && subscription != 0 {
+			s.send(c, map[string]any{
+				"id":   subscription,
+				"type": "event",
+				"event": map[string]any{
+					"event_type": "state_changed",
+					"data":       map[string]any{"entity_id": changed.EntityID, "new_state": changed},
+				},
+			})
+		}
+	}
+}
+
+func (s *fakeHA) send(c *websocket.Conn, msg any) {
+	err := c.WriteJSON(msg)
+	if err != nil {
+		s.t.Errorf("failed to send message: %v", err)
+	}
+}
+
+func serve(t *testing.T, s *fakeHA) string {
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/websocket"
+}
+
+func newFakeHA(t *testing.T) *fakeHA {
+	return &fakeHA{t: t, token: "token", states: map[string]State{
+		"light.kitchen":  {EntityID: "light.kitchen", State: "off", Attributes: map[string]any{"friendly_name": "Kitchen"}},
+		"sensor.outside": {EntityID: "sensor.outside", State: "12.5", Attributes: map[string]any{"unit_of_measurement": "°C"}},
+	}}
+}
+
+func TestClient(t *testing.T) {
+	u := serve(t, newFakeHA(t))
+	ctx := context.Background()
+
+	_, err := Dial(ctx, u, "wrong", nil)
+	if err == nil {
+		t.Error("expected error for invalid token")
+	}
+
+	changes := make(chan State, 10)
+	c, err := Dial(ctx, u, "token", func(s State) { changes <- s })
+	if err != nil {
+		t.Fatalf("unexpected error dialing: %v", err)
+	}
+	defer c.Close()
+	states, err := c.States(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error getting states: %v", err)
+	}
+	if len(states) != 2 {
+		t.Errorf("unexpected number of states: got:%d want:2", len(states))
+	}
+	err = c.Toggle(ctx, "light.kitchen")
+	if err != nil {
+		t.Errorf("unexpected error toggling: %v", err)
+	}
+	select {
+	case s := <-changes:
+		if s.EntityID != "light.kitchen" || s.State != "on" {
+			t.Errorf("unexpected state change: %+v", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("timed out waiting for state change")
+	}
+	err = c.Toggle(ctx, "light.missing")
+	if err == nil {
+		t.Error("expected error toggling missing entity")
+	}
+}
+
+// testDeck is a 3×5 deck of 72×72 keys that records the images set on it.
+type testDeck struct {
+	mu     sync.Mutex
+	images map[[2]int]image.Image
+}
+
+func (d *testDeck) Bounds() (image.Rectangle, error) {
+	return image.Rect(0, 0, 72, 72), nil
+}
+
+func (d *testDeck) SetImage(row, col int, img image.Image) error {
+	if row < 0 || 3 <= row || col < 0 || 5 <= col {
+		return errors.New("key out of bounds")
+	}
+	d.mu.Lock()
+	d.images[[2]int{row, col}] = img
+	d.mu.Unlock()
+	return nil
+}
+
+// background returns the colour of the corner of the key's image.
+func (d *testDeck) background(row, col int) color.Color {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img, ok := d.images[[2]int{row, col}]
+	if !ok {
+		return nil
+	}
+	return color.RGBAModel.Convert(img.At(0, 0))
+}
+
+func TestTiles(t *testing.T) {
+	u := serve(t, newFakeHA(t))
+	d := &testDeck{images: make(map[[2]int]image.Image)}
+	light, err := NewTile(d, 0, 0, "light.kitchen")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sensor, err := NewTile(d, 0, 1, "sensor.outside")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if img := light.Image(); img == nil || color.RGBAModel.Convert(img.At(0, 0)) != tileUnavailable {
+		t.Error("unexpected image for tile without state")
+	}
+	err = light.Do(context.Background(), action.Press{})
+	if err == nil {
+		t.Error("expected error for press while disconnected")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, u, "token", []*Tile{light, sensor}, func(err error) {
+			t.Errorf("unexpected render error: %v", err)
+		})
+	}()
+
+	black := color.RGBA{A: 0xff}
+	waitFor := func(what string, row, col int, want color.Color) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if d.background(row, col) == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %s: got:%v want:%v", what, d.background(row, col), want)
+	}
+	waitFor("light off", 0, 0, black)
+	waitFor("sensor", 0, 1, black)
+
+	err = light.Do(ctx, action.Press{})
+	if err != nil {
+		t.Errorf("unexpected error pressing light: %v", err)
+	}
+	waitFor("light on", 0, 0, tileOn)
+	if img := light.Image(); img == nil || color.RGBAModel.Convert(img.At(0, 0)) != tileOn {
+		t.Error("unexpected stateful image for light")
+	}
+	err = sensor.Do(ctx, action.Press{})
+	if err != nil {
+		t.Errorf("unexpected error pressing sensor: %v", err)
+	}
+
+	cancel()
+	select {
+	case err = <-done:
+		if err != context.Canceled {
+			t.Errorf("unexpected error from Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Run to return")
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The ardilla-mpris command shows the track playing in an MPRIS media
+// player on a block of Stream Deck keys, with keys for playback control.
+// It requires the playerctl program.
+//
+// Usage:
+//
+//	ardilla-mpris [-serial <serial>] [-player <name>] [-block <row>,<col>,<rows>,<cols>] [-controls <row>,<col>]
+//
+// The previous, play/pause and next controls are placed on three adjacent
+// keys starting at the -controls key. The block is drawn with the gap
+// between keys given by the device's geometry when it is known.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/contrib/mpris"
+)
+
+func main() {
+	os.Exit(Main())
+}
+
+func Main() int {
+	serial := flag.String("serial", "", "device serial number")
+	player := flag.String("player", "", "player to follow (default chosen by playerctl)")
+	block := flag.String("block", "0,0,2,2", "row,col,rows,cols of the track display")
+	controls := flag.String("controls", "2,0", "row,col of the first playback control key (none if empty)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-serial <serial>] [-player <name>] [-block <row>,<col>,<rows>,<cols>] [-controls <row>,<col>]\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		return 2
+	}
+	var row, col, rows, cols int
+	_, err := fmt.Sscanf(*block, "%d,%d,%d,%d", &row, &col, &rows, &cols)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid block %q: %v\n", *block, err)
+		return 2
+	}
+	var buttons map[[2]int]mpris.Action
+	if *controls != "" {
+		var r, c int
+		_, err = fmt.Sscanf(*controls, "%d,%d", &r, &c)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid controls %q: %v\n", *controls, err)
+			return 2
+		}
+		buttons = map[[2]int]mpris.Action{
+			{r, c}:     mpris.Previous,
+			{r, c + 1}: mpris.PlayPause,
+			{r, c + 2}: mpris.Next,
+		}
+	}
+
+	d, err := ardilla.NewDeck(ardilla.AnyPID, *serial)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+	gap, err := d.CanvasGap()
+	if err != nil {
+		gap = 0
+	}
+	w, err := mpris.New(d, row, col, rows, cols, gap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create widget: %v\n", err)
+		return 1
+	}
+	w.Player = *player
+	w.Buttons = buttons
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	go func() {
+		var states []bool
+		for {
+			var (
+				changes []ardilla.KeyChange
+				err     error
+			)
+			states, changes, err = d.Poll(states)
+			if err != nil {
+				if ctx.Err() == nil {
+					fmt.Fprintf(os.Stderr, "failed to get states: %v\n", err)
+					cancel()
+				}
+				return
+			}
+			for _, k := range changes {
+				ev := ardilla.KeyEvent{Key: k.Key, Row: k.Row, Col: k.Col, Pressed: k.Pressed}
+				_, err = w.HandleKey(ctx, ev)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "failed to control player: %v\n", err)
+				}
+			}
+		}
+	}()
+	err = w.Run(ctx, func(err error) {
+		fmt.Fprintf(os.Stderr, "failed to show track: %v\n", err)
+	})
+	if err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "failed to watch player: %v\n", err)
+		return 1
+	}
+	return 0
+}
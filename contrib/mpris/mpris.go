@@ -0,0 +1,384 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mpris provides a Stream Deck widget showing the album art and
+// title of the track playing in an MPRIS media player on Linux, with keys
+// bound to playback controls.
+//
+// Players are watched and controlled with the playerctl program, which
+// must be installed. The widget is drawn as a single image spanning a
+// block of keys, sliced in the same way as ardilla.Deck.SetCanvas.
+package mpris
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	_ "image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/label"
+)
+
+// Track is the state of a player and its current track.
+type Track struct {
+	// Player is the name of the player.
+	Player string
+
+	// Status is the playback status, one of
+	// "Playing", "Paused" or "Stopped".
+	Status string
+
+	Title, Artist, Album string
+
+	// ArtURL is the location of the album art.
+	// It is a file or HTTP URL, or empty if the
+	// player does not provide art.
+	ArtURL string
+}
+
+// trackFormat is the playerctl metadata format parsed by parseTrack.
+const trackFormat = "{{status}}\t{{playerName}}\t{{xesam:title}}\t{{xesam:artist}}\t{{xesam:album}}\t{{mpris:artUrl}}"
+
+// parseTrack returns the track described by a line of playerctl output
+// in trackFormat. An empty line is written by playerctl when no player is
+// available and gives the zero Track.
+func parseTrack(line string) (Track, error) {
+	if line == "" {
+		return Track{}, nil
+	}
+	f := strings.Split(line, "\t")
+	if len(f) != 6 {
+		return Track{}, fmt.Errorf("invalid playerctl metadata: %q", line)
+	}
+	return Track{
+		Status: f[0],
+		Player: f[1],
+		Title:  f[2],
+		Artist: f[3],
+		Album:  f[4],
+		ArtURL: f[5],
+	}, nil
+}
+
+// playerctl returns a command running playerctl with the given arguments
+// for the named player, or playerctl's default player if player is empty.
+func playerctl(ctx context.Context, player string, args ...string) *exec.Cmd {
+	if player != "" {
+		args = append([]string{"--player=" + player}, args...)
+	}
+	return exec.CommandContext(ctx, "playerctl", args...)
+}
+
+// Watch calls fn with the state of the named player each time it changes
+// until ctx is cancelled or watching fails. If player is empty, the player
+// chosen by playerctl is followed. When no player is running, fn is called
+// with the zero Track.
+func Watch(ctx context.Context, player string, fn func(Track)) error {
+	cmd := playerctl(ctx, player, "metadata", "--follow", "--format", trackFormat)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	sc := bufio.NewScanner(out)
+	for sc.Scan() {
+		t, err := parseTrack(sc.Text())
+		if err != nil {
+			continue
+		}
+		fn(t)
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err == nil {
+		err = errors.New("playerctl exited")
+	}
+	return err
+}
+
+// Action is a playback control.
+type Action string
+
+const (
+	PlayPause Action = "play-pause"
+	Next      Action = "next"
+	Previous  Action = "previous"
+)
+
+// Control performs the action on the named player, or on the player chosen
+// by playerctl if player is empty.
+func Control(ctx context.Context, player string, a Action) error {
+	switch a {
+	case PlayPause, Next, Previous:
+	default:
+		return fmt.Errorf("unknown action: %q", a)
+	}
+	out, err := playerctl(ctx, player, string(a)).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %w", msg, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Deck is the set of deck methods used by a Widget. It is satisfied by
+// *ardilla.Deck.
+type Deck interface {
+	Bounds() (image.Rectangle, error)
+	SetImage(row, col int, img image.Image) error
+	SetImageRegion(row, col int, img image.Image, src image.Rectangle) error
+}
+
+// Widget shows the current track of a player on a block of keys. The
+// widget's fields must not be altered after the first call to Run.
+type Widget struct {
+	// Player is the name of the player to follow and
+	// control. If Player is empty, the player chosen
+	// by playerctl is used.
+	Player string
+
+	// Buttons maps key positions, {row, col}, to the
+	// playback actions performed when they are
+	// pressed. Button keys should be outside the
+	// widget's block.
+	Buttons map[[2]int]Action
+
+	// Size is the font size of the track title in
+	// pixels. If Size is zero, 14 is used.
+	Size float64
+
+	deck        Deck
+	row, col    int
+	rows, cols  int
+	gap         int
+	key, bounds image.Rectangle
+
+	mu     sync.Mutex
+	art    image.Image
+	artURL string
+}
+
+// New returns a Widget drawn on d across the block of rows×cols keys with
+// its top left key at row and col. Adjacent keys are separated by gap
+// pixels as described by ardilla.Deck.CanvasBounds; ardilla.Deck.CanvasGap
+// gives a gap matching the device's bezels.
+func New(d Deck, row, col, rows, cols, gap int) (*Widget, error) {
+	if rows < 1 || cols < 1 {
+		return nil, fmt.Errorf("invalid block size: %dx%d", rows, cols)
+	}
+	if gap < 0 {
+		return nil, fmt.Errorf("negative gap: %d", gap)
+	}
+	key, err := d.Bounds()
+	if err != nil {
+		return nil, err
+	}
+	return &Widget{
+		deck: d,
+		row:  row, col: col,
+		rows: rows, cols: cols,
+		gap: gap,
+		key: key,
+		bounds: image.Rect(0, 0,
+			cols*key.Dx()+(cols-1)*gap,
+			rows*key.Dy()+(rows-1)*gap,
+		),
+	}, nil
+}
+
+// Run draws the buttons and then renders the player's track each time it
+// changes until ctx is cancelled or watching the player fails. Errors
+// loading album art or rendering are passed to errFn if it is not nil.
+func (w *Widget) Run(ctx context.Context, errFn func(error)) error {
+	report := func(err error) {
+		if err != nil && errFn != nil {
+			errFn(err)
+		}
+	}
+	report(w.DrawButtons())
+	return Watch(ctx, w.Player, func(t Track) {
+		art, err := w.loadArt(ctx, t.ArtURL)
+		report(err)
+		report(w.Render(t, art))
+	})
+}
+
+// HandleKey performs the action bound to the key in ev if it is a press.
+// It returns whether the key is a button of the widget.
+func (w *Widget) HandleKey(ctx context.Context, ev ardilla.KeyEvent) (bool, error) {
+	if ev.Err != nil || ev.Lagged != 0 || ev.Stuck {
+		return false, nil
+	}
+	a, ok := w.Buttons[[2]int{ev.Row, ev.Col}]
+	if !ok {
+		return false, nil
+	}
+	if !ev.Pressed {
+		return true, nil
+	}
+	return true, Control(ctx, w.Player, a)
+}
+
+// DrawButtons renders the labels of the widget's buttons.
+func (w *Widget) DrawButtons() error {
+	for pos, a := range w.Buttons {
+		text, ok := buttonLabels[a]
+		if !ok {
+			return fmt.Errorf("unknown action: %q", a)
+		}
+		img, err := label.Render(w.key, text, 14, color.White, color.Black)
+		if err != nil {
+			return err
+		}
+		err = w.deck.SetImage(pos[0], pos[1], img)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var buttonLabels = map[Action]string{
+	PlayPause: "Play\nPause",
+	Next:      "Next",
+	Previous:  "Previous",
+}
+
+// Render draws the track and album art across the widget's block. The art
+// is drawn as a square on the left of the block and the track's title and
+// artist fill the remaining space. If art is nil, the text fills the
+// block, and if the block is not wider than it is high, only the art is
+// shown.
+func (w *Widget) Render(t Track, art image.Image) error {
+	img, err := w.compose(t, art)
+	if err != nil {
+		return err
+	}
+	for r := 0; r < w.rows; r++ {
+		for c := 0; c < w.cols; c++ {
+			off := image.Pt(c*(w.key.Dx()+w.gap), r*(w.key.Dy()+w.gap))
+			err = w.deck.SetImageRegion(w.row+r, w.col+c, img, image.Rectangle{Max: w.key.Size()}.Add(off))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compose returns the image of the track spanning the widget's block.
+func (w *Widget) compose(t Track, art image.Image) (*image.RGBA, error) {
+	dst := image.NewRGBA(w.bounds)
+	draw.Draw(dst, dst.Bounds(), image.Black, image.Point{}, draw.Src)
+	text := w.bounds
+	if art != nil {
+		side := w.bounds.Dy()
+		if w.bounds.Dx() < side {
+			side = w.bounds.Dx()
+		}
+		sq := image.Rect(0, 0, side, side)
+		draw.CatmullRom.Scale(dst, sq, art, art.Bounds(), draw.Src, nil)
+		text.Min.X = side + w.gap
+	}
+	if text.Dx() <= 0 {
+		return dst, nil
+	}
+	var s string
+	switch {
+	case t.Player == "":
+		s = "No player"
+	case t.Status == "Stopped":
+		s = "Stopped"
+	default:
+		s = t.Title
+		if t.Artist != "" {
+			s += "\n" + t.Artist
+		}
+	}
+	size := w.Size
+	if size == 0 {
+		size = 14
+	}
+	lab, err := label.Render(text, s, size, color.White, color.Black)
+	if err != nil {
+		return nil, err
+	}
+	draw.Draw(dst, text, lab, text.Min, draw.Src)
+	return dst, nil
+}
+
+// loadArt returns the album art at the given URL. The most recently
+// loaded art is cached. If the URL is empty, loadArt returns nil.
+func (w *Widget) loadArt(ctx context.Context, u string) (image.Image, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if u == w.artURL {
+		return w.art, nil
+	}
+	w.art, w.artURL = nil, u
+	if u == "" {
+		return nil, nil
+	}
+	r, err := openArt(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode album art: %w", err)
+	}
+	w.art = img
+	return img, nil
+}
+
+// openArt opens the file or HTTP URL of album art.
+func openArt(ctx context.Context, u string) (io.ReadCloser, error) {
+	p, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	switch p.Scheme {
+	case "file":
+		return os.Open(p.Path)
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to get album art: %s", resp.Status)
+		}
+		return resp.Body, nil
+	default:
+		return nil, fmt.Errorf("unsupported album art URL: %s", u)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mpris
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kortschak/ardilla"
+)
+
+var parseTrackTests = []struct {
+	line    string
+	want    Track
+	wantErr bool
+}{
+	{line: "", want: Track{}},
+	{
+		line: "Playing\tspotify\tSo What\tMiles Davis\tKind of Blue\thttps://i.scdn.co/image/ab67",
+		want: Track{
+			Player: "spotify",
+			Status: "Playing",
+			Title:  "So What",
+			Artist: "Miles Davis",
+			Album:  "Kind of Blue",
+			ArtURL: "https://i.scdn.co/image/ab67",
+		},
+	},
+	{
+		line: "Paused\tvlc\tclip.mp4\t\t\t",
+		want: Track{Player: "vlc", Status: "Paused", Title: "clip.mp4"},
+	},
+	{line: "Playing\tvlc", wantErr: true},
+}
+
+func TestParseTrack(t *testing.T) {
+	for _, test := range parseTrackTests {
+		got, err := parseTrack(test.line)
+		if (err != nil) != test.wantErr {
+			t.Errorf("unexpected error for %q: %v", test.line, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("unexpected track for %q:\ngot: %+v\nwant:%+v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestPlayerctl(t *testing.T) {
+	for _, test := range []struct {
+		player string
+		want   []string
+	}{
+		{player: "", want: []string{"playerctl", "next"}},
+		{player: "mpv", want: []string{"playerctl", "--player=mpv", "next"}},
+	} {
+		got := playerctl(context.Background(), test.player, "next").Args
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("unexpected arguments for player %q: got:%q want:%q", test.player, got, test.want)
+		}
+	}
+}
+
+func TestControlUnknownAction(t *testing.T) {
+	err := Control(context.Background(), "", "stop")
+	if err == nil {
+		t.Error("expected error for unknown action")
+	}
+}
+
+// testDeck is a 3×5 deck of 72×72 keys that records the images set on it.
+type testDeck struct {
+	images  map[[2]int]image.Image
+	regions map[[2]int]image.Rectangle
+}
+
+func newTestDeck() *testDeck {
+	return &testDeck{
+		images:  make(map[[2]int]image.Image),
+		regions: make(map[[2]int]image.Rectangle),
+	}
+}
+
+func (d *testDeck) Bounds() (image.Rectangle, error) {
+	return image.Rect(0, 0, 72, 72), nil
+}
+
+func (d *testDeck) SetImage(row, col int, img image.Image) error {
+	if row < 0 || 3 <= row || col < 0 || 5 <= col {
+		return errors.New("key out of bounds")
+	}
+	d.images[[2]int{row, col}] = img
+	return nil
+}
+
+func (d *testDeck) SetImageRegion(row, col int, img image.Image, src image.Rectangle) error {
+	err := d.SetImage(row, col, img)
+	if err != nil {
+		return err
+	}
+	d.regions[[2]int{row, col}] = src
+	return nil
+}
+
+func TestWidgetRender(t *testing.T) {
+	d := newTestDeck()
+	w, err := New(d, 0, 1, 2, 3, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	red := color.RGBA{R: 0xff, A: 0xff}
+	sq := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < len(sq.Pix); i += 4 {
+		copy(sq.Pix[i:], []byte{0xff, 0, 0, 0xff})
+	}
+	err = w.Render(Track{Player: "mpv", Status: "Playing", Title: "Title"}, sq)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantRegions := map[[2]int]image.Rectangle{
+		{0, 1}: image.Rect(0, 0, 72, 72),
+		{0, 2}: image.Rect(82, 0, 154, 72),
+		{0, 3}: image.Rect(164, 0, 236, 72),
+		{1, 1}: image.Rect(0, 82, 72, 154),
+		{1, 2}: image.Rect(82, 82, 154, 154),
+		{1, 3}: image.Rect(164, 82, 236, 154),
+	}
+	if !reflect.DeepEqual(d.regions, wantRegions) {
+		t.Errorf("unexpected key regions:\ngot: %v\nwant:%v", d.regions, wantRegions)
+	}
+	img := d.images[[2]int{1, 2}]
+	if got := img.Bounds(); got != image.Rect(0, 0, 236, 154) {
+		t.Errorf("unexpected canvas bounds: %v", got)
+	}
+	// The art fills the square on the left of the
+	// block and the text is drawn to its right.
+	if got := color.RGBAModel.Convert(img.At(100, 100)); got != red {
+		t.Errorf("unexpected art colour: got:%v want:%v", got, red)
+	}
+	if got := color.RGBAModel.Convert(img.At(200, 140)); got != (color.RGBA{A: 0xff}) {
+		t.Errorf("unexpected background colour: got:%v", got)
+	}
+}
+
+func TestWidgetLoadArt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "art.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = png.Encode(f, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	f.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w, err := New(newTestDeck(), 0, 0, 1, 2, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	u := "file://" + filepath.ToSlash(path)
+	art, err := w.loadArt(ctx, u)
+	if err != nil {
+		t.Fatalf("unexpected error loading art: %v", err)
+	}
+	if art == nil || art.Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Fatalf("unexpected art: %v", art)
+	}
+	err = os.Remove(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cached, err := w.loadArt(ctx, u)
+	if err != nil || cached != art {
+		t.Errorf("art not cached: %v", err)
+	}
+	art, err = w.loadArt(ctx, "")
+	if err != nil || art != nil {
+		t.Errorf("unexpected art for empty URL: %v %v", art, err)
+	}
+	_, err = w.loadArt(ctx, "ftp://example.com/art.png")
+	if err == nil {
+		t.Error("expected error for unsupported URL")
+	}
+}
+
+func TestWidgetHandleKey(t *testing.T) {
+	d := newTestDeck()
+	w, err := New(d, 0, 0, 2, 3, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Buttons = map[[2]int]Action{{2, 0}: Previous, {2, 1}: PlayPause, {2, 2}: Next}
+	err = w.DrawButtons()
+	if err != nil {
+		t.Fatalf("unexpected error drawing buttons: %v", err)
+	}
+	if len(d.images) != 3 {
+		t.Errorf("unexpected number of buttons drawn: got:%d want:3", len(d.images))
+	}
+
+	ctx := context.Background()
+	for _, test := range []struct {
+		ev   ardilla.KeyEvent
+		want bool
+	}{
+		{ev: ardilla.KeyEvent{Row: 0, Col: 0, Pressed: true}, want: false},
+		{ev: ardilla.KeyEvent{Row: 2, Col: 1}, want: true},
+		{ev: ardilla.KeyEvent{Row: 2, Col: 1, Pressed: true, Stuck: true}, want: false},
+		{ev: ardilla.KeyEvent{Lagged: 1}, want: false},
+	} {
+		got, err := w.HandleKey(ctx, test.ev)
+		if err != nil {
+			t.Errorf("unexpected error for %+v: %v", test.ev, err)
+		}
+		if got != test.want {
+			t.Errorf("unexpected handled result for %+v: got:%t want:%t", test.ev, got, test.want)
+		}
+	}
+}
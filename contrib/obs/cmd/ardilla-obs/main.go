@@ -0,0 +1,129 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The ardilla-obs command binds Stream Deck keys to OBS Studio scenes and
+// to a recording toggle, using the obs-websocket protocol.
+//
+// Usage:
+//
+//	ardilla-obs [-serial <serial>] [-url <url>] [-password <ref>] [-record <row>,<col>] <row>,<col>=<scene>...
+//
+// Each argument binds a key to the named scene. The password is given as
+// a secret reference, for example env:OBS_PASSWORD or
+// keyring:obs-websocket/ardilla, as described in the secret package.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/contrib/obs"
+	"github.com/kortschak/ardilla/secret"
+)
+
+func main() {
+	os.Exit(Main())
+}
+
+func Main() int {
+	serial := flag.String("serial", "", "device serial number")
+	url := flag.String("url", obs.DefaultURL, "obs-websocket server URL")
+	password := flag.String("password", "", "secret reference for the obs-websocket password (none if empty)")
+	record := flag.String("record", "", "row,col of the recording toggle key (none if empty)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-serial <serial>] [-url <url>] [-password <ref>] [-record <row>,<col>] <row>,<col>=<scene>...\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 && *record == "" {
+		flag.Usage()
+		return 2
+	}
+	scenes := make(map[[2]int]string)
+	for _, arg := range flag.Args() {
+		pos, name, ok := strings.Cut(arg, "=")
+		var r, c int
+		_, err := fmt.Sscanf(pos, "%d,%d", &r, &c)
+		if !ok || err != nil || name == "" {
+			fmt.Fprintf(os.Stderr, "invalid scene binding %q\n", arg)
+			return 2
+		}
+		scenes[[2]int{r, c}] = name
+	}
+	var recordKey *[2]int
+	if *record != "" {
+		var r, c int
+		_, err := fmt.Sscanf(*record, "%d,%d", &r, &c)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid record key %q: %v\n", *record, err)
+			return 2
+		}
+		recordKey = &[2]int{r, c}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var pass string
+	if *password != "" {
+		var err error
+		pass, err = secret.Lookup(ctx, *password)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to get password: %v\n", err)
+			return 1
+		}
+	}
+
+	d, err := ardilla.NewDeck(ardilla.AnyPID, *serial)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+	p, err := obs.New(d)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create panel: %v\n", err)
+		return 1
+	}
+	p.Scenes = scenes
+	p.Record = recordKey
+
+	go func() {
+		var states []bool
+		for {
+			var (
+				changes []ardilla.KeyChange
+				err     error
+			)
+			states, changes, err = d.Poll(states)
+			if err != nil {
+				if ctx.Err() == nil {
+					fmt.Fprintf(os.Stderr, "failed to get states: %v\n", err)
+					cancel()
+				}
+				return
+			}
+			for _, k := range changes {
+				ev := ardilla.KeyEvent{Key: k.Key, Row: k.Row, Col: k.Col, Pressed: k.Pressed}
+				_, err = p.HandleKey(ctx, ev)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "failed to control obs: %v\n", err)
+				}
+			}
+		}
+	}()
+	err = p.Run(ctx, *url, pass, func(err error) {
+		fmt.Fprintf(os.Stderr, "failed to draw keys: %v\n", err)
+	})
+	if err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "obs connection failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,518 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package obs provides Stream Deck keys that switch scenes and toggle
+// recording in OBS Studio, showing the active scene and recording state.
+//
+// OBS is controlled with version 5 of the obs-websocket protocol, which is
+// built into OBS Studio 28 and later. The scene and recording state are
+// followed through obs-websocket events, so changes made in OBS itself
+// are shown on the deck.
+package obs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"sync"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/internal/websocket"
+	"github.com/kortschak/ardilla/label"
+)
+
+// DefaultURL is the default obs-websocket server address.
+const DefaultURL = "ws://localhost:4455"
+
+// obs-websocket message opcodes.
+const (
+	opHello      = 0
+	opIdentify   = 1
+	opIdentified = 2
+	opEvent      = 5
+	opRequest    = 6
+	opResponse   = 7
+)
+
+// obs-websocket event subscription categories.
+const (
+	subScenes  = 1 << 2
+	subOutputs = 1 << 6
+)
+
+// message is an obs-websocket message.
+type message struct {
+	Op   int             `json:"op"`
+	Data json.RawMessage `json:"d"`
+}
+
+// Event is an obs-websocket event.
+type Event struct {
+	Type string          `json:"eventType"`
+	Data json.RawMessage `json:"eventData"`
+}
+
+// response is the data of an obs-websocket request response message.
+type response struct {
+	ID     string `json:"requestId"`
+	Status struct {
+		Result  bool   `json:"result"`
+		Code    int    `json:"code"`
+		Comment string `json:"comment"`
+	} `json:"requestStatus"`
+	Data json.RawMessage `json:"responseData"`
+}
+
+// RequestError is returned when OBS fails a request.
+type RequestError struct {
+	Type    string
+	Code    int
+	Comment string
+}
+
+func (e *RequestError) Error() string {
+	if e.Comment == "" {
+		return fmt.Sprintf("obs: %s failed: code %d", e.Type, e.Code)
+	}
+	return fmt.Sprintf("obs: %s failed: %s (code %d)", e.Type, e.Comment, e.Code)
+}
+
+// Client is a connection to an obs-websocket server.
+type Client struct {
+	conn   *websocket.Conn
+	events func(Event)
+
+	mu      sync.Mutex
+	id      uint64
+	pending map[string]chan response
+	err     error
+	done    chan struct{}
+}
+
+// Dial connects to the obs-websocket server at url, authenticating with
+// password if the server requires it. Scene and output events are passed
+// to events, if it is not nil, from the client's receive goroutine; events
+// must not block on requests to the client.
+func Dial(ctx context.Context, url, password string, events func(Event)) (*Client, error) {
+	conn, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = identify(conn, password)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := &Client{
+		conn:    conn,
+		events:  events,
+		pending: make(map[string]chan response),
+		done:    make(chan struct{}),
+	}
+	go c.receive()
+	return c, nil
+}
+
+// identify completes the obs-websocket session handshake on conn.
+func identify(conn *websocket.Conn, password string) error {
+	var hello struct {
+		Op   int `json:"op"`
+		Data struct {
+			RPCVersion int `json:"rpcVersion"`
+			Auth       *struct {
+				Challenge string `json:"challenge"`
+				Salt      string `json:"salt"`
+			} `json:"authentication"`
+		} `json:"d"`
+	}
+	err := conn.ReadJSON(&hello)
+	if err != nil {
+		return err
+	}
+	if hello.Op != opHello {
+		return fmt.Errorf("obs: unexpected opcode during handshake: %d", hello.Op)
+	}
+	identify := struct {
+		RPCVersion    int    `json:"rpcVersion"`
+		Auth          string `json:"authentication,omitempty"`
+		Subscriptions int    `json:"eventSubscriptions"`
+	}{
+		RPCVersion:    1,
+		Subscriptions: subScenes | subOutputs,
+	}
+	if hello.Data.Auth != nil {
+		identify.Auth = authResponse(password, hello.Data.Auth.Salt, hello.Data.Auth.Challenge)
+	}
+	err = conn.WriteJSON(struct {
+		Op   int `json:"op"`
+		Data any `json:"d"`
+	}{Op: opIdentify, Data: identify})
+	if err != nil {
+		return err
+	}
+	var msg message
+	err = conn.ReadJSON(&msg)
+	if err != nil {
+		var cerr *websocket.CloseError
+		if errors.As(err, &cerr) && cerr.Code == 4009 {
+			return errors.New("obs: authentication failed")
+		}
+		return err
+	}
+	if msg.Op != opIdentified {
+		return fmt.Errorf("obs: unexpected opcode during handshake: %d", msg.Op)
+	}
+	return nil
+}
+
+// authResponse returns the obs-websocket authentication string for the
+// password and the server's salt and challenge.
+func authResponse(password, salt, challenge string) string {
+	secret := sha256.Sum256([]byte(password + salt))
+	auth := sha256.Sum256([]byte(base64.StdEncoding.EncodeToString(secret[:]) + challenge))
+	return base64.StdEncoding.EncodeToString(auth[:])
+}
+
+// receive dispatches responses and events until the connection fails.
+func (c *Client) receive() {
+	var err error
+	for {
+		var msg message
+		err = c.conn.ReadJSON(&msg)
+		if err != nil {
+			break
+		}
+		switch msg.Op {
+		case opEvent:
+			if c.events == nil {
+				continue
+			}
+			var ev Event
+			if json.Unmarshal(msg.Data, &ev) == nil {
+				c.events(ev)
+			}
+		case opResponse:
+			var resp response
+			if json.Unmarshal(msg.Data, &resp) != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch, ok := c.pending[resp.ID]
+			delete(c.pending, resp.ID)
+			c.mu.Unlock()
+			if ok {
+				ch <- resp
+			}
+		}
+	}
+	c.mu.Lock()
+	c.err = err
+	c.pending = nil
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// Done returns a channel that is closed when the connection is lost or
+// closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error that ended the connection, or nil if it is still
+// open.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Request sends the obs-websocket request typ with the given data, which
+// may be nil, and decodes the response data into resp if it is not nil.
+func (c *Client) Request(ctx context.Context, typ string, data, resp any) error {
+	c.mu.Lock()
+	if c.pending == nil {
+		err := c.err
+		c.mu.Unlock()
+		return fmt.Errorf("obs: connection closed: %w", err)
+	}
+	c.id++
+	id := strconv.FormatUint(c.id, 10)
+	ch := make(chan response, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	err := c.conn.WriteJSON(struct {
+		Op   int `json:"op"`
+		Data any `json:"d"`
+	}{
+		Op: opRequest,
+		Data: struct {
+			Type string `json:"requestType"`
+			ID   string `json:"requestId"`
+			Data any    `json:"requestData,omitempty"`
+		}{Type: typ, ID: id, Data: data},
+	})
+	if err != nil {
+		c.forget(id)
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		c.forget(id)
+		return ctx.Err()
+	case <-c.done:
+		return fmt.Errorf("obs: connection closed: %w", c.Err())
+	case r := <-ch:
+		if !r.Status.Result {
+			return &RequestError{Type: typ, Code: r.Status.Code, Comment: r.Status.Comment}
+		}
+		if resp == nil || len(r.Data) == 0 {
+			return nil
+		}
+		return json.Unmarshal(r.Data, resp)
+	}
+}
+
+// forget removes the pending request with the given ID.
+func (c *Client) forget(id string) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// CurrentScene returns the name of the current program scene.
+func (c *Client) CurrentScene(ctx context.Context) (string, error) {
+	var resp struct {
+		Name string `json:"currentProgramSceneName"`
+	}
+	err := c.Request(ctx, "GetCurrentProgramScene", nil, &resp)
+	return resp.Name, err
+}
+
+// SetScene switches the program to the named scene.
+func (c *Client) SetScene(ctx context.Context, name string) error {
+	return c.Request(ctx, "SetCurrentProgramScene", struct {
+		Name string `json:"sceneName"`
+	}{name}, nil)
+}
+
+// Recording returns whether OBS is recording.
+func (c *Client) Recording(ctx context.Context) (bool, error) {
+	var resp struct {
+		Active bool `json:"outputActive"`
+	}
+	err := c.Request(ctx, "GetRecordStatus", nil, &resp)
+	return resp.Active, err
+}
+
+// ToggleRecord starts recording if OBS is not recording, and stops it if
+// it is.
+func (c *Client) ToggleRecord(ctx context.Context) error {
+	return c.Request(ctx, "ToggleRecord", nil, nil)
+}
+
+// Deck is the set of deck methods used by a Panel. It is satisfied by
+// *ardilla.Deck.
+type Deck interface {
+	Bounds() (image.Rectangle, error)
+	SetImage(row, col int, img image.Image) error
+}
+
+// Panel is a set of keys controlling OBS. The panel's fields must not be
+// altered after the first call to Run.
+type Panel struct {
+	// Scenes maps key positions, {row, col}, to the
+	// names of the scenes they switch to. The key of
+	// the current scene is highlighted.
+	Scenes map[[2]int]string
+
+	// Record is the position of the key that toggles
+	// recording, or nil if there is no recording key.
+	Record *[2]int
+
+	// Size is the font size of the key labels in
+	// pixels. If Size is zero, 14 is used.
+	Size float64
+
+	deck Deck
+	key  image.Rectangle
+
+	mu        sync.Mutex
+	client    *Client
+	scene     string
+	recording bool
+}
+
+// New returns a Panel drawn on d.
+func New(d Deck) (*Panel, error) {
+	key, err := d.Bounds()
+	if err != nil {
+		return nil, err
+	}
+	return &Panel{deck: d, key: key}, nil
+}
+
+// Key colours.
+var (
+	sceneActive = color.RGBA{G: 0x80, A: 0xff}
+	recordingOn = color.RGBA{R: 0xc0, A: 0xff}
+)
+
+// Run connects to the obs-websocket server at url, draws the panel's keys
+// and then redraws them as the scene and recording state change, until
+// ctx is cancelled or the connection is lost. Rendering errors are passed
+// to errFn if it is not nil.
+func (p *Panel) Run(ctx context.Context, url, password string, errFn func(error)) error {
+	report := func(err error) {
+		if err != nil && errFn != nil {
+			errFn(err)
+		}
+	}
+	c, err := Dial(ctx, url, password, func(ev Event) {
+		report(p.handleEvent(ev))
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	scene, err := c.CurrentScene(ctx)
+	if err != nil {
+		return err
+	}
+	recording, err := c.Recording(ctx)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.client = c
+	p.scene = scene
+	p.recording = recording
+	p.mu.Unlock()
+	report(p.Render())
+
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-c.Done():
+		err = c.Err()
+	}
+	p.mu.Lock()
+	p.client = nil
+	p.mu.Unlock()
+	return err
+}
+
+// handleEvent updates the panel's state from an obs-websocket event and
+// redraws its keys if the state changed.
+func (p *Panel) handleEvent(ev Event) error {
+	p.mu.Lock()
+	changed := false
+	switch ev.Type {
+	case "CurrentProgramSceneChanged":
+		var data struct {
+			Name string `json:"sceneName"`
+		}
+		if json.Unmarshal(ev.Data, &data) == nil {
+			changed = data.Name != p.scene
+			p.scene = data.Name
+		}
+	case "RecordStateChanged":
+		var data struct {
+			Active bool `json:"outputActive"`
+		}
+		if json.Unmarshal(ev.Data, &data) == nil {
+			changed = data.Active != p.recording
+			p.recording = data.Active
+		}
+	}
+	p.mu.Unlock()
+	if !changed {
+		return nil
+	}
+	return p.Render()
+}
+
+// HandleKey switches scene or toggles recording if the key in ev is one
+// of the panel's keys and is pressed. It returns whether the key belongs
+// to the panel.
+func (p *Panel) HandleKey(ctx context.Context, ev ardilla.KeyEvent) (bool, error) {
+	if ev.Err != nil || ev.Lagged != 0 || ev.Stuck {
+		return false, nil
+	}
+	pos := [2]int{ev.Row, ev.Col}
+	scene, isScene := p.Scenes[pos]
+	isRecord := p.Record != nil && *p.Record == pos
+	if !isScene && !isRecord {
+		return false, nil
+	}
+	if !ev.Pressed {
+		return true, nil
+	}
+	p.mu.Lock()
+	c := p.client
+	p.mu.Unlock()
+	if c == nil {
+		return true, errors.New("obs: not connected")
+	}
+	if isScene {
+		return true, c.SetScene(ctx, scene)
+	}
+	return true, c.ToggleRecord(ctx)
+}
+
+// Render draws the panel's keys with the current scene and recording
+// state.
+func (p *Panel) Render() error {
+	p.mu.Lock()
+	scene, recording := p.scene, p.recording
+	p.mu.Unlock()
+
+	size := p.Size
+	if size == 0 {
+		size = 14
+	}
+	for pos, name := range p.Scenes {
+		var bg color.Color = color.Black
+		if name == scene {
+			bg = sceneActive
+		}
+		img, err := label.Render(p.key, name, size, color.White, bg)
+		if err != nil {
+			return err
+		}
+		err = p.deck.SetImage(pos[0], pos[1], img)
+		if err != nil {
+			return err
+		}
+	}
+	if p.Record != nil {
+		text := "Record"
+		var bg color.Color = color.Black
+		if recording {
+			text = "Recording"
+			bg = recordingOn
+		}
+		img, err := label.Render(p.key, text, size, color.White, bg)
+		if err != nil {
+			return err
+		}
+		err = p.deck.SetImage(p.Record[0], p.Record[1], img)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/internal/websocket"
+)
+
+// fakeOBS is an obs-websocket server with a set of scenes and a
+// recording output.
+type fakeOBS struct {
+	t        *testing.T
+	password string
+
+	mu        sync.Mutex
+	scene     string
+	recording bool
+	requests  []string
+}
+
+func (s *fakeOBS) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c, err := websocket.Upgrade(w, req)
+	if err != nil {
+		s.t.Errorf("failed to upgrade: %v", err)
+		return
+	}
+	defer c.Close()
+
+	hello := map[string]any{"rpcVersion": 1}
+	if s.password != "" {
+		hello["authentication"] = map[string]string{"challenge": "challenge", "salt": "salt"}
+	}
+	s.send(c, opHello, hello)
+	var identify struct {
+		Data struct {
+			Auth          string `json:"authentication"`
+			Subscriptions int    `json:"eventSubscriptions"`
+		} `json:"d"`
+	}
+	err = c.ReadJSON(&identify)
+	if err != nil {
+		return
+	}
+	if s.password != "" && identify.Data.Auth != authResponse(s.password, "salt", "challenge") {
+		// Close with the obs-websocket authentication failed code.
+		c.Close()
+		return
+	}
+	if identify.Data.Subscriptions&(subScenes|subOutputs) != subScenes|subOutputs {
+		s.t.Errorf("missing event subscriptions: %b", identify.Data.Subscriptions)
+	}
+	s.send(c, opIdentified, map[string]int{"negotiatedRpcVersion": 1})
+
+	for {
+		var msg struct {
+			Data struct {
+				Type string `json:"requestType"`
+				ID   string `json:"requestId"`
+				Data struct {
+					Scene string `json:"sceneName"`
+				} `json:"requestData"`
+			} `json:"d"`
+		}
+		err = c.ReadJSON(&msg)
+		if err != nil {
+			return
+		}
+		req := msg.Data
+		s.mu.Lock()
+		s.requests = append(s.requests, req.Type)
+		resp := map[string]any{
+			"requestType":   req.Type,
+			"requestId":     req.ID,
+			"requestStatus": map[string]any{"result": true, "code": 100},
+		}
+		var event map[string]any
+		switch req.Type {
+		case "GetCurrentProgramScene":
+			resp["responseData"] = map[string]string{"currentProgramSceneName": s.scene}
+		case "GetRecordStatus":
+			resp["responseData"] = map[string]bool{"outputActive": s.recording}
+		case "SetCurrentProgramScene":
+			s.scene = req.Data.Scene
+			event = map[string]any{
+				"eventType": "CurrentProgramSceneChanged",
+				"eventData": map[string]string{"sceneName": s.scene},
+			}
+		case "ToggleRecord":
+			s.recording = !s.recording
+			event = map[string]any{
+				"eventType": "RecordStateChanged",
+				"eventData": map[string]any{"outputActive": s.recording},
+			}
+		default:
+			resp["requestStatus"] = map[string]any{"result": false, "code": 204, "comment": "unknown request"}
+		}
+		s.mu.Unlock()
+		s.send(c, opResponse, resp)
+		if event != nil {
+			s.send(c, opEvent, event)
+		}
+	}
+}
+
+func (s *fakeOBS) send(c *websocket.Conn, op int, data any) {
+	err := c.WriteJSON(map[string]any{"op": op, "d": data})
+	if err != nil {
+		s.t.Errorf("failed to send message: %v", err)
+	}
+}
+
+func serve(t *testing.T, s *fakeOBS) string {
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestClient(t *testing.T) {
+	u := serve(t, &fakeOBS{t: t, password: "secret", scene: "Intro"})
+	ctx := context.Background()
+
+	_, err := Dial(ctx, u, "wrong", nil)
+	if err == nil {
+		t.Error("expected error for wrong password")
+	}
+
+	events := make(chan Event, 10)
+	c, err := Dial(ctx, u, "secret", func(ev Event) { events <- ev })
+	if err != nil {
+		t.Fatalf("unexpected error dialing: %v", err)
+	}
+	defer c.Close()
+
+	scene, err := c.CurrentScene(ctx)
+	if err != nil || scene != "Intro" {
+		t.Errorf("unexpected current scene: got:%q want:%q err:%v", scene, "Intro", err)
+	}
+	err = c.SetScene(ctx, "Main")
+	if err != nil {
+		t.Errorf("unexpected error setting scene: %v", err)
+	}
+	select {
+	case ev := <-events:
+		var data struct {
+			Name string `json:"sceneName"`
+		}
+		json.Unmarshal(ev.Data, &data)
+		if ev.Type != "CurrentProgramSceneChanged" || data.Name != "Main" {
+			t.Errorf("unexpected event: %s %s", ev.Type, ev.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("timed out waiting for scene event")
+	}
+	err = c.Request(ctx, "NoSuchRequest", nil, nil)
+	var rerr *RequestError
+	if !errors.As(err, &rerr) || rerr.Code != 204 {
+		t.Errorf("unexpected error for unknown request: %v", err)
+	}
+
+	c.Close()
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for connection to close")
+	}
+	_, err = c.Recording(ctx)
+	if err == nil {
+		t.Error("expected error for request on closed connection")
+	}
+}
+
+// testDeck is a 3×5 deck of 72×72 keys that records the images set on it.
+type testDeck struct {
+	mu     sync.Mutex
+	images map[[2]int]image.Image
+}
+
+func (d *testDeck) Bounds() (image.Rectangle, error) {
+	return image.Rect(0, 0, 72, 72), nil
+}
+
+func (d *testDeck) SetImage(row, col int, img image.Image) error {
+	if row < 0 || 3 <= row || col < 0 || 5 <= col {
+		return errors.New("key out of bounds")
+	}
+	d.mu.Lock()
+	d.images[[2]int{row, col}] = img
+	d.mu.Unlock()
+	return nil
+}
+
+// background returns the colour of the corner of the key's image.
+func (d *testDeck) background(row, col int) color.Color {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img, ok := d.images[[2]int{row, col}]
+	if !ok {
+		return nil
+	}
+	return color.RGBAModel.Convert(img.At(0, 0))
+}
+
+func TestPanel(t *testing.T) {
+	obs := &fakeOBS{t: t, scene: "Intro"}
+	u := serve(t, obs)
+	d := &testDeck{images: make(map[[2]int]image.Image)}
+	p, err := New(d)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.Scenes = map[[2]int]string{{0, 0}: "Intro", {0, 1}: "Main"}
+	p.Record = &[2]int{1, 0}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Run(ctx, u, "", func(err error) { t.Errorf("unexpected render error: %v", err) })
+	}()
+
+	black := color.RGBA{A: 0xff}
+	waitFor := func(what string, row, col int, want color.Color) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if d.background(row, col) == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %s: got:%v want:%v", what, d.background(row, col), want)
+	}
+	waitFor("initial scene", 0, 0, sceneActive)
+	waitFor("initial record state", 1, 0, black)
+
+	for _, test := range []struct {
+		ev   ardilla.KeyEvent
+		want bool
+	}{
+		{ev: ardilla.KeyEvent{Row: 2, Col: 2, Pressed: true}, want: false},
+		{ev: ardilla.KeyEvent{Row: 0, Col: 1, Pressed: true, Stuck: true}, want: false},
+		{ev: ardilla.KeyEvent{Row: 0, Col: 1}, want: true},
+		{ev: ardilla.KeyEvent{Row: 0, Col: 1, Pressed: true}, want: true},
+		{ev: ardilla.KeyEvent{Row: 1, Col: 0, Pressed: true}, want: true},
+	} {
+		got, err := p.HandleKey(ctx, test.ev)
+		if err != nil {
+			t.Errorf("unexpected error for %+v: %v", test.ev, err)
+		}
+		if got != test.want {
+			t.Errorf("unexpected handled result for %+v: got:%t want:%t", test.ev, got, test.want)
+		}
+	}
+	waitFor("new scene", 0, 1, sceneActive)
+	waitFor("old scene", 0, 0, black)
+	waitFor("recording", 1, 0, recordingOn)
+
+	obs.mu.Lock()
+	requests := strings.Join(obs.requests, ",")
+	obs.mu.Unlock()
+	want := "GetCurrentProgramScene,GetRecordStatus,SetCurrentProgramScene,ToggleRecord"
+	if requests != want {
+		t.Errorf("unexpected requests: got:%s want:%s", requests, want)
+	}
+
+	cancel()
+	select {
+	case err = <-done:
+		if err != context.Canceled {
+			t.Errorf("unexpected error from Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Run to return")
+	}
+	_, err = p.HandleKey(context.Background(), ardilla.KeyEvent{Row: 1, Col: 0, Pressed: true})
+	if err == nil {
+		t.Error("expected error for key press while disconnected")
+	}
+}
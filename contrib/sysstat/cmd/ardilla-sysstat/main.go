@@ -0,0 +1,128 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The ardilla-sysstat command is an ardillad plugin that shows system
+// statistics on its keys.
+//
+// Usage:
+//
+//	ardilla-sysstat [-interval <duration>] [-size <pixels>] <widget>...
+//
+// Each widget is shown on the corresponding key given to the plugin in
+// the ardillad plugins file. Widgets are described using the specs
+// accepted by sysstat.Parse. For example:
+//
+//	[
+//		{
+//			"path": "ardilla-sysstat",
+//			"args": ["cpu", "mem", "net", "disk:/home"],
+//			"keys": [{"row": 0, "col": 0}, {"row": 0, "col": 1}, {"row": 0, "col": 2}, {"row": 0, "col": 3}]
+//		}
+//	]
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kortschak/ardilla/contrib/sysstat"
+	"github.com/kortschak/ardilla/layout"
+)
+
+func main() {
+	os.Exit(Main())
+}
+
+func Main() int {
+	interval := flag.Duration("interval", 2*time.Second, "update interval")
+	size := flag.Float64("size", 16, "text size in pixels")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-interval <duration>] [-size <pixels>] <widget>...\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *interval <= 0 {
+		flag.Usage()
+		return 2
+	}
+	widgets := make([]sysstat.Widget, flag.NArg())
+	for i, spec := range flag.Args() {
+		w, err := sysstat.Parse(spec)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		widgets[i] = w
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	if !in.Scan() {
+		fmt.Fprintln(os.Stderr, "no init message")
+		return 1
+	}
+	var init struct {
+		Type string            `json:"type"`
+		Keys []layout.Position `json:"keys"`
+	}
+	err := json.Unmarshal(in.Bytes(), &init)
+	if err != nil || init.Type != "init" {
+		fmt.Fprintf(os.Stderr, "invalid init message: %s\n", in.Bytes())
+		return 1
+	}
+	if len(init.Keys) < len(widgets) {
+		fmt.Fprintf(os.Stderr, "too few keys for widgets: %d < %d\n", len(init.Keys), len(widgets))
+		return 1
+	}
+	p := sysstat.Panel{Widgets: make(map[layout.Position]sysstat.Widget)}
+	for i, w := range widgets {
+		p.Widgets[init.Keys[i]] = w
+	}
+
+	// The host closes stdin to stop the plugin. Other
+	// messages are logged if they are errors.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		for in.Scan() {
+			var msg struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			}
+			if json.Unmarshal(in.Bytes(), &msg) == nil && msg.Type == "error" {
+				fmt.Fprintf(os.Stderr, "host: %s\n", msg.Message)
+			}
+		}
+	}()
+
+	enc := json.NewEncoder(os.Stdout)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		err = p.Update(func(pos layout.Position, text string) error {
+			return enc.Encode(struct {
+				Type string  `json:"type"`
+				Row  int     `json:"row"`
+				Col  int     `json:"col"`
+				Text string  `json:"text"`
+				Size float64 `json:"size"`
+			}{Type: "text", Row: pos.Row, Col: pos.Col, Text: text, Size: *size})
+		}, func(pos layout.Position, err error) {
+			fmt.Fprintf(os.Stderr, "key %d,%d: %v\n", pos.Row, pos.Col, err)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to send update: %v\n", err)
+			return 1
+		}
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-ticker.C:
+		}
+	}
+}
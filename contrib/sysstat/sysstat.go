@@ -0,0 +1,381 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sysstat provides system monitoring widgets for Stream Deck keys.
+//
+// Widgets report CPU use, memory use, network throughput and file system
+// use. Statistics are read from /proc and statfs, and are only available
+// on Linux; on other platforms reading a widget returns ErrUnsupported.
+//
+// A Panel renders a set of widgets onto keys, re-rendering each key only
+// when its text changes. The ardilla-sysstat command runs a panel as an
+// ardillad plugin, so that a monitoring panel can be assembled from the
+// ardillad plugins file alone.
+package sysstat
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kortschak/ardilla/label"
+	"github.com/kortschak/ardilla/layout"
+)
+
+// ErrUnsupported is returned when a statistic is not available on the
+// platform.
+var ErrUnsupported = errors.New("statistic not supported on this platform")
+
+// Widget is a source of text for a key.
+type Widget interface {
+	// Text returns the text to show on the key.
+	Text() (string, error)
+}
+
+// Parse returns the widget described by spec. The following specs are
+// accepted:
+//
+//	cpu          CPU use as a percentage of all CPUs
+//	mem          memory in use and total memory
+//	net          receive and transmit rates for all non-loopback interfaces
+//	net:IFACE    receive and transmit rates for the interface IFACE
+//	disk         use of the file system holding /
+//	disk:PATH    use of the file system holding PATH
+func Parse(spec string) (Widget, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "cpu":
+		return &CPU{}, nil
+	case "mem":
+		return &Memory{}, nil
+	case "net":
+		return &Network{Interface: arg}, nil
+	case "disk":
+		if arg == "" {
+			arg = "/"
+		}
+		return &Disk{Path: arg}, nil
+	default:
+		return nil, fmt.Errorf("unknown widget: %q", spec)
+	}
+}
+
+// CPU is a widget showing the CPU use since its previous reading.
+type CPU struct {
+	last cpuTimes
+}
+
+// cpuTimes is the cumulative CPU time in clock ticks.
+type cpuTimes struct {
+	busy, total uint64
+}
+
+// Text returns the CPU use as a percentage. The first reading is the
+// average since boot.
+func (c *CPU) Text() (string, error) {
+	t, err := readCPU()
+	if err != nil {
+		return "", err
+	}
+	busy, total := t.busy-c.last.busy, t.total-c.last.total
+	c.last = t
+	if total == 0 {
+		return "CPU\n-", nil
+	}
+	return fmt.Sprintf("CPU\n%d%%", (100*busy+total/2)/total), nil
+}
+
+// Memory is a widget showing memory use.
+type Memory struct{}
+
+// Text returns the memory in use and the total memory.
+func (Memory) Text() (string, error) {
+	used, total, err := readMemory()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("MEM\n%s/%s", bytesText(used), bytesText(total)), nil
+}
+
+// Network is a widget showing network throughput since its previous
+// reading.
+type Network struct {
+	// Interface is the interface to report. If it
+	// is empty, the traffic for all interfaces
+	// other than loopback is summed.
+	Interface string
+
+	last     netBytes
+	lastTime time.Time
+}
+
+// netBytes is the cumulative count of bytes received and transmitted.
+type netBytes struct {
+	rx, tx uint64
+}
+
+// Text returns the receive and transmit rates in bytes per second. The
+// first reading has no rates.
+func (n *Network) Text() (string, error) {
+	b, err := readNetwork(n.Interface)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	last, lastTime := n.last, n.lastTime
+	n.last, n.lastTime = b, now
+	if lastTime.IsZero() || !now.After(lastTime) {
+		return "NET\n-", nil
+	}
+	secs := now.Sub(lastTime).Seconds()
+	rx := uint64(float64(b.rx-last.rx) / secs)
+	tx := uint64(float64(b.tx-last.tx) / secs)
+	return fmt.Sprintf("rx %s\ntx %s", bytesText(rx), bytesText(tx)), nil
+}
+
+// Disk is a widget showing file system use.
+type Disk struct {
+	// Path is a path in the file system to report.
+	Path string
+}
+
+// Text returns the percentage of the file system in use.
+func (d Disk) Text() (string, error) {
+	used, total, err := readDisk(d.Path)
+	if err != nil {
+		return "", err
+	}
+	if total == 0 {
+		return "DISK\n-", nil
+	}
+	return fmt.Sprintf("DISK\n%d%%", (100*used+total/2)/total), nil
+}
+
+// bytesText returns n formatted with a binary unit suffix.
+func bytesText(n uint64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return strconv.FormatUint(n, 10)
+	}
+	v := float64(n)
+	var i int
+	for v /= 1024; v >= 1024 && i < len(units)-1; i++ {
+		v /= 1024
+	}
+	if v < 10 {
+		return fmt.Sprintf("%.1f%c", v, units[i])
+	}
+	return fmt.Sprintf("%.0f%c", v, units[i])
+}
+
+// Deck is the device a Panel renders on. It is satisfied by *ardilla.Deck.
+type Deck interface {
+	Bounds() (image.Rectangle, error)
+	SetImage(row, col int, img image.Image) error
+}
+
+// Panel is a set of widgets shown on deck keys.
+type Panel struct {
+	// Widgets is the widget shown on each key.
+	Widgets map[layout.Position]Widget
+
+	// Size is the text size in pixels. If Size is
+	// zero, 16 is used.
+	Size float64
+
+	// FG and BG are the text and background colours.
+	// If nil, white and black are used.
+	FG, BG color.Color
+
+	shown map[layout.Position]string
+}
+
+// Run renders the panel on d every interval until ctx is cancelled. Errors
+// reading a widget are shown on its key and passed to report if it is not
+// nil. Run returns the first error rendering to d, or ctx.Err.
+func (p *Panel) Run(ctx context.Context, d Deck, interval time.Duration, report func(layout.Position, error)) error {
+	b, err := d.Bounds()
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err = p.Update(func(pos layout.Position, text string) error {
+			img, err := label.Render(b, text, p.size(), p.fg(), p.bg())
+			if err != nil {
+				return err
+			}
+			return d.SetImage(pos.Row, pos.Col, img)
+		}, report)
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update reads each of the panel's widgets and calls render for those
+// whose text has changed since it was last rendered. Errors reading a
+// widget are rendered as the widget's text and passed to report if it is
+// not nil. Update returns the first error returned by render.
+func (p *Panel) Update(render func(layout.Position, string) error, report func(layout.Position, error)) error {
+	if p.shown == nil {
+		p.shown = make(map[layout.Position]string)
+	}
+	for pos, w := range p.Widgets {
+		text, err := w.Text()
+		if err != nil {
+			if report != nil {
+				report(pos, err)
+			}
+			text = "ERR"
+		}
+		if prev, ok := p.shown[pos]; ok && prev == text {
+			continue
+		}
+		err = render(pos, text)
+		if err != nil {
+			delete(p.shown, pos)
+			return err
+		}
+		p.shown[pos] = text
+	}
+	return nil
+}
+
+func (p *Panel) size() float64 {
+	if p.Size == 0 {
+		return 16
+	}
+	return p.Size
+}
+
+func (p *Panel) fg() color.Color {
+	if p.FG == nil {
+		return color.White
+	}
+	return p.FG
+}
+
+func (p *Panel) bg() color.Color {
+	if p.BG == nil {
+		return color.Black
+	}
+	return p.BG
+}
+
+// parseCPU returns the aggregate CPU times held in the contents of
+// /proc/stat.
+func parseCPU(s string) (cpuTimes, error) {
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 5 || f[0] != "cpu" {
+			continue
+		}
+		var t cpuTimes
+		for i, v := range f[1:] {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return cpuTimes{}, fmt.Errorf("invalid cpu time: %w", err)
+			}
+			if i >= 8 {
+				// Guest times are already
+				// included in user and nice.
+				break
+			}
+			t.total += n
+			if i != 3 && i != 4 {
+				// Fields 3 and 4 are
+				// idle and iowait.
+				t.busy += n
+			}
+		}
+		return t, nil
+	}
+	return cpuTimes{}, errors.New("no aggregate cpu line")
+}
+
+// parseMemory returns the used and total memory in bytes held in the
+// contents of /proc/meminfo.
+func parseMemory(s string) (used, total uint64, err error) {
+	var avail uint64
+	var haveTotal, haveAvail bool
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		name, val, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		var dst *uint64
+		switch name {
+		case "MemTotal":
+			dst, haveTotal = &total, true
+		case "MemAvailable":
+			dst, haveAvail = &avail, true
+		default:
+			continue
+		}
+		kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(val), " kB"), 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*dst = kb * 1024
+	}
+	if !haveTotal || !haveAvail {
+		return 0, 0, errors.New("missing memory totals")
+	}
+	return total - avail, total, nil
+}
+
+// parseNetwork returns the bytes received and transmitted by iface, or by
+// all interfaces other than lo if iface is empty, held in the contents of
+// /proc/net/dev.
+func parseNetwork(s, iface string) (netBytes, error) {
+	var (
+		b     netBytes
+		found bool
+	)
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		name, stats, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if iface == "" && name == "lo" || iface != "" && name != iface {
+			continue
+		}
+		f := strings.Fields(stats)
+		if len(f) < 9 {
+			return netBytes{}, fmt.Errorf("short statistics for %s", name)
+		}
+		rx, err := strconv.ParseUint(f[0], 10, 64)
+		if err != nil {
+			return netBytes{}, fmt.Errorf("invalid received bytes for %s: %w", name, err)
+		}
+		tx, err := strconv.ParseUint(f[8], 10, 64)
+		if err != nil {
+			return netBytes{}, fmt.Errorf("invalid transmitted bytes for %s: %w", name, err)
+		}
+		b.rx += rx
+		b.tx += tx
+		found = true
+	}
+	if iface != "" && !found {
+		return netBytes{}, fmt.Errorf("no interface: %q", iface)
+	}
+	return b, nil
+}
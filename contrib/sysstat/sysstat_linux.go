@@ -0,0 +1,47 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sysstat
+
+import (
+	"os"
+	"syscall"
+)
+
+func readCPU() (cpuTimes, error) {
+	b, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuTimes{}, err
+	}
+	return parseCPU(string(b))
+}
+
+func readMemory() (used, total uint64, err error) {
+	b, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseMemory(string(b))
+}
+
+func readNetwork(iface string) (netBytes, error) {
+	b, err := os.ReadFile("/proc/net/dev")
+	if err != nil {
+		return netBytes{}, err
+	}
+	return parseNetwork(string(b), iface)
+}
+
+func readDisk(path string) (used, total uint64, err error) {
+	var st syscall.Statfs_t
+	err = syscall.Statfs(path, &st)
+	if err != nil {
+		return 0, 0, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	bsize := uint64(st.Bsize)
+	// Use is reported relative to the space available to
+	// unprivileged users, as df does.
+	used = (st.Blocks - st.Bfree) * bsize
+	return used, used + st.Bavail*bsize, nil
+}
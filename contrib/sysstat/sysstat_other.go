@@ -0,0 +1,23 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package sysstat
+
+func readCPU() (cpuTimes, error) {
+	return cpuTimes{}, ErrUnsupported
+}
+
+func readMemory() (used, total uint64, err error) {
+	return 0, 0, ErrUnsupported
+}
+
+func readNetwork(iface string) (netBytes, error) {
+	return netBytes{}, ErrUnsupported
+}
+
+func readDisk(path string) (used, total uint64, err error) {
+	return 0, 0, ErrUnsupported
+}
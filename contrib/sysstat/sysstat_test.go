@@ -0,0 +1,171 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sysstat
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kortschak/ardilla/layout"
+)
+
+const procStat = `cpu  100 20 30 800 50 0 0 0 7 0
+cpu0 50 10 15 400 25 0 0 0 3 0
+intr 1234
+`
+
+const procMeminfo = `MemTotal:       16384000 kB
+MemFree:         1024000 kB
+MemAvailable:    8192000 kB
+Buffers:          512000 kB
+`
+
+const procNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 5000      50    0    0    0     0          0         0     5000      50    0    0    0     0       0          0
+  eth0: 1000      10    0    0    0     0          0         0     2000      20    0    0    0     0       0          0
+ wlan0:  300       3    0    0    0     0          0         0      400       4    0    0    0     0       0          0
+`
+
+func TestParse(t *testing.T) {
+	cpu, err := parseCPU(procStat)
+	if err != nil {
+		t.Errorf("unexpected error parsing cpu: %v", err)
+	}
+	if want := (cpuTimes{busy: 150, total: 1000}); cpu != want {
+		t.Errorf("unexpected cpu times: got:%+v want:%+v", cpu, want)
+	}
+	_, err = parseCPU("intr 1234\n")
+	if err == nil {
+		t.Error("expected error for missing cpu line")
+	}
+
+	used, total, err := parseMemory(procMeminfo)
+	if err != nil {
+		t.Errorf("unexpected error parsing memory: %v", err)
+	}
+	if used != 8192000*1024 || total != 16384000*1024 {
+		t.Errorf("unexpected memory: got:%d/%d want:%d/%d", used, total, 8192000*1024, 16384000*1024)
+	}
+	_, _, err = parseMemory("MemTotal: 1 kB\n")
+	if err == nil {
+		t.Error("expected error for missing available memory")
+	}
+
+	for _, test := range []struct {
+		iface   string
+		want    netBytes
+		wantErr bool
+	}{
+		{iface: "", want: netBytes{rx: 1300, tx: 2400}},
+		{iface: "eth0", want: netBytes{rx: 1000, tx: 2000}},
+		{iface: "lo", want: netBytes{rx: 5000, tx: 5000}},
+		{iface: "eth1", wantErr: true},
+	} {
+		got, err := parseNetwork(procNetDev, test.iface)
+		if (err != nil) != test.wantErr {
+			t.Errorf("unexpected error for %q: %v", test.iface, err)
+		}
+		if got != test.want {
+			t.Errorf("unexpected network bytes for %q: got:%+v want:%+v", test.iface, got, test.want)
+		}
+	}
+
+	for _, spec := range []string{"cpu", "mem", "net", "net:eth0", "disk", "disk:/home"} {
+		_, err := Parse(spec)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", spec, err)
+		}
+	}
+	_, err = Parse("gpu")
+	if err == nil {
+		t.Error("expected error for unknown widget")
+	}
+}
+
+func TestBytesText(t *testing.T) {
+	for _, test := range []struct {
+		n    uint64
+		want string
+	}{
+		{n: 0, want: "0"},
+		{n: 1023, want: "1023"},
+		{n: 1024, want: "1.0K"},
+		{n: 1536, want: "1.5K"},
+		{n: 200 << 20, want: "200M"},
+		{n: 16 << 30, want: "16G"},
+	} {
+		if got := bytesText(test.n); got != test.want {
+			t.Errorf("unexpected text for %d: got:%q want:%q", test.n, got, test.want)
+		}
+	}
+}
+
+type textWidget struct {
+	text string
+	err  error
+}
+
+func (w *textWidget) Text() (string, error) { return w.text, w.err }
+
+func TestPanelUpdate(t *testing.T) {
+	a := &textWidget{text: "a"}
+	b := &textWidget{text: "b"}
+	p := Panel{Widgets: map[layout.Position]Widget{
+		{Row: 0, Col: 0}: a,
+		{Row: 0, Col: 1}: b,
+	}}
+	var rendered map[layout.Position]string
+	render := func(pos layout.Position, text string) error {
+		rendered[pos] = text
+		return nil
+	}
+	var reported []error
+	report := func(_ layout.Position, err error) {
+		reported = append(reported, err)
+	}
+
+	errFailed := errors.New("failed")
+	for _, step := range []struct {
+		name string
+		set  func()
+		want map[layout.Position]string
+	}{
+		{
+			name: "initial",
+			want: map[layout.Position]string{{Row: 0, Col: 0}: "a", {Row: 0, Col: 1}: "b"},
+		},
+		{
+			name: "unchanged",
+			want: map[layout.Position]string{},
+		},
+		{
+			name: "changed",
+			set:  func() { b.text = "c" },
+			want: map[layout.Position]string{{Row: 0, Col: 1}: "c"},
+		},
+		{
+			name: "error",
+			set:  func() { a.err = errFailed },
+			want: map[layout.Position]string{{Row: 0, Col: 0}: "ERR"},
+		},
+	} {
+		if step.set != nil {
+			step.set()
+		}
+		rendered = make(map[layout.Position]string)
+		err := p.Update(render, report)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(rendered, step.want) {
+			t.Errorf("unexpected rendering for %s: got:%v want:%v", step.name, rendered, step.want)
+		}
+	}
+	if len(reported) != 1 || reported[0] != errFailed {
+		t.Errorf("unexpected reported errors: %v", reported)
+	}
+}
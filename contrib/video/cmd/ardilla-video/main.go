@@ -0,0 +1,85 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The ardilla-video command plays video across the keys of a Stream Deck
+// using ffmpeg to decode the input.
+//
+// Usage:
+//
+//	ardilla-video [-serial <serial>] [-gap <pixels>] [-fps <rate>] <ffmpeg input args>...
+//
+// For example, to show the first webcam on Linux:
+//
+//	ardilla-video -fps 10 -f v4l2 -i /dev/video0
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/contrib/video"
+)
+
+func main() {
+	os.Exit(Main())
+}
+
+func Main() int {
+	serial := flag.String("serial", "", "device serial number")
+	gap := flag.Int("gap", 0, "pixels between keys")
+	fps := flag.Float64("fps", 15, "maximum frame rate (no limit if not positive)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-serial <serial>] [-gap <pixels>] [-fps <rate>] <ffmpeg input args>...\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return 2
+	}
+
+	d, err := ardilla.NewDeck(ardilla.AnyPID, *serial)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+	b, err := d.CanvasBounds(*gap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get canvas: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	cmd := video.FFmpeg(ctx, b.Dx(), b.Dy(), flag.Args()...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start ffmpeg: %v\n", err)
+		return 1
+	}
+	err = cmd.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start ffmpeg: %v\n", err)
+		return 1
+	}
+	src, err := video.NewSource(stdout, b.Dx(), b.Dy())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read video: %v\n", err)
+		return 1
+	}
+	_, err = video.Play(ctx, d, src, *gap, *fps)
+	cancel()
+	cmd.Wait()
+	if err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "failed to play video: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package video shows video across the keys of a Stream Deck.
+//
+// Frames are read as packed 24-bit RGB rawvideo, as written by
+//
+//	ffmpeg -i INPUT -f rawvideo -pix_fmt rgb24 -s WIDTHxHEIGHT -
+//
+// The FFmpeg function returns a command that does this for any input
+// ffmpeg can read, including webcams using the v4l2 input format on Linux.
+package video
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Source is a source of video frames.
+type Source struct {
+	r      io.Reader
+	buf    []byte
+	frame  *image.RGBA
+	frames int
+}
+
+// NewSource returns a Source reading packed 24-bit RGB frames of the given
+// size from r.
+func NewSource(r io.Reader, width, height int) (*Source, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid frame size: %dx%d", width, height)
+	}
+	return &Source{
+		r:     r,
+		buf:   make([]byte, 3*width*height),
+		frame: image.NewRGBA(image.Rect(0, 0, width, height)),
+	}, nil
+}
+
+// Next returns the next frame from the source. The returned image is
+// reused by the next call to Next. At the end of the stream Next returns
+// io.EOF. A partial final frame is reported as io.ErrUnexpectedEOF.
+func (s *Source) Next() (*image.RGBA, error) {
+	_, err := io.ReadFull(s.r, s.buf)
+	if err != nil {
+		return nil, err
+	}
+	pix := s.frame.Pix
+	for i, j := 0, 0; i < len(s.buf); i, j = i+3, j+4 {
+		pix[j] = s.buf[i]
+		pix[j+1] = s.buf[i+1]
+		pix[j+2] = s.buf[i+2]
+		pix[j+3] = 0xff
+	}
+	s.frames++
+	return s.frame, nil
+}
+
+// Frames returns the number of frames read from the source.
+func (s *Source) Frames() int {
+	return s.frames
+}
+
+// FFmpeg returns a command that writes the video read by ffmpeg with the
+// given input arguments to its standard output as rawvideo frames of the
+// given size, suitable for reading with NewSource. For example, on Linux
+//
+//	FFmpeg(ctx, w, h, "-f", "v4l2", "-i", "/dev/video0")
+//
+// reads from the first webcam, and
+//
+//	FFmpeg(ctx, w, h, "-re", "-i", "movie.mp4")
+//
+// reads a file at its native frame rate.
+func FFmpeg(ctx context.Context, width, height int, input ...string) *exec.Cmd {
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin"}
+	args = append(args, input...)
+	args = append(args,
+		"-an",
+		"-vf", "scale="+strconv.Itoa(width)+":"+strconv.Itoa(height)+":force_original_aspect_ratio=decrease,"+
+			"pad="+strconv.Itoa(width)+":"+strconv.Itoa(height)+":-1:-1",
+		"-f", "rawvideo", "-pix_fmt", "rgb24", "-",
+	)
+	return exec.CommandContext(ctx, "ffmpeg", args...)
+}
+
+// Canvas is a display that video frames are rendered to. It is satisfied
+// by *ardilla.Deck.
+type Canvas interface {
+	SetCanvas(img image.Image, gap int) error
+}
+
+// Play renders frames from src across c, with gap pixels between keys as
+// described by ardilla.Deck.CanvasBounds, until the source is exhausted
+// or ctx is cancelled. At most fps frames are rendered each second;
+// frames arriving sooner after the last rendered frame are read and
+// dropped so that a live source does not fall behind. If fps is not
+// positive, every frame is rendered. Play returns the number of frames
+// rendered. Reaching the end of the source is not an error.
+func Play(ctx context.Context, c Canvas, src *Source, gap int, fps float64) (int, error) {
+	var interval time.Duration
+	if fps > 0 {
+		interval = time.Duration(float64(time.Second) / fps)
+	}
+	var (
+		shown int
+		last  time.Time
+	)
+	for {
+		err := ctx.Err()
+		if err != nil {
+			return shown, err
+		}
+		frame, err := src.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			return shown, err
+		}
+		now := time.Now()
+		if !last.IsZero() && now.Sub(last) < interval {
+			continue
+		}
+		err = c.SetCanvas(frame, gap)
+		if err != nil {
+			return shown, err
+		}
+		last = now
+		shown++
+	}
+}
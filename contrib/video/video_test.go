@@ -0,0 +1,100 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package video
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+type testCanvas struct {
+	frames []color.RGBA
+}
+
+func (c *testCanvas) SetCanvas(img image.Image, gap int) error {
+	c.frames = append(c.frames, color.RGBAModel.Convert(img.At(0, 0)).(color.RGBA))
+	return nil
+}
+
+func TestPlay(t *testing.T) {
+	const w, h = 4, 2
+	var stream []byte
+	for i := 0; i < 3; i++ {
+		stream = append(stream, bytes.Repeat([]byte{byte(i), 0x10, 0x20}, w*h)...)
+	}
+
+	for _, test := range []struct {
+		name    string
+		stream  []byte
+		fps     float64
+		want    []color.RGBA
+		wantErr error
+	}{
+		{
+			name:   "uncapped",
+			stream: stream,
+			want: []color.RGBA{
+				{R: 0, G: 0x10, B: 0x20, A: 0xff},
+				{R: 1, G: 0x10, B: 0x20, A: 0xff},
+				{R: 2, G: 0x10, B: 0x20, A: 0xff},
+			},
+		},
+		{
+			// Frames are read faster than one per second,
+			// so only the first is shown.
+			name:   "capped",
+			stream: stream,
+			fps:    1,
+			want: []color.RGBA{
+				{R: 0, G: 0x10, B: 0x20, A: 0xff},
+			},
+		},
+		{
+			name:   "short",
+			stream: stream[:len(stream)-1],
+			want: []color.RGBA{
+				{R: 0, G: 0x10, B: 0x20, A: 0xff},
+				{R: 1, G: 0x10, B: 0x20, A: 0xff},
+			},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			src, err := NewSource(bytes.NewReader(test.stream), w, h)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var c testCanvas
+			n, err := Play(context.Background(), &c, src, 0, test.fps)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("unexpected error: got:%v want:%v", err, test.wantErr)
+			}
+			if n != len(test.want) {
+				t.Errorf("unexpected number of frames shown: got:%d want:%d", n, len(test.want))
+			}
+			if len(c.frames) != len(test.want) {
+				t.Fatalf("unexpected frames: got:%v want:%v", c.frames, test.want)
+			}
+			for i, got := range c.frames {
+				if got != test.want[i] {
+					t.Errorf("unexpected frame %d: got:%v want:%v", i, got, test.want[i])
+				}
+			}
+			if wantRead := len(test.stream) / (3 * w * h); src.Frames() != wantRead {
+				t.Errorf("unexpected number of frames read: got:%d want:%d", src.Frames(), wantRead)
+			}
+		})
+	}
+
+	_, err := NewSource(nil, 0, 1)
+	if err == nil {
+		t.Error("expected error for invalid frame size")
+	}
+}
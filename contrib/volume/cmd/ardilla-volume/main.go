@@ -0,0 +1,129 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The ardilla-volume command shows and controls the volume of the default
+// PulseAudio or PipeWire sink on Stream Deck keys, with an optional live
+// level meter of the sink's output. It requires pactl or wpctl, and parec
+// for the level meter.
+//
+// Usage:
+//
+//	ardilla-volume [-serial <serial>] [-backend pactl|wpctl] [-keys <row>,<col>] [-meter <row>,<col>,<n>]
+//
+// The volume down, mute and volume up keys are placed on three adjacent
+// keys starting at the -keys key. The level meter occupies n keys of a
+// row starting at the -meter key.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/contrib/volume"
+	"github.com/kortschak/ardilla/contrib/vu"
+)
+
+func main() {
+	os.Exit(Main())
+}
+
+func Main() int {
+	serial := flag.String("serial", "", "device serial number")
+	backend := flag.String("backend", string(volume.Pactl), "sink control program (pactl or wpctl)")
+	keys := flag.String("keys", "0,0", "row,col of the volume down key")
+	meter := flag.String("meter", "", "row,col,n of the level meter (none if empty)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-serial <serial>] [-backend pactl|wpctl] [-keys <row>,<col>] [-meter <row>,<col>,<n>]\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		return 2
+	}
+	b := volume.Backend(*backend)
+	if b != volume.Pactl && b != volume.Wpctl {
+		fmt.Fprintf(os.Stderr, "invalid backend %q\n", *backend)
+		return 2
+	}
+	var row, col int
+	_, err := fmt.Sscanf(*keys, "%d,%d", &row, &col)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid keys %q: %v\n", *keys, err)
+		return 2
+	}
+	var meterRow, meterCol, meterLen int
+	if *meter != "" {
+		_, err = fmt.Sscanf(*meter, "%d,%d,%d", &meterRow, &meterCol, &meterLen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid meter %q: %v\n", *meter, err)
+			return 2
+		}
+	}
+
+	d, err := ardilla.NewDeck(ardilla.AnyPID, *serial)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open device: %v\n", err)
+		return 1
+	}
+	defer d.Close()
+	k, err := volume.New(d, row, col)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create keys: %v\n", err)
+		return 1
+	}
+	k.Backend = b
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if *meter != "" {
+		m, err := vu.New(d, meterRow, meterCol, meterLen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create meter: %v\n", err)
+			return 1
+		}
+		go func() {
+			err := volume.Monitor(ctx, m)
+			if err != nil && err != context.Canceled {
+				fmt.Fprintf(os.Stderr, "failed to monitor sink: %v\n", err)
+			}
+		}()
+	}
+	go func() {
+		var states []bool
+		for {
+			var (
+				changes []ardilla.KeyChange
+				err     error
+			)
+			states, changes, err = d.Poll(states)
+			if err != nil {
+				if ctx.Err() == nil {
+					fmt.Fprintf(os.Stderr, "failed to get states: %v\n", err)
+					cancel()
+				}
+				return
+			}
+			for _, c := range changes {
+				ev := ardilla.KeyEvent{Key: c.Key, Row: c.Row, Col: c.Col, Pressed: c.Pressed}
+				_, err = k.HandleKey(ctx, ev)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "failed to control volume: %v\n", err)
+				}
+			}
+		}
+	}()
+	err = k.Run(ctx, func(err error) {
+		fmt.Fprintf(os.Stderr, "failed to draw keys: %v\n", err)
+	})
+	if err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "failed to watch sink: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,505 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package volume provides Stream Deck keys showing and controlling the
+// volume and mute state of the default PulseAudio or PipeWire sink on
+// Linux, and a live level meter of the sink's output.
+//
+// The sink is controlled with pactl, from PulseAudio or pipewire-pulse, or
+// with wpctl, from WirePlumber, one of which must be installed. The level
+// meter reads the sink's monitor source with parec and is drawn by a
+// vu.Meter, which redraws only the keys that change. Stream Deck + dials
+// are not supported.
+package volume
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/contrib/vu"
+	"github.com/kortschak/ardilla/label"
+)
+
+// Backend is the program used to query and control the sink.
+type Backend string
+
+const (
+	// Pactl uses pactl with the @DEFAULT_SINK@ sink.
+	Pactl Backend = "pactl"
+
+	// Wpctl uses wpctl with the @DEFAULT_AUDIO_SINK@ sink.
+	Wpctl Backend = "wpctl"
+)
+
+// State is the volume state of the sink.
+type State struct {
+	// Volume is the sink's volume, where 1 is 100%.
+	Volume float64
+
+	Muted bool
+}
+
+// command returns the command running the backend with the given
+// arguments.
+func command(ctx context.Context, b Backend, args ...string) (*exec.Cmd, error) {
+	switch b {
+	case Pactl, Wpctl:
+		return exec.CommandContext(ctx, string(b), args...), nil
+	default:
+		return nil, fmt.Errorf("unknown backend: %q", b)
+	}
+}
+
+// run runs the backend with the given arguments and returns its output.
+func run(ctx context.Context, b Backend, args ...string) (string, error) {
+	cmd, err := command(ctx, b, args...)
+	if err != nil {
+		return "", err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w", msg, err)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// Get returns the volume state of the sink.
+func Get(ctx context.Context, b Backend) (State, error) {
+	switch b {
+	case Pactl:
+		out, err := run(ctx, b, "get-sink-volume", "@DEFAULT_SINK@")
+		if err != nil {
+			return State{}, err
+		}
+		vol, err := parsePactlVolume(out)
+		if err != nil {
+			return State{}, err
+		}
+		out, err = run(ctx, b, "get-sink-mute", "@DEFAULT_SINK@")
+		if err != nil {
+			return State{}, err
+		}
+		muted, err := parsePactlMute(out)
+		if err != nil {
+			return State{}, err
+		}
+		return State{Volume: vol, Muted: muted}, nil
+	case Wpctl:
+		out, err := run(ctx, b, "get-volume", "@DEFAULT_AUDIO_SINK@")
+		if err != nil {
+			return State{}, err
+		}
+		return parseWpctlVolume(out)
+	default:
+		return State{}, fmt.Errorf("unknown backend: %q", b)
+	}
+}
+
+// parsePactlVolume returns the mean channel volume from the output of
+// pactl get-sink-volume, for example
+//
+//	Volume: front-left: 32768 /  50% / -18.06 dB,   front-right: 32768 /  50% / -18.06 dB
+//	        balance 0.00
+func parsePactlVolume(out string) (float64, error) {
+	line, _, _ := strings.Cut(out, "\n")
+	if !strings.HasPrefix(line, "Volume:") {
+		return 0, fmt.Errorf("invalid pactl volume: %q", out)
+	}
+	var sum float64
+	n := 0
+	for _, f := range strings.Fields(line) {
+		pct, ok := strings.CutSuffix(f, "%")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(pct, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid pactl volume: %q", out)
+		}
+		sum += v
+		n++
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("invalid pactl volume: %q", out)
+	}
+	return sum / float64(n) / 100, nil
+}
+
+// parsePactlMute returns the mute state from the output of pactl
+// get-sink-mute, for example
+//
+//	Mute: no
+func parsePactlMute(out string) (bool, error) {
+	switch strings.TrimSpace(out) {
+	case "Mute: yes":
+		return true, nil
+	case "Mute: no":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid pactl mute state: %q", out)
+	}
+}
+
+// parseWpctlVolume returns the state from the output of wpctl get-volume,
+// for example
+//
+//	Volume: 0.50 [MUTED]
+func parseWpctlVolume(out string) (State, error) {
+	f := strings.Fields(out)
+	if len(f) < 2 || f[0] != "Volume:" {
+		return State{}, fmt.Errorf("invalid wpctl volume: %q", out)
+	}
+	v, err := strconv.ParseFloat(f[1], 64)
+	if err != nil {
+		return State{}, fmt.Errorf("invalid wpctl volume: %q", out)
+	}
+	return State{Volume: v, Muted: len(f) > 2 && f[2] == "[MUTED]"}, nil
+}
+
+// setArgs returns the backend arguments setting the sink's volume to v.
+func setArgs(b Backend, v float64) []string {
+	switch b {
+	case Pactl:
+		return []string{"set-sink-volume", "@DEFAULT_SINK@", strconv.Itoa(int(math.Round(v*100))) + "%"}
+	case Wpctl:
+		return []string{"set-volume", "@DEFAULT_AUDIO_SINK@", strconv.FormatFloat(v, 'f', 2, 64)}
+	default:
+		return nil
+	}
+}
+
+// Set sets the volume of the sink, where 1 is 100%. Volumes are clamped
+// to the range [0, 1].
+func Set(ctx context.Context, b Backend, v float64) error {
+	v = math.Max(0, math.Min(v, 1))
+	_, err := run(ctx, b, setArgs(b, v)...)
+	return err
+}
+
+// Adjust changes the volume of the sink by delta, clamping the result to
+// the range [0, 1].
+func Adjust(ctx context.Context, b Backend, delta float64) error {
+	s, err := Get(ctx, b)
+	if err != nil {
+		return err
+	}
+	return Set(ctx, b, s.Volume+delta)
+}
+
+// muteArgs returns the backend arguments toggling the sink's mute state.
+func muteArgs(b Backend) []string {
+	switch b {
+	case Pactl:
+		return []string{"set-sink-mute", "@DEFAULT_SINK@", "toggle"}
+	case Wpctl:
+		return []string{"set-mute", "@DEFAULT_AUDIO_SINK@", "toggle"}
+	default:
+		return nil
+	}
+}
+
+// ToggleMute mutes the sink if it is not muted, and unmutes it if it is.
+func ToggleMute(ctx context.Context, b Backend) error {
+	_, err := run(ctx, b, muteArgs(b)...)
+	return err
+}
+
+// Watch calls fn with the state of the sink when watching starts and each
+// time it changes until ctx is cancelled or watching fails. Changes are
+// followed with pactl subscribe for the Pactl backend, and by polling at
+// the given interval for the Wpctl backend, which has no change events.
+func Watch(ctx context.Context, b Backend, interval time.Duration, fn func(State)) error {
+	last, err := Get(ctx, b)
+	if err != nil {
+		return err
+	}
+	fn(last)
+	update := func() error {
+		s, err := Get(ctx, b)
+		if err != nil {
+			return err
+		}
+		if s != last {
+			last = s
+			fn(s)
+		}
+		return nil
+	}
+
+	switch b {
+	case Pactl:
+		cmd, err := command(ctx, b, "subscribe")
+		if err != nil {
+			return err
+		}
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		err = cmd.Start()
+		if err != nil {
+			return err
+		}
+		sc := bufio.NewScanner(out)
+		for sc.Scan() {
+			// Sink events change the volume or mute
+			// state, and server events may change the
+			// default sink.
+			line := sc.Text()
+			if !strings.Contains(line, " on sink ") && !strings.Contains(line, " on server") {
+				continue
+			}
+			err = update()
+			if err != nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return err
+			}
+		}
+		err = cmd.Wait()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			err = errors.New("pactl subscribe exited")
+		}
+		return err
+	default:
+		if interval <= 0 {
+			return fmt.Errorf("invalid polling interval: %v", interval)
+		}
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-t.C:
+				err = update()
+				if err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					return err
+				}
+			}
+		}
+	}
+}
+
+// Monitor feeds m with the output of the default sink, read from its
+// monitor source with parec, until ctx is cancelled or reading fails.
+// parec is provided by PulseAudio and pipewire-pulse.
+func Monitor(ctx context.Context, m *vu.Meter) error {
+	cmd := exec.CommandContext(ctx, "parec",
+		"--device=@DEFAULT_MONITOR@",
+		"--format=s16le",
+		"--channels=2",
+		"--rate=44100",
+		"--latency-msec=20",
+	)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	err = feed(out, m)
+	if err != nil {
+		cmd.Process.Kill()
+	}
+	werr := cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	if werr == nil {
+		werr = errors.New("parec exited")
+	}
+	return werr
+}
+
+// feed writes the signed 16-bit little-endian PCM samples read from r to
+// m in 20ms blocks of 44.1kHz stereo audio until r is exhausted or writing
+// to the meter fails.
+func feed(r io.Reader, m *vu.Meter) error {
+	const block = 44100 * 2 / 50
+	buf := make([]byte, 2*block)
+	samples := make([]int16, block)
+	for {
+		n, err := io.ReadFull(r, buf)
+		n /= 2
+		for i := range samples[:n] {
+			samples[i] = int16(binary.LittleEndian.Uint16(buf[2*i:]))
+		}
+		if n != 0 {
+			werr := m.WriteInt16(samples[:n])
+			if werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Deck is the set of deck methods used by Keys. It is satisfied by
+// *ardilla.Deck.
+type Deck interface {
+	Bounds() (image.Rectangle, error)
+	SetImage(row, col int, img image.Image) error
+}
+
+// Keys is a set of three adjacent keys that lower the volume, toggle
+// muting and raise the volume of the sink. The mute key shows the volume,
+// or that the sink is muted. The fields of Keys must not be altered after
+// the first call to Run.
+type Keys struct {
+	// Backend is the program used to control
+	// the sink. If Backend is empty, Pactl is
+	// used.
+	Backend Backend
+
+	// Step is the volume change made by the
+	// volume keys. If Step is zero, 0.05 is
+	// used.
+	Step float64
+
+	// Interval is the polling interval used to
+	// follow the Wpctl backend. If Interval is
+	// zero, 500ms is used.
+	Interval time.Duration
+
+	// Size is the font size of the key labels
+	// in pixels. If Size is zero, 14 is used.
+	Size float64
+
+	deck     Deck
+	row, col int
+	key      image.Rectangle
+}
+
+// New returns Keys drawn on d with the volume down key at row and col, and
+// the mute and volume up keys to its right.
+func New(d Deck, row, col int) (*Keys, error) {
+	key, err := d.Bounds()
+	if err != nil {
+		return nil, err
+	}
+	return &Keys{deck: d, row: row, col: col, key: key}, nil
+}
+
+func (k *Keys) backend() Backend {
+	if k.Backend == "" {
+		return Pactl
+	}
+	return k.Backend
+}
+
+// Run draws the keys and redraws them each time the sink's state changes
+// until ctx is cancelled or watching the sink fails. Rendering errors are
+// passed to errFn if it is not nil.
+func (k *Keys) Run(ctx context.Context, errFn func(error)) error {
+	interval := k.Interval
+	if interval == 0 {
+		interval = 500 * time.Millisecond
+	}
+	return Watch(ctx, k.backend(), interval, func(s State) {
+		err := k.Render(s)
+		if err != nil && errFn != nil {
+			errFn(err)
+		}
+	})
+}
+
+// HandleKey changes the volume or toggles muting if the key in ev is one
+// of the keys and is pressed. It returns whether the key is one of the
+// keys.
+func (k *Keys) HandleKey(ctx context.Context, ev ardilla.KeyEvent) (bool, error) {
+	if ev.Err != nil || ev.Lagged != 0 || ev.Stuck {
+		return false, nil
+	}
+	if ev.Row != k.row || ev.Col < k.col || k.col+2 < ev.Col {
+		return false, nil
+	}
+	if !ev.Pressed {
+		return true, nil
+	}
+	step := k.Step
+	if step == 0 {
+		step = 0.05
+	}
+	switch ev.Col - k.col {
+	case 0:
+		return true, Adjust(ctx, k.backend(), -step)
+	case 1:
+		return true, ToggleMute(ctx, k.backend())
+	default:
+		return true, Adjust(ctx, k.backend(), step)
+	}
+}
+
+// mutedBackground is the background of the mute key when the sink is
+// muted.
+var mutedBackground = color.RGBA{R: 0xc0, A: 0xff}
+
+// Render draws the keys for the state s.
+func (k *Keys) Render(s State) error {
+	size := k.Size
+	if size == 0 {
+		size = 14
+	}
+	status := strconv.Itoa(int(math.Round(s.Volume*100))) + "%"
+	var bg color.Color = color.Black
+	if s.Muted {
+		status = "Muted"
+		bg = mutedBackground
+	}
+	for i, key := range []struct {
+		text string
+		bg   color.Color
+	}{
+		{text: "Vol\n-", bg: color.Black},
+		{text: status, bg: bg},
+		{text: "Vol\n+", bg: color.Black},
+	} {
+		img, err := label.Render(k.key, key.text, size, color.White, key.bg)
+		if err != nil {
+			return err
+		}
+		err = k.deck.SetImage(k.row, k.col+i, img)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package volume
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/contrib/vu"
+)
+
+var parsePactlVolumeTests = []struct {
+	out     string
+	want    float64
+	wantErr bool
+}{
+	{
+		out:  "Volume: front-left: 32768 /  50% / -18.06 dB,   front-right: 32768 /  50% / -18.06 dB\n        balance 0.00\n",
+		want: 0.5,
+	},
+	{
+		out:  "Volume: front-left: 26214 /  40% / -23.88 dB,   front-right: 39322 /  60% / -13.31 dB\n        balance 0.33\n",
+		want: 0.5,
+	},
+	{
+		out:  "Volume: mono: 65536 / 100% / 0.00 dB\n",
+		want: 1,
+	},
+	{out: "Failure: No such entity\n", wantErr: true},
+	{out: "Volume: front-left: 32768\n", wantErr: true},
+}
+
+func TestParsePactlVolume(t *testing.T) {
+	for _, test := range parsePactlVolumeTests {
+		got, err := parsePactlVolume(test.out)
+		if (err != nil) != test.wantErr {
+			t.Errorf("unexpected error for %q: %v", test.out, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("unexpected volume for %q: got:%v want:%v", test.out, got, test.want)
+		}
+	}
+}
+
+func TestParsePactlMute(t *testing.T) {
+	for _, test := range []struct {
+		out     string
+		want    bool
+		wantErr bool
+	}{
+		{out: "Mute: yes\n", want: true},
+		{out: "Mute: no\n", want: false},
+		{out: "Mute: maybe\n", wantErr: true},
+	} {
+		got, err := parsePactlMute(test.out)
+		if (err != nil) != test.wantErr {
+			t.Errorf("unexpected error for %q: %v", test.out, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("unexpected mute state for %q: got:%t want:%t", test.out, got, test.want)
+		}
+	}
+}
+
+func TestParseWpctlVolume(t *testing.T) {
+	for _, test := range []struct {
+		out     string
+		want    State
+		wantErr bool
+	}{
+		{out: "Volume: 0.50\n", want: State{Volume: 0.5}},
+		{out: "Volume: 0.35 [MUTED]\n", want: State{Volume: 0.35, Muted: true}},
+		{out: "Volume:\n", wantErr: true},
+		{out: "Volume: loud\n", wantErr: true},
+	} {
+		got, err := parseWpctlVolume(test.out)
+		if (err != nil) != test.wantErr {
+			t.Errorf("unexpected error for %q: %v", test.out, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("unexpected state for %q: got:%+v want:%+v", test.out, got, test.want)
+		}
+	}
+}
+
+func TestArgs(t *testing.T) {
+	for _, test := range []struct {
+		backend  Backend
+		set      []string
+		mute     []string
+		volume   float64
+		validCmd bool
+	}{
+		{
+			backend: Pactl, volume: 0.456,
+			set:      []string{"set-sink-volume", "@DEFAULT_SINK@", "46%"},
+			mute:     []string{"set-sink-mute", "@DEFAULT_SINK@", "toggle"},
+			validCmd: true,
+		},
+		{
+			backend: Wpctl, volume: 0.456,
+			set:      []string{"set-volume", "@DEFAULT_AUDIO_SINK@", "0.46"},
+			mute:     []string{"set-mute", "@DEFAULT_AUDIO_SINK@", "toggle"},
+			validCmd: true,
+		},
+		{backend: "amixer", validCmd: false},
+	} {
+		if got := setArgs(test.backend, test.volume); !reflect.DeepEqual(got, test.set) {
+			t.Errorf("unexpected set arguments for %s: got:%q want:%q", test.backend, got, test.set)
+		}
+		if got := muteArgs(test.backend); !reflect.DeepEqual(got, test.mute) {
+			t.Errorf("unexpected mute arguments for %s: got:%q want:%q", test.backend, got, test.mute)
+		}
+		_, err := command(context.Background(), test.backend)
+		if (err == nil) != test.validCmd {
+			t.Errorf("unexpected command error for %s: %v", test.backend, err)
+		}
+	}
+}
+
+// fakeWpctl is a wpctl replacement that keeps its state in the file
+// named by FAKE_WPCTL_STATE.
+const fakeWpctl = `#!/bin/sh
+case "$1" in
+get-volume)
+	cat "$FAKE_WPCTL_STATE" ;;
+set-volume)
+	if grep -q MUTED "$FAKE_WPCTL_STATE"; then
+		echo "Volume: $3 [MUTED]" > "$FAKE_WPCTL_STATE"
+	else
+		echo "Volume: $3" > "$FAKE_WPCTL_STATE"
+	fi ;;
+set-mute)
+	if grep -q MUTED "$FAKE_WPCTL_STATE"; then
+		sed 's/ \[MUTED\]//' "$FAKE_WPCTL_STATE" > "$FAKE_WPCTL_STATE.new"
+	else
+		sed 's/$/ [MUTED]/' "$FAKE_WPCTL_STATE" > "$FAKE_WPCTL_STATE.new"
+	fi
+	mv "$FAKE_WPCTL_STATE.new" "$FAKE_WPCTL_STATE" ;;
+*)
+	echo "unknown command: $1" >&2
+	exit 1 ;;
+esac
+`
+
+// installFakeWpctl puts fakeWpctl on the path with an initial volume.
+func installFakeWpctl(t *testing.T, initial string) {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("fake wpctl requires a Linux shell")
+	}
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "wpctl"), []byte(fakeWpctl), 0o755)
+	if err != nil {
+		t.Fatalf("failed to write fake wpctl: %v", err)
+	}
+	state := filepath.Join(dir, "state")
+	err = os.WriteFile(state, []byte(initial+"\n"), 0o644)
+	if err != nil {
+		t.Fatalf("failed to write fake wpctl state: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_WPCTL_STATE", state)
+}
+
+func TestControl(t *testing.T) {
+	installFakeWpctl(t, "Volume: 0.50")
+	ctx := context.Background()
+	for _, step := range []struct {
+		do   func() error
+		want State
+	}{
+		{do: func() error { return Adjust(ctx, Wpctl, 0.05) }, want: State{Volume: 0.55}},
+		{do: func() error { return ToggleMute(ctx, Wpctl) }, want: State{Volume: 0.55, Muted: true}},
+		{do: func() error { return Adjust(ctx, Wpctl, 1) }, want: State{Volume: 1, Muted: true}},
+		{do: func() error { return ToggleMute(ctx, Wpctl) }, want: State{Volume: 1}},
+		{do: func() error { return Set(ctx, Wpctl, -1) }, want: State{Volume: 0}},
+	} {
+		err := step.do()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := Get(ctx, Wpctl)
+		if err != nil {
+			t.Fatalf("unexpected error getting state: %v", err)
+		}
+		if got != step.want {
+			t.Errorf("unexpected state: got:%+v want:%+v", got, step.want)
+		}
+	}
+}
+
+// testDeck is a 3×5 deck of 72×72 keys that records the images set on it.
+type testDeck struct {
+	mu     sync.Mutex
+	images map[[2]int]image.Image
+}
+
+func newTestDeck() *testDeck {
+	return &testDeck{images: make(map[[2]int]image.Image)}
+}
+
+func (d *testDeck) Bounds() (image.Rectangle, error) {
+	return image.Rect(0, 0, 72, 72), nil
+}
+
+func (d *testDeck) SetImage(row, col int, img image.Image) error {
+	if row < 0 || 3 <= row || col < 0 || 5 <= col {
+		return errors.New("key out of bounds")
+	}
+	d.mu.Lock()
+	d.images[[2]int{row, col}] = img
+	d.mu.Unlock()
+	return nil
+}
+
+// background returns the colour of the corner of the key's image.
+func (d *testDeck) background(row, col int) color.Color {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img, ok := d.images[[2]int{row, col}]
+	if !ok {
+		return nil
+	}
+	return color.RGBAModel.Convert(img.At(0, 0))
+}
+
+func TestKeys(t *testing.T) {
+	installFakeWpctl(t, "Volume: 0.50")
+	d := newTestDeck()
+	k, err := New(d, 1, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	k.Backend = Wpctl
+	k.Interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- k.Run(ctx, func(err error) { t.Errorf("unexpected render error: %v", err) })
+	}()
+
+	waitFor := func(what string, want color.Color) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if d.background(1, 2) == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %s: got:%v want:%v", what, d.background(1, 2), want)
+	}
+	waitFor("unmuted", color.RGBA{A: 0xff})
+
+	for _, test := range []struct {
+		ev   ardilla.KeyEvent
+		want bool
+	}{
+		{ev: ardilla.KeyEvent{Row: 0, Col: 1, Pressed: true}, want: false},
+		{ev: ardilla.KeyEvent{Row: 1, Col: 4, Pressed: true}, want: false},
+		{ev: ardilla.KeyEvent{Row: 1, Col: 2, Pressed: true, Stuck: true}, want: false},
+		{ev: ardilla.KeyEvent{Lagged: 1}, want: false},
+		{ev: ardilla.KeyEvent{Row: 1, Col: 3}, want: true},
+		{ev: ardilla.KeyEvent{Row: 1, Col: 3, Pressed: true}, want: true},
+		{ev: ardilla.KeyEvent{Row: 1, Col: 2, Pressed: true}, want: true},
+	} {
+		got, err := k.HandleKey(ctx, test.ev)
+		if err != nil {
+			t.Errorf("unexpected error for %+v: %v", test.ev, err)
+		}
+		if got != test.want {
+			t.Errorf("unexpected handled result for %+v: got:%t want:%t", test.ev, got, test.want)
+		}
+	}
+	waitFor("muted", mutedBackground)
+	s, err := Get(ctx, Wpctl)
+	if err != nil {
+		t.Fatalf("unexpected error getting state: %v", err)
+	}
+	if want := (State{Volume: 0.55, Muted: true}); s != want {
+		t.Errorf("unexpected state after key presses: got:%+v want:%+v", s, want)
+	}
+	d.mu.Lock()
+	n := len(d.images)
+	d.mu.Unlock()
+	if n != 3 {
+		t.Errorf("unexpected number of keys drawn: got:%d want:3", n)
+	}
+
+	cancel()
+	select {
+	case err = <-done:
+		if err != context.Canceled {
+			t.Errorf("unexpected error from Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Run to return")
+	}
+}
+
+func TestFeed(t *testing.T) {
+	m, err := vu.New(newTestDeck(), 0, 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A full-scale square wave followed by a
+	// truncated sample.
+	var buf bytes.Buffer
+	for i := 0; i < 4000; i++ {
+		v := int16(0x7fff)
+		if i%2 == 0 {
+			v = -v
+		}
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.WriteByte(0)
+	err = feed(&buf, m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := m.Level(); got < -1 {
+		t.Errorf("unexpected level after full-scale input: got:%v dBFS", got)
+	}
+}
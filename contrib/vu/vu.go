@@ -0,0 +1,236 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vu renders a live audio level meter across a row of Stream Deck
+// keys.
+//
+// Samples are passed to a Meter from an audio capture callback. The meter
+// tracks the peak level with a falling decay, and redraws at a limited
+// frame rate only the keys whose lit segments have changed, so it may be
+// fed at audio callback rates without saturating the device.
+package vu
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+	"time"
+)
+
+// Deck is the set of deck methods used by a Meter. It is satisfied by
+// *ardilla.Deck.
+type Deck interface {
+	Bounds() (image.Rectangle, error)
+	SetImage(row, col int, img image.Image) error
+}
+
+// Meter is a level meter occupying n keys of a row. The meter's fields
+// must not be altered after the first call to Write.
+type Meter struct {
+	// Floor is the level in dBFS shown by an
+	// empty meter. If Floor is zero, -60 is used.
+	Floor float64
+
+	// Decay is the rate in dB per second at which
+	// the shown level falls after a peak. If
+	// Decay is zero, 20 is used.
+	Decay float64
+
+	// FPS is the maximum number of times per
+	// second the meter is redrawn. If FPS is zero,
+	// 30 is used.
+	FPS float64
+
+	// Segments is the number of segments shown
+	// on each key. If Segments is zero, 4 is used.
+	Segments int
+
+	// Warn and Clip are the fractions of the
+	// meter's length above which segments are
+	// shown in yellow and red. If zero, 0.7 and
+	// 0.9 are used.
+	Warn, Clip float64
+
+	deck     Deck
+	row, col int
+	n        int
+	bounds   image.Rectangle
+
+	mu     sync.Mutex
+	now    func() time.Time
+	level  float64 // Current level in dBFS.
+	last   time.Time
+	drawn  time.Time
+	shown  []int
+	images map[[2]int]image.Image
+}
+
+// New returns a Meter drawn on d using the n keys starting at row and col
+// and extending to the right.
+func New(d Deck, row, col, n int) (*Meter, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid number of keys: %d", n)
+	}
+	b, err := d.Bounds()
+	if err != nil {
+		return nil, err
+	}
+	shown := make([]int, n)
+	for i := range shown {
+		shown[i] = -1
+	}
+	return &Meter{
+		deck:   d,
+		row:    row,
+		col:    col,
+		n:      n,
+		bounds: b,
+		now:    time.Now,
+		level:  math.Inf(-1),
+		shown:  shown,
+		images: make(map[[2]int]image.Image),
+	}, nil
+}
+
+// Write updates the meter with the given PCM samples, which are expected
+// to be in the range [-1, 1]. Samples from all channels may be passed
+// together. The meter is redrawn if the shown level has changed and the
+// frame rate allows.
+func (m *Meter) Write(samples []float32) error {
+	var peak float64
+	for _, s := range samples {
+		if a := math.Abs(float64(s)); a > peak {
+			peak = a
+		}
+	}
+	return m.update(20 * math.Log10(peak))
+}
+
+// WriteInt16 is like Write for signed 16-bit PCM samples.
+func (m *Meter) WriteInt16(samples []int16) error {
+	var peak float64
+	for _, s := range samples {
+		if a := math.Abs(float64(s)); a > peak {
+			peak = a
+		}
+	}
+	return m.update(20 * math.Log10(peak/(1<<15)))
+}
+
+// update sets the meter's level to the given peak, or the decayed level if
+// that is higher, and redraws the meter if needed.
+func (m *Meter) update(peak float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if !m.last.IsZero() {
+		m.level -= orDefault(m.Decay, 20) * now.Sub(m.last).Seconds()
+	}
+	m.last = now
+	if peak > m.level {
+		m.level = peak
+	}
+
+	fps := orDefault(m.FPS, 30)
+	if !m.drawn.IsZero() && now.Sub(m.drawn) < time.Duration(float64(time.Second)/fps) {
+		return nil
+	}
+	m.drawn = now
+	return m.draw()
+}
+
+// Level returns the level currently held by the meter in dBFS.
+func (m *Meter) Level() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.level
+}
+
+// draw renders the keys whose lit segments differ from those shown. The
+// caller must hold m.mu.
+func (m *Meter) draw() error {
+	segs := m.segments()
+	floor := orDefault(m.Floor, -60)
+	frac := 1 - m.level/floor
+	if frac < 0 || math.IsNaN(frac) {
+		frac = 0
+	}
+	if frac > 1 {
+		frac = 1
+	}
+	lit := int(math.Round(frac * float64(m.n*segs)))
+	for i := 0; i < m.n; i++ {
+		k := lit - i*segs
+		if k < 0 {
+			k = 0
+		}
+		if k > segs {
+			k = segs
+		}
+		if m.shown[i] == k {
+			continue
+		}
+		err := m.deck.SetImage(m.row, m.col+i, m.image(i, k))
+		if err != nil {
+			// Leave the key marked as unknown so
+			// that it is redrawn next time.
+			m.shown[i] = -1
+			return err
+		}
+		m.shown[i] = k
+	}
+	return nil
+}
+
+// image returns the image for the ith key of the meter with lit segments
+// lit. The caller must hold m.mu.
+func (m *Meter) image(i, lit int) image.Image {
+	if img, ok := m.images[[2]int{i, lit}]; ok {
+		return img
+	}
+	segs := m.segments()
+	warn := orDefault(m.Warn, 0.7)
+	clip := orDefault(m.Clip, 0.9)
+	img := image.NewRGBA(m.bounds)
+	b := m.bounds
+	w := b.Dx() / segs
+	for s := 0; s < segs; s++ {
+		pos := float64(i*segs+s) / float64(m.n*segs)
+		var c color.RGBA
+		switch {
+		case pos >= clip:
+			c = color.RGBA{R: 0xff, A: 0xff}
+		case pos >= warn:
+			c = color.RGBA{R: 0xff, G: 0xd0, A: 0xff}
+		default:
+			c = color.RGBA{G: 0xd0, A: 0xff}
+		}
+		if s >= lit {
+			// Unlit segments are shown dimmed.
+			c = color.RGBA{R: c.R / 6, G: c.G / 6, B: c.B / 6, A: 0xff}
+		}
+		r := image.Rect(b.Min.X+s*w+1, b.Min.Y+b.Dy()/4, b.Min.X+(s+1)*w-1, b.Max.Y-b.Dy()/4)
+		draw.Draw(img, r, &image.Uniform{c}, image.Point{}, draw.Src)
+	}
+	m.images[[2]int{i, lit}] = img
+	return img
+}
+
+func (m *Meter) segments() int {
+	if m.Segments <= 0 {
+		return 4
+	}
+	return m.Segments
+}
+
+func orDefault(v, def float64) float64 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
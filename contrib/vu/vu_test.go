@@ -0,0 +1,100 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vu
+
+import (
+	"fmt"
+	"image"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testDeck struct {
+	calls []string
+}
+
+func (d *testDeck) Bounds() (image.Rectangle, error) {
+	return image.Rect(0, 0, 72, 72), nil
+}
+
+func (d *testDeck) SetImage(row, col int, img image.Image) error {
+	d.calls = append(d.calls, fmt.Sprintf("SetImage(%d, %d)", row, col))
+	return nil
+}
+
+func TestMeter(t *testing.T) {
+	var d testDeck
+	m, err := New(&d, 1, 2, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Unix(0, 0)
+	m.now = func() time.Time { return now }
+
+	silence := make([]float32, 16)
+	full := []float32{0, 0.5, -1, 0.25}
+
+	for _, step := range []struct {
+		name      string
+		advance   time.Duration
+		samples   []float32
+		wantLevel float64
+		wantCalls []string
+	}{
+		{
+			name:      "silence",
+			samples:   silence,
+			wantLevel: -60,
+			wantCalls: []string{"SetImage(1, 2)", "SetImage(1, 3)"},
+		},
+		{
+			name:      "full scale within frame",
+			advance:   time.Millisecond,
+			samples:   full,
+			wantLevel: 0,
+			wantCalls: nil,
+		},
+		{
+			name:      "full scale",
+			advance:   100 * time.Millisecond,
+			samples:   full,
+			wantLevel: 0,
+			wantCalls: []string{"SetImage(1, 2)", "SetImage(1, 3)"},
+		},
+		{
+			name:      "decay",
+			advance:   1500 * time.Millisecond,
+			samples:   silence,
+			wantLevel: -30,
+			wantCalls: []string{"SetImage(1, 3)"},
+		},
+		{
+			name:      "unchanged",
+			advance:   100 * time.Millisecond,
+			samples:   []float32{0.0316}, // About -30dBFS.
+			wantLevel: -30,
+			wantCalls: nil,
+		},
+	} {
+		now = now.Add(step.advance)
+		d.calls = nil
+		err := m.Write(step.samples)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", step.name, err)
+		}
+		if got := m.Level(); step.wantLevel > -60 && (got < step.wantLevel-0.1 || step.wantLevel+0.1 < got) {
+			t.Errorf("unexpected level for %s: got:%.2f want:%.2f", step.name, got, step.wantLevel)
+		}
+		if !reflect.DeepEqual(d.calls, step.wantCalls) {
+			t.Errorf("unexpected calls for %s:\ngot: %q\nwant:%q", step.name, d.calls, step.wantCalls)
+		}
+	}
+
+	_, err = New(&d, 0, 0, 0)
+	if err == nil {
+		t.Error("expected error for empty meter")
+	}
+}
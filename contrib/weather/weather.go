@@ -0,0 +1,247 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package weather provides a Stream Deck widget showing the current
+// weather from the Open-Meteo forecast API.
+//
+// Widget updates are fetched asynchronously by Run, so that rendering a
+// key never waits on the network; Text returns the most recently fetched
+// conditions.
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultURL is the Open-Meteo forecast API endpoint.
+const DefaultURL = "https://api.open-meteo.com/v1/forecast"
+
+// ErrNoData is returned by Widget.Text before conditions have been fetched.
+var ErrNoData = errors.New("no weather data")
+
+// Conditions is the current weather at a location.
+type Conditions struct {
+	// Temperature is the air temperature in the
+	// requested unit.
+	Temperature float64
+
+	// Fahrenheit is whether the temperature is in
+	// degrees Fahrenheit rather than Celsius.
+	Fahrenheit bool
+
+	// WindSpeed is the wind speed in km/h.
+	WindSpeed float64
+
+	// Code is the WMO weather interpretation code.
+	Code int
+
+	// Time is the time of the observation.
+	Time time.Time
+}
+
+// Text returns the temperature and a short description of the weather.
+func (c Conditions) Text() string {
+	unit := "C"
+	if c.Fahrenheit {
+		unit = "F"
+	}
+	return fmt.Sprintf("%d°%s\n%s", int(math.Round(c.Temperature)), unit, Description(c.Code))
+}
+
+// Description returns a short description of the WMO weather
+// interpretation code.
+func Description(code int) string {
+	switch code {
+	case 0:
+		return "Clear"
+	case 1, 2:
+		return "Partly cloudy"
+	case 3:
+		return "Overcast"
+	case 45, 48:
+		return "Fog"
+	case 51, 53, 55, 56, 57:
+		return "Drizzle"
+	case 61, 63, 65, 66, 67, 80, 81, 82:
+		return "Rain"
+	case 71, 73, 75, 77, 85, 86:
+		return "Snow"
+	case 95, 96, 99:
+		return "Storm"
+	default:
+		return "Unknown"
+	}
+}
+
+// Client fetches weather conditions.
+type Client struct {
+	// URL is the forecast API endpoint. If URL is
+	// empty, DefaultURL is used.
+	URL string
+
+	// HTTP is the client used for requests. If HTTP
+	// is nil, http.DefaultClient is used.
+	HTTP *http.Client
+}
+
+// Current returns the current conditions at the given latitude and
+// longitude.
+func (c *Client) Current(ctx context.Context, lat, lon float64, fahrenheit bool) (Conditions, error) {
+	endpoint := c.URL
+	if endpoint == "" {
+		endpoint = DefaultURL
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return Conditions{}, err
+	}
+	q := u.Query()
+	q.Set("latitude", strconv.FormatFloat(lat, 'f', -1, 64))
+	q.Set("longitude", strconv.FormatFloat(lon, 'f', -1, 64))
+	q.Set("current_weather", "true")
+	q.Set("timezone", "UTC")
+	if fahrenheit {
+		q.Set("temperature_unit", "fahrenheit")
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Conditions{}, err
+	}
+	cli := c.HTTP
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return Conditions{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Conditions{}, fmt.Errorf("weather request failed: %s", resp.Status)
+	}
+	var body struct {
+		Current *struct {
+			Temperature float64 `json:"temperature"`
+			WindSpeed   float64 `json:"windspeed"`
+			Code        int     `json:"weathercode"`
+			Time        string  `json:"time"`
+		} `json:"current_weather"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return Conditions{}, fmt.Errorf("invalid weather response: %w", err)
+	}
+	if body.Current == nil {
+		return Conditions{}, errors.New("invalid weather response: missing current weather")
+	}
+	// Times are in ISO 8601 without seconds or zone,
+	// in UTC as requested.
+	t, err := time.Parse("2006-01-02T15:04", body.Current.Time)
+	if err != nil {
+		return Conditions{}, fmt.Errorf("invalid weather time: %w", err)
+	}
+	return Conditions{
+		Temperature: body.Current.Temperature,
+		Fahrenheit:  fahrenheit,
+		WindSpeed:   body.Current.WindSpeed,
+		Code:        body.Current.Code,
+		Time:        t,
+	}, nil
+}
+
+// Widget is a key widget showing the current weather at a location.
+type Widget struct {
+	// Latitude and Longitude are the location in
+	// decimal degrees.
+	Latitude, Longitude float64
+
+	// Fahrenheit is whether temperatures are shown
+	// in degrees Fahrenheit.
+	Fahrenheit bool
+
+	// Refresh is the interval between updates. If
+	// Refresh is zero, 15 minutes is used.
+	Refresh time.Duration
+
+	// Client is used to fetch conditions.
+	Client Client
+
+	mu   sync.Mutex
+	cond Conditions
+	err  error
+}
+
+// Run fetches the weather immediately and then every Refresh interval until
+// ctx is cancelled, calling update with the widget's text each time it
+// changes. Failed fetches are retained and reported by Text, and update is
+// called with the text "ERR", but do not stop Run. Previously fetched
+// conditions are kept on failure. Run returns ctx.Err.
+func (w *Widget) Run(ctx context.Context, update func(text string)) error {
+	refresh := w.Refresh
+	if refresh <= 0 {
+		refresh = 15 * time.Minute
+	}
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	var last string
+	for {
+		cond, err := w.Client.Current(ctx, w.Latitude, w.Longitude, w.Fahrenheit)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		w.mu.Lock()
+		if err == nil {
+			w.cond = cond
+		}
+		w.err = err
+		w.mu.Unlock()
+
+		text := "ERR"
+		if err == nil {
+			text = cond.Text()
+		}
+		if text != last && update != nil {
+			update(text)
+		}
+		last = text
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Conditions returns the most recently fetched conditions. If no
+// conditions have been fetched, ok is false.
+func (w *Widget) Conditions() (c Conditions, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.cond, !w.cond.Time.IsZero()
+}
+
+// Text returns the text for the most recently fetched conditions, or the
+// error from the last fetch if it failed. It does not block on the network.
+func (w *Widget) Text() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return "", w.err
+	}
+	if w.cond.Time.IsZero() {
+		return "", ErrNoData
+	}
+	return w.cond.Text(), nil
+}
@@ -0,0 +1,127 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCurrent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("latitude") != "-34.93" || q.Get("longitude") != "138.6" || q.Get("current_weather") != "true" {
+			http.Error(w, "bad query: "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		unit := q.Get("temperature_unit")
+		temp := "12.6"
+		if unit == "fahrenheit" {
+			temp = "54.7"
+		}
+		w.Write([]byte(`{"latitude":-34.93,"current_weather":{"temperature":` + temp + `,"windspeed":11.2,"weathercode":61,"time":"2023-06-01T04:00"}}`))
+	}))
+	defer srv.Close()
+
+	c := Client{URL: srv.URL}
+	for _, test := range []struct {
+		fahrenheit bool
+		want       Conditions
+		wantText   string
+	}{
+		{
+			want: Conditions{
+				Temperature: 12.6,
+				WindSpeed:   11.2,
+				Code:        61,
+				Time:        time.Date(2023, 6, 1, 4, 0, 0, 0, time.UTC),
+			},
+			wantText: "13°C\nRain",
+		},
+		{
+			fahrenheit: true,
+			want: Conditions{
+				Temperature: 54.7,
+				Fahrenheit:  true,
+				WindSpeed:   11.2,
+				Code:        61,
+				Time:        time.Date(2023, 6, 1, 4, 0, 0, 0, time.UTC),
+			},
+			wantText: "55°F\nRain",
+		},
+	} {
+		got, err := c.Current(context.Background(), -34.93, 138.6, test.fahrenheit)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != test.want {
+			t.Errorf("unexpected conditions: got:%+v want:%+v", got, test.want)
+		}
+		if text := got.Text(); text != test.wantText {
+			t.Errorf("unexpected text: got:%q want:%q", text, test.wantText)
+		}
+	}
+
+	_, err := c.Current(context.Background(), 0, 0, false)
+	if err == nil {
+		t.Error("expected error for failed request")
+	}
+}
+
+func TestWidget(t *testing.T) {
+	fail := make(chan bool, 1)
+	fail <- false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if <-fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"current_weather":{"temperature":20,"windspeed":0,"weathercode":0,"time":"2023-06-01T04:00"}}`))
+	}))
+	defer srv.Close()
+
+	w := Widget{Refresh: time.Millisecond, Client: Client{URL: srv.URL}}
+	_, err := w.Text()
+	if !errors.Is(err, ErrNoData) {
+		t.Errorf("unexpected error before update: got:%v want:%v", err, ErrNoData)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan string)
+	go w.Run(ctx, func(text string) {
+		select {
+		case updates <- text:
+		case <-ctx.Done():
+		}
+	})
+
+	if got := <-updates; got != "20°C\nClear" {
+		t.Errorf("unexpected update: got:%q want:%q", got, "20°C\nClear")
+	}
+	text, err := w.Text()
+	if err != nil || text != "20°C\nClear" {
+		t.Errorf("unexpected text: got:%q %v", text, err)
+	}
+
+	fail <- true
+	if got := <-updates; got != "ERR" {
+		t.Errorf("unexpected update after failure: got:%q want:%q", got, "ERR")
+	}
+	_, err = w.Text()
+	if err == nil {
+		t.Error("expected error after failed update")
+	}
+	if _, ok := w.Conditions(); !ok {
+		t.Error("conditions not retained after failed update")
+	}
+	cancel()
+	// Unblock any request in flight.
+	close(fail)
+}
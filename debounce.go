@@ -0,0 +1,122 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import "time"
+
+// pedalDebounce is the default debounce interval for the Pedal. The
+// Pedal's mechanical switches bounce for longer than the buttons of the
+// other decks.
+const pedalDebounce = 25 * time.Millisecond
+
+// SetDebounce sets the minimum interval between accepted state changes of
+// a key in the key event stream. Changes that occur within the interval
+// after an accepted change are treated as switch bounce; if the key has
+// not returned to its accepted state by the end of the interval, the
+// change is delivered then. A zero interval disables debouncing. The
+// Pedal is debounced by default; other decks are not.
+//
+// SetDebounce takes effect for key event streams started after the call.
+func (d *Deck) SetDebounce(interval time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.debounce = interval
+}
+
+// SetStuckTimeout sets the duration after which a key that has been held
+// pressed is reported as stuck in the key event stream. A stuck key is
+// reported once for each press. A zero timeout, the default, disables
+// stuck key detection.
+//
+// SetStuckTimeout takes effect for key event streams started after the
+// call.
+func (d *Deck) SetStuckTimeout(timeout time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stuckTimeout = timeout
+}
+
+// keyConditioner applies debouncing and stuck key detection to a sequence
+// of key state reports.
+type keyConditioner struct {
+	debounce time.Duration
+	stuck    time.Duration
+	cols     int
+
+	// state is the conditioned key state and
+	// raw is the most recently reported state.
+	state []bool
+	raw   []bool
+
+	// changed is the time of the last accepted
+	// change for each key and reported is whether
+	// the current press has been reported stuck.
+	changed  []time.Time
+	reported []bool
+}
+
+// newKeyConditioner returns a keyConditioner for n keys in rows of cols,
+// with all keys released.
+func newKeyConditioner(n, cols int, debounce, stuck time.Duration) *keyConditioner {
+	return &keyConditioner{
+		debounce: debounce,
+		stuck:    stuck,
+		cols:     cols,
+		state:    make([]bool, n),
+		raw:      make([]bool, n),
+		changed:  make([]time.Time, n),
+		reported: make([]bool, n),
+	}
+}
+
+// update merges states, which may be nil or short, into the reported key
+// states and calls fn for each change that is accepted at time now and
+// for each key that has become stuck. update returns false if fn returns
+// false.
+func (c *keyConditioner) update(states []bool, now time.Time, fn func(ch KeyChange, stuck bool) bool) bool {
+	copy(c.raw, states)
+	for i, pressed := range c.raw {
+		ch := KeyChange{Key: i, Row: i / c.cols, Col: i % c.cols, Pressed: pressed}
+		switch {
+		case pressed != c.state[i]:
+			if c.debounce > 0 && !c.changed[i].IsZero() && now.Sub(c.changed[i]) < c.debounce {
+				continue
+			}
+			c.state[i] = pressed
+			c.changed[i] = now
+			c.reported[i] = false
+			if !fn(ch, false) {
+				return false
+			}
+		case pressed && c.stuck > 0 && !c.reported[i] && now.Sub(c.changed[i]) >= c.stuck:
+			c.reported[i] = true
+			if !fn(ch, true) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// next returns the time at which the next pending change is due to be
+// accepted or a held key is due to be reported stuck. If there is no
+// pending change or held key, ok is false.
+func (c *keyConditioner) next() (due time.Time, ok bool) {
+	for i, pressed := range c.raw {
+		var t time.Time
+		switch {
+		case pressed != c.state[i]:
+			t = c.changed[i].Add(c.debounce)
+		case pressed && c.stuck > 0 && !c.reported[i]:
+			t = c.changed[i].Add(c.stuck)
+		default:
+			continue
+		}
+		if !ok || t.Before(due) {
+			due, ok = t, true
+		}
+	}
+	return due, ok
+}
@@ -0,0 +1,98 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeyConditioner(t *testing.T) {
+	type event struct {
+		key     int
+		pressed bool
+		stuck   bool
+	}
+	type step struct {
+		at     time.Duration
+		states []bool // nil for a timer expiry.
+		want   []event
+	}
+	for _, test := range []struct {
+		name     string
+		debounce time.Duration
+		stuck    time.Duration
+		steps    []step
+		wantNext []time.Duration // -1 for no pending change.
+	}{
+		{
+			name: "none",
+			steps: []step{
+				{at: 0, states: []bool{true, false, false}, want: []event{{key: 0, pressed: true}}},
+				{at: 1, states: []bool{false, false, false}, want: []event{{key: 0, pressed: false}}},
+				{at: 2, states: []bool{true, false, false}, want: []event{{key: 0, pressed: true}}},
+			},
+			wantNext: []time.Duration{-1, -1, -1},
+		},
+		{
+			name:     "bounce",
+			debounce: 20,
+			steps: []step{
+				{at: 0, states: []bool{true, false, false}, want: []event{{key: 0, pressed: true}}},
+				{at: 2, states: []bool{false, false, false}},
+				{at: 4, states: []bool{true, false, false}},
+				{at: 6, states: []bool{false, true, false}, want: []event{{key: 1, pressed: true}}},
+				{at: 20, want: []event{{key: 0, pressed: false}}},
+				{at: 30, states: []bool{true, true, false}},
+				{at: 40, want: []event{{key: 0, pressed: true}}},
+			},
+			wantNext: []time.Duration{-1, 20, -1, 20, -1, 40, -1},
+		},
+		{
+			name:     "short_report",
+			debounce: 20,
+			steps: []step{
+				{at: 0, states: []bool{false, false, true}, want: []event{{key: 2, pressed: true}}},
+				{at: 30, states: []bool{true}, want: []event{{key: 0, pressed: true}}},
+			},
+			wantNext: []time.Duration{-1, -1},
+		},
+		{
+			name:     "stuck",
+			debounce: 20,
+			stuck:    100,
+			steps: []step{
+				{at: 0, states: []bool{false, true, false}, want: []event{{key: 1, pressed: true}}},
+				{at: 50, states: []bool{false, true, true}, want: []event{{key: 2, pressed: true}}},
+				{at: 100, want: []event{{key: 1, pressed: true, stuck: true}}},
+				{at: 150, want: []event{{key: 2, pressed: true, stuck: true}}},
+				{at: 200, states: []bool{false, false, true}, want: []event{{key: 1, pressed: false}}},
+			},
+			wantNext: []time.Duration{100, 100, 150, -1, -1},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var epoch time.Time
+			epoch = epoch.Add(time.Hour)
+			c := newKeyConditioner(3, 3, test.debounce, test.stuck)
+			for i, s := range test.steps {
+				var got []event
+				c.update(s.states, epoch.Add(s.at), func(ch KeyChange, stuck bool) bool {
+					got = append(got, event{ch.Key, ch.Pressed, stuck})
+					return true
+				})
+				if !reflect.DeepEqual(got, s.want) {
+					t.Errorf("unexpected events for step %d:\ngot: %v\nwant:%v", i, got, s.want)
+				}
+				next, ok := c.next()
+				want := test.wantNext[i]
+				if ok != (want >= 0) || (ok && next != epoch.Add(want)) {
+					t.Errorf("unexpected next time for step %d: got:%v (%t) want:%v", i, next.Sub(epoch), ok, want)
+				}
+			}
+		})
+	}
+}
@@ -10,87 +10,270 @@ import (
 	"errors"
 	"fmt"
 	"image"
-	"io"
+	"image/color"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
-	"unsafe"
 
 	"golang.org/x/image/draw"
 
-	"github.com/sstallion/go-hid"
+	"github.com/kortschak/ardilla/animation"
 )
 
 // Deck is a Stream Deck device.
 type Deck struct {
 	desc   *device
 	serial string // serial is the cached serial for reconnection.
-	dev    hidDevice
+	dev    HIDDevice
 	buf    []byte
-}
 
-type hidDevice interface {
-	io.Reader
-	io.Writer
-	io.Closer
-	GetFeatureReport([]byte) (int, error)
-	SendFeatureReport([]byte) (int, error)
+	// mu serialises device writes and feature
+	// report requests, and protects replacement
+	// of dev.
+	mu sync.Mutex
+
+	// reads is the number of key state reads in
+	// progress on dev, and readIdle is signalled
+	// when it falls to zero. Both are protected
+	// by mu.
+	reads    int
+	readIdle *sync.Cond
+
+	// pkt is the image report buffer. It is
+	// protected by mu.
+	pkt []byte
+
+	// readBufs holds key state report buffers.
+	readBufs sync.Pool
+
+	// throughput holds measurements of recent key
+	// image writes. It is protected by mu.
+	throughput throughputWindow
+
+	// latency holds measurements of recent
+	// press-to-render latencies. It is protected
+	// by mu.
+	latency latencyWindow
+
+	// held is whether the Deck is under a
+	// maintenance hold, and hold identifies the
+	// most recent hold.
+	held bool
+	hold uint64
+
+	// external indicates the device was provided by the user
+	// and so cannot be found by enumeration.
+	external bool
+
+	// noResetKeyStream indicates that the key stream
+	// is not reset when the device is initialised.
+	noResetKeyStream bool
+
+	// recoverOnOpen and recoverFull indicate that
+	// the device is recovered with Recover when it
+	// is initialised.
+	recoverOnOpen bool
+	recoverFull   bool
+
+	quirks    Quirk
+	quirksOn  Quirk
+	quirksOff Quirk
+
+	pacing    time.Duration
+	lastWrite time.Time // lastWrite is the time of the last paced write.
+
+	// brightness is the last brightness set, or -1 if
+	// no brightness has been set. If restoreBrightness
+	// is true, it is reapplied after a reset.
+	brightness        int
+	restoreBrightness bool
+
+	// background and padding are used to letterbox
+	// images that do not fill a key.
+	background color.Color
+	padding    int
+
+	// highContrast is whether images are rendered
+	// in high contrast mode.
+	highContrast bool
+
+	// dither is the dithering method used for
+	// BMP key images.
+	dither Dither
+
+	// sharpen is the amount of unsharp masking
+	// applied to scaled key images.
+	sharpen float64
+
+	// debounce and stuckTimeout condition
+	// the key event stream.
+	debounce     time.Duration
+	stuckTimeout time.Duration
+
+	clock  animation.Clock
+	images []image.Image // images is the last image set on each key.
+
+	// written is the encoded image data last written
+	// to each key, or nil if the key's content is not
+	// known. Writes of identical data are skipped.
+	written [][]byte
+
+	animMu     sync.Mutex
+	animations map[int]*Animation // animations is keyed by key number.
+	throttle   bool               // throttle is protected by animMu.
+
+	layerMu sync.Mutex
+	layers  map[int]*layers // layers is keyed by key number.
+
+	// mirrors holds the mirrors with the Deck as
+	// source. It is only replaced, never modified,
+	// and only with mirrorMu held, so that writing
+	// images does not need to take the lock.
+	mirrors atomic.Pointer[[]*Mirror]
+	// mirrored is the mirrors targeting the Deck.
+	// It is protected by mirrorMu.
+	mirrored []*Mirror
 }
 
 // NewDeck returns the first a Deck using the HID corresponding the the given
 // Stream Deck pid and serial. If serial is empty the first matching pid is
-// used.
-func NewDeck(pid PID, serial string) (*Deck, error) {
+// used. If pid is AnyPID, the first connected Stream Deck with a matching
+// serial is used, with decks that can display images chosen in preference
+// to those that cannot. Options are applied before the device is
+// initialised.
+func NewDeck(pid PID, serial string, opts ...Option) (*Deck, error) {
+	desc, serial, err := findDevice(pid, serial)
+	if err != nil {
+		return nil, err
+	}
+	dev, err := desc.open(serial)
+	if err != nil {
+		return nil, err
+	}
+	return newDeck(desc, serial, dev, opts)
+}
+
+// findDevice returns the description of the device with the given pid and
+// the serial number to open it with, resolving AnyPID to a connected
+// device.
+func findDevice(pid PID, serial string) (device, string, error) {
 	desc, ok := devices[pid]
-	if !ok && pid != hid.ProductIDAny {
-		return nil, fmt.Errorf("%s not a valid deck device identifier", pid)
+	if !ok && pid != AnyPID {
+		return device{}, "", fmt.Errorf("%s not a valid deck device identifier", pid)
 	}
-	if pid == hid.ProductIDAny {
-		// Find the first El Gato device with matching serial.
-		hid.Enumerate(vidElGato, uint16(pid), func(info *hid.DeviceInfo) error {
-			if serial == "" || serial == info.SerialNbr {
-				pid = PID(info.ProductID)
-			}
-			return io.EOF
-		})
-		desc, ok = devices[pid]
+	if pid != AnyPID {
+		return desc, serial, nil
+	}
+	infos, err := deckInfos()
+	if err != nil {
+		return device{}, "", err
+	}
+	info, ok := selectDeck(infos, serial)
+	if !ok {
+		if serial != "" {
+			return device{}, "", fmt.Errorf("no Stream Deck found with serial %s", serial)
+		}
+		return device{}, "", errors.New("no Stream Deck found")
+	}
+	return devices[info.PID], info.Serial, nil
+}
+
+// selectDeck returns the first deck in infos with the given serial, or
+// any serial if serial is empty, preferring visual devices.
+func selectDeck(infos []DeckInfo, serial string) (info DeckInfo, ok bool) {
+	for _, i := range infos {
+		if serial != "" && serial != i.Serial {
+			continue
+		}
+		if devices[i.PID].visual {
+			return i, true
+		}
 		if !ok {
-			return nil, fmt.Errorf("%s not a known deck device identifier", pid)
+			info, ok = i, true
 		}
 	}
-	var (
-		dev hidDevice
-		err error
-	)
-	if serial != "" {
-		dev, err = hid.Open(vidElGato, uint16(pid), serial)
-	} else {
-		dev, err = hid.OpenFirst(vidElGato, uint16(pid))
+	return info, ok
+}
+
+// NewDeckHID returns a Deck for the Stream Deck with the given pid using
+// the provided HID device. NewDeckHID allows alternative HID transports to
+// be used. Options are applied before the device is initialised.
+//
+// Decks returned by NewDeckHID cannot be reconnected, and do not report
+// ErrNotConnected since the device cannot be found by enumeration.
+func NewDeckHID(pid PID, dev HIDDevice, opts ...Option) (*Deck, error) {
+	desc, ok := devices[pid]
+	if !ok {
+		return nil, fmt.Errorf("%s not a valid deck device identifier", pid)
 	}
-	if err != nil {
-		return nil, err
+	return newDeck(desc, "", dev, append(opts[:len(opts):len(opts)], external))
+}
+
+// external is an Option marking a Deck as using a user-provided device.
+func external(d *Deck) {
+	d.external = true
+}
+
+// newDeck returns a Deck for the device described by desc using dev, after
+// applying opts and initialising the device.
+func newDeck(desc device, serial string, dev HIDDevice, opts []Option) (*Deck, error) {
+	d := &Deck{desc: &desc, serial: serial, dev: dev, buf: make([]byte, desc.bufLen()), brightness: -1, debounce: desc.debounce}
+	for _, o := range opts {
+		o(d)
 	}
-	d := &Deck{desc: &desc, serial: serial, dev: dev, buf: make([]byte, desc.bufLen())}
-	err = d.ResetKeyStream()
+	err := d.init()
 	if err != nil {
 		d.dev.Close()
 		return nil, err
 	}
-	if d.serial == "" {
-		d.serial, err = d.Serial()
+	return d, nil
+}
+
+// init initialises a newly opened device.
+func (d *Deck) init() error {
+	err := d.setQuirks()
+	if err != nil {
+		return err
+	}
+	switch {
+	case d.recoverOnOpen:
+		err = d.Recover(d.recoverFull)
+		if err != nil {
+			return err
+		}
+	case !d.noResetKeyStream:
+		err = d.ResetKeyStream()
+		if err == nil && d.Quirks()&QuirkResetOnOpen != 0 {
+			err = d.ResetKeyStream()
+		}
 		if err != nil {
-			d.dev.Close()
-			return nil, err
+			return err
 		}
 	}
-	return d, nil
+	if d.serial == "" {
+		d.serial, err = d.Serial()
+	}
+	return err
 }
 
 // ErrNotConnected indicates that the Deck is no longer connected.
 var ErrNotConnected = errors.New("device not connected")
 
+// ErrSerialMismatch indicates that a reconnected device does not have the
+// serial number of the device the Deck was opened with.
+var ErrSerialMismatch = errors.New("serial mismatch")
+
 // Reconnect attempts to reconnect to the receiver's device each delay until
 // successful or the context is cancelled. Reconnect returns the last error
-// if ctx is cancelled.
+// if ctx is cancelled. A device that does not have the serial number of
+// the original device is not accepted, and the error returned wraps
+// ErrSerialMismatch. Decks created by NewDeckHID cannot be reconnected.
 func (d *Deck) Reconnect(ctx context.Context, delay time.Duration) error {
+	if d.external {
+		return errors.New("cannot reconnect user-provided device")
+	}
 	var err error
 	for {
 		timer := time.NewTimer(delay)
@@ -100,43 +283,69 @@ func (d *Deck) Reconnect(ctx context.Context, delay time.Duration) error {
 			return err
 		case <-timer.C:
 		}
-		var found bool
-		hid.Enumerate(vidElGato, uint16(d.PID()), func(info *hid.DeviceInfo) error {
-			if info.SerialNbr == d.serial {
-				found = true
-			}
-			return nil
-		})
-		if !found {
+		if !d.connected() {
 			err = ErrNotConnected
 			continue
 		}
-		var _d *Deck
-		_d, err = NewDeck(d.PID(), d.serial)
+		var dev HIDDevice
+		dev, err = d.desc.open(d.serial)
+		if err != nil {
+			continue
+		}
+		err = d.reconnect(dev)
 		if err == nil {
-			d.Close()
-			*d = *_d
 			return nil
 		}
 	}
+}
+
+// reconnect replaces the receiver's device with dev and initialises it,
+// checking that dev has the serial number of the original device.
+func (d *Deck) reconnect(dev HIDDevice) error {
+	d.mu.Lock()
+	d.swapDevice(dev)
+	d.images = nil
+	d.written = nil
+	d.mu.Unlock()
+	err := d.init()
+	if err != nil {
+		return err
+	}
+	serial, err := d.ReadSerial()
+	if err != nil {
+		return err
+	}
+	if serial != d.serial {
+		return fmt.Errorf("%w: got %s want %s", ErrSerialMismatch, serial, d.serial)
+	}
+	if d.restoreBrightness && d.brightness >= 0 {
+		err = d.SetBrightness(d.brightness)
+	}
 	return err
 }
 
+// checkConnected returns ErrNotConnected if err is not nil and the device
+// is no longer connected, and err otherwise.
 func (d *Deck) checkConnected(err error) error {
-	if err == nil {
-		return nil
+	if err == nil || d.external || errors.Is(err, ErrReleased) {
+		return err
 	}
+	if !d.connected() {
+		return ErrNotConnected
+	}
+	return err
+}
+
+// connected returns whether the device is connected.
+func (d *Deck) connected() bool {
 	var found bool
-	hid.Enumerate(vidElGato, uint16(d.PID()), func(info *hid.DeviceInfo) error {
-		if info.SerialNbr == d.serial {
+	enumerate(d.desc.vendor(), d.PID(), func(info deviceInfo) error {
+		if info.serial == d.serial {
 			found = true
 		}
 		return nil
 	})
-	if !found {
-		return ErrNotConnected
-	}
-	return err
+	return found
 }
 
 // Serials returns the list of El Gato device serial numbers matching the
@@ -147,8 +356,8 @@ func Serials(pid PID) ([]string, error) {
 		return nil, fmt.Errorf("%s not a valid deck device identifier", pid)
 	}
 	var serials []string
-	err := hid.Enumerate(vidElGato, uint16(pid), func(info *hid.DeviceInfo) error {
-		serials = append(serials, info.SerialNbr)
+	err := enumerate(vidElGato, pid, func(info deviceInfo) error {
+		serials = append(serials, info.serial)
 		return nil
 	})
 	if err != nil {
@@ -164,18 +373,98 @@ func (d *Deck) ResetKeyStream() error {
 	if !d.desc.visual {
 		return nil
 	}
-	buf := d.buf[:d.desc.payloadLen]
-	zero(buf)
-	copy(buf, d.desc.resetKeyStream)
-	_, err := d.dev.SendFeatureReport(buf)
-	return err
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// Some devices blank the keys when the key stream
+	// is reset, so the written images are not known.
+	d.written = nil
+	return d.sendReport(d.newReport("reset key stream", d.desc.payloadLen, d.desc.resetKeyStream))
 }
 
-// Close closes the device.
+// Close stops all running animations and any Mirror with the Deck as its
+// source or one of its targets, and closes the device. Reads that are
+// started after Close fail. For devices that support reads with a
+// timeout, including those of the hidapi and hidraw backends, Close waits
+// for key state reads in progress to finish before the device is closed.
 func (d *Deck) Close() error {
-	return d.dev.Close()
+	d.stopAnimations()
+	d.stopMirrors()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.swapDevice(closedDevice{})
+}
+
+// swapDevice replaces the receiver's device with dev and closes the old
+// device. If the old device supports reads with a timeout, swapDevice
+// first waits for key state reads in progress on it to finish, so that
+// the device is not closed under a read. Other devices may block reads
+// indefinitely, so they are closed immediately, which is expected to end
+// the read. The caller must hold d.mu.
+func (d *Deck) swapDevice(dev HIDDevice) error {
+	old := d.dev
+	d.dev = dev
+	if _, ok := old.(timeoutReader); ok {
+		if d.readIdle == nil {
+			d.readIdle = sync.NewCond(&d.mu)
+		}
+		for d.reads != 0 {
+			d.readIdle.Wait()
+		}
+	}
+	return old.Close()
+}
+
+// readPoll is how long each read of a key state report waits on devices
+// that support reads with a timeout. It bounds the time that replacing
+// the device waits for a read in progress.
+const readPoll = 100 * time.Millisecond
+
+// readReport reads a key state report into buf. The read is made on the
+// receiver's current device, and if the device is replaced while the read
+// is waiting for a report on a device that supports reads with a timeout,
+// the read continues on the replacement.
+func (d *Deck) readReport(buf []byte) (int, error) {
+	for {
+		d.mu.Lock()
+		dev := d.dev
+		d.reads++
+		d.mu.Unlock()
+
+		var (
+			n   int
+			err error
+		)
+		r, ok := dev.(timeoutReader)
+		if ok {
+			n, err = r.ReadWithTimeout(buf, readPoll)
+		} else {
+			n, err = dev.Read(buf)
+		}
+
+		d.mu.Lock()
+		d.reads--
+		if d.reads == 0 && d.readIdle != nil {
+			d.readIdle.Broadcast()
+		}
+		d.mu.Unlock()
+		if !ok || n != 0 || err != nil {
+			return n, err
+		}
+	}
 }
 
+// closedDevice is the HIDDevice of a closed Deck.
+type closedDevice struct{}
+
+// errClosed is returned by operations on a closed Deck.
+var errClosed = errors.New("device closed")
+
+func (closedDevice) Read([]byte) (int, error)              { return 0, errClosed }
+func (closedDevice) Write([]byte) (int, error)             { return 0, errClosed }
+func (closedDevice) Close() error                          { return nil }
+func (closedDevice) GetFeatureReport([]byte) (int, error)  { return 0, errClosed }
+func (closedDevice) SendFeatureReport([]byte) (int, error) { return 0, errClosed }
+
 // Layout returns the number of rows and columns of buttons on the device.
 func (d *Deck) Layout() (rows, cols int) {
 	return d.desc.rows, d.desc.cols
@@ -184,10 +473,10 @@ func (d *Deck) Layout() (rows, cols int) {
 // Key returns the key number corresponding to the given row and column.
 // It panics if row or col are out of bounds.
 func (d *Deck) Key(row, col int) int {
-	if row < 0 || d.desc.rows < row {
+	if row < 0 || d.desc.rows <= row {
 		panic(fmt.Sprintf("row out of bounds: %d", row))
 	}
-	if col < 0 || d.desc.cols < col {
+	if col < 0 || d.desc.cols <= col {
 		panic(fmt.Sprintf("column out of bounds: %d", col))
 	}
 	return row*d.desc.cols + col
@@ -201,13 +490,64 @@ func (d *Deck) Len() int {
 // KeyStates returns a slice of booleans indicating which buttons are pressed.
 // The length of the returned slice is given by the Len method.
 func (d *Deck) KeyStates() ([]bool, error) {
-	buf := make([]byte, d.desc.keyStatesOffset+d.Len())
-	_, err := d.dev.Read(buf)
+	return d.AppendKeyStates(nil)
+}
+
+// AppendKeyStates is like KeyStates, but appends the key states to dst and
+// returns the extended slice. When dst has capacity for the key states,
+// AppendKeyStates does not allocate.
+func (d *Deck) AppendKeyStates(dst []bool) ([]bool, error) {
+	states, _, err := d.keyStates(dst)
 	if err != nil {
-		return nil, d.checkConnected(err)
+		return dst, err
 	}
-	buf = buf[d.desc.keyStatesOffset:]
-	return *(*[]bool)(unsafe.Pointer(&buf)), nil
+	for len(states)-len(dst) < d.Len() {
+		// Keys not covered by a short report
+		// are reported as released.
+		states = append(states, false)
+	}
+	return states, nil
+}
+
+// keyStates returns the key states appended to dst and the time the report
+// was read. If the report is short, the returned states only cover the keys
+// held in the report.
+func (d *Deck) keyStates(dst []bool) ([]bool, time.Time, error) {
+	n := d.desc.keyStatesOffset + d.Len()
+	p, _ := d.readBufs.Get().(*[]byte)
+	if p == nil || len(*p) != n {
+		b := make([]byte, n)
+		p = &b
+	}
+	defer d.readBufs.Put(p)
+	buf := *p
+	var (
+		now time.Time
+		err error
+	)
+	for {
+		n, err = d.readReport(buf)
+		now = time.Now()
+		if err != nil {
+			return dst, now, d.checkConnected(err)
+		}
+		// Skip reports that do not hold key states, such
+		// as those from encoders, rather than reporting
+		// their contents as key presses.
+		if !d.desc.typedInputReports || n < 2 || buf[1] == 0 {
+			break
+		}
+	}
+	if n < d.desc.keyStatesOffset {
+		n = d.desc.keyStatesOffset
+	}
+	buf = buf[d.desc.keyStatesOffset:n]
+	// Convert explicitly rather than reinterpreting the buffer since
+	// the device may send values other than 0 and 1.
+	for _, b := range buf {
+		dst = append(dst, b != 0)
+	}
+	return dst, now, nil
 }
 
 // Resets the Stream Deck, clearing all button images and showing the standby
@@ -216,10 +556,19 @@ func (d *Deck) Reset() error {
 	if !d.desc.visual {
 		return nil
 	}
-	buf := d.buf[:d.desc.payloadLen]
-	zero(buf)
-	copy(buf, d.desc.reset)
-	_, err := d.dev.SendFeatureReport(buf)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.held {
+		return ErrMaintenance
+	}
+	err := d.sendReport(d.newReport("reset", d.desc.payloadLen, d.desc.reset))
+	if err == nil {
+		d.images = nil
+		d.written = nil
+		if d.restoreBrightness && d.brightness >= 0 {
+			err = d.setBrightness(d.brightness)
+		}
+	}
 	return d.checkConnected(err)
 }
 
@@ -232,127 +581,543 @@ func (d *Deck) SetBrightness(percent int) error {
 	if percent < 0 || 100 < percent {
 		return fmt.Errorf("brightness out of range: %d", percent)
 	}
-	buf := d.buf[:d.desc.payloadLen]
-	zero(buf)
-	copy(buf, d.desc.brightness)
-	buf[len(d.desc.brightness)] = byte(percent)
-	_, err := d.dev.SendFeatureReport(buf)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.held {
+		return ErrMaintenance
+	}
+	err := d.setBrightness(percent)
+	if err == nil {
+		d.brightness = percent
+	}
 	return d.checkConnected(err)
 }
 
+// setBrightness sends a brightness report to the device. The receiver's
+// mutex must be held.
+func (d *Deck) setBrightness(percent int) error {
+	r := d.newReport("brightness", d.desc.payloadLen, d.desc.brightness)
+	r.put(byte(percent))
+	return d.sendReport(r)
+}
+
+// Brightness returns the last brightness successfully set on the Deck.
+// If no brightness has been set, ok is false.
+func (d *Deck) Brightness() (percent int, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.brightness, d.brightness >= 0
+}
+
+// SetRestoreBrightness sets whether the last brightness set on the Deck is
+// reapplied after the device is reset by Reset or reconnected by Reconnect.
+// Without this, the device returns to its default brightness.
+func (d *Deck) SetRestoreBrightness(restore bool) {
+	d.mu.Lock()
+	d.restoreBrightness = restore
+	d.mu.Unlock()
+}
+
 // SetImage renders the provided image on the button at the given row and
 // column. If img is a *RawImage the internal representation will be used
-// directly.
+// directly. Any animation running on the key is stopped.
 func (d *Deck) SetImage(row, col int, img image.Image) error {
-	if row < 0 || d.desc.rows < row {
-		return fmt.Errorf("row out of bounds: %d", row)
+	key, err := d.keyIndex(row, col)
+	if err != nil {
+		return err
+	}
+	d.stopAnimation(key)
+	return d.setImage(key, img)
+}
+
+// SetImageRegion renders the src region of the provided image on the
+// button at the given row and column, as if the region were the whole
+// image. The region is cropped during scaling, so callers showing parts
+// of a large image, for example a tile of a screen capture, do not need
+// to allocate a SubImage for each key. It is an error for the
+// intersection of src and the image's bounds to be empty. Any animation
+// running on the key is stopped.
+func (d *Deck) SetImageRegion(row, col int, img image.Image, src image.Rectangle) error {
+	key, err := d.keyIndex(row, col)
+	if err != nil {
+		return err
+	}
+	if raw, ok := img.(*RawImage); ok {
+		img = raw.Image
+	}
+	r := src.Intersect(img.Bounds())
+	if r.Empty() {
+		return fmt.Errorf("empty image region: %v", src)
 	}
-	if col < 0 || d.desc.cols < col {
-		return fmt.Errorf("column out of bounds: %d", col)
+	d.stopAnimation(key)
+	if r == img.Bounds() {
+		return d.setImage(key, img)
 	}
-	key := row*d.desc.cols + col
+	return d.setImage(key, &region{Image: img, r: r})
+}
 
-	var (
-		raw *RawImage
-		err error
-	)
-	switch img := img.(type) {
-	case *RawImage:
-		if img.pid == d.desc.PID {
-			raw = img
-			break
-		}
-		// Unwrap the original and reprocess.
-		raw, err = d.RawImage(img.rawImage.Image) //lint:ignore QF1008 rawImage included for clarity.
-		if err != nil {
-			return err
+// region is a view of the r region of an image. It is unwrapped when
+// scaling so that the fast paths for the underlying image type are used.
+type region struct {
+	image.Image
+	r image.Rectangle
+}
+
+// Bounds returns the bounds of the region.
+func (r *region) Bounds() image.Rectangle { return r.r }
+
+// setImage renders img on the key with the given key number and
+// replicates it to any mirrors of the Deck.
+func (d *Deck) setImage(key int, img image.Image) error {
+	err := d.writeImage(key, img)
+	if err != nil {
+		return err
+	}
+	d.mirror(key, img)
+	return nil
+}
+
+// rerender renders the images shown on the keys again so that a change to
+// the rendering settings is applied to them.
+func (d *Deck) rerender() error {
+	d.mu.Lock()
+	images := append([]image.Image(nil), d.images...)
+	d.mu.Unlock()
+	for key, img := range images {
+		if img == nil {
+			continue
 		}
-	default:
-		raw, err = d.RawImage(img)
+		err := d.setImage(key, img)
 		if err != nil {
 			return err
 		}
 	}
-	buf := bytes.NewReader(raw.data)
+	return nil
+}
 
-	pkt := make([]byte, d.desc.imgReportLen)
+// writeImage renders img on the key with the given key number.
+func (d *Deck) writeImage(key int, img image.Image) error {
+	// A RawImage is used directly if it was rendered
+	// with the current settings, and rendered again from
+	// its original image otherwise.
+	raw, err := d.RawImage(img)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.held {
+		return ErrMaintenance
+	}
+	if d.written == nil {
+		d.written = make([][]byte, d.Len())
+	}
+	if d.written[key] != nil && bytes.Equal(d.written[key], raw.data) {
+		// The key already shows this image.
+		d.images[key] = img
+		return nil
+	}
+	d.written[key] = nil
+	if len(d.pkt) != d.desc.imgReportLen {
+		d.pkt = make([]byte, d.desc.imgReportLen)
+	}
+	pkt := d.pkt
+	hdr := len(d.desc.imageHeader)
 	copy(pkt, d.desc.imageHeader)
+	data := raw.data
+	start := time.Now()
 	var page int
-	for buf.Len() != 0 {
-		n, err := buf.Read(pkt[len(d.desc.imageHeader):])
-		if err != nil && err != io.EOF {
-			return err
+	for ; len(data) != 0; page++ {
+		n := copy(pkt[hdr:], data)
+		data = data[n:]
+		if page == 0 {
+			// Clear any data left from an earlier image
+			// in a short first page. Short later pages
+			// hold the tail of the previous page.
+			zero(pkt[hdr+n:])
 		}
-		done := buf.Len() == 0 || n < d.desc.imgReportLen-len(d.desc.imageHeader)
-		d.desc.fillHeader(pkt[:len(d.desc.imageHeader)], key, page, n, done)
-		_, err = d.dev.Write(pkt)
+		d.desc.fillHeader(pkt[:hdr], key, page, n, len(data) == 0)
+		d.pace()
+		_, err := d.dev.Write(pkt)
 		if err != nil {
 			return d.checkConnected(err)
 		}
-		page++
 	}
+	d.throughput.add(page, len(raw.data), time.Since(start))
+	d.written[key] = raw.data
+	if d.images == nil {
+		d.images = make([]image.Image, d.Len())
+	}
+	d.images[key] = img
 	return nil
 }
 
+// keyIndex returns the key number for the given row and column, or an
+// error if they are out of bounds.
+func (d *Deck) keyIndex(row, col int) (int, error) {
+	if row < 0 || d.desc.rows <= row {
+		return 0, fmt.Errorf("row out of bounds: %d", row)
+	}
+	if col < 0 || d.desc.cols <= col {
+		return 0, fmt.Errorf("column out of bounds: %d", col)
+	}
+	return row*d.desc.cols + col, nil
+}
+
+// SetPacing sets the minimum delay between image report writes. Increasing
+// the delay reduces the maximum frame rate, but may prevent image corruption
+// when the device is connected through an unreliable USB hub. The default
+// delay is zero.
+func (d *Deck) SetPacing(delay time.Duration) {
+	d.mu.Lock()
+	d.pacing = delay
+	d.mu.Unlock()
+}
+
+// pace waits until the pacing delay has elapsed since the last paced image
+// report write.
+func (d *Deck) pace() {
+	delay := d.pacing
+	if d.quirks&QuirkSlowPacing != 0 && delay < slowPacing {
+		delay = slowPacing
+	}
+	if delay <= 0 {
+		return
+	}
+	if wait := delay - time.Since(d.lastWrite); wait > 0 {
+		time.Sleep(wait)
+	}
+	d.lastWrite = time.Now()
+}
+
 // RawImage returns an image.Image has had the internal image representation
 // pre-computed after resizing to fit the Deck's button size. The original image
 // is retained in the returned image.
 func (d *Deck) RawImage(img image.Image) (*RawImage, error) {
+	return d.rawImage(img, d.renderSettings())
+}
+
+// RawImages returns RawImages for each of the images in imgs, keyed by key
+// number as returned by Key. The images are rendered concurrently, so that
+// all the key images of a page can be prepared before they are needed.
+// If any image cannot be rendered, the first error encountered in key
+// order is returned.
+func (d *Deck) RawImages(imgs map[int]image.Image) (map[int]*RawImage, error) {
+	for k := range imgs {
+		if k < 0 || d.Len() <= k {
+			return nil, fmt.Errorf("key out of bounds: %d", k)
+		}
+	}
+	keys := make([]int, 0, len(imgs))
+	for k := range imgs {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	raw := make([]*RawImage, len(keys))
+	errs := make([]error, len(keys))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(keys) {
+		workers = len(keys)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				raw[i], errs[i] = d.RawImage(imgs[keys[i]])
+			}
+		}()
+	}
+	for i := range keys {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	m := make(map[int]*RawImage, len(keys))
+	for i, k := range keys {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("key %d: %w", k, errs[i])
+		}
+		m[k] = raw[i]
+	}
+	return m, nil
+}
+
+// Render returns a RawImage for img rendered for the Stream Deck model with
+// the given pid, without requiring a connected device. Options that affect
+// rendering, such as WithBackground, WithPadding, WithHighContrast,
+// WithDither and WithSharpen, are applied and other options are ignored.
+// The returned RawImage is used directly by Decks of the same model with
+// the same high contrast, dither and sharpen settings.
+func Render(pid PID, img image.Image, opts ...Option) (*RawImage, error) {
+	desc, ok := devices[pid]
+	if !ok {
+		return nil, fmt.Errorf("%s not a valid deck device identifier", pid)
+	}
+	d := &Deck{desc: &desc, brightness: -1}
+	for _, o := range opts {
+		o(d)
+	}
+	return d.RawImage(img)
+}
+
+// RawImageData returns a RawImage for the receiver's device holding data,
+// which must have been obtained from the Data method of a RawImage for the
+// same model rendered with the receiver's current high contrast, dither
+// and sharpen settings. No rendering is performed. The original image, img,
+// is retained and is only rendered if the returned RawImage is set on a
+// Deck with different settings.
+func (d *Deck) RawImageData(img image.Image, data []byte) (*RawImage, error) {
+	if !d.desc.visual {
+		return nil, fmt.Errorf("images not supported by %s", d.desc)
+	}
+	if img == nil {
+		return nil, errors.New("missing original image")
+	}
+	if len(data) == 0 {
+		return nil, errors.New("missing image data")
+	}
+	s := d.renderSettings()
+	return &RawImage{rawImage{
+		Image:        img,
+		data:         data,
+		pid:          d.desc.model(),
+		vid:          d.desc.vid,
+		highContrast: s.highContrast,
+		dither:       s.dither,
+		sharpen:      s.sharpen,
+	}}, nil
+}
+
+// rawImage returns a RawImage for img rendered with the settings s. The
+// returned RawImage is tagged with the same settings.
+func (d *Deck) rawImage(img image.Image, s renderSettings) (*RawImage, error) {
 	if !d.desc.visual {
 		return nil, fmt.Errorf("images not supported by %s", d.desc)
 	}
 	if raw, ok := img.(*RawImage); ok {
-		if raw.pid == d.desc.PID {
+		if s.reusable(d.desc, raw) {
 			return raw, nil
 		}
 		// Unwrap the original and reprocess.
 		img = raw.Image
 	}
 
-	orig := img
-	if img.Bounds() != d.desc.bounds() {
-		dst := image.NewRGBA(d.desc.bounds())
-		draw.BiLinear.Scale(dst, keepAspectRatio(dst, img), img, img.Bounds(), draw.Src, nil)
-		img = dst
+	fitted := toRGBA(d.desc.fit(img, s.background, s.padding))
+	if s.sharpen > 0 && img.Bounds().Size() != fitted.Bounds().Size() {
+		fitted = sharpen(fitted, s.sharpen)
+	}
+	if s.highContrast {
+		fitted = highContrast(fitted)
+	}
+	fitted = dither(fitted, s.dither)
+	encode, err := encoder(d.desc.format)
+	if err != nil {
+		return nil, err
 	}
-
 	var buf bytes.Buffer
-	err := d.desc.encode(&buf, d.desc.transform(img))
+	err = encode(&buf, d.desc.transform(fitted))
 	if err != nil {
 		return nil, err
 	}
 	return &RawImage{rawImage{
-		Image: orig,
-		data:  buf.Bytes(),
-		pid:   d.desc.PID,
+		Image:        img,
+		data:         buf.Bytes(),
+		pid:          d.desc.model(),
+		vid:          d.desc.vid,
+		highContrast: s.highContrast,
+		dither:       s.dither,
+		sharpen:      s.sharpen,
 	}}, nil
 }
 
+// reusable returns whether raw was computed for the receiver's device
+// model with the receiver's current rendering mode.
+func (d *Deck) reusable(raw *RawImage) bool {
+	return d.renderSettings().reusable(d.desc, raw)
+}
+
 // RawImage is an image.Image that holds pre-computed data in the raw format
 // used by a specific El Gato Stream Deck device.
 type RawImage struct {
 	rawImage
 }
 
+// Len returns the length of the receiver's pre-computed image data.
+func (r *RawImage) Len() int {
+	return len(r.data)
+}
+
+// Data returns the receiver's pre-computed image data in the device's key
+// image format. The returned slice must not be modified.
+func (r *RawImage) Data() []byte {
+	return r.data
+}
+
+// PID returns the product ID of the canonical device model the receiver's
+// data was computed for. The data is valid for all variants of the model.
+func (r *RawImage) PID() PID {
+	return r.pid
+}
+
 type rawImage struct {
 	image.Image
 	data []byte
 	pid  PID
+	vid  uint16 // vid is zero for El Gato devices.
+
+	highContrast bool
+	dither       Dither
+	sharpen      float64
+}
+
+// SetBackground sets the colour used to fill the regions of a key that
+// are not covered by an image, either because the image does not have
+// the aspect ratio of the key, it is padded or it is not opaque. If c
+// is nil, uncovered regions are black. The background is applied when
+// an image is converted to a RawImage, so RawImages created before the
+// call are not altered.
+func (d *Deck) SetBackground(c color.Color) {
+	d.mu.Lock()
+	d.background = c
+	d.mu.Unlock()
+}
+
+// SetPadding sets the number of pixels between key images and the key
+// edges. Negative padding is treated as zero. As for SetBackground,
+// padding is applied when an image is converted to a RawImage.
+func (d *Deck) SetPadding(pixels int) {
+	if pixels < 0 {
+		pixels = 0
+	}
+	d.mu.Lock()
+	d.padding = pixels
+	d.mu.Unlock()
+}
+
+// renderSettings is a snapshot of the settings used to render key images.
+type renderSettings struct {
+	// background and padding are used to letterbox
+	// images that do not fill a key.
+	background color.Color
+	padding    int
+
+	highContrast bool
+
+	// dither is the dithering method applied to key
+	// images. It is NoDither for devices that do not
+	// use BMP key images.
+	dither Dither
+
+	sharpen float64
+}
+
+// renderSettings returns the receiver's current rendering settings. Images
+// should be rendered and tagged with a single snapshot so that a RawImage
+// is not tagged with settings that changed while it was being rendered.
+func (d *Deck) renderSettings() renderSettings {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := renderSettings{
+		background:   d.background,
+		padding:      d.padding,
+		highContrast: d.highContrast,
+		dither:       d.dither,
+		sharpen:      d.sharpen,
+	}
+	if d.desc.format != "bmp" {
+		s.dither = NoDither
+	}
+	return s
+}
+
+// reusable returns whether raw was computed for the device desc with the
+// receiver's rendering mode.
+func (s renderSettings) reusable(desc *device, raw *RawImage) bool {
+	return raw.pid == desc.model() && raw.vid == desc.vid && raw.highContrast == s.highContrast && raw.dither == s.dither && raw.sharpen == s.sharpen
+}
+
+// fit returns img scaled to fit the key bounds of the receiver's device
+// using the receiver's background and padding.
+func (d *Deck) fit(img image.Image) image.Image {
+	s := d.renderSettings()
+	return d.desc.fit(img, s.background, s.padding)
+}
+
+// fit returns img scaled to fit the key bounds of the device inset by
+// pad pixels, preserving its aspect ratio, and drawn over background if
+// it is not nil. If img already has the key bounds and no background or
+// padding is requested it is returned unaltered, unless it is a region
+// of a larger image.
+func (d *device) fit(img image.Image, background color.Color, pad int) image.Image {
+	b := d.bounds()
+	_, isRegion := img.(*region)
+	if img.Bounds() == b && background == nil && pad <= 0 && !isRegion {
+		return img
+	}
+	dst := image.NewRGBA(b)
+	op := draw.Src
+	if background != nil {
+		draw.Draw(dst, b, &image.Uniform{background}, image.Point{}, draw.Src)
+		op = draw.Over
+	}
+	if pad > 0 {
+		b = b.Inset(pad)
+	}
+	sr := img.Bounds()
+	if r, ok := img.(*region); ok {
+		img = r.Image
+	}
+	draw.BiLinear.Scale(dst, keepAspectRatio(dst.SubImage(b), sr), img, sr, op, nil)
+	return dst
+}
+
+// toRGBA returns img converted to an *image.RGBA if it is one of the
+// concrete image types that have fast conversion paths, so that later
+// processing avoids generic per-pixel colour conversion. This is the case
+// for photographic images decoded from JPEG files, which are held as
+// *image.YCbCr. Images of other types, including *image.Gray which is
+// already cheap to sample, are returned unaltered.
+func toRGBA(img image.Image) image.Image {
+	switch img.(type) {
+	case *image.YCbCr, *image.NRGBA:
+		b := img.Bounds()
+		dst := image.NewRGBA(b)
+		draw.Draw(dst, b, img, b.Min, draw.Src)
+		return dst
+	default:
+		return img
+	}
+}
+
+// rgbaImage returns img as an *image.RGBA, converting it if necessary.
+func rgbaImage(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+	return dst
 }
 
 func keepAspectRatio(dst, src image.Image) image.Rectangle {
 	b := dst.Bounds()
 	dx, dy := src.Bounds().Dx(), src.Bounds().Dy()
 	switch {
-	case dx < dy:
-		dx, dy = dx*b.Max.X/dy, b.Max.Y
-	case dx > dy:
-		dx, dy = b.Max.X, dy*b.Max.Y/dx
+	case dx*b.Dy() < dy*b.Dx():
+		dx, dy = dx*b.Dy()/dy, b.Dy()
+	case dx*b.Dy() > dy*b.Dx():
+		dx, dy = b.Dx(), dy*b.Dx()/dx
 	default:
 		return b
 	}
 	offset := image.Point{X: (b.Dx() - dx) / 2, Y: (b.Dy() - dy) / 2}
-	return image.Rectangle{Max: image.Point{X: dx, Y: dy}}.Add(offset)
+	return image.Rectangle{Max: image.Point{X: dx, Y: dy}}.Add(b.Min.Add(offset))
 }
 
 // Bounds returns the image bounds for buttons on the device. If the device
@@ -369,41 +1134,36 @@ func (d *Deck) PID() PID {
 	return d.desc.PID
 }
 
-// Serial returns the serial number of the device.
+// Capabilities returns the description of the device's model. The
+// Canonical field of the returned DeviceInfo identifies the model the
+// device is a variant of.
+func (d *Deck) Capabilities() DeviceInfo {
+	return d.desc.info()
+}
+
+// Serial returns the serial number of the device. The serial number is
+// cached when the Deck is opened and is used to find the device when
+// reconnecting.
 func (d *Deck) Serial() (string, error) {
 	if d.serial != "" {
 		return d.serial, nil
 	}
+	return d.ReadSerial()
+}
+
+// ReadSerial reads the serial number from the device, bypassing the cached
+// serial number returned by Serial. The cached value is not altered.
+func (d *Deck) ReadSerial() (string, error) {
 	payloadLen := d.desc.serialPayloadLen
 	if payloadLen == 0 {
 		payloadLen = d.desc.payloadLen
 	}
-	buf := d.buf[:payloadLen]
-	zero(buf)
-	copy(buf, d.desc.serial)
-	buf[len(d.desc.serial)] = byte(payloadLen)
-	_, err := d.dev.GetFeatureReport(buf)
-	buf = buf[d.desc.serialOffset:]
-	idx := bytes.IndexByte(buf, 0)
-	if idx < 0 {
-		return string(buf), nil
-	}
-	return string(buf[:idx]), d.checkConnected(err)
+	return d.featureString("serial", d.desc.serial, payloadLen, d.desc.serialOffset)
 }
 
 // Firmware returns the firmware version number of the device.
 func (d *Deck) Firmware() (string, error) {
-	buf := d.buf[:d.desc.payloadLen]
-	zero(buf)
-	copy(buf, d.desc.firmware)
-	buf[len(d.desc.firmware)] = byte(d.desc.payloadLen)
-	_, err := d.dev.GetFeatureReport(buf)
-	buf = buf[d.desc.firmwareOffset:]
-	idx := bytes.IndexByte(buf, 0)
-	if idx < 0 {
-		return string(buf), nil
-	}
-	return string(buf[:idx]), d.checkConnected(err)
+	return d.featureString("firmware", d.desc.firmware, d.desc.payloadLen, d.desc.firmwareOffset)
 }
 
 func zero(b []byte) {
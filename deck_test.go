@@ -10,6 +10,7 @@ import (
 	"flag"
 	"fmt"
 	"image"
+	"image/color"
 	"image/png"
 	"io"
 	"os"
@@ -21,7 +22,7 @@ import (
 	"golang.org/x/image/draw"
 )
 
-var update = flag.Bool("update", false, "regenerate golden images")
+var update = flag.Bool("update", false, "regenerate golden images and traces")
 
 var resetKeyStreamTests = []struct {
 	pid    PID
@@ -267,6 +268,65 @@ func TestDeckSetBrightness(t *testing.T) {
 	}
 }
 
+func TestDeckRestoreBrightness(t *testing.T) {
+	for _, restore := range []bool{false, true} {
+		t.Run(fmt.Sprintf("restore=%t", restore), func(t *testing.T) {
+			d, err := newTestDeck(StreamDeckMK2)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dev := &virtDev{Writer: io.Discard}
+			d.setDev(dev)
+			d.SetRestoreBrightness(restore)
+
+			if _, ok := d.Brightness(); ok {
+				t.Error("unexpected brightness before SetBrightness")
+			}
+			err = d.Reset()
+			if err != nil {
+				t.Errorf("unexpected error for Reset: %v", err)
+			}
+			if len(dev.actions) != 1 {
+				t.Errorf("unexpected number of actions for Reset without brightness: got:%d want:1", len(dev.actions))
+			}
+
+			err = d.SetBrightness(40)
+			if err != nil {
+				t.Errorf("unexpected error for SetBrightness: %v", err)
+			}
+			if got, ok := d.Brightness(); !ok || got != 40 {
+				t.Errorf("unexpected brightness: got:%d,%t want:40,true", got, ok)
+			}
+			err = d.SetBrightness(101)
+			if err == nil {
+				t.Error("expected error for out of range brightness")
+			}
+			if got, _ := d.Brightness(); got != 40 {
+				t.Errorf("unexpected brightness after failed SetBrightness: got:%d want:40", got)
+			}
+
+			dev.actions = nil
+			err = d.Reset()
+			if err != nil {
+				t.Errorf("unexpected error for Reset: %v", err)
+			}
+			wantActions := 1
+			if restore {
+				wantActions = 2
+			}
+			if len(dev.actions) != wantActions {
+				t.Fatalf("unexpected number of actions for Reset: got:%d want:%d", len(dev.actions), wantActions)
+			}
+			if restore {
+				want := "SendFeatureReport([]byte{0x3, 0x8, 0x28, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}) -> (32, <nil>)"
+				if got := dev.actions[1]; got != want {
+					t.Errorf("unexpected brightness restore action:\ngot: %s\nwant:%s", got, want)
+				}
+			}
+		})
+	}
+}
+
 var serialTests = []struct {
 	pid        PID
 	data       string
@@ -471,6 +531,12 @@ var keyStateTests = []struct {
 		want:       []bool{0: true, 2: true},
 		wantAction: "Read(7 bytes) -> (7, <nil>)",
 	},
+	{
+		pid:        StreamDeckMini,
+		data:       prependZero(1, []byte{1, 0}),
+		want:       []bool{0: true, 5: false},
+		wantAction: "Read(7 bytes) -> (3, <nil>)",
+	},
 }
 
 func TestDeckKeyStates(t *testing.T) {
@@ -501,6 +567,117 @@ func TestDeckKeyStates(t *testing.T) {
 	}
 }
 
+func TestDeckKeyStatesSkipsOtherReports(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// An input report of type 3, as sent by devices with
+	// encoders, followed by a key state report.
+	other := append([]byte{1, 3, 15, 0}, bytes.Repeat([]byte{1}, 15)...)
+	keys := append([]byte{1, 0, 15, 0}, []byte{2: 1, 14: 0}...)
+	dev := &virtDev{Reader: bytes.NewReader(append(other, keys...))}
+	d.setDev(dev)
+
+	got, err := d.KeyStates()
+	if err != nil {
+		t.Fatalf("unexpected error for KeyStates: %v", err)
+	}
+	want := []bool{2: true, 14: false}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected result for KeyStates:\ngot: %v\nwant:%v", got, want)
+	}
+	if len(dev.actions) != 2 {
+		t.Errorf("unexpected number of reads for KeyStates: got:%d want:2", len(dev.actions))
+	}
+}
+
+func TestDeckPoll(t *testing.T) {
+	type change struct {
+		key, row, col int
+		pressed       bool
+	}
+	for _, test := range []struct {
+		name    string
+		pid     PID
+		prev    []bool
+		data    []byte
+		want    []bool
+		changes []change
+	}{
+		{
+			name:    "initial",
+			pid:     StreamDeckMini,
+			data:    prependZero(1, []byte{2: 1, 5: 1}),
+			want:    []bool{2: true, 5: true},
+			changes: []change{{key: 2, row: 0, col: 2, pressed: true}, {key: 5, row: 1, col: 2, pressed: true}},
+		},
+		{
+			name:    "release",
+			pid:     StreamDeckMini,
+			prev:    []bool{2: true, 5: true},
+			data:    prependZero(1, []byte{2: 1, 5: 0}),
+			want:    []bool{2: true, 5: false},
+			changes: []change{{key: 5, row: 1, col: 2, pressed: false}},
+		},
+		{
+			name: "unchanged",
+			pid:  StreamDeckMini,
+			prev: []bool{2: true, 5: false},
+			data: prependZero(1, []byte{2: 1, 5: 0}),
+			want: []bool{2: true, 5: false},
+		},
+		{
+			name:    "short_read",
+			pid:     StreamDeckMini,
+			prev:    []bool{1: true, 5: true},
+			data:    prependZero(1, []byte{1, 0}),
+			want:    []bool{0: true, 5: true},
+			changes: []change{{key: 0, row: 0, col: 0, pressed: true}, {key: 1, row: 0, col: 1, pressed: false}},
+		},
+		{
+			name:    "pedal",
+			pid:     StreamDeckPedal,
+			prev:    []bool{2: true},
+			data:    prependZero(4, []byte{0: 1, 1: 1, 2: 0}),
+			want:    []bool{0: true, 1: true, 2: false},
+			changes: []change{{key: 0, row: 0, col: 0, pressed: true}, {key: 1, row: 0, col: 1, pressed: true}, {key: 2, row: 0, col: 2, pressed: false}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := newTestDeck(test.pid)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			d.setDev(&virtDev{Reader: bytes.NewReader(test.data)})
+
+			prev := append([]bool(nil), test.prev...)
+			got, changes, err := d.Poll(prev)
+			if err != nil {
+				t.Fatalf("unexpected error for Poll: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected states for Poll:\ngot: %v\nwant:%v", got, test.want)
+			}
+			if !reflect.DeepEqual(prev, test.prev) {
+				t.Errorf("prev modified by Poll:\ngot: %v\nwant:%v", prev, test.prev)
+			}
+			var gotChanges []change
+			for _, c := range changes {
+				gotChanges = append(gotChanges, change{c.Key, c.Row, c.Col, c.Pressed})
+			}
+			if !reflect.DeepEqual(gotChanges, test.changes) {
+				t.Errorf("unexpected changes for Poll:\ngot: %v\nwant:%v", gotChanges, test.changes)
+			}
+
+			_, _, err = d.Poll(got)
+			if err != ErrNotConnected {
+				t.Errorf("unexpected error for exhausted Poll: got:%v want:%v", err, ErrNotConnected)
+			}
+		})
+	}
+}
+
 var setImageTests = []struct {
 	pid         PID
 	row         int
@@ -585,7 +762,7 @@ var setImageTests = []struct {
 		wantHeaders: [][]byte{
 			{0x2, 0x7, devices[StreamDeckOriginalV2].key(1, 2), 0x0, 0xf8, 0x3, 0x0, 0x0},
 			{0x2, 0x7, devices[StreamDeckOriginalV2].key(1, 2), 0x0, 0xf8, 0x3, 0x1, 0x0},
-			{0x2, 0x7, devices[StreamDeckOriginalV2].key(1, 2), 0x1, 0xae, 0x3, 0x2, 0x0},
+			{0x2, 0x7, devices[StreamDeckOriginalV2].key(1, 2), 0x1, 0xca, 0x3, 0x2, 0x0},
 		},
 	},
 	{
@@ -595,7 +772,7 @@ var setImageTests = []struct {
 		wantHeaders: [][]byte{
 			{0x2, 0x7, devices[StreamDeckMK2].key(1, 2), 0x0, 0xf8, 0x3, 0x0, 0x0},
 			{0x2, 0x7, devices[StreamDeckMK2].key(1, 2), 0x0, 0xf8, 0x3, 0x1, 0x0},
-			{0x2, 0x7, devices[StreamDeckMK2].key(1, 2), 0x1, 0xae, 0x3, 0x2, 0x0},
+			{0x2, 0x7, devices[StreamDeckMK2].key(1, 2), 0x1, 0xca, 0x3, 0x2, 0x0},
 		},
 	},
 	{
@@ -606,8 +783,8 @@ var setImageTests = []struct {
 			{0x2, 0x7, devices[StreamDeckXL].key(1, 2), 0x0, 0xf8, 0x3, 0x0, 0x0},
 			{0x2, 0x7, devices[StreamDeckXL].key(1, 2), 0x0, 0xf8, 0x3, 0x1, 0x0},
 			{0x2, 0x7, devices[StreamDeckXL].key(1, 2), 0x0, 0xf8, 0x3, 0x2, 0x0},
-			{0x2, 0x7, devices[StreamDeckXL].key(1, 2), 0x0, 0xf8, 0x3, 0x3, 0x0},
-			{0x2, 0x7, devices[StreamDeckXL].key(1, 2), 0x1, 0x1a, 0x0, 0x4, 0x0}},
+			{0x2, 0x7, devices[StreamDeckXL].key(1, 2), 0x1, 0xca, 0x3, 0x3, 0x0},
+		},
 	},
 	{
 		pid: StreamDeckPedal,
@@ -709,6 +886,254 @@ func TestDeckSetImage(t *testing.T) {
 	}
 }
 
+var deviceFitTests = []struct {
+	name       string
+	img        image.Image
+	background color.Color
+	pad        int
+	want       map[image.Point]color.RGBA
+}{
+	{
+		name: "letterbox",
+		img:  uniformRGBA(image.Rect(0, 0, 10, 20), color.White),
+		want: map[image.Point]color.RGBA{
+			{X: 0, Y: 36}:  {},
+			{X: 36, Y: 36}: {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		},
+	},
+	{
+		name:       "background",
+		img:        uniformRGBA(image.Rect(0, 0, 10, 20), color.White),
+		background: color.RGBA{R: 0xff, A: 0xff},
+		want: map[image.Point]color.RGBA{
+			{X: 0, Y: 36}:  {R: 0xff, A: 0xff},
+			{X: 36, Y: 36}: {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		},
+	},
+	{
+		name:       "transparent",
+		img:        uniformRGBA(image.Rect(0, 0, 72, 72), color.Transparent),
+		background: color.RGBA{G: 0xff, A: 0xff},
+		want: map[image.Point]color.RGBA{
+			{X: 0, Y: 0}:   {G: 0xff, A: 0xff},
+			{X: 36, Y: 36}: {G: 0xff, A: 0xff},
+		},
+	},
+	{
+		name:       "padding",
+		img:        uniformRGBA(image.Rect(0, 0, 72, 72), color.White),
+		background: color.RGBA{B: 0xff, A: 0xff},
+		pad:        8,
+		want: map[image.Point]color.RGBA{
+			{X: 4, Y: 36}:  {B: 0xff, A: 0xff},
+			{X: 36, Y: 4}:  {B: 0xff, A: 0xff},
+			{X: 67, Y: 67}: {B: 0xff, A: 0xff},
+			{X: 8, Y: 8}:   {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+			{X: 36, Y: 36}: {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		},
+	},
+}
+
+func TestDeckSetImageUnchanged(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dev := &virtDev{Writer: io.Discard}
+	d.setDev(dev)
+
+	white := uniformRGBA(image.Rect(0, 0, 72, 72), color.White)
+	black := uniformRGBA(image.Rect(0, 0, 72, 72), color.Black)
+	for _, step := range []struct {
+		op        func() error
+		wantWrite bool
+	}{
+		{op: func() error { return d.SetImage(0, 0, white) }, wantWrite: true},
+		{op: func() error { return d.SetImage(0, 0, white) }, wantWrite: false},
+		{op: func() error { return d.SetImage(0, 1, white) }, wantWrite: true},
+		{op: func() error { return d.SetImage(0, 0, black) }, wantWrite: true},
+		{op: func() error { return d.SetImage(0, 0, white) }, wantWrite: true},
+		{op: d.Reset, wantWrite: false},
+		{op: func() error { return d.SetImage(0, 0, white) }, wantWrite: true},
+	} {
+		n := len(dev.actions)
+		err := step.op()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var wrote bool
+		for _, a := range dev.actions[n:] {
+			wrote = wrote || strings.HasPrefix(a, "Write(")
+		}
+		if wrote != step.wantWrite {
+			t.Errorf("unexpected write state: got:%t want:%t", wrote, step.wantWrite)
+		}
+	}
+	if got := d.images[d.Key(0, 0)]; got != image.Image(white) {
+		t.Error("key image not recorded for skipped write")
+	}
+}
+
+func TestDeckSetImageRegion(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.setDev(&virtDev{Writer: io.Discard})
+
+	// A 2×1 mosaic of 72×72 tiles: red on the left, blue on the right.
+	src := uniformRGBA(image.Rect(0, 0, 144, 72), color.RGBA{R: 0xff, A: 0xff})
+	draw.Draw(src, image.Rect(72, 0, 144, 72), image.NewUniform(color.RGBA{B: 0xff, A: 0xff}), image.Point{}, draw.Src)
+
+	err = d.SetImageRegion(0, 0, src, image.Rect(72, 0, 144, 72))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, err := d.RawImage(uniformRGBA(image.Rect(0, 0, 72, 72), color.RGBA{B: 0xff, A: 0xff}))
+	if err != nil {
+		t.Fatalf("unexpected error for RawImage: %v", err)
+	}
+	if !bytes.Equal(d.written[d.Key(0, 0)], want.Data()) {
+		t.Error("unexpected image data for cropped region")
+	}
+	img := d.images[d.Key(0, 0)]
+	if img.Bounds() != image.Rect(72, 0, 144, 72) {
+		t.Errorf("unexpected recorded image bounds: got:%v want:%v", img.Bounds(), image.Rect(72, 0, 144, 72))
+	}
+
+	// A region extending past the image is clipped to it.
+	err = d.SetImageRegion(0, 1, src, image.Rect(-72, 0, 72, 72))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, err = d.RawImage(uniformRGBA(image.Rect(0, 0, 72, 72), color.RGBA{R: 0xff, A: 0xff}))
+	if err != nil {
+		t.Fatalf("unexpected error for RawImage: %v", err)
+	}
+	if !bytes.Equal(d.written[d.Key(0, 1)], want.Data()) {
+		t.Error("unexpected image data for clipped region")
+	}
+
+	err = d.SetImageRegion(0, 2, src, image.Rect(200, 0, 272, 72))
+	if err == nil {
+		t.Error("expected error for empty region")
+	}
+	err = d.SetImageRegion(10, 0, src, src.Bounds())
+	if err == nil {
+		t.Error("expected error for key out of bounds")
+	}
+}
+
+func TestDeviceFit(t *testing.T) {
+	desc := devices[StreamDeckMK2]
+	for _, test := range deviceFitTests {
+		t.Run(test.name, func(t *testing.T) {
+			got := desc.fit(test.img, test.background, test.pad)
+			if got.Bounds() != desc.bounds() {
+				t.Fatalf("unexpected bounds: got:%v want:%v", got.Bounds(), desc.bounds())
+			}
+			for p, want := range test.want {
+				if got := color.RGBAModel.Convert(got.At(p.X, p.Y)); got != want {
+					t.Errorf("unexpected colour at %v: got:%v want:%v", p, got, want)
+				}
+			}
+		})
+	}
+
+	img := image.NewRGBA(desc.bounds())
+	if got := desc.fit(img, nil, 0); got != image.Image(img) {
+		t.Error("fitted image unexpectedly altered")
+	}
+}
+
+func TestRawImages(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	imgs := map[int]image.Image{
+		0:  uniformRGBA(image.Rect(0, 0, 72, 72), color.RGBA{R: 0xff, A: 0xff}),
+		4:  uniformRGBA(image.Rect(0, 0, 36, 36), color.RGBA{G: 0xff, A: 0xff}),
+		14: uniformRGBA(image.Rect(0, 0, 100, 50), color.RGBA{B: 0xff, A: 0xff}),
+	}
+	got, err := d.RawImages(imgs)
+	if err != nil {
+		t.Fatalf("unexpected error for RawImages: %v", err)
+	}
+	if len(got) != len(imgs) {
+		t.Errorf("unexpected number of raw images: got:%d want:%d", len(got), len(imgs))
+	}
+	for k, img := range imgs {
+		want, err := d.RawImage(img)
+		if err != nil {
+			t.Fatalf("unexpected error for RawImage: %v", err)
+		}
+		raw, ok := got[k]
+		if !ok {
+			t.Errorf("missing raw image for key %d", k)
+			continue
+		}
+		if raw.Image != img {
+			t.Errorf("unexpected original image for key %d", k)
+		}
+		if !bytes.Equal(raw.data, want.data) {
+			t.Errorf("unexpected raw data for key %d", k)
+		}
+	}
+
+	imgs[15] = imgs[0]
+	_, err = d.RawImages(imgs)
+	if want := errors.New("key out of bounds: 15"); !sameError(err, want) {
+		t.Errorf("unexpected error for out of bounds key: got:%v want:%v", err, want)
+	}
+}
+
+func TestRender(t *testing.T) {
+	img := uniformRGBA(image.Rect(0, 0, 100, 50), color.RGBA{R: 0x80, G: 0x40, A: 0xff})
+	got, err := Render(StreamDeckMini, img, WithHighContrast(true))
+	if err != nil {
+		t.Fatalf("unexpected error for Render: %v", err)
+	}
+	d, err := newTestDeck(StreamDeckMini)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.SetHighContrast(true)
+	want, err := d.RawImage(img)
+	if err != nil {
+		t.Fatalf("unexpected error for RawImage: %v", err)
+	}
+	if got.PID() != StreamDeckMini {
+		t.Errorf("unexpected PID: got:%s want:%s", got.PID(), StreamDeckMini)
+	}
+	if !bytes.Equal(got.Data(), want.Data()) {
+		t.Error("rendered data does not match deck raw image")
+	}
+
+	raw, err := d.RawImageData(img, got.Data())
+	if err != nil {
+		t.Fatalf("unexpected error for RawImageData: %v", err)
+	}
+	if !d.reusable(raw) {
+		t.Error("raw image data not reusable by matching deck")
+	}
+	d.SetHighContrast(false)
+	if d.reusable(raw) {
+		t.Error("raw image data reusable by deck with different settings")
+	}
+
+	_, err = Render(StreamDeckPedal, img)
+	if want := errors.New("images not supported by StreamDeckPedal"); !sameError(err, want) {
+		t.Errorf("unexpected error for non-visual Render: got:%v want:%v", err, want)
+	}
+}
+
+func uniformRGBA(r image.Rectangle, c color.Color) *image.RGBA {
+	img := image.NewRGBA(r)
+	draw.Draw(img, r, &image.Uniform{c}, image.Point{}, draw.Src)
+	return img
+}
+
 func BenchmarkSetImage(b *testing.B) {
 	f, err := os.Open("testdata/gopher.png")
 	if err != nil {
@@ -802,12 +1227,84 @@ func prependZero(n int, b []byte) []byte {
 	return append(make([]byte, n), b...)
 }
 
+func TestDevices(t *testing.T) {
+	infos := Devices()
+	if len(infos) != len(devices) {
+		t.Fatalf("unexpected number of devices: got:%d want:%d", len(infos), len(devices))
+	}
+	for i, info := range infos {
+		if i != 0 && infos[i-1].PID >= info.PID {
+			t.Errorf("devices not sorted by PID: %s before %s", infos[i-1].PID, info.PID)
+		}
+		desc := devices[info.PID]
+		if info.Name != desc.PID.String() {
+			t.Errorf("unexpected name for %s: %s", desc.PID, info.Name)
+		}
+		if info.Rows != desc.rows || info.Cols != desc.cols {
+			t.Errorf("unexpected layout for %s: got:%dx%d want:%dx%d", info.Name, info.Rows, info.Cols, desc.rows, desc.cols)
+		}
+		want := map[PID]string{
+			StreamDeckMini:       "bmp",
+			StreamDeckMiniV2:     "bmp",
+			StreamDeckOriginal:   "bmp",
+			StreamDeckOriginalV2: "jpeg",
+			StreamDeckMK2:        "jpeg",
+			StreamDeckXL:         "jpeg",
+			StreamDeckMK2Scissor: "jpeg",
+		}[info.PID]
+		if info.Format != want {
+			t.Errorf("unexpected format for %s: got:%q want:%q", info.Name, info.Format, want)
+		}
+		canonical, ok := aliases[info.PID]
+		if !ok {
+			canonical = info.PID
+		}
+		if info.Canonical != canonical {
+			t.Errorf("unexpected canonical model for %s: got:%s want:%s", info.Name, info.Canonical, canonical)
+		}
+		if info.Geometry != nil {
+			info.Geometry.Rows = -1
+			if desc.geometry.Rows == -1 {
+				t.Errorf("device table geometry for %s mutated via Devices", info.Name)
+			}
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	for alias, pid := range aliases {
+		t.Run(fmt.Sprint(alias), func(t *testing.T) {
+			d, err := newTestDeck(alias)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if d.PID() != alias {
+				t.Errorf("unexpected PID: got:%s want:%s", d.PID(), alias)
+			}
+			if got := d.Capabilities().Canonical; got != pid {
+				t.Errorf("unexpected canonical model: got:%s want:%s", got, pid)
+			}
+			if rows, cols := d.Layout(); rows != devices[pid].rows || cols != devices[pid].cols {
+				t.Errorf("unexpected layout: got:%dx%d want:%dx%d", rows, cols, devices[pid].rows, devices[pid].cols)
+			}
+
+			raw, err := Render(pid, uniformRGBA(image.Rect(0, 0, 10, 10), color.RGBA{R: 0xff, A: 0xff}))
+			if err != nil {
+				t.Fatalf("unexpected error for Render: %v", err)
+			}
+			if !d.reusable(raw) {
+				t.Errorf("raw image rendered for %s not reusable by %s", pid, alias)
+			}
+		})
+	}
+}
+
 func newTestDeck(pid PID) (*Deck, error) {
 	desc, ok := devices[pid]
 	if !ok {
 		return nil, fmt.Errorf("%s not a valid deck device identifier", pid)
 	}
-	d := &Deck{desc: &desc, buf: make([]byte, desc.bufLen())}
+	d := &Deck{desc: &desc, buf: make([]byte, desc.bufLen()), brightness: -1}
 	return d, nil
 }
 
@@ -853,3 +1350,92 @@ func (d *virtDev) SendFeatureReport(b []byte) (int, error) {
 	d.actions = append(d.actions, fmt.Sprintf("SendFeatureReport(%#v) -> (%d, %v)", b, n, err))
 	return n, err
 }
+
+func TestToRGBA(t *testing.T) {
+	b := image.Rect(2, 3, 18, 19)
+	ycbcr := image.NewYCbCr(b, image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = uint8(i)
+	}
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i] = uint8(0x80 + i)
+		ycbcr.Cr[i] = uint8(0x80 - i)
+	}
+	nrgba := image.NewNRGBA(b)
+	gray := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			nrgba.Set(x, y, color.NRGBA{R: uint8(x * 10), G: uint8(y * 10), B: 0x40, A: uint8(0x80 + x)})
+			gray.Set(x, y, color.Gray{Y: uint8(x * y)})
+		}
+	}
+	for _, src := range []image.Image{ycbcr, nrgba, gray} {
+		var got *image.RGBA
+		if src == image.Image(gray) {
+			// Gray images are only converted on request.
+			got = rgbaImage(src)
+		} else {
+			var ok bool
+			got, ok = toRGBA(src).(*image.RGBA)
+			if !ok {
+				t.Errorf("%T not converted to *image.RGBA", src)
+				continue
+			}
+		}
+		if got.Bounds() != b {
+			t.Errorf("unexpected bounds for %T: got:%v want:%v", src, got.Bounds(), b)
+		}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				want := color.RGBAModel.Convert(src.At(x, y)).(color.RGBA)
+				if !closeRGBA(got.RGBAAt(x, y), want, 1) {
+					t.Errorf("unexpected colour for %T at (%d,%d): got:%v want:%v", src, x, y, got.RGBAAt(x, y), want)
+				}
+			}
+		}
+	}
+
+	for _, img := range []image.Image{image.NewRGBA(b), gray} {
+		if got := toRGBA(img); got != img {
+			t.Errorf("%T image unexpectedly converted", img)
+		}
+	}
+	paletted := image.NewPaletted(b, color.Palette{color.Black})
+	if got := toRGBA(paletted); got != image.Image(paletted) {
+		t.Error("paletted image unexpectedly converted")
+	}
+}
+
+// closeRGBA returns whether a and b differ by at most tol in each channel.
+func closeRGBA(a, b color.RGBA, tol int) bool {
+	diff := func(x, y uint8) bool {
+		d := int(x) - int(y)
+		return -tol <= d && d <= tol
+	}
+	return diff(a.R, b.R) && diff(a.G, b.G) && diff(a.B, b.B) && diff(a.A, b.A)
+}
+
+func BenchmarkRawImage(b *testing.B) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	for _, size := range []int{72, 512} {
+		r := image.Rect(0, 0, size, size)
+		for _, img := range []image.Image{
+			image.NewYCbCr(r, image.YCbCrSubsampleRatio420),
+			image.NewNRGBA(r),
+			image.NewGray(r),
+			image.NewRGBA(r),
+		} {
+			b.Run(fmt.Sprintf("%T/%d", img, size), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					_, err := d.RawImage(img)
+					if err != nil {
+						b.Fatalf("unexpected error for RawImage: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
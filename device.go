@@ -8,10 +8,8 @@ import (
 	"encoding/binary"
 	"image"
 	"image/color"
-	"image/jpeg"
-	"io"
-
-	"golang.org/x/image/bmp"
+	"sort"
+	"time"
 )
 
 const vidElGato = 0x0fd9
@@ -29,19 +27,54 @@ const (
 	StreamDeckMK2        PID = 0x0080
 	StreamDeckXL         PID = 0x006c
 	StreamDeckPedal      PID = 0x0086
+
+	// StreamDeckStudio is the rack-mounted Stream
+	// Deck Studio. It is not supported; its report
+	// layout has not been checked against a device.
+	StreamDeckStudio PID = 0x00aa
+
+	// StreamDeckMK2Scissor is the scissor-switch
+	// variant of the MK.2.
+	StreamDeckMK2Scissor PID = 0x00a5
 )
 
+// aliases maps the PIDs of variants of a model that differ from it only in
+// their PID, such as OEM and regional variants, to the PID of the model.
+// Aliased variants are added to the devices table when the package is
+// initialised.
+var aliases = map[PID]PID{
+	StreamDeckMK2Scissor: StreamDeckMK2,
+}
+
+func init() {
+	for alias, pid := range aliases {
+		desc := devices[pid]
+		desc.PID = alias
+		desc.canonical = pid
+		devices[alias] = desc
+	}
+}
+
 // device is an El Gato Stream Deck device description.
 type device struct {
 	PID
 
+	// canonical is the PID of the model the
+	// device is a variant of, or zero if the
+	// device is not a variant.
+	canonical PID
+
+	// vid is the USB vendor ID of the device,
+	// or zero for El Gato devices.
+	vid uint16
+
 	cols int
 	rows int
 
 	visual    bool
 	keySize   image.Point
 	transform func(image.Image) image.Image
-	encode    func(io.Writer, image.Image) error
+	format    string // format is the name of the registered image encoder.
 
 	imgReportLen int
 	imageHeader  []byte
@@ -61,6 +94,45 @@ type device struct {
 	keyStatesOffset int
 	serialOffset    int
 	firmwareOffset  int
+
+	// typedInputReports is whether the second byte of
+	// an input report gives its type. Only reports with
+	// type zero hold key states.
+	typedInputReports bool
+
+	// debounce is the default key debounce
+	// interval for the device.
+	debounce time.Duration
+
+	// geometry is the physical layout of the
+	// device's keys, if known.
+	geometry *Geometry
+
+	// quirks is the set of workarounds required
+	// for ranges of firmware versions.
+	quirks []quirkRange
+}
+
+// vendor returns the USB vendor ID of the device.
+func (d *device) vendor() uint16 {
+	if d.vid != 0 {
+		return d.vid
+	}
+	return vidElGato
+}
+
+// open opens the device with the given serial number, or the first
+// matching device if serial is empty.
+func (d *device) open(serial string) (HIDDevice, error) {
+	return open(d.vendor(), d.PID, serial)
+}
+
+// model returns the PID of the device's canonical model.
+func (d *device) model() PID {
+	if d.canonical != 0 {
+		return d.canonical
+	}
+	return d.PID
 }
 
 func (d *device) bufLen() int {
@@ -74,8 +146,33 @@ func (d *device) bounds() image.Rectangle {
 	return image.Rectangle{Max: d.keySize}
 }
 
+// transpose returns img transposed about its leading diagonal.
+//
+// The transformed images are only used for encoding, and the encoders
+// ignore alpha, so *image.RGBA and *image.NRGBA images are transposed
+// directly into a new opaque *image.RGBA holding the alpha-premultiplied
+// colour values the encoders would have read from the wrapped image.
+// Other image types are wrapped.
 func transpose(img image.Image) image.Image {
-	return t{img}
+	src, ok := pixels(img)
+	if !ok {
+		return t{img}
+	}
+	b := img.Bounds()
+	dst := opaqueRGBA(b)
+	w, h := b.Dx(), b.Dy()
+	n := w
+	if h < n {
+		n = h
+	}
+	for y := 0; y < n; y++ {
+		row := dst.Pix[y*dst.Stride:]
+		for x := 0; x < n; x++ {
+			// Destination (x, y) is source (y, x).
+			src.copyOpaque(row[x*4:x*4+4], y, x)
+		}
+	}
+	return dst
 }
 
 type t struct{ image.Image }
@@ -85,19 +182,146 @@ func (i t) At(x, y int) color.Color {
 	return i.Image.At(y-b.Min.Y+b.Min.X, x-b.Min.X+b.Min.Y)
 }
 
+// rotate180 returns img rotated by 180 degrees. As for transpose,
+// *image.RGBA and *image.NRGBA images are rotated directly into a new
+// opaque *image.RGBA and other image types are wrapped.
 func rotate180(img image.Image) image.Image {
-	return r180{img}
+	src, ok := pixels(img)
+	if !ok {
+		return r180{img}
+	}
+	b := img.Bounds()
+	dst := opaqueRGBA(b)
+	w, h := b.Dx(), b.Dy()
+	for y := 0; y < h; y++ {
+		row := dst.Pix[y*dst.Stride:]
+		for x := 0; x < w; x++ {
+			// Destination (x, y) is source (w-1-x, h-1-y).
+			src.copyOpaque(row[x*4:x*4+4], w-1-x, h-1-y)
+		}
+	}
+	return dst
 }
 
 type r180 struct{ image.Image }
 
 func (i r180) At(x, y int) color.Color {
 	b := i.Bounds()
-	return i.Image.At(b.Dx()-x+2*b.Min.X, b.Dy()-y+2*b.Min.Y)
+	return i.Image.At(b.Max.X+b.Min.X-1-x, b.Max.Y+b.Min.Y-1-y)
 }
 
-func jpegEncode(w io.Writer, img image.Image) error {
-	return jpeg.Encode(w, img, &jpeg.Options{Quality: 95})
+// pixBuffer is the pixel data of an *image.RGBA or *image.NRGBA.
+type pixBuffer struct {
+	pix    []byte
+	stride int
+	nrgba  bool
+}
+
+// pixels returns the pixel data of img if it is an *image.RGBA or an
+// *image.NRGBA.
+func pixels(img image.Image) (pixBuffer, bool) {
+	switch img := img.(type) {
+	case *image.RGBA:
+		return pixBuffer{pix: img.Pix, stride: img.Stride}, true
+	case *image.NRGBA:
+		return pixBuffer{pix: img.Pix, stride: img.Stride, nrgba: true}, true
+	}
+	return pixBuffer{}, false
+}
+
+// opaqueRGBA returns a new opaque black *image.RGBA with the given bounds.
+// Pixels of the transformed images with no source pixel are black, as the
+// encoders would see the transparent colour returned by the wrappers.
+func opaqueRGBA(b image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(b)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	return img
+}
+
+// copyOpaque copies the alpha-premultiplied colour of the pixel at (x, y)
+// relative to the buffer origin to dst as an opaque RGBA pixel.
+func (p pixBuffer) copyOpaque(dst []byte, x, y int) {
+	s := p.pix[y*p.stride+x*4 : y*p.stride+x*4+4]
+	if !p.nrgba {
+		dst[0], dst[1], dst[2], dst[3] = s[0], s[1], s[2], 0xff
+		return
+	}
+	// Premultiply as color.NRGBA.RGBA does.
+	a := uint32(s[3])
+	a |= a << 8
+	for i, v := range s[:3] {
+		c := uint32(v)
+		c |= c << 8
+		dst[i] = uint8((c * a / 0xffff) >> 8)
+	}
+	dst[3] = 0xff
+}
+
+// DeviceInfo describes a supported Stream Deck model.
+type DeviceInfo struct {
+	PID  PID
+	Name string
+
+	// Canonical is the PID of the model that
+	// the device is a variant of. For models
+	// that are not variants, Canonical is PID.
+	Canonical PID
+
+	// Rows and Cols are the key layout of
+	// the device.
+	Rows, Cols int
+
+	// Visual is whether the device's keys
+	// display images. KeySize and Format
+	// are only valid for visual devices.
+	Visual bool
+
+	// KeySize is the size of a key image
+	// in pixels.
+	KeySize image.Point
+
+	// Format is the name of the image format
+	// used to send key images to the device,
+	// "bmp" or "jpeg".
+	Format string
+
+	// Geometry is the physical layout of the
+	// device's keys, if known.
+	Geometry *Geometry
+}
+
+// Devices returns descriptions of all the Stream Deck models supported by
+// ardilla, sorted by PID.
+func Devices() []DeviceInfo {
+	infos := make([]DeviceInfo, 0, len(devices))
+	for _, d := range devices {
+		infos = append(infos, d.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].PID < infos[j].PID
+	})
+	return infos
+}
+
+// info returns the description of the device model.
+func (d *device) info() DeviceInfo {
+	info := DeviceInfo{
+		PID:       d.PID,
+		Name:      d.PID.String(),
+		Canonical: d.model(),
+		Rows:      d.rows,
+		Cols:      d.cols,
+		Visual:    d.visual,
+		KeySize:   d.keySize,
+		Format:    d.format,
+	}
+	if d.geometry != nil {
+		g := *d.geometry
+		info.Geometry = &g
+	}
+	return info
 }
 
 var devices = map[PID]device{
@@ -108,8 +332,9 @@ var devices = map[PID]device{
 
 		visual:    true,
 		keySize:   image.Point{80, 80},
+		geometry:  miniGeometry,
 		transform: transpose,
-		encode:    bmp.Encode,
+		format:    "bmp",
 
 		imgReportLen: 1024,
 		imageHeader:  []byte{0x02, 0x01, 0xff /*page*/, 0x00, 0xff /*done*/, 0xff /*key+1*/, 15: 0},
@@ -135,8 +360,9 @@ var devices = map[PID]device{
 
 		visual:    true,
 		keySize:   image.Point{80, 80},
+		geometry:  miniGeometry,
 		transform: transpose,
-		encode:    bmp.Encode,
+		format:    "bmp",
 
 		imgReportLen: 1024,
 		imageHeader:  []byte{0x02, 0x01, 0xff /*page*/, 0x00, 0xff /*done*/, 0xff /*key+1*/, 15: 0},
@@ -163,8 +389,9 @@ var devices = map[PID]device{
 
 		visual:    true,
 		keySize:   image.Point{72, 72},
+		geometry:  originalGeometry,
 		transform: rotate180,
-		encode:    bmp.Encode,
+		format:    "bmp",
 
 		imgReportLen: 8191,
 		imageHeader:  []byte{0x02, 0x01, 0xff /*page*/, 0x00, 0xff /*done*/, 0xff /*key+1*/, 15: 0},
@@ -190,8 +417,9 @@ var devices = map[PID]device{
 
 		visual:    true,
 		keySize:   image.Point{72, 72},
+		geometry:  originalGeometry,
 		transform: rotate180,
-		encode:    jpegEncode,
+		format:    "jpeg",
 
 		imgReportLen: 1024,
 		imageHeader:  []byte{0x02, 0x07, 0xff /*key*/, 0xff /*done*/, 0xff, 0xff /*length le*/, 0xff, 0xff /*page le*/},
@@ -208,6 +436,8 @@ var devices = map[PID]device{
 		firmwareOffset: 6,
 
 		keyStatesOffset: 4,
+
+		typedInputReports: true,
 	},
 
 	StreamDeckMK2: {
@@ -217,8 +447,9 @@ var devices = map[PID]device{
 
 		visual:    true,
 		keySize:   image.Point{72, 72},
+		geometry:  originalGeometry,
 		transform: rotate180,
-		encode:    jpegEncode,
+		format:    "jpeg",
 
 		imgReportLen: 1024,
 		imageHeader:  []byte{0x02, 0x07, 0xff /*key*/, 0xff /*done*/, 0xff, 0xff /*length le*/, 0xff, 0xff /*page le*/},
@@ -235,6 +466,8 @@ var devices = map[PID]device{
 		firmwareOffset: 6,
 
 		keyStatesOffset: 4,
+
+		typedInputReports: true,
 	},
 
 	StreamDeckXL: {
@@ -244,8 +477,9 @@ var devices = map[PID]device{
 
 		visual:    true,
 		keySize:   image.Point{96, 96},
+		geometry:  xlGeometry,
 		transform: rotate180,
-		encode:    jpegEncode,
+		format:    "jpeg",
 
 		imgReportLen: 1024,
 		imageHeader:  []byte{0x02, 0x07, 0xff /*key*/, 0xff /*done*/, 0xff, 0xff /*length le*/, 0xff, 0xff /*page le*/},
@@ -262,6 +496,8 @@ var devices = map[PID]device{
 		firmwareOffset: 6,
 
 		keyStatesOffset: 4,
+
+		typedInputReports: true,
 	},
 
 	StreamDeckPedal: {
@@ -277,6 +513,8 @@ var devices = map[PID]device{
 		firmwareOffset: 6,
 
 		keyStatesOffset: 4,
+
+		debounce: pedalDebounce,
 	},
 }
 
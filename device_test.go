@@ -0,0 +1,129 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+var transformTests = []struct {
+	name string
+	fn   func(image.Image) image.Image
+	wrap func(image.Image) image.Image
+}{
+	{name: "transpose", fn: transpose, wrap: func(img image.Image) image.Image { return t{img} }},
+	{name: "rotate180", fn: rotate180, wrap: func(img image.Image) image.Image { return r180{img} }},
+}
+
+var transformRects = []image.Rectangle{
+	image.Rect(0, 0, 72, 72),
+	image.Rect(3, 5, 13, 15),
+	image.Rect(0, 0, 7, 4),
+	image.Rect(-2, 1, 3, 9),
+}
+
+func TestTransformFastPath(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range transformTests {
+		for _, r := range transformRects {
+			src := map[string]image.Image{
+				"RGBA":  image.NewRGBA(r),
+				"NRGBA": image.NewNRGBA(r),
+			}
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					c := color.NRGBA{R: uint8(rnd.Intn(256)), G: uint8(rnd.Intn(256)), B: uint8(rnd.Intn(256)), A: uint8(rnd.Intn(256))}
+					src["RGBA"].(*image.RGBA).Set(x, y, c)
+					src["NRGBA"].(*image.NRGBA).Set(x, y, c)
+				}
+			}
+			for typ, img := range src {
+				got := test.fn(img)
+				want := test.wrap(img)
+				if got.Bounds() != want.Bounds() {
+					t.Errorf("unexpected bounds for %s %s %v: got:%v want:%v", test.name, typ, r, got.Bounds(), want.Bounds())
+					continue
+				}
+				rgba, ok := got.(*image.RGBA)
+				if !ok {
+					t.Errorf("unexpected result type for %s %s %v: got:%T want:*image.RGBA", test.name, typ, r, got)
+					continue
+				}
+				if !rgba.Opaque() {
+					t.Errorf("result not opaque for %s %s %v", test.name, typ, r)
+				}
+				// The encoders ignore alpha, so compare the
+				// premultiplied colour values they read.
+			pixels:
+				for y := r.Min.Y; y < r.Max.Y; y++ {
+					for x := r.Min.X; x < r.Max.X; x++ {
+						if g, w := encoded(got.At(x, y)), encoded(want.At(x, y)); g != w {
+							t.Errorf("unexpected pixel for %s %s %v at (%d,%d): got:%v want:%v", test.name, typ, r, x, y, g, w)
+							break pixels
+						}
+					}
+				}
+				for _, format := range []string{"bmp", "jpeg"} {
+					encode, err := encoder(format)
+					if err != nil {
+						t.Fatalf("unexpected error getting encoder: %v", err)
+					}
+					var gotBuf, wantBuf bytes.Buffer
+					err = encode(&gotBuf, got)
+					if err != nil {
+						t.Errorf("unexpected error encoding %s: %v", format, err)
+					}
+					err = encode(&wantBuf, want)
+					if err != nil {
+						t.Errorf("unexpected error encoding %s: %v", format, err)
+					}
+					if !bytes.Equal(gotBuf.Bytes(), wantBuf.Bytes()) {
+						t.Errorf("unexpected %s encoding for %s %s %v", format, test.name, typ, r)
+					}
+				}
+			}
+		}
+	}
+}
+
+// encoded returns the 8-bit colour values of c as read by the encoders.
+func encoded(c color.Color) [3]uint8 {
+	r, g, b, _ := c.RGBA()
+	return [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)}
+}
+
+func BenchmarkTransform(b *testing.B) {
+	src := image.NewRGBA(image.Rect(0, 0, 96, 96))
+	for _, test := range transformTests {
+		for _, impl := range []struct {
+			name string
+			fn   func(image.Image) image.Image
+		}{
+			{name: "fast", fn: test.fn},
+			{name: "wrapped", fn: test.wrap},
+		} {
+			for _, format := range []string{"bmp", "jpeg"} {
+				encode, err := encoder(format)
+				if err != nil {
+					b.Fatalf("unexpected error getting encoder: %v", err)
+				}
+				b.Run(test.name+"/"+impl.name+"/"+format, func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						err := encode(io.Discard, impl.fn(src))
+						if err != nil {
+							b.Fatalf("unexpected error encoding: %v", err)
+						}
+					}
+				})
+			}
+		}
+	}
+}
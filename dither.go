@@ -0,0 +1,175 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Dither is a dithering method for key images on devices that use BMP
+// key images.
+type Dither int
+
+const (
+	// NoDither renders key images without dithering.
+	NoDither Dither = iota
+
+	// FloydSteinberg renders key images with
+	// Floyd–Steinberg error diffusion.
+	FloydSteinberg
+
+	// OrderedDither renders key images with a 4×4
+	// Bayer threshold matrix. Ordered dithering does
+	// not spread changes in one part of an image to
+	// other parts, so it is better suited than
+	// error diffusion to animation.
+	OrderedDither
+)
+
+func (m Dither) String() string {
+	switch m {
+	case NoDither:
+		return "none"
+	case FloydSteinberg:
+		return "floyd-steinberg"
+	case OrderedDither:
+		return "ordered"
+	default:
+		return fmt.Sprintf("Dither(%d)", int(m))
+	}
+}
+
+// SetDither sets the dithering method used for key images on devices that
+// use BMP key images, such as the Stream Deck Mini, where gradients show
+// banding. Dithered images are quantised to 5 bits for red and blue and
+// 6 bits for green. The setting is ignored for other devices. Keys on a
+// BMP device are redrawn with the new method, and a RawImage dithered
+// with another method is encoded again when it is next set.
+func (d *Deck) SetDither(m Dither) error {
+	if m < NoDither || OrderedDither < m {
+		return fmt.Errorf("invalid dither method: %v", m)
+	}
+	if !d.desc.visual {
+		return nil
+	}
+	d.mu.Lock()
+	if d.dither == m {
+		d.mu.Unlock()
+		return nil
+	}
+	d.dither = m
+	bmp := d.desc.format == "bmp"
+	d.mu.Unlock()
+	if !bmp {
+		return nil
+	}
+	return d.rerender()
+}
+
+// Dither returns the dithering method set for the deck.
+func (d *Deck) Dither() Dither {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dither
+}
+
+// ditherBits is the number of bits retained for each of the red, green
+// and blue channels of dithered images.
+var ditherBits = [3]uint{5, 6, 5}
+
+// dither returns img dithered with the given method.
+func dither(img image.Image, m Dither) image.Image {
+	switch m {
+	case FloydSteinberg:
+		return floydSteinberg(img)
+	case OrderedDither:
+		return ordered(img)
+	default:
+		return img
+	}
+}
+
+// quantise returns v quantised to the given number of bits and scaled
+// back to the range [0, 255].
+func quantise(v int, bits uint) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 0xff {
+		return 0xff
+	}
+	levels := 1<<bits - 1
+	q := (v*levels + 0x7f) / 0xff
+	return uint8(q * 0xff / levels)
+}
+
+// floydSteinberg returns img quantised with Floyd–Steinberg error
+// diffusion.
+func floydSteinberg(img image.Image) *image.RGBA {
+	src := rgbaImage(img)
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	w := b.Dx()
+	// cur and next hold the accumulated error for the current
+	// and next rows, scaled by 16, with a pixel of margin at
+	// each end.
+	cur := make([][3]int, w+2)
+	next := make([][3]int, w+2)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := x - b.Min.X + 1
+			c := src.RGBAAt(x, y)
+			in := [3]int{int(c.R), int(c.G), int(c.B)}
+			var out [3]uint8
+			for ch, v := range in {
+				v += cur[i][ch] / 16
+				out[ch] = quantise(v, ditherBits[ch])
+				e := v - int(out[ch])
+				cur[i+1][ch] += e * 7
+				next[i-1][ch] += e * 3
+				next[i][ch] += e * 5
+				next[i+1][ch] += e * 1
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: out[0], G: out[1], B: out[2], A: c.A})
+		}
+		cur, next = next, cur
+		for i := range next {
+			next[i] = [3]int{}
+		}
+	}
+	return dst
+}
+
+// bayer4 is a 4×4 Bayer threshold matrix.
+var bayer4 = [4][4]int{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// ordered returns img quantised with ordered dithering.
+func ordered(img image.Image) *image.RGBA {
+	src := rgbaImage(img)
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := src.RGBAAt(x, y)
+			// The threshold offset is in the range (-0.5, 0.5)
+			// of a quantisation step, scaled by 32.
+			t := 2*bayer4[y&3][x&3] - 15
+			var out [3]uint8
+			for ch, v := range [3]int{int(c.R), int(c.G), int(c.B)} {
+				step := 0xff / (1<<ditherBits[ch] - 1)
+				out[ch] = quantise(v+t*step/32, ditherBits[ch])
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: out[0], G: out[1], B: out[2], A: c.A})
+		}
+	}
+	return dst
+}
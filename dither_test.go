@@ -0,0 +1,129 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func TestDither(t *testing.T) {
+	// A horizontal grey gradient shows banding when quantised
+	// without dithering.
+	grad := image.NewRGBA(image.Rect(0, 0, 64, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 64; x++ {
+			grad.Set(x, y, color.Gray{Y: uint8(0x40 + x/4)})
+		}
+	}
+	for _, m := range []Dither{FloydSteinberg, OrderedDither} {
+		got := dither(grad, m).(*image.RGBA)
+		if got.Bounds() != grad.Bounds() {
+			t.Errorf("unexpected bounds for %v: got:%v want:%v", m, got.Bounds(), grad.Bounds())
+		}
+		var sumIn, sumOut int
+		for i := 0; i < len(got.Pix); i += 4 {
+			for ch := 0; ch < 3; ch++ {
+				v := got.Pix[i+ch]
+				if quantise(int(v), ditherBits[ch]) != v {
+					t.Errorf("unquantised value for %v in channel %d: %#x", m, ch, v)
+				}
+			}
+			sumIn += int(grad.Pix[i])
+			sumOut += int(got.Pix[i])
+		}
+		// Dithering preserves the average intensity.
+		n := len(got.Pix) / 4
+		if diff := sumIn/n - sumOut/n; diff < -2 || 2 < diff {
+			t.Errorf("unexpected mean intensity for %v: got:%d want:%d", m, sumOut/n, sumIn/n)
+		}
+		// Dithering differs from plain quantisation.
+		dithered := false
+		for i := 0; i < len(got.Pix); i += 4 {
+			if got.Pix[i] != quantise(int(grad.Pix[i]), ditherBits[0]) {
+				dithered = true
+				break
+			}
+		}
+		if !dithered {
+			t.Errorf("no dithering for %v", m)
+		}
+	}
+	if got := dither(grad, NoDither); got != image.Image(grad) {
+		t.Error("image altered with NoDither")
+	}
+}
+
+func TestQuantise(t *testing.T) {
+	for _, test := range []struct {
+		v    int
+		bits uint
+		want uint8
+	}{
+		{v: -10, bits: 5, want: 0},
+		{v: 0, bits: 5, want: 0},
+		{v: 4, bits: 5, want: 0},
+		{v: 5, bits: 5, want: 8},
+		{v: 0x80, bits: 6, want: 0x81},
+		{v: 0xff, bits: 5, want: 0xff},
+		{v: 300, bits: 6, want: 0xff},
+	} {
+		if got := quantise(test.v, test.bits); got != test.want {
+			t.Errorf("unexpected result for quantise(%d, %d): got:%#x want:%#x", test.v, test.bits, got, test.want)
+		}
+	}
+}
+
+func TestDeckSetDither(t *testing.T) {
+	for _, test := range []struct {
+		pid         PID
+		wantWritten bool
+	}{
+		{pid: StreamDeckMini, wantWritten: true},
+		{pid: StreamDeckMK2, wantWritten: false},
+	} {
+		d, err := newTestDeck(test.pid)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		dev := &virtDev{Writer: io.Discard}
+		d.setDev(dev)
+
+		b, _ := d.Bounds()
+		img := image.NewRGBA(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				img.Set(x, y, color.Gray{Y: uint8(x)})
+			}
+		}
+		err = d.SetImage(0, 0, img)
+		if err != nil {
+			t.Fatalf("unexpected error for SetImage: %v", err)
+		}
+		dev.actions = nil
+		err = d.SetDither(OrderedDither)
+		if err != nil {
+			t.Fatalf("unexpected error for SetDither: %v", err)
+		}
+		if got := d.Dither(); got != OrderedDither {
+			t.Errorf("unexpected dither method for %s: got:%v want:%v", test.pid, got, OrderedDither)
+		}
+		if written := len(dev.actions) != 0; written != test.wantWritten {
+			t.Errorf("unexpected rewrite for %s: got:%t want:%t", test.pid, written, test.wantWritten)
+		}
+	}
+
+	d, err := newTestDeck(StreamDeckMini)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = d.SetDither(Dither(5))
+	if want := errors.New("invalid dither method: Dither(5)"); !sameError(err, want) {
+		t.Errorf("unexpected error for invalid method: got:%v want:%v", err, want)
+	}
+}
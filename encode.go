@@ -0,0 +1,62 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"sync"
+
+	"golang.org/x/image/bmp"
+)
+
+// Encoder is an image encoder for key images.
+type Encoder func(io.Writer, image.Image) error
+
+// encoders is the registry of key image encoders keyed by format name.
+var encoders = struct {
+	sync.RWMutex
+	m map[string]Encoder
+}{
+	m: map[string]Encoder{
+		"bmp":  bmp.Encode,
+		"jpeg": jpegEncode,
+	},
+}
+
+func jpegEncode(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 95})
+}
+
+// RegisterEncoder registers enc as the encoder for the named image format,
+// replacing any previously registered encoder. Devices use the "bmp" or
+// "jpeg" formats as given by the Format field of their DeviceInfo, and a
+// replacement encoder must produce data in the same format. Registering a
+// nil Encoder removes the named format.
+//
+// RegisterEncoder affects all Decks. Key images that have already been
+// encoded, including RawImages, are not re-encoded.
+func RegisterEncoder(format string, enc Encoder) {
+	encoders.Lock()
+	defer encoders.Unlock()
+	if enc == nil {
+		delete(encoders.m, format)
+		return
+	}
+	encoders.m[format] = enc
+}
+
+// encoder returns the registered encoder for the named format.
+func encoder(format string) (Encoder, error) {
+	encoders.RLock()
+	defer encoders.RUnlock()
+	enc, ok := encoders.m[format]
+	if !ok {
+		return nil, fmt.Errorf("no encoder registered for %q format", format)
+	}
+	return enc, nil
+}
@@ -0,0 +1,44 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"image"
+	"io"
+	"testing"
+)
+
+func TestRegisterEncoder(t *testing.T) {
+	d, err := newTestDeck(StreamDeckXL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 96, 96))
+
+	defer RegisterEncoder("jpeg", jpegEncode)
+	var called bool
+	RegisterEncoder("jpeg", func(w io.Writer, img image.Image) error {
+		called = true
+		_, err := w.Write([]byte("encoded"))
+		return err
+	})
+	raw, err := d.RawImage(img)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !called {
+		t.Error("registered encoder not called")
+	}
+	if got := string(raw.data); got != "encoded" {
+		t.Errorf("unexpected encoded data: got:%q want:%q", got, "encoded")
+	}
+
+	RegisterEncoder("jpeg", nil)
+	_, err = d.RawImage(img)
+	if want := errors.New(`no encoder registered for "jpeg" format`); !sameError(err, want) {
+		t.Errorf("unexpected error for missing encoder: got:%v want:%v", err, want)
+	}
+}
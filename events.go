@@ -0,0 +1,264 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"time"
+)
+
+// KeyEvent is a key press or release event.
+type KeyEvent struct {
+	// Time is the time the key state report was read,
+	// taken immediately after the read returned, or
+	// for changes delayed by debouncing and for Stuck
+	// events, the time the event was determined. Time
+	// holds a monotonic clock reading, so the interval
+	// between events may be found with Time.Sub.
+	Time time.Time
+
+	// Seq is the sequence number of the event in the
+	// stream, starting from one.
+	Seq uint64
+
+	// Report is the sequence number of the key state
+	// report that the event was derived from, starting
+	// from one. Events derived from the same report
+	// have the same Report number.
+	Report uint64
+
+	// Key, Row and Col identify the key.
+	Key, Row, Col int
+
+	// Pressed is whether the key was pressed
+	// or released.
+	Pressed bool
+
+	// Stuck is whether the event reports that the
+	// key has been held pressed for longer than the
+	// Deck's stuck timeout. A Stuck event does not
+	// change the state of the key.
+	Stuck bool
+
+	// Lagged is the number of key state reports that
+	// were dropped because the consumer of the events,
+	// or the reader of a buffering backend such as
+	// WebHID, did not keep up with the device. If
+	// Lagged is not zero, the key fields are not valid
+	// and the key states should be resynchronised, for
+	// example with KeyStates. Events following a Lagged event
+	// reflect the net change in key states since the
+	// last delivered report.
+	Lagged uint64
+
+	// Err is the error that ended the event stream.
+	// If Err is not nil, the other fields are not
+	// valid and the event is the last in the stream.
+	Err error
+}
+
+// eventBuffer is the number of key state reports held between the device
+// reader and the consumer of key events before reports are dropped.
+const eventBuffer = 64
+
+// lossyReader is implemented by HID devices that buffer input reports and
+// drop them when the buffer is full. Dropped returns the number of reports
+// dropped since the previous call.
+type lossyReader interface {
+	Dropped() uint64
+}
+
+// deviceDropped returns the number of input reports dropped by the device
+// since the previous call.
+func (d *Deck) deviceDropped() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if l, ok := d.dev.(lossyReader); ok {
+		return l.Dropped()
+	}
+	return 0
+}
+
+// stateReport is a key state report read from the device.
+type stateReport struct {
+	states []bool
+	time   time.Time
+	err    error
+
+	// dropped is the number of reports dropped
+	// immediately before this report.
+	dropped uint64
+}
+
+// keyEvents reads key state reports from the device and calls yield for
+// each key state change until ctx is cancelled, yield returns false or
+// reading a report fails. Key states are initially assumed to be released.
+// Key state changes are debounced and stuck keys are reported according
+// to the Deck's debounce interval and stuck timeout.
+//
+// Reports are read by a separate goroutine so that the device is drained
+// while yield is running. If more than eventBuffer reports are waiting to
+// be handled, further reports are dropped and a Lagged event is yielded
+// in their place. Reports dropped by the device itself are included in
+// the Lagged count. Reports that have been read but not handled when
+// keyEvents returns are discarded. The reading goroutine terminates when
+// its next read returns after keyEvents has returned.
+func (d *Deck) keyEvents(ctx context.Context, yield func(KeyEvent) bool) {
+	reports := make(chan stateReport, eventBuffer)
+	// States buffers are recycled through free so that the
+	// event stream does not allocate in steady state. At most
+	// eventBuffer buffers are queued, one is being handled and
+	// one is being filled.
+	free := make(chan []bool, eventBuffer+2)
+	for i := 0; i < cap(free); i++ {
+		free <- make([]bool, 0, d.Len())
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		var (
+			dropped uint64
+			now     time.Time
+			err     error
+		)
+		states := <-free
+		for {
+			states, now, err = d.keyStates(states[:0])
+			select {
+			case <-done:
+				return
+			default:
+			}
+			dropped += d.deviceDropped()
+			r := stateReport{states: states, time: now, err: err, dropped: dropped}
+			if err != nil {
+				select {
+				case reports <- r:
+				case <-done:
+				}
+				return
+			}
+			select {
+			case reports <- r:
+				dropped = 0
+				select {
+				case states = <-free:
+				case <-done:
+					return
+				}
+			default:
+				// Reuse the buffer for the next report.
+				dropped++
+			}
+		}
+	}()
+
+	d.mu.Lock()
+	keys := newKeyConditioner(d.Len(), d.desc.cols, d.debounce, d.stuckTimeout)
+	d.mu.Unlock()
+	var (
+		seq, report uint64
+		timer       *time.Timer
+		due         <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		var r stateReport
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-due:
+			r.time = now
+		case r = <-reports:
+			if r.dropped != 0 {
+				report += r.dropped
+				if !yield(KeyEvent{Time: r.time, Lagged: r.dropped}) {
+					return
+				}
+			}
+			if r.err != nil {
+				yield(KeyEvent{Err: r.err})
+				return
+			}
+			report++
+		}
+		ok := keys.update(r.states, r.time, func(c KeyChange, stuck bool) bool {
+			seq++
+			return yield(KeyEvent{
+				Time:    r.time,
+				Seq:     seq,
+				Report:  report,
+				Key:     c.Key,
+				Row:     c.Row,
+				Col:     c.Col,
+				Pressed: c.Pressed,
+				Stuck:   stuck,
+			})
+		})
+		if !ok {
+			return
+		}
+		if r.states != nil {
+			free <- r.states
+		}
+		if timer != nil {
+			timer.Stop()
+			timer, due = nil, nil
+		}
+		if t, ok := keys.next(); ok {
+			timer = time.NewTimer(time.Until(t))
+			due = timer.C
+		}
+	}
+}
+
+// KeyChange is a change in the state of a key.
+type KeyChange struct {
+	// Key, Row and Col identify the key.
+	Key, Row, Col int
+
+	// Pressed is whether the key was pressed
+	// or released.
+	Pressed bool
+}
+
+// Poll reads a key state report from the device and returns the current
+// key states and the changes from prev. If prev is nil, all keys are
+// assumed to have been released. Keys that are not covered by a short
+// report retain their state from prev. The returned states may be passed
+// as prev in the next call to Poll. Poll blocks until a report is received.
+func (d *Deck) Poll(prev []bool) (curr []bool, changes []KeyChange, err error) {
+	states, _, err := d.keyStates(nil)
+	if err != nil {
+		return prev, nil, err
+	}
+	curr = make([]bool, d.Len())
+	copy(curr, prev)
+	diffStates(curr, states, d.desc.cols, func(c KeyChange) bool {
+		changes = append(changes, c)
+		return true
+	})
+	return curr, changes, nil
+}
+
+// diffStates updates prev with the key states in curr, calling fn for each
+// key that has changed. Keys beyond the end of either slice are ignored.
+// diffStates returns false if fn returns false.
+func diffStates(prev, curr []bool, cols int, fn func(KeyChange) bool) bool {
+	for i, pressed := range curr {
+		if i >= len(prev) || pressed == prev[i] {
+			continue
+		}
+		prev[i] = pressed
+		if !fn(KeyChange{Key: i, Row: i / cols, Col: i % cols, Pressed: pressed}) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package ardilla
+
+import (
+	"context"
+	"iter"
+)
+
+// Events returns an iterator over key press and release events read from
+// the device. Key states are initially assumed to be released. The sequence
+// ends when ctx is cancelled or reading from the device fails, in which case
+// the last event holds the error. If the consumer does not keep up with the
+// device, reports are dropped and a Lagged event is included in the
+// sequence. Reports are read ahead of the consumer, and reports that have
+// been read but not delivered when the sequence ends are discarded. After
+// the sequence ends, at most one further report is read from the device.
+//
+// Events requires Go 1.23.
+func (d *Deck) Events(ctx context.Context) iter.Seq[KeyEvent] {
+	return func(yield func(KeyEvent) bool) {
+		d.keyEvents(ctx, yield)
+	}
+}
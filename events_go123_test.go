@@ -0,0 +1,273 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package ardilla
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeckEvents(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMini)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var reports []byte
+	for _, r := range [][]byte{
+		{0, 1, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 1, 0},
+		{0, 0, 0, 0, 0, 1, 0},
+		{0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 1, 0},
+	} {
+		reports = append(reports, r...)
+	}
+	d.setDev(&virtDev{Reader: bytes.NewReader(reports)})
+
+	type event struct {
+		seq, report   uint64
+		key, row, col int
+		pressed       bool
+	}
+	var got []event
+	var last error
+	for ev := range d.Events(context.Background()) {
+		if ev.Err != nil {
+			last = ev.Err
+			continue
+		}
+		if ev.Time.IsZero() {
+			t.Errorf("missing time for key %d", ev.Key)
+		}
+		got = append(got, event{ev.Seq, ev.Report, ev.Key, ev.Row, ev.Col, ev.Pressed})
+	}
+	want := []event{
+		{seq: 1, report: 1, key: 0, row: 0, col: 0, pressed: true},
+		{seq: 2, report: 2, key: 4, row: 1, col: 1, pressed: true},
+		{seq: 3, report: 3, key: 0, row: 0, col: 0, pressed: false},
+		{seq: 4, report: 4, key: 4, row: 1, col: 1, pressed: false},
+		{seq: 5, report: 6, key: 0, row: 0, col: 0, pressed: true},
+		{seq: 6, report: 6, key: 4, row: 1, col: 1, pressed: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected events:\ngot: %v\nwant:%v", got, want)
+	}
+	if last != ErrNotConnected {
+		t.Errorf("unexpected final error: got:%v want:%v", last, ErrNotConnected)
+	}
+}
+
+func TestDeckEventsBreak(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMini)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := &chanReader{c: make(chan []byte)}
+	d.setDev(&virtDev{Reader: r})
+	go func() { r.c <- []byte{0, 1, 1, 1, 1, 1, 1} }()
+	var n int
+	for range d.Events(context.Background()) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("unexpected number of events: %d", n)
+	}
+	// Unblock the pending read. The reader must
+	// then stop without reading again.
+	r.c <- []byte{0, 0, 0, 0, 0, 0, 0}
+	time.Sleep(10 * time.Millisecond)
+	if got := r.count(); got != 2 {
+		t.Errorf("unexpected number of reads after break: got:%d want:2", got)
+	}
+}
+
+func TestDeckEventsDebounce(t *testing.T) {
+	d, err := newTestDeck(StreamDeckPedal)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	const debounce = 20 * time.Millisecond
+	d.SetDebounce(debounce)
+	d.SetStuckTimeout(5 * debounce)
+	r := &chanReader{c: make(chan []byte)}
+	d.setDev(&virtDev{Reader: r})
+	go func() {
+		r.c <- []byte{0, 0, 0, 0, 1, 0, 0}
+		r.c <- []byte{0, 0, 0, 0, 0, 0, 0}
+		r.c <- []byte{0, 0, 0, 0, 1, 0, 0}
+		r.c <- []byte{0, 0, 0, 0, 0, 0, 0}
+	}()
+
+	type event struct {
+		key            int
+		pressed, stuck bool
+	}
+	var (
+		got   []event
+		start time.Time
+	)
+	for ev := range d.Events(context.Background()) {
+		if ev.Err != nil {
+			t.Fatalf("unexpected error: %v", ev.Err)
+		}
+		if start.IsZero() {
+			start = ev.Time
+		} else if ev.Time.Sub(start) < debounce {
+			t.Errorf("event within debounce interval: %v", ev.Time.Sub(start))
+		}
+		got = append(got, event{ev.Key, ev.Pressed, ev.Stuck})
+		if len(got) == 2 {
+			r.c <- []byte{0, 0, 0, 0, 0, 1, 0}
+		}
+		if ev.Stuck {
+			break
+		}
+	}
+	want := []event{
+		{key: 0, pressed: true},
+		{key: 0, pressed: false},
+		{key: 1, pressed: true},
+		{key: 1, pressed: true, stuck: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected events:\ngot: %v\nwant:%v", got, want)
+	}
+	go func() { r.c <- nil }()
+}
+
+// chanReader returns reports sent on c and counts reads.
+type chanReader struct {
+	c chan []byte
+
+	mu    sync.Mutex
+	reads int
+}
+
+func (r *chanReader) Read(b []byte) (int, error) {
+	n := copy(b, <-r.c)
+	r.mu.Lock()
+	r.reads++
+	r.mu.Unlock()
+	return n, nil
+}
+
+func (r *chanReader) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reads
+}
+
+func TestDeckEventsLagged(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMini)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	const n = 100
+	var reports []byte
+	for i := 0; i < n; i++ {
+		reports = append(reports, 0, byte((i+1)%2), 0, 0, 0, 0, 0)
+	}
+	exhausted := make(chan struct{})
+	d.setDev(&virtDev{Reader: &signalReader{r: bytes.NewReader(reports), eof: exhausted}})
+
+	var (
+		keyEvents int
+		lagged    uint64
+		lastSeen  uint64
+		last      error
+	)
+	for ev := range d.Events(context.Background()) {
+		switch {
+		case ev.Err != nil:
+			last = ev.Err
+		case ev.Lagged != 0:
+			lagged += ev.Lagged
+		default:
+			if keyEvents == 0 {
+				// Stall until all the reports have been read.
+				<-exhausted
+			}
+			keyEvents++
+			lastSeen = ev.Report
+		}
+	}
+	if lagged == 0 {
+		t.Error("expected lagged reports")
+	}
+	if uint64(keyEvents)+lagged != n {
+		t.Errorf("unexpected number of delivered and dropped reports: got:%d+%d want:%d", keyEvents, lagged, n)
+	}
+	if lastSeen != uint64(keyEvents) {
+		t.Errorf("unexpected last report number: got:%d want:%d", lastSeen, keyEvents)
+	}
+	if last != ErrNotConnected {
+		t.Errorf("unexpected final error: got:%v want:%v", last, ErrNotConnected)
+	}
+}
+
+func TestDeckEventsDeviceDropped(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMini)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.dev = &lossyDev{
+		virtDev: &virtDev{Reader: bytes.NewReader([]byte{0, 1, 0, 0, 0, 0, 0})},
+		dropped: 5,
+	}
+
+	var got []KeyEvent
+	for ev := range d.Events(context.Background()) {
+		if ev.Err != nil {
+			if ev.Err != ErrNotConnected {
+				t.Errorf("unexpected final error: got:%v want:%v", ev.Err, ErrNotConnected)
+			}
+			break
+		}
+		ev.Time = time.Time{}
+		got = append(got, ev)
+	}
+	want := []KeyEvent{
+		{Lagged: 5},
+		{Seq: 1, Report: 6, Key: 0, Pressed: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected events:\ngot: %+v\nwant:%+v", got, want)
+	}
+}
+
+// lossyDev is a virtDev that reports dropped input reports once.
+type lossyDev struct {
+	*virtDev
+	dropped uint64
+}
+
+func (d *lossyDev) Dropped() uint64 {
+	n := d.dropped
+	d.dropped = 0
+	return n
+}
+
+// signalReader closes eof when r is exhausted.
+type signalReader struct {
+	r   io.Reader
+	eof chan struct{}
+}
+
+func (r *signalReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if err == io.EOF {
+		close(r.eof)
+	}
+	return n, err
+}
@@ -0,0 +1,190 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fuzzPIDs is the sorted set of known devices, indexed by fuzz input.
+var fuzzPIDs = func() []PID {
+	pids := make([]PID, 0, len(devices))
+	for pid := range devices {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+	return pids
+}()
+
+func fuzzPID(i uint8) PID {
+	return fuzzPIDs[int(i)%len(fuzzPIDs)]
+}
+
+func FuzzKeyStates(f *testing.F) {
+	for i := range fuzzPIDs {
+		f.Add(uint8(i), []byte{})
+		f.Add(uint8(i), prependZero(4, []byte{0: 1, 2: 1}))
+		f.Add(uint8(i), bytes.Repeat([]byte{0xff}, 40))
+	}
+	f.Fuzz(func(t *testing.T, pid uint8, data []byte) {
+		d, err := newTestDeck(fuzzPID(pid))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d.setDev(&virtDev{Reader: bytes.NewReader(data)})
+
+		got, err := d.KeyStates()
+		if err != nil {
+			// An empty report is a read error.
+			return
+		}
+		if len(got) != d.Len() {
+			t.Fatalf("unexpected number of key states: got:%d want:%d", len(got), d.Len())
+		}
+		for i, pressed := range got {
+			var want bool
+			if j := d.desc.keyStatesOffset + i; j < len(data) {
+				want = data[j] != 0
+			}
+			if pressed != want {
+				t.Errorf("unexpected state for key %d: got:%t want:%t", i, pressed, want)
+			}
+		}
+	})
+}
+
+func FuzzSerial(f *testing.F) {
+	for i := range fuzzPIDs {
+		f.Add(uint8(i), "")
+		f.Add(uint8(i), padZero("\x06\x0c\x00\x00\x00AL12K1A01234", 32))
+		f.Add(uint8(i), strings.Repeat("A", 64))
+	}
+	f.Fuzz(func(t *testing.T, pid uint8, data string) {
+		d, err := newTestDeck(fuzzPID(pid))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d.setDev(&virtDev{Reader: strings.NewReader(data)})
+
+		got, _ := d.Serial()
+		checkReportString(t, "serial", got, data, d.desc.serialOffset)
+	})
+}
+
+func FuzzFirmware(f *testing.F) {
+	for i := range fuzzPIDs {
+		f.Add(uint8(i), "")
+		f.Add(uint8(i), padZero("\x05\x0c\x00\x00\x00\x001.00.004", 32))
+		f.Add(uint8(i), strings.Repeat("A", 64))
+	}
+	f.Fuzz(func(t *testing.T, pid uint8, data string) {
+		d, err := newTestDeck(fuzzPID(pid))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d.setDev(&virtDev{Reader: strings.NewReader(data)})
+
+		got, _ := d.Firmware()
+		checkReportString(t, "firmware", got, data, d.desc.firmwareOffset)
+	})
+}
+
+// checkReportString checks that a string extracted from a feature report
+// is a prefix of the report data starting at offset and contains no NUL.
+func checkReportString(t *testing.T, name, got, data string, offset int) {
+	t.Helper()
+	if strings.IndexByte(got, 0) >= 0 {
+		t.Errorf("%s contains NUL: %q", name, got)
+	}
+	if offset > len(data) {
+		data = ""
+	} else {
+		data = data[offset:]
+	}
+	// Data past the end of the report is not read.
+	if len(got) > len(data) && strings.Trim(got[len(data):], "\x00") == "" {
+		got = got[:len(data)]
+	}
+	if !strings.HasPrefix(data, got) {
+		t.Errorf("%s is not a prefix of the report data: got:%q data:%q", name, got, data)
+	}
+}
+
+func FuzzSetImagePackets(f *testing.F) {
+	for i := range fuzzPIDs {
+		f.Add(uint8(i), 0, 0, 0)
+		f.Add(uint8(i), 1, 2, 1)
+		f.Add(uint8(i), 0, 1, 1008)
+		f.Add(uint8(i), 1, 0, 1016)
+		f.Add(uint8(i), 0, 0, 8000)
+	}
+	f.Fuzz(func(t *testing.T, pid uint8, row, col, n int) {
+		if n < 0 || 1<<20 < n {
+			return
+		}
+		d, err := newTestDeck(fuzzPID(pid))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !d.desc.visual {
+			return
+		}
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(i*7 + 1)
+		}
+		img := &RawImage{rawImage{data: data, pid: d.desc.PID}}
+
+		var pkts packetCapture
+		d.setDev(&virtDev{Writer: &pkts})
+		err = d.SetImage(row, col, img)
+		rows, cols := d.Layout()
+		inBounds := 0 <= row && row < rows && 0 <= col && col < cols
+		if inBounds != (err == nil) {
+			t.Fatalf("unexpected error for key %d,%d on %dx%d device: %v", row, col, rows, cols, err)
+		}
+		if err != nil {
+			if len(pkts) != 0 {
+				t.Errorf("unexpected writes after error: %d", len(pkts))
+			}
+			return
+		}
+
+		hdrLen := len(d.desc.imageHeader)
+		key := d.Key(row, col)
+		var payload []byte
+		for i, p := range pkts {
+			if len(p) != d.desc.imgReportLen {
+				t.Fatalf("unexpected packet length for page %d: got:%d want:%d", i, len(p), d.desc.imgReportLen)
+			}
+			last := i == len(pkts)-1
+			want := make([]byte, hdrLen)
+			copy(want, d.desc.imageHeader)
+			size := d.desc.imgReportLen - hdrLen
+			if last {
+				size = n - i*(d.desc.imgReportLen-hdrLen)
+			}
+			d.desc.fillHeader(want, key, i, size, last)
+			if !bytes.Equal(p[:hdrLen], want) {
+				t.Errorf("unexpected header for page %d:\ngot: %#v\nwant:%#v", i, p[:hdrLen], want)
+			}
+			payload = append(payload, p[hdrLen:hdrLen+size]...)
+		}
+		if !bytes.Equal(payload, data) {
+			t.Errorf("payload mismatch for %d bytes in %d packets", n, len(pkts))
+		}
+	})
+}
+
+// packetCapture records written packets.
+type packetCapture [][]byte
+
+func (w *packetCapture) Write(b []byte) (int, error) {
+	*w = append(*w, append(b[:0:0], b...))
+	return len(b), nil
+}
@@ -0,0 +1,82 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"fmt"
+	"math"
+)
+
+// Geometry is the physical layout of a device's keys. All lengths are in
+// millimetres. The values are approximate, derived from published device
+// dimensions, and are intended for on-screen simulation and canvas slicing
+// rather than mechanical design.
+type Geometry struct {
+	// Rows and Cols are the number of key rows and columns.
+	Rows, Cols int
+
+	// KeySize is the width and height of the visible
+	// area of a key.
+	KeySize float64
+
+	// Pitch is the centre to centre distance between
+	// adjacent keys.
+	Pitch float64
+
+	// Width and Height are the dimensions of the
+	// device face. The key grid is centred on the face.
+	Width, Height float64
+}
+
+// Gap returns the distance between the visible areas of adjacent keys.
+func (g Geometry) Gap() float64 {
+	return g.Pitch - g.KeySize
+}
+
+// Key returns the position of the top-left corner of the visible area of
+// the key at row and col, relative to the top-left corner of the device
+// face.
+func (g Geometry) Key(row, col int) (x, y float64) {
+	x0 := (g.Width - float64(g.Cols-1)*g.Pitch - g.KeySize) / 2
+	y0 := (g.Height - float64(g.Rows-1)*g.Pitch - g.KeySize) / 2
+	return x0 + float64(col)*g.Pitch, y0 + float64(row)*g.Pitch
+}
+
+var (
+	miniGeometry = &Geometry{
+		Rows: 2, Cols: 3,
+		KeySize: 14.5, Pitch: 19,
+		Width: 84, Height: 60,
+	}
+	originalGeometry = &Geometry{
+		Rows: 3, Cols: 5,
+		KeySize: 14.5, Pitch: 19,
+		Width: 118, Height: 84,
+	}
+	xlGeometry = &Geometry{
+		Rows: 4, Cols: 8,
+		KeySize: 15, Pitch: 19.5,
+		Width: 182, Height: 114,
+	}
+)
+
+// Geometry returns the physical layout of the device's keys. If the
+// geometry of the device is not known an error is returned.
+func (d *Deck) Geometry() (Geometry, error) {
+	if d.desc.geometry == nil {
+		return Geometry{}, fmt.Errorf("geometry not known for %s", d.desc)
+	}
+	return *d.desc.geometry, nil
+}
+
+// CanvasGap returns the number of pixels hidden between adjacent keys,
+// based on the device geometry, for use with SetCanvas and CanvasBounds.
+func (d *Deck) CanvasGap() (int, error) {
+	g, err := d.Geometry()
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(g.Gap() / g.KeySize * float64(d.desc.keySize.X))), nil
+}
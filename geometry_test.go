@@ -0,0 +1,69 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDeckGeometry(t *testing.T) {
+	for pid, desc := range devices {
+		t.Run(fmt.Sprint(pid), func(t *testing.T) {
+			d, err := newTestDeck(pid)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			g, err := d.Geometry()
+			if !desc.visual {
+				want := fmt.Errorf("geometry not known for %s", pid)
+				if !sameError(err, want) {
+					t.Errorf("unexpected error for device without geometry: got:%v want:%v", err, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error for Geometry: %v", err)
+			}
+			if g.Rows != desc.rows || g.Cols != desc.cols {
+				t.Errorf("geometry grid does not match device: got:%dx%d want:%dx%d", g.Rows, g.Cols, desc.rows, desc.cols)
+			}
+			x, y := g.Key(0, 0)
+			if x <= 0 || y <= 0 {
+				t.Errorf("first key outside device face: %v,%v", x, y)
+			}
+			x, y = g.Key(g.Rows-1, g.Cols-1)
+			if x+g.KeySize >= g.Width || y+g.KeySize >= g.Height {
+				t.Errorf("last key outside device face: %v,%v", x+g.KeySize, y+g.KeySize)
+			}
+			gap, err := d.CanvasGap()
+			if err != nil {
+				t.Errorf("unexpected error for CanvasGap: %v", err)
+			}
+			if gap <= 0 || gap >= desc.keySize.X {
+				t.Errorf("unexpected canvas gap: %d", gap)
+			}
+		})
+	}
+}
+
+func TestGeometryKey(t *testing.T) {
+	g := Geometry{Rows: 2, Cols: 3, KeySize: 10, Pitch: 15, Width: 60, Height: 45}
+	for _, test := range []struct {
+		row, col int
+		x, y     float64
+	}{
+		{row: 0, col: 0, x: 10, y: 10},
+		{row: 1, col: 2, x: 40, y: 25},
+	} {
+		x, y := g.Key(test.row, test.col)
+		if x != test.x || y != test.y {
+			t.Errorf("unexpected position for %d,%d: got:%v,%v want:%v,%v", test.row, test.col, x, y, test.x, test.y)
+		}
+	}
+	if got := g.Gap(); got != 5 {
+		t.Errorf("unexpected gap: got:%v want:5", got)
+	}
+}
@@ -5,3 +5,5 @@ go 1.20
 require golang.org/x/image v0.3.0
 
 require github.com/sstallion/go-hid v0.13.2
+
+require golang.org/x/text v0.6.0 // indirect
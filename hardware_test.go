@@ -0,0 +1,217 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla/label"
+)
+
+// The hardware tests run against a real attached Stream Deck. They are
+// only run when the -hardware flag is given, for example
+//
+//	go test -run Hardware -hardware=any
+//	go test -run Hardware -hardware=AL12K1A01234 -hardware.interactive
+//
+// The interactive tests prompt on stderr for keys to be pressed.
+var (
+	hardware    = flag.String("hardware", "", `serial number of an attached deck to test against, or "any"`)
+	interactive = flag.Bool("hardware.interactive", false, "prompt for key presses in hardware tests")
+)
+
+// hardwareDeck returns the Deck selected by the -hardware flag, skipping
+// the test if no deck is selected. The deck is reset and closed when the
+// test completes.
+func hardwareDeck(t *testing.T) *Deck {
+	t.Helper()
+	if *hardware == "" {
+		t.Skip("no -hardware deck selected")
+	}
+	serial := *hardware
+	if serial == "any" {
+		serial = ""
+	}
+	d, err := NewDeck(AnyPID, serial)
+	if err != nil {
+		t.Fatalf("failed to open hardware deck: %v", err)
+	}
+	t.Cleanup(func() {
+		d.Reset()
+		d.Close()
+	})
+	return d
+}
+
+func TestHardwareInfo(t *testing.T) {
+	d := hardwareDeck(t)
+	t.Logf("testing %s", d.PID())
+
+	serial, err := d.Serial()
+	if err != nil {
+		t.Errorf("unexpected error for Serial: %v", err)
+	}
+	read, err := d.ReadSerial()
+	if err != nil {
+		t.Errorf("unexpected error for ReadSerial: %v", err)
+	}
+	if serial == "" || serial != read {
+		t.Errorf("inconsistent serial numbers: cached:%q read:%q", serial, read)
+	}
+	if *hardware != "any" && serial != *hardware {
+		t.Errorf("unexpected serial: got:%q want:%q", serial, *hardware)
+	}
+	firmware, err := d.Firmware()
+	if err != nil {
+		t.Errorf("unexpected error for Firmware: %v", err)
+	}
+	if firmware == "" {
+		t.Error("empty firmware version")
+	}
+	t.Logf("serial %s firmware %s quirks %v", serial, firmware, d.Quirks())
+
+	var found bool
+	for _, info := range Devices() {
+		if info.PID != d.PID() {
+			continue
+		}
+		found = true
+		rows, cols := d.Layout()
+		if info.Rows != rows || info.Cols != cols {
+			t.Errorf("layout does not match device table: got:%dx%d want:%dx%d", rows, cols, info.Rows, info.Cols)
+		}
+	}
+	if !found {
+		t.Errorf("%s not found in device table", d.PID())
+	}
+}
+
+func TestHardwarePatterns(t *testing.T) {
+	d := hardwareDeck(t)
+	if !d.desc.visual {
+		t.Skipf("%s does not display images", d.PID())
+	}
+	b, err := d.Bounds()
+	if err != nil {
+		t.Fatalf("unexpected error for Bounds: %v", err)
+	}
+	rows, cols := d.Layout()
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			// Label each key with its position so that
+			// orientation errors are visible.
+			img, err := label.Render(b, fmt.Sprintf("%d,%d", row, col), 24, color.White, color.Black)
+			if err != nil {
+				t.Fatalf("unexpected error rendering label: %v", err)
+			}
+			err = d.SetImage(row, col, img)
+			if err != nil {
+				t.Fatalf("unexpected error for SetImage(%d, %d): %v", row, col, err)
+			}
+		}
+	}
+	for _, percent := range []int{10, 50, 100} {
+		err = d.SetBrightness(percent)
+		if err != nil {
+			t.Errorf("unexpected error for SetBrightness(%d): %v", percent, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	cb, err := d.CanvasBounds(0)
+	if err != nil {
+		t.Fatalf("unexpected error for CanvasBounds: %v", err)
+	}
+	canvas := image.NewRGBA(cb)
+	for y := cb.Min.Y; y < cb.Max.Y; y++ {
+		for x := cb.Min.X; x < cb.Max.X; x++ {
+			canvas.Set(x, y, color.RGBA{R: uint8(255 * x / cb.Dx()), G: uint8(255 * y / cb.Dy()), B: 0x80, A: 0xff})
+		}
+	}
+	err = d.SetCanvas(canvas, 0)
+	if err != nil {
+		t.Errorf("unexpected error for SetCanvas: %v", err)
+	}
+	time.Sleep(time.Second)
+}
+
+func TestHardwareKeys(t *testing.T) {
+	d := hardwareDeck(t)
+	if !*interactive {
+		t.Skip("interactive hardware tests not requested")
+	}
+	const timeout = 10 * time.Second
+	rows, cols := d.Layout()
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			fmt.Fprintf(os.Stderr, "press and release key at row %d column %d\n", row, col)
+			want := d.Key(row, col)
+			pressed, released := waitKey(t, d, want, timeout)
+			if !pressed || !released {
+				t.Errorf("key %d not pressed and released within %v", want, timeout)
+			}
+		}
+	}
+}
+
+// waitKey waits for the key with the given key number to be pressed and
+// released, reporting any other key changes as errors.
+func waitKey(t *testing.T, d *Deck, key int, timeout time.Duration) (pressed, released bool) {
+	t.Helper()
+	type poll struct {
+		changes []KeyChange
+		err     error
+	}
+	c := make(chan poll)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		var states []bool
+		for {
+			var (
+				p   poll
+				err error
+			)
+			states, p.changes, err = d.Poll(states)
+			p.err = err
+			select {
+			case c <- p:
+			case <-stop:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	deadline := time.After(timeout)
+	for !released {
+		select {
+		case <-deadline:
+			return pressed, released
+		case p := <-c:
+			if p.err != nil {
+				t.Fatalf("unexpected error for Poll: %v", p.err)
+			}
+			for _, ch := range p.changes {
+				switch {
+				case ch.Key != key:
+					t.Errorf("unexpected key change: %+v", ch)
+				case ch.Pressed:
+					pressed = true
+				case pressed:
+					released = true
+				}
+			}
+		}
+	}
+	return pressed, released
+}
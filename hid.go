@@ -0,0 +1,67 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import "io"
+
+// HIDDevice is a HID device transport for a Deck. It is implemented by
+// *hid.Device from github.com/sstallion/go-hid.
+type HIDDevice interface {
+	io.Reader
+	io.Writer
+	io.Closer
+	GetFeatureReport([]byte) (int, error)
+	SendFeatureReport([]byte) (int, error)
+}
+
+// AnyPID is a wildcard product ID. When passed to NewDeck, AnyPID selects
+// any connected Stream Deck, preferring decks with image displays.
+const AnyPID PID = 0
+
+// deviceInfo is the description of an enumerated device.
+type deviceInfo struct {
+	pid    PID
+	serial string
+	path   string
+
+	// usagePage is the HID usage page of the
+	// interface, or zero if it is not known.
+	usagePage uint16
+}
+
+// deckUsagePage is the HID usage page of the Stream Deck interface that
+// accepts key state, image and feature reports. Some platforms, notably
+// macOS, enumerate a separate entry for each top-level collection of a
+// device, and only the entry with this usage page accepts image writes.
+const deckUsagePage = 0x000c
+
+// primaryInterfaces returns infos with only one entry for each device,
+// keeping the first entry with the Stream Deck usage page, or the first
+// entry if the device does not report its usage pages. Entries without
+// a serial number cannot be grouped and are all retained.
+func primaryInterfaces(infos []deviceInfo) []deviceInfo {
+	type id struct {
+		pid    PID
+		serial string
+	}
+	chosen := make(map[id]int)
+	var primary []deviceInfo
+	for _, info := range infos {
+		if info.serial == "" {
+			primary = append(primary, info)
+			continue
+		}
+		key := id{info.pid, info.serial}
+		i, ok := chosen[key]
+		switch {
+		case !ok:
+			chosen[key] = len(primary)
+			primary = append(primary, info)
+		case primary[i].usagePage != deckUsagePage && info.usagePage == deckUsagePage:
+			primary[i] = info
+		}
+	}
+	return primary
+}
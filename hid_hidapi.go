@@ -0,0 +1,83 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build cgo && !hidraw && !nohid
+
+package ardilla
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sstallion/go-hid"
+)
+
+// enumerate calls fn for each connected device with the given vendor and
+// product ID, or for all devices from the vendor if pid is AnyPID.
+// Enumeration stops if fn returns a non-nil error.
+func enumerate(vid uint16, pid PID, fn func(deviceInfo) error) error {
+	return hid.Enumerate(vid, uint16(pid), func(info *hid.DeviceInfo) error {
+		return fn(deviceInfo{
+			pid:    PID(info.ProductID),
+			serial: info.SerialNbr,
+			path:   info.Path,
+
+			usagePage: info.UsagePage,
+		})
+	})
+}
+
+// open opens the device with the given vendor and product ID and serial
+// number. If serial is empty, the first device with a matching product
+// ID is opened. When the device enumerates more than one interface, the
+// interface accepting Stream Deck reports is opened.
+func open(vid uint16, pid PID, serial string) (HIDDevice, error) {
+	var infos []deviceInfo
+	err := enumerate(vid, pid, func(info deviceInfo) error {
+		if serial == "" || serial == info.serial {
+			infos = append(infos, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var dev *hid.Device
+	if infos = primaryInterfaces(infos); len(infos) != 0 {
+		dev, err = hid.OpenPath(infos[0].path)
+	} else if serial != "" {
+		dev, err = hid.Open(vid, uint16(pid), serial)
+	} else {
+		dev, err = hid.OpenFirst(vid, uint16(pid))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hidapiDevice{dev}, nil
+}
+
+// openPath opens the device at the platform-specific HID device path.
+func openPath(path string) (HIDDevice, error) {
+	dev, err := hid.OpenPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return hidapiDevice{dev}, nil
+}
+
+// hidapiDevice is a HIDDevice backed by hidapi.
+type hidapiDevice struct {
+	*hid.Device
+}
+
+// ReadWithTimeout reads an input report, waiting at most timeout for one
+// to arrive. If no report arrives, ReadWithTimeout returns zero bytes and
+// a nil error, rather than hidapi's timeout error.
+func (d hidapiDevice) ReadWithTimeout(b []byte, timeout time.Duration) (int, error) {
+	n, err := d.Device.ReadWithTimeout(b, timeout)
+	if errors.Is(err, hid.ErrTimeout) {
+		return 0, nil
+	}
+	return n, err
+}
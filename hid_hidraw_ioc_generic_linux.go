@@ -0,0 +1,15 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build (!cgo || hidraw) && !nohid && !mips && !mipsle && !mips64 && !mips64le && !ppc64 && !ppc64le && !sparc64
+
+package ardilla
+
+// Linux ioctl directions from include/uapi/asm-generic/ioctl.h.
+const (
+	iocWrite = 1
+	iocRead  = 2
+
+	iocDirShift = 30
+)
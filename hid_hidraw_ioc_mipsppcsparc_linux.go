@@ -0,0 +1,16 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build (!cgo || hidraw) && !nohid && (mips || mipsle || mips64 || mips64le || ppc64 || ppc64le || sparc64)
+
+package ardilla
+
+// Linux ioctl directions from arch/{mips,powerpc,sparc}/include/uapi/asm/ioctl.h,
+// which use a three bit direction field and a 13 bit size field.
+const (
+	iocRead  = 2
+	iocWrite = 4
+
+	iocDirShift = 29
+)
@@ -0,0 +1,201 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build (!cgo || hidraw) && !nohid
+
+package ardilla
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The hidraw backend talks directly to the Linux hidraw device nodes
+// without using hidapi. It is used when cgo is not available or when
+// the hidraw build tag is set.
+
+// enumerate calls fn for each connected device with the given vendor and
+// product ID, or for all devices from the vendor if pid is AnyPID.
+// Enumeration stops if fn returns a non-nil error.
+func enumerate(vid uint16, pid PID, fn func(deviceInfo) error) error {
+	nodes, err := filepath.Glob(filepath.Join(sysfsHIDRaw, "hidraw*"))
+	if err != nil {
+		return err
+	}
+	// Order devices by number for consistency with hidapi.
+	sort.Slice(nodes, func(i, j int) bool {
+		return hidrawNumber(nodes[i]) < hidrawNumber(nodes[j])
+	})
+	for _, n := range nodes {
+		info, ok := readUevent(filepath.Join(n, "device", "uevent"), vid)
+		if !ok || (pid != AnyPID && info.pid != pid) {
+			continue
+		}
+		info.path = filepath.Join("/dev", filepath.Base(n))
+		err = fn(info)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hidrawNumber returns the device number of the hidraw node at path.
+func hidrawNumber(path string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(path), "hidraw"))
+	return n
+}
+
+// readUevent returns the device information in the HID uevent file at path
+// and whether the file describes a device from the given vendor.
+func readUevent(path string, vendor uint16) (deviceInfo, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return deviceInfo{}, false
+	}
+	defer f.Close()
+	var (
+		info  deviceInfo
+		match bool
+	)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, _ := strings.Cut(sc.Text(), "=")
+		switch k {
+		case "HID_ID":
+			// HID_ID=bus:vendor:product, for example
+			// HID_ID=0003:00000FD9:00000060.
+			parts := strings.Split(v, ":")
+			if len(parts) != 3 {
+				return deviceInfo{}, false
+			}
+			vid, err := strconv.ParseUint(parts[1], 16, 32)
+			if err != nil {
+				return deviceInfo{}, false
+			}
+			pid, err := strconv.ParseUint(parts[2], 16, 16)
+			if err != nil {
+				return deviceInfo{}, false
+			}
+			match = vid == uint64(vendor)
+			info.pid = PID(pid)
+		case "HID_UNIQ":
+			info.serial = v
+		}
+	}
+	return info, match
+}
+
+// open opens the device with the given vendor and product ID and serial
+// number. If serial is empty, the first device with a matching product
+// ID is opened.
+func open(vid uint16, pid PID, serial string) (HIDDevice, error) {
+	var path string
+	enumerate(vid, pid, func(info deviceInfo) error {
+		if serial == "" || serial == info.serial {
+			path = info.path
+			return errFound
+		}
+		return nil
+	})
+	if path == "" {
+		if serial != "" {
+			return nil, fmt.Errorf("%s with serial %q not found", pid, serial)
+		}
+		return nil, fmt.Errorf("%s not found", pid)
+	}
+	return openPath(path)
+}
+
+// openPath opens the hidraw device node at path.
+func openPath(path string) (HIDDevice, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return hidraw{f}, nil
+}
+
+// errFound is used to terminate enumeration.
+var errFound = errors.New("found")
+
+// hidraw is a HIDDevice backed by a Linux hidraw device node.
+type hidraw struct {
+	*os.File
+}
+
+// ReadWithTimeout reads an input report, waiting at most timeout for one
+// to arrive. If no report arrives, ReadWithTimeout returns zero bytes and
+// a nil error.
+func (d hidraw) ReadWithTimeout(b []byte, timeout time.Duration) (int, error) {
+	err := d.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return 0, err
+	}
+	defer d.SetReadDeadline(time.Time{})
+	n, err := d.Read(b)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (d hidraw) GetFeatureReport(b []byte) (int, error) {
+	return d.ioctl(hidiocgfeature(len(b)), b)
+}
+
+func (d hidraw) SendFeatureReport(b []byte) (int, error) {
+	return d.ioctl(hidiocsfeature(len(b)), b)
+}
+
+func (d hidraw) ioctl(req uintptr, b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, errors.New("empty feature report")
+	}
+	c, err := d.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		n     uintptr
+		errno syscall.Errno
+	)
+	err = c.Control(func(fd uintptr) {
+		n, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(&b[0])))
+	})
+	if err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// Linux ioctl request encoding. The direction values and shift are
+// architecture-specific and are defined in hid_hidraw_ioc_*_linux.go.
+const (
+	iocNRShift   = 0
+	iocTypeShift = 8
+	iocSizeShift = 16
+)
+
+func ioc(dir, typ, nr, size uintptr) uintptr {
+	return dir<<iocDirShift | typ<<iocTypeShift | nr<<iocNRShift | size<<iocSizeShift
+}
+
+// hidiocsfeature and hidiocgfeature return the HIDIOCSFEATURE and
+// HIDIOCGFEATURE requests for a report of length n, from
+// include/uapi/linux/hidraw.h.
+func hidiocsfeature(n int) uintptr { return ioc(iocWrite|iocRead, 'H', 0x06, uintptr(n)) }
+func hidiocgfeature(n int) uintptr { return ioc(iocWrite|iocRead, 'H', 0x07, uintptr(n)) }
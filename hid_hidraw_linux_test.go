@@ -0,0 +1,94 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build (!cgo || hidraw) && !nohid
+
+package ardilla
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestHIDRawEnumerate(t *testing.T) {
+	dir := t.TempDir()
+	for name, uevent := range map[string]string{
+		"hidraw0":  "DRIVER=hid-generic\nHID_ID=0003:0000046D:0000C52B\nHID_NAME=Logitech USB Receiver\nHID_UNIQ=\n",
+		"hidraw2":  "DRIVER=hid-generic\nHID_ID=0003:00000FD9:00000080\nHID_NAME=Elgato Stream Deck MK.2\nHID_UNIQ=DL1234567\n",
+		"hidraw10": "DRIVER=hid-generic\nHID_ID=0003:00000FD9:00000063\nHID_NAME=Elgato Stream Deck Mini\nHID_UNIQ=BL7654321\n",
+		"hidraw3":  "DRIVER=hid-generic\nHID_ID=0003:00000FD9:00000080\nHID_NAME=Elgato Stream Deck MK.2\nHID_UNIQ=DL7654321\n",
+	} {
+		path := filepath.Join(dir, name, "device")
+		err := os.MkdirAll(path, 0o755)
+		if err != nil {
+			t.Fatalf("unexpected error making device directory: %v", err)
+		}
+		err = os.WriteFile(filepath.Join(path, "uevent"), []byte(uevent), 0o644)
+		if err != nil {
+			t.Fatalf("unexpected error writing uevent: %v", err)
+		}
+	}
+	defer func(dir string) { sysfsHIDRaw = dir }(sysfsHIDRaw)
+	sysfsHIDRaw = dir
+
+	for _, test := range []struct {
+		pid  PID
+		want []deviceInfo
+	}{
+		{
+			pid: AnyPID,
+			want: []deviceInfo{
+				{pid: StreamDeckMK2, serial: "DL1234567", path: "/dev/hidraw2"},
+				{pid: StreamDeckMK2, serial: "DL7654321", path: "/dev/hidraw3"},
+				{pid: StreamDeckMini, serial: "BL7654321", path: "/dev/hidraw10"},
+			},
+		},
+		{
+			pid: StreamDeckMK2,
+			want: []deviceInfo{
+				{pid: StreamDeckMK2, serial: "DL1234567", path: "/dev/hidraw2"},
+				{pid: StreamDeckMK2, serial: "DL7654321", path: "/dev/hidraw3"},
+			},
+		},
+		{
+			pid: StreamDeckXL,
+		},
+	} {
+		var got []deviceInfo
+		err := enumerate(vidElGato, test.pid, func(info deviceInfo) error {
+			got = append(got, info)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("unexpected error enumerating %s: %v", test.pid, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("unexpected result enumerating %s:\ngot: %+v\nwant:%+v", test.pid, got, test.want)
+		}
+	}
+}
+
+func TestHIDRawIoctl(t *testing.T) {
+	// Values from the C macros for a 32 byte report.
+	if got, want := hidiocsfeature(32), uintptr(0xc0204806); got != want {
+		t.Errorf("unexpected HIDIOCSFEATURE(32): got:%#x want:%#x", got, want)
+	}
+	if got, want := hidiocgfeature(32), uintptr(0xc0204807); got != want {
+		t.Errorf("unexpected HIDIOCGFEATURE(32): got:%#x want:%#x", got, want)
+	}
+
+	// Read-only requests differ between the generic and
+	// the mips, powerpc and sparc encodings.
+	want := uintptr(0x80044801)
+	switch runtime.GOARCH {
+	case "mips", "mipsle", "mips64", "mips64le", "ppc64", "ppc64le", "sparc64":
+		want = 0x40044801
+	}
+	if got := ioc(iocRead, 'H', 0x01, 4); got != want {
+		t.Errorf("unexpected HIDIOCGRDESCSIZE: got:%#x want:%#x", got, want)
+	}
+}
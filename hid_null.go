@@ -0,0 +1,27 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build ((!cgo || hidraw) && !linux && !(js && wasm)) || nohid
+
+package ardilla
+
+// The null backend finds no devices. It is used when no other backend is
+// available for the platform, including when the hidraw build tag is set
+// on a platform other than Linux, or when the nohid build tag is set, so
+// that code using ardilla can be built and tested without hidapi.
+
+// enumerate finds no devices.
+func enumerate(vid uint16, pid PID, fn func(deviceInfo) error) error {
+	return nil
+}
+
+// open returns ErrNotConnected.
+func open(vid uint16, pid PID, serial string) (HIDDevice, error) {
+	return nil, ErrNotConnected
+}
+
+// openPath returns ErrNotConnected.
+func openPath(path string) (HIDDevice, error) {
+	return nil, ErrNotConnected
+}
@@ -0,0 +1,23 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build ((!cgo || hidraw) && !linux && !(js && wasm)) || nohid
+
+package ardilla
+
+import "testing"
+
+func TestNullBackend(t *testing.T) {
+	serials, err := Serials(StreamDeckMK2)
+	if err != nil {
+		t.Errorf("unexpected error for Serials: %v", err)
+	}
+	if len(serials) != 0 {
+		t.Errorf("unexpected serials: %q", serials)
+	}
+	_, err = NewDeck(StreamDeckMK2, "")
+	if err != ErrNotConnected {
+		t.Errorf("unexpected error for NewDeck: got:%v want:%v", err, ErrNotConnected)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewDeckHID(t *testing.T) {
+	dev := &virtDev{
+		Reader: strings.NewReader(padZero("\x06\x0cAL12K1A01234", 32)),
+		Writer: io.Discard,
+	}
+	d, err := NewDeckHID(StreamDeckMK2, dev)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	serial, err := d.Serial()
+	if err != nil {
+		t.Errorf("unexpected error for Serial: %v", err)
+	}
+	if want := "AL12K1A01234"; serial != want {
+		t.Errorf("unexpected serial: got:%q want:%q", serial, want)
+	}
+	wantActions := []string{
+		"SendFeatureReport([]byte{0x2, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}) -> (32, <nil>)",
+		"GetFeatureReport([]byte{0x6, 0x20, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}) -> (32, <nil>)",
+	}
+	if len(dev.actions) != len(wantActions) {
+		t.Fatalf("unexpected number of actions: got:%d want:%d\n%q", len(dev.actions), len(wantActions), dev.actions)
+	}
+	for i, got := range dev.actions {
+		if got != wantActions[i] {
+			t.Errorf("unexpected action %d:\ngot: %s\nwant:%s", i, got, wantActions[i])
+		}
+	}
+
+	// Errors from user-provided devices are not converted.
+	errFailed := errors.New("failed")
+	d.setDev(&virtDev{Writer: failWriter{errFailed}})
+	err = d.SetBrightness(50)
+	if err != errFailed {
+		t.Errorf("unexpected error for SetBrightness: got:%v want:%v", err, errFailed)
+	}
+
+	err = d.Reconnect(context.Background(), time.Millisecond)
+	if err == nil {
+		t.Error("expected error reconnecting user-provided device")
+	}
+}
+
+func TestNewDeckHIDNoReset(t *testing.T) {
+	dev := &virtDev{
+		Reader: strings.NewReader(padZero("\x06\x0cAL12K1A01234", 32)),
+		Writer: io.Discard,
+	}
+	_, err := NewDeckHID(StreamDeckMK2, dev, WithResetKeyStream(false))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, a := range dev.actions {
+		if !strings.HasPrefix(a, "GetFeatureReport(") {
+			t.Errorf("unexpected device mutation: %s", a)
+		}
+	}
+}
+
+type failWriter struct{ err error }
+
+func (w failWriter) Write([]byte) (int, error) { return 0, w.err }
+
+func TestDeckReconnectSerial(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.serial = "AL12K1A01234"
+	d.setDev(&virtDev{Writer: io.Discard, Closer: io.NopCloser(nil)})
+
+	err = d.reconnect(&virtDev{
+		Reader: strings.NewReader(padZero("\x06\x0cAL12K1A01234", 32)),
+		Writer: io.Discard,
+		Closer: io.NopCloser(nil),
+	})
+	if err != nil {
+		t.Errorf("unexpected error reconnecting same device: %v", err)
+	}
+
+	err = d.reconnect(&virtDev{
+		Reader: strings.NewReader(padZero("\x06\x0cAL12K1A99999", 32)),
+		Writer: io.Discard,
+		Closer: io.NopCloser(nil),
+	})
+	if !errors.Is(err, ErrSerialMismatch) {
+		t.Errorf("unexpected error reconnecting different device: got:%v want:%v", err, ErrSerialMismatch)
+	}
+	serial, err := d.Serial()
+	if err != nil {
+		t.Errorf("unexpected error for Serial: %v", err)
+	}
+	if want := "AL12K1A01234"; serial != want {
+		t.Errorf("unexpected cached serial: got:%q want:%q", serial, want)
+	}
+
+	d.setDev(&virtDev{Reader: strings.NewReader(padZero("\x06\x0cAL12K1A99999", 32))})
+	serial, err = d.ReadSerial()
+	if err != nil {
+		t.Errorf("unexpected error for ReadSerial: %v", err)
+	}
+	if want := "AL12K1A99999"; serial != want {
+		t.Errorf("unexpected read serial: got:%q want:%q", serial, want)
+	}
+}
+
+func TestPrimaryInterfaces(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		infos []deviceInfo
+		want  []deviceInfo
+	}{
+		{
+			name: "single",
+			infos: []deviceInfo{
+				{pid: StreamDeckOriginal, serial: "a", path: "0"},
+				{pid: StreamDeckXL, serial: "b", path: "1"},
+			},
+			want: []deviceInfo{
+				{pid: StreamDeckOriginal, serial: "a", path: "0"},
+				{pid: StreamDeckXL, serial: "b", path: "1"},
+			},
+		},
+		{
+			name: "multiple_collections",
+			infos: []deviceInfo{
+				{pid: StreamDeckOriginal, serial: "a", path: "0", usagePage: 0x0001},
+				{pid: StreamDeckXL, serial: "b", path: "1", usagePage: deckUsagePage},
+				{pid: StreamDeckOriginal, serial: "a", path: "2", usagePage: deckUsagePage},
+				{pid: StreamDeckOriginal, serial: "a", path: "3", usagePage: deckUsagePage},
+			},
+			want: []deviceInfo{
+				{pid: StreamDeckOriginal, serial: "a", path: "2", usagePage: deckUsagePage},
+				{pid: StreamDeckXL, serial: "b", path: "1", usagePage: deckUsagePage},
+			},
+		},
+		{
+			name: "unknown_usage",
+			infos: []deviceInfo{
+				{pid: StreamDeckOriginal, serial: "a", path: "0"},
+				{pid: StreamDeckOriginal, serial: "a", path: "1"},
+			},
+			want: []deviceInfo{
+				{pid: StreamDeckOriginal, serial: "a", path: "0"},
+			},
+		},
+		{
+			name: "no_serial",
+			infos: []deviceInfo{
+				{pid: StreamDeckOriginal, path: "0"},
+				{pid: StreamDeckOriginal, path: "1"},
+			},
+			want: []deviceInfo{
+				{pid: StreamDeckOriginal, path: "0"},
+				{pid: StreamDeckOriginal, path: "1"},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := primaryInterfaces(test.infos)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected result:\ngot: %+v\nwant:%+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build js && wasm && !nohid
+
+package ardilla
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"syscall/js"
+)
+
+// The WebHID backend uses the browser WebHID API. Only devices that the
+// user has previously granted access to are available; access is granted
+// by calling RequestWebHIDAccess.
+//
+// WebHID calls are asynchronous, so Deck methods must not be called from
+// a JavaScript event handler. They should be called from a goroutine.
+
+// RequestWebHIDAccess prompts the user to grant the page access to El Gato
+// devices. The browser requires that it is called in response to a user
+// gesture such as a click; the call must be made from a goroutine started
+// by the event handler. It returns the number of devices selected by the
+// user.
+func RequestWebHIDAccess() (int, error) {
+	hid, err := navigatorHID()
+	if err != nil {
+		return 0, err
+	}
+	filter := js.ValueOf(map[string]any{"vendorId": vidElGato})
+	opts := js.ValueOf(map[string]any{"filters": []any{filter}})
+	devs, err := await(hid.Call("requestDevice", opts))
+	if err != nil {
+		return 0, err
+	}
+	return devs.Length(), nil
+}
+
+// enumerate calls fn for each device that the page has access to with the
+// given vendor and product ID, or for all devices from the vendor if pid
+// is AnyPID. Enumeration stops if fn returns a non-nil error. WebHID does
+// not expose serial numbers, so each known device is opened to query it.
+func enumerate(vid uint16, pid PID, fn func(deviceInfo) error) error {
+	devs, err := webHIDDevices(vid, pid)
+	if err != nil {
+		return err
+	}
+	for i, dev := range devs {
+		info := deviceInfo{
+			pid:  PID(dev.Get("productId").Int()),
+			path: webHIDPath(i, dev),
+		}
+		if desc, ok := devices[info.pid]; ok && vid == vidElGato {
+			info.serial, _ = webHIDSerial(dev, desc)
+		}
+		err = fn(info)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// open opens the device with the given vendor and product ID and serial
+// number. If serial is empty, the first device with a matching product
+// ID is opened. Devices that are not in the devices table can only be
+// opened without a serial number.
+func open(vid uint16, pid PID, serial string) (HIDDevice, error) {
+	devs, err := webHIDDevices(vid, pid)
+	if err != nil {
+		return nil, err
+	}
+	desc, known := devices[pid]
+	for _, dev := range devs {
+		if serial != "" {
+			if !known || vid != vidElGato {
+				continue
+			}
+			s, err := webHIDSerial(dev, desc)
+			if err != nil || s != serial {
+				continue
+			}
+		}
+		return openWebHID(dev)
+	}
+	return nil, ErrNotConnected
+}
+
+// openPath opens the El Gato device with the given path, as reported by
+// enumerate when called for all products.
+func openPath(path string) (HIDDevice, error) {
+	devs, err := webHIDDevices(vidElGato, AnyPID)
+	if err != nil {
+		return nil, err
+	}
+	for i, dev := range devs {
+		if webHIDPath(i, dev) == path {
+			return openWebHID(dev)
+		}
+	}
+	return nil, ErrNotConnected
+}
+
+// webHIDPath returns the path of dev, the ith device that the page has
+// access to. The path is only valid until the set of devices changes.
+func webHIDPath(i int, dev js.Value) string {
+	return fmt.Sprintf("webhid:%d:%s", i, dev.Get("productName").String())
+}
+
+// navigatorHID returns navigator.hid.
+func navigatorHID() (js.Value, error) {
+	hid := js.Global().Get("navigator").Get("hid")
+	if hid.IsUndefined() {
+		return js.Value{}, errors.New("WebHID not supported by browser")
+	}
+	return hid, nil
+}
+
+// webHIDDevices returns the devices with the given vendor and product ID
+// that the page has access to.
+func webHIDDevices(vid uint16, pid PID) ([]js.Value, error) {
+	hid, err := navigatorHID()
+	if err != nil {
+		return nil, err
+	}
+	all, err := await(hid.Call("getDevices"))
+	if err != nil {
+		return nil, err
+	}
+	var devs []js.Value
+	for i := 0; i < all.Length(); i++ {
+		dev := all.Index(i)
+		if dev.Get("vendorId").Int() != int(vid) {
+			continue
+		}
+		if pid != AnyPID && PID(dev.Get("productId").Int()) != pid {
+			continue
+		}
+		devs = append(devs, dev)
+	}
+	return devs, nil
+}
+
+// webHIDSerial returns the serial number of dev, described by desc,
+// opening it if necessary.
+func webHIDSerial(dev js.Value, desc device) (string, error) {
+	wasOpen := dev.Get("opened").Bool()
+	w, err := openWebHID(dev)
+	if err != nil {
+		return "", err
+	}
+	if !wasOpen {
+		defer w.Close()
+	} else {
+		defer w.release()
+	}
+	d := &Deck{desc: &desc, dev: w, buf: make([]byte, desc.bufLen()), external: true}
+	return d.Serial()
+}
+
+// webHID is a HIDDevice backed by a WebHID HIDDevice.
+type webHID struct {
+	dev      js.Value
+	reports  chan []byte
+	listener js.Func
+	released sync.Once
+
+	// dropped is the number of input reports dropped
+	// since the last call to Dropped.
+	dropped atomic.Uint64
+}
+
+// openWebHID opens dev and starts collecting its input reports.
+func openWebHID(dev js.Value) (*webHID, error) {
+	if !dev.Get("opened").Bool() {
+		_, err := await(dev.Call("open"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	w := &webHID{dev: dev, reports: make(chan []byte, 16)}
+	w.listener = js.FuncOf(func(this js.Value, args []js.Value) any {
+		ev := args[0]
+		// Input reports are provided without the report ID, so
+		// prepend it to match hidapi.
+		data := ev.Get("data")
+		b := make([]byte, 1+data.Get("byteLength").Int())
+		b[0] = byte(ev.Get("reportId").Int())
+		js.CopyBytesToGo(b[1:], js.Global().Get("Uint8Array").New(data.Get("buffer"), data.Get("byteOffset"), data.Get("byteLength")))
+		select {
+		case w.reports <- b:
+		default:
+			// Drop reports if the reader is not keeping up
+			// rather than blocking the JavaScript event loop.
+			w.dropped.Add(1)
+		}
+		return nil
+	})
+	dev.Call("addEventListener", "inputreport", w.listener)
+	return w, nil
+}
+
+func (w *webHID) Read(b []byte) (int, error) {
+	r, ok := <-w.reports
+	if !ok {
+		return 0, io.EOF
+	}
+	return copy(b, r), nil
+}
+
+func (w *webHID) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, errors.New("empty report")
+	}
+	_, err := await(w.dev.Call("sendReport", int(b[0]), uint8Array(b[1:])))
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (w *webHID) SendFeatureReport(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, errors.New("empty feature report")
+	}
+	_, err := await(w.dev.Call("sendFeatureReport", int(b[0]), uint8Array(b[1:])))
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (w *webHID) GetFeatureReport(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, errors.New("empty feature report")
+	}
+	// The returned data includes the report ID as its first byte.
+	data, err := await(w.dev.Call("receiveFeatureReport", int(b[0])))
+	if err != nil {
+		return 0, err
+	}
+	buf := js.Global().Get("Uint8Array").New(data.Get("buffer"), data.Get("byteOffset"), data.Get("byteLength"))
+	return js.CopyBytesToGo(b, buf), nil
+}
+
+func (w *webHID) Close() error {
+	w.release()
+	_, err := await(w.dev.Call("close"))
+	return err
+}
+
+// Dropped returns the number of input reports that have been dropped
+// because the reader was not keeping up, and resets the count.
+func (w *webHID) Dropped() uint64 {
+	return w.dropped.Swap(0)
+}
+
+// release stops collecting input reports. It is safe to call release
+// more than once.
+func (w *webHID) release() {
+	w.released.Do(func() {
+		w.dev.Call("removeEventListener", "inputreport", w.listener)
+		w.listener.Release()
+		close(w.reports)
+	})
+}
+
+// uint8Array returns a JavaScript Uint8Array holding a copy of b.
+func uint8Array(b []byte) js.Value {
+	a := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(a, b)
+	return a
+}
+
+// await waits for the promise p to settle, returning its value or error.
+func await(p js.Value) (js.Value, error) {
+	type result struct {
+		val js.Value
+		err error
+	}
+	c := make(chan result, 1)
+	resolve := js.FuncOf(func(this js.Value, args []js.Value) any {
+		var v js.Value
+		if len(args) != 0 {
+			v = args[0]
+		}
+		c <- result{val: v}
+		return nil
+	})
+	defer resolve.Release()
+	reject := js.FuncOf(func(this js.Value, args []js.Value) any {
+		err := errors.New("WebHID request failed")
+		if len(args) != 0 {
+			err = jsError{args[0]}
+		}
+		c <- result{err: err}
+		return nil
+	})
+	defer reject.Release()
+	p.Call("then", resolve, reject)
+	r := <-c
+	return r.val, r.err
+}
+
+// jsError is a JavaScript error value.
+type jsError struct {
+	js.Value
+}
+
+func (e jsError) Error() string {
+	return e.Call("toString").String()
+}
@@ -0,0 +1,77 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+	"github.com/kortschak/ardilla/label"
+)
+
+const (
+	// identifyFlashes is the number of flashes shown by Identify.
+	identifyFlashes = 10
+
+	// identifyPeriod is the duration of each Identify flash.
+	identifyPeriod = 500 * time.Millisecond
+)
+
+// Identify displays the device's serial number across its keys, flashing
+// between inverted colours for five seconds so that the physical unit can
+// be identified. The key images shown before the call are restored when
+// Identify returns. Identify returns early with ctx.Err() if ctx is
+// cancelled.
+func (d *Deck) Identify(ctx context.Context) error {
+	b, err := d.CanvasBounds(0)
+	if err != nil {
+		return err
+	}
+	serial, err := d.Serial()
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	prev := make([]image.Image, d.Len())
+	if d.images != nil {
+		copy(prev, d.images)
+	}
+	d.mu.Unlock()
+	defer func() {
+		blank := image.NewRGBA(d.desc.bounds())
+		for key, img := range prev {
+			if img == nil {
+				img = blank
+			}
+			err := d.SetImage(key/d.desc.cols, key%d.desc.cols, img)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	size := float64(d.desc.keySize.Y) / 3
+	frames := make([]image.Image, 2)
+	for i, c := range [][2]color.Color{{color.White, color.Black}, {color.Black, color.White}} {
+		frames[i], err = label.Render(b, serial, size, c[0], c[1])
+		if err != nil {
+			return err
+		}
+	}
+	for i := 0; i < identifyFlashes; i++ {
+		err = d.SetCanvas(frames[i%2], 0)
+		if err != nil {
+			return err
+		}
+		if !animation.Sleep(ctx, d.clock, identifyPeriod) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"errors"
+	"image"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+func TestDeckIdentify(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMini)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.serial = "AL12345678"
+	d.setDev(&virtDev{Writer: io.Discard})
+	clock := animation.NewManualClock(time.Time{})
+	WithClock(clock)(d)
+
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	err = d.SetImage(1, 2, img)
+	if err != nil {
+		t.Fatalf("unexpected error for SetImage: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- d.Identify(context.Background())
+	}()
+	for i := 0; i < identifyFlashes; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(identifyPeriod)
+	}
+	err = <-done
+	if err != nil {
+		t.Errorf("unexpected error for Identify: %v", err)
+	}
+	if got := d.images[d.Key(1, 2)]; got != image.Image(img) {
+		t.Errorf("key image not restored after Identify: got:%T", got)
+	}
+	if got := d.images[d.Key(0, 0)]; got == nil || got.Bounds() != d.desc.bounds() {
+		t.Errorf("unexpected key image after Identify: got:%v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = d.Identify(ctx)
+	if err != context.Canceled {
+		t.Errorf("unexpected error for cancelled Identify: got:%v want:%v", err, context.Canceled)
+	}
+
+	p, err := newTestDeck(StreamDeckPedal)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = p.Identify(context.Background())
+	if want := errors.New("images not supported by StreamDeckPedal"); !sameError(err, want) {
+		t.Errorf("unexpected error for non-visual Identify: got:%v want:%v", err, want)
+	}
+}
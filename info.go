@@ -0,0 +1,73 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+// InfoHandle is a read-only handle to a Stream Deck. An InfoHandle never
+// writes to the device; it only requests the device's feature reports,
+// so it may be used by monitoring tools while another program owns the
+// deck's display.
+type InfoHandle struct {
+	d *Deck
+}
+
+// OpenInfo returns a read-only handle to the Stream Deck with the given
+// pid and serial. If serial is empty the first matching pid is used. If
+// pid is AnyPID, the device is selected as for NewDeck.
+func OpenInfo(pid PID, serial string) (*InfoHandle, error) {
+	desc, serial, err := findDevice(pid, serial)
+	if err != nil {
+		return nil, err
+	}
+	dev, err := desc.open(serial)
+	if err != nil {
+		return nil, err
+	}
+	return newInfoHandle(desc, serial, dev)
+}
+
+// newInfoHandle returns an InfoHandle for the device described by desc
+// using dev. The device is not initialised.
+func newInfoHandle(desc device, serial string, dev HIDDevice) (*InfoHandle, error) {
+	d := &Deck{desc: &desc, serial: serial, dev: dev, buf: make([]byte, desc.bufLen()), brightness: -1}
+	if d.serial == "" {
+		var err error
+		d.serial, err = d.ReadSerial()
+		if err != nil {
+			d.dev.Close()
+			return nil, err
+		}
+	}
+	return &InfoHandle{d: d}, nil
+}
+
+// PID returns the PID of the device.
+func (h *InfoHandle) PID() PID {
+	return h.d.PID()
+}
+
+// Serial returns the serial number of the device.
+func (h *InfoHandle) Serial() (string, error) {
+	return h.d.Serial()
+}
+
+// Firmware returns the firmware version number of the device.
+func (h *InfoHandle) Firmware() (string, error) {
+	return h.d.Firmware()
+}
+
+// Layout returns the number of rows and columns of buttons on the device.
+func (h *InfoHandle) Layout() (rows, cols int) {
+	return h.d.Layout()
+}
+
+// Capabilities returns the description of the device's model.
+func (h *InfoHandle) Capabilities() DeviceInfo {
+	return h.d.desc.info()
+}
+
+// Close closes the device.
+func (h *InfoHandle) Close() error {
+	return h.d.dev.Close()
+}
@@ -0,0 +1,52 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestInfoHandle(t *testing.T) {
+	dev := &virtDev{
+		Reader: strings.NewReader(padZero("\x06\x0cAL12K1A01234", 32) + padZero("\x05\x0c\x00\x00\x00\x001.00.008", 32)),
+		Writer: io.Discard,
+		Closer: io.NopCloser(nil),
+	}
+	h, err := newInfoHandle(devices[StreamDeckMK2], "", dev)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	serial, err := h.Serial()
+	if err != nil {
+		t.Errorf("unexpected error for Serial: %v", err)
+	}
+	if want := "AL12K1A01234"; serial != want {
+		t.Errorf("unexpected serial: got:%q want:%q", serial, want)
+	}
+	firmware, err := h.Firmware()
+	if err != nil {
+		t.Errorf("unexpected error for Firmware: %v", err)
+	}
+	if want := "1.00.008"; firmware != want {
+		t.Errorf("unexpected firmware: got:%q want:%q", firmware, want)
+	}
+	if rows, cols := h.Layout(); rows != 3 || cols != 5 {
+		t.Errorf("unexpected layout: got:%dx%d want:3x5", rows, cols)
+	}
+	if got := h.Capabilities(); got.PID != StreamDeckMK2 || !got.Visual || got.Format != "jpeg" {
+		t.Errorf("unexpected capabilities: %+v", got)
+	}
+	err = h.Close()
+	if err != nil {
+		t.Errorf("unexpected error for Close: %v", err)
+	}
+	for _, a := range dev.actions {
+		if !strings.HasPrefix(a, "GetFeatureReport(") && !strings.HasPrefix(a, "Close(") {
+			t.Errorf("unexpected device action: %s", a)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package decktest provides a fake Stream Deck for testing packages that
+// draw on a deck.
+package decktest
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"sync"
+	"testing"
+
+	"github.com/kortschak/ardilla"
+)
+
+// New returns a Stream Deck MK2 backed by a Device.
+func New(t testing.TB) (*ardilla.Deck, *Device) {
+	t.Helper()
+	dev := &Device{
+		images:  make(map[int]image.Image),
+		writes:  make(map[int]int),
+		partial: make(map[int][]byte),
+	}
+	d, err := ardilla.NewDeckHID(ardilla.StreamDeckMK2, dev)
+	if err != nil {
+		t.Fatalf("unexpected error creating deck: %v", err)
+	}
+	return d, dev
+}
+
+// Device is a Stream Deck MK2 HID device that decodes the key images
+// written to it. It never reports key states.
+type Device struct {
+	mu      sync.Mutex
+	images  map[int]image.Image
+	writes  map[int]int
+	partial map[int][]byte
+}
+
+func (d *Device) Read(b []byte) (int, error) { select {} }
+func (d *Device) Close() error               { return nil }
+
+func (d *Device) Write(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(b) < 8 || b[0] != 0x02 || b[1] != 0x07 {
+		return len(b), nil
+	}
+	key := int(b[2])
+	n := int(b[4]) | int(b[5])<<8
+	d.partial[key] = append(d.partial[key], b[8:8+n]...)
+	if b[3] == 1 {
+		img, err := jpeg.Decode(bytes.NewReader(d.partial[key]))
+		if err != nil {
+			return 0, err
+		}
+		d.images[key] = img
+		d.writes[key]++
+		delete(d.partial, key)
+	}
+	return len(b), nil
+}
+
+func (d *Device) SendFeatureReport(b []byte) (int, error) { return len(b), nil }
+func (d *Device) GetFeatureReport(b []byte) (int, error)  { return len(b), nil }
+
+// Written returns whether at least n images have been written to the key
+// at row and column.
+func (d *Device) Written(row, col, n int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writes[row*5+col] >= n
+}
+
+// Colour returns the name of the colour at the centre of the key at row
+// and column, or "unset" if no image has been written to the key. Colour
+// channels are taken as on or off, giving the eight colours from black
+// to white.
+func (d *Device) Colour(row, col int) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img, ok := d.images[row*5+col]
+	if !ok {
+		return "unset"
+	}
+	b := img.Bounds()
+	r, g, bl, _ := img.At(b.Min.X+b.Dx()/2, b.Min.Y+b.Dy()/2).RGBA()
+	bit := func(v uint32) int {
+		if v > 0x8000 {
+			return 1
+		}
+		return 0
+	}
+	return [...]string{"black", "blue", "green", "cyan", "red", "magenta", "yellow", "white"}[bit(r)<<2|bit(g)<<1|bit(bl)]
+}
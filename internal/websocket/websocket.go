@@ -0,0 +1,410 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package websocket implements a minimal RFC 6455 WebSocket client, with
+// the subset of the protocol needed by the JSON APIs of the contributed
+// integrations. The server side of the handshake is provided by Upgrade
+// for use in tests.
+//
+// Messages are read and written whole. Ping frames from the peer are
+// answered automatically while reading, and extensions and subprotocols
+// are not negotiated.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Frame opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// MaxMessageSize is the largest message accepted by Conn.Read.
+const MaxMessageSize = 16 << 20
+
+// acceptGUID is the key suffix hashed into Sec-WebSocket-Accept.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// CloseError is returned by Conn.Read when the peer closes the
+// connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket closed: %d", e.Code)
+	}
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Reason)
+}
+
+// Conn is a WebSocket connection. Read must not be called concurrently,
+// but Write may be called concurrently with Read and with other calls to
+// Write.
+type Conn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	client bool
+
+	wmu sync.Mutex
+}
+
+// Dial opens a WebSocket connection to the ws or wss URL u, sending the
+// given additional header fields with the opening handshake. The context
+// bounds the connection and handshake, but not the lifetime of the
+// returned connection.
+func Dial(ctx context.Context, u string, header http.Header) (*Conn, error) {
+	p, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	addr := p.Host
+	var dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	switch p.Scheme {
+	case "ws":
+		if p.Port() == "" {
+			addr = net.JoinHostPort(p.Hostname(), "80")
+		}
+		dial = (&net.Dialer{}).DialContext
+	case "wss":
+		if p.Port() == "" {
+			addr = net.JoinHostPort(p.Hostname(), "443")
+		}
+		dial = (&tls.Dialer{Config: &tls.Config{ServerName: p.Hostname()}}).DialContext
+	default:
+		return nil, fmt.Errorf("unsupported websocket URL scheme: %q", p.Scheme)
+	}
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Abandon the handshake if the context is
+	// cancelled while waiting for the server.
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	c, err := handshake(conn, p, header)
+	close(done)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake performs the client opening handshake on conn.
+func handshake(conn net.Conn, u *url.URL, header http.Header) (*Conn, error) {
+	var nonce [16]byte
+	_, err := rand.Read(nonce[:])
+	if err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	h := make(http.Header, len(header)+4)
+	for k, v := range header {
+		h[k] = v
+	}
+	h.Set("Upgrade", "websocket")
+	h.Set("Connection", "Upgrade")
+	h.Set("Sec-WebSocket-Key", key)
+	h.Set("Sec-WebSocket-Version", "13")
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     h,
+		Host:       u.Host,
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	err = req.Write(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("websocket handshake failed: %s", resp.Status)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") || !headerContains(resp.Header, "Connection", "upgrade") {
+		return nil, errors.New("websocket handshake failed: connection not upgraded")
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, errors.New("websocket handshake failed: invalid accept key")
+	}
+	return &Conn{conn: conn, r: r, client: true}, nil
+}
+
+// Upgrade completes the server side of the opening handshake for req and
+// returns the connection to the client.
+func Upgrade(w http.ResponseWriter, req *http.Request) (*Conn, error) {
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") || !headerContains(req.Header, "Connection", "upgrade") || key == "" {
+		http.Error(w, "not a websocket handshake", http.StatusBadRequest)
+		return nil, errors.New("websocket: not a websocket handshake")
+	}
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot upgrade connection", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response does not support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: "+acceptKey(key)+"\r\n\r\n")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, r: rw.Reader}, nil
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value for the given key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains returns whether the comma-separated list of tokens in
+// the named header field contains tok, ignoring case.
+func headerContains(h http.Header, name, tok string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), tok) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Read returns the payload of the next text or binary message. Pings
+// received while waiting for the message are answered. When the peer
+// closes the connection, Read returns a *CloseError.
+func (c *Conn) Read() ([]byte, error) {
+	var (
+		msg     []byte
+		started bool
+	)
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opText, opBinary:
+			if started {
+				return nil, errors.New("websocket: new message within fragmented message")
+			}
+			started = true
+			msg = payload
+		case opContinuation:
+			if !started {
+				return nil, errors.New("websocket: unexpected continuation frame")
+			}
+			if len(msg)+len(payload) > MaxMessageSize {
+				return nil, errors.New("websocket: message too large")
+			}
+			msg = append(msg, payload...)
+		case opPing:
+			err = c.writeFrame(opPong, payload)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			cerr := &CloseError{Code: 1005}
+			if len(payload) >= 2 {
+				cerr.Code = int(binary.BigEndian.Uint16(payload))
+				cerr.Reason = string(payload[2:])
+				payload = payload[:2]
+			}
+			c.writeFrame(opClose, payload)
+			c.conn.Close()
+			return nil, cerr
+		default:
+			return nil, fmt.Errorf("websocket: unknown opcode: %#x", op)
+		}
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// readFrame reads a single frame from the peer. Frames sent to a server
+// must be masked and frames sent to a client must not be.
+func (c *Conn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var hdr [8]byte
+	_, err = io.ReadFull(c.r, hdr[:2])
+	if err != nil {
+		return false, 0, nil, err
+	}
+	fin = hdr[0]&0x80 != 0
+	op = hdr[0] & 0x0f
+	if hdr[0]&0x70 != 0 {
+		return false, 0, nil, errors.New("websocket: reserved bits set")
+	}
+	masked := hdr[1]&0x80 != 0
+	if masked == c.client {
+		return false, 0, nil, errors.New("websocket: invalid frame masking")
+	}
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		_, err = io.ReadFull(c.r, hdr[:2])
+		n = uint64(binary.BigEndian.Uint16(hdr[:2]))
+	case 127:
+		_, err = io.ReadFull(c.r, hdr[:8])
+		n = binary.BigEndian.Uint64(hdr[:8])
+	}
+	if err != nil {
+		return false, 0, nil, err
+	}
+	if op&0x8 != 0 && (n > 125 || !fin) {
+		return false, 0, nil, errors.New("websocket: invalid control frame")
+	}
+	if n > MaxMessageSize {
+		return false, 0, nil, errors.New("websocket: message too large")
+	}
+	var mask [4]byte
+	if masked {
+		_, err = io.ReadFull(c.r, mask[:])
+		if err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	_, err = io.ReadFull(c.r, payload)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// Write sends data as a single text message.
+func (c *Conn) Write(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// writeFrame sends a single frame with the given opcode, masking it if
+// the connection is a client.
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|op)
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, maskBit|byte(n))
+	case n <= 0xffff:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	if !c.client {
+		buf = append(buf, payload...)
+	} else {
+		var mask [4]byte
+		_, err := rand.Read(mask[:])
+		if err != nil {
+			return err
+		}
+		buf = append(buf, mask[:]...)
+		off := len(buf)
+		buf = append(buf, payload...)
+		for i := range buf[off:] {
+			buf[off+i] ^= mask[i%4]
+		}
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v.
+func (c *Conn) ReadJSON(v any) error {
+	msg, err := c.Read()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(msg, v)
+}
+
+// WriteJSON sends the JSON encoding of v as a text message.
+func (c *Conn) WriteJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Write(b)
+}
+
+// Close sends a normal closure to the peer and closes the connection
+// without waiting for the peer's reply. A blocked Read returns an
+// error.
+func (c *Conn) Close() error {
+	c.writeFrame(opClose, []byte{0x03, 0xe8})
+	return c.conn.Close()
+}
@@ -0,0 +1,233 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455 section 1.3.
+	got := acceptKey("dGhlIHNhbXBsZSBub25jZQ==")
+	want := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+	if got != want {
+		t.Errorf("unexpected accept key: got:%s want:%s", got, want)
+	}
+}
+
+// serve starts a test server that completes the opening handshake and
+// then calls fn with the connection.
+func serve(t *testing.T, accept func(key string) string, fn func(conn net.Conn, r *bufio.Reader)) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Test") != "yes" {
+			http.Error(w, "missing header", http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		key := req.Header.Get("Sec-WebSocket-Key")
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\n"+
+			"Upgrade: websocket\r\n"+
+			"Connection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: "+accept(key)+"\r\n\r\n")
+		fn(conn, rw.Reader)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// writeServerFrame writes an unmasked frame.
+func writeServerFrame(w io.Writer, fin bool, op byte, payload []byte) error {
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	hdr := []byte{b0}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, byte(n))
+	case n <= 0xffff:
+		hdr = append(hdr, 126)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(n))
+	default:
+		hdr = append(hdr, 127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	_, err := w.Write(append(hdr, payload...))
+	return err
+}
+
+// readClientFrame reads a frame, checking that it is masked.
+func readClientFrame(r io.Reader) (op byte, payload []byte, err error) {
+	var hdr [8]byte
+	_, err = io.ReadFull(r, hdr[:2])
+	if err != nil {
+		return 0, nil, err
+	}
+	if hdr[1]&0x80 == 0 {
+		return 0, nil, errors.New("unmasked client frame")
+	}
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		_, err = io.ReadFull(r, hdr[:2])
+		n = uint64(binary.BigEndian.Uint16(hdr[:2]))
+	case 127:
+		_, err = io.ReadFull(r, hdr[:8])
+		n = binary.BigEndian.Uint64(hdr[:8])
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	var mask [4]byte
+	_, err = io.ReadFull(r, mask[:])
+	if err != nil {
+		return 0, nil, err
+	}
+	payload = make([]byte, n)
+	_, err = io.ReadFull(r, payload)
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return hdr[0] & 0x0f, payload, err
+}
+
+func TestConn(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), 70000)
+	u := serve(t, acceptKey, func(conn net.Conn, r *bufio.Reader) {
+		// Echo the client's first message back in two fragments
+		// with a ping between them, then a large message.
+		op, msg, err := readClientFrame(r)
+		if err != nil || op != opText {
+			t.Errorf("unexpected client frame: op=%#x err=%v", op, err)
+			return
+		}
+		writeServerFrame(conn, false, opText, msg[:2])
+		writeServerFrame(conn, true, opPing, []byte("ping"))
+		writeServerFrame(conn, true, opContinuation, msg[2:])
+		op, pong, err := readClientFrame(r)
+		if err != nil || op != opPong || string(pong) != "ping" {
+			t.Errorf("unexpected pong: op=%#x payload=%q err=%v", op, pong, err)
+		}
+		writeServerFrame(conn, true, opBinary, big)
+		writeServerFrame(conn, true, opClose, append([]byte{0x03, 0xe9}, "going away"...))
+		op, _, err = readClientFrame(r)
+		if err != nil || op != opClose {
+			t.Errorf("unexpected close reply: op=%#x err=%v", op, err)
+		}
+	})
+
+	c, err := Dial(context.Background(), u, http.Header{"X-Test": {"yes"}})
+	if err != nil {
+		t.Fatalf("unexpected error dialing: %v", err)
+	}
+	defer c.Close()
+	err = c.WriteJSON(map[string]int{"op": 1})
+	if err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	var got map[string]int
+	err = c.ReadJSON(&got)
+	if err != nil {
+		t.Fatalf("unexpected error reading: %v", err)
+	}
+	if got["op"] != 1 {
+		t.Errorf("unexpected echo: %v", got)
+	}
+	msg, err := c.Read()
+	if err != nil {
+		t.Fatalf("unexpected error reading: %v", err)
+	}
+	if !bytes.Equal(msg, big) {
+		t.Errorf("unexpected large message: got %d bytes want %d", len(msg), len(big))
+	}
+	_, err = c.Read()
+	var cerr *CloseError
+	if !errors.As(err, &cerr) || cerr.Code != 1001 || cerr.Reason != "going away" {
+		t.Errorf("unexpected close error: %v", err)
+	}
+}
+
+func TestDialErrors(t *testing.T) {
+	bad := serve(t, func(string) string { return "invalid" }, func(net.Conn, *bufio.Reader) {})
+	for _, test := range []struct {
+		url    string
+		header http.Header
+	}{
+		{url: "http://example.com/"},
+		{url: bad, header: http.Header{"X-Test": {"yes"}}},
+		{url: bad},
+	} {
+		_, err := Dial(context.Background(), test.url, test.header)
+		if err == nil {
+			t.Errorf("expected error dialing %s with %v", test.url, test.header)
+		}
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c, err := Upgrade(w, req)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			msg, err := c.Read()
+			if err != nil {
+				return
+			}
+			err = c.Write(bytes.ToUpper(msg))
+			if err != nil {
+				t.Errorf("unexpected error writing: %v", err)
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unexpected status for plain request: %s", resp.Status)
+	}
+
+	c, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/path?q=1", nil)
+	if err != nil {
+		t.Fatalf("unexpected error dialing: %v", err)
+	}
+	defer c.Close()
+	for _, msg := range []string{"hello", strings.Repeat("a", 200)} {
+		err = c.Write([]byte(msg))
+		if err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+		got, err := c.Read()
+		if err != nil {
+			t.Fatalf("unexpected error reading: %v", err)
+		}
+		if want := strings.ToUpper(msg); string(got) != want {
+			t.Errorf("unexpected reply: got:%q want:%q", got, want)
+		}
+	}
+}
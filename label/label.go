@@ -0,0 +1,113 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package label renders text labels for Stream Deck keys.
+package label
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	parseOnce sync.Once
+	regular   *sfnt.Font
+	parseErr  error
+)
+
+// Face returns a Go Regular font face with the given size in pixels.
+func Face(size float64) (font.Face, error) {
+	parseOnce.Do(func() {
+		regular, parseErr = opentype.Parse(goregular.TTF)
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return opentype.NewFace(regular, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+}
+
+// Render returns an image with the given bounds filled with bg and with
+// text drawn centred in fg using the Go Regular font at the given size in
+// pixels. Text is broken into lines at newlines and wrapped at spaces to
+// fit the width of the image. Text that does not fit is clipped.
+func Render(bounds image.Rectangle, text string, size float64, fg, bg color.Color) (*image.RGBA, error) {
+	face, err := Face(size)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+	return RenderFace(bounds, text, face, fg, bg), nil
+}
+
+// RenderFace is like Render, but uses the provided font face.
+func RenderFace(bounds image.Rectangle, text string, face font.Face, fg, bg color.Color) *image.RGBA {
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.NewUniform(bg), image.Point{}, draw.Src)
+
+	lines := wrap(face, text, fixed.I(bounds.Dx()))
+	if len(lines) == 0 {
+		return dst
+	}
+	m := face.Metrics()
+	height := m.Height.Mul(fixed.I(len(lines)-1)) + m.Ascent + m.Descent
+	y := fixed.I(bounds.Min.Y) + (fixed.I(bounds.Dy())-height)/2 + m.Ascent
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(fg),
+		Face: face,
+	}
+	for _, l := range lines {
+		w := d.MeasureString(l)
+		d.Dot = fixed.Point26_6{
+			X: fixed.I(bounds.Min.X) + (fixed.I(bounds.Dx())-w)/2,
+			Y: y,
+		}
+		d.DrawString(l)
+		y += m.Height
+	}
+	return dst
+}
+
+// wrap returns text broken into lines at newlines and wrapped at spaces
+// so that each line fits within width when rendered with face, if
+// possible. Words that are wider than width are placed on their own line.
+func wrap(face font.Face, text string, width fixed.Int26_6) []string {
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := words[0]
+		for _, w := range words[1:] {
+			if font.MeasureString(face, line+" "+w) <= width {
+				line += " " + w
+				continue
+			}
+			lines = append(lines, line)
+			line = w
+		}
+		lines = append(lines, line)
+	}
+	// Drop trailing blank lines so that they
+	// do not affect vertical centring.
+	for len(lines) != 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
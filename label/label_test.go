@@ -0,0 +1,95 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package label
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+var wrapTests = []struct {
+	text  string
+	width int
+	want  []string
+}{
+	{text: "", width: 72, want: []string{}},
+	{text: "Deploy", width: 72, want: []string{"Deploy"}},
+	{text: "Deploy\nnow", width: 72, want: []string{"Deploy", "now"}},
+	{text: "Deploy now", width: 200, want: []string{"Deploy now"}},
+	{text: "Deploy now", width: 60, want: []string{"Deploy", "now"}},
+	{text: "Deploy\n\nnow\n\n", width: 72, want: []string{"Deploy", "", "now"}},
+	{text: "Supercalifragilistic", width: 72, want: []string{"Supercalifragilistic"}},
+}
+
+func TestWrap(t *testing.T) {
+	face, err := Face(18)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer face.Close()
+	for _, test := range wrapTests {
+		got := wrap(face, test.text, fixed.I(test.width))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("unexpected result for %q at width %d:\ngot: %q\nwant:%q", test.text, test.width, got, test.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	bounds := image.Rect(0, 0, 72, 72)
+	fg := color.RGBA{R: 0xff, A: 0xff}
+	bg := color.RGBA{B: 0xff, A: 0xff}
+
+	img, err := Render(bounds, "", 18, fg, bg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if img.Bounds() != bounds {
+		t.Errorf("unexpected bounds: got:%v want:%v", img.Bounds(), bounds)
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if got := img.RGBAAt(x, y); got != bg {
+				t.Fatalf("unexpected pixel in empty label at (%d,%d): got:%v want:%v", x, y, got, bg)
+			}
+		}
+	}
+
+	img, err = Render(bounds, "I", 36, fg, bg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var n int
+	ink := image.Rectangle{Min: bounds.Max, Max: bounds.Min}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if img.RGBAAt(x, y) == fg {
+				n++
+				ink = ink.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if n == 0 {
+		t.Fatal("no text rendered")
+	}
+	// The glyph for I is symmetrical, so its ink should be
+	// centred in the image to within a couple of pixels.
+	centre := ink.Min.Add(ink.Max).Div(2)
+	want := bounds.Min.Add(bounds.Max).Div(2)
+	if d := centre.Sub(want); abs(d.X) > 2 || abs(d.Y) > 2 {
+		t.Errorf("text not centred: ink bounds %v centre %v want %v", ink, centre, want)
+	}
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
@@ -0,0 +1,129 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"image"
+	"sort"
+	"time"
+)
+
+// Latency is a summary of press-to-render latencies, the time from a key
+// event to the completion of the image write that responds to it.
+type Latency struct {
+	// Count is the number of measurements
+	// summarised.
+	Count int
+
+	// Min and Max are the shortest and longest
+	// latencies measured.
+	Min, Max time.Duration
+
+	// P50, P90 and P99 are the 50th, 90th and
+	// 99th percentile latencies.
+	P50, P90, P99 time.Duration
+}
+
+// latencySamples is the number of recent press-to-render latencies that
+// are summarised.
+const latencySamples = 256
+
+// latencyWindow holds the most recent press-to-render latencies.
+type latencyWindow struct {
+	samples [latencySamples]time.Duration
+	next    int
+	n       int
+}
+
+// add adds a latency to the window, replacing the oldest if the window
+// is full.
+func (w *latencyWindow) add(l time.Duration) {
+	w.samples[w.next] = l
+	w.next = (w.next + 1) % latencySamples
+	if w.n < latencySamples {
+		w.n++
+	}
+}
+
+// summary returns a summary of the latencies in the window.
+func (w *latencyWindow) summary() Latency {
+	if w.n == 0 {
+		return Latency{}
+	}
+	s := make([]time.Duration, w.n)
+	copy(s, w.samples[:w.n])
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return Latency{
+		Count: len(s),
+		Min:   s[0],
+		Max:   s[len(s)-1],
+		P50:   percentile(s, 50),
+		P90:   percentile(s, 90),
+		P99:   percentile(s, 99),
+	}
+}
+
+// percentile returns the p'th percentile of the sorted durations in s
+// using the nearest-rank method.
+func percentile(s []time.Duration, p int) time.Duration {
+	rank := (p*len(s) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return s[rank-1]
+}
+
+// SetImageFor sets the image on the button at the given row and column,
+// as SetImage does, in response to ev, and records the time from the
+// event to the completion of the write as a press-to-render latency.
+// The latency is not recorded if the write fails.
+func (d *Deck) SetImageFor(ev KeyEvent, row, col int, img image.Image) error {
+	err := d.SetImage(row, col, img)
+	if err != nil {
+		return err
+	}
+	d.RecordLatency(ev)
+	return nil
+}
+
+// RecordLatency records the time from ev to now as a press-to-render
+// latency. It allows latencies to be measured for responses rendered by
+// other means than SetImageFor, for example with a Batch, and should be
+// called when the response has been written. Events without a time are
+// ignored.
+func (d *Deck) RecordLatency(ev KeyEvent) {
+	if ev.Time.IsZero() {
+		return
+	}
+	var now time.Time
+	if d.clock != nil {
+		now = d.clock.Now()
+	} else {
+		now = time.Now()
+	}
+	d.mu.Lock()
+	d.latency.add(now.Sub(ev.Time))
+	d.mu.Unlock()
+}
+
+// Latency returns a summary of the most recent press-to-render latencies
+// recorded by SetImageFor and RecordLatency. Latencies are recorded by
+// the program, so they include the time taken by the program to respond
+// to the event, including encoding the image. The effect of rendering
+// options such as pacing, dithering and sharpening can be compared by
+// calling ResetLatency between trials. The returned Latency is zero if
+// no latencies have been recorded.
+func (d *Deck) Latency() Latency {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.latency.summary()
+}
+
+// ResetLatency discards the recorded press-to-render latencies.
+func (d *Deck) ResetLatency() {
+	d.mu.Lock()
+	d.latency = latencyWindow{}
+	d.mu.Unlock()
+}
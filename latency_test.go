@@ -0,0 +1,101 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+func TestLatencyWindow(t *testing.T) {
+	var w latencyWindow
+	if got := w.summary(); got != (Latency{}) {
+		t.Errorf("unexpected summary of empty window: %+v", got)
+	}
+	// Add more than a window of samples; only the
+	// last latencySamples are retained.
+	for i := 1; i <= latencySamples+44; i++ {
+		w.add(time.Duration((i-1)%latencySamples+1) * time.Millisecond)
+	}
+	// The last latencySamples values are 45..256 and 1..44.
+	want := Latency{
+		Count: latencySamples,
+		Min:   time.Millisecond,
+		Max:   latencySamples * time.Millisecond,
+		P50:   128 * time.Millisecond,
+		P90:   231 * time.Millisecond,
+		P99:   254 * time.Millisecond,
+	}
+	if got := w.summary(); got != want {
+		t.Errorf("unexpected summary:\ngot: %+v\nwant:%+v", got, want)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	s := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for _, test := range []struct {
+		p    int
+		want time.Duration
+	}{
+		{p: 0, want: 1},
+		{p: 10, want: 1},
+		{p: 11, want: 2},
+		{p: 50, want: 5},
+		{p: 90, want: 9},
+		{p: 99, want: 10},
+		{p: 100, want: 10},
+	} {
+		if got := percentile(s, test.p); got != test.want {
+			t.Errorf("unexpected %d'th percentile: got:%d want:%d", test.p, got, test.want)
+		}
+	}
+}
+
+func TestSetImageFor(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.setDev(&virtDev{Writer: io.Discard})
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := animation.NewManualClock(start)
+	d.clock = clock
+
+	img := uniformRGBA(image.Rect(0, 0, 72, 72), color.White)
+	for _, l := range []time.Duration{30, 10, 20} {
+		ev := KeyEvent{Time: clock.Now(), Key: 0, Pressed: true}
+		clock.Advance(l * time.Millisecond)
+		err = d.SetImageFor(ev, 0, 0, img)
+		if err != nil {
+			t.Fatalf("unexpected error for SetImageFor: %v", err)
+		}
+	}
+	err = d.SetImageFor(KeyEvent{Time: clock.Now()}, 5, 0, img)
+	if err == nil {
+		t.Error("expected error for out of bounds key")
+	}
+	d.RecordLatency(KeyEvent{})
+
+	want := Latency{
+		Count: 3,
+		Min:   10 * time.Millisecond,
+		Max:   30 * time.Millisecond,
+		P50:   20 * time.Millisecond,
+		P90:   30 * time.Millisecond,
+		P99:   30 * time.Millisecond,
+	}
+	if got := d.Latency(); got != want {
+		t.Errorf("unexpected latency:\ngot: %+v\nwant:%+v", got, want)
+	}
+	d.ResetLatency()
+	if got := d.Latency(); got != (Latency{}) {
+		t.Errorf("unexpected latency after reset: %+v", got)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// Layer is a z-ordered image layer of a key. Layers are composited from
+// LayerBackground up to LayerOverlay, so that independent parts of a
+// program can each own a layer of a key without overwriting the pixels
+// of the others.
+type Layer int
+
+const (
+	LayerBackground Layer = iota
+	LayerIcon
+	LayerBadge
+	LayerOverlay
+
+	numLayers
+)
+
+func (l Layer) String() string {
+	switch l {
+	case LayerBackground:
+		return "background"
+	case LayerIcon:
+		return "icon"
+	case LayerBadge:
+		return "badge"
+	case LayerOverlay:
+		return "overlay"
+	default:
+		return fmt.Sprintf("Layer(%d)", int(l))
+	}
+}
+
+// layers is the set of layer images of a key.
+type layers [numLayers]image.Image
+
+// SetLayer sets the image of the given layer of the button at row and
+// column and renders the composite of the key's layers. A nil image clears
+// the layer. Layer images are scaled to fit the key, preserving their
+// aspect ratio, and are drawn over the layers below them, with transparent
+// regions showing the lower layers. Uncovered regions are black.
+//
+// Calls to SetImage on a key replace its composite until the next change
+// to one of its layers. Any animation running on the key is stopped.
+func (d *Deck) SetLayer(row, col int, layer Layer, img image.Image) error {
+	if layer < 0 || numLayers <= layer {
+		return fmt.Errorf("invalid layer: %v", layer)
+	}
+	key, err := d.keyIndex(row, col)
+	if err != nil {
+		return err
+	}
+	bounds, err := d.Bounds()
+	if err != nil {
+		return err
+	}
+
+	d.layerMu.Lock()
+	defer d.layerMu.Unlock()
+	if d.layers == nil {
+		d.layers = make(map[int]*layers)
+	}
+	l := d.layers[key]
+	if l == nil {
+		if img == nil {
+			// Clearing a layer of a key with no layers
+			// leaves the key unchanged.
+			return nil
+		}
+		l = &layers{}
+		d.layers[key] = l
+	}
+	l[layer] = img
+	if *l == (layers{}) {
+		delete(d.layers, key)
+	}
+	d.stopAnimation(key)
+	return d.setImage(key, l.composite(bounds))
+}
+
+// Layer returns the image of the given layer of the button at row and
+// column, or nil if the layer is not set.
+func (d *Deck) Layer(row, col int, layer Layer) image.Image {
+	if layer < 0 || numLayers <= layer {
+		return nil
+	}
+	key, err := d.keyIndex(row, col)
+	if err != nil {
+		return nil
+	}
+	d.layerMu.Lock()
+	defer d.layerMu.Unlock()
+	l := d.layers[key]
+	if l == nil {
+		return nil
+	}
+	return l[layer]
+}
+
+// ClearLayers clears all the layers of the button at row and column and
+// sets the key to black.
+func (d *Deck) ClearLayers(row, col int) error {
+	key, err := d.keyIndex(row, col)
+	if err != nil {
+		return err
+	}
+	bounds, err := d.Bounds()
+	if err != nil {
+		return err
+	}
+	d.layerMu.Lock()
+	defer d.layerMu.Unlock()
+	delete(d.layers, key)
+	d.stopAnimation(key)
+	return d.setImage(key, (&layers{}).composite(bounds))
+}
+
+// composite returns the composite of the layers with the given bounds.
+func (l *layers) composite(bounds image.Rectangle) image.Image {
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	for _, img := range l {
+		if img == nil {
+			continue
+		}
+		if img.Bounds() == bounds {
+			draw.Draw(dst, bounds, img, bounds.Min, draw.Over)
+			continue
+		}
+		draw.BiLinear.Scale(dst, keepAspectRatio(dst, img), img, img.Bounds(), draw.Over, nil)
+	}
+	return dst
+}
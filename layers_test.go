@@ -0,0 +1,100 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"testing"
+)
+
+func TestDeckSetLayer(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMini)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dev := &virtDev{Writer: io.Discard}
+	d.setDev(dev)
+
+	red := uniformRGBA(image.Rect(0, 0, 80, 80), color.RGBA{R: 0xff, A: 0xff})
+	// The badge is a square covering the top left quarter of
+	// the key with its right half transparent.
+	badge := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(badge, image.Rect(0, 0, 20, 40), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	err = d.SetLayer(0, 1, LayerBadge, badge)
+	if err != nil {
+		t.Fatalf("unexpected error setting badge: %v", err)
+	}
+	err = d.SetLayer(0, 1, LayerBackground, red)
+	if err != nil {
+		t.Fatalf("unexpected error setting background: %v", err)
+	}
+	if d.Layer(0, 1, LayerBadge) != image.Image(badge) {
+		t.Error("badge layer not retained")
+	}
+	if d.Layer(0, 1, LayerIcon) != nil {
+		t.Error("unexpected icon layer")
+	}
+
+	// The badge is scaled to fill the key, so its opaque half
+	// covers the left of the key.
+	checkPixels := func(want map[image.Point]color.RGBA) {
+		t.Helper()
+		img := d.images[d.Key(0, 1)]
+		for p, c := range want {
+			got := color.RGBAModel.Convert(img.At(p.X, p.Y))
+			if got != c {
+				t.Errorf("unexpected colour at %v: got:%v want:%v", p, got, c)
+			}
+		}
+	}
+	checkPixels(map[image.Point]color.RGBA{
+		{X: 10, Y: 40}: {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		{X: 70, Y: 40}: {R: 0xff, A: 0xff},
+	})
+
+	err = d.SetLayer(0, 1, LayerBackground, nil)
+	if err != nil {
+		t.Fatalf("unexpected error clearing background: %v", err)
+	}
+	checkPixels(map[image.Point]color.RGBA{
+		{X: 10, Y: 40}: {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		{X: 70, Y: 40}: {A: 0xff},
+	})
+
+	err = d.ClearLayers(0, 1)
+	if err != nil {
+		t.Fatalf("unexpected error clearing layers: %v", err)
+	}
+	checkPixels(map[image.Point]color.RGBA{
+		{X: 10, Y: 40}: {A: 0xff},
+		{X: 70, Y: 40}: {A: 0xff},
+	})
+	if d.Layer(0, 1, LayerBadge) != nil {
+		t.Error("badge layer not cleared")
+	}
+
+	dev.actions = nil
+	err = d.SetLayer(1, 1, LayerOverlay, nil)
+	if err != nil {
+		t.Errorf("unexpected error clearing unset layer: %v", err)
+	}
+	if len(dev.actions) != 0 {
+		t.Errorf("unexpected actions clearing unset layer: %q", dev.actions)
+	}
+
+	err = d.SetLayer(0, 0, numLayers, red)
+	if want := errors.New("invalid layer: Layer(4)"); !sameError(err, want) {
+		t.Errorf("unexpected error for invalid layer: got:%v want:%v", err, want)
+	}
+	err = d.SetLayer(2, 0, LayerIcon, red)
+	if want := errors.New("row out of bounds: 2"); !sameError(err, want) {
+		t.Errorf("unexpected error for invalid row: got:%v want:%v", err, want)
+	}
+}
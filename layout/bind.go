@@ -0,0 +1,162 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package layout
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/kortschak/ardilla"
+)
+
+// Bindings is a persistent assignment of layouts to decks.
+//
+// A binding with a Serial is pinned to the deck with that serial number.
+// Bindings without a Serial are rules that match decks by Model and Port;
+// when a rule is used to assign a layout to a deck with a serial number,
+// a pinned binding is added so that the deck keeps its layout when it is
+// later connected to a different port or enumerated in a different order.
+type Bindings struct {
+	Bindings []Binding `json:"bindings"`
+
+	// path is the file the bindings were loaded from.
+	path string
+}
+
+// Binding assigns a layout to a deck.
+type Binding struct {
+	// Serial is the serial number of the bound deck.
+	Serial string `json:"serial,omitempty"`
+
+	// Model is the name of the product ID of the
+	// bound deck, for example "StreamDeckXL".
+	Model string `json:"model,omitempty"`
+
+	// Port is the platform-specific physical
+	// location of the bound deck, as reported
+	// in ardilla.DeckInfo.Port.
+	Port string `json:"port,omitempty"`
+
+	// Layout is the path to the layout file.
+	// Relative paths are resolved relative to
+	// the directory holding the bindings file.
+	Layout string `json:"layout"`
+}
+
+// matches returns whether the rule b matches the deck described by info.
+func (b Binding) matches(info ardilla.DeckInfo) bool {
+	return (b.Model == "" || b.Model == info.PID.String()) &&
+		(b.Port == "" || b.Port == info.Port)
+}
+
+// Assignment is a layout assigned to a deck.
+type Assignment struct {
+	Deck ardilla.DeckInfo
+
+	// Layout is the resolved path to the layout file.
+	Layout string
+}
+
+// LoadBindings reads the bindings held in the JSON file at path.
+func LoadBindings(path string) (*Bindings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Bindings
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, r := range b.Bindings {
+		if r.Layout == "" {
+			return nil, fmt.Errorf("%s: binding %d: missing layout", path, i)
+		}
+	}
+	b.path = path
+	return &b, nil
+}
+
+// Save writes the bindings back to the file they were loaded from.
+func (b *Bindings) Save() error {
+	if b.path == "" {
+		return errors.New("bindings not loaded from a file")
+	}
+	data, err := json.MarshalIndent(b, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := b.path + ".tmp"
+	err = os.WriteFile(tmp, append(data, '\n'), 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+// Assign returns the layout assignments for the provided decks. Decks are
+// first matched by serial number and then by the first matching rule, in
+// the order the bindings are held. Decks that match no binding are not
+// included in the returned assignments. Assign reports whether new pinned
+// bindings were added, in which case the bindings should be saved.
+func (b *Bindings) Assign(decks []ardilla.DeckInfo) (assigned []Assignment, changed bool) {
+	decks = append(decks[:0:0], decks...)
+	sort.Slice(decks, func(i, j int) bool {
+		return decks[i].Port < decks[j].Port
+	})
+	pinned := make(map[string]string)
+	for _, r := range b.Bindings {
+		if r.Serial != "" {
+			pinned[r.Serial] = r.Layout
+		}
+	}
+	for _, d := range decks {
+		layout, ok := pinned[d.Serial]
+		if !ok || d.Serial == "" {
+			layout, ok = b.rule(d)
+			if !ok {
+				continue
+			}
+			if d.Serial != "" {
+				b.Bindings = append(b.Bindings, Binding{
+					Serial: d.Serial,
+					Model:  d.PID.String(),
+					Layout: layout,
+				})
+				pinned[d.Serial] = layout
+				changed = true
+			}
+		}
+		assigned = append(assigned, Assignment{Deck: d, Layout: b.resolve(layout)})
+	}
+	return assigned, changed
+}
+
+// rule returns the layout of the first rule matching the deck described
+// by info.
+func (b *Bindings) rule(info ardilla.DeckInfo) (layout string, ok bool) {
+	for _, r := range b.Bindings {
+		if r.Serial == "" && r.matches(info) {
+			return r.Layout, true
+		}
+	}
+	return "", false
+}
+
+// resolve returns the path to the named layout file, resolving relative
+// paths against the bindings file's directory.
+func (b *Bindings) resolve(name string) string {
+	if filepath.IsAbs(name) || b.path == "" {
+		return name
+	}
+	return filepath.Join(filepath.Dir(b.path), name)
+}
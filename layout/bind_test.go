@@ -0,0 +1,105 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package layout
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kortschak/ardilla"
+)
+
+func TestBindings(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bindings.json")
+	err := os.WriteFile(path, []byte(`{"bindings": [
+	{"serial": "A", "layout": "a.json"},
+	{"port": "/devices/usb1/1-1/1-1:1.0", "layout": "/abs/port.json"},
+	{"model": "StreamDeckXL", "layout": "xl.json"}
+]}`), 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error writing bindings: %v", err)
+	}
+	b, err := LoadBindings(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading bindings: %v", err)
+	}
+
+	decks := []ardilla.DeckInfo{
+		{PID: ardilla.StreamDeckXL, Serial: "C", Path: "/dev/hidraw0", Port: "/devices/usb1/1-3/1-3:1.0"},
+		{PID: ardilla.StreamDeckMK2, Serial: "A", Path: "/dev/hidraw1", Port: "/devices/usb1/1-2/1-2:1.0"},
+		{PID: ardilla.StreamDeckMK2, Serial: "B", Path: "/dev/hidraw2", Port: "/devices/usb1/1-1/1-1:1.0"},
+		{PID: ardilla.StreamDeckMini, Serial: "D", Path: "/dev/hidraw3", Port: "/devices/usb1/1-4/1-4:1.0"},
+	}
+	got, changed := b.Assign(decks)
+	want := []Assignment{
+		{Deck: decks[2], Layout: "/abs/port.json"},
+		{Deck: decks[1], Layout: filepath.Join(dir, "a.json")},
+		{Deck: decks[0], Layout: filepath.Join(dir, "xl.json")},
+	}
+	if !changed {
+		t.Error("expected bindings to be changed")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected assignment:\ngot: %+v\nwant:%+v", got, want)
+	}
+	err = b.Save()
+	if err != nil {
+		t.Fatalf("unexpected error saving bindings: %v", err)
+	}
+
+	// Swap ports, renumbering the device nodes. The pinned
+	// decks keep their layouts.
+	b, err = LoadBindings(path)
+	if err != nil {
+		t.Fatalf("unexpected error reloading bindings: %v", err)
+	}
+	decks = []ardilla.DeckInfo{
+		{PID: ardilla.StreamDeckMK2, Serial: "A", Path: "/dev/hidraw5", Port: "/devices/usb1/1-1/1-1:1.0"},
+		{PID: ardilla.StreamDeckMK2, Serial: "B", Path: "/dev/hidraw4", Port: "/devices/usb1/1-2/1-2:1.0"},
+	}
+	got, changed = b.Assign(decks)
+	want = []Assignment{
+		{Deck: decks[0], Layout: filepath.Join(dir, "a.json")},
+		{Deck: decks[1], Layout: "/abs/port.json"},
+	}
+	if changed {
+		t.Error("unexpected change to bindings")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected assignment after port swap:\ngot: %+v\nwant:%+v", got, want)
+	}
+
+	// A deck without a serial number is matched by its port,
+	// whatever its device node.
+	decks = []ardilla.DeckInfo{
+		{PID: ardilla.StreamDeckMini, Path: "/dev/hidraw7", Port: "/devices/usb1/1-1/1-1:1.0"},
+	}
+	got, changed = b.Assign(decks)
+	want = []Assignment{
+		{Deck: decks[0], Layout: "/abs/port.json"},
+	}
+	if changed {
+		t.Error("unexpected change to bindings for deck without serial")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected assignment for deck without serial:\ngot: %+v\nwant:%+v", got, want)
+	}
+}
+
+func TestLoadBindingsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bindings.json")
+	err := os.WriteFile(path, []byte(`{"bindings": [{"serial": "A"}]}`), 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error writing bindings: %v", err)
+	}
+	_, err = LoadBindings(path)
+	if want := errors.New(path + ": binding 0: missing layout"); !sameError(err, want) {
+		t.Errorf("unexpected error: got:%v want:%v", err, want)
+	}
+}
@@ -0,0 +1,463 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package layout
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kortschak/ardilla"
+)
+
+// A layout bundle is a single file holding a layout, the image files it
+// uses and, for each of a set of targets, the pre-rendered key images for
+// the layout. A bundle begins with the line "ardilla-bundle", followed by
+// a line holding the name of the compression method used for the rest
+// of the file, which is a tar archive.
+//
+// When a bundled layout is applied to a Deck matching one of the bundle's
+// targets, the pre-rendered key images are used without decoding or
+// rendering any images.
+
+// bundleMagic is the first line of a layout bundle.
+const bundleMagic = "ardilla-bundle\n"
+
+// Bundle archive entry names.
+const (
+	bundleLayout  = "layout.json"
+	bundleTargets = "targets.json"
+	bundleAssets  = "assets/"
+	bundleRaw     = "raw/"
+	bundleBlank   = "blank"
+)
+
+// Compression is a stream compression method for layout bundles.
+type Compression struct {
+	// NewReader returns a reader that decompresses r.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+	// NewWriter returns a writer that compresses to w.
+	// The writer is closed when the bundle is complete.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+// compressions is the registry of bundle compression methods.
+var compressions = struct {
+	sync.RWMutex
+	m map[string]Compression
+}{
+	m: map[string]Compression{
+		"none": {
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return io.NopCloser(r), nil
+			},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return nopWriteCloser{w}, nil
+			},
+		},
+		"gzip": {
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return gzip.NewWriter(w), nil
+			},
+		},
+	},
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// RegisterCompression registers c as the named bundle compression method,
+// replacing any previously registered method. The "none" and "gzip"
+// methods are registered by default. Other methods, such as zstd or LZ4,
+// may be registered by programs that provide an implementation.
+func RegisterCompression(name string, c Compression) {
+	if strings.ContainsAny(name, "\n") {
+		panic(fmt.Sprintf("invalid compression name: %q", name))
+	}
+	compressions.Lock()
+	defer compressions.Unlock()
+	compressions.m[name] = c
+}
+
+// compression returns the named compression method.
+func compression(name string) (Compression, error) {
+	compressions.RLock()
+	defer compressions.RUnlock()
+	c, ok := compressions.m[name]
+	if !ok {
+		return Compression{}, fmt.Errorf("unknown bundle compression: %q", name)
+	}
+	return c, nil
+}
+
+// Target is a device model and set of rendering settings that the key
+// images of a bundle are pre-rendered for.
+type Target struct {
+	PID          ardilla.PID    `json:"pid"`
+	HighContrast bool           `json:"high_contrast,omitempty"`
+	Dither       ardilla.Dither `json:"dither,omitempty"`
+	Sharpen      float64        `json:"sharpen,omitempty"`
+}
+
+// matches returns whether images rendered for t may be used by d.
+// Images rendered for a model may be used by all its variants.
+func (t Target) matches(d *ardilla.Deck) bool {
+	info, ok := deviceInfo(t.PID)
+	if !ok || info.Canonical != d.Capabilities().Canonical {
+		return false
+	}
+	return t.HighContrast == d.HighContrast() && t.Dither == d.Dither() && t.Sharpen == d.Sharpen()
+}
+
+// options returns the rendering options for t.
+func (t Target) options() []ardilla.Option {
+	return []ardilla.Option{
+		ardilla.WithHighContrast(t.HighContrast),
+		ardilla.WithDither(t.Dither),
+		ardilla.WithSharpen(t.Sharpen),
+	}
+}
+
+// bundle is the content of a layout bundle.
+type bundle struct {
+	assets  map[string][]byte
+	targets []Target
+
+	// raw is the pre-rendered image data for each
+	// target, keyed by "row,col" or bundleBlank.
+	raw []map[string][]byte
+}
+
+// WriteBundle writes l to w as a layout bundle compressed with the named
+// compression method. The key images of the layout are pre-rendered for
+// each of the targets. Masked keys and keys not described by the layout
+// use a pre-rendered black image.
+func WriteBundle(w io.Writer, l *Layout, compression string, targets ...Target) error {
+	err := l.checkMasked()
+	if err != nil {
+		return err
+	}
+	c, err := compressionFor(compression)
+	if err != nil {
+		return err
+	}
+
+	// Collect the image files used by the layout and rename
+	// them within the bundle.
+	bl := *l
+	bl.Keys = append([]Key(nil), l.Keys...)
+	bl.dir = ""
+	bl.bundle = nil
+	assets := make(map[string][]byte)
+	names := make(map[string]string)
+	asset := func(path string) (string, error) {
+		src := l.path(path)
+		name, ok := names[src]
+		if !ok {
+			b, err := l.readFile(path)
+			if err != nil {
+				return "", err
+			}
+			name = bundleAssets + strconv.Itoa(len(names)) + strings.ToLower(filepath.Ext(path))
+			names[src] = name
+			assets[name] = b
+		}
+		return name, nil
+	}
+	for i, k := range bl.Keys {
+		if k.Image != "" {
+			name, err := asset(k.Image)
+			if err != nil {
+				return fmt.Errorf("key %d,%d: %w", k.Row, k.Col, err)
+			}
+			bl.Keys[i].Image = name
+		}
+		if k.Button == nil {
+			continue
+		}
+		bl.Keys[i].Button = k.Button.clone()
+		for j, s := range k.Button.States {
+			if s.Image == "" {
+				continue
+			}
+			name, err := asset(s.Image)
+			if err != nil {
+				return fmt.Errorf("key %d,%d: state %d: %w", k.Row, k.Col, j, err)
+			}
+			bl.Keys[i].Button.States[j].Image = name
+		}
+	}
+	layoutData, err := Marshal(&bl)
+	if err != nil {
+		return err
+	}
+	targetData, err := json.MarshalIndent(targets, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, bundleMagic+compression+"\n")
+	if err != nil {
+		return err
+	}
+	cw, err := c.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(cw)
+	write := func(name string, data []byte) error {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data))})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+	err = write(bundleLayout, layoutData)
+	if err != nil {
+		return err
+	}
+	err = write(bundleTargets, targetData)
+	if err != nil {
+		return err
+	}
+	assetNames := make([]string, 0, len(assets))
+	for name := range assets {
+		assetNames = append(assetNames, name)
+	}
+	sort.Strings(assetNames)
+	for _, name := range assetNames {
+		err = write(name, assets[name])
+		if err != nil {
+			return err
+		}
+	}
+	for i, t := range targets {
+		info, ok := deviceInfo(t.PID)
+		if !ok || !info.Visual {
+			return fmt.Errorf("target %d: %s does not display images", i, t.PID)
+		}
+		bounds := image.Rectangle{Max: info.KeySize}
+		dir := bundleRaw + strconv.Itoa(i) + "/"
+		for _, k := range l.Keys {
+			if k.Row < 0 || info.Rows <= k.Row || k.Col < 0 || info.Cols <= k.Col {
+				return fmt.Errorf("target %d: key %d,%d out of bounds for %s", i, k.Row, k.Col, t.PID)
+			}
+			img, err := l.image(k, bounds)
+			if err != nil {
+				return fmt.Errorf("key %d,%d: %w", k.Row, k.Col, err)
+			}
+			raw, err := ardilla.Render(t.PID, img, t.options()...)
+			if err != nil {
+				return fmt.Errorf("target %d: key %d,%d: %w", i, k.Row, k.Col, err)
+			}
+			err = write(dir+rawName(k.Row, k.Col), raw.Data())
+			if err != nil {
+				return err
+			}
+		}
+		raw, err := ardilla.Render(t.PID, Fill(bounds, color.Black), t.options()...)
+		if err != nil {
+			return fmt.Errorf("target %d: %w", i, err)
+		}
+		err = write(dir+bundleBlank, raw.Data())
+		if err != nil {
+			return err
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+// compressionFor returns the named compression method, rejecting names
+// that cannot be written in a bundle header.
+func compressionFor(name string) (Compression, error) {
+	if name == "" || strings.Contains(name, "\n") {
+		return Compression{}, fmt.Errorf("invalid bundle compression: %q", name)
+	}
+	return compression(name)
+}
+
+// deviceInfo returns the description of the device model with the given
+// product ID.
+func deviceInfo(pid ardilla.PID) (ardilla.DeviceInfo, bool) {
+	for _, info := range ardilla.Devices() {
+		if info.PID == pid {
+			return info, true
+		}
+	}
+	return ardilla.DeviceInfo{}, false
+}
+
+// rawName returns the bundle entry name for the key at row and col.
+func rawName(row, col int) string {
+	return strconv.Itoa(row) + "," + strconv.Itoa(col)
+}
+
+// isBundle returns whether data begins with the bundle header line.
+func isBundle(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(bundleMagic))
+}
+
+// ReadBundle returns the layout held in the layout bundle read from r.
+func ReadBundle(r io.Reader) (*Layout, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.ReadString('\n')
+	if err != nil || magic != bundleMagic {
+		return nil, errors.New("not a layout bundle")
+	}
+	name, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle header: %w", err)
+	}
+	c, err := compression(strings.TrimSuffix(name, "\n"))
+	if err != nil {
+		return nil, err
+	}
+	cr, err := c.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	defer cr.Close()
+
+	var (
+		layoutData, targetData []byte
+		b                      = &bundle{assets: make(map[string][]byte)}
+		raw                    = make(map[string][]byte)
+	)
+	tr := tar.NewReader(cr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		switch name := path.Clean(hdr.Name); {
+		case name == bundleLayout:
+			layoutData = data
+		case name == bundleTargets:
+			targetData = data
+		case strings.HasPrefix(name, bundleAssets):
+			b.assets[name] = data
+		case strings.HasPrefix(name, bundleRaw):
+			raw[strings.TrimPrefix(name, bundleRaw)] = data
+		default:
+			return nil, fmt.Errorf("unexpected bundle entry: %q", hdr.Name)
+		}
+	}
+	if layoutData == nil {
+		return nil, errors.New("bundle missing layout")
+	}
+	l, err := Unmarshal(layoutData)
+	if err != nil {
+		return nil, err
+	}
+	if targetData != nil {
+		err = json.Unmarshal(targetData, &b.targets)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle targets: %w", err)
+		}
+	}
+	b.raw = make([]map[string][]byte, len(b.targets))
+	for name, data := range raw {
+		idx, key, ok := strings.Cut(name, "/")
+		i, err := strconv.Atoi(idx)
+		if !ok || err != nil || i < 0 || len(b.targets) <= i {
+			return nil, fmt.Errorf("invalid bundle image entry: %q", bundleRaw+name)
+		}
+		if b.raw[i] == nil {
+			b.raw[i] = make(map[string][]byte)
+		}
+		b.raw[i][key] = data
+	}
+	for _, k := range l.Keys {
+		if k.Image != "" && b.assets[k.Image] == nil {
+			return nil, fmt.Errorf("key %d,%d: missing bundle image: %q", k.Row, k.Col, k.Image)
+		}
+		if k.Button == nil {
+			continue
+		}
+		for i, s := range k.Button.States {
+			if s.Image != "" && b.assets[s.Image] == nil {
+				return nil, fmt.Errorf("key %d,%d: state %d: missing bundle image: %q", k.Row, k.Col, i, s.Image)
+			}
+		}
+	}
+	l.bundle = b
+	return l, nil
+}
+
+// prerendered returns the pre-rendered image data in the receiver's bundle
+// for d for the given key name, if it exists.
+func (l *Layout) prerendered(d *ardilla.Deck, name string) ([]byte, bool) {
+	if l.bundle == nil {
+		return nil, false
+	}
+	for i, t := range l.bundle.targets {
+		if !t.matches(d) {
+			continue
+		}
+		data, ok := l.bundle.raw[i][name]
+		if ok {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// lazyImage is an image that is only loaded when it is first used.
+type lazyImage struct {
+	once sync.Once
+	load func() (image.Image, error)
+	img  image.Image
+}
+
+func (i *lazyImage) image() image.Image {
+	i.once.Do(func() {
+		img, err := i.load()
+		if err != nil {
+			// Show an obviously broken image rather than
+			// failing, since image.Image cannot return an
+			// error.
+			broken := image.NewRGBA(image.Rect(0, 0, 1, 1))
+			broken.SetRGBA(0, 0, color.RGBA{R: 0xff, A: 0xff})
+			img = broken
+		}
+		i.img = img
+	})
+	return i.img
+}
+
+func (i *lazyImage) ColorModel() color.Model { return i.image().ColorModel() }
+func (i *lazyImage) Bounds() image.Rectangle { return i.image().Bounds() }
+func (i *lazyImage) At(x, y int) color.Color { return i.image().At(x, y) }
@@ -0,0 +1,169 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package layout
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kortschak/ardilla"
+)
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+	icon := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := range icon.Pix {
+		icon.Pix[i] = uint8(i)
+	}
+	var buf bytes.Buffer
+	err := png.Encode(&buf, icon)
+	if err != nil {
+		t.Fatalf("unexpected error encoding icon: %v", err)
+	}
+	iconData := buf.Bytes()
+	err = os.WriteFile(filepath.Join(dir, "icon.png"), iconData, 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error writing icon: %v", err)
+	}
+
+	l := &Layout{
+		Keys: []Key{
+			{Row: 0, Col: 0, Image: "icon.png"},
+			{Row: 0, Col: 1, Color: "#f80"},
+			{Row: 1, Col: 2, Image: "icon.png"},
+			{Row: 0, Col: 2, Color: "red", Button: &Button{
+				Kind: Toggle, Name: "mic",
+				States: []ButtonState{{Color: "red"}, {Image: "icon.png"}},
+			}},
+		},
+		Masked: []Position{{Row: 1, Col: 0}},
+		dir:    dir,
+	}
+	targets := []Target{
+		{PID: ardilla.StreamDeckMini},
+		{PID: ardilla.StreamDeckMK2, HighContrast: true, Sharpen: 0.5},
+	}
+
+	for _, compression := range []string{"none", "gzip"} {
+		t.Run(compression, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteBundle(&buf, l, compression, targets...)
+			if err != nil {
+				t.Fatalf("unexpected error writing bundle: %v", err)
+			}
+			if !isBundle(buf.Bytes()) {
+				t.Fatal("bundle not recognised")
+			}
+			path := filepath.Join(t.TempDir(), "layout.bundle")
+			err = os.WriteFile(path, buf.Bytes(), 0o644)
+			if err != nil {
+				t.Fatalf("unexpected error writing bundle file: %v", err)
+			}
+
+			got, err := Load(path)
+			if err != nil {
+				t.Fatalf("unexpected error loading bundle: %v", err)
+			}
+			if got.bundle == nil {
+				t.Fatal("bundle content not retained")
+			}
+			if files := got.Files(); files != nil {
+				t.Errorf("unexpected files for bundled layout: %q", files)
+			}
+			if !reflect.DeepEqual(got.Masked, l.Masked) {
+				t.Errorf("unexpected masked keys: got:%v want:%v", got.Masked, l.Masked)
+			}
+			if got.Keys[0].Image != got.Keys[2].Image {
+				t.Errorf("shared image not deduplicated: %q %q", got.Keys[0].Image, got.Keys[2].Image)
+			}
+			if len(got.bundle.assets) != 1 {
+				t.Errorf("unexpected number of assets: got:%d want:1", len(got.bundle.assets))
+			}
+			b, err := got.readFile(got.Keys[0].Image)
+			if err != nil {
+				t.Fatalf("unexpected error reading bundled image: %v", err)
+			}
+			if !bytes.Equal(b, iconData) {
+				t.Error("bundled image does not match source")
+			}
+			if l.Keys[3].Button.States[1].Image != "icon.png" {
+				t.Errorf("source button state image altered: %q", l.Keys[3].Button.States[1].Image)
+			}
+			if got.Keys[3].Button.States[1].Image != got.Keys[0].Image {
+				t.Errorf("button state image not deduplicated: %q %q", got.Keys[3].Button.States[1].Image, got.Keys[0].Image)
+			}
+			imgs, err := got.ButtonImages(got.Keys[3], image.Rect(0, 0, 72, 72))
+			if err != nil {
+				t.Fatalf("unexpected error reading bundled button images: %v", err)
+			}
+			if imgs[1].Bounds() != icon.Bounds() {
+				t.Errorf("unexpected bundled button state image bounds: got:%v want:%v", imgs[1].Bounds(), icon.Bounds())
+			}
+			if !reflect.DeepEqual(got.bundle.targets, targets) {
+				t.Errorf("unexpected targets: got:%+v want:%+v", got.bundle.targets, targets)
+			}
+
+			for i, target := range targets {
+				info, _ := deviceInfo(target.PID)
+				bounds := image.Rectangle{Max: info.KeySize}
+				for _, k := range l.Keys {
+					img, err := l.image(k, bounds)
+					if err != nil {
+						t.Fatalf("unexpected error rendering key %d,%d: %v", k.Row, k.Col, err)
+					}
+					want, err := ardilla.Render(target.PID, img, target.options()...)
+					if err != nil {
+						t.Fatalf("unexpected error rendering key %d,%d: %v", k.Row, k.Col, err)
+					}
+					data := got.bundle.raw[i][rawName(k.Row, k.Col)]
+					if !bytes.Equal(data, want.Data()) {
+						t.Errorf("unexpected pre-rendered image for target %d key %d,%d", i, k.Row, k.Col)
+					}
+				}
+				want, err := ardilla.Render(target.PID, Fill(bounds, color.Black), target.options()...)
+				if err != nil {
+					t.Fatalf("unexpected error rendering blank: %v", err)
+				}
+				if !bytes.Equal(got.bundle.raw[i][bundleBlank], want.Data()) {
+					t.Errorf("unexpected pre-rendered blank for target %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestBundleErrors(t *testing.T) {
+	l := &Layout{Keys: []Key{{Row: 0, Col: 0, Color: "white"}}}
+
+	var buf bytes.Buffer
+	err := WriteBundle(&buf, l, "brotli")
+	if want := errors.New(`unknown bundle compression: "brotli"`); !sameError(err, want) {
+		t.Errorf("unexpected error for unknown compression: got:%v want:%v", err, want)
+	}
+
+	err = WriteBundle(&buf, l, "none", Target{PID: ardilla.StreamDeckPedal})
+	if want := errors.New("target 0: StreamDeckPedal does not display images"); !sameError(err, want) {
+		t.Errorf("unexpected error for non-visual target: got:%v want:%v", err, want)
+	}
+
+	buf.Reset()
+	buf.WriteString(bundleMagic + "brotli\n")
+	_, err = ReadBundle(&buf)
+	if want := errors.New(`unknown bundle compression: "brotli"`); !sameError(err, want) {
+		t.Errorf("unexpected error for unknown compression: got:%v want:%v", err, want)
+	}
+
+	_, err = ReadBundle(bytes.NewReader([]byte(`{"keys":[]}`)))
+	if want := errors.New("not a layout bundle"); !sameError(err, want) {
+		t.Errorf("unexpected error for non-bundle: got:%v want:%v", err, want)
+	}
+}
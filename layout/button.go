@@ -0,0 +1,121 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// Button kinds.
+const (
+	Toggle = "toggle"
+	Cycle  = "cycle"
+)
+
+// Button describes a stateful key. Each press moves the button to its
+// next state, running the command of the new state and showing its image.
+// The button's state is persisted under its name, so that it is restored
+// when the layout is next used. Buttons are bound to a deck's actions by
+// the action package.
+//
+// For example, a microphone mute toggle is described by
+//
+//	{
+//		"row": 0, "col": 0, "image": "mic.png",
+//		"button": {
+//			"kind": "toggle",
+//			"name": "mic",
+//			"states": [
+//				{"image": "mic.png", "command": ["pactl", "set-source-mute", "@DEFAULT_SOURCE@", "0"]},
+//				{"image": "mic-muted.png", "command": ["pactl", "set-source-mute", "@DEFAULT_SOURCE@", "1"]}
+//			]
+//		}
+//	}
+type Button struct {
+	// Kind is the kind of button, Toggle or Cycle.
+	// A toggle has two states, off and on in that
+	// order. A cycle has two or more states and
+	// moves from its last state to its first.
+	Kind string `json:"kind"`
+
+	// Name identifies the button's persisted state.
+	// Buttons with the same name share their state.
+	Name string `json:"name"`
+
+	// States are the button's states. The first
+	// state is the initial state.
+	States []ButtonState `json:"states"`
+}
+
+// ButtonState is a state of a Button.
+type ButtonState struct {
+	// Image and Color are the content of the key in
+	// the state, as for Key.
+	Image string `json:"image,omitempty"`
+	Color string `json:"color,omitempty"`
+
+	// Command is the program and its arguments run
+	// when the button enters the state. If Command
+	// is empty, no program is run.
+	Command []string `json:"command,omitempty"`
+}
+
+// validate returns an error if the button is not valid.
+func (b *Button) validate() error {
+	switch b.Kind {
+	case Toggle:
+		if len(b.States) != 2 {
+			return fmt.Errorf("toggle must have 2 states: %d", len(b.States))
+		}
+	case Cycle:
+		if len(b.States) < 2 {
+			return fmt.Errorf("cycle must have at least 2 states: %d", len(b.States))
+		}
+	default:
+		return fmt.Errorf("invalid button kind: %q", b.Kind)
+	}
+	if b.Name == "" {
+		return errors.New("missing button name")
+	}
+	for i, s := range b.States {
+		if s.Image == "" && s.Color != "" {
+			_, err := ParseColor(s.Color)
+			if err != nil {
+				return fmt.Errorf("state %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// clone returns a copy of b that does not share its states.
+func (b *Button) clone() *Button {
+	if b == nil {
+		return nil
+	}
+	c := *b
+	c.States = append([]ButtonState(nil), b.States...)
+	return &c
+}
+
+// ButtonImages returns the images of the states of the button of k, with
+// solid colour images rendered with the provided bounds. It returns an
+// error if k is not a button.
+func (l *Layout) ButtonImages(k Key, bounds image.Rectangle) ([]image.Image, error) {
+	if k.Button == nil {
+		return nil, fmt.Errorf("key %d,%d: not a button", k.Row, k.Col)
+	}
+	imgs := make([]image.Image, len(k.Button.States))
+	for i, s := range k.Button.States {
+		img, err := l.image(Key{Row: k.Row, Col: k.Col, Image: s.Image, Color: s.Color}, bounds)
+		if err != nil {
+			return nil, fmt.Errorf("key %d,%d: state %d: %w", k.Row, k.Col, i, err)
+		}
+		imgs[i] = img
+	}
+	return imgs, nil
+}
@@ -0,0 +1,361 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package layout provides a serialisation format for Stream Deck key
+// layouts.
+package layout
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kortschak/ardilla"
+)
+
+// Layout is a complete description of the content of a Stream Deck.
+type Layout struct {
+	// Brightness is the screen brightness percentage to set. If
+	// Brightness is nil, the device brightness is left unchanged.
+	Brightness *int `json:"brightness,omitempty"`
+
+	// Keys is the set of key contents. Keys not described in the
+	// layout are cleared when the layout is applied.
+	Keys []Key `json:"keys,omitempty"`
+
+	// Masked is the set of disabled keys. Masked keys are kept
+	// black and may not be given content in Keys. Events from
+	// masked keys are suppressed by Events and are reported by
+	// IsMasked so that other event consumers can ignore them.
+	Masked []Position `json:"masked,omitempty"`
+
+	// dir is the directory relative paths are resolved against.
+	dir string
+
+	// bundle holds the images of a layout read
+	// from a bundle. Image paths in a bundled
+	// layout refer to entries in the bundle.
+	bundle *bundle
+}
+
+// Key is the content of a single Stream Deck key.
+type Key struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+
+	// Image is the path to an image file to render on the key.
+	// Relative paths are resolved relative to the directory holding
+	// the layout file. Image decoders must be registered by the
+	// program using the layout.
+	Image string `json:"image,omitempty"`
+
+	// Color is a colour to fill the key with, in #rgb or #rrggbb
+	// notation or a colour name accepted by ParseColor. Color is
+	// ignored if Image is not empty.
+	Color string `json:"color,omitempty"`
+
+	// Button makes the key a stateful button. The
+	// key's Image or Color is shown when the layout
+	// is applied, and the image of the button's
+	// current state when the button is bound.
+	Button *Button `json:"button,omitempty"`
+}
+
+// Position is the location of a key.
+type Position struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+// IsMasked returns whether the key at row and col is masked.
+func (l *Layout) IsMasked(row, col int) bool {
+	for _, p := range l.Masked {
+		if p.Row == row && p.Col == col {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMasked returns an error if any key with content is masked.
+func (l *Layout) checkMasked() error {
+	for _, k := range l.Keys {
+		if l.IsMasked(k.Row, k.Col) {
+			return fmt.Errorf("key %d,%d: masked key has content", k.Row, k.Col)
+		}
+	}
+	return nil
+}
+
+// Load reads the layout held in the JSON file or layout bundle at path.
+func Load(path string) (*Layout, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isBundle(b) {
+		l, err := ReadBundle(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return l, nil
+	}
+	l, err := Unmarshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	l.dir = filepath.Dir(path)
+	return l, nil
+}
+
+// Unmarshal returns the layout encoded in the JSON data. Relative image
+// paths in the returned layout are resolved relative to the current
+// working directory.
+func Unmarshal(data []byte) (*Layout, error) {
+	var l Layout
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&l)
+	if err != nil {
+		return nil, err
+	}
+	if l.Brightness != nil && (*l.Brightness < 0 || 100 < *l.Brightness) {
+		return nil, fmt.Errorf("brightness out of range: %d", *l.Brightness)
+	}
+	for _, k := range l.Keys {
+		if k.Image == "" && k.Color != "" {
+			_, err = ParseColor(k.Color)
+			if err != nil {
+				return nil, fmt.Errorf("key %d,%d: %w", k.Row, k.Col, err)
+			}
+		}
+		if k.Button != nil {
+			err = k.Button.validate()
+			if err != nil {
+				return nil, fmt.Errorf("key %d,%d: %w", k.Row, k.Col, err)
+			}
+		}
+	}
+	err = l.checkMasked()
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Marshal returns the JSON encoding of the layout.
+func Marshal(l *Layout) ([]byte, error) {
+	b, err := json.MarshalIndent(l, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// Apply renders the layout onto the provided deck. Masked keys are
+// cleared. If the layout was read from a bundle with images pre-rendered
+// for the deck's model and rendering settings, the pre-rendered images
+// are used.
+func (l *Layout) Apply(d *ardilla.Deck) error {
+	err := l.checkMasked()
+	if err != nil {
+		return err
+	}
+	if l.Brightness != nil {
+		err := d.SetBrightness(*l.Brightness)
+		if err != nil {
+			return err
+		}
+	}
+	if len(l.Keys) == 0 && len(l.Masked) == 0 {
+		// Nothing to render, and non-visual devices
+		// may be configured with an empty layout.
+		return nil
+	}
+	bounds, err := d.Bounds()
+	if err != nil {
+		return err
+	}
+	rows, cols := d.Layout()
+	for _, p := range l.Masked {
+		if p.Row < 0 || rows <= p.Row {
+			return fmt.Errorf("masked row out of bounds: %d", p.Row)
+		}
+		if p.Col < 0 || cols <= p.Col {
+			return fmt.Errorf("masked column out of bounds: %d", p.Col)
+		}
+	}
+	set := make([]bool, rows*cols)
+	for _, k := range l.Keys {
+		if k.Row < 0 || rows <= k.Row {
+			return fmt.Errorf("row out of bounds: %d", k.Row)
+		}
+		if k.Col < 0 || cols <= k.Col {
+			return fmt.Errorf("column out of bounds: %d", k.Col)
+		}
+		img, err := l.keyImage(d, k, bounds)
+		if err != nil {
+			return fmt.Errorf("key %d,%d: %w", k.Row, k.Col, err)
+		}
+		err = d.SetImage(k.Row, k.Col, img)
+		if err != nil {
+			return fmt.Errorf("key %d,%d: %w", k.Row, k.Col, err)
+		}
+		set[d.Key(k.Row, k.Col)] = true
+	}
+	var black image.Image = Fill(bounds, color.Black)
+	if data, ok := l.prerendered(d, bundleBlank); ok {
+		black, err = d.RawImageData(black, data)
+		if err != nil {
+			return err
+		}
+	}
+	for key, ok := range set {
+		if ok {
+			continue
+		}
+		err := d.SetImage(key/cols, key%cols, black)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bundled returns whether the layout was read from a layout bundle.
+func (l *Layout) Bundled() bool {
+	return l.bundle != nil
+}
+
+// Files returns the paths of the image files used by the layout. Layouts
+// read from a bundle have no image files.
+func (l *Layout) Files() []string {
+	if l.bundle != nil {
+		return nil
+	}
+	var paths []string
+	for _, k := range l.Keys {
+		if k.Image != "" {
+			paths = append(paths, l.path(k.Image))
+		}
+		if k.Button == nil {
+			continue
+		}
+		for _, s := range k.Button.States {
+			if s.Image != "" {
+				paths = append(paths, l.path(s.Image))
+			}
+		}
+	}
+	return paths
+}
+
+// path returns the path to the named file, resolving relative paths
+// against the layout's directory.
+func (l *Layout) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(l.dir, name)
+}
+
+// keyImage returns the image described by k for d, using a pre-rendered
+// image from the layout's bundle if one is available.
+func (l *Layout) keyImage(d *ardilla.Deck, k Key, bounds image.Rectangle) (image.Image, error) {
+	data, ok := l.prerendered(d, rawName(k.Row, k.Col))
+	if !ok {
+		return l.image(k, bounds)
+	}
+	orig := &lazyImage{load: func() (image.Image, error) {
+		return l.image(k, bounds)
+	}}
+	return d.RawImageData(orig, data)
+}
+
+// image returns the image described by k. Solid colour images are
+// rendered with the provided bounds.
+func (l *Layout) image(k Key, bounds image.Rectangle) (image.Image, error) {
+	if k.Image == "" {
+		if k.Color == "" {
+			return Fill(bounds, color.Black), nil
+		}
+		c, err := ParseColor(k.Color)
+		if err != nil {
+			return nil, err
+		}
+		return Fill(bounds, c), nil
+	}
+	b, err := l.readFile(k.Image)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	return img, err
+}
+
+// readFile returns the contents of the named image file, reading from the
+// layout's bundle if it has one.
+func (l *Layout) readFile(name string) ([]byte, error) {
+	if l.bundle != nil {
+		b, ok := l.bundle.assets[name]
+		if !ok {
+			return nil, fmt.Errorf("missing bundle image: %q", name)
+		}
+		return b, nil
+	}
+	return os.ReadFile(l.path(name))
+}
+
+// Fill returns an image with the given bounds filled with c.
+func Fill(bounds image.Rectangle, c color.Color) image.Image {
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+// ParseColor returns the colour described by s in #rgb or #rrggbb
+// notation, or by one of the names black, white, grey, red, green, blue,
+// yellow, cyan, magenta or orange.
+func ParseColor(s string) (color.NRGBA, error) {
+	if c, ok := namedColors[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+	if !strings.HasPrefix(s, "#") {
+		return color.NRGBA{}, fmt.Errorf("invalid colour: %q", s)
+	}
+	hex := s[1:]
+	switch len(hex) {
+	case 3:
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	case 6:
+	default:
+		return color.NRGBA{}, fmt.Errorf("invalid colour: %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid colour: %q", s)
+	}
+	return color.NRGBA{R: byte(v >> 16), G: byte(v >> 8), B: byte(v), A: 0xff}, nil
+}
+
+var namedColors = map[string]color.NRGBA{
+	"black":   {A: 0xff},
+	"white":   {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	"grey":    {R: 0x80, G: 0x80, B: 0x80, A: 0xff},
+	"gray":    {R: 0x80, G: 0x80, B: 0x80, A: 0xff},
+	"red":     {R: 0xff, A: 0xff},
+	"green":   {G: 0xff, A: 0xff},
+	"blue":    {B: 0xff, A: 0xff},
+	"yellow":  {R: 0xff, G: 0xff, A: 0xff},
+	"cyan":    {G: 0xff, B: 0xff, A: 0xff},
+	"magenta": {R: 0xff, B: 0xff, A: 0xff},
+	"orange":  {R: 0xff, G: 0xa5, A: 0xff},
+}
@@ -0,0 +1,32 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package layout
+
+import (
+	"context"
+	"iter"
+
+	"github.com/kortschak/ardilla"
+)
+
+// Events returns an iterator over the key events of d as for Deck.Events,
+// with events from masked keys removed. Lagged and error events are
+// always included.
+//
+// Events requires Go 1.23.
+func (l *Layout) Events(ctx context.Context, d *ardilla.Deck) iter.Seq[ardilla.KeyEvent] {
+	return func(yield func(ardilla.KeyEvent) bool) {
+		for ev := range d.Events(ctx) {
+			if ev.Err == nil && ev.Lagged == 0 && l.IsMasked(ev.Row, ev.Col) {
+				continue
+			}
+			if !yield(ev) {
+				return
+			}
+		}
+	}
+}
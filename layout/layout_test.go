@@ -0,0 +1,206 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package layout
+
+import (
+	"errors"
+	"image/color"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var parseColorTests = []struct {
+	in      string
+	want    color.NRGBA
+	wantErr error
+}{
+	{in: "#ff8800", want: color.NRGBA{R: 0xff, G: 0x88, B: 0x00, A: 0xff}},
+	{in: "#F80", want: color.NRGBA{R: 0xff, G: 0x88, B: 0x00, A: 0xff}},
+	{in: "#000", want: color.NRGBA{A: 0xff}},
+	{in: "white", want: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+	{in: "Orange", want: color.NRGBA{R: 0xff, G: 0xa5, A: 0xff}},
+	{in: "chartreuse", wantErr: errors.New(`invalid colour: "chartreuse"`)},
+	{in: "ff8800", wantErr: errors.New(`invalid colour: "ff8800"`)},
+	{in: "#ff880", wantErr: errors.New(`invalid colour: "#ff880"`)},
+	{in: "#gg8800", wantErr: errors.New(`invalid colour: "#gg8800"`)},
+}
+
+func TestParseColor(t *testing.T) {
+	for _, test := range parseColorTests {
+		got, err := ParseColor(test.in)
+		if !sameError(err, test.wantErr) {
+			t.Errorf("unexpected error for %q: got:%v want:%v", test.in, err, test.wantErr)
+		}
+		if got != test.want {
+			t.Errorf("unexpected result for %q: got:%v want:%v", test.in, got, test.want)
+		}
+	}
+}
+
+var unmarshalTests = []struct {
+	in      string
+	want    *Layout
+	wantErr error
+}{
+	{
+		in: `{"brightness": 50, "keys": [{"row": 1, "col": 2, "color": "#fff"}, {"row": 0, "col": 0, "image": "icon.png"}]}`,
+		want: &Layout{
+			Brightness: intPtr(50),
+			Keys: []Key{
+				{Row: 1, Col: 2, Color: "#fff"},
+				{Row: 0, Col: 0, Image: "icon.png"},
+			},
+		},
+	},
+	{
+		in:   `{}`,
+		want: &Layout{},
+	},
+	{
+		in:      `{"brightness": 101}`,
+		wantErr: errors.New("brightness out of range: 101"),
+	},
+	{
+		in:      `{"keys": [{"row": 1, "col": 2, "color": "fff"}]}`,
+		wantErr: errors.New(`key 1,2: invalid colour: "fff"`),
+	},
+	{
+		in:      `{"keys": [{"row": 1, "col": 2, "colour": "#fff"}]}`,
+		wantErr: errors.New(`json: unknown field "colour"`),
+	},
+	{
+		in: `{"keys": [{"row": 0, "col": 0, "color": "#fff"}], "masked": [{"row": 1, "col": 2}]}`,
+		want: &Layout{
+			Keys:   []Key{{Row: 0, Col: 0, Color: "#fff"}},
+			Masked: []Position{{Row: 1, Col: 2}},
+		},
+	},
+	{
+		in:      `{"keys": [{"row": 1, "col": 2, "color": "#fff"}], "masked": [{"row": 1, "col": 2}]}`,
+		wantErr: errors.New("key 1,2: masked key has content"),
+	},
+	{
+		in: `{"keys": [{"row": 0, "col": 0, "button": {"kind": "cycle", "name": "scene", "states": [{"color": "red", "command": ["obs", "scene", "1"]}, {"image": "two.png"}, {"color": "#00f"}]}}]}`,
+		want: &Layout{
+			Keys: []Key{{Row: 0, Col: 0, Button: &Button{
+				Kind: Cycle,
+				Name: "scene",
+				States: []ButtonState{
+					{Color: "red", Command: []string{"obs", "scene", "1"}},
+					{Image: "two.png"},
+					{Color: "#00f"},
+				},
+			}}},
+		},
+	},
+	{
+		in:      `{"keys": [{"row": 0, "col": 1, "button": {"kind": "toggle", "name": "mic", "states": [{"color": "red"}]}}]}`,
+		wantErr: errors.New("key 0,1: toggle must have 2 states: 1"),
+	},
+	{
+		in:      `{"keys": [{"row": 0, "col": 1, "button": {"kind": "cycle", "name": "mic", "states": [{"color": "red"}]}}]}`,
+		wantErr: errors.New("key 0,1: cycle must have at least 2 states: 1"),
+	},
+	{
+		in:      `{"keys": [{"row": 0, "col": 1, "button": {"kind": "radio", "name": "mic"}}]}`,
+		wantErr: errors.New(`key 0,1: invalid button kind: "radio"`),
+	},
+	{
+		in:      `{"keys": [{"row": 0, "col": 1, "button": {"kind": "toggle", "states": [{}, {}]}}]}`,
+		wantErr: errors.New("key 0,1: missing button name"),
+	},
+	{
+		in:      `{"keys": [{"row": 0, "col": 1, "button": {"kind": "toggle", "name": "mic", "states": [{}, {"color": "fff"}]}}]}`,
+		wantErr: errors.New(`key 0,1: state 1: invalid colour: "fff"`),
+	},
+}
+
+func TestUnmarshal(t *testing.T) {
+	for _, test := range unmarshalTests {
+		got, err := Unmarshal([]byte(test.in))
+		if !sameError(err, test.wantErr) {
+			t.Errorf("unexpected error for %s: got:%v want:%v", test.in, err, test.wantErr)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("unexpected result for %s:\ngot: %#v\nwant:%#v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	for _, test := range unmarshalTests {
+		if test.wantErr != nil {
+			continue
+		}
+		b, err := Marshal(test.want)
+		if err != nil {
+			t.Errorf("unexpected error marshaling %#v: %v", test.want, err)
+			continue
+		}
+		got, err := Unmarshal(b)
+		if err != nil {
+			t.Errorf("unexpected error unmarshaling %s: %v", b, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("unexpected round trip result for %s:\ngot: %#v\nwant:%#v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	l := &Layout{
+		Keys: []Key{
+			{Row: 0, Col: 0, Image: "icon.png"},
+			{Row: 0, Col: 1, Color: "#fff"},
+			{Row: 0, Col: 2, Image: "/abs/icon.png"},
+			{Row: 1, Col: 0, Image: "sub/icon.png"},
+			{Row: 1, Col: 1, Button: &Button{States: []ButtonState{{Image: "off.png"}, {Color: "red"}, {Image: "on.png"}}}},
+		},
+		dir: "/layouts",
+	}
+	want := []string{
+		filepath.Join("/layouts", "icon.png"),
+		"/abs/icon.png",
+		filepath.Join("/layouts", "sub", "icon.png"),
+		filepath.Join("/layouts", "off.png"),
+		filepath.Join("/layouts", "on.png"),
+	}
+	got := l.Files()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected result for Files:\ngot: %q\nwant:%q", got, want)
+	}
+}
+
+func TestIsMasked(t *testing.T) {
+	l := &Layout{Masked: []Position{{Row: 0, Col: 1}, {Row: 2, Col: 4}}}
+	for _, test := range []struct {
+		row, col int
+		want     bool
+	}{
+		{row: 0, col: 1, want: true},
+		{row: 2, col: 4, want: true},
+		{row: 1, col: 0, want: false},
+		{row: 0, col: 0, want: false},
+	} {
+		if got := l.IsMasked(test.row, test.col); got != test.want {
+			t.Errorf("unexpected result for IsMasked(%d, %d): got:%t want:%t", test.row, test.col, got, test.want)
+		}
+	}
+}
+
+func intPtr(i int) *int { return &i }
+
+func sameError(a, b error) bool {
+	switch {
+	case a == nil && b == nil:
+		return true
+	case a == nil, b == nil, a.Error() != b.Error():
+		return false
+	default:
+		return true
+	}
+}
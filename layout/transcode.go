@@ -0,0 +1,137 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package layout
+
+import (
+	"fmt"
+
+	"github.com/kortschak/ardilla"
+)
+
+// Placement is a strategy for positioning keys when a layout is
+// transcoded from one device model to another.
+type Placement int
+
+const (
+	// Anchor keeps keys at their original row and
+	// column, anchoring the layout at the top left
+	// of the destination.
+	Anchor Placement = iota
+
+	// Center places the block of keys in the centre
+	// of the destination. When the difference in
+	// size is odd, the offset is rounded down.
+	Center
+
+	// Scale spreads key positions proportionally
+	// over the destination so that the layout keeps
+	// its overall shape.
+	Scale
+)
+
+func (p Placement) String() string {
+	switch p {
+	case Anchor:
+		return "anchor"
+	case Center:
+		return "center"
+	case Scale:
+		return "scale"
+	default:
+		return fmt.Sprintf("Placement(%d)", int(p))
+	}
+}
+
+// Transcode returns a copy of l, authored for the from device model,
+// retargeted to the to device model using the given placement. It is an
+// error for a key with content to be placed off the destination or for
+// two keys to be placed at the same position. Masked positions that are
+// placed off the destination are dropped.
+//
+// Key images are rendered for the destination's key size and image format
+// when the returned layout is applied or written to a bundle.
+// Pre-rendered images held by a bundled layout are not retained.
+func Transcode(l *Layout, from, to ardilla.PID, p Placement) (*Layout, error) {
+	src, ok := deviceInfo(from)
+	if !ok {
+		return nil, fmt.Errorf("%s not a valid deck device identifier", from)
+	}
+	dst, ok := deviceInfo(to)
+	if !ok {
+		return nil, fmt.Errorf("%s not a valid deck device identifier", to)
+	}
+	if !dst.Visual {
+		return nil, fmt.Errorf("%s does not display images", to)
+	}
+	var place func(row, col int) (int, int)
+	switch p {
+	case Anchor:
+		place = func(row, col int) (int, int) {
+			return row, col
+		}
+	case Center:
+		dr := floorHalf(dst.Rows - src.Rows)
+		dc := floorHalf(dst.Cols - src.Cols)
+		place = func(row, col int) (int, int) {
+			return row + dr, col + dc
+		}
+	case Scale:
+		// Map the centre of each source key to the
+		// destination key containing it.
+		place = func(row, col int) (int, int) {
+			return (2*row + 1) * dst.Rows / (2 * src.Rows), (2*col + 1) * dst.Cols / (2 * src.Cols)
+		}
+	default:
+		return nil, fmt.Errorf("invalid placement: %v", p)
+	}
+	fits := func(row, col int) bool {
+		return 0 <= row && row < dst.Rows && 0 <= col && col < dst.Cols
+	}
+
+	t := &Layout{Brightness: l.Brightness, dir: l.dir}
+	if l.bundle != nil {
+		t.bundle = &bundle{assets: l.bundle.assets}
+	}
+	used := make(map[Position]Key)
+	for _, k := range l.Keys {
+		if k.Row < 0 || src.Rows <= k.Row || k.Col < 0 || src.Cols <= k.Col {
+			return nil, fmt.Errorf("key %d,%d out of bounds for %s", k.Row, k.Col, from)
+		}
+		row, col := place(k.Row, k.Col)
+		if !fits(row, col) {
+			return nil, fmt.Errorf("key %d,%d does not fit on %s", k.Row, k.Col, to)
+		}
+		pos := Position{Row: row, Col: col}
+		if prev, ok := used[pos]; ok {
+			return nil, fmt.Errorf("keys %d,%d and %d,%d both placed at %d,%d", prev.Row, prev.Col, k.Row, k.Col, row, col)
+		}
+		used[pos] = k
+		k.Row, k.Col = row, col
+		k.Button = k.Button.clone()
+		t.Keys = append(t.Keys, k)
+	}
+	masked := make(map[Position]bool)
+	for _, m := range l.Masked {
+		row, col := place(m.Row, m.Col)
+		pos := Position{Row: row, Col: col}
+		if !fits(row, col) || masked[pos] {
+			continue
+		}
+		if _, ok := used[pos]; ok {
+			return nil, fmt.Errorf("masked key %d,%d placed at %d,%d which has content", m.Row, m.Col, row, col)
+		}
+		masked[pos] = true
+		t.Masked = append(t.Masked, pos)
+	}
+	return t, nil
+}
+
+// floorHalf returns n/2 rounded down.
+func floorHalf(n int) int {
+	if n < 0 {
+		return -((1 - n) / 2)
+	}
+	return n / 2
+}
@@ -0,0 +1,117 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package layout
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kortschak/ardilla"
+)
+
+var transcodeTests = []struct {
+	name      string
+	in        *Layout
+	from, to  ardilla.PID
+	placement Placement
+	want      *Layout
+	wantErr   error
+}{
+	{
+		name: "anchor",
+		in: &Layout{
+			Brightness: intPtr(50),
+			Keys:       []Key{{Row: 0, Col: 0, Color: "red"}, {Row: 2, Col: 4, Image: "icon.png"}},
+			Masked:     []Position{{Row: 1, Col: 1}},
+		},
+		from: ardilla.StreamDeckMK2, to: ardilla.StreamDeckXL, placement: Anchor,
+		want: &Layout{
+			Brightness: intPtr(50),
+			Keys:       []Key{{Row: 0, Col: 0, Color: "red"}, {Row: 2, Col: 4, Image: "icon.png"}},
+			Masked:     []Position{{Row: 1, Col: 1}},
+		},
+	},
+	{
+		name: "center",
+		in: &Layout{
+			Keys:   []Key{{Row: 0, Col: 0, Color: "red"}, {Row: 2, Col: 4, Image: "icon.png"}},
+			Masked: []Position{{Row: 1, Col: 1}},
+		},
+		from: ardilla.StreamDeckMK2, to: ardilla.StreamDeckXL, placement: Center,
+		want: &Layout{
+			Keys:   []Key{{Row: 0, Col: 1, Color: "red"}, {Row: 2, Col: 5, Image: "icon.png"}},
+			Masked: []Position{{Row: 1, Col: 2}},
+		},
+	},
+	{
+		name: "scale",
+		in: &Layout{
+			Keys: []Key{{Row: 0, Col: 0, Color: "red"}, {Row: 1, Col: 2, Color: "green"}, {Row: 2, Col: 4, Image: "icon.png"}},
+		},
+		from: ardilla.StreamDeckMK2, to: ardilla.StreamDeckXL, placement: Scale,
+		want: &Layout{
+			Keys: []Key{{Row: 0, Col: 0, Color: "red"}, {Row: 2, Col: 4, Color: "green"}, {Row: 3, Col: 7, Image: "icon.png"}},
+		},
+	},
+	{
+		name: "center_smaller",
+		in: &Layout{
+			Keys:   []Key{{Row: 1, Col: 1, Color: "red"}, {Row: 1, Col: 3, Color: "blue"}},
+			Masked: []Position{{Row: 0, Col: 0}, {Row: 2, Col: 2}},
+		},
+		from: ardilla.StreamDeckMK2, to: ardilla.StreamDeckMini, placement: Center,
+		want: &Layout{
+			Keys:   []Key{{Row: 0, Col: 0, Color: "red"}, {Row: 0, Col: 2, Color: "blue"}},
+			Masked: []Position{{Row: 1, Col: 1}},
+		},
+	},
+	{
+		name:    "anchor_off_grid",
+		in:      &Layout{Keys: []Key{{Row: 2, Col: 4, Color: "red"}}},
+		from:    ardilla.StreamDeckMK2,
+		to:      ardilla.StreamDeckMini,
+		wantErr: errors.New("key 2,4 does not fit on StreamDeckMini"),
+	},
+	{
+		name:      "scale_collision",
+		in:        &Layout{Keys: []Key{{Row: 0, Col: 0, Color: "red"}, {Row: 0, Col: 1, Color: "blue"}}},
+		from:      ardilla.StreamDeckXL,
+		to:        ardilla.StreamDeckMini,
+		placement: Scale,
+		wantErr:   errors.New("keys 0,0 and 0,1 both placed at 0,0"),
+	},
+	{
+		name:    "source_bounds",
+		in:      &Layout{Keys: []Key{{Row: 3, Col: 0, Color: "red"}}},
+		from:    ardilla.StreamDeckMK2,
+		to:      ardilla.StreamDeckXL,
+		wantErr: errors.New("key 3,0 out of bounds for StreamDeckMK2"),
+	},
+	{
+		name:    "non_visual",
+		in:      &Layout{},
+		from:    ardilla.StreamDeckMK2,
+		to:      ardilla.StreamDeckPedal,
+		wantErr: errors.New("StreamDeckPedal does not display images"),
+	},
+}
+
+func TestTranscode(t *testing.T) {
+	for _, test := range transcodeTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Transcode(test.in, test.from, test.to, test.placement)
+			if !sameError(err, test.wantErr) {
+				t.Fatalf("unexpected error: got:%v want:%v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unexpected result:\ngot: %#v\nwant:%#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package lease divides the keys of a Stream Deck between independent
+// consumers, so that several programs can share one device, for example
+// an XL. Each consumer is given a lease on a rectangular region of keys.
+// A lease may only draw on its own keys and receives events only for
+// them, and addresses its keys relative to the top left of its region.
+//
+// A Lease has the methods of a Deck, so a plugin may be started on a
+// lease with plugin.Start.
+package lease
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/layout"
+)
+
+// ErrReleased is returned when drawing on a lease that has been released.
+var ErrReleased = errors.New("lease released")
+
+// queueLen is the number of key events that may be waiting to be received
+// by a lease holder before events are dropped. The events channel has one
+// more slot, reserved for reporting dropped events.
+const queueLen = 64
+
+// Deck is the device whose keys are leased. It is satisfied by
+// *ardilla.Deck.
+type Deck interface {
+	PID() ardilla.PID
+	Layout() (rows, cols int)
+	Bounds() (image.Rectangle, error)
+	SetImage(row, col int, img image.Image) error
+}
+
+// Region is a rectangular region of keys.
+type Region struct {
+	Row  int `json:"row"`
+	Col  int `json:"col"`
+	Rows int `json:"rows"`
+	Cols int `json:"cols"`
+}
+
+// contains returns whether the key at row and col is in r.
+func (r Region) contains(row, col int) bool {
+	return r.Row <= row && row < r.Row+r.Rows && r.Col <= col && col < r.Col+r.Cols
+}
+
+// overlaps returns whether r and o have keys in common.
+func (r Region) overlaps(o Region) bool {
+	return r.Row < o.Row+o.Rows && o.Row < r.Row+r.Rows &&
+		r.Col < o.Col+o.Cols && o.Col < r.Col+r.Cols
+}
+
+// Leaser grants leases on the keys of a Deck and routes key events to
+// the lease holding each key.
+type Leaser struct {
+	deck Deck
+
+	mu     sync.Mutex
+	leases map[*Lease]bool
+}
+
+// New returns a Leaser for the keys of d.
+func New(d Deck) *Leaser {
+	return &Leaser{deck: d, leases: make(map[*Lease]bool)}
+}
+
+// Lease grants a lease on the keys in r. It is an error for r to extend
+// beyond the deck or to overlap an unreleased lease.
+func (l *Leaser) Lease(r Region) (*Lease, error) {
+	if r.Rows <= 0 || r.Cols <= 0 {
+		return nil, fmt.Errorf("invalid lease size: %dx%d", r.Rows, r.Cols)
+	}
+	rows, cols := l.deck.Layout()
+	if r.Row < 0 || rows < r.Row+r.Rows {
+		return nil, fmt.Errorf("row out of bounds: %d", r.Row)
+	}
+	if r.Col < 0 || cols < r.Col+r.Cols {
+		return nil, fmt.Errorf("column out of bounds: %d", r.Col)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for o := range l.leases {
+		if r.overlaps(o.region) {
+			return nil, fmt.Errorf("lease overlaps leased keys at %d,%d", o.region.Row, o.region.Col)
+		}
+	}
+	lease := &Lease{
+		leaser: l,
+		region: r,
+		events: make(chan ardilla.KeyEvent, queueLen+1),
+		images: make(map[layout.Position]image.Image),
+	}
+	l.leases[lease] = true
+	return lease, nil
+}
+
+// Handle sends ev to the lease holding the event's key, with the event's
+// key position translated into the lease's coordinates. Events without a
+// key position, those reporting an error or lagged events, are sent to
+// all leases. Handle returns whether the event was sent to any lease.
+// Events are dropped for leases that are not keeping up with their
+// events, and the number dropped is reported to the lease holder with a
+// Lagged event.
+func (l *Leaser) Handle(ev ardilla.KeyEvent) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ev.Err != nil || ev.Lagged != 0 {
+		var sent bool
+		for lease := range l.leases {
+			sent = lease.send(ev) || sent
+		}
+		return sent
+	}
+	for lease := range l.leases {
+		r := lease.region
+		if !r.contains(ev.Row, ev.Col) {
+			continue
+		}
+		ev.Row -= r.Row
+		ev.Col -= r.Col
+		ev.Key = ev.Row*r.Cols + ev.Col
+		return lease.send(ev)
+	}
+	return false
+}
+
+// Redraw sets the last image drawn by each lease on its keys. It is used
+// to restore leased keys after the deck has been reset or the keys have
+// been drawn over.
+func (l *Leaser) Redraw() error {
+	l.mu.Lock()
+	leases := make([]*Lease, 0, len(l.leases))
+	for lease := range l.leases {
+		leases = append(leases, lease)
+	}
+	l.mu.Unlock()
+	var errs []error
+	for _, lease := range leases {
+		errs = append(errs, lease.Redraw())
+	}
+	return errors.Join(errs...)
+}
+
+// release removes lease from the leaser.
+func (l *Leaser) release(lease *Lease) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.leases, lease)
+}
+
+// Lease is a lease on a region of a Deck's keys. Keys are addressed
+// relative to the top left of the region.
+type Lease struct {
+	leaser *Leaser
+	region Region
+
+	mu       sync.Mutex
+	events   chan ardilla.KeyEvent
+	dropped  uint64 // Events dropped and not yet reported.
+	images   map[layout.Position]image.Image
+	released bool
+}
+
+// Region returns the region of the deck held by the lease.
+func (l *Lease) Region() Region {
+	return l.region
+}
+
+// PID returns the product ID of the leased deck.
+func (l *Lease) PID() ardilla.PID {
+	return l.leaser.deck.PID()
+}
+
+// Layout returns the number of rows and columns of keys held by the
+// lease.
+func (l *Lease) Layout() (rows, cols int) {
+	return l.region.Rows, l.region.Cols
+}
+
+// Bounds returns the image bounds of the leased keys.
+func (l *Lease) Bounds() (image.Rectangle, error) {
+	return l.leaser.deck.Bounds()
+}
+
+// SetImage renders img on the leased key at the given row and column of
+// the lease.
+func (l *Lease) SetImage(row, col int, img image.Image) error {
+	if row < 0 || l.region.Rows <= row {
+		return fmt.Errorf("row out of bounds: %d", row)
+	}
+	if col < 0 || l.region.Cols <= col {
+		return fmt.Errorf("column out of bounds: %d", col)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return ErrReleased
+	}
+	// Retain the image even if setting it fails so
+	// that it is restored by Redraw.
+	l.images[layout.Position{Row: row, Col: col}] = img
+	return l.leaser.deck.SetImage(l.region.Row+row, l.region.Col+col, img)
+}
+
+// Redraw sets the last image drawn on each of the lease's keys.
+func (l *Lease) Redraw() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return nil
+	}
+	var errs []error
+	for k, img := range l.images {
+		err := l.leaser.deck.SetImage(l.region.Row+k.Row, l.region.Col+k.Col, img)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %d,%d: %w", k.Row, k.Col, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Events returns the channel on which the lease's key events are sent.
+// The channel is closed when the lease is released. If the holder does not
+// keep up with its events, events are dropped and a KeyEvent with Lagged
+// set to the number dropped is sent, after which the holder should treat
+// its view of the key states as stale.
+func (l *Lease) Events() <-chan ardilla.KeyEvent {
+	return l.events
+}
+
+// Release releases the lease, returning its keys to the leaser. Images
+// drawn by the lease are left on the deck.
+func (l *Lease) Release() {
+	l.mu.Lock()
+	if l.released {
+		l.mu.Unlock()
+		return
+	}
+	l.released = true
+	close(l.events)
+	l.mu.Unlock()
+	l.leaser.release(l)
+}
+
+// send sends ev to the lease holder without blocking, returning whether
+// the event was sent. When the queue is full the event is counted as
+// dropped, and dropped events are reported by a Lagged event sent in the
+// slot reserved for it, so that the holder learns of the loss once it has
+// drained the queue. Lagged events from the deck are counted with the
+// events dropped by the lease.
+func (l *Lease) send(ev ardilla.KeyEvent) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return false
+	}
+	// Sends cannot block since only the holder receives from
+	// the channel and the lock is held while checking its length.
+	if l.dropped != 0 && len(l.events) < cap(l.events) {
+		l.events <- ardilla.KeyEvent{Time: ev.Time, Lagged: l.dropped}
+		l.dropped = 0
+	}
+	if l.dropped == 0 && ev.Lagged == 0 && len(l.events) < queueLen {
+		l.events <- ev
+		return true
+	}
+	if ev.Lagged != 0 {
+		l.dropped += ev.Lagged
+	} else {
+		l.dropped++
+	}
+	if len(l.events) < cap(l.events) {
+		l.events <- ardilla.KeyEvent{Time: ev.Time, Lagged: l.dropped}
+		l.dropped = 0
+		return ev.Lagged != 0
+	}
+	return false
+}
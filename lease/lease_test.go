@@ -0,0 +1,202 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lease
+
+import (
+	"errors"
+	"image"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/layout"
+	"github.com/kortschak/ardilla/plugin"
+)
+
+var _ plugin.Deck = (*Lease)(nil)
+
+func TestLeaser(t *testing.T) {
+	d := &testDeck{rows: 4, cols: 8, images: make(map[layout.Position]image.Image)}
+	l := New(d)
+
+	for _, r := range []Region{
+		{Row: 0, Col: 0, Rows: 0, Cols: 1},
+		{Row: -1, Col: 0, Rows: 1, Cols: 1},
+		{Row: 3, Col: 0, Rows: 2, Cols: 1},
+		{Row: 0, Col: 6, Rows: 1, Cols: 3},
+	} {
+		if _, err := l.Lease(r); err == nil {
+			t.Errorf("expected error for lease of %+v", r)
+		}
+	}
+
+	left, err := l.Lease(Region{Row: 0, Col: 0, Rows: 4, Cols: 4})
+	if err != nil {
+		t.Fatalf("unexpected error leasing left region: %v", err)
+	}
+	right, err := l.Lease(Region{Row: 1, Col: 4, Rows: 2, Cols: 4})
+	if err != nil {
+		t.Fatalf("unexpected error leasing right region: %v", err)
+	}
+	if _, err := l.Lease(Region{Row: 2, Col: 3, Rows: 1, Cols: 2}); err == nil {
+		t.Error("expected error for overlapping lease")
+	}
+	if rows, cols := right.Layout(); rows != 2 || cols != 4 {
+		t.Errorf("unexpected lease layout: got:%dx%d want:2x4", rows, cols)
+	}
+
+	// Leases draw in their own coordinates and only on their
+	// own keys.
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	err = right.SetImage(1, 2, img)
+	if err != nil {
+		t.Errorf("unexpected error setting image: %v", err)
+	}
+	if d.images[layout.Position{Row: 2, Col: 6}] != image.Image(img) {
+		t.Error("lease image not drawn at deck key 2,6")
+	}
+	for _, k := range []layout.Position{{Row: 2, Col: 0}, {Row: 0, Col: 4}, {Row: -1, Col: 0}} {
+		if err := right.SetImage(k.Row, k.Col, img); err == nil {
+			t.Errorf("expected error drawing outside lease at %d,%d", k.Row, k.Col)
+		}
+	}
+
+	// Events are routed to the lease holding the key in the
+	// lease's coordinates.
+	for _, test := range []struct {
+		ev    ardilla.KeyEvent
+		lease *Lease
+		want  ardilla.KeyEvent
+	}{
+		{
+			ev:    ardilla.KeyEvent{Key: 22, Row: 2, Col: 6, Pressed: true},
+			lease: right,
+			want:  ardilla.KeyEvent{Key: 6, Row: 1, Col: 2, Pressed: true},
+		},
+		{
+			ev:    ardilla.KeyEvent{Key: 25, Row: 3, Col: 1, Pressed: true},
+			lease: left,
+			want:  ardilla.KeyEvent{Key: 13, Row: 3, Col: 1, Pressed: true},
+		},
+		{
+			ev: ardilla.KeyEvent{Key: 4, Row: 0, Col: 4, Pressed: true},
+		},
+	} {
+		sent := l.Handle(test.ev)
+		if sent != (test.lease != nil) {
+			t.Errorf("unexpected handling of %+v: got:%t want:%t", test.ev, sent, test.lease != nil)
+		}
+		if test.lease == nil {
+			continue
+		}
+		got := <-test.lease.Events()
+		if got != test.want {
+			t.Errorf("unexpected event: got:%+v want:%+v", got, test.want)
+		}
+	}
+	if len(left.Events()) != 0 || len(right.Events()) != 0 {
+		t.Error("unexpected events queued")
+	}
+
+	d.images = make(map[layout.Position]image.Image)
+	err = l.Redraw()
+	if err != nil {
+		t.Errorf("unexpected error redrawing: %v", err)
+	}
+	if !reflect.DeepEqual(d.images, map[layout.Position]image.Image{{Row: 2, Col: 6}: img}) {
+		t.Errorf("unexpected images after redraw: %v", d.images)
+	}
+
+	right.Release()
+	right.Release()
+	if _, ok := <-right.Events(); ok {
+		t.Error("events channel not closed after release")
+	}
+	if err := right.SetImage(0, 0, img); !errors.Is(err, ErrReleased) {
+		t.Errorf("unexpected error drawing on released lease: got:%v want:%v", err, ErrReleased)
+	}
+	if l.Handle(ardilla.KeyEvent{Key: 22, Row: 2, Col: 6, Pressed: true}) {
+		t.Error("event sent to released lease")
+	}
+	if _, err := l.Lease(Region{Row: 2, Col: 3, Rows: 1, Cols: 2}); err == nil {
+		t.Error("expected error for lease overlapping unreleased lease")
+	}
+	_, err = l.Lease(Region{Row: 2, Col: 4, Rows: 2, Cols: 4})
+	if err != nil {
+		t.Errorf("unexpected error leasing released keys: %v", err)
+	}
+	left.Release()
+}
+
+type testDeck struct {
+	rows, cols int
+
+	mu     sync.Mutex
+	images map[layout.Position]image.Image
+}
+
+func (d *testDeck) PID() ardilla.PID                 { return ardilla.StreamDeckXL }
+func (d *testDeck) Layout() (rows, cols int)         { return d.rows, d.cols }
+func (d *testDeck) Bounds() (image.Rectangle, error) { return image.Rect(0, 0, 96, 96), nil }
+
+func (d *testDeck) SetImage(row, col int, img image.Image) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.images[layout.Position{Row: row, Col: col}] = img
+	return nil
+}
+
+func TestLeaseLagged(t *testing.T) {
+	d := &testDeck{rows: 4, cols: 8, images: make(map[layout.Position]image.Image)}
+	l := New(d)
+	lease, err := l.Lease(Region{Row: 0, Col: 0, Rows: 1, Cols: 1})
+	if err != nil {
+		t.Fatalf("unexpected error leasing region: %v", err)
+	}
+	press := ardilla.KeyEvent{Pressed: true}
+
+	// Events beyond the queue length are dropped, and the
+	// first drop is reported in the reserved slot.
+	for i := 0; i < queueLen+5; i++ {
+		sent := l.Handle(press)
+		if want := i < queueLen; sent != want {
+			t.Errorf("unexpected handling of event %d: got:%t want:%t", i, sent, want)
+		}
+	}
+	var got []ardilla.KeyEvent
+	for len(lease.Events()) != 0 {
+		got = append(got, <-lease.Events())
+	}
+	want := make([]ardilla.KeyEvent, queueLen, queueLen+1)
+	for i := range want {
+		want[i] = press
+	}
+	want = append(want, ardilla.KeyEvent{Lagged: 1})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected events from full queue:\ngot: %+v\nwant:%+v", got, want)
+	}
+
+	// Later drops are reported before the next event, and
+	// lags reported by the deck include unreported drops.
+	for _, test := range []struct {
+		ev   ardilla.KeyEvent
+		want []ardilla.KeyEvent
+	}{
+		{ev: press, want: []ardilla.KeyEvent{{Lagged: 4}, press}},
+		{ev: ardilla.KeyEvent{Lagged: 3}, want: []ardilla.KeyEvent{{Lagged: 3}}},
+	} {
+		if !l.Handle(test.ev) {
+			t.Errorf("event not sent: %+v", test.ev)
+		}
+		var got []ardilla.KeyEvent
+		for len(lease.Events()) != 0 {
+			got = append(got, <-lease.Events())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("unexpected events after %+v:\ngot: %+v\nwant:%+v", test.ev, got, test.want)
+		}
+	}
+}
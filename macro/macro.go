@@ -0,0 +1,219 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package macro records and replays timed sequences of Stream Deck key
+// events.
+package macro
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kortschak/ardilla"
+)
+
+// Macro is a recorded sequence of key events.
+type Macro struct {
+	Steps []Step `json:"steps"`
+}
+
+// Step is a single key event in a Macro.
+type Step struct {
+	// Delay is the time between the previous
+	// step, or the start of the macro, and the
+	// step.
+	Delay time.Duration `json:"-"`
+
+	// Key, Row and Col identify the key.
+	Key int `json:"key"`
+	Row int `json:"row"`
+	Col int `json:"col"`
+
+	// Pressed is whether the key was pressed
+	// or released.
+	Pressed bool `json:"pressed"`
+}
+
+// step is the JSON representation of a Step.
+type step struct {
+	Delay string `json:"delay"`
+	Key   int    `json:"key"`
+	Row   int    `json:"row"`
+	Col   int    `json:"col"`
+
+	Pressed bool `json:"pressed"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. The step's
+// delay is written in time.Duration string format.
+func (s Step) MarshalJSON() ([]byte, error) {
+	return json.Marshal(step{
+		Delay:   s.Delay.String(),
+		Key:     s.Key,
+		Row:     s.Row,
+		Col:     s.Col,
+		Pressed: s.Pressed,
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *Step) UnmarshalJSON(data []byte) error {
+	var v step
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&v)
+	if err != nil {
+		return err
+	}
+	delay, err := time.ParseDuration(v.Delay)
+	if err != nil {
+		return err
+	}
+	if delay < 0 {
+		return fmt.Errorf("negative delay: %s", v.Delay)
+	}
+	*s = Step{Delay: delay, Key: v.Key, Row: v.Row, Col: v.Col, Pressed: v.Pressed}
+	return nil
+}
+
+// Load reads the macro held in the JSON file at path.
+func Load(path string) (*Macro, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Macro
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Duration returns the total duration of the macro.
+func (m *Macro) Duration() time.Duration {
+	var d time.Duration
+	for _, s := range m.Steps {
+		d += s.Delay
+	}
+	return d
+}
+
+// Recorder records key events into a Macro.
+type Recorder struct {
+	start time.Time
+	last  time.Time
+	steps []Step
+}
+
+// NewRecorder returns a Recorder that measures the delay of the first
+// recorded event from start. If start is zero, the first event is recorded
+// with no delay.
+func NewRecorder(start time.Time) *Recorder {
+	return &Recorder{start: start}
+}
+
+// Record adds the key press or release in ev to the recording. Stuck,
+// Lagged and error events are not recorded and Record returns false for
+// them.
+func (r *Recorder) Record(ev ardilla.KeyEvent) bool {
+	if ev.Err != nil || ev.Lagged != 0 || ev.Stuck {
+		return false
+	}
+	last := r.last
+	if len(r.steps) == 0 {
+		last = r.start
+	}
+	var delay time.Duration
+	if !last.IsZero() && ev.Time.After(last) {
+		delay = ev.Time.Sub(last)
+	}
+	r.last = ev.Time
+	r.steps = append(r.steps, Step{
+		Delay:   delay,
+		Key:     ev.Key,
+		Row:     ev.Row,
+		Col:     ev.Col,
+		Pressed: ev.Pressed,
+	})
+	return true
+}
+
+// Macro returns the recorded macro. Keys that are pressed at the end of
+// the recording are released at the end of the returned macro with no
+// delay, so that replaying the macro does not leave keys held.
+func (r *Recorder) Macro() *Macro {
+	steps := append([]Step(nil), r.steps...)
+	held := make(map[int]Step)
+	var order []int
+	for _, s := range steps {
+		if s.Pressed {
+			if _, ok := held[s.Key]; !ok {
+				order = append(order, s.Key)
+			}
+			held[s.Key] = s
+		} else {
+			delete(held, s.Key)
+		}
+	}
+	for _, k := range order {
+		s, ok := held[k]
+		if !ok {
+			continue
+		}
+		steps = append(steps, Step{Key: s.Key, Row: s.Row, Col: s.Col})
+	}
+	return &Macro{Steps: steps}
+}
+
+// Reset discards the recorded events and sets the start of the recording.
+func (r *Recorder) Reset(start time.Time) {
+	*r = Recorder{start: start}
+}
+
+// ErrStopped is returned by Replay when the replay is stopped by its
+// callback.
+var ErrStopped = errors.New("replay stopped")
+
+// Replay calls fn with a key event for each step of the macro, waiting for
+// each step's delay before the call. The events have their Time set to the
+// time of the call and Seq numbered from one. Replay returns ctx.Err() if
+// ctx is cancelled and ErrStopped if fn returns false.
+func (m *Macro) Replay(ctx context.Context, fn func(ardilla.KeyEvent) bool) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	for i, s := range m.Steps {
+		if s.Delay > 0 {
+			timer.Reset(s.Delay)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+		ok := fn(ardilla.KeyEvent{
+			Time:    time.Now(),
+			Seq:     uint64(i + 1),
+			Report:  uint64(i + 1),
+			Key:     s.Key,
+			Row:     s.Row,
+			Col:     s.Col,
+			Pressed: s.Pressed,
+		})
+		if !ok {
+			return ErrStopped
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package macro
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla"
+)
+
+func TestRecorder(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time {
+		return start.Add(time.Duration(ms) * time.Millisecond)
+	}
+	r := NewRecorder(start)
+	events := []ardilla.KeyEvent{
+		{Time: at(100), Key: 1, Row: 0, Col: 1, Pressed: true},
+		{Time: at(150), Key: 1, Row: 0, Col: 1},
+		{Time: at(200), Lagged: 2},
+		{Time: at(400), Key: 5, Row: 1, Col: 0, Pressed: true},
+		{Time: at(900), Key: 5, Row: 1, Col: 0, Pressed: true, Stuck: true},
+		{Time: at(950), Key: 2, Row: 0, Col: 2, Pressed: true},
+	}
+	wantRecorded := []bool{true, true, false, true, false, true}
+	for i, ev := range events {
+		if got := r.Record(ev); got != wantRecorded[i] {
+			t.Errorf("unexpected result recording event %d: got:%t want:%t", i, got, wantRecorded[i])
+		}
+	}
+	got := r.Macro()
+	want := &Macro{Steps: []Step{
+		{Delay: 100 * time.Millisecond, Key: 1, Row: 0, Col: 1, Pressed: true},
+		{Delay: 50 * time.Millisecond, Key: 1, Row: 0, Col: 1},
+		{Delay: 250 * time.Millisecond, Key: 5, Row: 1, Col: 0, Pressed: true},
+		{Delay: 550 * time.Millisecond, Key: 2, Row: 0, Col: 2, Pressed: true},
+		{Key: 5, Row: 1, Col: 0},
+		{Key: 2, Row: 0, Col: 2},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected macro:\ngot: %+v\nwant:%+v", got, want)
+	}
+	if d := got.Duration(); d != 950*time.Millisecond {
+		t.Errorf("unexpected duration: got:%v want:950ms", d)
+	}
+
+	r.Reset(time.Time{})
+	r.Record(events[0])
+	if got := r.Macro().Steps[0].Delay; got != 0 {
+		t.Errorf("unexpected delay for first event without start: %v", got)
+	}
+}
+
+func TestMacroJSON(t *testing.T) {
+	m := &Macro{Steps: []Step{
+		{Delay: 100 * time.Millisecond, Key: 1, Row: 0, Col: 1, Pressed: true},
+		{Delay: 1500 * time.Millisecond, Key: 1, Row: 0, Col: 1},
+	}}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling macro: %v", err)
+	}
+	const want = `{"steps":[{"delay":"100ms","key":1,"row":0,"col":1,"pressed":true},{"delay":"1.5s","key":1,"row":0,"col":1,"pressed":false}]}`
+	if string(data) != want {
+		t.Errorf("unexpected JSON:\ngot: %s\nwant:%s", data, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "macro.json")
+	err = os.WriteFile(path, data, 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error writing macro: %v", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading macro: %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("unexpected round trip:\ngot: %+v\nwant:%+v", got, m)
+	}
+
+	for _, bad := range []string{
+		`{"steps":[{"delay":"-1s","key":1,"row":0,"col":1,"pressed":true}]}`,
+		`{"steps":[{"delay":"soon","key":1,"row":0,"col":1,"pressed":true}]}`,
+		`{"steps":[{"delay":"1s","key":1,"row":0,"col":1,"down":true}]}`,
+	} {
+		var m Macro
+		if err := json.Unmarshal([]byte(bad), &m); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	m := &Macro{Steps: []Step{
+		{Key: 1, Row: 0, Col: 1, Pressed: true},
+		{Delay: 20 * time.Millisecond, Key: 1, Row: 0, Col: 1},
+		{Delay: 20 * time.Millisecond, Key: 2, Row: 0, Col: 2, Pressed: true},
+	}}
+
+	var got []ardilla.KeyEvent
+	start := time.Now()
+	err := m.Replay(context.Background(), func(ev ardilla.KeyEvent) bool {
+		got = append(got, ev)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != len(m.Steps) {
+		t.Fatalf("unexpected number of events: got:%d want:%d", len(got), len(m.Steps))
+	}
+	for i, ev := range got {
+		s := m.Steps[i]
+		if ev.Seq != uint64(i+1) || ev.Key != s.Key || ev.Row != s.Row || ev.Col != s.Col || ev.Pressed != s.Pressed {
+			t.Errorf("unexpected event %d: got:%+v for step:%+v", i, ev, s)
+		}
+	}
+	if elapsed := got[2].Time.Sub(start); elapsed < m.Duration() {
+		t.Errorf("replay faster than recording: got:%v want at least:%v", elapsed, m.Duration())
+	}
+
+	n := 0
+	err = m.Replay(context.Background(), func(ardilla.KeyEvent) bool {
+		n++
+		return false
+	})
+	if err != ErrStopped || n != 1 {
+		t.Errorf("unexpected result for stopped replay: err=%v calls=%d", err, n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = m.Replay(ctx, func(ardilla.KeyEvent) bool {
+		t.Error("unexpected event after cancellation")
+		return true
+	})
+	if err != context.Canceled {
+		t.Errorf("unexpected error for cancelled replay: got:%v want:%v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import "errors"
+
+// ErrMaintenance is returned by operations that write to the display
+// while the Deck is held for maintenance.
+var ErrMaintenance = errors.New("device held for maintenance")
+
+// Maintenance is an exclusive maintenance hold on a Deck. While a hold is
+// in effect, image, brightness and reset writes to the device fail with
+// ErrMaintenance, so that a program sharing a device with a firmware
+// updater can avoid writing to the device during an update.
+//
+// The commands used by El Gato firmware updaters to enter and leave the
+// bootloader are not documented and are not provided, and a device in
+// bootloader mode is not detected.
+type Maintenance struct {
+	d *Deck
+
+	// hold identifies the hold so that
+	// releasing a stale hold has no effect.
+	hold uint64
+}
+
+// Hold stops all running animations and places the Deck under a
+// maintenance hold. Hold returns an error if the Deck is already held.
+func (d *Deck) Hold() (*Maintenance, error) {
+	d.mu.Lock()
+	if d.held {
+		d.mu.Unlock()
+		return nil, ErrMaintenance
+	}
+	d.held = true
+	d.hold++
+	m := &Maintenance{d: d, hold: d.hold}
+	d.mu.Unlock()
+	d.stopAnimations()
+	return m, nil
+}
+
+// Held returns whether the Deck is under a maintenance hold.
+func (d *Deck) Held() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.held
+}
+
+// Firmware returns the firmware version of the held device.
+func (m *Maintenance) Firmware() (string, error) {
+	return m.d.Firmware()
+}
+
+// Release releases the maintenance hold. The Deck's key images and
+// brightness are not restored; the device should be reset or reconnected
+// before use if it has been updated. Releasing a hold that has already
+// been released has no effect, even if the Deck has been held again.
+func (m *Maintenance) Release() {
+	m.d.mu.Lock()
+	if m.d.hold == m.hold {
+		m.d.held = false
+	}
+	m.d.mu.Unlock()
+}
@@ -0,0 +1,94 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"image"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDeckHold(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dev := &virtDev{
+		Reader: strings.NewReader(padZero("\x05\x0c\x00\x00\x00\x001.00.004", 32)),
+		Writer: io.Discard,
+	}
+	d.setDev(dev)
+
+	started := make(chan struct{})
+	a, err := d.Animate(context.Background(), 0, 0, blockingAnimation(started))
+	if err != nil {
+		t.Fatalf("unexpected error for Animate: %v", err)
+	}
+	<-started
+
+	m, err := d.Hold()
+	if err != nil {
+		t.Fatalf("unexpected error for Hold: %v", err)
+	}
+	select {
+	case <-a.Done():
+	default:
+		t.Error("animation not stopped by Hold")
+	}
+	if !d.Held() {
+		t.Error("expected deck to be held")
+	}
+	_, err = d.Hold()
+	if err != ErrMaintenance {
+		t.Errorf("unexpected error for second Hold: got:%v want:%v", err, ErrMaintenance)
+	}
+
+	dev.actions = nil
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for _, op := range []struct {
+		name string
+		fn   func() error
+	}{
+		{name: "SetImage", fn: func() error { return d.SetImage(0, 0, img) }},
+		{name: "SetBrightness", fn: func() error { return d.SetBrightness(50) }},
+		{name: "Reset", fn: d.Reset},
+	} {
+		err = op.fn()
+		if err != ErrMaintenance {
+			t.Errorf("unexpected error for held %s: got:%v want:%v", op.name, err, ErrMaintenance)
+		}
+	}
+	if len(dev.actions) != 0 {
+		t.Errorf("unexpected actions while held: %q", dev.actions)
+	}
+
+	firmware, err := m.Firmware()
+	if err != nil {
+		t.Errorf("unexpected error for Firmware: %v", err)
+	}
+	if want := "1.00.004"; firmware != want {
+		t.Errorf("unexpected firmware: got:%q want:%q", firmware, want)
+	}
+
+	m.Release()
+	if d.Held() {
+		t.Error("expected deck to be released")
+	}
+	err = d.SetImage(0, 0, img)
+	if err != nil {
+		t.Errorf("unexpected error for SetImage after Release: %v", err)
+	}
+
+	_, err = d.Hold()
+	if err != nil {
+		t.Fatalf("unexpected error for new Hold: %v", err)
+	}
+	m.Release()
+	if !d.Held() {
+		t.Error("new hold released by stale hold")
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeckInfo is the description of a connected Stream Deck.
+type DeckInfo struct {
+	PID    PID
+	Serial string
+	Path   string // Path is the platform-specific HID device path.
+
+	// Port is the platform-specific physical location
+	// of the device. Unlike Path, it does not change
+	// when the device is reconnected to the same USB
+	// port. On Linux it is the sysfs device path of
+	// the device's USB interface. Where the location
+	// is not known, Port is the same as Path.
+	Port string
+}
+
+// deckInfos returns the descriptions of all connected Stream Decks with
+// product IDs supported by ardilla.
+func deckInfos() ([]DeckInfo, error) {
+	var found []deviceInfo
+	err := enumerate(vidElGato, AnyPID, func(info deviceInfo) error {
+		if _, ok := devices[info.pid]; ok {
+			found = append(found, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var infos []DeckInfo
+	for _, info := range primaryInterfaces(found) {
+		infos = append(infos, DeckInfo{
+			PID:    info.pid,
+			Serial: info.serial,
+			Path:   info.path,
+			Port:   physicalPort(info.path),
+		})
+	}
+	return infos, nil
+}
+
+// openDeck opens the Deck described by info. Devices without a serial
+// number are opened by path, since opening by product ID alone opens the
+// first enumerated device with the product ID, which may not be the
+// described device.
+func openDeck(info DeckInfo, opts []Option) (*Deck, error) {
+	if info.Serial != "" {
+		return NewDeck(info.PID, info.Serial, opts...)
+	}
+	desc, ok := devices[info.PID]
+	if !ok {
+		return nil, fmt.Errorf("%s not a valid deck device identifier", info.PID)
+	}
+	dev, err := openHIDPath(info.Path)
+	if err != nil {
+		return nil, err
+	}
+	return newDeck(desc, "", dev, opts)
+}
+
+// openHIDPath is openPath unless replaced in tests.
+var openHIDPath = openPath
+
+// OpenAll opens all connected Stream Decks for which filter returns true,
+// applying opts to each. If filter is nil, all connected Stream Decks are
+// opened. If any Deck fails to open, all opened Decks are closed and the
+// error is returned.
+func OpenAll(filter func(DeckInfo) bool, opts ...Option) ([]*Deck, error) {
+	infos, err := deckInfos()
+	if err != nil {
+		return nil, err
+	}
+	var decks []*Deck
+	for _, info := range infos {
+		if filter != nil && !filter(info) {
+			continue
+		}
+		d, err := openDeck(info, opts)
+		if err != nil {
+			for _, d := range decks {
+				d.Close()
+			}
+			return nil, fmt.Errorf("failed to open %s %s: %w", info.PID, info.Serial, err)
+		}
+		decks = append(decks, d)
+	}
+	return decks, nil
+}
+
+// Manager maintains the set of open Stream Decks matching a filter as
+// devices are connected and disconnected.
+type Manager struct {
+	// Filter selects the devices to open. If Filter
+	// is nil, all connected Stream Decks are opened.
+	Filter func(DeckInfo) bool
+
+	// Options are applied to each opened Deck.
+	Options []Option
+
+	// Added, if not nil, is called with each newly
+	// opened Deck.
+	Added func(*Deck)
+
+	// Removed, if not nil, is called with each Deck
+	// that is no longer connected, before it is closed.
+	//
+	// Added and Removed must not call methods of the
+	// Manager.
+	Removed func(*Deck)
+
+	mu    sync.Mutex
+	decks map[string]*Deck // decks is keyed by serial or path.
+
+	// list and open are deckInfos and openDeck
+	// unless replaced in tests.
+	list func() ([]DeckInfo, error)
+	open func(DeckInfo, []Option) (*Deck, error)
+}
+
+// Decks returns the currently open Decks, sorted by serial number, or by
+// device path for devices without a serial number.
+func (m *Manager) Decks() []*Deck {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.decks))
+	for k := range m.decks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	decks := make([]*Deck, len(keys))
+	for i, k := range keys {
+		decks[i] = m.decks[k]
+	}
+	return decks
+}
+
+// Update enumerates the connected devices, opening matching devices that
+// are not already open and closing Decks whose devices are no longer
+// connected. Devices that fail to open are retried on the next call to
+// Update. The errors from any failed opens are returned.
+func (m *Manager) Update() error {
+	list := m.list
+	if list == nil {
+		list = deckInfos
+	}
+	open := m.open
+	if open == nil {
+		open = openDeck
+	}
+
+	infos, err := list()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.decks == nil {
+		m.decks = make(map[string]*Deck)
+	}
+	present := make(map[string]bool)
+	var errs []error
+	for _, info := range infos {
+		if m.Filter != nil && !m.Filter(info) {
+			continue
+		}
+		key := info.Serial
+		if key == "" {
+			key = info.Path
+		}
+		present[key] = true
+		if _, ok := m.decks[key]; ok {
+			continue
+		}
+		d, err := open(info, m.Options)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to open %s %s: %w", info.PID, info.Serial, err))
+			continue
+		}
+		m.decks[key] = d
+		if m.Added != nil {
+			m.Added(d)
+		}
+	}
+	for key, d := range m.decks {
+		if present[key] {
+			continue
+		}
+		m.remove(key, d)
+	}
+	return errors.Join(errs...)
+}
+
+// Run calls Update every interval until ctx is cancelled, and then closes
+// all open Decks. Errors from Update are passed to errFn if it is not nil.
+// Run returns ctx.Err().
+func (m *Manager) Run(ctx context.Context, interval time.Duration, errFn func(error)) error {
+	defer m.Close()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := m.Update()
+		if err != nil && errFn != nil {
+			errFn(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes all open Decks, calling Removed for each.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for key, d := range m.decks {
+		errs = append(errs, m.remove(key, d))
+	}
+	return errors.Join(errs...)
+}
+
+// remove removes and closes the Deck d held with the given key. The
+// receiver's mutex must be held.
+func (m *Manager) remove(key string, d *Deck) error {
+	delete(m.decks, key)
+	if m.Removed != nil {
+		m.Removed(d)
+	}
+	return d.Close()
+}
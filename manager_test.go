@@ -0,0 +1,187 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManager(t *testing.T) {
+	var (
+		connected []DeckInfo
+		failOpen  bool
+		events    []string
+	)
+	m := &Manager{
+		Filter: func(info DeckInfo) bool {
+			return info.PID != StreamDeckPedal
+		},
+		Added: func(d *Deck) {
+			events = append(events, "added "+d.serial)
+		},
+		Removed: func(d *Deck) {
+			events = append(events, "removed "+d.serial)
+		},
+		list: func() ([]DeckInfo, error) {
+			return connected, nil
+		},
+		open: func(info DeckInfo, _ []Option) (*Deck, error) {
+			if failOpen {
+				return nil, errors.New("busy")
+			}
+			d, err := newTestDeck(info.PID)
+			if err != nil {
+				return nil, err
+			}
+			d.serial = info.Serial
+			d.setDev(&virtDev{Writer: io.Discard, Closer: io.NopCloser(nil)})
+			return d, nil
+		},
+	}
+
+	steps := []struct {
+		connected  []DeckInfo
+		failOpen   bool
+		wantErr    error
+		wantEvents []string
+		wantDecks  []string
+	}{
+		{
+			connected: []DeckInfo{
+				{PID: StreamDeckXL, Serial: "B"},
+				{PID: StreamDeckPedal, Serial: "P"},
+				{PID: StreamDeckMK2, Serial: "A"},
+			},
+			wantEvents: []string{"added B", "added A"},
+			wantDecks:  []string{"A", "B"},
+		},
+		{
+			connected: []DeckInfo{
+				{PID: StreamDeckMK2, Serial: "A"},
+			},
+			wantEvents: []string{"removed B"},
+			wantDecks:  []string{"A"},
+		},
+		{
+			connected: []DeckInfo{
+				{PID: StreamDeckMK2, Serial: "A"},
+				{PID: StreamDeckXL, Serial: "B"},
+			},
+			failOpen:  true,
+			wantErr:   errors.New("failed to open StreamDeckXL B: busy"),
+			wantDecks: []string{"A"},
+		},
+		{
+			connected: []DeckInfo{
+				{PID: StreamDeckMK2, Serial: "A"},
+				{PID: StreamDeckXL, Serial: "B"},
+			},
+			wantEvents: []string{"added B"},
+			wantDecks:  []string{"A", "B"},
+		},
+	}
+	for i, step := range steps {
+		connected = step.connected
+		failOpen = step.failOpen
+		events = nil
+		err := m.Update()
+		if !sameError(err, step.wantErr) {
+			t.Errorf("unexpected error for step %d: got:%v want:%v", i, err, step.wantErr)
+		}
+		if !reflect.DeepEqual(events, step.wantEvents) {
+			t.Errorf("unexpected events for step %d:\ngot: %q\nwant:%q", i, events, step.wantEvents)
+		}
+		var serials []string
+		for _, d := range m.Decks() {
+			serials = append(serials, d.serial)
+		}
+		if !reflect.DeepEqual(serials, step.wantDecks) {
+			t.Errorf("unexpected decks for step %d: got:%q want:%q", i, serials, step.wantDecks)
+		}
+	}
+
+	events = nil
+	err := m.Close()
+	if err != nil {
+		t.Errorf("unexpected error closing manager: %v", err)
+	}
+	if len(events) != 2 || len(m.Decks()) != 0 {
+		t.Errorf("unexpected state after close: events:%q decks:%d", events, len(m.Decks()))
+	}
+}
+
+func TestSelectDeck(t *testing.T) {
+	infos := []DeckInfo{
+		{PID: StreamDeckPedal, Serial: "p1", Path: "/dev/hidraw0"},
+		{PID: StreamDeckMini, Serial: "m1", Path: "/dev/hidraw1"},
+		{PID: StreamDeckXL, Serial: "x1", Path: "/dev/hidraw2"},
+	}
+	for _, test := range []struct {
+		infos  []DeckInfo
+		serial string
+		want   DeckInfo
+		wantOK bool
+	}{
+		{infos: infos, want: infos[1], wantOK: true},
+		{infos: infos, serial: "x1", want: infos[2], wantOK: true},
+		{infos: infos, serial: "p1", want: infos[0], wantOK: true},
+		{infos: infos[:1], want: infos[0], wantOK: true},
+		{infos: infos, serial: "none"},
+		{infos: nil},
+	} {
+		got, ok := selectDeck(test.infos, test.serial)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("unexpected result for serial %q: got:%v %t want:%v %t", test.serial, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestOpenDeckWithoutSerial(t *testing.T) {
+	defer func(fn func(string) (HIDDevice, error)) { openHIDPath = fn }(openHIDPath)
+	// Each device reports its serial only when
+	// queried, so enumeration does not find it.
+	serials := map[string]string{
+		"/dev/hidraw1": "AL12K1A00001",
+		"/dev/hidraw2": "AL12K1A00002",
+	}
+	openHIDPath = func(path string) (HIDDevice, error) {
+		serial, ok := serials[path]
+		if !ok {
+			return nil, ErrNotConnected
+		}
+		return &virtDev{
+			Reader: strings.NewReader(padZero("\x06\x0c"+serial, 32)),
+			Writer: io.Discard,
+			Closer: io.NopCloser(nil),
+		}, nil
+	}
+
+	for _, info := range []DeckInfo{
+		{PID: StreamDeckMK2, Path: "/dev/hidraw2"},
+		{PID: StreamDeckMK2, Path: "/dev/hidraw1"},
+	} {
+		d, err := openDeck(info, []Option{WithResetKeyStream(false)})
+		if err != nil {
+			t.Errorf("unexpected error opening %s: %v", info.Path, err)
+			continue
+		}
+		got, err := d.Serial()
+		if err != nil {
+			t.Errorf("unexpected error getting serial of %s: %v", info.Path, err)
+		}
+		if want := serials[info.Path]; got != want {
+			t.Errorf("unexpected device opened for %s: got serial %q want %q", info.Path, got, want)
+		}
+		d.Close()
+	}
+	_, err := openDeck(DeckInfo{PID: StreamDeckMK2, Path: "/dev/hidraw3"}, nil)
+	if !errors.Is(err, ErrNotConnected) {
+		t.Errorf("unexpected error opening missing device: got:%v want:%v", err, ErrNotConnected)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"sync"
+	"time"
+)
+
+// Handler handles a key event, returning whether the event was handled.
+// Methods such as the Handle methods of action.Dispatcher and
+// plugin.Plugin may be used as Handlers.
+type Handler func(KeyEvent) bool
+
+// Middleware wraps a Handler to add behaviour to the event pipeline, for
+// example logging, filtering, remapping or rate limiting, without
+// changing the handler.
+type Middleware func(next Handler) Handler
+
+// Chain returns h wrapped by the middleware. The first middleware is the
+// outermost, and so sees each event first.
+func Chain(h Handler, mw ...Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// LogEvents returns a Middleware that logs each event with logf, for
+// example log.Printf, before passing it on.
+func LogEvents(logf func(format string, args ...any)) Middleware {
+	return func(next Handler) Handler {
+		return func(ev KeyEvent) bool {
+			switch {
+			case ev.Err != nil:
+				logf("key events: %v", ev.Err)
+			case ev.Lagged != 0:
+				logf("key events lagged: %d reports dropped", ev.Lagged)
+			case ev.Stuck:
+				logf("key %d,%d stuck", ev.Row, ev.Col)
+			case ev.Pressed:
+				logf("key %d,%d pressed", ev.Row, ev.Col)
+			default:
+				logf("key %d,%d released", ev.Row, ev.Col)
+			}
+			return next(ev)
+		}
+	}
+}
+
+// FilterEvents returns a Middleware that passes on only the events for
+// which keep returns true. Dropped events are reported as not handled.
+func FilterEvents(keep func(KeyEvent) bool) Middleware {
+	return func(next Handler) Handler {
+		return func(ev KeyEvent) bool {
+			if !keep(ev) {
+				return false
+			}
+			return next(ev)
+		}
+	}
+}
+
+// MapEvents returns a Middleware that passes on the result of fn applied
+// to each event, for example to move events from one key to another. If
+// fn changes the Row and Col of an event, it should also change the Key.
+func MapEvents(fn func(KeyEvent) KeyEvent) Middleware {
+	return func(next Handler) Handler {
+		return func(ev KeyEvent) bool {
+			return next(fn(ev))
+		}
+	}
+}
+
+// RateLimitEvents returns a Middleware that drops presses of a key that
+// follow the previous passed press of the key within interval, measured
+// by the events' times, and the releases that follow dropped presses.
+// Events without a key are always passed on. Dropped events are reported
+// as not handled.
+func RateLimitEvents(interval time.Duration) Middleware {
+	return func(next Handler) Handler {
+		var (
+			mu      sync.Mutex
+			last    = make(map[int]time.Time)
+			dropped = make(map[int]bool)
+		)
+		return func(ev KeyEvent) bool {
+			if ev.Err != nil || ev.Lagged != 0 {
+				return next(ev)
+			}
+			mu.Lock()
+			switch {
+			case ev.Pressed && !ev.Stuck:
+				t, ok := last[ev.Key]
+				if ok && ev.Time.Sub(t) < interval {
+					dropped[ev.Key] = true
+					mu.Unlock()
+					return false
+				}
+				last[ev.Key] = ev.Time
+				delete(dropped, ev.Key)
+			case dropped[ev.Key]:
+				// Drop the release or stuck report of
+				// a dropped press.
+				if !ev.Pressed {
+					delete(dropped, ev.Key)
+				}
+				mu.Unlock()
+				return false
+			}
+			mu.Unlock()
+			return next(ev)
+		}
+	}
+}
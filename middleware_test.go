@@ -0,0 +1,152 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ev KeyEvent) bool {
+				order = append(order, name)
+				return next(ev)
+			}
+		}
+	}
+	h := Chain(func(KeyEvent) bool {
+		order = append(order, "handler")
+		return true
+	}, mark("first"), mark("second"))
+	if !h(KeyEvent{}) {
+		t.Error("event not handled")
+	}
+	want := []string{"first", "second", "handler"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("unexpected middleware order: got:%v want:%v", order, want)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	for _, test := range []struct {
+		name   string
+		mw     []Middleware
+		events []KeyEvent
+		want   []KeyEvent
+		log    []string
+	}{
+		{
+			name: "log",
+			events: []KeyEvent{
+				{Row: 1, Col: 2, Pressed: true},
+				{Row: 1, Col: 2, Pressed: true, Stuck: true},
+				{Row: 1, Col: 2},
+				{Lagged: 3},
+				{Err: errors.New("device gone")},
+			},
+			want: []KeyEvent{
+				{Row: 1, Col: 2, Pressed: true},
+				{Row: 1, Col: 2, Pressed: true, Stuck: true},
+				{Row: 1, Col: 2},
+				{Lagged: 3},
+				{Err: errors.New("device gone")},
+			},
+			log: []string{
+				"key 1,2 pressed",
+				"key 1,2 stuck",
+				"key 1,2 released",
+				"key events lagged: 3 reports dropped",
+				"key events: device gone",
+			},
+		},
+		{
+			name: "filter",
+			mw: []Middleware{FilterEvents(func(ev KeyEvent) bool {
+				return ev.Row != 0
+			})},
+			events: []KeyEvent{
+				{Key: 0, Row: 0, Col: 0, Pressed: true},
+				{Key: 5, Row: 1, Col: 0, Pressed: true},
+			},
+			want: []KeyEvent{
+				{Key: 5, Row: 1, Col: 0, Pressed: true},
+			},
+		},
+		{
+			name: "map",
+			mw: []Middleware{MapEvents(func(ev KeyEvent) KeyEvent {
+				if ev.Row == 0 && ev.Col == 0 {
+					ev.Key, ev.Row, ev.Col = 14, 2, 4
+				}
+				return ev
+			})},
+			events: []KeyEvent{
+				{Key: 0, Row: 0, Col: 0, Pressed: true},
+				{Key: 1, Row: 0, Col: 1, Pressed: true},
+			},
+			want: []KeyEvent{
+				{Key: 14, Row: 2, Col: 4, Pressed: true},
+				{Key: 1, Row: 0, Col: 1, Pressed: true},
+			},
+		},
+		{
+			name: "rate limit",
+			mw:   []Middleware{RateLimitEvents(100 * time.Millisecond)},
+			events: []KeyEvent{
+				{Time: at(0), Key: 1, Pressed: true},
+				{Time: at(10), Key: 1},
+				{Time: at(50), Key: 1, Pressed: true},
+				{Time: at(60), Key: 2, Pressed: true},
+				{Time: at(70), Key: 1},
+				{Time: at(80), Key: 2},
+				{Time: at(100), Key: 1, Pressed: true},
+				{Time: at(110), Key: 1},
+				{Lagged: 1},
+			},
+			want: []KeyEvent{
+				{Time: at(0), Key: 1, Pressed: true},
+				{Time: at(10), Key: 1},
+				{Time: at(60), Key: 2, Pressed: true},
+				{Time: at(80), Key: 2},
+				{Time: at(100), Key: 1, Pressed: true},
+				{Time: at(110), Key: 1},
+				{Lagged: 1},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var log []string
+			mw := test.mw
+			if test.log != nil {
+				mw = append(mw, LogEvents(func(format string, args ...any) {
+					log = append(log, fmt.Sprintf(format, args...))
+				}))
+			}
+			var got []KeyEvent
+			h := Chain(func(ev KeyEvent) bool {
+				got = append(got, ev)
+				return true
+			}, mw...)
+			for _, ev := range test.events {
+				h(ev)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("unexpected events:\ngot: %v\nwant:%v", got, test.want)
+			}
+			if !reflect.DeepEqual(log, test.log) {
+				t.Errorf("unexpected log:\ngot: %q\nwant:%q", log, test.log)
+			}
+		})
+	}
+}
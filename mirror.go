@@ -0,0 +1,170 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+)
+
+// mirrorMu serialises changes to the mirror registration of all Decks so
+// that chains of mirrors can be detected.
+var mirrorMu sync.Mutex
+
+// Mirror replicates the key images written to a source Deck onto one or
+// more target Decks, for example so that a presenter and an operator see
+// identical panels. Images are rescaled to the key size of each target.
+// Keys are matched by row and column, and keys of the source that are
+// outside the layout of a target are not shown on it. Brightness and
+// resets are not mirrored.
+//
+// Images are written to the targets by the goroutine writing to the
+// source, after the source has been written.
+type Mirror struct {
+	src     *Deck
+	targets []*Deck
+	errFn   func(target *Deck, err error)
+
+	mu      sync.Mutex
+	stopped bool
+}
+
+// NewMirror starts mirroring src onto the targets. The images currently
+// shown on src are written to the targets before NewMirror returns. If
+// errFn is not nil, it is called with errors writing to a target;
+// mirroring continues after an error. errFn must not call Stop or close
+// src or a target. A target may not be the source of a Mirror, and src
+// may not be the target of a Mirror.
+func NewMirror(src *Deck, errFn func(target *Deck, err error), targets ...*Deck) (*Mirror, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("no mirror targets")
+	}
+	seen := make(map[*Deck]bool)
+	for _, t := range targets {
+		switch {
+		case t == nil:
+			return nil, errors.New("nil mirror target")
+		case t == src:
+			return nil, errors.New("mirror target is source")
+		case seen[t]:
+			return nil, errors.New("duplicate mirror target")
+		}
+		seen[t] = true
+	}
+	m := &Mirror{
+		src:     src,
+		targets: append([]*Deck(nil), targets...),
+		errFn:   errFn,
+	}
+
+	mirrorMu.Lock()
+	if len(src.mirrored) != 0 {
+		mirrorMu.Unlock()
+		return nil, errors.New("mirror source is a mirror target")
+	}
+	for _, t := range targets {
+		if len(t.mirrorsOf()) != 0 {
+			mirrorMu.Unlock()
+			return nil, errors.New("mirror target is a mirror source")
+		}
+	}
+	for _, t := range targets {
+		t.mirrored = append(t.mirrored, m)
+	}
+	mirrors := append([]*Mirror(nil), src.mirrorsOf()...)
+	mirrors = append(mirrors, m)
+	src.mirrors.Store(&mirrors)
+	mirrorMu.Unlock()
+
+	src.mu.Lock()
+	images := append([]image.Image(nil), src.images...)
+	src.mu.Unlock()
+	for key, img := range images {
+		if img != nil {
+			m.write(key, img)
+		}
+	}
+	return m, nil
+}
+
+// Stop stops mirroring. Images already written to the targets are left
+// in place.
+func (m *Mirror) Stop() {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return
+	}
+	m.stopped = true
+	m.mu.Unlock()
+
+	mirrorMu.Lock()
+	defer mirrorMu.Unlock()
+	for _, t := range m.targets {
+		t.mirrored = without(t.mirrored, m)
+	}
+	mirrors := without(m.src.mirrorsOf(), m)
+	m.src.mirrors.Store(&mirrors)
+}
+
+// without returns a copy of mirrors with m removed.
+func without(mirrors []*Mirror, m *Mirror) []*Mirror {
+	var rest []*Mirror
+	for _, o := range mirrors {
+		if o != m {
+			rest = append(rest, o)
+		}
+	}
+	return rest
+}
+
+// write writes img to the key of each target at the row and column of
+// the source's key number.
+func (m *Mirror) write(key int, img image.Image) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return
+	}
+	row, col := key/m.src.desc.cols, key%m.src.desc.cols
+	for _, t := range m.targets {
+		if !t.desc.visual || t.desc.rows <= row || t.desc.cols <= col {
+			continue
+		}
+		err := t.SetImage(row, col, img)
+		if err != nil && m.errFn != nil {
+			m.errFn(t, fmt.Errorf("mirror key %d,%d: %w", row, col, err))
+		}
+	}
+}
+
+// mirror replicates img on key to the receiver's mirrors.
+func (d *Deck) mirror(key int, img image.Image) {
+	for _, m := range d.mirrorsOf() {
+		m.write(key, img)
+	}
+}
+
+// mirrorsOf returns the mirrors with the receiver as source. The returned
+// slice must not be modified.
+func (d *Deck) mirrorsOf() []*Mirror {
+	mirrors := d.mirrors.Load()
+	if mirrors == nil {
+		return nil
+	}
+	return *mirrors
+}
+
+// stopMirrors stops the mirrors with the receiver as source or target.
+func (d *Deck) stopMirrors() {
+	mirrorMu.Lock()
+	mirrors := append(append([]*Mirror(nil), d.mirrorsOf()...), d.mirrored...)
+	mirrorMu.Unlock()
+	for _, m := range mirrors {
+		m.Stop()
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func TestMirror(t *testing.T) {
+	newDeck := func(pid PID) *Deck {
+		t.Helper()
+		d, err := newTestDeck(pid)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d.setDev(&virtDev{Writer: io.Discard, Closer: io.NopCloser(nil)})
+		return d
+	}
+	src := newDeck(StreamDeckMK2)
+	mini := newDeck(StreamDeckMini)
+	failing := newDeck(StreamDeckMini)
+	failing.setDev(&virtDev{Writer: errWriter{}})
+
+	red := uniformRGBA(image.Rect(0, 0, 72, 72), color.RGBA{R: 0xff, A: 0xff})
+	blue := uniformRGBA(image.Rect(0, 0, 72, 72), color.RGBA{B: 0xff, A: 0xff})
+	err := src.SetImage(0, 0, red)
+	if err != nil {
+		t.Fatalf("unexpected error setting image: %v", err)
+	}
+
+	var errs []error
+	m, err := NewMirror(src, func(target *Deck, err error) {
+		if target != failing {
+			t.Errorf("unexpected target for error: %v", err)
+		}
+		errs = append(errs, err)
+	}, mini, failing)
+	if err != nil {
+		t.Fatalf("unexpected error creating mirror: %v", err)
+	}
+
+	// Images already shown are replicated when the mirror starts,
+	// and later images as they are written, rescaled to the
+	// target's key size.
+	checkKey := func(d *Deck, key int, want color.Color) {
+		t.Helper()
+		var img image.Image
+		if d.images != nil {
+			img = d.images[key]
+		}
+		if img == nil {
+			if want != nil {
+				t.Errorf("missing image for key %d", key)
+			}
+			return
+		}
+		if want == nil {
+			t.Errorf("unexpected image for key %d", key)
+			return
+		}
+		img = d.fit(img)
+		if got := img.Bounds().Size(); got != (image.Point{X: 80, Y: 80}) {
+			t.Errorf("unexpected image size for key %d: got:%v want:80x80", key, got)
+		}
+		got := color.RGBAModel.Convert(img.At(40, 40))
+		if got != color.RGBAModel.Convert(want) {
+			t.Errorf("unexpected colour for key %d: got:%v want:%v", key, got, want)
+		}
+	}
+	checkKey(mini, mini.Key(0, 0), red.At(0, 0))
+
+	err = src.SetImage(1, 2, blue)
+	if err != nil {
+		t.Fatalf("unexpected error setting image: %v", err)
+	}
+	checkKey(mini, mini.Key(1, 2), blue.At(0, 0))
+
+	// Keys outside the target's layout are not shown.
+	err = src.SetImage(2, 4, blue)
+	if err != nil {
+		t.Fatalf("unexpected error setting image: %v", err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("unexpected number of target errors: got:%d want:2", len(errs))
+	}
+
+	if _, err := NewMirror(mini, nil, src); err == nil {
+		t.Error("expected error for mirror target as source")
+	}
+	if _, err := NewMirror(newDeck(StreamDeckMK2), nil, src); err == nil {
+		t.Error("expected error for mirror source as target")
+	}
+	if _, err := NewMirror(src, nil, src); err == nil {
+		t.Error("expected error for mirror of source onto itself")
+	}
+
+	m.Stop()
+	m.Stop()
+	err = src.SetImage(0, 1, red)
+	if err != nil {
+		t.Fatalf("unexpected error setting image: %v", err)
+	}
+	checkKey(mini, mini.Key(0, 1), nil)
+	if len(errs) != 2 {
+		t.Errorf("unexpected target error after stop: %v", errs[len(errs)-1])
+	}
+	if len(src.mirrorsOf()) != 0 || len(mini.mirrored) != 0 {
+		t.Errorf("mirror registration not removed: mirrors:%d mirrored:%d", len(src.mirrorsOf()), len(mini.mirrored))
+	}
+
+	// A stopped mirror's target may be used as a source.
+	m, err = NewMirror(mini, nil, newDeck(StreamDeckMini))
+	if err != nil {
+		t.Fatalf("unexpected error creating mirror: %v", err)
+	}
+	m.Stop()
+
+	// Closing a source or target stops its mirrors.
+	for _, closeSrc := range []bool{true, false} {
+		src := newDeck(StreamDeckMK2)
+		dst := newDeck(StreamDeckMini)
+		_, err = NewMirror(src, nil, dst)
+		if err != nil {
+			t.Fatalf("unexpected error creating mirror: %v", err)
+		}
+		if closeSrc {
+			src.Close()
+		} else {
+			dst.Close()
+		}
+		if len(src.mirrorsOf()) != 0 || len(dst.mirrored) != 0 {
+			t.Errorf("mirror registration not removed on close of source=%t: mirrors:%d mirrored:%d",
+				closeSrc, len(src.mirrorsOf()), len(dst.mirrored))
+		}
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
@@ -0,0 +1,94 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ninepatch provides border-preserving image scaling for Stream Deck
+// key frames.
+//
+// A nine-patch image is divided into a three by three grid by its insets.
+// When the image is rendered at a new size the corners are drawn unscaled,
+// the edges are scaled along their length and the centre is scaled in both
+// directions. This allows a single frame asset to be used for keys of all
+// sizes without blurring or stretching its border.
+package ninepatch
+
+import (
+	"fmt"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// Insets are the widths in pixels of the fixed borders of a nine-patch image.
+type Insets struct {
+	Top, Left, Bottom, Right int
+}
+
+// NinePatch is a nine-patch image.
+type NinePatch struct {
+	src    image.Image
+	insets Insets
+}
+
+// New returns a new NinePatch using the provided source image and insets.
+// The insets must be non-negative and must fit within the bounds of src.
+func New(src image.Image, insets Insets) (*NinePatch, error) {
+	b := src.Bounds()
+	switch {
+	case insets.Top < 0, insets.Left < 0, insets.Bottom < 0, insets.Right < 0:
+		return nil, fmt.Errorf("negative inset: %+v", insets)
+	case insets.Left+insets.Right > b.Dx(), insets.Top+insets.Bottom > b.Dy():
+		return nil, fmt.Errorf("insets %+v do not fit image bounds %v", insets, b)
+	}
+	return &NinePatch{src: src, insets: insets}, nil
+}
+
+// Render returns the nine-patch image scaled to the given bounds.
+func (p *NinePatch) Render(bounds image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(bounds)
+	p.Draw(dst, bounds)
+	return dst
+}
+
+// Draw draws the nine-patch image scaled to fill r in dst. If r is too
+// small to hold the borders unscaled, the borders are reduced in proportion
+// and the centre is not drawn.
+func (p *NinePatch) Draw(dst draw.Image, r image.Rectangle) {
+	sb := p.src.Bounds()
+	sx := splits(sb.Min.X, sb.Max.X, p.insets.Left, p.insets.Right)
+	sy := splits(sb.Min.Y, sb.Max.Y, p.insets.Top, p.insets.Bottom)
+	left, right := fitBorders(p.insets.Left, p.insets.Right, r.Dx())
+	top, bottom := fitBorders(p.insets.Top, p.insets.Bottom, r.Dy())
+	dx := splits(r.Min.X, r.Max.X, left, right)
+	dy := splits(r.Min.Y, r.Max.Y, top, bottom)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			s := image.Rect(sx[j], sy[i], sx[j+1], sy[i+1])
+			d := image.Rect(dx[j], dy[i], dx[j+1], dy[i+1])
+			if s.Empty() || d.Empty() {
+				continue
+			}
+			if s.Size() == d.Size() {
+				draw.Draw(dst, d, p.src, s.Min, draw.Src)
+			} else {
+				draw.BiLinear.Scale(dst, d, p.src, s, draw.Src, nil)
+			}
+		}
+	}
+}
+
+// splits returns the grid lines dividing the interval [min, max) into
+// a leading border of width a, a centre and a trailing border of width b.
+func splits(min, max, a, b int) [4]int {
+	return [4]int{min, min + a, max - b, max}
+}
+
+// fitBorders returns the border widths a and b reduced in proportion to
+// fit within n if necessary.
+func fitBorders(a, b, n int) (int, int) {
+	if a+b <= n {
+		return a, b
+	}
+	a = a * n / (a + b)
+	return a, n - a
+}
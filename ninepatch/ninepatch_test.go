@@ -0,0 +1,107 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ninepatch
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+var (
+	red   = color.RGBA{R: 0xff, A: 0xff}
+	green = color.RGBA{G: 0xff, A: 0xff}
+	blue  = color.RGBA{B: 0xff, A: 0xff}
+)
+
+// frame returns a 9×9 image with 3 pixel red corners, green edges and
+// a blue centre.
+func frame() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 9, 9))
+	draw.Draw(img, img.Bounds(), image.NewUniform(green), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(3, 3, 6, 6), image.NewUniform(blue), image.Point{}, draw.Src)
+	for _, p := range []image.Point{{0, 0}, {6, 0}, {0, 6}, {6, 6}} {
+		draw.Draw(img, image.Rectangle{Min: p, Max: p.Add(image.Pt(3, 3))}, image.NewUniform(red), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+var newTests = []struct {
+	insets  Insets
+	wantErr error
+}{
+	{insets: Insets{Top: 3, Left: 3, Bottom: 3, Right: 3}},
+	{insets: Insets{Top: 9}},
+	{insets: Insets{Top: -1}, wantErr: errors.New("negative inset: {Top:-1 Left:0 Bottom:0 Right:0}")},
+	{insets: Insets{Left: 5, Right: 5}, wantErr: errors.New("insets {Top:0 Left:5 Bottom:0 Right:5} do not fit image bounds (0,0)-(9,9)")},
+}
+
+func TestNew(t *testing.T) {
+	for _, test := range newTests {
+		_, err := New(frame(), test.insets)
+		if !sameError(err, test.wantErr) {
+			t.Errorf("unexpected error for %+v: got:%v want:%v", test.insets, err, test.wantErr)
+		}
+	}
+}
+
+var renderTests = []struct {
+	bounds image.Rectangle
+	want   map[image.Point]color.RGBA
+}{
+	{
+		bounds: image.Rect(0, 0, 72, 72),
+		want: map[image.Point]color.RGBA{
+			{0, 0}: red, {2, 2}: red, {69, 0}: red, {71, 71}: red, {0, 69}: red,
+			{36, 0}: green, {36, 2}: green, {0, 36}: green, {71, 36}: green, {36, 71}: green,
+			{3, 3}: blue, {36, 36}: blue, {68, 68}: blue,
+		},
+	},
+	{
+		bounds: image.Rect(10, 10, 106, 106),
+		want: map[image.Point]color.RGBA{
+			{10, 10}: red, {12, 12}: red, {103, 103}: red,
+			{58, 10}: green, {10, 58}: green,
+			{13, 13}: blue, {58, 58}: blue, {102, 102}: blue,
+		},
+	},
+	{
+		bounds: image.Rect(0, 0, 4, 4),
+		want: map[image.Point]color.RGBA{
+			{0, 0}: red, {1, 1}: red, {2, 2}: red, {3, 3}: red,
+		},
+	},
+}
+
+func TestRender(t *testing.T) {
+	p, err := New(frame(), Insets{Top: 3, Left: 3, Bottom: 3, Right: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, test := range renderTests {
+		got := p.Render(test.bounds)
+		if got.Bounds() != test.bounds {
+			t.Errorf("unexpected bounds: got:%v want:%v", got.Bounds(), test.bounds)
+		}
+		for pt, want := range test.want {
+			if got := got.RGBAAt(pt.X, pt.Y); got != want {
+				t.Errorf("unexpected colour at %v for %v: got:%v want:%v", pt, test.bounds, got, want)
+			}
+		}
+	}
+}
+
+func sameError(a, b error) bool {
+	switch {
+	case a == nil && b == nil:
+		return true
+	case a == nil, b == nil, a.Error() != b.Error():
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"image/color"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+// Option is an option for opening a Deck.
+type Option func(*Deck)
+
+// WithQuirks forces the quirks in on to be used and the quirks in off
+// not to be used, irrespective of the device's firmware version.
+func WithQuirks(on, off Quirk) Option {
+	return func(d *Deck) {
+		d.quirksOn |= on
+		d.quirksOff |= off
+	}
+}
+
+// WithResetKeyStream sets whether the key stream is reset when the device
+// is opened or reconnected. The key stream is reset by default. Resetting
+// the key stream may blank the keys on some devices, so tools that only
+// inspect a deck that is being driven by another program should disable
+// the reset.
+func WithResetKeyStream(reset bool) Option {
+	return func(d *Deck) {
+		d.noResetKeyStream = !reset
+	}
+}
+
+// WithRecoverOnOpen sets the device to be recovered with Deck.Recover
+// when it is opened or reconnected, in place of the default key stream
+// reset. This is intended for devices that may have been left part way
+// through an image upload by a previous owner. Recovery is performed even
+// if the key stream reset has been disabled with WithResetKeyStream.
+func WithRecoverOnOpen(full bool) Option {
+	return func(d *Deck) {
+		d.recoverOnOpen = true
+		d.recoverFull = full
+	}
+}
+
+// WithPacing sets the minimum delay between image report writes. See
+// Deck.SetPacing.
+func WithPacing(delay time.Duration) Option {
+	return func(d *Deck) {
+		d.pacing = delay
+	}
+}
+
+// WithRestoreBrightness sets whether the last brightness set is reapplied
+// after a reset. See Deck.SetRestoreBrightness.
+func WithRestoreBrightness(restore bool) Option {
+	return func(d *Deck) {
+		d.SetRestoreBrightness(restore)
+	}
+}
+
+// WithBackground sets the letterbox background colour for key images.
+// See Deck.SetBackground.
+func WithBackground(c color.Color) Option {
+	return func(d *Deck) {
+		d.SetBackground(c)
+	}
+}
+
+// WithPadding sets the padding around key images. See Deck.SetPadding.
+func WithPadding(pixels int) Option {
+	return func(d *Deck) {
+		d.SetPadding(pixels)
+	}
+}
+
+// WithHighContrast sets whether key images are rendered in high contrast
+// mode. See Deck.SetHighContrast.
+func WithHighContrast(on bool) Option {
+	return func(d *Deck) {
+		d.highContrast = on
+	}
+}
+
+// WithDither sets the dithering method for BMP key images. See
+// Deck.SetDither.
+func WithDither(m Dither) Option {
+	return func(d *Deck) {
+		d.dither = m
+	}
+}
+
+// WithSharpen sets the amount of sharpening applied to scaled key images.
+// See Deck.SetSharpen.
+func WithSharpen(amount float64) Option {
+	return func(d *Deck) {
+		d.sharpen = amount
+	}
+}
+
+// WithDebounce sets the key debounce interval. See Deck.SetDebounce.
+func WithDebounce(interval time.Duration) Option {
+	return func(d *Deck) {
+		d.debounce = interval
+	}
+}
+
+// WithStuckTimeout sets the duration after which a held key is reported
+// as stuck. See Deck.SetStuckTimeout.
+func WithStuckTimeout(timeout time.Duration) Option {
+	return func(d *Deck) {
+		d.stuckTimeout = timeout
+	}
+}
+
+// WithThrottle sets whether animations are throttled to the Deck's frame
+// budget. See Deck.SetThrottle.
+func WithThrottle(throttle bool) Option {
+	return func(d *Deck) {
+		d.throttle = throttle
+	}
+}
+
+// WithClock sets the clock used to time animations. If clock is nil,
+// animation.SystemClock is used.
+func WithClock(clock animation.Clock) Option {
+	return func(d *Deck) {
+		d.clock = clock
+	}
+}
@@ -15,6 +15,8 @@ func _() {
 	_ = x[StreamDeckMK2-128]
 	_ = x[StreamDeckXL-108]
 	_ = x[StreamDeckPedal-134]
+	_ = x[StreamDeckStudio-170]
+	_ = x[StreamDeckMK2Scissor-165]
 }
 
 const (
@@ -24,6 +26,8 @@ const (
 	_PID_name_3 = "StreamDeckMK2"
 	_PID_name_4 = "StreamDeckPedal"
 	_PID_name_5 = "StreamDeckMiniV2"
+	_PID_name_6 = "StreamDeckMK2Scissor"
+	_PID_name_7 = "StreamDeckStudio"
 )
 
 var (
@@ -45,6 +49,10 @@ func (i PID) String() string {
 		return _PID_name_4
 	case i == 144:
 		return _PID_name_5
+	case i == 165:
+		return _PID_name_6
+	case i == 170:
+		return _PID_name_7
 	default:
 		return "PID(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
@@ -0,0 +1,403 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package plugin implements a subprocess protocol that allows Stream Deck
+// widgets to be written in any language and hosted by the program that
+// owns the device. Plugins run in their own processes, so a plugin that
+// crashes does not take the device owner down with it.
+//
+// A plugin is a program that exchanges JSON messages with its host, one
+// object per line, on its standard input and output. Each message has a
+// "type" field. A plugin owns a set of keys; it receives events only for
+// those keys and may only draw on them.
+//
+// The host sends the following messages to the plugin:
+//
+//	{"type":"init","device":"StreamDeckMK2","width":72,"height":72,"keys":[{"row":0,"col":1}]}
+//	{"type":"key","time":"2023-01-01T00:00:00Z","row":0,"col":1,"pressed":true}
+//	{"type":"error","message":"key 1,0 not owned by plugin"}
+//
+// The init message is sent once when the plugin is started and gives the
+// device model, the key image size in pixels and the plugin's keys. Error
+// messages are sent in response to requests that could not be completed.
+// When the host closes the plugin's standard input, the plugin should exit.
+//
+// The plugin sends the following render requests to the host:
+//
+//	{"type":"image","row":0,"col":1,"image":"<base64 encoded image file>"}
+//	{"type":"text","row":0,"col":1,"text":"12°C","size":18,"fg":"white","bg":"#004"}
+//
+// Image data may be in any format that has a decoder registered by the
+// host program. Text is rendered with the label package; size is in pixels
+// and defaults to 18, and the fg and bg colours are in the notation
+// accepted by layout.ParseColor and default to white and black. A plugin
+// that writes a line that is not a JSON object is terminated. The plugin's
+// standard error is not part of the protocol and may be used by the host
+// for logging.
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/label"
+	"github.com/kortschak/ardilla/layout"
+)
+
+const (
+	// maxMessage is the maximum length of a message
+	// line sent by a plugin.
+	maxMessage = 16 << 20
+
+	// queueLen is the number of messages that may be
+	// waiting to be sent to a plugin before key events
+	// are dropped.
+	queueLen = 64
+
+	// closeGrace is how long a plugin is given to exit
+	// after its input is closed before it is killed.
+	closeGrace = 2 * time.Second
+
+	defaultSize = 18
+)
+
+// Deck is the device that plugins draw on. It is satisfied by *ardilla.Deck.
+type Deck interface {
+	PID() ardilla.PID
+	Layout() (rows, cols int)
+	Bounds() (image.Rectangle, error)
+	SetImage(row, col int, img image.Image) error
+}
+
+// Plugin is a running plugin process.
+type Plugin struct {
+	cmd  *exec.Cmd
+	deck Deck
+	keys map[layout.Position]bool
+
+	bounds image.Rectangle
+
+	send chan interface{}
+	quit chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	mu     sync.Mutex
+	images map[layout.Position]image.Image
+	err    error
+}
+
+// Start starts cmd as a plugin that owns the given keys of d. The command's
+// Stdin and Stdout must not be set; they are used for the protocol.
+func Start(cmd *exec.Cmd, d Deck, keys []layout.Position) (*Plugin, error) {
+	if cmd.Stdin != nil || cmd.Stdout != nil {
+		return nil, errors.New("plugin stdin or stdout already set")
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no keys for plugin")
+	}
+	rows, cols := d.Layout()
+	owned := make(map[layout.Position]bool)
+	for _, k := range keys {
+		if k.Row < 0 || rows <= k.Row {
+			return nil, fmt.Errorf("row out of bounds: %d", k.Row)
+		}
+		if k.Col < 0 || cols <= k.Col {
+			return nil, fmt.Errorf("column out of bounds: %d", k.Col)
+		}
+		owned[k] = true
+	}
+	bounds, err := d.Bounds()
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		stdin.Close()
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Plugin{
+		cmd:    cmd,
+		deck:   d,
+		keys:   owned,
+		bounds: bounds,
+		send:   make(chan interface{}, queueLen),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+		images: make(map[layout.Position]image.Image),
+	}
+	p.send <- initMessage{
+		Type:   "init",
+		Device: d.PID().String(),
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+		Keys:   keys,
+	}
+	go p.write(stdin)
+	go p.read(stdout)
+	return p, nil
+}
+
+// initMessage is the first message sent to a plugin.
+type initMessage struct {
+	Type   string            `json:"type"`
+	Device string            `json:"device"`
+	Width  int               `json:"width"`
+	Height int               `json:"height"`
+	Keys   []layout.Position `json:"keys"`
+}
+
+// keyMessage is a key event sent to a plugin.
+type keyMessage struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Row     int       `json:"row"`
+	Col     int       `json:"col"`
+	Pressed bool      `json:"pressed"`
+}
+
+// errorMessage reports a failed request to a plugin.
+type errorMessage struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// request is a render request sent by a plugin.
+type request struct {
+	Type  string  `json:"type"`
+	Row   *int    `json:"row"`
+	Col   *int    `json:"col"`
+	Image []byte  `json:"image"`
+	Text  string  `json:"text"`
+	Size  float64 `json:"size"`
+	FG    string  `json:"fg"`
+	BG    string  `json:"bg"`
+}
+
+// Handle sends ev to the plugin if the plugin owns the event's key.
+// Handle returns whether the event was sent. Events are dropped if the
+// plugin is not keeping up with its input.
+func (p *Plugin) Handle(ev ardilla.KeyEvent) bool {
+	if ev.Stuck || ev.Lagged != 0 || ev.Err != nil {
+		return false
+	}
+	if !p.keys[layout.Position{Row: ev.Row, Col: ev.Col}] {
+		return false
+	}
+	msg := keyMessage{
+		Type:    "key",
+		Time:    ev.Time,
+		Row:     ev.Row,
+		Col:     ev.Col,
+		Pressed: ev.Pressed,
+	}
+	select {
+	case <-p.done:
+		return false
+	default:
+	}
+	select {
+	case p.send <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// Redraw sets the last image rendered by the plugin on each of its keys.
+// It is used to restore the plugin's keys after the deck has been reset
+// or the keys have been drawn over.
+func (p *Plugin) Redraw() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	for k, img := range p.images {
+		err := p.deck.SetImage(k.Row, k.Col, img)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %d,%d: %w", k.Row, k.Col, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Done returns a channel that is closed when the plugin process has
+// exited.
+func (p *Plugin) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait waits for the plugin process to exit and returns the reason it
+// exited.
+func (p *Plugin) Wait() error {
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Close closes the plugin's input and waits for it to exit, killing it
+// if it does not exit promptly. Close returns the result of Wait.
+func (p *Plugin) Close() error {
+	p.once.Do(func() { close(p.quit) })
+	select {
+	case <-p.done:
+	case <-time.After(closeGrace):
+		p.cmd.Process.Kill()
+	}
+	return p.Wait()
+}
+
+// write sends queued messages to the plugin until the plugin is closed
+// or exits.
+func (p *Plugin) write(w io.WriteCloser) {
+	defer w.Close()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case msg := <-p.send:
+			err := enc.Encode(msg)
+			if err != nil {
+				// The plugin has stopped reading; read
+				// will see it exit.
+				return
+			}
+		case <-p.quit:
+			return
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// read handles requests from the plugin until it closes its output, and
+// then waits for the process to exit.
+func (p *Plugin) read(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxMessage)
+	var err error
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req request
+		err = json.Unmarshal(line, &req)
+		if err != nil {
+			err = fmt.Errorf("invalid message: %w", err)
+			break
+		}
+		p.handle(req)
+	}
+	if err == nil {
+		err = sc.Err()
+	}
+	if err != nil {
+		p.cmd.Process.Kill()
+		// Drain the plugin's output so that Wait does
+		// not block on the pipe.
+		io.Copy(io.Discard, r)
+	}
+	waitErr := p.cmd.Wait()
+	if err == nil {
+		err = waitErr
+	}
+	p.mu.Lock()
+	p.err = err
+	p.mu.Unlock()
+	close(p.done)
+}
+
+// handle performs a render request, reporting failures to the plugin.
+func (p *Plugin) handle(req request) {
+	err := p.render(req)
+	if err == nil {
+		return
+	}
+	select {
+	case p.send <- errorMessage{Type: "error", Message: err.Error()}:
+	default:
+	}
+}
+
+// render renders the image described by req onto the requested key.
+func (p *Plugin) render(req request) error {
+	if req.Type != "image" && req.Type != "text" {
+		return fmt.Errorf("unknown request type: %q", req.Type)
+	}
+	if req.Row == nil || req.Col == nil {
+		return fmt.Errorf("%s request missing key", req.Type)
+	}
+	pos := layout.Position{Row: *req.Row, Col: *req.Col}
+	if !p.keys[pos] {
+		return fmt.Errorf("key %d,%d not owned by plugin", pos.Row, pos.Col)
+	}
+
+	var img image.Image
+	switch req.Type {
+	case "image":
+		var err error
+		img, _, err = image.Decode(bytes.NewReader(req.Image))
+		if err != nil {
+			return fmt.Errorf("key %d,%d: %w", pos.Row, pos.Col, err)
+		}
+	case "text":
+		size := req.Size
+		if size == 0 {
+			size = defaultSize
+		}
+		if size < 0 {
+			return fmt.Errorf("invalid font size: %v", size)
+		}
+		fg, err := colorOr(req.FG, color.White)
+		if err != nil {
+			return err
+		}
+		bg, err := colorOr(req.BG, color.Black)
+		if err != nil {
+			return err
+		}
+		img, err = label.Render(p.bounds, req.Text, size, fg, bg)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Retain the image even if setting it fails so that
+	// it is restored by Redraw.
+	p.images[pos] = img
+	err := p.deck.SetImage(pos.Row, pos.Col, img)
+	if err != nil {
+		return fmt.Errorf("key %d,%d: %w", pos.Row, pos.Col, err)
+	}
+	return nil
+}
+
+// colorOr returns the colour described by s, or def if s is empty.
+func colorOr(s string, def color.Color) (color.Color, error) {
+	if s == "" {
+		return def, nil
+	}
+	return layout.ParseColor(s)
+}
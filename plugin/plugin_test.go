@@ -0,0 +1,270 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/layout"
+)
+
+// TestHelperPlugin is not a test. It is run as a plugin process by the
+// tests below when the ARDILLA_TEST_PLUGIN environment variable is set to
+// the behaviour to perform.
+func TestHelperPlugin(t *testing.T) {
+	mode := os.Getenv("ARDILLA_TEST_PLUGIN")
+	if mode == "" {
+		t.Skip("helper process")
+	}
+	defer os.Exit(0)
+
+	out := json.NewEncoder(os.Stdout)
+	sc := bufio.NewScanner(os.Stdin)
+	if !sc.Scan() {
+		os.Exit(1)
+	}
+	var init struct {
+		Type   string
+		Device string
+		Width  int
+		Height int
+		Keys   []layout.Position
+	}
+	json.Unmarshal(sc.Bytes(), &init)
+	fmt.Fprintf(os.Stderr, "%+v\n", init)
+
+	switch mode {
+	case "garbage":
+		fmt.Println("not json")
+		select {}
+	case "crash":
+		os.Exit(3)
+	}
+
+	out.Encode(map[string]interface{}{"type": "text", "row": 0, "col": 1, "text": "", "bg": "red"})
+	out.Encode(map[string]interface{}{"type": "text", "row": 1, "col": 1, "text": "no"})
+	for sc.Scan() {
+		var msg struct {
+			Type    string
+			Message string
+			Row     int
+			Col     int
+			Pressed bool
+		}
+		json.Unmarshal(sc.Bytes(), &msg)
+		switch msg.Type {
+		case "error":
+			fmt.Fprintln(os.Stderr, msg.Message)
+		case "key":
+			if !msg.Pressed {
+				continue
+			}
+			img := image.NewRGBA(image.Rect(0, 0, init.Width, init.Height))
+			draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{G: 0xff, A: 0xff}), image.Point{}, draw.Src)
+			var buf bytes.Buffer
+			png.Encode(&buf, img)
+			out.Encode(map[string]interface{}{"type": "image", "row": msg.Row, "col": msg.Col, "image": buf.Bytes()})
+		}
+	}
+}
+
+func helper(mode string, stderr *syncBuffer) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperPlugin$")
+	cmd.Env = append(os.Environ(), "ARDILLA_TEST_PLUGIN="+mode)
+	cmd.Stderr = stderr
+	return cmd
+}
+
+func TestPlugin(t *testing.T) {
+	d := newTestDeck()
+	var stderr syncBuffer
+	keys := []layout.Position{{Row: 0, Col: 1}, {Row: 0, Col: 2}}
+	p, err := Start(helper("draw", &stderr), d, keys)
+	if err != nil {
+		t.Fatalf("unexpected error starting plugin: %v", err)
+	}
+
+	if !d.wait(layout.Position{Row: 0, Col: 1}, "red") {
+		t.Fatal("text request not rendered")
+	}
+	if p.Handle(ardilla.KeyEvent{Key: 5, Row: 1, Col: 0, Pressed: true}) {
+		t.Error("unexpected event sent for unowned key")
+	}
+	if !p.Handle(ardilla.KeyEvent{Key: 2, Row: 0, Col: 2, Pressed: true}) {
+		t.Error("event not sent for owned key")
+	}
+	if !d.wait(layout.Position{Row: 0, Col: 2}, "green") {
+		t.Fatal("image request not rendered")
+	}
+
+	d.clear()
+	err = p.Redraw()
+	if err != nil {
+		t.Errorf("unexpected error redrawing plugin keys: %v", err)
+	}
+	for _, k := range keys {
+		if _, ok := d.image(k); !ok {
+			t.Errorf("key %d,%d not redrawn", k.Row, k.Col)
+		}
+	}
+	if _, ok := d.image(layout.Position{Row: 1, Col: 1}); ok {
+		t.Error("plugin drew on unowned key")
+	}
+
+	err = p.Close()
+	if err != nil {
+		t.Errorf("unexpected error closing plugin: %v", err)
+	}
+	if p.Handle(ardilla.KeyEvent{Key: 2, Row: 0, Col: 2, Pressed: true}) {
+		t.Error("unexpected event sent to closed plugin")
+	}
+	log := stderr.String()
+	for _, want := range []string{
+		"Device:StreamDeckMK2 Width:72 Height:72 Keys:[{Row:0 Col:1} {Row:0 Col:2}]",
+		"key 1,1 not owned by plugin",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("plugin did not receive %q:\n%s", want, log)
+		}
+	}
+}
+
+func TestPluginFailure(t *testing.T) {
+	for _, test := range []struct {
+		mode string
+		want string
+	}{
+		{mode: "garbage", want: "invalid message: invalid character 'o' in literal null (expecting 'u')"},
+		{mode: "crash", want: "exit status 3"},
+	} {
+		t.Run(test.mode, func(t *testing.T) {
+			var stderr syncBuffer
+			p, err := Start(helper(test.mode, &stderr), newTestDeck(), []layout.Position{{}})
+			if err != nil {
+				t.Fatalf("unexpected error starting plugin: %v", err)
+			}
+			select {
+			case <-p.Done():
+			case <-time.After(10 * time.Second):
+				t.Fatal("plugin did not terminate")
+			}
+			err = p.Wait()
+			if err == nil || err.Error() != test.want {
+				t.Errorf("unexpected error: got:%v want:%s", err, test.want)
+			}
+		})
+	}
+}
+
+func TestStartErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		cmd  *exec.Cmd
+		keys []layout.Position
+		want string
+	}{
+		{name: "no keys", cmd: exec.Command("true"), want: "no keys for plugin"},
+		{name: "row", cmd: exec.Command("true"), keys: []layout.Position{{Row: 3}}, want: "row out of bounds: 3"},
+		{name: "col", cmd: exec.Command("true"), keys: []layout.Position{{Col: -1}}, want: "column out of bounds: -1"},
+		{name: "stdout", cmd: &exec.Cmd{Path: "true", Stdout: os.Stdout}, keys: []layout.Position{{}}, want: "plugin stdin or stdout already set"},
+	} {
+		_, err := Start(test.cmd, newTestDeck(), test.keys)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("unexpected error for %s: got:%v want:%s", test.name, err, test.want)
+		}
+	}
+}
+
+// testDeck is an MK2-sized Deck that records the images set on it.
+type testDeck struct {
+	mu     sync.Mutex
+	images map[layout.Position]image.Image
+	set    chan struct{}
+}
+
+func newTestDeck() *testDeck {
+	return &testDeck{images: make(map[layout.Position]image.Image), set: make(chan struct{}, 1)}
+}
+
+func (d *testDeck) PID() ardilla.PID                 { return ardilla.StreamDeckMK2 }
+func (d *testDeck) Layout() (rows, cols int)         { return 3, 5 }
+func (d *testDeck) Bounds() (image.Rectangle, error) { return image.Rect(0, 0, 72, 72), nil }
+
+func (d *testDeck) SetImage(row, col int, img image.Image) error {
+	d.mu.Lock()
+	d.images[layout.Position{Row: row, Col: col}] = img
+	d.mu.Unlock()
+	select {
+	case d.set <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (d *testDeck) image(k layout.Position) (image.Image, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img, ok := d.images[k]
+	return img, ok
+}
+
+func (d *testDeck) clear() {
+	d.mu.Lock()
+	d.images = make(map[layout.Position]image.Image)
+	d.mu.Unlock()
+}
+
+// wait waits for the centre of the image on key k to be the named colour.
+func (d *testDeck) wait(k layout.Position, colour string) bool {
+	timeout := time.After(10 * time.Second)
+	for {
+		if img, ok := d.image(k); ok {
+			b := img.Bounds()
+			r, g, _, _ := img.At(b.Min.X+b.Dx()/2, b.Min.Y+b.Dy()/2).RGBA()
+			switch {
+			case colour == "red" && r > 0x8000 && g < 0x8000,
+				colour == "green" && g > 0x8000 && r < 0x8000:
+				return true
+			}
+		}
+		select {
+		case <-d.set:
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,41 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// sysfsHIDRaw is the sysfs directory listing hidraw devices.
+var sysfsHIDRaw = "/sys/class/hidraw"
+
+// physicalPort returns the sysfs device path of the USB interface holding
+// the hidraw node at path, relative to the sysfs root, for example
+// /devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0. Unlike the hidraw
+// node, this path depends only on the USB port the device is connected
+// to. If the path cannot be resolved, path is returned.
+func physicalPort(path string) string {
+	name := filepath.Base(path)
+	if filepath.Dir(path) != "/dev" || !strings.HasPrefix(name, "hidraw") {
+		return path
+	}
+	dev, err := filepath.EvalSymlinks(filepath.Join(sysfsHIDRaw, name, "device"))
+	if err != nil {
+		return path
+	}
+	// The device directory is the HID device, which is named
+	// with an instance number that changes on reconnection, so
+	// use its parent, the USB interface.
+	root, err := filepath.EvalSymlinks(filepath.Dir(filepath.Dir(sysfsHIDRaw)))
+	if err != nil {
+		return path
+	}
+	port, ok := strings.CutPrefix(filepath.Dir(dev), root)
+	if !ok || !strings.HasPrefix(port, "/") {
+		return path
+	}
+	return port
+}
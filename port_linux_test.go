@@ -0,0 +1,51 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPhysicalPort(t *testing.T) {
+	// Mimic the sysfs layout, where the class entry and its device
+	// link both point into the devices tree.
+	root := t.TempDir()
+	intf := filepath.Join(root, "devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0")
+	hid := filepath.Join(intf, "0003:0FD9:0080.0004")
+	err := os.MkdirAll(filepath.Join(hid, "hidraw", "hidraw5"), 0o755)
+	if err != nil {
+		t.Fatalf("unexpected error making device directory: %v", err)
+	}
+	err = os.Symlink("../../../0003:0FD9:0080.0004", filepath.Join(hid, "hidraw", "hidraw5", "device"))
+	if err != nil {
+		t.Fatalf("unexpected error linking device: %v", err)
+	}
+	class := filepath.Join(root, "class", "hidraw")
+	err = os.MkdirAll(class, 0o755)
+	if err != nil {
+		t.Fatalf("unexpected error making class directory: %v", err)
+	}
+	err = os.Symlink(filepath.Join(hid, "hidraw", "hidraw5"), filepath.Join(class, "hidraw5"))
+	if err != nil {
+		t.Fatalf("unexpected error linking class entry: %v", err)
+	}
+	defer func(dir string) { sysfsHIDRaw = dir }(sysfsHIDRaw)
+	sysfsHIDRaw = class
+
+	for _, test := range []struct {
+		path string
+		want string
+	}{
+		{path: "/dev/hidraw5", want: "/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0"},
+		{path: "/dev/hidraw6", want: "/dev/hidraw6"},
+		{path: "1-2:1.0", want: "1-2:1.0"},
+	} {
+		if got := physicalPort(test.path); got != test.want {
+			t.Errorf("unexpected port for %s: got:%q want:%q", test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package ardilla
+
+// physicalPort returns path; the physical location of devices is not
+// resolved on this platform.
+func physicalPort(path string) string {
+	return path
+}
@@ -0,0 +1,130 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"image"
+	"sync"
+)
+
+// ErrQueueClosed is returned for operations submitted to a closed Queue.
+var ErrQueueClosed = errors.New("queue closed")
+
+// Queue is an asynchronous write queue for a Deck. Operations submitted
+// to a Queue are performed in order by a single writer goroutine, so
+// callers such as event handlers and user interface code do not block on
+// USB transfers. When the queue is full, submitting an operation blocks
+// until there is room.
+//
+// A Queue may be used concurrently with direct calls to the Deck's
+// methods, but the order of operations between the two is not defined.
+type Queue struct {
+	d   *Deck
+	ops chan queued
+
+	mu     sync.RWMutex // mu protects ops from sends after close.
+	closed bool
+	done   chan struct{}
+}
+
+// queued is an operation waiting in a Queue.
+type queued struct {
+	fn      func() error
+	pending *Pending
+}
+
+// Pending is the result of an operation submitted to a Queue.
+type Pending struct {
+	done chan struct{}
+	err  error
+}
+
+// Done returns a channel that is closed when the operation is complete.
+func (p *Pending) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait waits for the operation to complete and returns its error.
+func (p *Pending) Wait() error {
+	<-p.done
+	return p.err
+}
+
+// NewQueue returns a new Queue that holds up to size operations waiting to
+// be written to the receiver. The Queue must be closed with Close when no
+// longer needed.
+func (d *Deck) NewQueue(size int) *Queue {
+	q := &Queue{
+		d:    d,
+		ops:  make(chan queued, size),
+		done: make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// run performs queued operations until the queue is closed and drained.
+func (q *Queue) run() {
+	defer close(q.done)
+	for op := range q.ops {
+		op.pending.err = op.fn()
+		close(op.pending.done)
+	}
+}
+
+// submit adds fn to the queue, blocking while the queue is full.
+func (q *Queue) submit(fn func() error) *Pending {
+	p := &Pending{done: make(chan struct{})}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		p.err = ErrQueueClosed
+		close(p.done)
+		return p
+	}
+	q.ops <- queued{fn: fn, pending: p}
+	return p
+}
+
+// SetImage queues setting the image on the button at the given row and
+// column. See Deck.SetImage.
+func (q *Queue) SetImage(row, col int, img image.Image) *Pending {
+	return q.submit(func() error {
+		return q.d.SetImage(row, col, img)
+	})
+}
+
+// SetBrightness queues setting the brightness of the device. See
+// Deck.SetBrightness.
+func (q *Queue) SetBrightness(percent int) *Pending {
+	return q.submit(func() error {
+		return q.d.SetBrightness(percent)
+	})
+}
+
+// Reset queues resetting the device. See Deck.Reset.
+func (q *Queue) Reset() *Pending {
+	return q.submit(q.d.Reset)
+}
+
+// Flush waits until all operations submitted before the call have been
+// completed.
+func (q *Queue) Flush() {
+	q.submit(func() error { return nil }).Wait()
+}
+
+// Close stops the Queue accepting operations and waits for the operations
+// already submitted to complete. Close does not close the Deck.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.ops)
+	}
+	q.mu.Unlock()
+	<-q.done
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"image"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMini)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := &blockingWriter{release: make(chan struct{})}
+	d.setDev(&virtDev{Writer: w})
+
+	q := d.NewQueue(1)
+	img := image.NewRGBA(image.Rect(0, 0, 80, 80))
+	first := q.SetImage(0, 0, img)
+	second := q.SetBrightness(50)
+
+	select {
+	case <-first.Done():
+		t.Fatal("queued image write completed while device blocked")
+	default:
+	}
+	close(w.release)
+	if err := first.Wait(); err != nil {
+		t.Errorf("unexpected error for queued SetImage: %v", err)
+	}
+	if err := second.Wait(); err != nil {
+		t.Errorf("unexpected error for queued SetBrightness: %v", err)
+	}
+	if percent, ok := d.Brightness(); !ok || percent != 50 {
+		t.Errorf("unexpected brightness: got:%d %t want:50 true", percent, ok)
+	}
+	if got := d.images[d.Key(0, 0)]; got != image.Image(img) {
+		t.Error("key image not set by queued SetImage")
+	}
+
+	bad := q.SetImage(2, 0, img)
+	q.Flush()
+	select {
+	case <-bad.Done():
+	default:
+		t.Error("operation incomplete after Flush")
+	}
+	if want := errors.New("row out of bounds: 2"); !sameError(bad.Wait(), want) {
+		t.Errorf("unexpected error for invalid queued SetImage: got:%v want:%v", bad.Wait(), want)
+	}
+
+	q.Close()
+	if err := q.Reset().Wait(); err != ErrQueueClosed {
+		t.Errorf("unexpected error for closed queue: got:%v want:%v", err, ErrQueueClosed)
+	}
+	w.mu.Lock()
+	writes := w.writes
+	w.mu.Unlock()
+	if writes == 0 {
+		t.Error("no image data written")
+	}
+}
+
+// blockingWriter blocks all writes until release is closed.
+type blockingWriter struct {
+	release chan struct{}
+
+	mu     sync.Mutex
+	writes int
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	w.writes++
+	w.mu.Unlock()
+	return io.Discard.Write(b)
+}
@@ -0,0 +1,109 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Quirk is a set of device workarounds.
+type Quirk uint
+
+const (
+	// QuirkResetOnOpen sends an additional key stream reset when
+	// the device is opened.
+	QuirkResetOnOpen Quirk = 1 << iota
+
+	// QuirkSlowPacing inserts a delay between image report writes.
+	QuirkSlowPacing
+)
+
+// slowPacing is the delay between image report writes when
+// QuirkSlowPacing is in effect.
+const slowPacing = time.Millisecond
+
+// quirkRange is a set of quirks applying to a range of firmware versions.
+type quirkRange struct {
+	// min and max are the inclusive lower and exclusive upper
+	// firmware version bounds. An empty bound is unbounded.
+	min, max string
+
+	quirks Quirk
+}
+
+// quirksFor returns the quirks that apply to the device with the
+// provided firmware version.
+func (d *device) quirksFor(firmware string) Quirk {
+	var q Quirk
+	for _, r := range d.quirks {
+		if r.min != "" && compareVersions(firmware, r.min) < 0 {
+			continue
+		}
+		if r.max != "" && compareVersions(firmware, r.max) >= 0 {
+			continue
+		}
+		q |= r.quirks
+	}
+	return q
+}
+
+// compareVersions returns -1, 0 or 1 depending on whether the dotted
+// version a is less than, equal to or greater than b. Version elements
+// are compared numerically if they are both numbers and lexically
+// otherwise. Missing elements are less than present elements.
+func compareVersions(a, b string) int {
+	ae := strings.Split(a, ".")
+	be := strings.Split(b, ".")
+	for i := 0; i < len(ae) && i < len(be); i++ {
+		an, aErr := strconv.Atoi(ae[i])
+		bn, bErr := strconv.Atoi(be[i])
+		if aErr == nil && bErr == nil {
+			switch {
+			case an < bn:
+				return -1
+			case an > bn:
+				return 1
+			}
+			continue
+		}
+		if c := strings.Compare(ae[i], be[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(ae) < len(be):
+		return -1
+	case len(ae) > len(be):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Quirks returns the workarounds in use for the device.
+func (d *Deck) Quirks() Quirk {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.quirks
+}
+
+// setQuirks sets the quirks used by the deck based on the device's firmware
+// version and the quirks forced on or off by options.
+func (d *Deck) setQuirks() error {
+	var q Quirk
+	if len(d.desc.quirks) != 0 {
+		firmware, err := d.Firmware()
+		if err != nil {
+			return err
+		}
+		q = d.desc.quirksFor(firmware)
+	}
+	d.mu.Lock()
+	d.quirks = (q | d.quirksOn) &^ d.quirksOff
+	d.mu.Unlock()
+	return nil
+}
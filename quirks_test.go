@@ -0,0 +1,117 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var compareVersionsTests = []struct {
+	a, b string
+	want int
+}{
+	{a: "1.00.008", b: "1.00.008", want: 0},
+	{a: "1.00.008", b: "1.00.009", want: -1},
+	{a: "1.00.010", b: "1.00.009", want: 1},
+	{a: "2.0", b: "1.99.99", want: 1},
+	{a: "1.0", b: "1.0.1", want: -1},
+	{a: "1.0.1", b: "1.0", want: 1},
+	{a: "1.0.b", b: "1.0.a", want: 1},
+	{a: "", b: "1.0", want: -1},
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, test := range compareVersionsTests {
+		got := compareVersions(test.a, test.b)
+		if got != test.want {
+			t.Errorf("unexpected result for compareVersions(%q, %q): got:%d want:%d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+var quirksForTests = []struct {
+	firmware string
+	want     Quirk
+}{
+	{firmware: "0.9.0", want: QuirkResetOnOpen},
+	{firmware: "1.00.000", want: QuirkResetOnOpen | QuirkSlowPacing},
+	{firmware: "1.99.999", want: QuirkResetOnOpen | QuirkSlowPacing},
+	{firmware: "2.00.000", want: QuirkResetOnOpen},
+	{firmware: "3.00.000", want: 0},
+}
+
+func TestQuirksFor(t *testing.T) {
+	d := device{quirks: []quirkRange{
+		{max: "3", quirks: QuirkResetOnOpen},
+		{min: "1", max: "2", quirks: QuirkSlowPacing},
+	}}
+	for _, test := range quirksForTests {
+		got := d.quirksFor(test.firmware)
+		if got != test.want {
+			t.Errorf("unexpected result for quirksFor(%q): got:%b want:%b", test.firmware, got, test.want)
+		}
+	}
+}
+
+var setQuirksTests = []struct {
+	on, off Quirk
+	want    Quirk
+}{
+	{want: QuirkSlowPacing},
+	{on: QuirkResetOnOpen, want: QuirkResetOnOpen | QuirkSlowPacing},
+	{off: QuirkSlowPacing, want: 0},
+	{on: QuirkResetOnOpen, off: QuirkSlowPacing, want: QuirkResetOnOpen},
+}
+
+func TestDeckSetQuirks(t *testing.T) {
+	for _, test := range setQuirksTests {
+		t.Run(fmt.Sprintf("on=%b_off=%b", test.on, test.off), func(t *testing.T) {
+			d, err := newTestDeck(StreamDeckOriginal)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			d.desc.quirks = []quirkRange{{max: "2", quirks: QuirkSlowPacing}}
+			dev := &virtDev{Reader: strings.NewReader(padZero("xxxxx1.0.0", 17))}
+			d.setDev(dev)
+			WithQuirks(test.on, test.off)(d)
+
+			err = d.setQuirks()
+			if err != nil {
+				t.Errorf("unexpected error for setQuirks: %v", err)
+			}
+			if got := d.Quirks(); got != test.want {
+				t.Errorf("unexpected quirks: got:%b want:%b", got, test.want)
+			}
+			if len(dev.actions) != 1 {
+				t.Errorf("unexpected number of actions for setQuirks: %d", len(dev.actions))
+			}
+		})
+	}
+}
+
+func TestDeckPacing(t *testing.T) {
+	const delay = 10 * time.Millisecond
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var pkts packetCapture
+	d.setDev(&virtDev{Writer: &pkts})
+	d.SetPacing(delay)
+
+	img := &RawImage{rawImage{data: make([]byte, 3*d.desc.imgReportLen), pid: d.desc.PID}}
+	start := time.Now()
+	err = d.SetImage(0, 0, img)
+	if err != nil {
+		t.Fatalf("unexpected error for SetImage: %v", err)
+	}
+	elapsed := time.Since(start)
+	if want := time.Duration(len(pkts)-1) * delay; elapsed < want {
+		t.Errorf("unexpected time for %d packets: got:%v want:>=%v", len(pkts), elapsed, want)
+	}
+}
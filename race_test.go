@@ -0,0 +1,11 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build race
+
+package ardilla
+
+// raceEnabled is whether the race detector is enabled. Allocation
+// counts are not reliable when it is.
+const raceEnabled = true
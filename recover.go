@@ -0,0 +1,72 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import "time"
+
+// timeoutReader is implemented by HID devices that can wait for an input
+// report for a limited time. When no report arrives within the timeout,
+// ReadWithTimeout returns zero bytes and a nil error.
+type timeoutReader interface {
+	ReadWithTimeout([]byte, time.Duration) (int, error)
+}
+
+const (
+	// drainTimeout is how long to wait for each stale
+	// input report when draining the device.
+	drainTimeout = 10 * time.Millisecond
+
+	// drainLimit is the maximum number of stale input
+	// reports discarded by a drain, bounding the time
+	// spent on a device that reports continuously.
+	drainLimit = 256
+)
+
+// Recover returns the device to a known state after it has been left
+// part way through an operation, for example when a previous owner of
+// the device crashed during an image upload or after a write error. The
+// recovery sequence discards any pending input reports, if the device
+// supports reading with a timeout, and resets the key stream. If full is
+// true, the device is also reset with Reset, clearing all key images.
+//
+// Recover must not be called while key states are being read.
+func (d *Deck) Recover(full bool) error {
+	err := d.drain()
+	if err != nil {
+		return d.checkConnected(err)
+	}
+	err = d.ResetKeyStream()
+	if err == nil && d.Quirks()&QuirkResetOnOpen != 0 {
+		err = d.ResetKeyStream()
+	}
+	if err != nil {
+		return d.checkConnected(err)
+	}
+	if full {
+		return d.Reset()
+	}
+	return nil
+}
+
+// drain discards pending input reports from the device. Devices that do
+// not support reading with a timeout are not drained.
+func (d *Deck) drain() error {
+	r, ok := d.dev.(timeoutReader)
+	if !ok {
+		return nil
+	}
+	buf := make([]byte, d.desc.keyStatesOffset+d.Len())
+	for i := 0; i < drainLimit; i++ {
+		n, err := r.ReadWithTimeout(buf, drainTimeout)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// No report arrived before the timeout.
+			return nil
+		}
+	}
+	return nil
+}
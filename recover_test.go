@@ -0,0 +1,95 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeckRecover(t *testing.T) {
+	for _, full := range []bool{false, true} {
+		t.Run(fmt.Sprintf("full=%t", full), func(t *testing.T) {
+			d, err := newTestDeck(StreamDeckMK2)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dev := &timeoutDev{
+				virtDev: &virtDev{Writer: io.Discard},
+				pending: 3,
+			}
+			d.dev = dev
+
+			err = d.Recover(full)
+			if err != nil {
+				t.Fatalf("unexpected error for Recover: %v", err)
+			}
+			want := []string{
+				"ReadWithTimeout -> 1",
+				"ReadWithTimeout -> 1",
+				"ReadWithTimeout -> 1",
+				"ReadWithTimeout -> 0",
+				"SendFeatureReport 0x2", // Reset key stream.
+			}
+			if full {
+				want = append(want, "SendFeatureReport 0x3") // Reset.
+			}
+			got := summarise(dev.actions)
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("unexpected recovery sequence:\ngot: %q\nwant:%q", got, want)
+			}
+		})
+	}
+
+	// Read errors are not mistaken for the end of the drain.
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	readErr := errors.New("read failed")
+	d.dev = &timeoutDev{virtDev: &virtDev{Writer: io.Discard}, err: readErr}
+	err = d.drain()
+	if !errors.Is(err, readErr) {
+		t.Errorf("unexpected error for failed drain: got:%v want:%v", err, readErr)
+	}
+}
+
+// timeoutDev is a virtDev that supports reads with a timeout, returning
+// pending stale reports before timing out, or failing with err.
+type timeoutDev struct {
+	*virtDev
+	pending int
+	err     error // err is returned by reads with no data.
+}
+
+func (d *timeoutDev) ReadWithTimeout(b []byte, _ time.Duration) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	n := 0
+	if d.pending > 0 {
+		d.pending--
+		n = 1
+	}
+	d.actions = append(d.actions, fmt.Sprintf("ReadWithTimeout -> %d", n))
+	return n, nil
+}
+
+// summarise returns the device actions truncated to their report IDs.
+func summarise(actions []string) []string {
+	s := make([]string, len(actions))
+	for i, a := range actions {
+		if strings.HasPrefix(a, "SendFeatureReport(") {
+			id, _, _ := strings.Cut(strings.TrimPrefix(a, "SendFeatureReport([]byte{"), ",")
+			a = "SendFeatureReport " + id
+		}
+		s[i] = a
+	}
+	return s
+}
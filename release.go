@@ -0,0 +1,78 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import "errors"
+
+// ErrReleased is returned by operations on a Deck whose device has been
+// released with Release.
+var ErrReleased = errors.New("device released")
+
+// Release stops all running animations and closes the receiver's device
+// so that it may be opened by another process, for example by a daemon
+// running in another login session on the same seat. Operations on a
+// released Deck fail with ErrReleased until the device is reopened with
+// Reclaim. Decks created by NewDeckHID cannot be released.
+//
+// Key state reads in progress, for example by Poll or Events, end with
+// ErrReleased before the device is closed. Release waits for them, which
+// is at most a short interval for the hidapi and hidraw backends.
+func (d *Deck) Release() error {
+	if d.external {
+		return errors.New("cannot release user-provided device")
+	}
+	d.stopAnimations()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.dev.(releasedDevice); ok {
+		return nil
+	}
+	err := d.swapDevice(releasedDevice{})
+	d.images = nil
+	d.written = nil
+	return err
+}
+
+// Released returns whether the receiver's device has been released.
+func (d *Deck) Released() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.dev.(releasedDevice)
+	return ok
+}
+
+// Reclaim reopens the device released by Release. As with Reconnect, the
+// device must have the serial number of the original device, and the
+// brightness is restored if brightness restoration is enabled with
+// SetRestoreBrightness. Key images are not restored. If Reclaim fails,
+// the Deck remains released and Reclaim may be retried.
+func (d *Deck) Reclaim() error {
+	if !d.Released() {
+		return errors.New("device not released")
+	}
+	dev, err := d.desc.open(d.serial)
+	if err != nil {
+		if !d.connected() {
+			return ErrNotConnected
+		}
+		return err
+	}
+	err = d.reconnect(dev)
+	if err != nil {
+		d.mu.Lock()
+		d.swapDevice(releasedDevice{})
+		d.mu.Unlock()
+	}
+	return err
+}
+
+// releasedDevice is the HIDDevice of a released Deck.
+type releasedDevice struct{}
+
+func (releasedDevice) Read([]byte) (int, error)              { return 0, ErrReleased }
+func (releasedDevice) Write([]byte) (int, error)             { return 0, ErrReleased }
+func (releasedDevice) Close() error                          { return nil }
+func (releasedDevice) GetFeatureReport([]byte) (int, error)  { return 0, ErrReleased }
+func (releasedDevice) SendFeatureReport([]byte) (int, error) { return 0, ErrReleased }
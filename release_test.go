@@ -0,0 +1,164 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"errors"
+	"image"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeckRelease(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dev := &virtDev{Writer: io.Discard, Closer: io.NopCloser(nil)}
+	d.setDev(dev)
+
+	started := make(chan struct{})
+	a, err := d.Animate(context.Background(), 0, 0, blockingAnimation(started))
+	if err != nil {
+		t.Fatalf("unexpected error for Animate: %v", err)
+	}
+	<-started
+
+	if d.Released() {
+		t.Error("unexpected released deck")
+	}
+	err = d.Reclaim()
+	if err == nil {
+		t.Error("expected error reclaiming deck that is not released")
+	}
+	dev.actions = nil
+	err = d.Release()
+	if err != nil {
+		t.Fatalf("unexpected error for Release: %v", err)
+	}
+	select {
+	case <-a.Done():
+	default:
+		t.Error("animation not stopped by Release")
+	}
+	if !d.Released() {
+		t.Error("expected deck to be released")
+	}
+	if len(dev.actions) != 1 || dev.actions[0] != "Close() -> <nil>" {
+		t.Errorf("unexpected device actions: %q", dev.actions)
+	}
+	err = d.Release()
+	if err != nil {
+		t.Errorf("unexpected error for second Release: %v", err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for _, op := range []struct {
+		name string
+		fn   func() error
+	}{
+		{name: "SetImage", fn: func() error { return d.SetImage(0, 0, img) }},
+		{name: "SetBrightness", fn: func() error { return d.SetBrightness(50) }},
+		{name: "Reset", fn: d.Reset},
+		{name: "KeyStates", fn: func() error { _, err := d.KeyStates(); return err }},
+	} {
+		err = op.fn()
+		if !errors.Is(err, ErrReleased) {
+			t.Errorf("unexpected error for %s on released deck: got:%v want:%v", op.name, err, ErrReleased)
+		}
+	}
+
+	// The test deck cannot be reopened.
+	err = d.Reclaim()
+	if err == nil {
+		t.Error("expected error reclaiming test deck")
+	}
+	if !d.Released() {
+		t.Error("expected deck to remain released after failed Reclaim")
+	}
+}
+
+func TestDeckReleaseExternal(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.setDev(&virtDev{Writer: io.Discard})
+	d.external = true
+	err = d.Release()
+	if err == nil {
+		t.Error("expected error releasing user-provided device")
+	}
+}
+
+func TestDeckReleaseDuringRead(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dev := &pollDev{reading: make(chan struct{}, 1)}
+	d.dev = dev
+
+	done := make(chan error)
+	go func() {
+		_, _, err := d.Poll(nil)
+		done <- err
+	}()
+	<-dev.reading
+	err = d.Release()
+	if err != nil {
+		t.Fatalf("unexpected error for Release: %v", err)
+	}
+	err = <-done
+	if !errors.Is(err, ErrReleased) {
+		t.Errorf("unexpected error for Poll during Release: got:%v want:%v", err, ErrReleased)
+	}
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.readAfterClose {
+		t.Error("device read after it was closed")
+	}
+}
+
+// pollDev is a device with no key state reports that supports reads with
+// a timeout. It records whether it is read after being closed.
+type pollDev struct {
+	virtDev
+
+	reading chan struct{}
+
+	mu             sync.Mutex
+	closed         bool
+	readAfterClose bool
+}
+
+func (d *pollDev) ReadWithTimeout(b []byte, timeout time.Duration) (int, error) {
+	d.mu.Lock()
+	if d.closed {
+		d.readAfterClose = true
+	}
+	d.mu.Unlock()
+	select {
+	case d.reading <- struct{}{}:
+	default:
+	}
+	time.Sleep(timeout / 10)
+	d.mu.Lock()
+	if d.closed {
+		d.readAfterClose = true
+	}
+	d.mu.Unlock()
+	return 0, nil
+}
+
+func (d *pollDev) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// SendFeatureReport sends the feature report in b to the device. The first
+// byte of b must be the report ID.
+//
+// SendFeatureReport is intended for experimenting with device commands that
+// are not otherwise supported. Sending arbitrary reports may leave the
+// device in a state that is inconsistent with the Deck.
+func (d *Deck) SendFeatureReport(b []byte) (int, error) {
+	d.mu.Lock()
+	n, err := d.dev.SendFeatureReport(b)
+	d.mu.Unlock()
+	return n, d.checkConnected(err)
+}
+
+// GetFeatureReport gets a feature report from the device into b. The first
+// byte of b must be the report ID and b must be long enough to hold the
+// complete report. Devices may require additional request bytes following
+// the report ID.
+//
+// GetFeatureReport is intended for experimenting with device queries that
+// are not otherwise supported.
+func (d *Deck) GetFeatureReport(b []byte) (int, error) {
+	d.mu.Lock()
+	n, err := d.dev.GetFeatureReport(b)
+	d.mu.Unlock()
+	return n, d.checkConnected(err)
+}
+
+// featureString requests the named feature report using the request
+// prefix req and returns the NUL-terminated string starting at offset in
+// the response. If the response is shorter than payloadLen, a *ReportError
+// is returned.
+func (d *Deck) featureString(name string, req []byte, payloadLen, offset int) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := d.newReport(name, payloadLen, req)
+	r.put(byte(payloadLen))
+	buf, err := d.getReport(r)
+	if err != nil {
+		return "", err
+	}
+	buf = buf[offset:]
+	if idx := bytes.IndexByte(buf, 0); idx >= 0 {
+		buf = buf[:idx]
+	}
+	return string(buf), nil
+}
+
+// report is a feature report under construction.
+type report struct {
+	name string // name is the name of the report used in errors.
+	buf  []byte // buf holds the complete zero-padded report.
+	n    int    // n is the number of bytes written to buf.
+	err  error  // err is the first error in building the report.
+}
+
+// newReport returns a zeroed report of the given length with the provided
+// prefix, which must start with the report ID. The report is built in the
+// receiver's buffer, so the receiver's mutex must be held until the report
+// has been sent.
+func (d *Deck) newReport(name string, length int, prefix []byte) *report {
+	r := &report{name: name, buf: d.buf[:length]}
+	zero(r.buf)
+	r.put(prefix...)
+	return r
+}
+
+// put appends b to the report's payload. If b does not fit in the report,
+// the report is marked as invalid.
+func (r *report) put(b ...byte) {
+	if r.err != nil {
+		return
+	}
+	if len(b) > len(r.buf)-r.n {
+		r.err = fmt.Errorf("%s report: %d bytes overflows %d byte report", r.name, r.n+len(b), len(r.buf))
+		return
+	}
+	r.n += copy(r.buf[r.n:], b)
+}
+
+// bytes returns the complete report, or an error if the report could not
+// be built.
+func (r *report) bytes() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.n == 0 {
+		return nil, fmt.Errorf("%s report: missing report ID", r.name)
+	}
+	return r.buf, nil
+}
+
+// sendReport sends r to the device. The receiver's mutex must be held.
+func (d *Deck) sendReport(r *report) error {
+	b, err := r.bytes()
+	if err != nil {
+		return err
+	}
+	_, err = d.dev.SendFeatureReport(b)
+	return err
+}
+
+// getReport requests r from the device and returns the response. If the
+// response is shorter than the report, a *ReportError is returned. The
+// receiver's mutex must be held.
+func (d *Deck) getReport(r *report) ([]byte, error) {
+	b, err := r.bytes()
+	if err != nil {
+		return nil, err
+	}
+	n, err := d.dev.GetFeatureReport(b)
+	if err != nil {
+		return nil, d.checkConnected(err)
+	}
+	if n < len(b) {
+		return nil, &ReportError{Report: r.name, ID: b[0], Want: len(b), Got: n}
+	}
+	return b, nil
+}
+
+// ReportError is returned when a device responds to a feature report
+// request with fewer bytes than the report length for the device. This
+// may indicate a faulty cable or an unexpected firmware version.
+type ReportError struct {
+	Report string // Report is the name of the requested report.
+	ID     byte   // ID is the requested report ID.
+	Want   int    // Want is the expected report length.
+	Got    int    // Got is the length of the received report.
+}
+
+func (e *ReportError) Error() string {
+	return fmt.Sprintf("short %s report 0x%02x: got %d bytes, want %d", e.Report, e.ID, e.Got, e.Want)
+}
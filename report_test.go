@@ -0,0 +1,156 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDeckFeatureReports(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dev := &virtDev{Reader: strings.NewReader("\x05\x0cdata"), Writer: io.Discard}
+	d.setDev(dev)
+
+	n, err := d.SendFeatureReport([]byte{0x03, 0x0d, 0x01})
+	if err != nil {
+		t.Errorf("unexpected error for SendFeatureReport: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("unexpected length for SendFeatureReport: got:%d want:3", n)
+	}
+	buf := []byte{0x05, 0, 0, 0, 0, 0}
+	n, err = d.GetFeatureReport(buf)
+	if err != nil {
+		t.Errorf("unexpected error for GetFeatureReport: %v", err)
+	}
+	if want := []byte("\x05\x0cdata"); n != len(want) || !bytes.Equal(buf, want) {
+		t.Errorf("unexpected result for GetFeatureReport: got:%q want:%q", buf[:n], want)
+	}
+
+	want := []string{
+		"SendFeatureReport([]byte{0x3, 0xd, 0x1}) -> (3, <nil>)",
+		"GetFeatureReport([]byte{0x5, 0x0, 0x0, 0x0, 0x0, 0x0}) -> (6, <nil>)",
+	}
+	if len(dev.actions) != len(want) {
+		t.Fatalf("unexpected number of actions: got:%d want:%d", len(dev.actions), len(want))
+	}
+	for i, got := range dev.actions {
+		if got != want[i] {
+			t.Errorf("unexpected action %d:\ngot: %s\nwant:%s", i, got, want[i])
+		}
+	}
+}
+
+var shortReportTests = []struct {
+	pid     PID
+	data    string
+	op      func(*Deck) (string, error)
+	wantErr error
+}{
+	{
+		pid:     StreamDeckMK2,
+		data:    "\x06\x0cAL12",
+		op:      (*Deck).Serial,
+		wantErr: &ReportError{Report: "serial", ID: 0x06, Want: 32, Got: 6},
+	},
+	{
+		pid:     StreamDeckMini,
+		data:    "\x04\x0c\x00\x00\x001.0",
+		op:      (*Deck).Firmware,
+		wantErr: &ReportError{Report: "firmware", ID: 0x04, Want: 17, Got: 8},
+	},
+	{
+		pid:  StreamDeckMK2,
+		data: padZero("\x05\x0c\x00\x00\x00\x001.00.004", 32),
+		op:   (*Deck).Firmware,
+	},
+}
+
+func TestDeckShortReport(t *testing.T) {
+	for _, test := range shortReportTests {
+		d, err := newTestDeck(test.pid)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d.setDev(&virtDev{Reader: strings.NewReader(test.data)})
+		got, err := test.op(d)
+		if !sameError(err, test.wantErr) {
+			t.Errorf("unexpected error for %s: got:%v want:%v", test.pid, err, test.wantErr)
+		}
+		if err != nil {
+			if _, ok := err.(*ReportError); !ok {
+				t.Errorf("unexpected error type for %s: %T", test.pid, err)
+			}
+			if got != "" {
+				t.Errorf("unexpected result with error for %s: %q", test.pid, got)
+			}
+		}
+	}
+}
+
+func TestReportBuilder(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, test := range []struct {
+		name    string
+		length  int
+		prefix  []byte
+		payload []byte
+		want    []byte
+		wantErr error
+	}{
+		{
+			name:    "brightness",
+			length:  8,
+			prefix:  []byte{0x03, 0x08},
+			payload: []byte{50},
+			want:    []byte{0x03, 0x08, 50, 0, 0, 0, 0, 0},
+		},
+		{
+			name:    "full",
+			length:  4,
+			prefix:  []byte{0x03, 0x08},
+			payload: []byte{1, 2},
+			want:    []byte{0x03, 0x08, 1, 2},
+		},
+		{
+			name:    "overflow",
+			length:  4,
+			prefix:  []byte{0x03, 0x08},
+			payload: []byte{1, 2, 3},
+			wantErr: errors.New("overflow report: 5 bytes overflows 4 byte report"),
+		},
+		{
+			name:    "empty",
+			length:  4,
+			wantErr: errors.New("empty report: missing report ID"),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// Dirty the buffer to check that reports are zeroed.
+			for i := range d.buf {
+				d.buf[i] = 0xff
+			}
+			r := d.newReport(test.name, test.length, test.prefix)
+			r.put(test.payload...)
+			got, err := r.bytes()
+			if !sameError(err, test.wantErr) {
+				t.Errorf("unexpected error: got:%v want:%v", err, test.wantErr)
+			}
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("unexpected report:\ngot: %#v\nwant:%#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron is a parsed cron-like schedule. Each field is a bit set of the
+// matching values.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+
+	// domStar and dowStar are whether the day of month
+	// and day of week fields are unrestricted. When both
+	// are restricted, a day matches if either matches.
+	domStar, dowStar bool
+}
+
+// cronDescriptors are the named schedules accepted by parseCron.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses a five field cron specification of minute, hour, day
+// of month, month and day of week. Fields may be *, a value, a range a-b
+// or a comma-separated list of these, each optionally followed by a /n
+// step. Days of the week are numbered from Sunday, which may be 0 or 7.
+func parseCron(spec string) (cron, error) {
+	if s, ok := cronDescriptors[spec]; ok {
+		spec = s
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return cron{}, fmt.Errorf("invalid cron specification %q: want 5 fields", spec)
+	}
+	var (
+		c   cron
+		err error
+	)
+	for i, f := range []struct {
+		dst      *uint64
+		min, max int
+		name     string
+	}{
+		{dst: &c.minute, min: 0, max: 59, name: "minute"},
+		{dst: &c.hour, min: 0, max: 23, name: "hour"},
+		{dst: &c.dom, min: 1, max: 31, name: "day of month"},
+		{dst: &c.month, min: 1, max: 12, name: "month"},
+		{dst: &c.dow, min: 0, max: 7, name: "day of week"},
+	} {
+		*f.dst, err = parseCronField(fields[i], f.min, f.max)
+		if err != nil {
+			return cron{}, fmt.Errorf("invalid cron %s: %w", f.name, err)
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parseCronField returns the bit set of values described by field.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+		n := 1
+		if hasStep {
+			var err error
+			n, err = strconv.Atoi(step)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step: %q", step)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			lo, err = strconv.Atoi(a)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value: %q", a)
+			}
+			hi = lo
+			if isRange {
+				hi, err = strconv.Atoi(b)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value: %q", b)
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if lo < min || max < hi || hi < lo {
+				return 0, fmt.Errorf("value out of range: %q", rng)
+			}
+		}
+		for v := lo; v <= hi; v += n {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// matchDay returns whether the day of t matches the schedule.
+func (c cron) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<t.Weekday()) != 0
+	switch {
+	case c.domStar && c.dowStar:
+		return true
+	case c.domStar:
+		return dow
+	case c.dowStar:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// cronSearchLimit is the furthest next and prev search for a match.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// next returns the first time after t that matches the schedule. It
+// returns false if no match is found within five years.
+func (c cron) next(t time.Time) (time.Time, bool) {
+	limit := t.Add(cronSearchLimit)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Truncate(time.Minute).Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// prev returns the last time at or before t that matches the schedule and
+// is after since.
+func (c cron) prev(t, since time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	for t.After(since) {
+		switch {
+		case c.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()).Add(-time.Minute)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(-time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,91 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+var cronTests = []struct {
+	spec    string
+	wantErr bool
+	after   time.Time
+	next    time.Time
+	prev    time.Time
+}{
+	{
+		spec:  "*/15 * * * *",
+		after: date(2023, 3, 1, 10, 7),
+		next:  date(2023, 3, 1, 10, 15),
+		prev:  date(2023, 3, 1, 10, 0),
+	},
+	{
+		spec:  "0 9 * * 1-5",
+		after: date(2023, 3, 3, 9, 0), // Friday.
+		next:  date(2023, 3, 6, 9, 0),
+		prev:  date(2023, 3, 3, 9, 0),
+	},
+	{
+		spec:  "30 8,17 * * *",
+		after: date(2023, 3, 1, 12, 0),
+		next:  date(2023, 3, 1, 17, 30),
+		prev:  date(2023, 3, 1, 8, 30),
+	},
+	{
+		spec:  "@monthly",
+		after: date(2023, 12, 15, 0, 0),
+		next:  date(2024, 1, 1, 0, 0),
+		prev:  date(2023, 12, 1, 0, 0),
+	},
+	{
+		// Day of month or Sunday.
+		spec:  "0 0 13 * 7",
+		after: date(2023, 3, 6, 0, 0), // Monday.
+		next:  date(2023, 3, 12, 0, 0),
+		prev:  date(2023, 3, 5, 0, 0),
+	},
+	{
+		spec:  "0 0 29 2 *",
+		after: date(2023, 1, 1, 0, 0),
+		next:  date(2024, 2, 29, 0, 0),
+		prev:  date(2020, 2, 29, 0, 0),
+	},
+	{spec: "* * * *", wantErr: true},
+	{spec: "60 * * * *", wantErr: true},
+	{spec: "5-1 * * * *", wantErr: true},
+	{spec: "*/0 * * * *", wantErr: true},
+	{spec: "* * 0 * *", wantErr: true},
+	{spec: "@sometimes", wantErr: true},
+}
+
+func TestCron(t *testing.T) {
+	for _, test := range cronTests {
+		c, err := parseCron(test.spec)
+		if (err != nil) != test.wantErr {
+			t.Errorf("unexpected error for %q: %v", test.spec, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		next, ok := c.next(test.after)
+		if !ok || !next.Equal(test.next) {
+			t.Errorf("unexpected next time for %q after %v: got:%v %t want:%v", test.spec, test.after, next, ok, test.next)
+		}
+		prev, ok := c.prev(test.after, test.after.AddDate(-5, 0, 0))
+		if !ok || !prev.Equal(test.prev) {
+			t.Errorf("unexpected previous time for %q at %v: got:%v %t want:%v", test.spec, test.after, prev, ok, test.prev)
+		}
+		_, ok = c.prev(test.after, test.prev)
+		if ok {
+			t.Errorf("unexpected previous time for %q at %v at or before limit", test.spec, test.after)
+		}
+	}
+}
+
+func date(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+}
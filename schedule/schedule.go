@@ -0,0 +1,287 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package schedule shows Stream Deck layouts and key content according
+// to time-based schedules.
+//
+// A schedule is described in JSON. The following schedule shows a work
+// page during office hours and flashes a key red for the five minutes
+// before each hour:
+//
+//	{
+//		"layout": "home.json",
+//		"entries": [
+//			{"cron": "0 9 * * 1-5", "for": "8h", "layout": "work.json"},
+//			{"cron": "55 * * * *", "for": "5m", "key": {"row": 0, "col": 0, "color": "red"}, "flash": "500ms"}
+//		]
+//	}
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/layout"
+)
+
+// Schedule is a base layout and a set of scheduled changes to it.
+type Schedule struct {
+	// Layout is the path to the layout shown when no
+	// scheduled layout is active. If Layout is empty,
+	// an empty layout is used. Relative paths are
+	// resolved relative to the directory holding the
+	// schedule file.
+	Layout string `json:"layout,omitempty"`
+
+	// Entries is the set of scheduled changes. When
+	// more than one entry is active, later entries
+	// take precedence over earlier entries.
+	Entries []Entry `json:"entries"`
+
+	// dir is the directory relative paths are resolved against.
+	dir string
+}
+
+// Entry is a scheduled change to the deck's content.
+type Entry struct {
+	// Cron is the cron-like specification of the
+	// times the entry becomes active, in the local
+	// time zone. It holds five fields for minute,
+	// hour, day of month, month and day of week,
+	// or one of @hourly, @daily, @weekly, @monthly
+	// or @yearly.
+	Cron string `json:"cron"`
+
+	// For is how long the entry is active for
+	// after each time it becomes active.
+	For Duration `json:"for"`
+
+	// Layout is the path to a layout that replaces
+	// the schedule's layout while the entry is
+	// active. Relative paths are resolved relative
+	// to the directory holding the schedule file.
+	Layout string `json:"layout,omitempty"`
+
+	// Key is the content of a single key that is
+	// shown while the entry is active. Relative
+	// image paths are resolved relative to the
+	// directory holding the schedule file.
+	Key *layout.Key `json:"key,omitempty"`
+
+	// Flash is the period the key alternates
+	// between its content and black while the
+	// entry is active. If Flash is zero, the key
+	// does not flash.
+	Flash Duration `json:"flash,omitempty"`
+
+	cron cron
+}
+
+// Duration is a time.Duration that is represented in JSON in
+// time.Duration string format.
+type Duration time.Duration
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Load reads the schedule held in the JSON file at path.
+func Load(path string) (*Schedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s.dir = filepath.Dir(path)
+	return s, nil
+}
+
+// Unmarshal returns the schedule encoded in the JSON data. Relative paths
+// in the returned schedule are resolved relative to the current working
+// directory.
+func Unmarshal(data []byte) (*Schedule, error) {
+	var s Schedule
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&s)
+	if err != nil {
+		return nil, err
+	}
+	for i := range s.Entries {
+		e := &s.Entries[i]
+		e.cron, err = parseCron(e.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		switch {
+		case e.For <= 0:
+			return nil, fmt.Errorf("entry %d: invalid duration: %v", i, time.Duration(e.For))
+		case (e.Layout == "") == (e.Key == nil):
+			return nil, fmt.Errorf("entry %d: must have exactly one of layout or key", i)
+		case e.Flash < 0:
+			return nil, fmt.Errorf("entry %d: invalid flash period: %v", i, time.Duration(e.Flash))
+		case e.Flash != 0 && e.Key == nil:
+			return nil, fmt.Errorf("entry %d: flash without key", i)
+		}
+		if e.Key != nil && e.Key.Image == "" && e.Key.Color != "" {
+			_, err = layout.ParseColor(e.Key.Color)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i, err)
+			}
+		}
+	}
+	return &s, nil
+}
+
+// At returns the layout to show at the given time and the time after
+// which the layout may next change. The returned time is zero if no
+// change is scheduled within five years.
+func (s *Schedule) At(now time.Time) (l *layout.Layout, next time.Time, err error) {
+	page := s.Layout
+	var keys []layout.Key
+	for _, e := range s.Entries {
+		start, active := e.active(now)
+		if change, ok := e.cron.next(now); ok {
+			next = earliest(next, change)
+		}
+		if active {
+			next = earliest(next, start.Add(time.Duration(e.For)))
+		}
+		if !active {
+			continue
+		}
+		if e.Layout != "" {
+			page = e.Layout
+			keys = keys[:0]
+			continue
+		}
+		k := *e.Key
+		if k.Image != "" {
+			k.Image = s.path(k.Image)
+		}
+		if e.Flash > 0 {
+			period := time.Duration(e.Flash)
+			phase := now.Sub(start) / period
+			if phase%2 == 1 {
+				k = layout.Key{Row: k.Row, Col: k.Col}
+			}
+			next = earliest(next, start.Add((phase+1)*period))
+		}
+		keys = append(keys, k)
+	}
+
+	if page == "" {
+		l = &layout.Layout{}
+	} else {
+		l, err = layout.Load(s.path(page))
+		if err != nil {
+			return nil, next, err
+		}
+	}
+	for _, k := range keys {
+		if l.IsMasked(k.Row, k.Col) {
+			return nil, next, fmt.Errorf("key %d,%d: masked key has content", k.Row, k.Col)
+		}
+		replaced := false
+		for i, lk := range l.Keys {
+			if lk.Row == k.Row && lk.Col == k.Col {
+				l.Keys[i] = k
+				replaced = true
+			}
+		}
+		if !replaced {
+			l.Keys = append(l.Keys, k)
+		}
+	}
+	return l, next, nil
+}
+
+// apply applies l to d. Applying a layout with no keys leaves the deck
+// unchanged, so an empty layout is applied as all keys black to clear
+// expired key content.
+func apply(d *ardilla.Deck, l *layout.Layout) error {
+	if len(l.Keys) == 0 && len(l.Masked) == 0 {
+		if _, err := d.Bounds(); err == nil {
+			rows, cols := d.Layout()
+			for r := 0; r < rows; r++ {
+				for c := 0; c < cols; c++ {
+					l.Keys = append(l.Keys, layout.Key{Row: r, Col: c})
+				}
+			}
+		}
+	}
+	return l.Apply(d)
+}
+
+// earliest returns the earlier of a and b, treating a zero time as
+// later than any other.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || b.Before(a) {
+		return b
+	}
+	return a
+}
+
+// active returns whether the entry is active at now and the time that
+// it most recently became active.
+func (e *Entry) active(now time.Time) (start time.Time, ok bool) {
+	return e.cron.prev(now, now.Add(-time.Duration(e.For)))
+}
+
+// path returns the path to the named file, resolving relative paths
+// against the schedule's directory.
+func (s *Schedule) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.dir, name)
+}
+
+// Run applies the scheduled layout to d each time it may change, until
+// ctx is cancelled. Errors loading or applying a layout are passed to
+// errFn if it is not nil and do not stop Run.
+func Run(ctx context.Context, d *ardilla.Deck, s *Schedule, errFn func(error)) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		l, next, err := s.At(time.Now())
+		if err == nil {
+			err = apply(d, l)
+		}
+		if err != nil && errFn != nil {
+			errFn(err)
+		}
+		if next.IsZero() {
+			// Nothing further is scheduled.
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		timer.Reset(time.Until(next))
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schedule
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla/layout"
+)
+
+var unmarshalErrTests = []string{
+	`{"entries": [{"cron": "* * *", "for": "1m", "layout": "a.json"}]}`,
+	`{"entries": [{"cron": "* * * * *", "for": "0s", "layout": "a.json"}]}`,
+	`{"entries": [{"cron": "* * * * *", "for": "soon", "layout": "a.json"}]}`,
+	`{"entries": [{"cron": "* * * * *", "for": "1m"}]}`,
+	`{"entries": [{"cron": "* * * * *", "for": "1m", "layout": "a.json", "key": {"row": 0, "col": 0}}]}`,
+	`{"entries": [{"cron": "* * * * *", "for": "1m", "layout": "a.json", "flash": "1s"}]}`,
+	`{"entries": [{"cron": "* * * * *", "for": "1m", "key": {"row": 0, "col": 0, "color": "puce"}}]}`,
+	`{"entries": [], "unknown": true}`,
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, in := range unmarshalErrTests {
+		_, err := Unmarshal([]byte(in))
+		if err == nil {
+			t.Errorf("expected error for %s", in)
+		}
+	}
+}
+
+func TestScheduleAt(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"home.json": `{"keys": [{"row": 0, "col": 0, "color": "blue"}, {"row": 0, "col": 1, "color": "green"}]}`,
+		"work.json": `{"keys": [{"row": 0, "col": 0, "color": "white"}]}`,
+		"schedule.json": `{
+	"layout": "home.json",
+	"entries": [
+		{"cron": "0 9 * * 1-5", "for": "8h", "layout": "work.json"},
+		{"cron": "55 * * * *", "for": "5m", "key": {"row": 0, "col": 1, "color": "red"}, "flash": "30s"},
+		{"cron": "0 12 * * *", "for": "1h", "key": {"row": 1, "col": 0, "image": "lunch.png"}}
+	]
+}`,
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644)
+		if err != nil {
+			t.Fatalf("unexpected error writing %s: %v", name, err)
+		}
+	}
+	s, err := Load(filepath.Join(dir, "schedule.json"))
+	if err != nil {
+		t.Fatalf("unexpected error loading schedule: %v", err)
+	}
+
+	at := func(day, hour, min, sec int) time.Time {
+		// 2023-03-06 is a Monday.
+		return time.Date(2023, 3, day, hour, min, sec, 0, time.UTC)
+	}
+	for _, test := range []struct {
+		now      time.Time
+		wantKeys []layout.Key
+		wantNext time.Time
+	}{
+		{
+			now: at(6, 8, 0, 0),
+			wantKeys: []layout.Key{
+				{Row: 0, Col: 0, Color: "blue"},
+				{Row: 0, Col: 1, Color: "green"},
+			},
+			wantNext: at(6, 8, 55, 0),
+		},
+		{
+			now: at(6, 8, 55, 10),
+			wantKeys: []layout.Key{
+				{Row: 0, Col: 0, Color: "blue"},
+				{Row: 0, Col: 1, Color: "red"},
+			},
+			wantNext: at(6, 8, 55, 30),
+		},
+		{
+			now: at(6, 8, 55, 40),
+			wantKeys: []layout.Key{
+				{Row: 0, Col: 0, Color: "blue"},
+				{Row: 0, Col: 1},
+			},
+			wantNext: at(6, 8, 56, 0),
+		},
+		{
+			now: at(6, 12, 10, 0),
+			wantKeys: []layout.Key{
+				{Row: 0, Col: 0, Color: "white"},
+				{Row: 1, Col: 0, Image: filepath.Join(dir, "lunch.png")},
+			},
+			wantNext: at(6, 12, 55, 0),
+		},
+		{
+			now: at(11, 12, 10, 0), // Saturday.
+			wantKeys: []layout.Key{
+				{Row: 0, Col: 0, Color: "blue"},
+				{Row: 0, Col: 1, Color: "green"},
+				{Row: 1, Col: 0, Image: filepath.Join(dir, "lunch.png")},
+			},
+			wantNext: at(11, 12, 55, 0),
+		},
+	} {
+		l, next, err := s.At(test.now)
+		if err != nil {
+			t.Errorf("unexpected error at %v: %v", test.now, err)
+			continue
+		}
+		if !reflect.DeepEqual(l.Keys, test.wantKeys) {
+			t.Errorf("unexpected keys at %v:\ngot: %+v\nwant:%+v", test.now, l.Keys, test.wantKeys)
+		}
+		if !next.Equal(test.wantNext) {
+			t.Errorf("unexpected next change at %v: got:%v want:%v", test.now, next, test.wantNext)
+		}
+	}
+}
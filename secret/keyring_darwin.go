@@ -0,0 +1,49 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	Register("keyring", ProviderFunc(lookupKeyring))
+}
+
+// errItemNotFound is the exit status of security when the requested item
+// does not exist.
+const errItemNotFound = 44
+
+// lookupKeyring returns the password of the generic password item in the
+// login keychain with the service and account given by name, as stored by
+//
+//	security add-generic-password -s SERVICE -a USER -w
+func lookupKeyring(ctx context.Context, name string) (string, error) {
+	service, user, err := keyringEntry(name)
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "security", "find-generic-password", "-s", service, "-a", user, "-w")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == errItemNotFound:
+		return "", ErrNotFound
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("security: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("security: %w", err)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
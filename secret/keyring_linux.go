@@ -0,0 +1,47 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	Register("keyring", ProviderFunc(lookupKeyring))
+}
+
+// lookupKeyring returns the secret stored in the Secret Service keyring
+// with the service and user attributes given by name, as stored by
+//
+//	secret-tool store --label=LABEL service SERVICE user USER
+func lookupKeyring(ctx context.Context, name string) (string, error) {
+	service, user, err := keyringEntry(name)
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "secret-tool", "lookup", "service", service, "user", user)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && stderr.Len() == 0:
+		// secret-tool exits with status 1 and no
+		// message when the secret does not exist.
+		return "", ErrNotFound
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("secret-tool: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("secret-tool: %w", err)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
@@ -0,0 +1,166 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package secret resolves references to credentials, so that profiles can
+// name the API tokens they need, for example for OBS or Home Assistant,
+// without holding them in plain text.
+//
+// A reference has the form scheme:name. The following schemes are
+// registered by default:
+//
+//	env:NAME              the value of the environment variable NAME
+//	file:PATH             the contents of the file at PATH
+//	keyring:SERVICE/USER  the password for USER in the OS keyring entry for SERVICE
+//
+// Trailing newlines are removed from secrets read from files. On Unix-like
+// systems a secret file must not be accessible to group or other users.
+//
+// The keyring scheme is available on Linux, using secret-tool from
+// libsecret, and on macOS, using the security command. Other schemes may
+// be added with Register.
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when a referenced secret does not exist.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider looks up secrets.
+type Provider interface {
+	// Lookup returns the secret with the given
+	// name. The name is the part of a reference
+	// after the scheme. If the secret does not
+	// exist, Lookup returns an error wrapping
+	// ErrNotFound.
+	Lookup(ctx context.Context, name string) (string, error)
+}
+
+// ProviderFunc is a Provider implemented by a function.
+type ProviderFunc func(ctx context.Context, name string) (string, error)
+
+// Lookup calls f.
+func (f ProviderFunc) Lookup(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// providers is the registry of secret providers.
+var providers = struct {
+	sync.RWMutex
+	m map[string]Provider
+}{
+	m: map[string]Provider{
+		"env":  ProviderFunc(lookupEnv),
+		"file": ProviderFunc(lookupFile),
+	},
+}
+
+// Register registers p as the provider for references with the given
+// scheme, replacing any previously registered provider.
+func Register(scheme string, p Provider) {
+	if scheme == "" || strings.Contains(scheme, ":") {
+		panic(fmt.Sprintf("invalid secret scheme: %q", scheme))
+	}
+	providers.Lock()
+	defer providers.Unlock()
+	providers.m[scheme] = p
+}
+
+// Lookup returns the secret identified by ref.
+func Lookup(ctx context.Context, ref string) (string, error) {
+	scheme, name, ok := strings.Cut(ref, ":")
+	if !ok || name == "" {
+		return "", fmt.Errorf("invalid secret reference: %q", ref)
+	}
+	providers.RLock()
+	p, ok := providers.m[scheme]
+	providers.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown secret scheme: %q", scheme)
+	}
+	s, err := p.Lookup(ctx, name)
+	if err != nil {
+		// Only the reference is included in errors,
+		// never the secret.
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	return s, nil
+}
+
+// AppendEnv looks up the secret referenced for each environment variable
+// name in secrets and appends the NAME=value pairs to env in name order.
+// If env is nil, the current process's environment is used.
+func AppendEnv(ctx context.Context, env []string, secrets map[string]string) ([]string, error) {
+	if len(secrets) == 0 {
+		return env, nil
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" || strings.Contains(name, "=") {
+			return nil, fmt.Errorf("invalid environment variable name: %q", name)
+		}
+		s, err := Lookup(ctx, secrets[name])
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, name+"="+s)
+	}
+	return env, nil
+}
+
+func lookupEnv(_ context.Context, name string) (string, error) {
+	s, ok := os.LookupEnv(name)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return s, nil
+}
+
+func lookupFile(_ context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("secret file accessible to other users: mode %v", fi.Mode().Perm())
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// keyringEntry returns the service and user of a keyring secret name.
+func keyringEntry(name string) (service, user string, err error) {
+	service, user, ok := strings.Cut(name, "/")
+	if !ok || service == "" || user == "" {
+		return "", "", fmt.Errorf("invalid keyring entry: %q", name)
+	}
+	return service, user, nil
+}
@@ -0,0 +1,104 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	private := filepath.Join(dir, "private")
+	err := os.WriteFile(private, []byte("file-token\n"), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error writing secret file: %v", err)
+	}
+	public := filepath.Join(dir, "public")
+	err = os.WriteFile(public, []byte("leaked"), 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error writing secret file: %v", err)
+	}
+	err = os.Chmod(public, 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error setting file mode: %v", err)
+	}
+	t.Setenv("ARDILLA_TEST_TOKEN", "env-token")
+	Register("test", ProviderFunc(func(_ context.Context, name string) (string, error) {
+		if name != "known" {
+			return "", ErrNotFound
+		}
+		return "test-token", nil
+	}))
+
+	for _, test := range []struct {
+		ref      string
+		want     string
+		wantErr  error
+		wantText string
+	}{
+		{ref: "env:ARDILLA_TEST_TOKEN", want: "env-token"},
+		{ref: "env:ARDILLA_TEST_MISSING", wantErr: ErrNotFound},
+		{ref: "file:" + private, want: "file-token"},
+		{ref: "file:" + filepath.Join(dir, "missing"), wantErr: ErrNotFound},
+		{ref: "test:known", want: "test-token"},
+		{ref: "test:unknown", wantErr: ErrNotFound},
+		{ref: "vault:token", wantText: `unknown secret scheme: "vault"`},
+		{ref: "token", wantText: `invalid secret reference: "token"`},
+		{ref: "env:", wantText: `invalid secret reference: "env:"`},
+	} {
+		got, err := Lookup(context.Background(), test.ref)
+		switch {
+		case test.wantErr != nil:
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("unexpected error for %s: got:%v want:%v", test.ref, err, test.wantErr)
+			}
+		case test.wantText != "":
+			if err == nil || err.Error() != test.wantText {
+				t.Errorf("unexpected error for %s: got:%v want:%s", test.ref, err, test.wantText)
+			}
+		case err != nil:
+			t.Errorf("unexpected error for %s: %v", test.ref, err)
+		case got != test.want:
+			t.Errorf("unexpected secret for %s: got:%q want:%q", test.ref, got, test.want)
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		_, err = Lookup(context.Background(), "file:"+public)
+		if err == nil {
+			t.Error("expected error for secret file accessible to other users")
+		}
+	}
+}
+
+func TestAppendEnv(t *testing.T) {
+	t.Setenv("ARDILLA_TEST_TOKEN", "env-token")
+	env, err := AppendEnv(context.Background(), []string{"HOME=/home/user"}, map[string]string{
+		"TOKEN_B": "env:ARDILLA_TEST_TOKEN",
+		"TOKEN_A": "env:ARDILLA_TEST_TOKEN",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"HOME=/home/user", "TOKEN_A=env-token", "TOKEN_B=env-token"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("unexpected environment: got:%q want:%q", env, want)
+	}
+
+	_, err = AppendEnv(context.Background(), nil, map[string]string{"A=B": "env:ARDILLA_TEST_TOKEN"})
+	if err == nil {
+		t.Error("expected error for invalid variable name")
+	}
+	_, err = AppendEnv(context.Background(), nil, map[string]string{"TOKEN": "env:ARDILLA_TEST_MISSING"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("unexpected error for missing secret: got:%v want:%v", err, ErrNotFound)
+	}
+}
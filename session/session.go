@@ -0,0 +1,145 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package session blanks or dims a Stream Deck and suppresses its actions
+// while the user's desktop session is locked, so that a deck in a shared
+// space cannot be used to act on a locked machine.
+//
+// The lock state is read from systemd-logind's LockedHint session
+// property using loginctl on Linux, and from the Windows Terminal Services
+// session information on Windows. Locking is not detected on other
+// platforms.
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/action"
+)
+
+// ErrUnsupported is returned when the session lock state cannot be
+// determined on the platform.
+var ErrUnsupported = errors.New("session lock state not supported on this platform")
+
+// Locked returns whether the current user's session is locked.
+func Locked(ctx context.Context) (bool, error) {
+	return locked(ctx)
+}
+
+// Watch calls fn with the session's lock state when it is first read and
+// each time it changes, polling every interval, until ctx is cancelled or
+// the lock state cannot be read. Watch returns ctx.Err or the error
+// reading the lock state.
+func Watch(ctx context.Context, interval time.Duration, fn func(locked bool)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var (
+		last  bool
+		first = true
+	)
+	for {
+		l, err := locked(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if first || l != last {
+			fn(l)
+		}
+		last, first = l, false
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Guard blanks or dims a deck and suppresses a dispatcher's actions while
+// it is locked.
+type Guard struct {
+	// Deck is the guarded deck.
+	Deck *ardilla.Deck
+
+	// Dispatcher starts the deck's actions. If
+	// Dispatcher is nil, no actions are suppressed.
+	Dispatcher *action.Dispatcher
+
+	// Brightness is the brightness percentage of
+	// the deck while locked. Zero blanks the deck.
+	Brightness int
+
+	mu      sync.Mutex
+	locked  bool
+	restore int
+}
+
+// Run locks and unlocks the guard to follow the session's lock state,
+// polling every interval, until ctx is cancelled or the lock state cannot
+// be read. Errors setting the deck's brightness are passed to report if
+// it is not nil. Run returns ctx.Err or the error reading the lock state.
+func (g *Guard) Run(ctx context.Context, interval time.Duration, report func(error)) error {
+	return Watch(ctx, interval, func(locked bool) {
+		var err error
+		if locked {
+			err = g.Lock()
+		} else {
+			err = g.Unlock()
+		}
+		if err != nil && report != nil {
+			report(err)
+		}
+	})
+}
+
+// Lock suppresses the dispatcher's actions and sets the deck's brightness
+// to the guard's locked brightness. The previous brightness is restored by
+// Unlock. Actions are suppressed even if the brightness cannot be set.
+func (g *Guard) Lock() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.locked {
+		return nil
+	}
+	if g.Dispatcher != nil {
+		g.Dispatcher.Suppress(true)
+	}
+	g.locked = true
+	restore, ok := g.Deck.Brightness()
+	if !ok {
+		restore = 100
+	}
+	g.restore = restore
+	return g.Deck.SetBrightness(g.Brightness)
+}
+
+// Unlock restores the deck's brightness from before Lock and ends the
+// suppression of the dispatcher's actions. If no brightness had been set
+// on the deck before Lock, full brightness is restored.
+func (g *Guard) Unlock() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.locked {
+		return nil
+	}
+	g.locked = false
+	err := g.Deck.SetBrightness(g.restore)
+	if g.Dispatcher != nil {
+		g.Dispatcher.Suppress(false)
+	}
+	return err
+}
+
+// Locked returns whether the guard is locked.
+func (g *Guard) Locked() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.locked
+}
@@ -0,0 +1,44 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// locked returns the LockedHint property of the logind session of the
+// process, or of the user's graphical session if the process is not part
+// of a session, as is the case for user services. Screen lockers set the
+// hint when locking the session. See org.freedesktop.login1(5).
+func locked(ctx context.Context) (bool, error) {
+	id := os.Getenv("XDG_SESSION_ID")
+	if id == "" {
+		id = "auto"
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "loginctl", "show-session", id, "--property=LockedHint", "--value")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return false, fmt.Errorf("loginctl: %w: %s", err, msg)
+		}
+		return false, fmt.Errorf("loginctl: %w", err)
+	}
+	switch v := strings.TrimSpace(stdout.String()); v {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected LockedHint value: %q", v)
+	}
+}
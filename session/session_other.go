@@ -0,0 +1,13 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !windows
+
+package session
+
+import "context"
+
+func locked(context.Context) (bool, error) {
+	return false, ErrUnsupported
+}
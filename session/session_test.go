@@ -0,0 +1,71 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"testing"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/action"
+)
+
+// nullDev is a Stream Deck HID device that discards writes.
+type nullDev struct{}
+
+func (nullDev) Read(b []byte) (int, error)              { select {} }
+func (nullDev) Write(b []byte) (int, error)             { return len(b), nil }
+func (nullDev) Close() error                            { return nil }
+func (nullDev) GetFeatureReport(b []byte) (int, error)  { return len(b), nil }
+func (nullDev) SendFeatureReport(b []byte) (int, error) { return len(b), nil }
+
+func TestGuard(t *testing.T) {
+	for _, initial := range []struct {
+		set  bool
+		want int
+	}{
+		{set: true, want: 60},
+		{set: false, want: 100},
+	} {
+		d, err := ardilla.NewDeckHID(ardilla.StreamDeckMK2, nullDev{})
+		if err != nil {
+			t.Fatalf("unexpected error creating deck: %v", err)
+		}
+		if initial.set {
+			err = d.SetBrightness(initial.want)
+			if err != nil {
+				t.Fatalf("unexpected error setting brightness: %v", err)
+			}
+		}
+		p := action.New(d)
+		g := Guard{Deck: d, Dispatcher: p, Brightness: 10}
+
+		for i := 0; i < 2; i++ {
+			// Repeated locks must not lose the brightness
+			// to restore.
+			err = g.Lock()
+			if err != nil {
+				t.Fatalf("unexpected error locking: %v", err)
+			}
+		}
+		if !g.Locked() || !p.Suppressed() {
+			t.Errorf("unexpected lock state: guard:%t dispatcher:%t", g.Locked(), p.Suppressed())
+		}
+		if b, _ := d.Brightness(); b != 10 {
+			t.Errorf("unexpected locked brightness: got:%d want:10", b)
+		}
+
+		err = g.Unlock()
+		if err != nil {
+			t.Fatalf("unexpected error unlocking: %v", err)
+		}
+		if g.Locked() || p.Suppressed() {
+			t.Errorf("unexpected unlock state: guard:%t dispatcher:%t", g.Locked(), p.Suppressed())
+		}
+		if b, _ := d.Brightness(); b != initial.want {
+			t.Errorf("unexpected restored brightness: got:%d want:%d", b, initial.want)
+		}
+		p.Close()
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	wtsapi32 = syscall.NewLazyDLL("wtsapi32.dll")
+
+	procWTSQuerySessionInformationW = wtsapi32.NewProc("WTSQuerySessionInformationW")
+	procWTSFreeMemory               = wtsapi32.NewProc("WTSFreeMemory")
+)
+
+const (
+	wtsCurrentServerHandle = 0
+	wtsCurrentSession      = 0xffffffff
+	wtsSessionInfoEx       = 25
+
+	wtsSessionStateLock   = 0
+	wtsSessionStateUnlock = 1
+)
+
+// wtsInfoEx is the level 1 WTSINFOEXW structure.
+type wtsInfoEx struct {
+	Level        uint32
+	SessionID    uint32
+	SessionState int32
+	SessionFlags int32
+	// The remaining fields are not used.
+}
+
+// locked returns whether the session of the process is locked, from the
+// session flags reported by WTSQuerySessionInformation. Note that Windows 7
+// and Windows Server 2008 R2 report the lock flags inverted.
+func locked(_ context.Context) (bool, error) {
+	var (
+		info *wtsInfoEx
+		n    uint32
+	)
+	r, _, err := procWTSQuerySessionInformationW.Call(
+		wtsCurrentServerHandle,
+		wtsCurrentSession,
+		wtsSessionInfoEx,
+		uintptr(unsafe.Pointer(&info)),
+		uintptr(unsafe.Pointer(&n)),
+	)
+	if r == 0 {
+		return false, fmt.Errorf("WTSQuerySessionInformation: %w", err)
+	}
+	defer procWTSFreeMemory.Call(uintptr(unsafe.Pointer(info)))
+	if info.Level != 1 {
+		return false, fmt.Errorf("unexpected session information level: %d", info.Level)
+	}
+	switch info.SessionFlags {
+	case wtsSessionStateLock:
+		return true, nil
+	case wtsSessionStateUnlock:
+		return false, nil
+	default:
+		return false, ErrUnsupported
+	}
+}
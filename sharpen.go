@@ -0,0 +1,88 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// SetSharpen sets the amount of unsharp masking applied to key images
+// after they are scaled to fit a key, countering the softening of large
+// images scaled down to key size. An amount of zero disables sharpening
+// and amounts around one give moderate sharpening. Images that already
+// have the key size are not sharpened. The keys are redrawn with the new
+// amount, and a RawImage prepared with a different amount is scaled and
+// sharpened again when it is next set.
+func (d *Deck) SetSharpen(amount float64) error {
+	if amount < 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return fmt.Errorf("invalid sharpen amount: %v", amount)
+	}
+	if !d.desc.visual {
+		return nil
+	}
+	d.mu.Lock()
+	if d.sharpen == amount {
+		d.mu.Unlock()
+		return nil
+	}
+	d.sharpen = amount
+	d.mu.Unlock()
+	return d.rerender()
+}
+
+// Sharpen returns the amount of sharpening applied to scaled key images.
+func (d *Deck) Sharpen() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sharpen
+}
+
+// sharpen returns img sharpened with an unsharp mask of the given amount
+// using a 3×3 Gaussian blur.
+func sharpen(img image.Image, amount float64) *image.RGBA {
+	b := img.Bounds()
+	src := rgbaImage(img)
+	dst := image.NewRGBA(b)
+	kernel := [3]int{1, 2, 1}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var blur [3]int
+			for j, ky := range kernel {
+				yy := clampInt(y+j-1, b.Min.Y, b.Max.Y-1)
+				for i, kx := range kernel {
+					xx := clampInt(x+i-1, b.Min.X, b.Max.X-1)
+					c := src.RGBAAt(xx, yy)
+					w := kx * ky
+					blur[0] += w * int(c.R)
+					blur[1] += w * int(c.G)
+					blur[2] += w * int(c.B)
+				}
+			}
+			c := src.RGBAAt(x, y)
+			var out [3]uint8
+			for ch, v := range [3]int{int(c.R), int(c.G), int(c.B)} {
+				s := float64(v) + amount*float64(v-blur[ch]/16)
+				// Premultiplied values may not exceed alpha.
+				out[ch] = uint8(math.Round(math.Max(0, math.Min(float64(c.A), s))))
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: out[0], G: out[1], B: out[2], A: c.A})
+		}
+	}
+	return dst
+}
+
+// clampInt returns v clamped to [lo, hi].
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,109 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestSharpen(t *testing.T) {
+	// A vertical edge between dark and light grey.
+	img := uniformRGBA(image.Rect(0, 0, 8, 8), color.Gray{Y: 0x40})
+	for y := 0; y < 8; y++ {
+		for x := 4; x < 8; x++ {
+			img.Set(x, y, color.Gray{Y: 0xc0})
+		}
+	}
+	got := sharpen(img, 1)
+	for _, test := range []struct {
+		x    int
+		want func(uint8) bool
+		desc string
+	}{
+		{x: 0, want: func(v uint8) bool { return v == 0x40 }, desc: "unchanged"},
+		{x: 3, want: func(v uint8) bool { return v < 0x40 }, desc: "darker"},
+		{x: 4, want: func(v uint8) bool { return v > 0xc0 }, desc: "lighter"},
+		{x: 7, want: func(v uint8) bool { return v == 0xc0 }, desc: "unchanged"},
+	} {
+		c := got.RGBAAt(test.x, 4)
+		if !test.want(c.R) || c.R != c.G || c.G != c.B {
+			t.Errorf("unexpected value at x=%d: got:%#v want %s", test.x, c, test.desc)
+		}
+	}
+	if got := sharpen(img, 0); got.RGBAAt(3, 4) != img.RGBAAt(3, 4) {
+		t.Error("image altered with zero sharpening")
+	}
+}
+
+func TestDeckSetSharpen(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.setDev(&virtDev{Writer: io.Discard})
+
+	img := uniformRGBA(image.Rect(0, 0, 144, 144), color.Gray{Y: 0x80})
+	raw, err := d.RawImage(img)
+	if err != nil {
+		t.Fatalf("unexpected error for RawImage: %v", err)
+	}
+	err = d.SetSharpen(1.5)
+	if err != nil {
+		t.Fatalf("unexpected error for SetSharpen: %v", err)
+	}
+	if got := d.Sharpen(); got != 1.5 {
+		t.Errorf("unexpected sharpen amount: got:%v want:1.5", got)
+	}
+	if d.reusable(raw) {
+		t.Error("RawImage computed without sharpening reused after SetSharpen")
+	}
+
+	for _, amount := range []float64{-1, math.NaN(), math.Inf(1)} {
+		err = d.SetSharpen(amount)
+		if want := fmt.Errorf("invalid sharpen amount: %v", amount); !sameError(err, want) {
+			t.Errorf("unexpected error for SetSharpen(%v): got:%v want:%v", amount, err, want)
+		}
+	}
+}
+
+func TestDeckSetSharpenConcurrent(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.setDev(&virtDev{Writer: io.Discard})
+
+	// Run with -race to check that rendering does not
+	// read the sharpen setting without the deck lock.
+	// Images with the key size are used so that each
+	// render is quick and the race window is hit often.
+	img := uniformRGBA(image.Rect(0, 0, 72, 72), color.Gray{Y: 0x80})
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 100; i++ {
+			err := d.SetSharpen(float64(i % 2))
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for i := 0; i < 100; i++ {
+		err = d.SetImage(0, i%5, img)
+		if err != nil {
+			t.Errorf("unexpected error for SetImage: %v", err)
+		}
+	}
+	err = <-done
+	if err != nil {
+		t.Errorf("unexpected error for SetSharpen: %v", err)
+	}
+}
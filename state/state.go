@@ -0,0 +1,142 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package state provides a small persistent key-value store for
+// remembering the state of controls, such as toggle positions, across
+// program restarts.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Store is a key-value store held in a JSON file. Values are stored in
+// their JSON encoding. Each change is written to the file before the
+// method making it returns, replacing the file atomically so that a
+// crash does not leave it partially written.
+type Store struct {
+	path string
+
+	mu     sync.Mutex
+	values map[string]json.RawMessage
+}
+
+// Open returns a Store held in the file at path. If the file does not
+// exist, the store is empty and the file is created when a value is
+// first set.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, values: make(map[string]json.RawMessage)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &s.values)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get decodes the value stored for key into v. It returns false if there
+// is no value for key.
+func (s *Store) Get(key string, v interface{}) (ok bool, err error) {
+	s.mu.Lock()
+	b, ok := s.values[key]
+	s.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return true, fmt.Errorf("%s: %w", key, err)
+	}
+	return true, nil
+}
+
+// Set stores v for key and writes the store to its file.
+func (s *Store) Set(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.values[key]
+	s.values[key] = b
+	err = s.write()
+	if err != nil {
+		// Keep the store consistent with its file.
+		if ok {
+			s.values[key] = prev
+		} else {
+			delete(s.values, key)
+		}
+	}
+	return err
+}
+
+// Delete removes the value for key and writes the store to its file.
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.values[key]
+	if !ok {
+		return nil
+	}
+	delete(s.values, key)
+	err := s.write()
+	if err != nil {
+		s.values[key] = prev
+	}
+	return err
+}
+
+// Keys returns the keys held in the store in sorted order.
+func (s *Store) Keys() []string {
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	s.mu.Unlock()
+	sort.Strings(keys)
+	return keys
+}
+
+// write writes the store's values to its file via a temporary file in
+// the same directory. The caller must hold s.mu.
+func (s *Store) write() error {
+	b, err := json.MarshalIndent(s.values, "", "\t")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
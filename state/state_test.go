@@ -0,0 +1,102 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening new store: %v", err)
+	}
+	var muted bool
+	ok, err := s.Get("mic", &muted)
+	if ok || err != nil {
+		t.Errorf("unexpected result for missing key: ok=%t err=%v", ok, err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Error("store file created before any value was set")
+	}
+
+	type scene struct {
+		Name  string `json:"name"`
+		Index int    `json:"index"`
+	}
+	for key, v := range map[string]interface{}{
+		"mic":   true,
+		"scene": scene{Name: "intro", Index: 2},
+		"tmp":   1,
+	} {
+		err = s.Set(key, v)
+		if err != nil {
+			t.Fatalf("unexpected error setting %s: %v", key, err)
+		}
+	}
+	err = s.Delete("tmp")
+	if err != nil {
+		t.Fatalf("unexpected error deleting key: %v", err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error reopening store: %v", err)
+	}
+	if got, want := s.Keys(), []string{"mic", "scene"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected keys: got:%q want:%q", got, want)
+	}
+	ok, err = s.Get("mic", &muted)
+	if !ok || err != nil || !muted {
+		t.Errorf("unexpected result for mic: ok=%t err=%v value=%t", ok, err, muted)
+	}
+	var sc scene
+	ok, err = s.Get("scene", &sc)
+	if !ok || err != nil || sc != (scene{Name: "intro", Index: 2}) {
+		t.Errorf("unexpected result for scene: ok=%t err=%v value=%+v", ok, err, sc)
+	}
+	ok, err = s.Get("scene", &muted)
+	if !ok || err == nil {
+		t.Errorf("expected error decoding into wrong type: ok=%t err=%v", ok, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("unexpected error reading store directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("unexpected files left in store directory: %v", entries)
+	}
+}
+
+func TestStoreWriteFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "state.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening new store: %v", err)
+	}
+	err = s.Set("mic", true)
+	if err == nil {
+		t.Fatal("expected error writing store in missing directory")
+	}
+	if keys := s.Keys(); len(keys) != 0 {
+		t.Errorf("unexpected keys after failed write: %q", keys)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	err := os.WriteFile(path, []byte("[]"), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error writing invalid store: %v", err)
+	}
+	_, err = Open(path)
+	if err == nil {
+		t.Error("expected error opening invalid store")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+// Throughput is a measurement of key image write throughput.
+type Throughput struct {
+	// Images, Reports and Bytes are the number of key
+	// images, image reports and bytes of encoded image
+	// data written during Elapsed.
+	Images  int
+	Reports int
+	Bytes   int64
+
+	// Elapsed is the total time taken to write the
+	// images, including any pacing delays.
+	Elapsed time.Duration
+}
+
+// BytesPerSecond returns the rate of encoded image data written.
+func (t Throughput) BytesPerSecond() float64 {
+	if t.Elapsed <= 0 {
+		return 0
+	}
+	return float64(t.Bytes) / t.Elapsed.Seconds()
+}
+
+// ImagesPerSecond returns the rate of key images written.
+func (t Throughput) ImagesPerSecond() float64 {
+	if t.Elapsed <= 0 {
+		return 0
+	}
+	return float64(t.Images) / t.Elapsed.Seconds()
+}
+
+// throughputSamples is the number of recent key image writes that
+// throughput is measured over.
+const throughputSamples = 32
+
+// throughputWindow holds the most recent key image writes.
+type throughputWindow struct {
+	samples [throughputSamples]Throughput
+	next    int
+	total   Throughput
+}
+
+// add adds a single key image write to the window, replacing the oldest.
+func (w *throughputWindow) add(reports, bytes int, elapsed time.Duration) {
+	old := w.samples[w.next]
+	w.total.Images -= old.Images
+	w.total.Reports -= old.Reports
+	w.total.Bytes -= old.Bytes
+	w.total.Elapsed -= old.Elapsed
+
+	s := Throughput{Images: 1, Reports: reports, Bytes: int64(bytes), Elapsed: elapsed}
+	w.samples[w.next] = s
+	w.next = (w.next + 1) % throughputSamples
+	w.total.Images += s.Images
+	w.total.Reports += s.Reports
+	w.total.Bytes += s.Bytes
+	w.total.Elapsed += s.Elapsed
+}
+
+// Throughput returns the key image write throughput measured over the
+// most recent key image writes to the device. Writes skipped because the
+// key already shows the image are not counted. The returned Throughput is
+// zero if no images have been written.
+func (d *Deck) Throughput() Throughput {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.throughput.total
+}
+
+// FrameBudget returns the estimated maximum number of times per second
+// that the images on the given number of keys can all be replaced, based
+// on the measured throughput and, if pacing is in effect, the number of
+// image reports it allows per second. FrameBudget returns false if no
+// images have been written to the device.
+func (d *Deck) FrameBudget(keys int) (fps float64, ok bool) {
+	if keys < 1 {
+		keys = 1
+	}
+	t := d.Throughput()
+	if t.Images == 0 {
+		return 0, false
+	}
+	rate := t.ImagesPerSecond()
+	d.mu.Lock()
+	delay, quirks := d.pacing, d.quirks
+	d.mu.Unlock()
+	if quirks&QuirkSlowPacing != 0 && delay < slowPacing {
+		delay = slowPacing
+	}
+	if delay > 0 {
+		// Images take on average Reports/Images
+		// reports, each taking at least delay.
+		perImage := time.Duration(float64(delay) * float64(t.Reports) / float64(t.Images))
+		if paced := 1 / perImage.Seconds(); paced < rate {
+			rate = paced
+		}
+	}
+	return rate / float64(keys), true
+}
+
+// SetThrottle sets whether animations are throttled to the Deck's frame
+// budget. When throttling is enabled, each running animation waits before
+// rendering a frame until the measured throughput allows all running
+// animations to render a frame in the time since its last frame. This
+// slows animations that would otherwise send images faster than the
+// device can accept them.
+func (d *Deck) SetThrottle(throttle bool) {
+	d.animMu.Lock()
+	d.throttle = throttle
+	d.animMu.Unlock()
+}
+
+// throttleFrame waits until the next frame of an animation may be
+// rendered, given that its previous frame was rendered at last. It
+// returns the time of the new frame and false if ctx is cancelled while
+// waiting.
+func (d *Deck) throttleFrame(ctx context.Context, last time.Time) (time.Time, bool) {
+	d.animMu.Lock()
+	throttle := d.throttle
+	running := len(d.animations)
+	d.animMu.Unlock()
+	clock := d.clock
+	if clock == nil {
+		clock = animation.SystemClock
+	}
+	if !throttle || last.IsZero() {
+		return clock.Now(), ctx.Err() == nil
+	}
+	fps, ok := d.FrameBudget(running)
+	if ok && fps > 0 {
+		next := last.Add(time.Duration(float64(time.Second) / fps))
+		if !animation.Sleep(ctx, clock, next.Sub(clock.Now())) {
+			return last, false
+		}
+	}
+	return clock.Now(), ctx.Err() == nil
+}
@@ -0,0 +1,115 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+func TestThroughputWindow(t *testing.T) {
+	var w throughputWindow
+	for i := 1; i <= 2*throughputSamples; i++ {
+		w.add(i, 10*i, time.Duration(i)*time.Millisecond)
+	}
+	// Only the most recent samples are retained.
+	var want Throughput
+	for i := throughputSamples + 1; i <= 2*throughputSamples; i++ {
+		want.Images++
+		want.Reports += i
+		want.Bytes += int64(10 * i)
+		want.Elapsed += time.Duration(i) * time.Millisecond
+	}
+	if w.total != want {
+		t.Errorf("unexpected window total: got:%+v want:%+v", w.total, want)
+	}
+}
+
+func TestFrameBudget(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.setDev(&virtDev{Writer: io.Discard})
+
+	if _, ok := d.FrameBudget(1); ok {
+		t.Error("unexpected frame budget before images written")
+	}
+	for i, c := range []uint8{0x00, 0xff, 0x80} {
+		err = d.SetImage(0, i, uniformRGBA(image.Rect(0, 0, 72, 72), color.Gray{Y: c}))
+		if err != nil {
+			t.Fatalf("unexpected error for SetImage: %v", err)
+		}
+	}
+	tp := d.Throughput()
+	if tp.Images != 3 || tp.Reports < 3 || tp.Bytes == 0 {
+		t.Errorf("unexpected throughput: %+v", tp)
+	}
+	fps, ok := d.FrameBudget(3)
+	if !ok {
+		t.Fatal("no frame budget after images written")
+	}
+	if want := tp.ImagesPerSecond() / 3; math.Abs(fps-want) > 1e-9*want {
+		t.Errorf("unexpected unpaced frame budget: got:%v want:%v", fps, want)
+	}
+
+	// Pacing limits the budget to one report per delay.
+	d.SetPacing(time.Second)
+	fps, _ = d.FrameBudget(3)
+	reportsPerImage := float64(tp.Reports) / float64(tp.Images)
+	if want := 1 / reportsPerImage / 3; math.Abs(fps-want) > 1e-9*want {
+		t.Errorf("unexpected paced frame budget: got:%v want:%v", fps, want)
+	}
+}
+
+func TestThrottleFrame(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock := animation.NewManualClock(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	d.clock = clock
+	// Ten images per second with a single animation
+	// running gives a frame interval of 100ms.
+	d.throughput.add(1, 1000, 100*time.Millisecond)
+	d.animations = map[int]*Animation{0: nil}
+
+	last := clock.Now()
+	got, ok := d.throttleFrame(context.Background(), last)
+	if !ok || !got.Equal(last) {
+		t.Errorf("unexpected result without throttling: got:%v %t want:%v true", got, ok, last)
+	}
+
+	d.SetThrottle(true)
+	done := make(chan time.Time)
+	go func() {
+		got, _ := d.throttleFrame(context.Background(), last)
+		done <- got
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(50 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("frame not throttled")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(50 * time.Millisecond)
+	if got, want := <-done, last.Add(100*time.Millisecond); !got.Equal(want) {
+		t.Errorf("unexpected frame time: got:%v want:%v", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := d.throttleFrame(ctx, last); ok {
+		t.Error("throttled frame not cancelled")
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestTraces replays the HID transcripts in testdata/traces against the
+// library and checks that the traffic sent to the device is byte-identical
+// to the transcript.
+//
+// Transcripts are held in testdata/traces/<device>/<name>.trace, where
+// device is the PID name of the device. Each transcript is a line-oriented
+// text file. Blank lines and lines starting with '#' are ignored. The first
+// remaining line describes the operation performed:
+//
+//	op reset
+//	op reset-key-stream
+//	op brightness <percent>
+//	op serial
+//	op firmware
+//	op key-states
+//	op set-image <row> <col> <file>
+//
+// where file is the raw encoded key image, relative to the transcript. The
+// following lines are the HID traffic in order:
+//
+//	send-feature <report>
+//	get-feature <report> -> <response>
+//	write <report>
+//	read <length> -> <response>
+//
+// Reports are written as <length>:<hex>, where bytes beyond the given hex
+// are zero. Responses are the data provided by the device.
+//
+// Running the test with -update rewrites the traffic of each transcript
+// from the behaviour of the library, retaining comments, the operation
+// and the device responses.
+func TestTraces(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "traces", "*", "*.trace"))
+	if err != nil {
+		t.Fatalf("unexpected error finding traces: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no traces found")
+	}
+	for _, path := range paths {
+		name := filepath.Join(filepath.Base(filepath.Dir(path)), filepath.Base(path))
+		t.Run(name, func(t *testing.T) {
+			tr, err := readTrace(path)
+			if err != nil {
+				t.Fatalf("unexpected error reading trace: %v", err)
+			}
+			pid, ok := pidFor(filepath.Base(filepath.Dir(path)))
+			if !ok {
+				t.Fatalf("unknown device: %s", filepath.Base(filepath.Dir(path)))
+			}
+			d, err := newTestDeck(pid)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dev := &traceDev{t: t, want: tr.events, record: *update}
+			d.dev = dev
+
+			err = tr.run(d, filepath.Dir(path))
+			if err != nil {
+				t.Errorf("unexpected error running %s: %v", tr.op, err)
+			}
+			if *update {
+				tr.events = dev.got
+				err = tr.write(path)
+				if err != nil {
+					t.Fatalf("unexpected error writing trace: %v", err)
+				}
+				return
+			}
+			if dev.next != len(tr.events) {
+				t.Errorf("unexpected number of events: got:%d want:%d", dev.next, len(tr.events))
+			}
+		})
+	}
+}
+
+// pidFor returns the PID for the device with the given name.
+func pidFor(name string) (PID, bool) {
+	for pid := range devices {
+		if pid.String() == name {
+			return pid, true
+		}
+	}
+	return 0, false
+}
+
+// trace is a HID transcript.
+type trace struct {
+	comments []string
+	op       []string
+	events   []traceEvent
+}
+
+// traceEvent is a single HID transaction.
+type traceEvent struct {
+	kind     string
+	report   []byte
+	response []byte
+	length   int // length is the read buffer length.
+}
+
+// readTrace reads the transcript held in the file at path.
+func readTrace(path string) (*trace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tr trace
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "#") {
+			tr.comments = append(tr.comments, text)
+			continue
+		}
+		fields := strings.Fields(text)
+		if tr.op == nil {
+			if fields[0] != "op" || len(fields) < 2 {
+				return nil, fmt.Errorf("%s:%d: missing operation", path, line)
+			}
+			tr.op = fields[1:]
+			continue
+		}
+		ev, err := parseTraceEvent(fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		tr.events = append(tr.events, ev)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if tr.op == nil {
+		return nil, fmt.Errorf("%s: missing operation", path)
+	}
+	return &tr, nil
+}
+
+func parseTraceEvent(fields []string) (traceEvent, error) {
+	ev := traceEvent{kind: fields[0]}
+	var err error
+	switch ev.kind {
+	case "send-feature", "write":
+		if len(fields) != 2 {
+			return ev, fmt.Errorf("invalid %s event", ev.kind)
+		}
+		ev.report, err = parseReport(fields[1])
+	case "get-feature":
+		if len(fields) != 4 || fields[2] != "->" {
+			return ev, fmt.Errorf("invalid %s event", ev.kind)
+		}
+		ev.report, err = parseReport(fields[1])
+		if err != nil {
+			return ev, err
+		}
+		ev.response, err = parseReport(fields[3])
+	case "read":
+		if len(fields) != 4 || fields[2] != "->" {
+			return ev, fmt.Errorf("invalid %s event", ev.kind)
+		}
+		ev.length, err = strconv.Atoi(fields[1])
+		if err != nil {
+			return ev, err
+		}
+		ev.response, err = parseReport(fields[3])
+	default:
+		return ev, fmt.Errorf("unknown event kind: %q", ev.kind)
+	}
+	return ev, err
+}
+
+// parseReport parses a report in <length>:<hex> notation.
+func parseReport(s string) ([]byte, error) {
+	l, h, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid report: %q", s)
+	}
+	n, err := strconv.Atoi(l)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report length: %w", err)
+	}
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report data: %w", err)
+	}
+	if len(b) > n {
+		return nil, fmt.Errorf("report data longer than length: %d > %d", len(b), n)
+	}
+	return append(b, make([]byte, n-len(b))...), nil
+}
+
+// formatReport returns b in <length>:<hex> notation.
+func formatReport(b []byte) string {
+	return fmt.Sprintf("%d:%x", len(b), bytes.TrimRight(b, "\x00"))
+}
+
+// run performs the trace's operation on d, reading any files relative
+// to dir.
+func (tr *trace) run(d *Deck, dir string) error {
+	args := tr.op[1:]
+	switch op := tr.op[0]; op {
+	case "reset":
+		return d.Reset()
+	case "reset-key-stream":
+		return d.ResetKeyStream()
+	case "brightness":
+		if len(args) != 1 {
+			return fmt.Errorf("invalid arguments for %s: %q", op, args)
+		}
+		percent, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		return d.SetBrightness(percent)
+	case "serial":
+		_, err := d.Serial()
+		return err
+	case "firmware":
+		_, err := d.Firmware()
+		return err
+	case "key-states":
+		_, err := d.KeyStates()
+		return err
+	case "set-image":
+		if len(args) != 3 {
+			return fmt.Errorf("invalid arguments for %s: %q", op, args)
+		}
+		row, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		col, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(filepath.Join(dir, args[2]))
+		if err != nil {
+			return err
+		}
+		return d.SetImage(row, col, &RawImage{rawImage{data: data, pid: d.desc.PID}})
+	default:
+		return fmt.Errorf("unknown operation: %q", op)
+	}
+}
+
+// write writes the transcript to the file at path.
+func (tr *trace) write(path string) error {
+	var buf bytes.Buffer
+	for _, c := range tr.comments {
+		fmt.Fprintln(&buf, c)
+	}
+	fmt.Fprintf(&buf, "op %s\n", strings.Join(tr.op, " "))
+	for _, ev := range tr.events {
+		switch ev.kind {
+		case "send-feature", "write":
+			fmt.Fprintf(&buf, "%s %s\n", ev.kind, formatReport(ev.report))
+		case "get-feature":
+			fmt.Fprintf(&buf, "%s %s -> %s\n", ev.kind, formatReport(ev.report), formatReport(ev.response))
+		case "read":
+			fmt.Fprintf(&buf, "%s %d -> %s\n", ev.kind, ev.length, formatReport(ev.response))
+		}
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// traceDev is a HIDDevice that checks traffic against a transcript. When
+// record is true, traffic is recorded instead of being checked, with device
+// responses taken from the transcript.
+type traceDev struct {
+	t      *testing.T
+	want   []traceEvent
+	next   int
+	record bool
+	got    []traceEvent
+}
+
+// event returns the next expected event, checking that it has the given
+// kind. In record mode, the returned event holds only the response.
+func (d *traceDev) event(kind string) (traceEvent, bool) {
+	d.t.Helper()
+	var ev traceEvent
+	if d.next < len(d.want) {
+		ev = d.want[d.next]
+	}
+	d.next++
+	if d.record {
+		return ev, true
+	}
+	if d.next > len(d.want) {
+		d.t.Errorf("unexpected %s event %d beyond end of trace", kind, d.next-1)
+		return ev, false
+	}
+	if ev.kind != kind {
+		d.t.Errorf("unexpected event %d kind: got:%s want:%s", d.next-1, kind, ev.kind)
+		return ev, false
+	}
+	return ev, true
+}
+
+// check checks a report against the expected report.
+func (d *traceDev) check(i int, got, want []byte) {
+	d.t.Helper()
+	if d.record {
+		return
+	}
+	if !bytes.Equal(got, want) {
+		d.t.Errorf("unexpected report for event %d:\ngot: %s\nwant:%s", i, formatReport(got), formatReport(want))
+	}
+}
+
+func (d *traceDev) Read(b []byte) (int, error) {
+	ev, ok := d.event("read")
+	if !ok {
+		return 0, nil
+	}
+	if !d.record && len(b) != ev.length {
+		d.t.Errorf("unexpected read length for event %d: got:%d want:%d", d.next-1, len(b), ev.length)
+	}
+	n := copy(b, ev.response)
+	d.got = append(d.got, traceEvent{kind: "read", length: len(b), response: ev.response})
+	return n, nil
+}
+
+func (d *traceDev) Write(b []byte) (int, error) {
+	ev, ok := d.event("write")
+	if ok {
+		d.check(d.next-1, b, ev.report)
+	}
+	d.got = append(d.got, traceEvent{kind: "write", report: append(b[:0:0], b...)})
+	return len(b), nil
+}
+
+func (d *traceDev) Close() error { return nil }
+
+func (d *traceDev) GetFeatureReport(b []byte) (int, error) {
+	ev, ok := d.event("get-feature")
+	if ok {
+		d.check(d.next-1, b, ev.report)
+	}
+	d.got = append(d.got, traceEvent{kind: "get-feature", report: append(b[:0:0], b...), response: ev.response})
+	return copy(b, ev.response), nil
+}
+
+func (d *traceDev) SendFeatureReport(b []byte) (int, error) {
+	ev, ok := d.event("send-feature")
+	if ok {
+		d.check(d.next-1, b, ev.report)
+	}
+	d.got = append(d.got, traceEvent{kind: "send-feature", report: append(b[:0:0], b...)})
+	return len(b), nil
+}
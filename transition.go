@@ -0,0 +1,62 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"image"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+// Transition renders the transition t from the image currently shown on the
+// key at row and col to img over the duration dur. If no image has been set
+// on the key since the Deck was opened or reset, the transition starts from
+// black. Transition returns when the transition is complete, ctx is
+// cancelled or the transition is stopped by another image being set on
+// the key, leaving img on the key if it completed.
+func (d *Deck) Transition(ctx context.Context, row, col int, img image.Image, t animation.Transition, dur time.Duration) error {
+	if _, err := d.Bounds(); err != nil {
+		return err
+	}
+	key, err := d.keyIndex(row, col)
+	if err != nil {
+		return err
+	}
+	var from image.Image
+	d.mu.Lock()
+	if d.images != nil && d.images[key] != nil {
+		from = d.images[key]
+	}
+	d.mu.Unlock()
+	if from != nil {
+		from = d.fit(unwrapRaw(from))
+	}
+	to := d.fit(unwrapRaw(img))
+	a, err := d.Animate(ctx, row, col, func(ctx context.Context, set func(image.Image) error) error {
+		return animation.PlayTransition(ctx, d.clock, t, from, to, dur, func(frame image.Image) error {
+			if frame == to {
+				// Set the original so that a RawImage is used
+				// directly and is retained as the key image.
+				frame = img
+			}
+			return set(frame)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return a.Wait()
+}
+
+// unwrapRaw returns the original image of a *RawImage, or img if it is
+// not a *RawImage.
+func unwrapRaw(img image.Image) image.Image {
+	if raw, ok := img.(*RawImage); ok {
+		return raw.Image
+	}
+	return img
+}
@@ -0,0 +1,46 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ardilla
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla/animation"
+)
+
+func TestDeckTransition(t *testing.T) {
+	d, err := newTestDeck(StreamDeckMK2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.setDev(&virtDev{Writer: io.Discard})
+	WithClock(animation.NewManualClock(time.Time{}))(d)
+
+	from := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(from, from.Bounds(), image.White, image.Point{}, draw.Src)
+	err = d.SetImage(1, 2, from)
+	if err != nil {
+		t.Fatalf("unexpected error for SetImage: %v", err)
+	}
+	to := image.NewRGBA(d.desc.bounds())
+	err = d.Transition(context.Background(), 1, 2, to, animation.Crossfade, 0)
+	if err != nil {
+		t.Errorf("unexpected error for Transition: %v", err)
+	}
+	if got := d.images[d.Key(1, 2)]; got != image.Image(to) {
+		t.Errorf("unexpected key image after transition: got:%T want:%T", got, to)
+	}
+
+	err = d.Transition(context.Background(), 3, 0, to, animation.Crossfade, 0)
+	if want := errors.New("row out of bounds: 3"); !sameError(err, want) {
+		t.Errorf("unexpected error for out of bounds Transition: got:%v want:%v", err, want)
+	}
+}
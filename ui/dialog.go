@@ -0,0 +1,94 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ui
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/label"
+)
+
+// Dialog is a screen that shows a message across the deck with buttons
+// bound to keys. It is intended to be shown over other screens with
+// Controller.Push. Pressing a button dismisses the dialog and passes the
+// button's value to Choose. Presses of other keys are ignored.
+type Dialog struct {
+	// Message is rendered across the deck. Message
+	// may be nil.
+	Message image.Image
+
+	// Gap is the gap used to render Message.
+	// See ardilla.Deck.SetCanvas.
+	Gap int
+
+	// Buttons are the dialog's responses.
+	Buttons []Button
+
+	// Choose is called with the value of the
+	// button that dismissed the dialog.
+	Choose func(value string)
+}
+
+// Button is a dialog response bound to a key.
+type Button struct {
+	Row, Col int
+
+	// Image is rendered on the key. If Image is
+	// nil, Label is rendered as white text on
+	// a grey background.
+	Image image.Image
+	Label string
+
+	// Value is the value passed to the dialog's
+	// Choose function when the button is pressed.
+	Value string
+}
+
+// Show implements the Screen interface.
+func (d *Dialog) Show(s *Surface) error {
+	if d.Message != nil {
+		err := s.SetCanvas(d.Message, d.Gap)
+		if err != nil {
+			return err
+		}
+	}
+	bounds, err := s.Bounds()
+	if err != nil {
+		return err
+	}
+	for _, b := range d.Buttons {
+		img := b.Image
+		if img == nil {
+			img, err = label.Render(bounds, b.Label, float64(bounds.Dy())/4, color.White, color.Gray{Y: 0x60})
+			if err != nil {
+				return err
+			}
+		}
+		err = s.SetImage(b.Row, b.Col, img)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleKey implements the Screen interface.
+func (d *Dialog) HandleKey(s *Surface, ev ardilla.KeyEvent) {
+	if !ev.Pressed || ev.Err != nil || ev.Lagged != 0 || ev.Stuck {
+		return
+	}
+	for _, b := range d.Buttons {
+		if b.Row != ev.Row || b.Col != ev.Col {
+			continue
+		}
+		s.Dismiss()
+		if d.Choose != nil {
+			d.Choose(b.Value)
+		}
+		return
+	}
+}
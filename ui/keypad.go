@@ -0,0 +1,183 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ui
+
+import (
+	"errors"
+	"image/color"
+	"strings"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/label"
+	"github.com/kortschak/ardilla/layout"
+)
+
+// Keypad is a screen for numeric entry, such as entering a PIN. Digits
+// are entered on keys and the accumulated value is shown on the Display
+// key. The Delete key removes the last digit and the Enter key passes the
+// value to Submit and clears it.
+type Keypad struct {
+	// Display, Delete and Enter are the keys
+	// showing the value, deleting the last
+	// digit and submitting the value.
+	Display, Delete, Enter layout.Position
+
+	// Digits holds the keys for the digits zero
+	// to nine. If Digits is nil, the digits are
+	// placed in key order from one to nine and
+	// then zero on the keys not otherwise used.
+	Digits []layout.Position
+
+	// MaxLen is the maximum number of digits in
+	// the value. If MaxLen is zero, values are
+	// limited to 16 digits.
+	MaxLen int
+
+	// Mask is whether the value is shown masked
+	// with asterisks.
+	Mask bool
+
+	// Submit is called with the surface showing
+	// the keypad and the entered value when the
+	// Enter key is pressed.
+	Submit func(s *Surface, value string)
+
+	value string
+}
+
+// maxKeypadLen is the maximum length of a keypad value when MaxLen is zero.
+const maxKeypadLen = 16
+
+// Value returns the currently entered value.
+func (k *Keypad) Value() string {
+	return k.value
+}
+
+// Show implements the Screen interface.
+func (k *Keypad) Show(s *Surface) error {
+	digits, err := k.digits(s)
+	if err != nil {
+		return err
+	}
+	bounds, err := s.Bounds()
+	if err != nil {
+		return err
+	}
+	size := float64(bounds.Dy()) / 2
+	for d, p := range digits {
+		img, err := label.Render(bounds, string(rune('0'+d)), size, color.White, color.Gray{Y: 0x40})
+		if err != nil {
+			return err
+		}
+		err = s.SetImage(p.Row, p.Col, img)
+		if err != nil {
+			return err
+		}
+	}
+	for _, c := range []struct {
+		pos  layout.Position
+		text string
+	}{
+		{pos: k.Delete, text: "del"},
+		{pos: k.Enter, text: "enter"},
+	} {
+		img, err := label.Render(bounds, c.text, size/2, color.White, color.Gray{Y: 0x60})
+		if err != nil {
+			return err
+		}
+		err = s.SetImage(c.pos.Row, c.pos.Col, img)
+		if err != nil {
+			return err
+		}
+	}
+	return k.renderDisplay(s)
+}
+
+// HandleKey implements the Screen interface.
+func (k *Keypad) HandleKey(s *Surface, ev ardilla.KeyEvent) {
+	if !ev.Pressed || ev.Err != nil || ev.Lagged != 0 || ev.Stuck {
+		return
+	}
+	pos := layout.Position{Row: ev.Row, Col: ev.Col}
+	switch pos {
+	case k.Delete:
+		if k.value == "" {
+			return
+		}
+		k.value = k.value[:len(k.value)-1]
+	case k.Enter:
+		value := k.value
+		k.value = ""
+		k.renderDisplay(s)
+		if k.Submit != nil {
+			k.Submit(s, value)
+		}
+		return
+	default:
+		digits, err := k.digits(s)
+		if err != nil {
+			return
+		}
+		limit := k.MaxLen
+		if limit <= 0 {
+			limit = maxKeypadLen
+		}
+		for d, p := range digits {
+			if p == pos && len(k.value) < limit {
+				k.value += string(rune('0' + d))
+				break
+			}
+		}
+	}
+	k.renderDisplay(s)
+}
+
+// renderDisplay renders the current value on the display key.
+func (k *Keypad) renderDisplay(s *Surface) error {
+	bounds, err := s.Bounds()
+	if err != nil {
+		return err
+	}
+	text := k.value
+	if k.Mask {
+		text = strings.Repeat("*", len(text))
+	}
+	img, err := label.Render(bounds, text, float64(bounds.Dy())/4, color.White, color.Black)
+	if err != nil {
+		return err
+	}
+	return s.SetImage(k.Display.Row, k.Display.Col, img)
+}
+
+// digits returns the keys for the digits zero to nine.
+func (k *Keypad) digits(s *Surface) ([]layout.Position, error) {
+	if k.Digits != nil {
+		if len(k.Digits) != 10 {
+			return nil, errors.New("keypad must have ten digit keys")
+		}
+		return k.Digits, nil
+	}
+	rows, cols := s.Layout()
+	var free []layout.Position
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			p := layout.Position{Row: r, Col: c}
+			if p == k.Display || p == k.Delete || p == k.Enter {
+				continue
+			}
+			free = append(free, p)
+		}
+	}
+	if len(free) < 10 {
+		return nil, errors.New("not enough keys for keypad digits")
+	}
+	// Place one to nine then zero.
+	digits := make([]layout.Position, 10)
+	for d := 1; d <= 9; d++ {
+		digits[d] = free[d-1]
+	}
+	digits[0] = free[9]
+	return digits, nil
+}
@@ -0,0 +1,72 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ui
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/internal/decktest"
+	"github.com/kortschak/ardilla/layout"
+)
+
+func TestKeypad(t *testing.T) {
+	d, dev := decktest.New(t)
+	c := New(d)
+
+	var submitted []string
+	k := &Keypad{
+		Display: layout.Position{Row: 0, Col: 4},
+		Delete:  layout.Position{Row: 2, Col: 3},
+		Enter:   layout.Position{Row: 2, Col: 4},
+		MaxLen:  4,
+		Mask:    true,
+		Submit: func(_ *Surface, v string) {
+			submitted = append(submitted, v)
+		},
+	}
+	err := c.Show(k)
+	if err != nil {
+		t.Fatalf("unexpected error showing keypad: %v", err)
+	}
+	if got := dev.Colour(0, 0); got == "unset" {
+		t.Error("digit key not drawn")
+	}
+
+	press := func(row, col int) {
+		c.Dispatch(ardilla.KeyEvent{Row: row, Col: col, Pressed: true})
+		c.Dispatch(ardilla.KeyEvent{Row: row, Col: col})
+	}
+	// Digits 1-4 are on row 0, 5-9 on row 1, and 0 on 2,0.
+	press(0, 0) // 1
+	press(1, 4) // 9
+	press(2, 0) // 0
+	if got := k.Value(); got != "190" {
+		t.Errorf("unexpected value: got:%q want:190", got)
+	}
+	press(2, 3) // Delete.
+	press(0, 1) // 2
+	press(0, 2) // 3
+	press(0, 3) // 4, beyond MaxLen.
+	if got := k.Value(); got != "1923" {
+		t.Errorf("unexpected value: got:%q want:1923", got)
+	}
+	press(2, 4) // Enter.
+	press(1, 0) // 5
+	press(2, 4)
+	want := []string{"1923", "5"}
+	if !reflect.DeepEqual(submitted, want) {
+		t.Errorf("unexpected submitted values: got:%q want:%q", submitted, want)
+	}
+	if k.Value() != "" {
+		t.Errorf("value not cleared after submit: %q", k.Value())
+	}
+
+	bad := &Keypad{Digits: []layout.Position{{Row: 0, Col: 0}}}
+	if err := c.Show(bad); err == nil {
+		t.Error("expected error for short digit key list")
+	}
+}
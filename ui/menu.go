@@ -0,0 +1,169 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ui
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/label"
+	"github.com/kortschak/ardilla/layout"
+)
+
+// Menu is a screen showing a scrollable list of items, one item per key.
+// Items are laid out in key order on the keys not used for navigation.
+// Pressing an item's key passes the item to Choose. The Prev and Next
+// keys scroll the list by a page of items.
+type Menu struct {
+	// Items is the list of menu items.
+	Items []MenuItem
+
+	// Prev and Next are the keys that scroll
+	// the menu backwards and forwards.
+	Prev, Next layout.Position
+
+	// Back is an optional key that dismisses
+	// the menu.
+	Back *layout.Position
+
+	// Choose is called with the surface
+	// showing the menu and the item that was
+	// pressed. Choose may dismiss the menu or
+	// push another screen, for example a
+	// sub-menu, over it.
+	Choose func(s *Surface, item MenuItem)
+
+	// offset is the index of the first visible item.
+	offset int
+}
+
+// MenuItem is an item in a Menu.
+type MenuItem struct {
+	// Image is rendered on the item's key. If
+	// Image is nil, Label is rendered as white
+	// text on black.
+	Image image.Image
+	Label string
+
+	// Value identifies the item.
+	Value string
+}
+
+// Show implements the Screen interface.
+func (m *Menu) Show(s *Surface) error {
+	slots := m.slots(s)
+	if m.offset >= len(m.Items) || len(slots) == 0 {
+		m.offset = 0
+	}
+	return m.render(s, slots)
+}
+
+// HandleKey implements the Screen interface.
+func (m *Menu) HandleKey(s *Surface, ev ardilla.KeyEvent) {
+	if !ev.Pressed || ev.Err != nil || ev.Lagged != 0 || ev.Stuck {
+		return
+	}
+	pos := layout.Position{Row: ev.Row, Col: ev.Col}
+	slots := m.slots(s)
+	switch {
+	case m.Back != nil && pos == *m.Back:
+		s.Dismiss()
+	case pos == m.Prev:
+		if m.offset == 0 {
+			return
+		}
+		m.offset -= len(slots)
+		if m.offset < 0 {
+			m.offset = 0
+		}
+		m.render(s, slots)
+	case pos == m.Next:
+		if m.offset+len(slots) >= len(m.Items) {
+			return
+		}
+		m.offset += len(slots)
+		m.render(s, slots)
+	default:
+		for i, slot := range slots {
+			if slot != pos {
+				continue
+			}
+			if idx := m.offset + i; idx < len(m.Items) && m.Choose != nil {
+				m.Choose(s, m.Items[idx])
+			}
+			return
+		}
+	}
+}
+
+// slots returns the keys available for items in key order.
+func (m *Menu) slots(s *Surface) []layout.Position {
+	rows, cols := s.Layout()
+	var slots []layout.Position
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			p := layout.Position{Row: r, Col: c}
+			if p == m.Prev || p == m.Next || (m.Back != nil && p == *m.Back) {
+				continue
+			}
+			slots = append(slots, p)
+		}
+	}
+	return slots
+}
+
+// render draws the visible page of items and the navigation keys.
+func (m *Menu) render(s *Surface, slots []layout.Position) error {
+	bounds, err := s.Bounds()
+	if err != nil {
+		return err
+	}
+	size := float64(bounds.Dy()) / 4
+	black := layout.Fill(bounds, color.Black)
+	for i, p := range slots {
+		img := black
+		if idx := m.offset + i; idx < len(m.Items) {
+			item := m.Items[idx]
+			img = item.Image
+			if img == nil {
+				img, err = label.Render(bounds, item.Label, size, color.White, color.Black)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		err = s.SetImage(p.Row, p.Col, img)
+		if err != nil {
+			return err
+		}
+	}
+	type navKey struct {
+		pos    layout.Position
+		text   string
+		active bool
+	}
+	nav := []navKey{
+		{pos: m.Prev, text: "<", active: m.offset > 0},
+		{pos: m.Next, text: ">", active: m.offset+len(slots) < len(m.Items)},
+	}
+	if m.Back != nil {
+		nav = append(nav, navKey{pos: *m.Back, text: "back", active: true})
+	}
+	for _, n := range nav {
+		img := black
+		if n.active {
+			img, err = label.Render(bounds, n.text, size, color.White, color.Gray{Y: 0x60})
+			if err != nil {
+				return err
+			}
+		}
+		err = s.SetImage(n.pos.Row, n.pos.Col, img)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
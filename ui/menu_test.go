@@ -0,0 +1,90 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/internal/decktest"
+	"github.com/kortschak/ardilla/layout"
+)
+
+func TestMenu(t *testing.T) {
+	d, dev := decktest.New(t)
+	c := New(d)
+
+	// An MK2 has 15 keys, leaving 12 for items.
+	colours := []color.Color{
+		color.RGBA{R: 0xff, A: 0xff},
+		color.RGBA{G: 0xff, A: 0xff},
+		color.RGBA{B: 0xff, A: 0xff},
+	}
+	var items []MenuItem
+	for i := 0; i < 14; i++ {
+		items = append(items, MenuItem{
+			Image: layout.Fill(image.Rect(0, 0, 72, 72), colours[i%len(colours)]),
+			Value: fmt.Sprint(i),
+		})
+	}
+	var chosen []string
+	m := &Menu{
+		Items: items,
+		Prev:  layout.Position{Row: 2, Col: 0},
+		Next:  layout.Position{Row: 2, Col: 4},
+		Back:  &layout.Position{Row: 0, Col: 4},
+		Choose: func(_ *Surface, item MenuItem) {
+			chosen = append(chosen, item.Value)
+		},
+	}
+	err := c.Push(m)
+	if err != nil {
+		t.Fatalf("unexpected error showing menu: %v", err)
+	}
+	// Item 4 is at 1,0 after the back key.
+	if got := dev.Colour(1, 0); got != "green" {
+		t.Errorf("unexpected colour for item 4: got:%s want:green", got)
+	}
+	if got := dev.Colour(2, 0); got != "black" {
+		t.Errorf("prev key active on first page: got:%s", got)
+	}
+	if got := dev.Colour(2, 4); got == "black" {
+		t.Error("next key inactive with more items")
+	}
+
+	press := func(row, col int) {
+		c.Dispatch(ardilla.KeyEvent{Row: row, Col: col, Pressed: true})
+		c.Dispatch(ardilla.KeyEvent{Row: row, Col: col})
+	}
+	press(1, 0)
+	press(2, 4) // Next page holds items 12 and 13.
+	if got := dev.Colour(0, 0); got != "red" {
+		t.Errorf("unexpected colour for item 12: got:%s want:red", got)
+	}
+	if got := dev.Colour(0, 2); got != "black" {
+		t.Errorf("unexpected colour for empty slot: got:%s want:black", got)
+	}
+	if got := dev.Colour(2, 4); got != "black" {
+		t.Errorf("next key active on last page: got:%s", got)
+	}
+	press(0, 1)
+	press(0, 2) // Empty slot.
+	press(2, 4) // No further page.
+	press(2, 0)
+	press(0, 0)
+	want := []string{"4", "13", "0"}
+	if !reflect.DeepEqual(chosen, want) {
+		t.Errorf("unexpected choices: got:%q want:%q", chosen, want)
+	}
+
+	press(0, 4)
+	if c.Top() != nil {
+		t.Error("menu not dismissed by back key")
+	}
+}
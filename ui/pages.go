@@ -0,0 +1,190 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/label"
+	"github.com/kortschak/ardilla/layout"
+)
+
+// Pages is a screen that shows one of a set of page screens. The visible
+// page receives the key events of the Pages screen, except for events
+// from the indicator key.
+type Pages struct {
+	// Pages is the set of page screens.
+	Pages []Screen
+
+	// Indicator is an optional indicator of
+	// the current page. Pressing the indicator
+	// key shows the next page.
+	Indicator *PageIndicator
+
+	// OnChange is called with the index of the
+	// new page when the page changes.
+	OnChange func(page int)
+
+	mu      sync.Mutex
+	current int
+	surface *Surface
+}
+
+// Current returns the index of the current page.
+func (p *Pages) Current() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.current
+}
+
+// Go shows the page with the given index.
+func (p *Pages) Go(page int) error {
+	if page < 0 || len(p.Pages) <= page {
+		return fmt.Errorf("page out of range: %d", page)
+	}
+	p.mu.Lock()
+	changed := page != p.current
+	p.current = page
+	s := p.surface
+	p.mu.Unlock()
+	if changed && p.OnChange != nil {
+		p.OnChange(page)
+	}
+	if s == nil {
+		return nil
+	}
+	return s.Redraw()
+}
+
+// Next shows the next page, wrapping to the first page after the last.
+func (p *Pages) Next() error {
+	if len(p.Pages) == 0 {
+		return nil
+	}
+	return p.Go((p.Current() + 1) % len(p.Pages))
+}
+
+// Prev shows the previous page, wrapping to the last page before the
+// first.
+func (p *Pages) Prev() error {
+	if len(p.Pages) == 0 {
+		return nil
+	}
+	return p.Go((p.Current() + len(p.Pages) - 1) % len(p.Pages))
+}
+
+// Show implements the Screen interface.
+func (p *Pages) Show(s *Surface) error {
+	p.mu.Lock()
+	p.surface = s
+	current := p.current
+	p.mu.Unlock()
+	if len(p.Pages) == 0 {
+		return nil
+	}
+	err := p.Pages[current].Show(s)
+	if err != nil {
+		return err
+	}
+	if p.Indicator == nil {
+		return nil
+	}
+	return p.Indicator.render(s, current, len(p.Pages))
+}
+
+// HandleKey implements the Screen interface.
+func (p *Pages) HandleKey(s *Surface, ev ardilla.KeyEvent) {
+	if len(p.Pages) == 0 {
+		return
+	}
+	if p.Indicator != nil && ev.Err == nil && ev.Lagged == 0 && ev.Row == p.Indicator.Key.Row && ev.Col == p.Indicator.Key.Col {
+		if ev.Pressed && !ev.Stuck {
+			p.Next()
+		}
+		return
+	}
+	p.Pages[p.Current()].HandleKey(s, ev)
+}
+
+// PageIndicator renders the current page position on a key.
+type PageIndicator struct {
+	// Key is the key showing the indicator.
+	Key layout.Position
+
+	// Numbers is whether the indicator shows
+	// the page number and count, such as "2/5",
+	// rather than a row of dots. Dots are only
+	// used for up to eight pages.
+	Numbers bool
+
+	// Color is the colour of the current page's
+	// dot or the page numbers. If Color is nil,
+	// white is used. Other dots are drawn in grey.
+	Color color.Color
+}
+
+// maxDots is the maximum number of pages shown as dots.
+const maxDots = 8
+
+// render draws the indicator for the given page of n pages.
+func (ind *PageIndicator) render(s *Surface, page, n int) error {
+	bounds, err := s.Bounds()
+	if err != nil {
+		return err
+	}
+	fg := ind.Color
+	if fg == nil {
+		fg = color.White
+	}
+	var img image.Image
+	if ind.Numbers || n > maxDots {
+		img, err = label.Render(bounds, fmt.Sprintf("%d/%d", page+1, n), float64(bounds.Dy())/4, fg, color.Black)
+		if err != nil {
+			return err
+		}
+	} else {
+		img = dots(bounds, page, n, fg)
+	}
+	return s.SetImage(ind.Key.Row, ind.Key.Col, img)
+}
+
+// dots returns an image of n dots across the middle of bounds with the
+// dot for page drawn in fg and the others in grey.
+func dots(bounds image.Rectangle, page, n int, fg color.Color) image.Image {
+	img := image.NewRGBA(bounds)
+	disc := func(cx, cy, r int, c color.Color) {
+		for y := cy - r; y <= cy+r; y++ {
+			for x := cx - r; x <= cx+r; x++ {
+				if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r {
+					img.Set(x, y, c)
+				}
+			}
+		}
+	}
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i+3] = 0xff
+	}
+	step := bounds.Dx() / (n + 1)
+	r := step / 3
+	if r > bounds.Dy()/8 {
+		r = bounds.Dy() / 8
+	}
+	if r < 1 {
+		r = 1
+	}
+	cy := bounds.Min.Y + bounds.Dy()/2
+	for i := 0; i < n; i++ {
+		c := color.Color(color.Gray{Y: 0x50})
+		if i == page {
+			c = fg
+		}
+		disc(bounds.Min.X+step*(i+1), cy, r, c)
+	}
+	return img
+}
@@ -0,0 +1,98 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ui
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/internal/decktest"
+	"github.com/kortschak/ardilla/layout"
+)
+
+func TestPages(t *testing.T) {
+	d, dev := decktest.New(t)
+	c := New(d)
+
+	first := &colourScreen{keys: map[[2]int]color.Color{
+		{0, 0}: color.RGBA{R: 0xff, A: 0xff},
+	}}
+	second := &colourScreen{keys: map[[2]int]color.Color{
+		{1, 1}: color.RGBA{G: 0xff, A: 0xff},
+	}}
+	var changes []int
+	p := &Pages{
+		Pages:     []Screen{first, second},
+		Indicator: &PageIndicator{Key: layout.Position{Row: 2, Col: 4}, Numbers: true},
+		OnChange:  func(page int) { changes = append(changes, page) },
+	}
+	err := c.Show(p)
+	if err != nil {
+		t.Fatalf("unexpected error showing pages: %v", err)
+	}
+	if got := dev.Colour(0, 0); got != "red" {
+		t.Errorf("unexpected colour at 0,0 on first page: got:%s want:red", got)
+	}
+	if got := dev.Colour(2, 4); got == "unset" {
+		t.Error("indicator not drawn")
+	}
+
+	err = p.Go(1)
+	if err != nil {
+		t.Fatalf("unexpected error changing page: %v", err)
+	}
+	if got := dev.Colour(0, 0); got != "black" {
+		t.Errorf("first page content not cleared: got:%s", got)
+	}
+	if got := dev.Colour(1, 1); got != "green" {
+		t.Errorf("unexpected colour at 1,1 on second page: got:%s want:green", got)
+	}
+	c.Dispatch(ardilla.KeyEvent{Row: 1, Col: 1, Pressed: true})
+	if len(first.events) != 0 || len(second.events) != 1 {
+		t.Errorf("unexpected event dispatch: first:%d second:%d", len(first.events), len(second.events))
+	}
+
+	// Pressing the indicator shows the next page without
+	// passing the event to the page.
+	c.Dispatch(ardilla.KeyEvent{Row: 2, Col: 4, Pressed: true})
+	c.Dispatch(ardilla.KeyEvent{Row: 2, Col: 4})
+	if p.Current() != 0 {
+		t.Errorf("unexpected page after indicator press: got:%d want:0", p.Current())
+	}
+	if len(second.events) != 1 {
+		t.Errorf("indicator event passed to page")
+	}
+	if got := dev.Colour(0, 0); got != "red" {
+		t.Errorf("unexpected colour at 0,0 after wrapping: got:%s want:red", got)
+	}
+	if err := p.Go(2); err == nil {
+		t.Error("expected error for page out of range")
+	}
+	if !reflect.DeepEqual(changes, []int{1, 0}) {
+		t.Errorf("unexpected page changes: got:%v want:[1 0]", changes)
+	}
+}
+
+func TestDots(t *testing.T) {
+	b := image.Rect(0, 0, 72, 72)
+	img := dots(b, 1, 3, color.White)
+	// Dots are centred at x = 18, 36 and 54.
+	for _, test := range []struct {
+		x    int
+		want color.Color
+	}{
+		{x: 18, want: color.RGBA{R: 0x50, G: 0x50, B: 0x50, A: 0xff}},
+		{x: 27, want: color.RGBA{A: 0xff}},
+		{x: 36, want: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+		{x: 54, want: color.RGBA{R: 0x50, G: 0x50, B: 0x50, A: 0xff}},
+	} {
+		if got := img.At(test.x, 36); got != test.want {
+			t.Errorf("unexpected colour at x=%d: got:%v want:%v", test.x, got, test.want)
+		}
+	}
+}
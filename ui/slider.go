@@ -0,0 +1,296 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ui
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/animation"
+	"github.com/kortschak/ardilla/label"
+)
+
+const (
+	// sliderRepeatDelay is how long an edge key of
+	// a slider is held before the value repeatedly
+	// changes.
+	sliderRepeatDelay = 400 * time.Millisecond
+
+	// sliderRepeatInterval is the interval between
+	// repeated changes while an edge key is held.
+	sliderRepeatInterval = 100 * time.Millisecond
+)
+
+// Slider is a control for a value in a range, such as a volume or a
+// brightness, that occupies a horizontal run of keys. The level of the
+// value is shown as a bar filling the keys from the left. Pressing an
+// inner key sets the value to the key's position in the range; pressing
+// the left or right edge key decreases or increases the value by Step,
+// repeating while the key is held.
+//
+// A Slider is a Screen, and may also be shown as part of another screen
+// by calling its Show and HandleKey methods from those of the screen.
+type Slider struct {
+	// Row and Col are the position of the
+	// slider's leftmost key.
+	Row, Col int
+
+	// Keys is the number of keys occupied by
+	// the slider. It must be at least 3.
+	Keys int
+
+	// Min and Max are the bounds of the range.
+	Min, Max float64
+
+	// Step is the change made by the edge keys.
+	// If Step is zero, a twentieth of the range
+	// is used.
+	Step float64
+
+	// Fill and Empty are the colours of the filled
+	// and empty parts of the bar. If nil, cyan and
+	// dark grey are used.
+	Fill, Empty color.Color
+
+	// OnChange is called with the new value each
+	// time the value is changed by a key press.
+	OnChange func(v float64)
+
+	mu      sync.Mutex
+	value   float64
+	set     bool
+	surface *Surface
+	shown   []int // Filled pixels shown on each key.
+	stop    context.CancelFunc
+	clock   animation.Clock
+}
+
+// Value returns the slider's value.
+func (sl *Slider) Value() float64 {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	return sl.valueLocked()
+}
+
+// valueLocked returns the slider's value, which is Min if it has not been
+// set. The caller must hold sl.mu.
+func (sl *Slider) valueLocked() float64 {
+	if !sl.set {
+		return sl.Min
+	}
+	return sl.value
+}
+
+// SetValue sets the slider's value, limited to the slider's range, and
+// shows it if the slider is visible. OnChange is not called.
+func (sl *Slider) SetValue(v float64) error {
+	sl.mu.Lock()
+	sl.setLocked(v)
+	s := sl.surface
+	sl.mu.Unlock()
+	if s == nil {
+		return nil
+	}
+	return sl.render(s, false)
+}
+
+// setLocked sets the slider's value, limited to its range, and returns
+// the value set. The caller must hold sl.mu.
+func (sl *Slider) setLocked(v float64) float64 {
+	lo, hi := sl.Min, sl.Max
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	if v < lo {
+		v = lo
+	}
+	if v > hi {
+		v = hi
+	}
+	sl.value = v
+	sl.set = true
+	return v
+}
+
+// Show implements the Screen interface.
+func (sl *Slider) Show(s *Surface) error {
+	if sl.Keys < 3 {
+		return fmt.Errorf("slider too short: %d keys", sl.Keys)
+	}
+	rows, cols := s.Layout()
+	if sl.Row < 0 || rows <= sl.Row {
+		return fmt.Errorf("row out of bounds: %d", sl.Row)
+	}
+	if sl.Col < 0 || cols < sl.Col+sl.Keys {
+		return fmt.Errorf("slider out of bounds: columns %d-%d", sl.Col, sl.Col+sl.Keys-1)
+	}
+	sl.mu.Lock()
+	sl.surface = s
+	sl.mu.Unlock()
+	return sl.render(s, true)
+}
+
+// HandleKey implements the Screen interface.
+func (sl *Slider) HandleKey(s *Surface, ev ardilla.KeyEvent) {
+	i := ev.Col - sl.Col
+	if ev.Row != sl.Row || i < 0 || sl.Keys <= i {
+		return
+	}
+	if ev.Err != nil || ev.Lagged != 0 {
+		return
+	}
+	sl.mu.Lock()
+	if sl.stop != nil {
+		// Any press or release ends a repeat.
+		sl.stop()
+		sl.stop = nil
+	}
+	if !ev.Pressed || ev.Stuck {
+		sl.mu.Unlock()
+		return
+	}
+	var dir float64
+	switch i {
+	case 0:
+		dir = -1
+	case sl.Keys - 1:
+		dir = 1
+	default:
+		v := sl.setLocked(sl.Min + (sl.Max-sl.Min)*float64(i)/float64(sl.Keys-1))
+		sl.mu.Unlock()
+		sl.changed(s, v)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sl.stop = cancel
+	clock := sl.clock
+	if clock == nil {
+		clock = animation.SystemClock
+	}
+	sl.mu.Unlock()
+
+	if !sl.nudge(s, dir) {
+		return
+	}
+	go func() {
+		d := sliderRepeatDelay
+		for animation.Sleep(ctx, clock, d) {
+			if !sl.nudge(s, dir) {
+				return
+			}
+			d = sliderRepeatInterval
+		}
+	}()
+}
+
+// nudge changes the slider's value by a step in the direction of dir. It
+// returns false if the value is already at the limit of the range or the
+// slider is no longer visible.
+func (sl *Slider) nudge(s *Surface, dir float64) bool {
+	if !s.Visible() {
+		return false
+	}
+	sl.mu.Lock()
+	step := sl.Step
+	if step == 0 {
+		step = math.Abs(sl.Max-sl.Min) / 20
+	}
+	if sl.Min > sl.Max {
+		dir = -dir
+	}
+	prev := sl.valueLocked()
+	v := sl.setLocked(prev + dir*step)
+	sl.mu.Unlock()
+	if v == prev {
+		return false
+	}
+	sl.changed(s, v)
+	return true
+}
+
+// changed renders the slider and reports the new value.
+func (sl *Slider) changed(s *Surface, v float64) {
+	sl.render(s, false)
+	if sl.OnChange != nil {
+		sl.OnChange(v)
+	}
+}
+
+// render draws the slider's keys on s. Only keys whose fill has changed
+// are drawn unless all is true.
+func (sl *Slider) render(s *Surface, all bool) error {
+	bounds, err := s.Bounds()
+	if err != nil {
+		return err
+	}
+	fill, empty := sl.Fill, sl.Empty
+	if fill == nil {
+		fill = color.RGBA{G: 0xc0, B: 0xff, A: 0xff}
+	}
+	if empty == nil {
+		empty = color.RGBA{R: 0x30, G: 0x30, B: 0x30, A: 0xff}
+	}
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if all || len(sl.shown) != sl.Keys {
+		sl.shown = make([]int, sl.Keys)
+		for i := range sl.shown {
+			sl.shown[i] = -1
+		}
+	}
+	var frac float64
+	if sl.Max != sl.Min {
+		frac = (sl.valueLocked() - sl.Min) / (sl.Max - sl.Min)
+	}
+	w := bounds.Dx()
+	filled := int(math.Round(frac * float64(sl.Keys*w)))
+	for i := 0; i < sl.Keys; i++ {
+		px := filled - i*w
+		if px < 0 {
+			px = 0
+		}
+		if px > w {
+			px = w
+		}
+		if sl.shown[i] == px {
+			continue
+		}
+		img := image.NewRGBA(bounds)
+		draw.Draw(img, bounds, image.Black, image.Point{}, draw.Src)
+		bar := image.Rect(bounds.Min.X, bounds.Min.Y+bounds.Dy()/3, bounds.Max.X, bounds.Max.Y-bounds.Dy()/3)
+		draw.Draw(img, bar, image.NewUniform(empty), image.Point{}, draw.Src)
+		bar.Max.X = bar.Min.X + px
+		draw.Draw(img, bar, image.NewUniform(fill), image.Point{}, draw.Src)
+		var mark string
+		switch i {
+		case 0:
+			mark = "−"
+		case sl.Keys - 1:
+			mark = "+"
+		}
+		if mark != "" {
+			m, err := label.Render(bounds, mark, float64(bounds.Dy())/2, color.White, color.Transparent)
+			if err != nil {
+				return err
+			}
+			draw.Draw(img, bounds, m, bounds.Min, draw.Over)
+		}
+		err = s.SetImage(sl.Row, sl.Col+i, img)
+		if err != nil {
+			sl.shown[i] = -1
+			return err
+		}
+		sl.shown[i] = px
+	}
+	return nil
+}
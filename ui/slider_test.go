@@ -0,0 +1,95 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/animation"
+	"github.com/kortschak/ardilla/internal/decktest"
+)
+
+func TestSlider(t *testing.T) {
+	d, dev := decktest.New(t)
+	c := New(d)
+
+	changes := make(chan float64, 10)
+	clock := animation.NewManualClock(time.Unix(0, 0))
+	sl := &Slider{
+		Row: 1, Col: 0, Keys: 5,
+		Min: 0, Max: 100, Step: 5,
+		OnChange: func(v float64) { changes <- v },
+		clock:    clock,
+	}
+	err := c.Show(sl)
+	if err != nil {
+		t.Fatalf("unexpected error showing slider: %v", err)
+	}
+	for col := 1; col < 4; col++ {
+		if got := dev.Colour(1, col); got != "black" {
+			t.Errorf("unexpected colour at 1,%d for empty slider: got:%s want:black", col, got)
+		}
+	}
+
+	// Pressing an inner key jumps to its position.
+	c.Dispatch(ardilla.KeyEvent{Row: 1, Col: 3, Pressed: true})
+	c.Dispatch(ardilla.KeyEvent{Row: 1, Col: 3})
+	if got := <-changes; got != 75 {
+		t.Errorf("unexpected value after jump: got:%v want:75", got)
+	}
+	for col := 1; col < 4; col++ {
+		if got := dev.Colour(1, col); got != "cyan" {
+			t.Errorf("unexpected colour at 1,%d after jump: got:%s want:cyan", col, got)
+		}
+	}
+
+	// Holding an edge key repeats the nudge until release.
+	c.Dispatch(ardilla.KeyEvent{Row: 1, Col: 0, Pressed: true})
+	if got := <-changes; got != 70 {
+		t.Errorf("unexpected value after nudge: got:%v want:70", got)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(sliderRepeatDelay)
+	if got := <-changes; got != 65 {
+		t.Errorf("unexpected value after repeat delay: got:%v want:65", got)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(sliderRepeatInterval)
+	if got := <-changes; got != 60 {
+		t.Errorf("unexpected value after repeat interval: got:%v want:60", got)
+	}
+	clock.BlockUntil(1)
+	c.Dispatch(ardilla.KeyEvent{Row: 1, Col: 0})
+	clock.BlockUntil(0)
+	if got := dev.Colour(1, 3); got != "black" {
+		t.Errorf("unexpected colour at 1,3 after nudges: got:%s want:black", got)
+	}
+
+	// Values are limited to the range and set
+	// without reporting a change.
+	err = sl.SetValue(150)
+	if err != nil {
+		t.Fatalf("unexpected error setting value: %v", err)
+	}
+	if got := sl.Value(); got != 100 {
+		t.Errorf("unexpected value after set: got:%v want:100", got)
+	}
+	c.Dispatch(ardilla.KeyEvent{Row: 1, Col: 4, Pressed: true})
+	c.Dispatch(ardilla.KeyEvent{Row: 1, Col: 4})
+	select {
+	case v := <-changes:
+		t.Errorf("unexpected change at limit: %v", v)
+	default:
+	}
+
+	if err := c.Show(&Slider{Row: 1, Col: 3, Keys: 3}); err == nil {
+		t.Error("expected error for slider out of bounds")
+	}
+	if err := c.Show(&Slider{Row: 1, Keys: 2}); err == nil {
+		t.Error("expected error for short slider")
+	}
+}
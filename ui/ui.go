@@ -0,0 +1,256 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ui provides on-deck user interface screens for Stream Decks.
+//
+// A Controller shows a stack of screens on a deck. The top screen is
+// visible and receives key events. Screens pushed over it, such as
+// dialogs, take over the whole deck until they are dismissed, when the
+// screen below is shown again.
+package ui
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"sync"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/layout"
+)
+
+// Screen is content that occupies a deck and handles its key events.
+type Screen interface {
+	// Show renders the screen on s. It is called each
+	// time the screen becomes visible. Keys that are
+	// not drawn by Show are cleared to black.
+	Show(s *Surface) error
+
+	// HandleKey is called with each key event received
+	// while the screen is visible.
+	HandleKey(s *Surface, ev ardilla.KeyEvent)
+}
+
+// Controller shows a stack of screens on a deck.
+type Controller struct {
+	d *ardilla.Deck
+
+	// transition serialises changes to the stack.
+	transition sync.Mutex
+
+	// mu protects stack and serialises surface
+	// writes with changes to the visible screen.
+	mu    sync.Mutex
+	stack []*Surface
+}
+
+// New returns a new Controller for the deck.
+func New(d *ardilla.Deck) *Controller {
+	return &Controller{d: d}
+}
+
+// Deck returns the deck controlled by c.
+func (c *Controller) Deck() *ardilla.Deck {
+	return c.d
+}
+
+// Show replaces all the screens held by c with s and shows it.
+func (c *Controller) Show(s Screen) error {
+	c.transition.Lock()
+	defer c.transition.Unlock()
+	surf := &Surface{c: c, screen: s}
+	c.mu.Lock()
+	c.stack = append(c.stack[:0], surf)
+	c.mu.Unlock()
+	return c.show(surf)
+}
+
+// Push shows s over the current screen, which no longer receives key
+// events and can no longer draw on the deck until s is dismissed.
+func (c *Controller) Push(s Screen) error {
+	c.transition.Lock()
+	defer c.transition.Unlock()
+	surf := &Surface{c: c, screen: s}
+	c.mu.Lock()
+	c.stack = append(c.stack, surf)
+	c.mu.Unlock()
+	return c.show(surf)
+}
+
+// Pop dismisses the top screen and shows the screen below it, if any.
+// Pop returns an error if there is no screen.
+func (c *Controller) Pop() error {
+	c.mu.Lock()
+	if len(c.stack) == 0 {
+		c.mu.Unlock()
+		return errors.New("no screen")
+	}
+	top := c.stack[len(c.stack)-1]
+	c.mu.Unlock()
+	return top.Dismiss()
+}
+
+// Top returns the visible screen, or nil if there is none.
+func (c *Controller) Top() Screen {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.stack) == 0 {
+		return nil
+	}
+	return c.stack[len(c.stack)-1].screen
+}
+
+// Dispatch passes ev to the visible screen.
+func (c *Controller) Dispatch(ev ardilla.KeyEvent) {
+	c.mu.Lock()
+	if len(c.stack) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	top := c.stack[len(c.stack)-1]
+	c.mu.Unlock()
+	top.screen.HandleKey(top, ev)
+}
+
+// show renders surf's screen, clearing keys that it does not draw.
+func (c *Controller) show(surf *Surface) error {
+	c.mu.Lock()
+	surf.drawn = make([]bool, c.d.Len())
+	c.mu.Unlock()
+	err := surf.screen.Show(surf)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	drawn := surf.drawn
+	surf.drawn = nil
+	if err != nil || !c.visible(surf) {
+		return err
+	}
+	bounds, err := c.d.Bounds()
+	if err != nil {
+		// Non-visual devices have nothing to clear.
+		return nil
+	}
+	var black image.Image
+	_, cols := c.d.Layout()
+	for key, ok := range drawn {
+		if ok {
+			continue
+		}
+		if black == nil {
+			black = layout.Fill(bounds, color.Black)
+		}
+		err = c.d.SetImage(key/cols, key%cols, black)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visible returns whether surf is the top of the stack. It must be
+// called with c.mu held.
+func (c *Controller) visible(surf *Surface) bool {
+	return len(c.stack) != 0 && c.stack[len(c.stack)-1] == surf
+}
+
+// Surface is a screen's view of a deck. Drawing on the surface of a
+// screen that is not visible is discarded.
+type Surface struct {
+	c      *Controller
+	screen Screen
+
+	// drawn records the keys drawn while
+	// the screen is being shown.
+	drawn []bool
+}
+
+// Controller returns the controller holding the surface's screen.
+func (s *Surface) Controller() *Controller {
+	return s.c
+}
+
+// Visible returns whether the surface's screen is visible.
+func (s *Surface) Visible() bool {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	return s.c.visible(s)
+}
+
+// Layout returns the number of rows and columns of keys on the deck.
+func (s *Surface) Layout() (rows, cols int) {
+	return s.c.d.Layout()
+}
+
+// Bounds returns the image bounds of the deck's keys.
+func (s *Surface) Bounds() (image.Rectangle, error) {
+	return s.c.d.Bounds()
+}
+
+// SetImage renders img on the key at row and column if the surface's
+// screen is visible. See ardilla.Deck.SetImage.
+func (s *Surface) SetImage(row, col int, img image.Image) error {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	if !s.c.visible(s) {
+		return nil
+	}
+	err := s.c.d.SetImage(row, col, img)
+	if err == nil && s.drawn != nil {
+		s.drawn[s.c.d.Key(row, col)] = true
+	}
+	return err
+}
+
+// SetCanvas renders img across the deck if the surface's screen is
+// visible. See ardilla.Deck.SetCanvas.
+func (s *Surface) SetCanvas(img image.Image, gap int) error {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	if !s.c.visible(s) {
+		return nil
+	}
+	err := s.c.d.SetCanvas(img, gap)
+	if err == nil && s.drawn != nil {
+		for i := range s.drawn {
+			s.drawn[i] = true
+		}
+	}
+	return err
+}
+
+// Redraw shows the surface's screen again if it is visible, clearing keys
+// that it does not draw. Screens that change their content as a whole,
+// such as by switching pages, can use Redraw to render the change.
+func (s *Surface) Redraw() error {
+	if !s.Visible() {
+		return nil
+	}
+	return s.c.show(s)
+}
+
+// Dismiss removes the surface's screen from its controller. If the screen
+// was visible, the screen below it is shown.
+func (s *Surface) Dismiss() error {
+	c := s.c
+	c.transition.Lock()
+	defer c.transition.Unlock()
+	c.mu.Lock()
+	wasTop := c.visible(s)
+	for i, surf := range c.stack {
+		if surf == s {
+			c.stack = append(c.stack[:i], c.stack[i+1:]...)
+			break
+		}
+	}
+	var next *Surface
+	if wasTop && len(c.stack) != 0 {
+		next = c.stack[len(c.stack)-1]
+	}
+	c.mu.Unlock()
+	if next == nil {
+		return nil
+	}
+	return c.show(next)
+}
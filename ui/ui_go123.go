@@ -0,0 +1,24 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package ui
+
+import "context"
+
+// Run dispatches key events from the controller's deck to the visible
+// screen until ctx is cancelled or reading events fails, returning the
+// error that ended the event stream.
+//
+// Run requires Go 1.23.
+func (c *Controller) Run(ctx context.Context) error {
+	for ev := range c.d.Events(ctx) {
+		if ev.Err != nil {
+			return ev.Err
+		}
+		c.Dispatch(ev)
+	}
+	return ctx.Err()
+}
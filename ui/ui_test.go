@@ -0,0 +1,173 @@
+// Copyright ©2023 Dan Kortschak. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ui
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/kortschak/ardilla"
+	"github.com/kortschak/ardilla/internal/decktest"
+	"github.com/kortschak/ardilla/layout"
+)
+
+func TestControllerPushPop(t *testing.T) {
+	d, dev := decktest.New(t)
+	c := New(d)
+
+	base := &colourScreen{keys: map[[2]int]color.Color{
+		{0, 0}: color.RGBA{R: 0xff, A: 0xff},
+		{1, 1}: color.RGBA{G: 0xff, A: 0xff},
+	}}
+	err := c.Show(base)
+	if err != nil {
+		t.Fatalf("unexpected error showing base: %v", err)
+	}
+	if got := dev.Colour(0, 0); got != "red" {
+		t.Errorf("unexpected colour at 0,0: got:%s want:red", got)
+	}
+	if got := dev.Colour(2, 4); got != "black" {
+		t.Errorf("unexpected colour at 2,4: got:%s want:black", got)
+	}
+
+	overlay := &colourScreen{keys: map[[2]int]color.Color{
+		{1, 1}: color.RGBA{B: 0xff, A: 0xff},
+	}}
+	err = c.Push(overlay)
+	if err != nil {
+		t.Fatalf("unexpected error pushing overlay: %v", err)
+	}
+	if got := dev.Colour(0, 0); got != "black" {
+		t.Errorf("unexpected colour at 0,0 under overlay: got:%s want:black", got)
+	}
+	if got := dev.Colour(1, 1); got != "blue" {
+		t.Errorf("unexpected colour at 1,1 under overlay: got:%s want:blue", got)
+	}
+
+	// The base screen may not draw while it is covered, and
+	// does not receive events.
+	err = base.surface.SetImage(2, 0, layout.Fill(image.Rect(0, 0, 72, 72), color.White))
+	if err != nil {
+		t.Errorf("unexpected error drawing on hidden surface: %v", err)
+	}
+	if got := dev.Colour(2, 0); got != "black" {
+		t.Errorf("hidden surface drew on deck: got:%s want:black", got)
+	}
+	c.Dispatch(ardilla.KeyEvent{Row: 1, Col: 1, Pressed: true})
+	if len(base.events) != 0 || len(overlay.events) != 1 {
+		t.Errorf("unexpected event dispatch: base:%d overlay:%d", len(base.events), len(overlay.events))
+	}
+	if base.surface.Visible() {
+		t.Error("covered surface reported as visible")
+	}
+
+	err = c.Pop()
+	if err != nil {
+		t.Fatalf("unexpected error popping overlay: %v", err)
+	}
+	if c.Top() != Screen(base) {
+		t.Error("base screen not restored")
+	}
+	want := map[[2]int]string{{0, 0}: "red", {1, 1}: "green", {2, 0}: "black"}
+	for k, w := range want {
+		if got := dev.Colour(k[0], k[1]); got != w {
+			t.Errorf("unexpected colour at %d,%d after pop: got:%s want:%s", k[0], k[1], got, w)
+		}
+	}
+	if base.shown != 2 {
+		t.Errorf("unexpected number of base shows: got:%d want:2", base.shown)
+	}
+	c.Dispatch(ardilla.KeyEvent{Row: 0, Col: 0, Pressed: true})
+	if len(base.events) != 1 {
+		t.Errorf("base did not receive event after pop")
+	}
+
+	err = c.Pop()
+	if err != nil {
+		t.Fatalf("unexpected error popping base: %v", err)
+	}
+	if c.Pop() == nil {
+		t.Error("expected error popping empty controller")
+	}
+}
+
+func TestDialog(t *testing.T) {
+	d, dev := decktest.New(t)
+	c := New(d)
+	base := &colourScreen{keys: map[[2]int]color.Color{
+		{0, 0}: color.RGBA{R: 0xff, A: 0xff},
+	}}
+	err := c.Show(base)
+	if err != nil {
+		t.Fatalf("unexpected error showing base: %v", err)
+	}
+
+	var chosen []string
+	dialog := &Dialog{
+		Message: layout.Fill(image.Rect(0, 0, 100, 60), color.RGBA{R: 0xff, G: 0xff, A: 0xff}),
+		Buttons: []Button{
+			{Row: 2, Col: 0, Label: "Yes", Value: "yes"},
+			{Row: 2, Col: 4, Image: layout.Fill(image.Rect(0, 0, 72, 72), color.RGBA{B: 0xff, A: 0xff}), Value: "no"},
+		},
+		Choose: func(v string) { chosen = append(chosen, v) },
+	}
+	err = c.Push(dialog)
+	if err != nil {
+		t.Fatalf("unexpected error pushing dialog: %v", err)
+	}
+	if got := dev.Colour(1, 2); got != "yellow" {
+		t.Errorf("unexpected message colour: got:%s want:yellow", got)
+	}
+	if got := dev.Colour(2, 4); got != "blue" {
+		t.Errorf("unexpected button colour: got:%s want:blue", got)
+	}
+
+	c.Dispatch(ardilla.KeyEvent{Row: 0, Col: 0, Pressed: true})
+	c.Dispatch(ardilla.KeyEvent{Row: 2, Col: 4})
+	if len(chosen) != 0 || c.Top() != Screen(dialog) {
+		t.Fatalf("dialog dismissed by non-button event: %q", chosen)
+	}
+	c.Dispatch(ardilla.KeyEvent{Row: 2, Col: 4, Pressed: true})
+	if !reflect.DeepEqual(chosen, []string{"no"}) {
+		t.Errorf("unexpected choice: got:%q want:[no]", chosen)
+	}
+	if c.Top() != Screen(base) {
+		t.Error("base screen not restored after dialog")
+	}
+	if got := dev.Colour(0, 0); got != "red" {
+		t.Errorf("unexpected colour at 0,0 after dialog: got:%s want:red", got)
+	}
+}
+
+// colourScreen is a screen that fills keys with solid colours and
+// records the key events it receives.
+type colourScreen struct {
+	keys    map[[2]int]color.Color
+	surface *Surface
+	shown   int
+	events  []ardilla.KeyEvent
+}
+
+func (s *colourScreen) Show(surf *Surface) error {
+	s.surface = surf
+	s.shown++
+	b, err := surf.Bounds()
+	if err != nil {
+		return err
+	}
+	for k, c := range s.keys {
+		err := surf.SetImage(k[0], k[1], layout.Fill(b, c))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *colourScreen) HandleKey(_ *Surface, ev ardilla.KeyEvent) {
+	s.events = append(s.events, ev)
+}